@@ -0,0 +1,227 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// svgrender renders .svg files to PNG or PDF files, without opening a
+// window -- for rasterizing icon sets in build scripts, and for regression
+// testing the svg renderer against a corpus of files.
+//
+// Usage:
+//
+//	svgrender [flags] file.svg|'glob*.svg' ...
+//
+// Each argument can be a file or a glob pattern (quote it to keep the shell
+// from expanding it), as can -glob.  Each input is written to a file of the
+// same name with the .png or .pdf extension, in -outdir if given or next to
+// the input otherwise -- -o names the output for a single input.  With no
+// -width or -height the natural size of the svg (its width / height or
+// viewBox) is used, scaled by -dpi / 96 -- if only one is given the other
+// keeps the aspect ratio.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/driver/offscreen"
+	"github.com/goki/gi/svg"
+)
+
+var (
+	width  = flag.Int("width", 0, "width of the output in pixels -- 0 = natural width of the svg, or from -height keeping the aspect ratio")
+	height = flag.Int("height", 0, "height of the output in pixels -- 0 = natural height of the svg, or from -width keeping the aspect ratio")
+	dpi    = flag.Float64("dpi", 96, "resolution of the output in dots per inch -- scales the natural size of the svg (which is at 96 dpi), and sets the page size of pdf output")
+	bg     = flag.String("bg", "", "background color, e.g., white or #fff -- empty = transparent")
+	format = flag.String("format", "", "output format: png or pdf -- empty = from the -o extension, else png")
+	out    = flag.String("o", "", "output file name, for a single input file")
+	outDir = flag.String("outdir", "", "directory for the output files -- empty = same directory as each input")
+	glob   = flag.String("glob", "", "glob pattern of input files, in addition to any arguments")
+	quiet  = flag.Bool("q", false, "do not print the name of each file as it is written")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: svgrender [flags] file.svg|'glob*.svg' ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	files, err := inputFiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "svgrender: %v\n", err)
+		os.Exit(2)
+	}
+	if *out != "" && len(files) > 1 {
+		fmt.Fprintf(os.Stderr, "svgrender: -o given with %v input files -- use -outdir\n", len(files))
+		os.Exit(2)
+	}
+	ext, err := outputExt()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "svgrender: %v\n", err)
+		os.Exit(2)
+	}
+	if *bg != "" {
+		if _, err := gi.ColorFromString(*bg, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "svgrender: -bg: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "svgrender: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	nerr := 0
+	offscreen.Main(func(app oswin.App) {
+		app.SetName("svgrender")
+		gi.Init()
+		for _, fn := range files {
+			ofn := outputFile(fn, ext)
+			if err := render(fn, ofn, ext); err != nil {
+				fmt.Fprintf(os.Stderr, "svgrender: %v: %v\n", fn, err)
+				nerr++
+				continue
+			}
+			if !*quiet {
+				fmt.Printf("%v -> %v\n", fn, ofn)
+			}
+		}
+	})
+	if nerr > 0 {
+		fmt.Fprintf(os.Stderr, "svgrender: %v of %v files failed\n", nerr, len(files))
+		os.Exit(1)
+	}
+}
+
+// inputFiles returns the input files from the arguments and -glob, in order
+// and without duplicates
+func inputFiles() ([]string, error) {
+	pats := flag.Args()
+	if *glob != "" {
+		pats = append(pats, *glob)
+	}
+	if len(pats) == 0 {
+		return nil, errors.New("no input files")
+	}
+	var files []string
+	have := make(map[string]bool)
+	for _, pat := range pats {
+		fns, err := filepath.Glob(pat)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", pat, err)
+		}
+		if len(fns) == 0 {
+			return nil, fmt.Errorf("%v: no matching files", pat)
+		}
+		for _, fn := range fns {
+			if !have[fn] {
+				have[fn] = true
+				files = append(files, fn)
+			}
+		}
+	}
+	return files, nil
+}
+
+// outputExt returns the extension for the output format
+func outputExt() (string, error) {
+	ft := strings.ToLower(*format)
+	if ft == "" {
+		ft = strings.TrimPrefix(strings.ToLower(filepath.Ext(*out)), ".")
+	}
+	switch ft {
+	case "", "png":
+		return ".png", nil
+	case "pdf":
+		return ".pdf", nil
+	}
+	return "", errors.New("format must be png or pdf, not: " + ft)
+}
+
+// outputFile returns the output file name for given input file
+func outputFile(fn, ext string) string {
+	if *out != "" {
+		return *out
+	}
+	ofn := strings.TrimSuffix(fn, filepath.Ext(fn)) + ext
+	if *outDir != "" {
+		ofn = filepath.Join(*outDir, filepath.Base(ofn))
+	}
+	return ofn
+}
+
+// render renders svg file fn into output file ofn in the format of ext
+func render(fn, ofn, ext string) error {
+	sv := &svg.SVG{}
+	sv.InitName(sv, filepath.Base(fn))
+	if err := sv.OpenXML(fn); err != nil {
+		return err
+	}
+	sz, err := outputSize(sv.ViewBox.Size)
+	if err != nil {
+		return err
+	}
+	sv.Norm = true
+	if *bg != "" {
+		sv.Fill = true
+		sv.SetProp("background-color", *bg)
+	}
+	sv.Resize(sz)
+	if ext == ".pdf" {
+		return savePDF(sv, ofn)
+	}
+	sv.FullRender2DTree()
+	return sv.SavePNG(ofn)
+}
+
+// outputSize returns the size of the output in pixels, given the natural
+// size of the svg
+func outputSize(nat gi.Vec2D) (image.Point, error) {
+	sc := float32(*dpi) / 96
+	w, h := float32(*width), float32(*height)
+	switch {
+	case w > 0 && h > 0:
+	case nat.X <= 0 || nat.Y <= 0:
+		return image.ZP, errors.New("svg has no width / height or viewBox -- both -width and -height are needed")
+	case w > 0:
+		h = w * nat.Y / nat.X
+	case h > 0:
+		w = h * nat.X / nat.Y
+	default:
+		w, h = nat.X*sc, nat.Y*sc
+	}
+	sz := image.Point{int(math.Ceil(float64(w))), int(math.Ceil(float64(h)))}
+	if sz.X <= 0 || sz.Y <= 0 {
+		return image.ZP, fmt.Errorf("invalid output size: %v", sz)
+	}
+	return sz, nil
+}
+
+// savePDF renders the svg as vector graphics into a one-page pdf file, with
+// the page size set from the -dpi resolution
+func savePDF(sv *svg.SVG, ofn string) error {
+	f, err := os.Create(ofn)
+	if err != nil {
+		return err
+	}
+	pd := gi.NewPDF(f)
+	pd.PageSize = gi.Vec2DZero
+	pd.DPI = float32(*dpi)
+	pd.Title = sv.Title
+	pd.Render(sv.AsViewport2D())
+	err = pd.Close()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/driver/offscreen"
+)
+
+const testSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20" viewBox="0 0 40 20">
+  <rect x="0" y="0" width="20" height="20" fill="#ff0000"/>
+</svg>
+`
+
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "svgrender")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "test.svg")
+	if err := ioutil.WriteFile(fn, []byte(testSVG), 0644); err != nil {
+		t.Fatal(err)
+	}
+	offscreen.Main(func(app oswin.App) {
+		for _, ext := range []string{".png", ".pdf"} {
+			if err := render(fn, outputFile(fn, ext), ext); err != nil {
+				t.Errorf("%v: %v", ext, err)
+			}
+		}
+	})
+
+	f, err := os.Open(filepath.Join(dir, "test.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sz := img.Bounds().Size(); sz.X != 40 || sz.Y != 20 {
+		t.Errorf("png size %v, want 40x20", sz)
+	}
+	if r, g, b, a := img.At(5, 10).RGBA(); r != 0xffff || g != 0 || b != 0 || a != 0xffff {
+		t.Errorf("png rect not rendered: %v %v %v %v", r, g, b, a)
+	}
+	if _, _, _, a := img.At(35, 10).RGBA(); a != 0 {
+		t.Errorf("png background not transparent")
+	}
+
+	pdf, err := ioutil.ReadFile(filepath.Join(dir, "test.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.Contains(pdf, []byte("/Count 1 ")) {
+		t.Errorf("not a one-page pdf")
+	}
+	if !bytes.Contains(pdf, []byte("/MediaBox [0 0 30 15]")) {
+		t.Errorf("pdf page is not the size of the svg, at 96 dpi")
+	}
+}
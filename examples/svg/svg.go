@@ -24,6 +24,7 @@ func main() {
 
 var CurFilename = ""
 var TheSVG *svg.Editor
+var TheTreeView *giv.TreeView
 var TheZoom *gi.SpinBox
 var TheTransX *gi.SpinBox
 var TheTransY *gi.SpinBox
@@ -49,6 +50,7 @@ func OpenSVG(fnm string) {
 	TheSVG.SetFullReRender()
 	fmt.Printf("Opening: %v\n", CurFilename)
 	TheSVG.OpenXML(CurFilename)
+	TheTreeView.SetRootNode(TheSVG.This())
 	SetZoom(TheSVG.Viewport.Win.LogicalDPI() / 96.0)
 	SetTrans(0, 0)
 	TheSVG.UpdateEnd(updt)
@@ -64,13 +66,63 @@ func FileViewOpenSVG(vp *gi.Viewport2D) {
 		})
 }
 
+// inSync is set while syncing the selection between the svg and the tree
+// view, so that it does not go back and forth
+var inSync = false
+
+// SyncTreeView syncs the selection of the svg editor with the tree view of
+// it, in both directions
+func SyncTreeView(svge *svg.Editor, tv *giv.TreeView) {
+	tv.TreeViewSig.Connect(svge.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if inSync {
+			return
+		}
+		var sl []gi.Node2D
+		switch sig {
+		case int64(giv.TreeViewSelected):
+			for _, sn := range tv.SelectedSrcNodes() {
+				if _, ok := sn.(svg.NodeSVG); ok && sn != svge.This() {
+					sl = append(sl, sn.(gi.Node2D))
+				}
+			}
+		case int64(giv.TreeViewAllUnselected):
+		default:
+			return
+		}
+		inSync = true
+		svge.SetSelected(sl)
+		inSync = false
+	})
+	svge.SelectSig.Connect(tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if inSync || tv.Viewport == nil {
+			return
+		}
+		sl := data.([]gi.Node2D)
+		inSync = true
+		tv.UnselectAll()
+		tv.FuncDownMeFirst(0, nil, func(k ki.Ki, level int, d interface{}) bool {
+			tvn, ok := k.Embed(giv.KiT_TreeView).(*giv.TreeView)
+			if !ok {
+				return true
+			}
+			for _, sn := range sl {
+				if tvn.SrcNode.Ptr == sn.This() {
+					tvn.Select()
+				}
+			}
+			return true
+		})
+		inSync = false
+	})
+}
+
 func mainrun() {
 	width := 1600
 	height := 1200
 
 	gi.SetAppName("svg")
 	gi.SetAppAbout(`This is a demo of the SVG rendering (and start on editing) in the <b>GoGi</b> graphical interface system, within the <b>GoKi</b> tree framework.  See <a href="https://github.com/goki">GoKi on GitHub</a>
-<p>You can drag the image around and use the scroll wheel to zoom.</p>`)
+<p>Click or drag a box around elements to select them, and drag the handles to move, scale and rotate them -- a selected path shows its points for editing.  Drag with the middle mouse button or with Alt to move the image around, and use the scroll wheel to zoom.  Undo and redo edits with the standard keys.</p>`)
 
 	win := gi.NewWindow2D("gogi-svg-viewer", "GoGi SVG Viewer", width, height, true)
 
@@ -90,8 +142,20 @@ func mainrun() {
 	svgrow.SetStretchMaxWidth()
 	svgrow.SetStretchMaxHeight()
 
-	svge := svgrow.AddNewChild(svg.KiT_Editor, "svg").(*svg.Editor)
+	split := svgrow.AddNewChild(gi.KiT_SplitView, "split").(*gi.SplitView)
+	split.Dim = gi.X
+
+	tvfr := split.AddNewChild(gi.KiT_Frame, "tvfr").(*gi.Frame)
+	svfr := split.AddNewChild(gi.KiT_Frame, "svfr").(*gi.Frame)
+	split.SetSplits(.2, .8)
+
+	tv := tvfr.AddNewChild(giv.KiT_TreeView, "tv").(*giv.TreeView)
+
+	svge := svfr.AddNewChild(svg.KiT_Editor, "svg").(*svg.Editor)
 	TheSVG = svge
+	TheTreeView = tv
+	tv.SetRootNode(svge.This())
+	SyncTreeView(svge, tv)
 	svge.InitScale()
 	svge.Fill = true
 	svge.SetProp("background-color", "white")
@@ -154,6 +218,11 @@ func mainrun() {
 	try.SetValue(svge.Trans.Y)
 	TheTransY = try
 
+	tbar.AddNewChild(gi.KiT_Space, "spcsn")
+	snap := tbar.AddNewChild(gi.KiT_CheckBox, "snap").(*gi.CheckBox)
+	snap.Text = "Snap to Grid"
+	snap.Tooltip = "show the grid, and snap moved elements and points to it"
+
 	loads.ActionSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
 		FileViewOpenSVG(vp)
 	})
@@ -182,6 +251,16 @@ func mainrun() {
 		win.FullReRender()
 	})
 
+	snap.ButtonSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if sig == int64(gi.ButtonToggled) {
+			cb := send.(*gi.CheckBox)
+			svge.SnapGrid = cb.IsChecked()
+			svge.ShowGrid = svge.SnapGrid
+			svge.SetFullReRender()
+			svge.UpdateSig()
+		}
+	})
+
 	svge.NodeSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
 		ssvg := send.Embed(svg.KiT_Editor).(*svg.Editor)
 		SetZoom(ssvg.Scale)
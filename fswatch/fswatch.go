@@ -0,0 +1,204 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fswatch watches directories for changes to the files in them, for
+// keeping views of the file system in sync with changes made by other
+// programs.  It uses inotify on Linux, and otherwise (or if inotify is not
+// available) polls the directories.
+package fswatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op is the kind of change to a file -- bit flags
+type Op uint32
+
+const (
+	// Create is a file that was created, or moved into the directory
+	Create Op = 1 << iota
+
+	// Write is a file whose contents were written
+	Write
+
+	// Remove is a file that was removed
+	Remove
+
+	// Rename is a file that was renamed, or moved out of the directory --
+	// its new name, if still watched, has a Create event
+	Rename
+
+	// Rescan is sent when changes were lost, e.g., when the inotify queue
+	// overflowed -- Name is empty, and all the watched directories must be
+	// read again
+	Rescan
+)
+
+func (op Op) String() string {
+	var ops []string
+	for i, nm := range []string{"Create", "Write", "Remove", "Rename", "Rescan"} {
+		if op&(1<<uint(i)) != 0 {
+			ops = append(ops, nm)
+		}
+	}
+	return strings.Join(ops, "|")
+}
+
+// Event is a change to a file in a watched directory, or to the directory
+// itself
+type Event struct {
+	Name string `desc:"full path of the file"`
+	Op   Op     `desc:"what happened to it"`
+}
+
+// Watcher watches directories for changes to the files immediately within
+// them (not recursively), reporting them on its Events channel, which is
+// closed when the watcher is closed
+type Watcher interface {
+	// Add starts watching given directory -- does nothing if already watched
+	Add(dir string) error
+
+	// Remove stops watching given directory
+	Remove(dir string) error
+
+	// Events returns the channel that the changes are sent on -- it must be
+	// read continually, else the watcher blocks
+	Events() <-chan Event
+
+	// Close stops watching all directories, and closes the Events channel
+	Close() error
+}
+
+// PollInterval is how often the directories are checked for changes when
+// polling
+var PollInterval = time.Second
+
+// New returns a new Watcher, using inotify on Linux, and otherwise polling
+// every PollInterval
+func New() (Watcher, error) {
+	w, err := newNative()
+	if err == nil {
+		return w, nil
+	}
+	return NewPoll(PollInterval), nil
+}
+
+// pollWatcher is a Watcher that checks the directories for changes at
+// regular intervals, comparing the modification times and sizes of the
+// files -- renames are reported as a Remove and a Create
+type pollWatcher struct {
+	mu     sync.Mutex
+	dirs   map[string]map[string]os.FileInfo
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewPoll returns a new Watcher that polls the directories every interval
+func NewPoll(interval time.Duration) Watcher {
+	pw := &pollWatcher{dirs: make(map[string]map[string]os.FileInfo), events: make(chan Event, 100), done: make(chan struct{})}
+	go pw.run(interval)
+	return pw
+}
+
+// readDir returns the files in given directory, by name
+func readDir(dir string) (map[string]os.FileInfo, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fm := make(map[string]os.FileInfo, len(fis))
+	for _, fi := range fis {
+		fm[fi.Name()] = fi
+	}
+	return fm, nil
+}
+
+func (pw *pollWatcher) Add(dir string) error {
+	dir = filepath.Clean(dir)
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if _, has := pw.dirs[dir]; has {
+		return nil
+	}
+	fm, err := readDir(dir)
+	if err != nil {
+		return err
+	}
+	pw.dirs[dir] = fm
+	return nil
+}
+
+func (pw *pollWatcher) Remove(dir string) error {
+	pw.mu.Lock()
+	delete(pw.dirs, filepath.Clean(dir))
+	pw.mu.Unlock()
+	return nil
+}
+
+func (pw *pollWatcher) Events() <-chan Event {
+	return pw.events
+}
+
+func (pw *pollWatcher) Close() error {
+	pw.once.Do(func() { close(pw.done) })
+	return nil
+}
+
+// run polls until closed
+func (pw *pollWatcher) run(interval time.Duration) {
+	defer close(pw.events)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-pw.done:
+			return
+		case <-tick.C:
+		}
+		for _, ev := range pw.poll() {
+			select {
+			case pw.events <- ev:
+			case <-pw.done:
+				return
+			}
+		}
+	}
+}
+
+// poll returns the changes since the last poll
+func (pw *pollWatcher) poll() []Event {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	var evs []Event
+	for dir, ofm := range pw.dirs {
+		fm, err := readDir(dir)
+		if err != nil {
+			evs = append(evs, Event{Name: dir, Op: Remove})
+			delete(pw.dirs, dir)
+			continue
+		}
+		for nm := range ofm { // removes first, as for the old name of a rename
+			if _, has := fm[nm]; !has {
+				evs = append(evs, Event{Name: filepath.Join(dir, nm), Op: Remove})
+			}
+		}
+		for nm, fi := range fm {
+			ofi, has := ofm[nm]
+			switch {
+			case !has:
+				evs = append(evs, Event{Name: filepath.Join(dir, nm), Op: Create})
+			case !fi.IsDir() && (fi.ModTime() != ofi.ModTime() || fi.Size() != ofi.Size()):
+				evs = append(evs, Event{Name: filepath.Join(dir, nm), Op: Write})
+			}
+		}
+		pw.dirs[dir] = fm
+	}
+	return evs
+}
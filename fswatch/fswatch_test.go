@@ -0,0 +1,93 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fswatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor waits for an event on given file with any of given ops
+func waitFor(t *testing.T, w Watcher, name string, op Op) {
+	t.Helper()
+	tmo := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-w.Events():
+			if !ok {
+				t.Fatalf("events closed waiting for %v on %v", op, name)
+			}
+			if ev.Name == name && ev.Op&op != 0 {
+				return
+			}
+		case <-tmo:
+			t.Fatalf("timed out waiting for %v on %v", op, name)
+		}
+	}
+}
+
+func testWatcher(t *testing.T, w Watcher) {
+	dir, err := ioutil.TempDir("", "fswatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	a := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(a, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, a, Create)
+
+	if err := ioutil.WriteFile(a, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, a, Write)
+
+	b := filepath.Join(dir, "b.txt")
+	if err := os.Rename(a, b); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, a, Rename|Remove) // polling can't tell renames
+	waitFor(t, w, b, Create)
+
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, w, b, Remove)
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range w.Events() { // must be closed
+	}
+}
+
+func TestNew(t *testing.T) {
+	w, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testWatcher(t, w)
+}
+
+func TestPoll(t *testing.T) {
+	testWatcher(t, NewPoll(20*time.Millisecond))
+}
+
+func TestOpString(t *testing.T) {
+	if s := (Create | Remove).String(); s != "Create|Remove" {
+		t.Errorf("got %q", s)
+	}
+	if s := Rescan.String(); s != "Rescan" {
+		t.Errorf("got %q", s)
+	}
+}
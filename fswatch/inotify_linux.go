@@ -0,0 +1,149 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package fswatch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask are the inotify events watched for
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_DELETE | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyWatcher is a Watcher using Linux inotify
+type inotifyWatcher struct {
+	fd     int
+	f      *os.File
+	mu     sync.Mutex
+	dirs   map[int32]string
+	wds    map[string]int32
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newNative returns an inotify Watcher
+func newNative() (Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	iw := &inotifyWatcher{fd: fd, dirs: make(map[int32]string), wds: make(map[string]int32), events: make(chan Event, 100), done: make(chan struct{})}
+	iw.f = os.NewFile(uintptr(fd), "inotify") // non-blocking, so Close ends a pending Read
+	go iw.run()
+	return iw, nil
+}
+
+func (iw *inotifyWatcher) Add(dir string) error {
+	dir = filepath.Clean(dir)
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	if _, has := iw.wds[dir]; has {
+		return nil
+	}
+	wd, err := syscall.InotifyAddWatch(iw.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	iw.dirs[int32(wd)] = dir
+	iw.wds[dir] = int32(wd)
+	return nil
+}
+
+func (iw *inotifyWatcher) Remove(dir string) error {
+	dir = filepath.Clean(dir)
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	wd, has := iw.wds[dir]
+	if !has {
+		return nil
+	}
+	delete(iw.wds, dir)
+	delete(iw.dirs, wd)
+	if _, err := syscall.InotifyRmWatch(iw.fd, uint32(wd)); err != nil {
+		return &os.PathError{Op: "inotify_rm_watch", Path: dir, Err: err}
+	}
+	return nil
+}
+
+func (iw *inotifyWatcher) Events() <-chan Event {
+	return iw.events
+}
+
+func (iw *inotifyWatcher) Close() error {
+	var err error
+	iw.once.Do(func() {
+		close(iw.done) // ends a pending send of an event
+		err = iw.f.Close()
+	})
+	return err
+}
+
+// run reads the inotify events until closed
+func (iw *inotifyWatcher) run() {
+	defer close(iw.events)
+	var buf [4096 * (syscall.SizeofInotifyEvent + 16)]byte
+	for {
+		n, err := iw.f.Read(buf[:])
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ie := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			st := off + syscall.SizeofInotifyEvent
+			off = st + int(ie.Len)
+			nm := string(bytes.TrimRight(buf[st:off], "\x00"))
+			if ie.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				if !iw.send(Event{Op: Rescan}) {
+					return
+				}
+				continue
+			}
+			iw.mu.Lock()
+			dir, has := iw.dirs[ie.Wd]
+			if has && ie.Mask&(syscall.IN_IGNORED|syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 { // watch is gone
+				delete(iw.dirs, ie.Wd)
+				delete(iw.wds, dir)
+			}
+			iw.mu.Unlock()
+			if !has {
+				continue
+			}
+			var op Op
+			switch {
+			case ie.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+				op = Create
+			case ie.Mask&syscall.IN_MODIFY != 0:
+				op = Write
+			case ie.Mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0:
+				op = Remove
+			case ie.Mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0:
+				op = Rename
+			default:
+				continue
+			}
+			if !iw.send(Event{Name: filepath.Join(dir, nm), Op: op}) {
+				return
+			}
+		}
+	}
+}
+
+// send sends given event, returning false if the watcher was closed first
+func (iw *inotifyWatcher) send(ev Event) bool {
+	select {
+	case iw.events <- ev:
+		return true
+	case <-iw.done:
+		return false
+	}
+}
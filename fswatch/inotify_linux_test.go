@@ -0,0 +1,99 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package fswatch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempInotify returns a new inotify watcher of a new temporary directory,
+// and a function that removes it
+func tempInotify(t *testing.T) (Watcher, string, func()) {
+	w, err := newNative()
+	if err != nil {
+		t.Skipf("inotify not available: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "fswatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	return w, dir, func() { w.Close(); os.RemoveAll(dir) }
+}
+
+// writeFiles writes n new files in dir
+func writeFiles(t *testing.T, dir string, n int) {
+	for i := 0; i < n; i++ {
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("f%v.txt", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInotifyCloseUnread(t *testing.T) {
+	w, dir, done := tempInotify(t)
+	defer done()
+	writeFiles(t, dir, 200) // more than the events buffer, none read
+	time.Sleep(100 * time.Millisecond)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tmo := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-w.Events():
+			if !ok {
+				return
+			}
+		case <-tmo:
+			t.Fatal("events not closed after Close")
+		}
+	}
+}
+
+func TestInotifyOverflow(t *testing.T) {
+	b, err := ioutil.ReadFile("/proc/sys/fs/inotify/max_queued_events")
+	if err != nil {
+		t.Skip(err)
+	}
+	maxq, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || maxq > 100000 {
+		t.Skipf("inotify queue too big to overflow: %s", b)
+	}
+	w, dir, done := tempInotify(t)
+	defer done()
+	// the reader takes up to 4096 events in one read of its buffer, and
+	// then blocks sending them to the events channel, of 100, so the kernel
+	// queue only overflows with at least that many more than its max
+	writeFiles(t, dir, maxq+4096+100+1000)
+	tmo := time.After(10 * time.Second)
+	for {
+		select {
+		case ev, ok := <-w.Events():
+			if !ok {
+				t.Fatal("events closed waiting for Rescan")
+			}
+			if ev.Op == Rescan {
+				if ev.Name != "" {
+					t.Errorf("Rescan with name: %v", ev.Name)
+				}
+				return
+			}
+		case <-tmo:
+			t.Fatal("timed out waiting for Rescan")
+		}
+	}
+}
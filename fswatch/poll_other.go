@@ -0,0 +1,15 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package fswatch
+
+import "errors"
+
+// newNative returns an error, as there is no native watcher on this
+// platform yet, so New polls
+func newNative() (Watcher, error) {
+	return nil, errors.New("fswatch: no native file system watcher on this platform")
+}
@@ -26,9 +26,10 @@ import (
 // ColorSpec fully specifies the color for rendering -- used in FillStyle and
 // StrokeStyle
 type ColorSpec struct {
-	Source   ColorSources      `desc:"source of color (solid, gradient)"`
+	Source   ColorSources      `desc:"source of color (solid, gradient, pattern)"`
 	Color    Color             `desc:"color for solid color source"`
 	Gradient *rasterx.Gradient `desc:"gradient parameters for gradient color source"`
+	Pattern  Patterner         `view:"-" json:"-" xml:"-" desc:"paint server for pattern color source"`
 }
 
 var KiT_ColorSpec = kit.Types.AddType(&ColorSpec{}, nil)
@@ -42,6 +43,7 @@ const (
 	SolidColor ColorSources = iota
 	LinearGradient
 	RadialGradient
+	PatternPaint
 	ColorSourcesN
 )
 
@@ -63,10 +65,23 @@ const (
 	GradientPointsN
 )
 
-// IsNil tests for nil solid or gradient colors
+// Patterner is a paint server that fills with a repeating pattern of
+// arbitrary content, e.g., an svg.Pattern -- it is found by url in the same
+// way as a Gradient, setting the PatternPaint source
+type Patterner interface {
+	// PatternColor returns the color for filling or stroking an element
+	// with given bounding box, rendered with given transform, at given
+	// opacity -- a color.Color or rasterx.ColorFunc, as for RenderColor
+	PatternColor(opacity float32, bounds image.Rectangle, xform Matrix2D) interface{}
+}
+
+// IsNil tests for nil solid, gradient or pattern colors
 func (cs *ColorSpec) IsNil() bool {
-	if cs.Source == SolidColor {
+	switch cs.Source {
+	case SolidColor:
 		return cs.Color.IsNil()
+	case PatternPaint:
+		return cs.Pattern == nil
 	}
 	return cs.Gradient == nil
 }
@@ -85,6 +100,7 @@ func (cs *ColorSpec) SetColor(cl color.Color) {
 	cs.Color.SetColor(cl)
 	cs.Source = SolidColor
 	cs.Gradient = nil
+	cs.Pattern = nil
 }
 
 // Copy copies a gradient, making new copies of the stops instead of
@@ -133,6 +149,9 @@ func CopyGradient(dst, src *rasterx.Gradient) {
 // RenderColor gets the color for rendering, applying opacity and bounds for
 // gradients
 func (cs *ColorSpec) RenderColor(opacity float32, bounds image.Rectangle, xform Matrix2D) interface{} {
+	if cs.Source == PatternPaint && cs.Pattern != nil {
+		return cs.Pattern.PatternColor(opacity, bounds, xform)
+	}
 	if cs.Source == SolidColor || cs.Gradient == nil {
 		return rasterx.ApplyOpacity(cs.Color, float64(opacity))
 	} else {
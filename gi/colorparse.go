@@ -50,6 +50,12 @@ func (cs *ColorSpec) SetString(clrstr string, vp *Viewport2D) bool {
 					*cs = grad.Grad
 					return true
 				}
+				if pat, ok := ne.(Patterner); ok {
+					cs.Source = PatternPaint
+					cs.Pattern = pat
+					cs.Gradient = nil
+					return true
+				}
 			}
 		}
 		fmt.Printf("gi.Color Warning: Not able to find url: %v\n", val)
@@ -37,7 +37,8 @@ func (ss *StyleSheet) ParseString(str string) error {
 }
 
 // CSSProps returns the properties for each of the rules in this style sheet,
-// suitable for setting the CSS value of a node -- returns nil if empty sheet
+// suitable for setting the CSS value of a node -- returns nil if empty sheet.
+// Rules for the same selector are merged, with later ones taking precedence.
 func (ss *StyleSheet) CSSProps() ki.Props {
 	if ss.Sheet == nil {
 		return nil
@@ -56,11 +57,14 @@ func (ss *StyleSheet) CSSProps() ki.Props {
 			continue
 		}
 		for _, sel := range r.Selectors {
-			sp := make(ki.Props, nd)
+			sp, ok := pr[sel].(ki.Props)
+			if !ok {
+				sp = make(ki.Props, nd)
+				pr[sel] = sp
+			}
 			for _, de := range r.Declarations {
 				sp[de.Property] = de.Value
 			}
-			pr[sel] = sp
 		}
 	}
 	return pr
@@ -25,6 +25,7 @@ import (
 	"github.com/goki/ki/bitflag"
 	"github.com/goki/ki/ki"
 	"github.com/goki/ki/kit"
+	"github.com/srwiley/rasterx"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/gobold"
 	"golang.org/x/image/font/gofont/gobolditalic"
@@ -40,6 +41,7 @@ import (
 	"golang.org/x/image/font/gofont/gosmallcapsitalic"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
 )
 
 // font.go contains all font and basic SVG-level text rendering styles, and the
@@ -1086,6 +1088,7 @@ func OpenFontFace(path string, size int, strokeWidth int) (font.Face, error) {
 			// Hinting: font.HintingFull,
 			// GlyphCacheEntries: 1024, // default is 512 -- todo benchmark
 		})
+		faceFonts.Store(face, faceFont{f, size})
 		return face, nil
 	}
 }
@@ -1125,6 +1128,7 @@ func OpenGoFont(path string, size int, strokeWidth int) (font.Face, error) {
 		// GlyphCacheEntries: 1024, // default is 512 -- todo benchmark
 
 	})
+	faceFonts.Store(face, faceFont{f, size})
 	return face, nil
 }
 
@@ -1137,3 +1141,81 @@ func (fl *FontLib) GoFontsAvail() {
 		fl.FontInfo = append(fl.FontInfo, fi)
 	}
 }
+
+// faceFont is the truetype font and size of a font face
+type faceFont struct {
+	font *truetype.Font
+	size int
+}
+
+// faceFonts records the faceFont of each truetype font face opened, to get
+// the outlines of its glyphs
+var faceFonts sync.Map
+
+// GlyphPath returns the outline of given rune in given font face, as a path
+// in dots relative to the origin of the glyph on the baseline, with Y
+// increasing downward, to be filled with the non-zero winding rule -- returns
+// false if the outline is not available, e.g., for non-truetype faces
+func GlyphPath(face font.Face, r rune) (rasterx.Path, bool) {
+	ffi, ok := faceFonts.Load(face)
+	if !ok {
+		return nil, false
+	}
+	ff := ffi.(faceFont)
+	gb := &truetype.GlyphBuf{}
+	if err := gb.Load(ff.font, fixed.I(ff.size), ff.font.Index(r), font.HintingNone); err != nil {
+		return nil, false
+	}
+	var p rasterx.Path
+	st := 0
+	for _, end := range gb.Ends {
+		glyphContour(&p, gb.Points[st:end])
+		st = end
+	}
+	return p, true
+}
+
+// glyphContour adds one contour of a truetype glyph to the path -- two
+// consecutive off-curve points imply an on-curve point midway between them
+func glyphContour(p *rasterx.Path, ps []truetype.Point) {
+	if len(ps) == 0 {
+		return
+	}
+	pt := func(q truetype.Point) fixed.Point26_6 { return fixed.Point26_6{X: q.X, Y: -q.Y} }
+	mid := func(a, b fixed.Point26_6) fixed.Point26_6 {
+		return fixed.Point26_6{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+	}
+	start := pt(ps[0])
+	others := ps[1:]
+	if ps[0].Flags&1 == 0 {
+		last := pt(ps[len(ps)-1])
+		if ps[len(ps)-1].Flags&1 != 0 {
+			start = last
+			others = ps[:len(ps)-1]
+		} else {
+			start = mid(start, last)
+			others = ps
+		}
+	}
+	p.Start(start)
+	q0, on0 := start, true
+	for _, q := range others {
+		q1 := pt(q)
+		on := q.Flags&1 != 0
+		switch {
+		case on && on0:
+			p.Line(q1)
+		case on:
+			p.QuadBezier(q0, q1)
+		case !on0:
+			p.QuadBezier(q0, mid(q0, q1))
+		}
+		q0, on0 = q1, on
+	}
+	if on0 {
+		p.Line(start)
+	} else {
+		p.QuadBezier(q0, start)
+	}
+	p.Stop(true)
+}
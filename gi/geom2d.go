@@ -501,6 +501,11 @@ func (a Vec2D) Distance(b Vec2D) float32 {
 	return math32.Hypot(a.X-b.X, a.Y-b.Y)
 }
 
+// Length returns the length of the vector
+func (a Vec2D) Length() float32 {
+	return math32.Hypot(a.X, a.Y)
+}
+
 func (a Vec2D) Interpolate(b Vec2D, t float32) Vec2D {
 	x := a.X + (b.X-a.X)*t
 	y := a.Y + (b.Y-a.Y)*t
@@ -639,6 +644,21 @@ func (a Matrix2D) Skew(x, y float32) Matrix2D {
 	return Skew2D(x, y).Multiply(a)
 }
 
+// Inverse returns the inverse of the matrix, which undoes its transform --
+// returns the identity for a singular matrix, which has no inverse
+func (a Matrix2D) Inverse() Matrix2D {
+	det := a.XX*a.YY - a.XY*a.YX
+	if det == 0 {
+		return Identity2D()
+	}
+	id := 1 / det
+	return Matrix2D{
+		a.YY * id, -a.YX * id,
+		-a.XY * id, a.XX * id,
+		(a.XY*a.Y0 - a.YY*a.X0) * id, (a.YX*a.X0 - a.XX*a.Y0) * id,
+	}
+}
+
 func (a Matrix2D) ToRasterx() rasterx.Matrix2D {
 	return rasterx.Matrix2D{float64(a.XX), float64(a.YX), float64(a.XY), float64(a.YY), float64(a.X0), float64(a.Y0)}
 }
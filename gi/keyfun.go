@@ -82,6 +82,11 @@ const (
 	KeyFunHistPrev
 	KeyFunHistNext
 	KeyFunWinFocusNext
+	KeyFunMultiCursorNext  // add a cursor at the next occurrence of the selection
+	KeyFunMultiCursorLines // split selection into a cursor on each line
+	KeyFunBoxSelectMode    // rectangular (box / column) selection mode
+	KeyFunProblemNext      // move to next diagnostic (error, warning etc)
+	KeyFunProblemPrev      // move to previous diagnostic
 	// Below are menu specific functions -- use these as shortcuts for menu actions
 	// allows uniqueness of mapping and easy customization of all key actions
 	KeyFunMenuNew
@@ -586,6 +591,11 @@ var StdKeyMaps = KeyMaps{
 		"Meta+[":                  KeyFunHistPrev,
 		"Meta+]":                  KeyFunHistNext,
 		"Meta+`":                  KeyFunWinFocusNext,
+		"Meta+D":                  KeyFunMultiCursorNext,
+		"Shift+Meta+L":            KeyFunMultiCursorLines,
+		"Shift+Control+Spacebar":  KeyFunBoxSelectMode,
+		"F8":                      KeyFunProblemNext,
+		"Shift+F8":                KeyFunProblemPrev,
 		"Meta+N":                  KeyFunMenuNew,
 		"Shift+Meta+N":            KeyFunMenuNewAlt1,
 		"Alt+Meta+N":              KeyFunMenuNewAlt2,
@@ -714,6 +724,11 @@ var StdKeyMaps = KeyMaps{
 		"Meta+[":                  KeyFunHistPrev,
 		"Meta+]":                  KeyFunHistNext,
 		"Meta+`":                  KeyFunWinFocusNext,
+		"Meta+D":                  KeyFunMultiCursorNext,
+		"Shift+Meta+L":            KeyFunMultiCursorLines,
+		"Shift+Control+Spacebar":  KeyFunBoxSelectMode,
+		"F8":                      KeyFunProblemNext,
+		"Shift+F8":                KeyFunProblemPrev,
 		"Meta+N":                  KeyFunMenuNew,
 		"Shift+Meta+N":            KeyFunMenuNewAlt1,
 		"Alt+Meta+N":              KeyFunMenuNewAlt2,
@@ -827,6 +842,11 @@ var StdKeyMaps = KeyMaps{
 		"Control+[":               KeyFunHistPrev,
 		"Control+]":               KeyFunHistNext,
 		"Alt+F6":                  KeyFunWinFocusNext,
+		"Shift+Control+D":         KeyFunMultiCursorNext,
+		"Shift+Control+L":         KeyFunMultiCursorLines,
+		"Shift+Control+Spacebar":  KeyFunBoxSelectMode,
+		"F8":                      KeyFunProblemNext,
+		"Shift+F8":                KeyFunProblemPrev,
 		"Alt+N":                   KeyFunMenuNew, // ctrl keys conflict..
 		"Shift+Alt+N":             KeyFunMenuNewAlt1,
 		"Control+Alt+N":           KeyFunMenuNewAlt2,
@@ -910,6 +930,11 @@ var StdKeyMaps = KeyMaps{
 		"Control+]":               KeyFunHistNext,
 		"Control+N":               KeyFunMenuNew,
 		"Alt+F6":                  KeyFunWinFocusNext,
+		"Shift+Control+D":         KeyFunMultiCursorNext,
+		"Shift+Control+L":         KeyFunMultiCursorLines,
+		"Shift+Control+Spacebar":  KeyFunBoxSelectMode,
+		"F8":                      KeyFunProblemNext,
+		"Shift+F8":                KeyFunProblemPrev,
 		"Shift+Control+N":         KeyFunMenuNewAlt1,
 		"Control+Alt+N":           KeyFunMenuNewAlt2,
 		"Control+O":               KeyFunMenuOpen,
@@ -991,6 +1016,11 @@ var StdKeyMaps = KeyMaps{
 		"Control+[":               KeyFunHistPrev,
 		"Control+]":               KeyFunHistNext,
 		"Alt+F6":                  KeyFunWinFocusNext,
+		"Shift+Control+D":         KeyFunMultiCursorNext,
+		"Shift+Control+L":         KeyFunMultiCursorLines,
+		"Shift+Control+Spacebar":  KeyFunBoxSelectMode,
+		"F8":                      KeyFunProblemNext,
+		"Shift+F8":                KeyFunProblemPrev,
 		"Control+N":               KeyFunMenuNew,
 		"Shift+Control+N":         KeyFunMenuNewAlt1,
 		"Control+Alt+N":           KeyFunMenuNewAlt2,
@@ -1073,6 +1103,11 @@ var StdKeyMaps = KeyMaps{
 		"Control+[":               KeyFunHistPrev,
 		"Control+]":               KeyFunHistNext,
 		"Alt+F6":                  KeyFunWinFocusNext,
+		"Shift+Control+D":         KeyFunMultiCursorNext,
+		"Shift+Control+L":         KeyFunMultiCursorLines,
+		"Shift+Control+Spacebar":  KeyFunBoxSelectMode,
+		"F8":                      KeyFunProblemNext,
+		"Shift+F8":                KeyFunProblemPrev,
 		"Control+N":               KeyFunMenuNew,
 		"Shift+Control+N":         KeyFunMenuNewAlt1,
 		"Control+Alt+N":           KeyFunMenuNewAlt2,
@@ -22,6 +22,7 @@ import (
 	"github.com/srwiley/rasterx"
 	"github.com/srwiley/scanx"
 	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
 	"golang.org/x/image/math/f64"
 )
 
@@ -242,9 +243,65 @@ type RenderState struct {
 	XFormStack     []Matrix2D        `desc:"stack of transforms"`
 	BoundsStack    []image.Rectangle `desc:"stack of bounds -- every render starts with a push onto this stack, and finishes with a pop"`
 	ClipStack      []*image.Alpha    `desc:"stack of clips, if needed"`
+	ImageStack     []*image.RGBA     `desc:"stack of images rendered into, for rendering into separate layers, e.g., for clipping and masking"`
 	PaintBack      Paint             `desc:"backup of paint -- don't need a full stack but sometimes safer to backup and restore"`
 	RenderMu       sync.Mutex        `desc:"mutex for overall rendering"`
 	RasterMu       sync.Mutex        `desc:"mutex for final rasterx rendering -- only one at a time"`
+	Vector         VectorRenderer    `json:"-" xml:"-" desc:"if set, all rendering is also sent to this vector renderer, e.g., a PDF, in addition to being rendered into the Image"`
+}
+
+// VectorRenderer receives everything rendered with a RenderState as vector
+// graphics, in addition to its rendering into the Image, e.g., to export it
+// as a PDF document -- set it as the Vector of the RenderState.  All
+// coordinates are device coordinates of the Image, with the current transform
+// already applied, and drawing is limited to the current Bounds.
+type VectorRenderer interface {
+	// FillPath fills the current Path with the fill style of given paint
+	FillPath(rs *RenderState, pc *Paint)
+
+	// StrokePath strokes the current Path with the stroke style of given
+	// paint, with given line width and dashes in device units
+	StrokePath(rs *RenderState, pc *Paint, width float32, dash []float64)
+
+	// ClipPath intersects the clipping region with the current Path as it
+	// would be filled by given paint, until ResetClip
+	ClipPath(rs *RenderState, pc *Paint)
+
+	// ResetClip clears the clipping region set by ClipPath
+	ResetClip(rs *RenderState)
+
+	// FillRect fills given rectangle with given uniform color
+	FillRect(rs *RenderState, r image.Rectangle, clr color.Color)
+
+	// DrawImage draws given image, transformed from image to device
+	// coordinates by given transform
+	DrawImage(rs *RenderState, img image.Image, xf Matrix2D)
+
+	// DrawGlyph draws given rune of given font face in given color, with its
+	// origin on the baseline and its dots transformed to device coordinates
+	// by given transform
+	DrawGlyph(rs *RenderState, face font.Face, r rune, xf Matrix2D, clr color.Color)
+
+	// PushLayer starts rendering into a separate layer, at PushImage
+	PushLayer(rs *RenderState)
+
+	// PopLayer ends the layer started by PushLayer, at PopImage, with the
+	// layer image that was rendered into
+	PopLayer(rs *RenderState, layer *image.RGBA)
+
+	// DrawLayer draws given layer image through given mask (nil for none),
+	// as in RenderState DrawLayer -- the vector contents of the layer are
+	// drawn if it is the image of a popped layer, and otherwise (e.g., after
+	// filtering) the image itself
+	DrawLayer(rs *RenderState, layer *image.RGBA, mask *image.Alpha)
+
+	// PushViewport starts drawing a sub-viewport, whose device coordinates
+	// are offset by given amount from the current ones, clipped to given
+	// rectangle in current coordinates
+	PushViewport(off image.Point, clip image.Rectangle)
+
+	// PopViewport ends drawing the sub-viewport started by PushViewport
+	PopViewport()
 }
 
 // Init initializes RenderState -- must be called whenever image size changes
@@ -379,6 +436,71 @@ func (rs *RenderState) PopClip() {
 	rs.ClipStack = rs.ClipStack[:sz-1]
 }
 
+// PushImage pushes the current Image onto the image stack, and starts
+// rendering into a new transparent image of the same size -- used for
+// rendering into a separate layer, e.g., for clipping and masking, which is
+// then composited into the prior image with PopImageMask
+func (rs *RenderState) PushImage() {
+	if rs.ImageStack == nil {
+		rs.ImageStack = make([]*image.RGBA, 0, 10)
+	}
+	rs.ImageStack = append(rs.ImageStack, rs.Image)
+	rs.Image = image.NewRGBA(rs.Image.Bounds())
+	rs.ImgSpanner.SetImage(rs.Image)
+	if rs.Vector != nil {
+		rs.Vector.PushLayer(rs)
+	}
+}
+
+// PopImage pops the prior image off the image stack and restores it as the
+// current image to render into, returning the layer image rendered since
+// PushImage, which is not drawn into the prior image
+func (rs *RenderState) PopImage() *image.RGBA {
+	sz := len(rs.ImageStack)
+	if sz == 0 {
+		log.Printf("gi.RenderState PopImage: stack is empty -- programmer error\n")
+		return nil
+	}
+	layer := rs.Image
+	rs.Image = rs.ImageStack[sz-1]
+	rs.ImageStack[sz-1] = nil
+	rs.ImageStack = rs.ImageStack[:sz-1]
+	rs.ImgSpanner.SetImage(rs.Image)
+	if rs.Vector != nil {
+		rs.Vector.PopLayer(rs, layer)
+	}
+	return layer
+}
+
+// PopImageMask pops the prior image off the image stack as in PopImage, and
+// draws the layer image rendered since PushImage into it through given mask
+// -- a nil mask draws the layer as is
+func (rs *RenderState) PopImageMask(mask *image.Alpha) {
+	layer := rs.PopImage()
+	if layer == nil {
+		return
+	}
+	rs.DrawLayer(layer, mask)
+}
+
+// DrawLayer draws given layer image, e.g., from PopImage, into the current
+// image through given mask, within the current bounds -- a nil mask draws the
+// layer as is
+func (rs *RenderState) DrawLayer(layer *image.RGBA, mask *image.Alpha) {
+	if rs.Vector != nil {
+		rs.Vector.DrawLayer(rs, layer, mask)
+	}
+	b := rs.Bounds
+	if b.Empty() {
+		b = rs.Image.Bounds()
+	}
+	if mask == nil {
+		draw.Draw(rs.Image, b, layer, b.Min, draw.Over)
+		return
+	}
+	draw.DrawMask(rs.Image, b, layer, b.Min, mask, b.Min, draw.Over)
+}
+
 // BackupPaint copies style settings from Paint to PaintBack
 func (rs *RenderState) BackupPaint() {
 	rs.PaintBack.CopyStyleFrom(&rs.Paint)
@@ -593,6 +715,9 @@ func (pc *Paint) stroke(rs *RenderState) {
 	// fmt.Printf("node: %v fbox: %v\n", g.Nm, fbox)
 	rs.LastRenderBBox = image.Rectangle{Min: image.Point{fbox.Min.X.Floor(), fbox.Min.Y.Floor()},
 		Max: image.Point{fbox.Max.X.Ceil(), fbox.Max.Y.Ceil()}}
+	if rs.Vector != nil {
+		rs.Vector.StrokePath(rs, pc, pc.StrokeWidth(rs), dash)
+	}
 	rs.Raster.SetColor(pc.StrokeStyle.Color.RenderColor(pc.FontStyle.Opacity*pc.StrokeStyle.Opacity, rs.LastRenderBBox, rs.XForm))
 	rs.Raster.Draw()
 	rs.Raster.Clear()
@@ -619,6 +744,9 @@ func (pc *Paint) fill(rs *RenderState) {
 	// fmt.Printf("node: %v fbox: %v\n", g.Nm, fbox)
 	rs.LastRenderBBox = image.Rectangle{Min: image.Point{fbox.Min.X.Floor(), fbox.Min.Y.Floor()},
 		Max: image.Point{fbox.Max.X.Ceil(), fbox.Max.Y.Ceil()}}
+	if rs.Vector != nil {
+		rs.Vector.FillPath(rs, pc)
+	}
 	if pc.FillStyle.Color.Source == RadialGradient {
 		rf.SetColor(pc.FillStyle.Color.RenderColor(pc.FontStyle.Opacity*pc.FillStyle.Opacity, rs.LastRenderBBox, rs.XForm))
 	} else {
@@ -669,6 +797,9 @@ func (pc *Paint) FillBox(rs *RenderState, pos, size Vec2D, clr *ColorSpec) {
 	if clr.Source == SolidColor {
 		b := rs.Bounds.Intersect(RectFromPosSizeMax(pos, size))
 		draw.Draw(rs.Image, b, &image.Uniform{clr.Color}, image.ZP, draw.Src)
+		if rs.Vector != nil {
+			rs.Vector.FillRect(rs, b, clr.Color)
+		}
 	} else {
 		pc.FillStyle.SetColorSpec(clr)
 		pc.DrawRectangle(rs, pos.X, pos.Y, size.X, size.Y)
@@ -680,6 +811,9 @@ func (pc *Paint) FillBox(rs *RenderState, pos, size Vec2D, clr *ColorSpec) {
 func (pc *Paint) FillBoxColor(rs *RenderState, pos, size Vec2D, clr color.Color) {
 	b := rs.Bounds.Intersect(RectFromPosSizeMax(pos, size))
 	draw.Draw(rs.Image, b, &image.Uniform{clr}, image.ZP, draw.Src)
+	if rs.Vector != nil {
+		rs.Vector.FillRect(rs, b, clr)
+	}
 }
 
 // ClipPreserve updates the clipping region by intersecting the current
@@ -688,6 +822,11 @@ func (pc *Paint) FillBoxColor(rs *RenderState, pos, size Vec2D, clr color.Color)
 func (pc *Paint) ClipPreserve(rs *RenderState) {
 	clip := image.NewAlpha(rs.Image.Bounds())
 	// painter := raster.NewAlphaOverPainter(clip) // todo!
+	if vr := rs.Vector; vr != nil {
+		vr.ClipPath(rs, pc)
+		rs.Vector = nil // the clip path is not filled
+		defer func() { rs.Vector = vr }()
+	}
 	pc.fill(rs)
 	if rs.Mask == nil {
 		rs.Mask = clip
@@ -730,6 +869,9 @@ func (pc *Paint) Clip(rs *RenderState) {
 // ResetClip clears the clipping region.
 func (pc *Paint) ResetClip(rs *RenderState) {
 	rs.Mask = nil
+	if rs.Vector != nil {
+		rs.Vector.ResetClip(rs)
+	}
 }
 
 //////////////////////////////////////////////////////////////////////////////////
@@ -739,6 +881,9 @@ func (pc *Paint) ResetClip(rs *RenderState) {
 func (pc *Paint) Clear(rs *RenderState) {
 	src := image.NewUniform(&pc.FillStyle.Color.Color)
 	draw.Draw(rs.Image, rs.Image.Bounds(), src, image.ZP, draw.Src)
+	if rs.Vector != nil {
+		rs.Vector.FillRect(rs, rs.Image.Bounds(), &pc.FillStyle.Color.Color)
+	}
 }
 
 // SetPixel sets the color of the specified pixel using the current stroke color.
@@ -1016,6 +1161,9 @@ func (pc *Paint) DrawImageAnchored(rs *RenderState, fmIm image.Image, x, y int,
 	fx, fy := float32(x), float32(y)
 	m := rs.XForm.Translate(fx, fy)
 	s2d := f64.Aff3{float64(m.XX), float64(m.XY), float64(m.X0), float64(m.YX), float64(m.YY), float64(m.Y0)}
+	if rs.Vector != nil {
+		rs.Vector.DrawImage(rs, fmIm, m)
+	}
 	if rs.Mask == nil {
 		transformer.Transform(rs.Image, s2d, fmIm, fmIm.Bounds(), draw.Over, nil)
 	} else {
@@ -0,0 +1,785 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// PDF is a VectorRenderer that writes everything rendered into it as vector
+// graphics into a multi-page PDF document, e.g., for reports and printing.
+// Render renders a Viewport2D (including SVG and widget trees) into the
+// current page, NewPage starts a new page, and Close finishes the document,
+// which is written to the io.Writer given to NewPDF as it goes.  Paths,
+// strokes, clipping, solid colors, opacity and most gradients are written as
+// vector graphics, and text as the outlines of its glyphs -- only filters,
+// gradients with transparent stops or repeating spreads, and patterns are
+// rendered as images.
+type PDF struct {
+	PageSize Vec2D   `desc:"size of each page in points (1/72 inch) -- if zero, each page has the size of the first viewport rendered into it"`
+	DPI      float32 `desc:"resolution of the device pixels (dots) of the viewports rendered, in dots per inch -- each dot is 72 / DPI points on the page"`
+	Title    string  `desc:"title of the document, recorded in its information"`
+
+	w       io.Writer
+	nwrit   int
+	err     error
+	objOffs []int
+	pages   []int
+	page    Vec2D
+	strm    *pdfStream
+	strms   []*pdfStream
+	layers  map[*image.RGBA]*pdfStream
+	clips   []string
+	clipGen int
+	gstates map[string]int
+	res     map[string][]int
+}
+
+// pdfStream is a content stream of a PDF being written, for a page or a
+// layer
+type pdfStream struct {
+	buf      bytes.Buffer
+	base     rasterx.Matrix2D
+	offs     []image.Point
+	depth    int
+	bounds   image.Rectangle // of a layer
+	clipOn   bool
+	clipRect image.Rectangle
+	clipGen  int
+}
+
+// object numbers reserved at the start of every PDF
+const (
+	pdfCatalogObj = iota + 1
+	pdfPagesObj
+	pdfResObj
+	pdfInfoObj
+)
+
+// NewPDF returns a new PDF document written to given writer, with A4 pages
+// and a resolution of 96 dots per inch -- Close must be called when done
+func NewPDF(w io.Writer) *PDF {
+	pd := &PDF{PageSize: Vec2D{595, 842}, DPI: 96, w: w}
+	pd.layers = make(map[*image.RGBA]*pdfStream)
+	pd.gstates = make(map[string]int)
+	pd.res = make(map[string][]int)
+	pd.objOffs = make([]int, pdfInfoObj)
+	pd.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	return pd
+}
+
+// Render fully renders given viewport into the current page, starting a new
+// page if none is in progress, with the top-left of the viewport at the
+// top-left of the page
+func (pd *PDF) Render(vp *Viewport2D) {
+	if vp.Pixels == nil {
+		return
+	}
+	if pd.strm == nil {
+		pd.startPage(vp.Pixels.Bounds().Size())
+	}
+	rs := &vp.Render
+	rs.Vector = pd
+	vp.FullRender2DTree()
+	rs.Vector = nil
+}
+
+// NewPage ends the current page, so that rendering continues on a new page
+func (pd *PDF) NewPage() {
+	pd.endPage()
+}
+
+// NPages returns the number of pages in the document so far, including the
+// current one
+func (pd *PDF) NPages() int {
+	if pd.strm != nil {
+		return len(pd.pages) + 1
+	}
+	return len(pd.pages)
+}
+
+// Close ends the current page and finishes writing the document, returning
+// the first error encountered in writing it
+func (pd *PDF) Close() error {
+	pd.endPage()
+	if len(pd.pages) == 0 { // must have at least one page
+		pd.startPage(image.ZP)
+		pd.endPage()
+	}
+	kids := make([]string, len(pd.pages))
+	for i, pg := range pd.pages {
+		kids[i] = pdfRef(pg)
+	}
+	pd.writeObj(pdfPagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %v >>", strings.Join(kids, " "), len(pd.pages)))
+	var rb strings.Builder
+	rb.WriteString("<< /ProcSet [/PDF /ImageC /ImageB]")
+	for _, cat := range []string{"ExtGState", "XObject", "Pattern"} {
+		objs := pd.res[cat]
+		if len(objs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&rb, " /%v <<", cat)
+		for _, ob := range objs {
+			fmt.Fprintf(&rb, " /R%v %v", ob, pdfRef(ob))
+		}
+		rb.WriteString(" >>")
+	}
+	rb.WriteString(" >>")
+	pd.writeObj(pdfResObj, rb.String())
+	pd.writeObj(pdfCatalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %v >>", pdfRef(pdfPagesObj)))
+	pd.writeObj(pdfInfoObj, fmt.Sprintf("<< /Producer (GoGi) /Title %v >>", pdfString(pd.Title)))
+	xref := pd.nwrit
+	pd.write(fmt.Sprintf("xref\n0 %v\n0000000000 65535 f \n", len(pd.objOffs)+1))
+	for _, off := range pd.objOffs {
+		pd.write(fmt.Sprintf("%010d 00000 n \n", off))
+	}
+	pd.write(fmt.Sprintf("trailer\n<< /Size %v /Root %v /Info %v >>\nstartxref\n%v\n%%%%EOF\n", len(pd.objOffs)+1, pdfRef(pdfCatalogObj), pdfRef(pdfInfoObj), xref))
+	return pd.err
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//  Pages and objects
+
+// startPage starts a new page, sized for given size of content in dots if
+// PageSize is not set
+func (pd *PDF) startPage(sz image.Point) {
+	dpi := pd.DPI
+	if dpi <= 0 {
+		dpi = 96
+	}
+	sc := 72 / dpi
+	pd.page = pd.PageSize
+	if pd.page.X <= 0 || pd.page.Y <= 0 {
+		pd.page = NewVec2DFmPoint(sz).MulVal(sc)
+	}
+	pd.strm = &pdfStream{base: rasterx.Matrix2D{A: float64(sc), D: -float64(sc), F: float64(pd.page.Y)}}
+	fmt.Fprintf(&pd.strm.buf, "%v cm\n", pdfMatrix(pd.strm.base))
+}
+
+// endPage writes the current page, if any
+func (pd *PDF) endPage() {
+	if pd.strm == nil {
+		return
+	}
+	for len(pd.strms) > 0 { // unbalanced layers
+		pd.PopLayer(nil, nil)
+	}
+	pd.strm.endClip()
+	cont := pd.newObj()
+	pd.writeStream(cont, "", pd.strm.buf.Bytes())
+	pg := pd.newObj()
+	pd.writeObj(pg, fmt.Sprintf("<< /Type /Page /Parent %v /MediaBox [0 0 %v %v] /Resources %v /Contents %v >>",
+		pdfRef(pdfPagesObj), pdfNum(pd.page.X), pdfNum(pd.page.Y), pdfRef(pdfResObj), pdfRef(cont)))
+	pd.pages = append(pd.pages, pg)
+	pd.strm = nil
+	pd.layers = make(map[*image.RGBA]*pdfStream)
+	pd.clips = nil
+	pd.clipGen++
+}
+
+// write writes given string to the document, recording any error
+func (pd *PDF) write(s string) {
+	if pd.err != nil {
+		return
+	}
+	n, err := io.WriteString(pd.w, s)
+	pd.nwrit += n
+	pd.err = err
+}
+
+// newObj returns the number of a new object, to be written with writeObj or
+// writeStream
+func (pd *PDF) newObj() int {
+	pd.objOffs = append(pd.objOffs, 0)
+	return len(pd.objOffs)
+}
+
+// writeObj writes the object with given number, as given dictionary or
+// other value
+func (pd *PDF) writeObj(num int, val string) {
+	pd.objOffs[num-1] = pd.nwrit
+	pd.write(fmt.Sprintf("%v 0 obj\n%v\nendobj\n", num, val))
+}
+
+// writeStream writes the stream object with given number, with given
+// dictionary, to which the compression and length of given data are added
+func (pd *PDF) writeStream(num int, dict string, data []byte) {
+	var zb bytes.Buffer
+	zw := zlib.NewWriter(&zb)
+	zw.Write(data)
+	zw.Close()
+	dict = strings.TrimSuffix(strings.TrimSpace(dict), ">>")
+	if dict == "" {
+		dict = "<<"
+	}
+	pd.objOffs[num-1] = pd.nwrit
+	pd.write(fmt.Sprintf("%v 0 obj\n%v /Filter /FlateDecode /Length %v >>\nstream\n", num, dict, zb.Len()))
+	pd.write(zb.String())
+	pd.write("\nendstream\nendobj\n")
+}
+
+// addRes adds given object as a resource of given category, returning its
+// resource name
+func (pd *PDF) addRes(cat string, num int) string {
+	pd.res[cat] = append(pd.res[cat], num)
+	return fmt.Sprintf("/R%v", num)
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//  VectorRenderer interface
+
+// FillPath fills the current Path with the fill style of given paint
+func (pd *PDF) FillPath(rs *RenderState, pc *Paint) {
+	if pd.strm == nil || len(rs.Path) == 0 {
+		return
+	}
+	s := pd.setClip(rs)
+	op, clop := "f", "W n"
+	if pc.FillStyle.Rule == FillRuleEvenOdd {
+		op, clop = "f*", "W* n"
+	}
+	b := &s.buf
+	b.WriteString("q\n")
+	opacity := pc.FontStyle.Opacity * pc.FillStyle.Opacity
+	if cf := pd.setColor(rs, &pc.FillStyle.Color, opacity, true); cf == nil {
+		pdfPath(b, rs.Path)
+		b.WriteString(op + "\n")
+	} else { // rendered as an image clipped to the path
+		pdfPath(b, rs.Path)
+		b.WriteString(clop + "\n")
+		r := rs.LastRenderBBox.Intersect(pd.bounds(rs))
+		if !r.Empty() {
+			pd.drawImage(colorFuncImage(cf, r), Identity2D())
+		}
+	}
+	b.WriteString("Q\n")
+}
+
+// StrokePath strokes the current Path with the stroke style of given paint,
+// with given line width and dashes in device units
+func (pd *PDF) StrokePath(rs *RenderState, pc *Paint, width float32, dash []float64) {
+	if pd.strm == nil || len(rs.Path) == 0 || width <= 0 {
+		return
+	}
+	s := pd.setClip(rs)
+	b := &s.buf
+	b.WriteString("q\n")
+	opacity := pc.FontStyle.Opacity * pc.StrokeStyle.Opacity
+	if cf := pd.setColor(rs, &pc.StrokeStyle.Color, opacity, false); cf != nil { // use the center color
+		c := rs.LastRenderBBox.Min.Add(rs.LastRenderBBox.Max).Div(2)
+		pd.setSolid(cf(c.X, c.Y), false)
+	}
+	lcap := 0
+	switch pc.StrokeStyle.Cap {
+	case LineCapRound, LineCapCubic, LineCapQuadratic:
+		lcap = 1
+	case LineCapSquare:
+		lcap = 2
+	}
+	join := 0
+	switch pc.StrokeStyle.Join {
+	case LineJoinRound, LineJoinArcs, LineJoinArcsClip:
+		join = 1
+	case LineJoinBevel:
+		join = 2
+	}
+	fmt.Fprintf(b, "%v w %v J %v j %v M\n", pdfNum(width), lcap, join, pdfNum(Max32(pc.StrokeStyle.MiterLimit, 1)))
+	if len(dash) > 0 {
+		ds := make([]string, len(dash))
+		for i, d := range dash {
+			ds[i] = pdfNum(float32(d))
+		}
+		fmt.Fprintf(b, "[%v] 0 d\n", strings.Join(ds, " "))
+	}
+	pdfPath(b, rs.Path)
+	b.WriteString("S\nQ\n")
+}
+
+// ClipPath intersects the clipping region with the current Path as it would
+// be filled by given paint, until ResetClip
+func (pd *PDF) ClipPath(rs *RenderState, pc *Paint) {
+	var b bytes.Buffer
+	pdfPath(&b, rs.Path)
+	if pc.FillStyle.Rule == FillRuleEvenOdd {
+		b.WriteString("W* n\n")
+	} else {
+		b.WriteString("W n\n")
+	}
+	pd.clips = append(pd.clips, b.String())
+	pd.clipGen++
+}
+
+// ResetClip clears the clipping region set by ClipPath
+func (pd *PDF) ResetClip(rs *RenderState) {
+	pd.clips = nil
+	pd.clipGen++
+}
+
+// FillRect fills given rectangle with given uniform color
+func (pd *PDF) FillRect(rs *RenderState, r image.Rectangle, clr color.Color) {
+	if pd.strm == nil || r.Empty() || clr == nil {
+		return
+	}
+	s := pd.setClip(rs)
+	s.buf.WriteString("q\n")
+	pd.setSolid(clr, true)
+	fmt.Fprintf(&s.buf, "%v re f\nQ\n", pdfRect(r))
+}
+
+// DrawImage draws given image, transformed from image to device coordinates
+// by given transform
+func (pd *PDF) DrawImage(rs *RenderState, img image.Image, xf Matrix2D) {
+	if pd.strm == nil {
+		return
+	}
+	s := pd.setClip(rs)
+	s.buf.WriteString("q\n")
+	pd.drawImage(img, xf)
+	s.buf.WriteString("Q\n")
+}
+
+// DrawGlyph draws given rune of given font face in given color, with its
+// origin on the baseline and its dots transformed to device coordinates by
+// given transform -- glyphs without outlines are drawn as images
+func (pd *PDF) DrawGlyph(rs *RenderState, face font.Face, r rune, xf Matrix2D, clr color.Color) {
+	if pd.strm == nil || face == nil || clr == nil {
+		return
+	}
+	s := pd.setClip(rs)
+	b := &s.buf
+	b.WriteString("q\n")
+	if p, ok := GlyphPath(face, r); ok {
+		pd.setSolid(clr, true)
+		fmt.Fprintf(b, "%v cm\n", pdfMatrix(xf.ToRasterx()))
+		pdfPath(b, p)
+		b.WriteString("f\n")
+	} else if dr, mask, mp, _, ok := face.Glyph(fixed.Point26_6{}, r); ok && !dr.Empty() {
+		c := color.NRGBAModel.Convert(clr).(color.NRGBA)
+		img := image.NewNRGBA(dr)
+		for y := dr.Min.Y; y < dr.Max.Y; y++ {
+			for x := dr.Min.X; x < dr.Max.X; x++ {
+				_, _, _, a := mask.At(mp.X+x-dr.Min.X, mp.Y+y-dr.Min.Y).RGBA()
+				img.SetNRGBA(x, y, color.NRGBA{c.R, c.G, c.B, uint8((uint32(c.A) * a) / 0xffff)})
+			}
+		}
+		pd.drawImage(img, xf)
+	}
+	b.WriteString("Q\n")
+}
+
+// PushLayer starts rendering into a separate layer, at PushImage
+func (pd *PDF) PushLayer(rs *RenderState) {
+	if pd.strm == nil {
+		return
+	}
+	pd.strms = append(pd.strms, pd.strm)
+	pd.strm = &pdfStream{base: rasterx.Identity, depth: len(pd.strms), bounds: rs.Image.Bounds()}
+}
+
+// PopLayer ends the layer started by PushLayer, at PopImage, keeping its
+// contents to draw in DrawLayer if that is called with the layer image
+func (pd *PDF) PopLayer(rs *RenderState, layer *image.RGBA) {
+	sz := len(pd.strms)
+	if sz == 0 {
+		return
+	}
+	ls := pd.strm
+	ls.endClip()
+	pd.strm = pd.strms[sz-1]
+	pd.strms = pd.strms[:sz-1]
+	if layer != nil {
+		pd.layers[layer] = ls
+	}
+}
+
+// DrawLayer draws given layer image through given mask (nil for none) --
+// the vector contents of the layer are drawn if it is the image of a popped
+// layer, and otherwise the image itself
+func (pd *PDF) DrawLayer(rs *RenderState, layer *image.RGBA, mask *image.Alpha) {
+	if pd.strm == nil || layer == nil {
+		return
+	}
+	ls, has := pd.layers[layer]
+	for li, l := range pd.layers { // done with all layers within ours
+		if l.depth > len(pd.strms) {
+			delete(pd.layers, li)
+		}
+	}
+	s := pd.setClip(rs)
+	b := &s.buf
+	b.WriteString("q\n")
+	if mask != nil {
+		fmt.Fprintf(b, "%v gs\n", pd.maskGState(mask))
+	}
+	if has {
+		fm := pd.newObj()
+		lb := ls.bounds
+		pd.writeStream(fm, fmt.Sprintf("<< /Type /XObject /Subtype /Form /BBox [%v %v %v %v] /Group << /S /Transparency >> /Resources %v >>",
+			lb.Min.X, lb.Min.Y, lb.Max.X, lb.Max.Y, pdfRef(pdfResObj)), ls.buf.Bytes())
+		fmt.Fprintf(b, "%v Do\n", pd.addRes("XObject", fm))
+	} else {
+		pd.drawImage(layer, Identity2D())
+	}
+	b.WriteString("Q\n")
+}
+
+// PushViewport starts drawing a sub-viewport, whose device coordinates are
+// offset by given amount from the current ones, clipped to given rectangle
+func (pd *PDF) PushViewport(off image.Point, clip image.Rectangle) {
+	s := pd.strm
+	if s == nil {
+		return
+	}
+	s.endClip()
+	fmt.Fprintf(&s.buf, "q %v re W n 1 0 0 1 %v %v cm\n", pdfRect(clip), off.X, off.Y)
+	s.offs = append(s.offs, off)
+}
+
+// PopViewport ends drawing the sub-viewport started by PushViewport
+func (pd *PDF) PopViewport() {
+	s := pd.strm
+	if s == nil || len(s.offs) == 0 {
+		return
+	}
+	s.endClip()
+	s.buf.WriteString("Q\n")
+	s.offs = s.offs[:len(s.offs)-1]
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//  Graphics state
+
+// bounds returns the current bounds of the render state
+func (pd *PDF) bounds(rs *RenderState) image.Rectangle {
+	if rs.Bounds.Empty() {
+		return rs.Image.Bounds()
+	}
+	return rs.Bounds
+}
+
+// setClip sets the clipping of the current stream to the bounds of the
+// render state and the current clip paths, returning the stream
+func (pd *PDF) setClip(rs *RenderState) *pdfStream {
+	s := pd.strm
+	b := pd.bounds(rs)
+	if s.clipOn && s.clipRect == b && s.clipGen == pd.clipGen {
+		return s
+	}
+	s.endClip()
+	fmt.Fprintf(&s.buf, "q %v re W n\n", pdfRect(b))
+	for _, cp := range pd.clips {
+		s.buf.WriteString(cp)
+	}
+	s.clipOn, s.clipRect, s.clipGen = true, b, pd.clipGen
+	return s
+}
+
+// endClip ends the current clipping of the stream
+func (s *pdfStream) endClip() {
+	if s.clipOn {
+		s.buf.WriteString("Q\n")
+		s.clipOn = false
+	}
+}
+
+// setSolid sets the fill or stroke color of the current stream to given
+// solid color, including its opacity
+func (pd *PDF) setSolid(clr color.Color, fill bool) {
+	c := color.NRGBAModel.Convert(clr).(color.NRGBA)
+	op := "RG"
+	if fill {
+		op = "rg"
+	}
+	fmt.Fprintf(&pd.strm.buf, "%v %v %v %v\n", pdfNum(float32(c.R)/255), pdfNum(float32(c.G)/255), pdfNum(float32(c.B)/255), op)
+	pd.setAlpha(float32(c.A)/255, fill)
+}
+
+// setAlpha sets the fill or stroke opacity of the current stream
+func (pd *PDF) setAlpha(alpha float32, fill bool) {
+	if alpha >= 1 {
+		return
+	}
+	key := "CA"
+	if fill {
+		key = "ca"
+	}
+	key += " " + pdfNum(alpha)
+	gs, ok := pd.gstates[key]
+	if !ok {
+		gs = pd.newObj()
+		pd.writeObj(gs, fmt.Sprintf("<< /Type /ExtGState /%v >>", key))
+		pd.addRes("ExtGState", gs)
+		pd.gstates[key] = gs
+	}
+	fmt.Fprintf(&pd.strm.buf, "/R%v gs\n", gs)
+}
+
+// setColor sets the fill or stroke color of the current stream to given
+// color spec at given opacity, as rendered for the last render bounding box
+// -- if it cannot be written as a solid color or a gradient, the function
+// giving its color at each pixel is returned instead
+func (pd *PDF) setColor(rs *RenderState, cs *ColorSpec, opacity float32, fill bool) rasterx.ColorFunc {
+	ci := cs.RenderColor(opacity, rs.LastRenderBBox, rs.XForm)
+	switch cv := ci.(type) {
+	case color.Color:
+		pd.setSolid(cv, fill)
+		return nil
+	case rasterx.ColorFunc:
+		if cs.Source != PatternPaint && pd.setGradient(rs, cs.Gradient, opacity, fill) {
+			return nil
+		}
+		return cv
+	}
+	return func(x, y int) color.Color { return color.Transparent }
+}
+
+// setGradient sets the fill or stroke color of the current stream to given
+// gradient, as set up by ColorSpec RenderColor, returning false if it cannot
+// be written as a PDF shading
+func (pd *PDF) setGradient(rs *RenderState, g *rasterx.Gradient, opacity float32, fill bool) bool {
+	if g == nil || g.Spread != rasterx.PadSpread || len(g.Stops) < 2 {
+		return false
+	}
+	for _, st := range g.Stops {
+		if _, _, _, a := st.StopColor.RGBA(); a < 0xffff || st.Opacity < 1 {
+			return false
+		}
+	}
+	var gxf rasterx.Matrix2D // gradient to device coordinates
+	var coords []float64
+	p := g.Points
+	ox, oy, w, h := g.Bounds.X, g.Bounds.Y, g.Bounds.W, g.Bounds.H
+	if g.Units == rasterx.ObjectBoundingBox {
+		if w == 0 || h == 0 {
+			return false
+		}
+		gxf = rasterx.Identity.Translate(ox, oy).Scale(w, h).Mult(g.Matrix).Scale(1/w, 1/h).Translate(-ox, -oy)
+		if g.IsRadial { // in units of the bounding box
+			gxf = gxf.Mult(rasterx.Matrix2D{A: w, D: h, E: ox, F: oy})
+		} else {
+			p[0], p[1], p[2], p[3] = ox+w*p[0], oy+h*p[1], ox+w*p[2], oy+h*p[3]
+		}
+	} else {
+		gxf = rs.XForm.ToRasterx().Mult(g.Matrix)
+	}
+	shtyp := 2
+	if g.IsRadial {
+		shtyp = 3
+		cx, cy, fx, fy, r := p[0], p[1], p[2], p[3], p[4]
+		if d := math.Hypot(fx-cx, fy-cy); d > 0.999*r { // focus within circle, as in rasterx
+			fx, fy = cx+(fx-cx)*0.999*r/d, cy+(fy-cy)*0.999*r/d
+		}
+		coords = []float64{fx, fy, 0, cx, cy, r}
+	} else {
+		coords = p[:4]
+	}
+	cs := make([]string, len(coords))
+	for i, c := range coords {
+		cs[i] = pdfNum(float32(c))
+	}
+	s := pd.strm
+	dxf := s.base
+	for _, off := range s.offs {
+		dxf = dxf.Mult(rasterx.Matrix2D{A: 1, D: 1, E: float64(off.X), F: float64(off.Y)})
+	}
+	pat := pd.newObj()
+	pd.writeObj(pat, fmt.Sprintf("<< /PatternType 2 /Matrix [%v] /Shading << /ShadingType %v /ColorSpace /DeviceRGB /Coords [%v] /Function %v /Extend [true true] >> >>",
+		pdfMatrix(dxf.Mult(gxf)), shtyp, strings.Join(cs, " "), pdfGradFunc(g.Stops)))
+	op := "/Pattern CS %v SCN\n"
+	if fill {
+		op = "/Pattern cs %v scn\n"
+	}
+	fmt.Fprintf(&s.buf, op, pd.addRes("Pattern", pat))
+	pd.setAlpha(opacity, fill)
+	return true
+}
+
+// pdfGradFunc returns a PDF function interpolating the colors of given
+// gradient stops, sorted by offset, over the domain 0..1
+func pdfGradFunc(stops []rasterx.GradStop) string {
+	type stop struct {
+		off float64
+		clr string
+	}
+	var sts []stop
+	for _, st := range stops {
+		c := color.NRGBAModel.Convert(st.StopColor).(color.NRGBA)
+		sts = append(sts, stop{math.Min(math.Max(st.Offset, 0), 1), fmt.Sprintf("%v %v %v", pdfNum(float32(c.R)/255), pdfNum(float32(c.G)/255), pdfNum(float32(c.B)/255))})
+	}
+	if sts[0].off > 0 {
+		sts = append([]stop{{0, sts[0].clr}}, sts...)
+	}
+	if lst := sts[len(sts)-1]; lst.off < 1 {
+		sts = append(sts, stop{1, lst.clr})
+	}
+	var fns, bnds, encs []string
+	for i := 1; i < len(sts); i++ {
+		if sts[i].off <= sts[i-1].off {
+			continue
+		}
+		if len(fns) > 0 {
+			bnds = append(bnds, pdfNum(float32(sts[i-1].off)))
+		}
+		fns = append(fns, fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 [%v] /C1 [%v] /N 1 >>", sts[i-1].clr, sts[i].clr))
+		encs = append(encs, "0 1")
+	}
+	switch len(fns) {
+	case 0: // all at one offset
+		lst := sts[len(sts)-1].clr
+		return fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 [%v] /C1 [%v] /N 1 >>", lst, lst)
+	case 1:
+		return fns[0]
+	}
+	return fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%v] /Bounds [%v] /Encode [%v] >>",
+		strings.Join(fns, " "), strings.Join(bnds, " "), strings.Join(encs, " "))
+}
+
+// maskGState returns the name of a new graphics state with given mask as
+// its soft mask
+func (pd *PDF) maskGState(mask *image.Alpha) string {
+	mb := mask.Bounds()
+	gray := make([]byte, 0, mb.Dx()*mb.Dy())
+	for y := mb.Min.Y; y < mb.Max.Y; y++ {
+		st := mask.PixOffset(mb.Min.X, y)
+		gray = append(gray, mask.Pix[st:st+mb.Dx()]...)
+	}
+	im := pd.newObj()
+	pd.writeStream(im, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %v /Height %v /ColorSpace /DeviceGray /BitsPerComponent 8 >>", mb.Dx(), mb.Dy()), gray)
+	fm := pd.newObj()
+	pd.writeStream(fm, fmt.Sprintf("<< /Type /XObject /Subtype /Form /BBox [%v %v %v %v] /Group << /S /Transparency /CS /DeviceGray >> /Resources << /XObject << /M %v >> >> >>",
+		mb.Min.X, mb.Min.Y, mb.Max.X, mb.Max.Y, pdfRef(im)), []byte(fmt.Sprintf("q %v cm /M Do Q\n", pdfImageMatrix(mb))))
+	gs := pd.newObj()
+	pd.writeObj(gs, fmt.Sprintf("<< /Type /ExtGState /SMask << /Type /Mask /S /Luminosity /G %v >> >>", pdfRef(fm)))
+	return pd.addRes("ExtGState", gs)
+}
+
+// drawImage draws given image into the current stream, transformed from
+// image to device coordinates by given transform
+func (pd *PDF) drawImage(img image.Image, xf Matrix2D) {
+	ib := img.Bounds()
+	if ib.Empty() {
+		return
+	}
+	rgb := make([]byte, 0, 3*ib.Dx()*ib.Dy())
+	alpha := make([]byte, 0, ib.Dx()*ib.Dy())
+	opaque := true
+	for y := ib.Min.Y; y < ib.Max.Y; y++ {
+		for x := ib.Min.X; x < ib.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			opaque = opaque && c.A == 255
+		}
+	}
+	smask := ""
+	if !opaque {
+		sm := pd.newObj()
+		pd.writeStream(sm, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %v /Height %v /ColorSpace /DeviceGray /BitsPerComponent 8 >>", ib.Dx(), ib.Dy()), alpha)
+		smask = " /SMask " + pdfRef(sm)
+	}
+	im := pd.newObj()
+	pd.writeStream(im, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %v /Height %v /ColorSpace /DeviceRGB /BitsPerComponent 8%v >>", ib.Dx(), ib.Dy(), smask), rgb)
+	fmt.Fprintf(&pd.strm.buf, "%v cm %v cm %v Do\n", pdfMatrix(xf.ToRasterx()), pdfImageMatrix(ib), pd.addRes("XObject", im))
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//  Formatting
+
+// pdfNum formats given number for a PDF, with up to 3 decimals
+func pdfNum(v float32) string {
+	r := math.Round(float64(v)*1000) / 1000
+	if r == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(r, 'f', -1, 64)
+}
+
+// pdfRef returns a reference to the object with given number
+func pdfRef(num int) string {
+	return fmt.Sprintf("%v 0 R", num)
+}
+
+// pdfString returns given text as a PDF string literal
+func pdfString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// pdfRect returns the operands for a PDF rectangle
+func pdfRect(r image.Rectangle) string {
+	return fmt.Sprintf("%v %v %v %v", r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+}
+
+// pdfMatrix returns the operands for a PDF transform matrix
+func pdfMatrix(m rasterx.Matrix2D) string {
+	return fmt.Sprintf("%v %v %v %v %v %v", pdfNum(float32(m.A)), pdfNum(float32(m.B)), pdfNum(float32(m.C)), pdfNum(float32(m.D)), pdfNum(float32(m.E)), pdfNum(float32(m.F)))
+}
+
+// pdfImageMatrix returns the operands for a PDF transform matrix from the
+// unit square of an image to given rectangle of dots, with the first row of
+// the image at the top
+func pdfImageMatrix(r image.Rectangle) string {
+	return fmt.Sprintf("%v 0 0 %v %v %v", r.Dx(), -r.Dy(), r.Min.X, r.Max.Y)
+}
+
+// pdfPath writes the PDF operators constructing given path
+func pdfPath(b *bytes.Buffer, p rasterx.Path) {
+	pt := func(i int) (float32, float32) { return float32(p[i]) / 64, float32(p[i+1]) / 64 }
+	var cx, cy float32
+	for i := 0; i < len(p); {
+		switch rasterx.PathCommand(p[i]) {
+		case rasterx.PathMoveTo:
+			cx, cy = pt(i + 1)
+			fmt.Fprintf(b, "%v %v m\n", pdfNum(cx), pdfNum(cy))
+			i += 3
+		case rasterx.PathLineTo:
+			cx, cy = pt(i + 1)
+			fmt.Fprintf(b, "%v %v l\n", pdfNum(cx), pdfNum(cy))
+			i += 3
+		case rasterx.PathQuadTo: // as an equivalent cubic
+			qx, qy := pt(i + 1)
+			ex, ey := pt(i + 3)
+			fmt.Fprintf(b, "%v %v %v %v %v %v c\n", pdfNum(cx+2*(qx-cx)/3), pdfNum(cy+2*(qy-cy)/3),
+				pdfNum(ex+2*(qx-ex)/3), pdfNum(ey+2*(qy-ey)/3), pdfNum(ex), pdfNum(ey))
+			cx, cy = ex, ey
+			i += 5
+		case rasterx.PathCubicTo:
+			x1, y1 := pt(i + 1)
+			x2, y2 := pt(i + 3)
+			cx, cy = pt(i + 5)
+			fmt.Fprintf(b, "%v %v %v %v %v %v c\n", pdfNum(x1), pdfNum(y1), pdfNum(x2), pdfNum(y2), pdfNum(cx), pdfNum(cy))
+			i += 7
+		case rasterx.PathClose:
+			b.WriteString("h\n")
+			i++
+		default:
+			return
+		}
+	}
+}
+
+// colorFuncImage returns an image of the colors of given color function over
+// given rectangle
+func colorFuncImage(cf rasterx.ColorFunc, r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, cf(x, y))
+		}
+	}
+	return img
+}
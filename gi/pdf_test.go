@@ -0,0 +1,100 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gi
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testPDFViewport returns a viewport of given size with pixels to render
+// into, filled with its background color -- there is no oswin app to Resize
+// with
+func testPDFViewport(w, h int) *Viewport2D {
+	vp := &Viewport2D{}
+	vp.InitName(vp, "vp")
+	vp.Fill = true
+	vp.Pixels = image.NewRGBA(image.Rect(0, 0, w, h))
+	vp.Render.Init(w, h, vp.Pixels)
+	vp.Geom.Size = image.Point{w, h}
+	return vp
+}
+
+// checkPDFXRef checks that the cross-reference table of the pdf points at
+// each of its objects, returning the number of objects
+func checkPDFXRef(t *testing.T, pdf []byte) int {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatalf("no startxref at the end")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if xref >= len(pdf) || !bytes.HasPrefix(pdf[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %v does not point at the xref table", xref)
+	}
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	nobj, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for i := 1; i < nobj; i++ {
+		off, err := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		if err != nil {
+			t.Fatalf("xref entry %v: %v", i, err)
+		}
+		if obj := fmt.Sprintf("%v 0 obj\n", i); off >= len(pdf) || !bytes.HasPrefix(pdf[off:], []byte(obj)) {
+			t.Errorf("xref entry %v at %v does not point at %q", i, off, obj)
+		}
+	}
+	return nobj
+}
+
+func TestPDFPages(t *testing.T) {
+	var b bytes.Buffer
+	pd := NewPDF(&b)
+	pd.Title = "Pages"
+	pd.Render(testPDFViewport(100, 50))
+	pd.NewPage()
+	pd.Render(testPDFViewport(80, 80))
+	np := pd.NPages()
+	if np != 2 {
+		t.Errorf("NPages %v, want 2", np)
+	}
+	if err := pd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	pdf := b.Bytes()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) {
+		t.Errorf("no pdf header")
+	}
+	checkPDFXRef(t, pdf)
+	m := regexp.MustCompile(`/Type /Pages /Kids \[([^\]]*)\] /Count (\d+)`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatalf("no pages object")
+	}
+	if cnt, _ := strconv.Atoi(string(m[2])); cnt != np || len(strings.Fields(string(m[1])))/3 != np {
+		t.Errorf("pages object %q does not have %v pages", m[0], np)
+	}
+	if n := bytes.Count(pdf, []byte("/Type /Page /Parent")); n != np {
+		t.Errorf("%v page objects, want %v", n, np)
+	}
+	if n := bytes.Count(pdf, []byte("/MediaBox [0 0 595 842]")); n != np {
+		t.Errorf("%v A4 pages, want %v", n, np)
+	}
+}
+
+func TestEncodePDF(t *testing.T) {
+	var b bytes.Buffer
+	if err := testPDFViewport(100, 50).EncodePDF(&b); err != nil {
+		t.Fatal(err)
+	}
+	pdf := b.Bytes()
+	checkPDFXRef(t, pdf)
+	if !bytes.Contains(pdf, []byte("/Count 1 ")) || !bytes.Contains(pdf, []byte("/MediaBox [0 0 75 37.5]")) {
+		t.Errorf("EncodePDF should write one page of the size of the viewport: %q", regexp.MustCompile(`/MediaBox \[[^\]]*\]`).FindAll(pdf, -1))
+	}
+}
@@ -59,6 +59,28 @@ type ParamPrefs struct {
 	LocalMainMenu   bool `desc:"controls whether the main menu is displayed locally at top of each window, in addition to global menu at the top of the screen.  Mac native apps do not do this, but OTOH it makes things more consistent with other platforms, and with larger screens, it can be convenient to have access to all the menu items right there."`
 }
 
+// EditModes are the different modes of text editing available in TextView
+// and other text editing widgets.
+type EditModes int32
+
+const (
+	// EditStd is standard editing, driven entirely by the active KeyMap
+	EditStd EditModes = iota
+
+	// EditVim is vim-style modal editing, with normal, insert, visual and
+	// command-line modes -- the KeyMap is still used in insert mode
+	EditVim
+
+	EditModesN
+)
+
+var KiT_EditModes = kit.Enums.AddEnumAltLower(EditModesN, false, nil, "Edit")
+
+func (ev EditModes) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *EditModes) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+//go:generate stringer -type=EditModes
+
 // User basic user information that might be needed for different apps
 type User struct {
 	user.User
@@ -75,6 +97,7 @@ type Preferences struct {
 	Colors               ColorPrefs             `desc:"color preferences"`
 	Params               ParamPrefs             `desc:"parameters controlling GUI behavior"`
 	KeyMap               KeyMapName             `desc:"select the active keymap from list of available keymaps -- see Edit KeyMaps for editing / saving / loading that list"`
+	EditMode             EditModes              `desc:"text editing mode -- Std uses the active KeyMap for all editing, while Vim provides vim-style modal editing (normal, insert, visual modes, and : commands)"`
 	SaveKeyMaps          bool                   `desc:"if set, the current available set of key maps is saved to your preferences directory, and automatically loaded at startup -- this should be set if you are using custom key maps, but it may be safer to keep it <i>OFF</i> if you are <i>not</i> using custom key maps, so that you'll always have the latest compiled-in standard key maps with all the current key functions bound to standard key chords"`
 	SaveDetailed         bool                   `desc:"if set, the detailed preferences are saved and loaded at startup -- only "`
 	CustomStyles         ki.Props               `desc:"a custom style sheet -- add a separate Props entry for each type of object, e.g., button, or class using .classname, or specific named element using #name -- all are case insensitive"`
@@ -147,6 +170,7 @@ func (pf *Preferences) Defaults() {
 	pf.FontFamily = "Go"
 	pf.SavedPathsMax = 20
 	pf.KeyMap = DefaultKeyMap
+	pf.EditMode = EditStd
 	pf.UpdateUser()
 }
 
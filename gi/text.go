@@ -752,6 +752,11 @@ func (tr *TextRender) Render(rs *RenderState, pos Vec2D) {
 				// fmt.Printf("not ok rendering rune: %v\n", string(r))
 				continue
 			}
+			if rs.Vector != nil {
+				gxf := tx
+				gxf.X0, gxf.Y0 = rp.X, rp.Y
+				rs.Vector.DrawGlyph(rs, curFace, r, gxf, curColor)
+			}
 			if rr.RotRad == 0 && (rr.ScaleX == 0 || rr.ScaleX == 1) {
 				idr := dr.Intersect(rs.Bounds)
 				soff := image.ZP
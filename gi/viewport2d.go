@@ -12,6 +12,7 @@ import (
 	"image/png"
 	"io"
 	"log"
+	"os"
 	"sync"
 
 	"github.com/goki/gi/oswin"
@@ -213,18 +214,25 @@ func (vp *Viewport2D) DrawIntoParent(parVp *Viewport2D) {
 		draw.Draw(parVp.Pixels, r, vp.Pixels, image.ZP, draw.Over)
 		return
 	}
-	r := vp.Geom.Bounds()
-	sp := image.ZP
+	r, sp := vp.ParentDrawRect()
+	if Render2DTrace {
+		fmt.Printf("Render: vp DrawIntoParent: %v parVp: %v rect: %v sp: %v\n", vp.PathUnique(), parVp.PathUnique(), r, sp)
+	}
+	draw.Draw(parVp.Pixels, r, vp.Pixels, sp, draw.Over)
+}
+
+// ParentDrawRect returns the rectangle in the parent viewport where this
+// viewport is drawn, limited to where its parent can draw children, and the
+// point in our image drawn at the start of that rectangle
+func (vp *Viewport2D) ParentDrawRect() (r image.Rectangle, sp image.Point) {
+	r = vp.Geom.Bounds()
 	if vp.Par != nil { // use parents children bbox to determine where we can draw
 		pni, _ := KiToNode2D(vp.Par)
 		nr := r.Intersect(pni.ChildrenBBox2D())
 		sp = nr.Min.Sub(r.Min)
 		r = nr
 	}
-	if Render2DTrace {
-		fmt.Printf("Render: vp DrawIntoParent: %v parVp: %v rect: %v sp: %v\n", vp.PathUnique(), parVp.PathUnique(), r, sp)
-	}
-	draw.Draw(parVp.Pixels, r, vp.Pixels, sp, draw.Over)
+	return
 }
 
 // ReRender2DNode re-renders a specific node
@@ -451,6 +459,11 @@ func (vp *Viewport2D) PushBounds() bool {
 	rs := &vp.Render
 	bb := vp.Pixels.Bounds() // our bounds.. not vp.VpBBox)
 	rs.PushBounds(bb)
+	if vp.Viewport != nil && vp.Viewport.Render.Vector != nil { // vector rendering continues in us
+		r, sp := vp.ParentDrawRect()
+		rs.Vector = vp.Viewport.Render.Vector
+		rs.Vector.PushViewport(r.Min.Sub(sp), r)
+	}
 	if Render2DTrace {
 		fmt.Printf("Render: %v at %v\n", vp.PathUnique(), bb)
 	}
@@ -466,6 +479,10 @@ func (vp *Viewport2D) PopBounds() {
 	}
 	rs := &vp.Render
 	rs.PopBounds()
+	if vp.Viewport != nil && rs.Vector != nil {
+		rs.Vector.PopViewport()
+		rs.Vector = nil
+	}
 }
 
 func (vp *Viewport2D) Move2D(delta image.Point, parBBox image.Rectangle) {
@@ -679,3 +696,27 @@ func (vp *Viewport2D) SavePNG(path string) error {
 func (vp *Viewport2D) EncodePNG(w io.Writer) error {
 	return png.Encode(w, vp.Pixels)
 }
+
+// SavePDF fully renders the viewport as vector graphics into a PDF document
+// with one page of the size of the viewport, and writes it to disk.
+func (vp *Viewport2D) SavePDF(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = vp.EncodePDF(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// EncodePDF fully renders the viewport as vector graphics into a PDF document
+// with one page of the size of the viewport, written to the provided
+// io.Writer -- see PDF for multiple pages.
+func (vp *Viewport2D) EncodePDF(w io.Writer) error {
+	pd := NewPDF(w)
+	pd.PageSize = Vec2DZero
+	pd.Render(vp)
+	return pd.Close()
+}
@@ -813,6 +813,14 @@ func (w *Window) SendCustomEvent(data interface{}) {
 	oswin.SendCustomEvent(w.OSWin, data)
 }
 
+// RunOnEventLoop runs given function on the goroutine of the event loop of
+// this window, after the events already pending, where it is safe to update
+// the nodes shown in the window -- for work done in other goroutines, e.g.,
+// timers and file watchers.  It is run even if the window is not in focus.
+func (w *Window) RunOnEventLoop(fun func()) {
+	w.SendCustomEvent(fun)
+}
+
 /////////////////////////////////////////////////////////////////////////////
 //                   Rendering
 
@@ -1558,6 +1566,11 @@ mainloop:
 		//  Window gets first crack at these events, and handles window-specific ones
 
 		switch e := evi.(type) {
+		case *oswin.CustomEvent:
+			if fun, ok := e.Data.(func()); ok { // from RunOnEventLoop
+				fun()
+				continue
+			}
 		case *window.Event:
 			switch e.Action {
 			// case window.Resize: // note: already handled earlier in lag process
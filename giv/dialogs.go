@@ -5,10 +5,12 @@
 package giv
 
 import (
+	"fmt"
 	"image"
 
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/units"
+	"github.com/goki/gi/vci"
 	"github.com/goki/ki/ki"
 )
 
@@ -405,3 +407,141 @@ func ArgViewDialog(avp *gi.Viewport2D, args []ArgData, opts DlgOpts, recv ki.Ki,
 	dlg.Open(0, 0, avp, nil)
 	return dlg
 }
+
+// DiffViewDialog shows the differences between two text buffers in a
+// DiffView -- optionally connects to given signal receiving object and
+// function for dialog signals (nil to ignore)
+func DiffViewDialog(avp *gi.Viewport2D, bufA, bufB *TextBuf, opts DlgOpts, recv ki.Ki, dlgFunc ki.RecvFunc) *gi.Dialog {
+	dlg := gi.NewStdDialog(opts.ToGiOpts(), opts.Ok, opts.Cancel)
+	dlg.SetName("diff-view") // use a consistent name for consistent sizing / placement
+
+	frame := dlg.Frame()
+	_, prIdx := dlg.PromptWidget(frame)
+
+	dv := frame.InsertNewChild(KiT_DiffView, prIdx+1, "diff-view").(*DiffView)
+	dv.Viewport = dlg.Embed(gi.KiT_Viewport2D).(*gi.Viewport2D)
+	dv.SetBufs(bufA, bufB)
+
+	if recv != nil && dlgFunc != nil {
+		dlg.DialogSig.Connect(recv, dlgFunc)
+	}
+	dlg.SetProp("min-width", units.NewValue(100, units.Em))
+	dlg.SetProp("min-height", units.NewValue(40, units.Em))
+	dlg.UpdateEndNoSig(true)
+	dlg.Open(0, 0, avp, nil)
+	return dlg
+}
+
+// DiffFilesDialog opens the two files in new text buffers and shows the
+// differences between them in a DiffViewDialog
+func DiffFilesDialog(avp *gi.Viewport2D, fileA, fileB gi.FileName, opts DlgOpts) (*gi.Dialog, error) {
+	bufA := &TextBuf{}
+	bufA.InitName(bufA, "diff-file-a")
+	if err := bufA.Open(fileA); err != nil {
+		return nil, err
+	}
+	bufB := &TextBuf{}
+	bufB.InitName(bufB, "diff-file-b")
+	if err := bufB.Open(fileB); err != nil {
+		return nil, err
+	}
+	if opts.Title == "" {
+		opts.Title = fmt.Sprintf("Diff: %v <-> %v", fileA, fileB)
+	}
+	return DiffViewDialog(avp, bufA, bufB, opts, nil, nil), nil
+}
+
+// MergeViewDialog opens a dialog with a MergeView for resolving the merge
+// conflicts in given file, from its version control repository -- see
+// MergeView.SetFileNode
+func MergeViewDialog(avp *gi.Viewport2D, fn *FileNode, opts DlgOpts, recv ki.Ki, dlgFunc ki.RecvFunc) (*gi.Dialog, error) {
+	dlg := gi.NewStdDialog(opts.ToGiOpts(), opts.Ok, opts.Cancel)
+	dlg.SetName("merge-view") // use a consistent name for consistent sizing / placement
+
+	frame := dlg.Frame()
+	_, prIdx := dlg.PromptWidget(frame)
+
+	mv := frame.InsertNewChild(KiT_MergeView, prIdx+1, "merge-view").(*MergeView)
+	mv.Viewport = dlg.Embed(gi.KiT_Viewport2D).(*gi.Viewport2D)
+	if err := mv.SetFileNode(fn); err != nil {
+		return nil, err
+	}
+
+	if recv != nil && dlgFunc != nil {
+		dlg.DialogSig.Connect(recv, dlgFunc)
+	}
+	dlg.SetProp("min-width", units.NewValue(100, units.Em))
+	dlg.SetProp("min-height", units.NewValue(50, units.Em))
+	dlg.UpdateEndNoSig(true)
+	dlg.Open(0, 0, avp, nil)
+	return dlg, nil
+}
+
+// TextViewDialog opens a dialog for viewing the text in given buffer, in an
+// inactive TextView -- see TextViewDialogTextView to access it, e.g., for
+// SetBlame
+func TextViewDialog(avp *gi.Viewport2D, buf *TextBuf, opts DlgOpts, recv ki.Ki, dlgFunc ki.RecvFunc) *gi.Dialog {
+	dlg := gi.NewStdDialog(opts.ToGiOpts(), opts.Ok, opts.Cancel)
+	dlg.SetName("text-view") // use a consistent name for consistent sizing / placement
+
+	frame := dlg.Frame()
+	_, prIdx := dlg.PromptWidget(frame)
+
+	ly := frame.InsertNewChild(gi.KiT_Layout, prIdx+1, "text-lay").(*gi.Layout)
+	ly.SetStretchMaxWidth()
+	ly.SetStretchMaxHeight()
+	ly.SetMinPrefWidth(units.NewValue(20, units.Ch))
+	ly.SetMinPrefHeight(units.NewValue(10, units.Ch))
+	tv := ly.AddNewChild(KiT_TextView, "text-view").(*TextView)
+	tv.Viewport = dlg.Embed(gi.KiT_Viewport2D).(*gi.Viewport2D)
+	tv.SetInactive()
+	tv.SetBuf(buf)
+
+	if recv != nil && dlgFunc != nil {
+		dlg.DialogSig.Connect(recv, dlgFunc)
+	}
+	dlg.SetProp("min-width", units.NewValue(80, units.Em))
+	dlg.SetProp("min-height", units.NewValue(40, units.Em))
+	dlg.UpdateEndNoSig(true)
+	dlg.Open(0, 0, avp, nil)
+	return dlg
+}
+
+// TextViewDialogTextView returns the TextView in a TextViewDialog
+func TextViewDialogTextView(dlg *gi.Dialog) *TextView {
+	frame := dlg.Frame()
+	ly := frame.ChildByName("text-lay", 0).(*gi.Layout)
+	return ly.Child(0).(*TextView)
+}
+
+// VcsLogViewDialog opens a dialog with a VcsLogView of the commits of given
+// file in given repository, with buffer of its current contents if open
+// (else nil)
+func VcsLogViewDialog(avp *gi.Viewport2D, repo vci.Repo, file string, buf *TextBuf, opts DlgOpts, recv ki.Ki, dlgFunc ki.RecvFunc) (*gi.Dialog, error) {
+	dlg := gi.NewStdDialog(opts.ToGiOpts(), opts.Ok, opts.Cancel)
+	dlg.SetName("vcs-log-view") // use a consistent name for consistent sizing / placement
+
+	frame := dlg.Frame()
+	_, prIdx := dlg.PromptWidget(frame)
+
+	lv := frame.InsertNewChild(KiT_VcsLogView, prIdx+1, "vcs-log-view").(*VcsLogView)
+	lv.Viewport = dlg.Embed(gi.KiT_Viewport2D).(*gi.Viewport2D)
+	if err := lv.SetRepo(repo, file, buf); err != nil {
+		return nil, err
+	}
+
+	if recv != nil && dlgFunc != nil {
+		dlg.DialogSig.Connect(recv, dlgFunc)
+	}
+	dlg.SetProp("min-width", units.NewValue(80, units.Em))
+	dlg.SetProp("min-height", units.NewValue(40, units.Em))
+	dlg.UpdateEndNoSig(true)
+	dlg.Open(0, 0, avp, nil)
+	return dlg, nil
+}
+
+// VcsLogViewDialogView returns the VcsLogView in a VcsLogViewDialog
+func VcsLogViewDialogView(dlg *gi.Dialog) *VcsLogView {
+	frame := dlg.Frame()
+	return frame.ChildByName("vcs-log-view", 0).(*VcsLogView)
+}
@@ -0,0 +1,581 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/mouse"
+	"github.com/goki/gi/units"
+	"github.com/goki/ki/ints"
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DiffView colors for the backgrounds of changed lines, the changed text
+// within them, and the blank filler lines that keep the two sides aligned
+var (
+	DiffDeleteColor     = gi.Color{R: 100, G: 10, B: 10, A: 100}
+	DiffInsertColor     = gi.Color{R: 10, G: 90, B: 10, A: 100}
+	DiffChangeColor     = gi.Color{R: 20, G: 50, B: 100, A: 100}
+	DiffChangeTextColor = gi.Color{R: 40, G: 100, B: 200, A: 200}
+	DiffFillerColor     = gi.Color{R: 32, G: 32, B: 32, A: 64}
+)
+
+// DiffViewMaxIntraLine is the maximum length of lines, in runes, for which
+// the changed text within the line is highlighted
+var DiffViewMaxIntraLine = 500
+
+// DiffHunk is one change between the two buffers in a DiffView: one of the
+// non-equal Diffs, with the lines it spans in the aligned display buffers
+type DiffHunk struct {
+	Diff int `desc:"index of the diff operation in Diffs"`
+	St   int `desc:"starting line in the display buffers"`
+	Ed   int `desc:"ending line in the display buffers (exclusive)"`
+}
+
+// DiffView shows two text buffers side by side, with the differences between
+// them highlighted: changed lines in the background, and the changed text
+// within them more strongly, with blank filler lines where one side has lines
+// that the other does not, so the two line up, and scroll together.  A
+// navigation bar at the right shows where the changes are, and the toolbar
+// moves between them, and applies the current change from one side to the
+// other, using PatchFromBuf.  The views show separate display buffers with
+// the filler lines, and are read-only -- the buffers can be edited elsewhere,
+// and the diffs are updated when they are opened or saved (or with Refresh).
+type DiffView struct {
+	gi.Frame
+	BufA    *TextBuf   `json:"-" xml:"-" desc:"the buffer shown on the left (a)"`
+	BufB    *TextBuf   `json:"-" xml:"-" desc:"the buffer shown on the right (b)"`
+	Diffs   TextDiffs  `json:"-" xml:"-" desc:"the differences between BufA and BufB, as from BufA.DiffBufs(BufB)"`
+	Hunks   []DiffHunk `json:"-" xml:"-" desc:"the changes -- the non-equal Diffs, with the lines they span in the display buffers"`
+	CurHunk int        `json:"-" xml:"-" desc:"index of the current change in Hunks, -1 if none"`
+	AlignA  *TextBuf   `json:"-" xml:"-" desc:"display buffer for BufA, with blank filler lines where BufB has extra lines"`
+	AlignB  *TextBuf   `json:"-" xml:"-" desc:"display buffer for BufB, with blank filler lines where BufA has extra lines"`
+	LinesA  []int      `json:"-" xml:"-" desc:"line in BufA of each line in AlignA, -1 for filler lines"`
+	LinesB  []int      `json:"-" xml:"-" desc:"line in BufB of each line in AlignB, -1 for filler lines"`
+	syncing bool
+}
+
+var KiT_DiffView = kit.Types.AddType(&DiffView{}, DiffViewProps)
+
+var DiffViewProps = ki.Props{
+	"background-color": &gi.Prefs.Colors.Background,
+	"color":            &gi.Prefs.Colors.Font,
+	"max-width":        -1,
+	"max-height":       -1,
+}
+
+// SetBufs sets the two buffers to compare, and updates the view -- it is
+// updated again whenever either is opened or saved
+func (dv *DiffView) SetBufs(bufA, bufB *TextBuf) {
+	for _, tb := range []*TextBuf{dv.BufA, dv.BufB} {
+		if tb != nil && tb != bufA && tb != bufB {
+			tb.TextBufSig.Disconnect(dv.This())
+		}
+	}
+	dv.BufA = bufA
+	dv.BufB = bufB
+	for _, tb := range []*TextBuf{bufA, bufB} {
+		tb.TextBufSig.Connect(dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			if sig == int64(TextBufNew) || sig == int64(TextBufDone) {
+				dvv := recv.Embed(KiT_DiffView).(*DiffView)
+				dvv.UpdateDiffs()
+			}
+		})
+	}
+	dv.CurHunk = -1
+	dv.Config()
+	dv.UpdateDiffs()
+}
+
+// Config configures the toolbar, the text views and the navigation bar
+func (dv *DiffView) Config() {
+	dv.Lay = gi.LayoutVert
+	config := kit.TypeAndNameList{}
+	config.Add(gi.KiT_ToolBar, "toolbar")
+	config.Add(gi.KiT_Layout, "diff-lay")
+	mods, updt := dv.ConfigChildren(config, false)
+	if !mods {
+		updt = dv.UpdateStart()
+	}
+	dv.ConfigToolbar()
+	dl := dv.ChildByName("diff-lay", 1).(*gi.Layout)
+	dl.Lay = gi.LayoutHoriz
+	dl.SetStretchMaxWidth()
+	dl.SetStretchMaxHeight()
+	config = kit.TypeAndNameList{}
+	config.Add(gi.KiT_Layout, "text-lay-a")
+	config.Add(gi.KiT_Layout, "text-lay-b")
+	config.Add(KiT_DiffNavBar, "navbar")
+	if lmods, _ := dl.ConfigChildren(config, false); lmods {
+		if dv.AlignA == nil {
+			dv.AlignA = &TextBuf{}
+			dv.AlignA.InitName(dv.AlignA, "diff-a")
+			dv.AlignB = &TextBuf{}
+			dv.AlignB.InitName(dv.AlignB, "diff-b")
+		}
+		for i, ab := range []*TextBuf{dv.AlignA, dv.AlignB} {
+			ly := dl.Child(i).(*gi.Layout)
+			ly.SetStretchMaxWidth()
+			ly.SetStretchMaxHeight()
+			ly.SetMinPrefWidth(units.NewValue(20, units.Ch))
+			ly.SetMinPrefHeight(units.NewValue(10, units.Ch))
+			tv := ly.AddNewChild(KiT_TextView, "text").(*TextView)
+			tv.SetProp("white-space", gi.WhiteSpacePre) // wrapping would break the alignment
+			tv.SetInactive()
+			tv.SetBuf(ab)
+		}
+	}
+	dv.UpdateEnd(updt)
+}
+
+// ConfigToolbar adds the actions to the toolbar, if not already done
+func (dv *DiffView) ConfigToolbar() {
+	tb := dv.ToolBar()
+	if tb.HasChildren() {
+		return
+	}
+	tb.SetStretchMaxWidth()
+	tb.AddAction(gi.ActOpts{Label: "Prev", Icon: "widget-wedge-up", Tooltip: "go to the previous change",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(len(dv.Hunks) > 0)
+		}}, dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		dvv := recv.Embed(KiT_DiffView).(*DiffView)
+		dvv.PrevHunk()
+	})
+	tb.AddAction(gi.ActOpts{Label: "Next", Icon: "widget-wedge-down", Tooltip: "go to the next change",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(len(dv.Hunks) > 0)
+		}}, dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		dvv := recv.Embed(KiT_DiffView).(*DiffView)
+		dvv.NextHunk()
+	})
+	lbl := tb.AddNewChild(gi.KiT_Label, "changes").(*gi.Label)
+	lbl.SetMinPrefWidth(units.NewValue(16, units.Ch))
+	tb.AddNewChild(gi.KiT_Separator, "sep-apply")
+	tb.AddAction(gi.ActOpts{Label: "Apply Left", Icon: "step-fwd", Tooltip: "apply the left version of the current change to the right buffer, making them the same there",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(dv.CurHunk >= 0)
+		}}, dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		dvv := recv.Embed(KiT_DiffView).(*DiffView)
+		dvv.ApplyLeft(dvv.CurHunk)
+	})
+	tb.AddAction(gi.ActOpts{Label: "Apply Right", Icon: "step-bkwd", Tooltip: "apply the right version of the current change to the left buffer, making them the same there",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(dv.CurHunk >= 0)
+		}}, dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		dvv := recv.Embed(KiT_DiffView).(*DiffView)
+		dvv.ApplyRight(dvv.CurHunk)
+	})
+	tb.AddNewChild(gi.KiT_Separator, "sep-save")
+	tb.AddAction(gi.ActOpts{Label: "Save Left", Icon: "file-save", Tooltip: "save the left buffer to its file",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(dv.BufA != nil && dv.BufA.Filename != "" && dv.BufA.IsChanged())
+		}}, dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		dvv := recv.Embed(KiT_DiffView).(*DiffView)
+		dvv.BufA.Save()
+	})
+	tb.AddAction(gi.ActOpts{Label: "Save Right", Icon: "file-save", Tooltip: "save the right buffer to its file",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(dv.BufB != nil && dv.BufB.Filename != "" && dv.BufB.IsChanged())
+		}}, dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		dvv := recv.Embed(KiT_DiffView).(*DiffView)
+		dvv.BufB.Save()
+	})
+	tb.AddAction(gi.ActOpts{Label: "Refresh", Icon: "update", Tooltip: "recompute the differences, e.g., after editing the buffers elsewhere"},
+		dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			dvv := recv.Embed(KiT_DiffView).(*DiffView)
+			dvv.UpdateDiffs()
+		})
+}
+
+// ToolBar returns the toolbar
+func (dv *DiffView) ToolBar() *gi.ToolBar {
+	return dv.ChildByName("toolbar", 0).(*gi.ToolBar)
+}
+
+// TextLayouts returns the scrolling layouts holding the two text views
+func (dv *DiffView) TextLayouts() (lya, lyb *gi.Layout) {
+	dl := dv.ChildByName("diff-lay", 1).(*gi.Layout)
+	return dl.Child(0).(*gi.Layout), dl.Child(1).(*gi.Layout)
+}
+
+// TextViews returns the two text views, showing AlignA and AlignB
+func (dv *DiffView) TextViews() (tva, tvb *TextView) {
+	lya, lyb := dv.TextLayouts()
+	return lya.Child(0).(*TextView), lyb.Child(0).(*TextView)
+}
+
+// NavBar returns the navigation bar
+func (dv *DiffView) NavBar() *DiffNavBar {
+	dl := dv.ChildByName("diff-lay", 1).(*gi.Layout)
+	return dl.Child(2).(*DiffNavBar)
+}
+
+// UpdateDiffs recomputes the differences between the buffers, and updates
+// the display buffers, highlighting and navigation bar
+func (dv *DiffView) UpdateDiffs() {
+	if dv.BufA == nil || dv.BufB == nil || dv.AlignA == nil {
+		return
+	}
+	updt := dv.UpdateStart()
+	defer dv.UpdateEnd(updt)
+	dv.Diffs = nil
+	if !dv.BufA.IsLarge() && !dv.BufB.IsLarge() {
+		dv.Diffs = dv.BufA.DiffBufs(dv.BufB)
+	}
+	dv.Align()
+	if dv.CurHunk >= len(dv.Hunks) {
+		dv.CurHunk = len(dv.Hunks) - 1
+	}
+	if dv.CurHunk < 0 && len(dv.Hunks) > 0 {
+		dv.CurHunk = 0
+	}
+	dv.UpdateLabel()
+}
+
+// Align builds the display buffers from the Diffs, with filler lines so the
+// two sides line up, and sets the line colors of the views
+func (dv *DiffView) Align() {
+	tva, tvb := dv.TextViews()
+	tva.ClearLineColors()
+	tvb.ClearLineColors()
+	dv.Hunks = nil
+	dv.LinesA = dv.LinesA[:0]
+	dv.LinesB = dv.LinesB[:0]
+	var ba, bb bytes.Buffer
+	dv.BufA.LinesMu.RLock()
+	dv.BufB.LinesMu.RLock()
+	add := func(tb *TextBuf, buf *bytes.Buffer, lines *[]int, tv *TextView, ln int, clr gi.Color) {
+		if ln >= 0 {
+			buf.WriteString(string(tb.Lines[ln]))
+		}
+		buf.WriteByte('\n')
+		if clr.A > 0 {
+			tv.SetLineColor(len(*lines), clr)
+		}
+		*lines = append(*lines, ln)
+	}
+	if len(dv.Diffs) == 0 { // identical, or not compared
+		for ln := 0; ln < dv.BufA.NLines; ln++ {
+			add(dv.BufA, &ba, &dv.LinesA, tva, ln, gi.Color{})
+		}
+		for ln := 0; ln < dv.BufB.NLines; ln++ {
+			add(dv.BufB, &bb, &dv.LinesB, tvb, ln, gi.Color{})
+		}
+	}
+	for di, df := range dv.Diffs {
+		if df.Tag == 'e' {
+			for k := 0; k < df.I2-df.I1; k++ {
+				add(dv.BufA, &ba, &dv.LinesA, tva, df.I1+k, gi.Color{})
+				add(dv.BufB, &bb, &dv.LinesB, tvb, df.J1+k, gi.Color{})
+			}
+			continue
+		}
+		st := len(dv.LinesA)
+		na := df.I2 - df.I1
+		nb := df.J2 - df.J1
+		n := ints.MaxInt(na, nb)
+		for k := 0; k < n; k++ {
+			paired := k < na && k < nb
+			switch {
+			case paired:
+				add(dv.BufA, &ba, &dv.LinesA, tva, df.I1+k, DiffChangeColor)
+				add(dv.BufB, &bb, &dv.LinesB, tvb, df.J1+k, DiffChangeColor)
+				dv.intraLine(tva, tvb, st+k, dv.BufA.Lines[df.I1+k], dv.BufB.Lines[df.J1+k])
+			case k < na:
+				add(dv.BufA, &ba, &dv.LinesA, tva, df.I1+k, DiffDeleteColor)
+				add(dv.BufB, &bb, &dv.LinesB, tvb, -1, DiffFillerColor)
+			default:
+				add(dv.BufA, &ba, &dv.LinesA, tva, -1, DiffFillerColor)
+				add(dv.BufB, &bb, &dv.LinesB, tvb, df.J1+k, DiffInsertColor)
+			}
+		}
+		dv.Hunks = append(dv.Hunks, DiffHunk{Diff: di, St: st, Ed: st + n})
+	}
+	dv.BufB.LinesMu.RUnlock()
+	dv.BufA.LinesMu.RUnlock()
+	for _, ab := range [][2]*TextBuf{{dv.AlignA, dv.BufA}, {dv.AlignB, dv.BufB}} {
+		tb, src := ab[0], ab[1]
+		tb.Opts = src.Opts
+		tb.Opts.LineNos = false // line numbers would count the filler lines
+		tb.Opts.LSP = false
+		tb.Opts.PersistUndo = false
+		tb.Info = src.Info
+		tb.SetHiStyle(src.Hi.Style)
+	}
+	dv.AlignA.SetText(ba.Bytes())
+	dv.AlignB.SetText(bb.Bytes())
+	dv.AlignA.ReMarkup()
+	dv.AlignB.ReMarkup()
+}
+
+// intraLine adds highlighting of the text that differs between given pair
+// of changed lines, at given line in the display buffers
+func (dv *DiffView) intraLine(tva, tvb *TextView, ln int, la, lb []rune) {
+	if len(la) > DiffViewMaxIntraLine || len(lb) > DiffViewMaxIntraLine {
+		return
+	}
+	sa := make([]string, len(la))
+	for i, r := range la {
+		sa[i] = string(r)
+	}
+	sb := make([]string, len(lb))
+	for i, r := range lb {
+		sb[i] = string(r)
+	}
+	m := difflib.NewMatcherWithJunk(sa, sb, false, nil)
+	for _, op := range m.GetOpCodes() {
+		if op.Tag == 'e' {
+			continue
+		}
+		if op.I2 > op.I1 {
+			tva.ColorRegions = append(tva.ColorRegions, TextColorRegion{Reg: NewTextRegion(ln, op.I1, ln, op.I2), Color: DiffChangeTextColor})
+		}
+		if op.J2 > op.J1 {
+			tvb.ColorRegions = append(tvb.ColorRegions, TextColorRegion{Reg: NewTextRegion(ln, op.J1, ln, op.J2), Color: DiffChangeTextColor})
+		}
+	}
+}
+
+// UpdateLabel updates the toolbar label showing the current change
+func (dv *DiffView) UpdateLabel() {
+	lbl := dv.ToolBar().ChildByName("changes", 2).(*gi.Label)
+	switch {
+	case dv.BufA.IsLarge() || dv.BufB.IsLarge():
+		lbl.SetText("too large to compare")
+	case len(dv.Hunks) == 0:
+		lbl.SetText("no differences")
+	default:
+		lbl.SetText(fmt.Sprintf("change %v of %v", dv.CurHunk+1, len(dv.Hunks)))
+	}
+}
+
+// GoToHunk makes given change the current one, and scrolls to show it
+func (dv *DiffView) GoToHunk(idx int) {
+	if idx < 0 || idx >= len(dv.Hunks) {
+		return
+	}
+	dv.CurHunk = idx
+	dv.UpdateLabel()
+	hk := dv.Hunks[idx]
+	tva, tvb := dv.TextViews()
+	updt := dv.Viewport.Win.UpdateStart()
+	tvb.CursorPos = TextPos{Ln: hk.St}
+	tva.SetCursorShow(TextPos{Ln: hk.St}) // scrolls b too
+	dv.NavBar().UpdateSig()
+	dv.Viewport.Win.UpdateEnd(updt)
+}
+
+// NextHunk goes to the next change, wrapping around to the first
+func (dv *DiffView) NextHunk() {
+	if len(dv.Hunks) == 0 {
+		return
+	}
+	dv.GoToHunk((dv.CurHunk + 1) % len(dv.Hunks))
+}
+
+// PrevHunk goes to the previous change, wrapping around to the last
+func (dv *DiffView) PrevHunk() {
+	if len(dv.Hunks) == 0 {
+		return
+	}
+	idx := dv.CurHunk - 1
+	if idx < 0 {
+		idx = len(dv.Hunks) - 1
+	}
+	dv.GoToHunk(idx)
+}
+
+// HunkAtLine returns the index of the change at or after given line in the
+// display buffers -- the last one if none after
+func (dv *DiffView) HunkAtLine(ln int) int {
+	for i, hk := range dv.Hunks {
+		if hk.Ed > ln {
+			return i
+		}
+	}
+	return len(dv.Hunks) - 1
+}
+
+// ApplyLeft applies the left (BufA) version of given change to BufB, making
+// the two the same there -- the edit can be undone in BufB
+func (dv *DiffView) ApplyLeft(idx int) bool {
+	if idx < 0 || idx >= len(dv.Hunks) {
+		return false
+	}
+	df := dv.Diffs[dv.Hunks[idx].Diff]
+	inv := difflib.OpCode{Tag: df.Tag, I1: df.J1, I2: df.J2, J1: df.I1, J2: df.I2}
+	switch df.Tag {
+	case 'd':
+		inv.Tag = 'i'
+	case 'i':
+		inv.Tag = 'd'
+	}
+	dv.BufB.PatchFromBufUndo(dv.BufA, TextDiffs{inv}, true)
+	dv.UpdateDiffs()
+	return true
+}
+
+// ApplyRight applies the right (BufB) version of given change to BufA,
+// making the two the same there -- the edit can be undone in BufA
+func (dv *DiffView) ApplyRight(idx int) bool {
+	if idx < 0 || idx >= len(dv.Hunks) {
+		return false
+	}
+	df := dv.Diffs[dv.Hunks[idx].Diff]
+	dv.BufA.PatchFromBufUndo(dv.BufB, TextDiffs{df}, true)
+	dv.UpdateDiffs()
+	return true
+}
+
+// ConnectScrolls connects the scrollbars of the two text views so they
+// scroll together -- the layouts re-create these connections each time they
+// lay out, so this is done after layout and before rendering
+func (dv *DiffView) ConnectScrolls() {
+	if !dv.HasChildren() {
+		return
+	}
+	lya, lyb := dv.TextLayouts()
+	for d := gi.X; d <= gi.Y; d++ {
+		sa, sb := lya.Scrolls[d], lyb.Scrolls[d]
+		if sa == nil || sb == nil {
+			continue
+		}
+		dv.connectScroll(sa, sb)
+		dv.connectScroll(sb, sa)
+	}
+}
+
+// connectScroll makes scrollbar to follow scrollbar from
+func (dv *DiffView) connectScroll(from, to *gi.ScrollBar) {
+	from.SliderSig.Connect(dv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if sig != int64(gi.SliderValueChanged) {
+			return
+		}
+		dvv := recv.Embed(KiT_DiffView).(*DiffView)
+		if dvv.syncing {
+			return
+		}
+		dvv.syncing = true
+		to.SetValueAction(from.Value)
+		dvv.syncing = false
+	})
+}
+
+func (dv *DiffView) Layout2D(parBBox image.Rectangle, iter int) bool {
+	redo := dv.Frame.Layout2D(parBBox, iter)
+	dv.ConnectScrolls()
+	return redo
+}
+
+func (dv *DiffView) Render2D() {
+	dv.ConnectScrolls()
+	dv.Frame.Render2D()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   DiffNavBar
+
+// DiffNavBar is the navigation bar of a DiffView, showing where the changes
+// are over the whole length of the buffers -- clicking goes to the change
+// there
+type DiffNavBar struct {
+	gi.WidgetBase
+}
+
+var KiT_DiffNavBar = kit.Types.AddType(&DiffNavBar{}, DiffNavBarProps)
+
+var DiffNavBarProps = ki.Props{
+	"padding":          units.NewValue(0, units.Px),
+	"margin":           units.NewValue(2, units.Px),
+	"min-width":        units.NewValue(1.5, units.Ch),
+	"max-height":       -1,
+	"border-color":     &gi.Prefs.Colors.Border,
+	"border-width":     units.NewValue(1, units.Px),
+	"background-color": &gi.Prefs.Colors.Control,
+}
+
+// DiffView returns the DiffView that this is the navigation bar of
+func (nb *DiffNavBar) DiffView() *DiffView {
+	dvi := nb.ParentByType(KiT_DiffView, true)
+	if dvi == nil {
+		return nil
+	}
+	return dvi.Embed(KiT_DiffView).(*DiffView)
+}
+
+// HunkColor returns the color for given change in the navigation bar
+func (nb *DiffNavBar) HunkColor(dv *DiffView, hk DiffHunk) gi.Color {
+	switch dv.Diffs[hk.Diff].Tag {
+	case 'd':
+		return DiffDeleteColor
+	case 'i':
+		return DiffInsertColor
+	}
+	return DiffChangeColor
+}
+
+func (nb *DiffNavBar) Render2D() {
+	if nb.FullReRenderIfNeeded() {
+		return
+	}
+	if nb.PushBounds() {
+		nb.This().(gi.Node2D).ConnectEvents2D()
+		dv := nb.DiffView()
+		rs := &nb.Viewport.Render
+		rs.Lock()
+		pc := &rs.Paint
+		st := &nb.Sty
+		pos := nb.LayData.AllocPos.AddVal(st.Layout.Margin.Dots)
+		sz := nb.LayData.AllocSize.AddVal(-2.0 * st.Layout.Margin.Dots)
+		pc.FillBox(rs, pos, sz, &st.Font.BgColor)
+		if dv != nil && len(dv.LinesA) > 0 {
+			nln := float32(len(dv.LinesA))
+			for i, hk := range dv.Hunks {
+				y := pos.Y + sz.Y*float32(hk.St)/nln
+				h := gi.Max32(sz.Y*float32(hk.Ed-hk.St)/nln, 2)
+				x, w := pos.X+2, sz.X-4
+				if i == dv.CurHunk {
+					x, w = pos.X, sz.X
+				}
+				clr := nb.HunkColor(dv, hk)
+				pc.FillBoxColor(rs, gi.Vec2D{x, y}, gi.Vec2D{w, h}, clr.Opaquer(100))
+			}
+		}
+		pc.StrokeStyle.Width = st.Border.Width
+		pc.StrokeStyle.SetColor(&st.Border.Color)
+		pc.DrawRectangle(rs, pos.X, pos.Y, sz.X, sz.Y)
+		pc.Stroke(rs)
+		rs.Unlock()
+		nb.PopBounds()
+	} else {
+		nb.DisconnectAllEvents(gi.RegPri)
+	}
+}
+
+func (nb *DiffNavBar) ConnectEvents2D() {
+	nb.ConnectEvent(oswin.MouseEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
+		me := d.(*mouse.Event)
+		if me.Button != mouse.Left || me.Action != mouse.Press {
+			return
+		}
+		me.SetProcessed()
+		nbb := recv.Embed(KiT_DiffNavBar).(*DiffNavBar)
+		dv := nbb.DiffView()
+		if dv == nil || len(dv.Hunks) == 0 {
+			return
+		}
+		pt := nbb.PointToRelPos(me.Pos())
+		ln := int(float32(pt.Y) / nbb.LayData.AllocSize.Y * float32(len(dv.LinesA)))
+		dv.GoToHunk(dv.HunkAtLine(ln))
+	})
+}
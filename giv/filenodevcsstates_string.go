@@ -9,9 +9,9 @@ import (
 
 var _ = errors.New("dummy error")
 
-const _FileNodeVcsStates_name = "FileNodeNotInVcsFileNodeInVcsFileNodeVcsModifiedFileNodeVcsAddedFileNodeVcsStatesN"
+const _FileNodeVcsStates_name = "FileNodeNotInVcsFileNodeVcsAddedFileNodeInVcsFileNodeVcsModifiedFileNodeVcsConflictedFileNodeVcsStatesN"
 
-var _FileNodeVcsStates_index = [...]uint8{0, 16, 29, 48, 64, 82}
+var _FileNodeVcsStates_index = [...]uint8{0, 16, 32, 45, 64, 85, 103}
 
 func (i FileNodeVcsStates) String() string {
 	if i < 0 || i >= FileNodeVcsStates(len(_FileNodeVcsStates_index)-1) {
@@ -17,7 +17,10 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/goki/gi/fswatch"
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/histyle"
 	"github.com/goki/gi/oswin"
@@ -39,11 +42,25 @@ import (
 // interface into it.
 type FileTree struct {
 	FileNode
-	OpenDirs  OpenDirMap   `desc:"records which directories within the tree (encoded using paths relative to root) are open (i.e., have been opened by the user) -- can persist this to restore prior view of a tree"`
-	DirsOnTop bool         `desc:"if true, then all directories are placed at the top of the tree view -- otherwise everything is alpha sorted"`
-	NodeType  reflect.Type `view:"-" json:"-" xml:"-" desc:"type of node to create -- defaults to giv.FileNode but can use custom node types"`
-	Repo      vci.Repo     `view:"-" json:"-" xml:"-" desc:"interface for version control system calls"`
-	RepoType  string       `desc:"the repository type, git, svn, etc cached for performance"`
+	OpenDirs    OpenDirMap      `desc:"records which directories within the tree (encoded using paths relative to root) are open (i.e., have been opened by the user) -- can persist this to restore prior view of a tree"`
+	DirsOnTop   bool            `desc:"if true, then all directories are placed at the top of the tree view -- otherwise everything is alpha sorted"`
+	NodeType    reflect.Type    `view:"-" json:"-" xml:"-" desc:"type of node to create -- defaults to giv.FileNode but can use custom node types"`
+	Repo        vci.Repo        `view:"-" json:"-" xml:"-" desc:"interface for version control system calls"`
+	RepoType    string          `desc:"the repository type, git, svn, etc cached for performance"`
+	RepoMu      sync.Mutex      `view:"-" json:"-" xml:"-" desc:"mutex protecting the Repo and its caches, which are refreshed in the background by UpdateVcs"`
+	vcsMu       sync.Mutex      `desc:"mutex protecting the UpdateVcs state"`
+	vcsTimer    *time.Timer     `desc:"timer for the pending UpdateVcs"`
+	vcsAll      bool            `desc:"the pending UpdateVcs re-reads all the files in the repository"`
+	vcsBusy     bool            `desc:"UpdateVcs is running"`
+	vcsPending  bool            `desc:"UpdateVcs was requested while running, so it runs again when done"`
+	win         *gi.Window      `desc:"window of the view of the tree, on whose event loop the updates from the background are run -- see SetWindow"`
+	winMu       sync.Mutex      `desc:"mutex protecting win"`
+	Watcher     fswatch.Watcher `view:"-" json:"-" xml:"-" desc:"watcher of the open directories for changes on disk -- see Watch"`
+	watchMu     sync.Mutex      `desc:"mutex protecting the pending watch updates"`
+	watchDirs   map[string]bool `desc:"directories changed on disk, pending update"`
+	watchFiles  map[string]bool `desc:"files written on disk, pending notifying their buffers"`
+	watchRescan bool            `desc:"changes on disk were lost, so the whole tree is pending update"`
+	watchTimer  *time.Timer     `desc:"timer for the pending watch update"`
 }
 
 var KiT_FileTree = kit.Types.AddType(&FileTree{}, FileTreeProps)
@@ -59,14 +76,11 @@ func (ft *FileTree) OpenPath(path string) {
 		if err == nil {
 			ft.Repo = repo
 			ft.RepoType = string(repo.Vcs())
-			ft.Repo.CacheFileNames()
-			ft.Repo.CacheFilesModified()
-			ft.Repo.CacheFilesAdded()
 		}
-	} else {
-		ft.Repo.CacheFileNames() // refresh
-		ft.Repo.CacheFilesModified()
-		ft.Repo.CacheFilesAdded()
+	}
+	ft.UpdateVcs(true) // in background
+	if err := ft.Watch(); err != nil {
+		log.Printf("giv.FileTree: could not watch files: %v\n", err)
 	}
 
 	ft.FRoot = ft // we are our own root..
@@ -114,6 +128,29 @@ func (ft *FileTree) SetDirClosed(fpath gi.FileName) {
 	ft.OpenDirs.SetClosed(ft.RelPath(fpath))
 }
 
+// SetWindow sets the window viewing the tree -- the updates of the tree
+// from the background, for UpdateVcs and Watch, are run on its event loop --
+// called by the FileTreeView viewing it
+func (ft *FileTree) SetWindow(win *gi.Window) {
+	ft.winMu.Lock()
+	ft.win = win
+	ft.winMu.Unlock()
+}
+
+// runOnEventLoop runs given function on the event loop of the window viewing
+// the tree, where it is safe to update the nodes and their views -- it is
+// run directly if the tree is not viewed in a window
+func (ft *FileTree) runOnEventLoop(fun func()) {
+	ft.winMu.Lock()
+	win := ft.win
+	ft.winMu.Unlock()
+	if win == nil || win.IsClosed() {
+		fun()
+		return
+	}
+	win.RunOnEventLoop(fun)
+}
+
 //////////////////////////////////////////////////////////////////////////////
 //    FileNode
 
@@ -207,6 +244,7 @@ func (fn *FileNode) ReadDir(path string) error {
 		return err
 	}
 	fn.SetOpen()
+	fn.FRoot.WatchDir(pth)
 	config := fn.ConfigOfFiles(path)
 	mods, updt := fn.ConfigChildren(config, false) // NOT unique names
 	// always go through kids, regardless of mods
@@ -215,21 +253,9 @@ func (fn *FileNode) ReadDir(path string) error {
 		sf.FRoot = fn.FRoot
 		fp := filepath.Join(path, sf.Nm)
 		sf.SetNodePath(fp)
-		if sf.Repo() != nil {
-			prefix := string(fn.FRoot.FPath) + "/"
-			relpth := strings.TrimPrefix(fp, prefix)
-			if sf.Repo().IsAdded(string(relpth)) {
-				sf.VcsState = FileNodeVcsAdded
-			} else if sf.Repo().IsModified(string(relpth)) {
-				sf.VcsState = FileNodeVcsModified
-			} else if sf.Repo().InRepo(string(relpth)) {
-				sf.VcsState = FileNodeInVcs
-			} else {
-				sf.VcsState = FileNodeNotInVcs
-			}
-		}
 	}
 	if mods {
+		fn.FRoot.UpdateVcs(false) // status of new nodes
 		fn.UpdateEnd(updt)
 	}
 	return nil
@@ -310,6 +336,7 @@ func (fn *FileNode) OpenDir() {
 func (fn *FileNode) CloseDir() {
 	fn.SetClosed()
 	fn.FRoot.SetDirClosed(fn.FPath)
+	fn.FRoot.UnwatchDir(string(fn.FPath))
 	// todo: do anything with open files within directory??
 }
 
@@ -331,7 +358,11 @@ func (fn *FileNode) OpenBuf() (bool, error) {
 		fn.Buf.AddFileNode(fn)
 	}
 	fn.Buf.Hi.Style = FileNodeHiStyle
-	return true, fn.Buf.Open(fn.FPath)
+	err := fn.Buf.Open(fn.FPath)
+	if err == nil {
+		fn.UpdateVcsBase()
+	}
+	return true, err
 }
 
 // CloseBuf closes the file in its buffer if it is open -- returns true if closed
@@ -519,7 +550,9 @@ func (fn *FileNode) DuplicateFile() error {
 // DeleteFile deletes this file
 func (fn *FileNode) DeleteFile() (err error) {
 	if fn.VcsState >= FileNodeInVcs {
+		fn.FRoot.RepoMu.Lock()
 		err = fn.Repo().Remove(string(fn.FPath))
+		fn.FRoot.RepoMu.Unlock()
 	} else {
 		err = fn.Info.Delete()
 	}
@@ -536,7 +569,9 @@ func (fn *FileNode) RenameFile(newpath string) (err error) {
 		return err
 	}
 	if fn.FRoot.Repo != nil && fn.VcsState >= FileNodeVcsAdded {
+		fn.FRoot.RepoMu.Lock()
 		err = fn.Repo().Move(string(fn.FPath), newpath)
+		fn.FRoot.RepoMu.Unlock()
 	} else {
 		err = os.Rename(string(fn.FPath), newpath)
 	}
@@ -655,7 +690,9 @@ func (fn *FileNode) AddToVcs() {
 	if fn.Repo() == nil {
 		return
 	}
+	fn.FRoot.RepoMu.Lock()
 	err := fn.Repo().Add(string(fn.FPath))
+	fn.FRoot.RepoMu.Unlock()
 	if err == nil {
 		fn.VcsState = FileNodeVcsAdded
 		dpath, _ := filepath.Split(string(fn.FPath))
@@ -670,7 +707,9 @@ func (fn *FileNode) RemoveFromVcs() {
 	if fn.Repo() == nil {
 		return
 	}
+	fn.FRoot.RepoMu.Lock()
 	err := fn.Repo().RemoveKeepLocal(string(fn.FPath))
+	fn.FRoot.RepoMu.Unlock()
 	if fn != nil && err == nil {
 		fn.VcsState = FileNodeNotInVcs
 		dpath, _ := filepath.Split(string(fn.FPath))
@@ -685,9 +724,12 @@ func (fn *FileNode) CommitToVcs(message string) (err error) {
 	if fn.Repo() == nil || fn.VcsState == FileNodeNotInVcs {
 		return errors.New("Repo nil or file not in repo")
 	}
+	fn.FRoot.RepoMu.Lock()
 	err = fn.Repo().CommitFile(string(fn.FPath), message)
+	fn.FRoot.RepoMu.Unlock()
 	if err == nil {
 		fn.VcsState = FileNodeInVcs
+		fn.UpdateVcsBase()
 		fn.UpdateSig()
 	}
 	return err
@@ -698,7 +740,9 @@ func (fn *FileNode) RevertVcs() (err error) {
 	if fn.Repo() == nil || fn.VcsState == FileNodeNotInVcs {
 		return errors.New("Repo nil or file not in repo")
 	}
+	fn.FRoot.RepoMu.Lock()
 	err = fn.Repo().RevertFile(string(fn.FPath))
+	fn.FRoot.RepoMu.Unlock()
 	if err == nil {
 		if fn.VcsState == FileNodeVcsModified {
 			fn.VcsState = FileNodeInVcs
@@ -713,6 +757,63 @@ func (fn *FileNode) RevertVcs() (err error) {
 	return err
 }
 
+// ResolveVcs marks the merge conflicts in the file as resolved
+func (fn *FileNode) ResolveVcs() (err error) {
+	if fn.Repo() == nil || fn.VcsState != FileNodeVcsConflicted {
+		return errors.New("Repo nil or file not conflicted")
+	}
+	fn.FRoot.RepoMu.Lock()
+	err = fn.Repo().MarkResolved(string(fn.FPath))
+	fn.FRoot.RepoMu.Unlock()
+	if err == nil {
+		fn.VcsState = FileNodeVcsModified
+		fn.UpdateSig()
+	}
+	return err
+}
+
+// UpdateVcsBase sets the version control base of the buffer, if open, to
+// the last commit of the file, so the changes since then are marked in the
+// gutter -- cleared if the file is not committed.  The file is retrieved from
+// version control in the background, and the views update when it arrives.
+func (fn *FileNode) UpdateVcsBase() {
+	tb := fn.Buf
+	if tb == nil {
+		return
+	}
+	repo := fn.Repo()
+	if repo == nil || fn.VcsState < FileNodeInVcs {
+		tb.SetVcsBase(nil)
+		return
+	}
+	fpath := string(fn.FPath)
+	go func() {
+		txt, err := repo.Show(fpath, "")
+		if err != nil {
+			txt = nil
+		}
+		if string(tb.Filename) == fpath { // still open on this file
+			tb.SetVcsBase(txt)
+		}
+	}()
+}
+
+// BlameVcs returns the commit that last changed each line of the file
+func (fn *FileNode) BlameVcs() (vci.Blame, error) {
+	if fn.Repo() == nil || fn.VcsState < FileNodeVcsAdded {
+		return nil, errors.New("Repo nil or file not in repo")
+	}
+	return fn.Repo().Blame(string(fn.FPath))
+}
+
+// LogVcs returns the history of commits of the file, most recent first
+func (fn *FileNode) LogVcs() (vci.Log, error) {
+	if fn.Repo() == nil || fn.VcsState < FileNodeVcsAdded {
+		return nil, errors.New("Repo nil or file not in repo")
+	}
+	return fn.Repo().Log(string(fn.FPath))
+}
+
 //////////////////////////////////////////////////////////////////////////
 //  Search
 
@@ -808,6 +909,9 @@ const (
 	// FileNodeVcsModified means the file is in the repository and modified since last commit
 	FileNodeVcsModified
 
+	// FileNodeVcsConflicted means the file has unresolved merge conflicts
+	FileNodeVcsConflicted
+
 	// FileNodeVcsStatesN is the number of FileNodeVcsStates
 	FileNodeVcsStatesN
 )
@@ -940,6 +1044,9 @@ func (ftv *FileTreeView) FileNode() *FileNode {
 }
 
 func (ftv *FileTreeView) ConnectEvents2D() {
+	if fn := ftv.FileNode(); fn != nil && fn.FRoot != nil && ftv.Viewport != nil {
+		fn.FRoot.SetWindow(ftv.Viewport.Win)
+	}
 	ftv.FileTreeViewEvents()
 }
 
@@ -1245,6 +1352,95 @@ func (ftv *FileTreeView) RevertVcs() {
 	}
 }
 
+// MergeVcs opens a merge view for resolving the conflicts in the file
+func (ftv *FileTreeView) MergeVcs() {
+	sels := ftv.SelectedViews()
+	sz := len(sels)
+	if sz == 0 { // shouldn't happen
+		return
+	}
+	sn := sels[sz-1]
+	ftvv := sn.Embed(KiT_FileTreeView).(*FileTreeView)
+	fn := ftvv.FileNode()
+	if fn != nil {
+		if _, err := MergeViewDialog(ftv.Viewport, fn, DlgOpts{Title: "Merge: " + fn.Nm}, nil, nil); err != nil {
+			gi.PromptDialog(ftv.Viewport, gi.DlgOpts{Title: "Could not Merge", Prompt: err.Error()}, true, false, nil, nil)
+		}
+	}
+}
+
+// LogVcs shows the history of commits of the file in a log browser
+func (ftv *FileTreeView) LogVcs() {
+	sels := ftv.SelectedViews()
+	sz := len(sels)
+	if sz == 0 { // shouldn't happen
+		return
+	}
+	sn := sels[sz-1]
+	ftvv := sn.Embed(KiT_FileTreeView).(*FileTreeView)
+	fn := ftvv.FileNode()
+	if fn != nil && fn.Repo() != nil {
+		dlg, err := VcsLogViewDialog(ftv.Viewport, fn.Repo(), string(fn.FPath), fn.Buf, DlgOpts{Title: fn.RepoType() + " Log: " + fn.Nm}, nil, nil)
+		if err != nil {
+			gi.PromptDialog(ftv.Viewport, gi.DlgOpts{Title: "Could not Get Log", Prompt: err.Error()}, true, false, nil, nil)
+			return
+		}
+		VcsLogViewDialogView(dlg).RepoMu = &fn.FRoot.RepoMu
+	}
+}
+
+// BlameVcs shows the file with the commit that last changed each line in
+// the line number gutter
+func (ftv *FileTreeView) BlameVcs() {
+	sels := ftv.SelectedViews()
+	sz := len(sels)
+	if sz == 0 { // shouldn't happen
+		return
+	}
+	sn := sels[sz-1]
+	ftvv := sn.Embed(KiT_FileTreeView).(*FileTreeView)
+	fn := ftvv.FileNode()
+	if fn == nil {
+		return
+	}
+	bl, err := fn.BlameVcs()
+	if err == nil {
+		_, err = fn.OpenBuf()
+	}
+	if err != nil {
+		gi.PromptDialog(ftv.Viewport, gi.DlgOpts{Title: "Could not Get Blame", Prompt: err.Error()}, true, false, nil, nil)
+		return
+	}
+	dlg := TextViewDialog(ftv.Viewport, fn.Buf, DlgOpts{Title: fn.RepoType() + " Blame: " + fn.Nm}, nil, nil)
+	TextViewDialogTextView(dlg).SetBlame(bl)
+}
+
+// DiffVcs shows the differences between the last commit of the file and
+// its current contents
+func (ftv *FileTreeView) DiffVcs() {
+	sels := ftv.SelectedViews()
+	sz := len(sels)
+	if sz == 0 { // shouldn't happen
+		return
+	}
+	sn := sels[sz-1]
+	ftvv := sn.Embed(KiT_FileTreeView).(*FileTreeView)
+	fn := ftvv.FileNode()
+	if fn == nil || fn.Repo() == nil {
+		return
+	}
+	txt, err := fn.Repo().Show(string(fn.FPath), "")
+	if err == nil {
+		_, err = fn.OpenBuf()
+	}
+	if err != nil {
+		gi.PromptDialog(ftv.Viewport, gi.DlgOpts{Title: "Could not Diff", Prompt: err.Error()}, true, false, nil, nil)
+		return
+	}
+	tb := newTextBufLike("last-commit", txt, fn.Buf)
+	DiffViewDialog(ftv.Viewport, tb, fn.Buf, DlgOpts{Title: fn.RepoType() + " Diff: " + fn.Nm}, nil, nil)
+}
+
 // Cut copies to clip.Board and deletes selected items
 // satisfies gi.Clipper interface and can be overridden by subtypes
 func (ftv *FileTreeView) Cut() {
@@ -1401,6 +1597,20 @@ var FileTreeActiveInVcsModifiedFunc = ActionUpdateFunc(func(fni interface{}, act
 	}
 })
 
+// FileTreeActiveInVcsConflictedFunc is an ActionUpdateFunc that activates action if node is under version control
+// and the file has unresolved merge conflicts
+var FileTreeActiveInVcsConflictedFunc = ActionUpdateFunc(func(fni interface{}, act *gi.Action) {
+	ftv := fni.(ki.Ki).Embed(KiT_FileTreeView).(*FileTreeView)
+	fn := ftv.FileNode()
+	if fn != nil {
+		if fn.Repo() == nil || fn.IsDir() {
+			act.SetActiveState((false))
+			return
+		}
+		act.SetActiveState((fn.VcsState == FileNodeVcsConflicted))
+	}
+})
+
 // VcsGetRemoveLabelFunc gets the appropriate label for removing from version control
 var VcsLabelFunc = LabelFunc(func(fni interface{}, act *gi.Action) string {
 	ftv := fni.(ki.Ki).Embed(KiT_FileTreeView).(*FileTreeView)
@@ -1435,6 +1645,10 @@ var FileTreeViewProps = ki.Props{
 	".changed": ki.Props{
 		"color": "#4b7fd1",
 	},
+	".conflicted": ki.Props{
+		"color":       "#d97c20",
+		"font-weight": gi.WeightBold,
+	},
 	"#icon": ki.Props{
 		"width":   units.NewValue(1, units.Em),
 		"height":  units.NewValue(1, units.Em),
@@ -1538,6 +1752,29 @@ var FileTreeViewProps = ki.Props{
 			"updtfunc":   FileTreeActiveInVcsModifiedFunc,
 			"label-func": VcsLabelFunc,
 		}},
+		{"LogVcs", ki.Props{
+			"label":      "Vcs Log...",
+			"desc":       "Browse the history of commits of the file",
+			"updtfunc":   FileTreeActiveInVcsFunc,
+			"label-func": VcsLabelFunc,
+		}},
+		{"BlameVcs", ki.Props{
+			"label":      "Vcs Blame...",
+			"desc":       "Show the commit that last changed each line of the file",
+			"updtfunc":   FileTreeActiveInVcsFunc,
+			"label-func": VcsLabelFunc,
+		}},
+		{"DiffVcs", ki.Props{
+			"label":      "Vcs Diff...",
+			"desc":       "Show the differences between the last commit of the file and its current contents",
+			"updtfunc":   FileTreeActiveInVcsModifiedFunc,
+			"label-func": VcsLabelFunc,
+		}},
+		{"MergeVcs", ki.Props{
+			"desc":       "Resolve merge conflicts in file",
+			"updtfunc":   FileTreeActiveInVcsConflictedFunc,
+			"label-func": VcsLabelFunc,
+		}},
 	},
 }
 
@@ -1579,6 +1816,8 @@ func (ft *FileTreeView) Style2D() {
 					ft.AddClass("modified")
 				case FileNodeVcsAdded:
 					ft.AddClass("added")
+				case FileNodeVcsConflicted:
+					ft.AddClass("conflicted")
 				}
 			}
 		}
@@ -1595,5 +1834,12 @@ func FileNodeBufSigRecv(rvwki, sbufki ki.Ki, sig int64, data interface{}) {
 		if fn.VcsState == FileNodeInVcs {
 			fn.VcsState = FileNodeVcsModified
 		}
+	case TextBufSaved:
+		if fn.VcsState == FileNodeVcsConflicted && fn.Buf != nil && len(fn.Buf.MergeConflicts()) == 0 {
+			fn.ResolveVcs()
+		}
+		if fn.FRoot != nil {
+			fn.FRoot.UpdateVcs(false)
+		}
 	}
 }
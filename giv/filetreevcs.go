@@ -0,0 +1,117 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"strings"
+	"time"
+
+	"github.com/goki/ki/ki"
+)
+
+// FileTreeVcsDelay is how long the FileTree waits after the last request to
+// update the version control status of its files before doing so -- a burst
+// of requests, e.g., from saving several files, results in one update
+var FileTreeVcsDelay = 500 * time.Millisecond
+
+// UpdateVcs requests an update of the version control status of the files in
+// the tree, which is done in the background, after FileTreeVcsDelay without
+// further requests, so it never blocks the view.  The status of the whole
+// working tree is read again each time, as that is how the version control
+// tools report it, but only the nodes whose status changed are then updated,
+// on the event loop of the window viewing the tree.  If all is true, the list
+// of all the files in the repository is also read again, which is only
+// needed when files may have been added to or removed from it outside of the
+// tree.
+func (ft *FileTree) UpdateVcs(all bool) {
+	if ft.Repo == nil {
+		return
+	}
+	ft.vcsMu.Lock()
+	defer ft.vcsMu.Unlock()
+	ft.vcsAll = ft.vcsAll || all
+	if ft.vcsTimer != nil {
+		ft.vcsTimer.Stop()
+	}
+	ft.vcsTimer = time.AfterFunc(FileTreeVcsDelay, ft.vcsRefresh)
+}
+
+// vcsRefresh refreshes the repository caches, and then has vcsApply update
+// the nodes on the event loop -- runs in the UpdateVcs timer goroutine, and
+// runs again when done if there were further requests while it was running
+func (ft *FileTree) vcsRefresh() {
+	ft.vcsMu.Lock()
+	ft.vcsTimer = nil
+	if ft.vcsBusy {
+		ft.vcsPending = true
+		ft.vcsMu.Unlock()
+		return
+	}
+	ft.vcsBusy = true
+	all := ft.vcsAll
+	ft.vcsAll = false
+	ft.vcsMu.Unlock()
+
+	ft.RepoMu.Lock()
+	if all {
+		ft.Repo.CacheFileNames()
+	}
+	ft.Repo.CacheFilesAdded()
+	ft.Repo.CacheFilesModified()
+	ft.Repo.CacheFilesConflicted()
+	ft.RepoMu.Unlock()
+	ft.runOnEventLoop(ft.vcsApply)
+
+	ft.vcsMu.Lock()
+	ft.vcsBusy = false
+	if ft.vcsPending {
+		ft.vcsPending = false
+		ft.vcsTimer = time.AfterFunc(FileTreeVcsDelay, ft.vcsRefresh)
+	}
+	ft.vcsMu.Unlock()
+}
+
+// vcsApply sets the version control status of the nodes from the repository
+// caches, and updates those whose status changed -- runs on the event loop
+func (ft *FileTree) vcsApply() {
+	var chg []*FileNode
+	ft.RepoMu.Lock()
+	ft.FuncDownMeFirst(0, ft, func(k ki.Ki, level int, d interface{}) bool {
+		sfn := k.Embed(KiT_FileNode).(*FileNode)
+		if sfn == &ft.FileNode {
+			return true
+		}
+		st := ft.RepoVcsState(string(sfn.FPath))
+		if st == FileNodeInVcs && sfn.IsChanged() { // unsaved edits
+			st = FileNodeVcsModified
+		}
+		if st != sfn.VcsState {
+			sfn.VcsState = st
+			chg = append(chg, sfn)
+		}
+		return true
+	})
+	ft.RepoMu.Unlock()
+	for _, fn := range chg {
+		fn.UpdateSig()
+	}
+}
+
+// RepoVcsState returns the version control status of given file, from the
+// repository caches, which must be locked with RepoMu
+func (ft *FileTree) RepoVcsState(fpath string) FileNodeVcsStates {
+	relpth := strings.TrimPrefix(fpath, string(ft.FPath)+"/")
+	switch {
+	case ft.Repo.IsConflicted(relpth):
+		return FileNodeVcsConflicted
+	case ft.Repo.IsAdded(relpth):
+		return FileNodeVcsAdded
+	case ft.Repo.IsModified(relpth):
+		return FileNodeVcsModified
+	case ft.Repo.InRepo(relpth):
+		return FileNodeInVcs
+	}
+	return FileNodeNotInVcs
+}
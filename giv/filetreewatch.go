@@ -0,0 +1,155 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goki/gi/fswatch"
+	"github.com/goki/ki/ki"
+)
+
+// FileTreeWatchDelay is how long the FileTree waits after the last change
+// on disk to the files in its open directories before updating -- a burst
+// of changes, e.g., from a checkout or build, results in one update
+var FileTreeWatchDelay = 250 * time.Millisecond
+
+// Watch starts watching the open directories of the tree for changes on
+// disk made by other programs, if not already -- called by OpenPath.  Files
+// that are added, removed or renamed are updated in the tree, along with the
+// version control status, and open buffers of files that are written are
+// notified with TextBuf.FileModExternal -- on the event loop of the window
+// viewing the tree (see SetWindow).  Uses inotify on Linux, and otherwise
+// polling -- see fswatch.
+func (ft *FileTree) Watch() error {
+	if ft.Watcher != nil {
+		return nil
+	}
+	w, err := fswatch.New()
+	if err != nil {
+		return err
+	}
+	ft.Watcher = w
+	go ft.watchEvents(w)
+	return nil
+}
+
+// StopWatch stops watching the directories of the tree
+func (ft *FileTree) StopWatch() {
+	if ft.Watcher == nil {
+		return
+	}
+	ft.Watcher.Close()
+	ft.Watcher = nil
+	ft.watchMu.Lock()
+	if ft.watchTimer != nil {
+		ft.watchTimer.Stop()
+		ft.watchTimer = nil
+	}
+	ft.watchMu.Unlock()
+}
+
+// WatchDir starts watching given directory, if watching the tree
+func (ft *FileTree) WatchDir(dir string) {
+	if ft.Watcher == nil {
+		return
+	}
+	if err := ft.Watcher.Add(dir); err != nil {
+		log.Printf("giv.FileTree: could not watch directory: %v\n", err)
+	}
+}
+
+// UnwatchDir stops watching given directory
+func (ft *FileTree) UnwatchDir(dir string) {
+	if ft.Watcher == nil {
+		return
+	}
+	ft.Watcher.Remove(dir)
+}
+
+// watchEvents receives the changes from the watcher, and schedules the
+// update -- runs in its own goroutine until the watcher is closed
+func (ft *FileTree) watchEvents(w fswatch.Watcher) {
+	for ev := range w.Events() {
+		ft.watchMu.Lock()
+		switch ev.Op {
+		case fswatch.Rescan:
+			ft.watchRescan = true
+		case fswatch.Write:
+			if ft.watchFiles == nil {
+				ft.watchFiles = make(map[string]bool)
+			}
+			ft.watchFiles[ev.Name] = true
+		default:
+			if ft.watchDirs == nil {
+				ft.watchDirs = make(map[string]bool)
+			}
+			ft.watchDirs[filepath.Dir(ev.Name)] = true
+		}
+		if ft.watchTimer != nil {
+			ft.watchTimer.Stop()
+		}
+		ft.watchTimer = time.AfterFunc(FileTreeWatchDelay, ft.watchUpdate)
+		ft.watchMu.Unlock()
+	}
+}
+
+// watchUpdate takes the pending changes, and has watchApply apply them on
+// the event loop -- runs in the watch timer goroutine
+func (ft *FileTree) watchUpdate() {
+	ft.watchMu.Lock()
+	dirs, files, rescan := ft.watchDirs, ft.watchFiles, ft.watchRescan
+	ft.watchDirs, ft.watchFiles, ft.watchRescan = nil, nil, false
+	ft.watchTimer = nil
+	ft.watchMu.Unlock()
+	ft.runOnEventLoop(func() { ft.watchApply(dirs, files, rescan) })
+}
+
+// watchApply updates the directories that changed, and notifies the buffers
+// of files that were written -- all of them, and the whole tree, if rescan,
+// as changes were lost -- runs on the event loop
+func (ft *FileTree) watchApply(dirs, files map[string]bool, rescan bool) {
+	if rescan {
+		ft.UpdateNode()
+	} else {
+		for dir := range dirs {
+			if dn := ft.dirNode(dir); dn != nil {
+				dn.UpdateNode()
+			}
+		}
+	}
+	if rescan || len(files) > 0 {
+		ft.FuncDownMeFirst(0, ft, func(k ki.Ki, level int, d interface{}) bool {
+			sfn := k.Embed(KiT_FileNode).(*FileNode)
+			if sfn.Buf != nil && (rescan || files[string(sfn.FPath)]) {
+				sfn.Buf.FileModExternal() // only if its mod time changed
+			}
+			return true
+		})
+	}
+	ft.UpdateVcs(rescan)
+}
+
+// dirNode returns the node of given open directory, or nil if not in the
+// tree
+func (ft *FileTree) dirNode(dir string) *FileNode {
+	var dn *FileNode
+	ft.FuncDownMeFirst(0, ft, func(k ki.Ki, level int, d interface{}) bool {
+		sfn := k.Embed(KiT_FileNode).(*FileNode)
+		fp := string(sfn.FPath)
+		if fp == dir {
+			dn = sfn
+			return false
+		}
+		return dn == nil && strings.HasPrefix(dir, fp+string(filepath.Separator))
+	})
+	if dn == nil || !dn.IsDir() || !dn.IsOpen() {
+		return nil
+	}
+	return dn
+}
@@ -0,0 +1,425 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/units"
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+)
+
+// MergeView colors for the backgrounds of the two sides of a conflict in
+// the result, and of the conflict markers
+var (
+	MergeOursColor   = gi.Color{R: 10, G: 70, B: 100, A: 100}
+	MergeTheirsColor = gi.Color{R: 90, G: 60, B: 10, A: 100}
+	MergeMarkerColor = gi.Color{R: 100, G: 10, B: 10, A: 120}
+)
+
+// MergeView resolves the conflicts of a three-way merge: it shows the base
+// version and the two changed versions (ours and theirs) side by side, with
+// the lines each changed from the base highlighted, above the editable
+// result.  The conflicts in the result are found from the standard conflict
+// markers, as written by version control systems, and can be recomputed from
+// the three versions with Re-Merge.  The toolbar moves between them and
+// resolves the current one by accepting our version, theirs, or both.  When
+// viewing the conflicts of a FileNode (SetFileNode), the result is its
+// buffer, and saving it without any conflicts left marks it as resolved.
+type MergeView struct {
+	gi.Frame
+	BufBase     *TextBuf        `json:"-" xml:"-" desc:"the common base version that ours and theirs were changed from"`
+	BufOurs     *TextBuf        `json:"-" xml:"-" desc:"our version -- the one changed locally"`
+	BufTheirs   *TextBuf        `json:"-" xml:"-" desc:"their version -- the one being merged in"`
+	Result      *TextBuf        `json:"-" xml:"-" desc:"the merged result, which is edited to resolve the conflicts"`
+	FileNode    *FileNode       `json:"-" xml:"-" desc:"file node being merged, if set with SetFileNode -- Result is its buffer"`
+	Conflicts   []MergeConflict `json:"-" xml:"-" desc:"the conflicts marked in Result"`
+	CurConflict int             `json:"-" xml:"-" desc:"index of the current conflict in Conflicts, -1 if none"`
+}
+
+var KiT_MergeView = kit.Types.AddType(&MergeView{}, MergeViewProps)
+
+var MergeViewProps = ki.Props{
+	"background-color": &gi.Prefs.Colors.Background,
+	"color":            &gi.Prefs.Colors.Font,
+	"max-width":        -1,
+	"max-height":       -1,
+}
+
+// MergeViewPanes are the names of the panes, in order, and the titles shown
+// above them
+var MergeViewPanes = [][2]string{{"base", "Base"}, {"ours", "Ours (local)"}, {"theirs", "Theirs (incoming)"}}
+
+// SetBufs sets the base, ours and theirs versions and the result buffer,
+// and updates the view -- the conflicts are updated whenever the result is
+// edited
+func (mv *MergeView) SetBufs(base, ours, theirs, result *TextBuf) {
+	if mv.Result != nil && mv.Result != result {
+		mv.Result.TextBufSig.Disconnect(mv.This())
+	}
+	mv.BufBase = base
+	mv.BufOurs = ours
+	mv.BufTheirs = theirs
+	mv.Result = result
+	result.TextBufSig.Connect(mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		switch TextBufSignals(sig) {
+		case TextBufNew, TextBufInsert, TextBufDelete:
+			mvv := recv.Embed(KiT_MergeView).(*MergeView)
+			mvv.UpdateConflicts()
+		}
+	})
+	mv.CurConflict = -1
+	mv.Config()
+	mv.UpdateVersions()
+	mv.UpdateConflicts()
+}
+
+// SetVersions sets the text of the base, ours and theirs versions, in new
+// buffers with the same file info and highlighting as the result, and calls
+// SetBufs
+func (mv *MergeView) SetVersions(base, ours, theirs []byte, result *TextBuf) {
+	bufs := make([]*TextBuf, len(MergeViewPanes))
+	for i, txt := range [][]byte{base, ours, theirs} {
+		bufs[i] = newTextBufLike("merge-"+MergeViewPanes[i][0], txt, result)
+	}
+	mv.SetBufs(bufs[0], bufs[1], bufs[2], result)
+}
+
+// SetFileNode shows the conflicts of given file, from its version control
+// repository, with its buffer as the result -- if the file has no conflict
+// markers and is still just our version, the merge is computed from the
+// three versions
+func (mv *MergeView) SetFileNode(fn *FileNode) error {
+	if fn.Repo() == nil {
+		return errors.New("giv.MergeView: file is not in a version control repository")
+	}
+	base, ours, theirs, err := fn.Repo().ConflictVersions(string(fn.FPath))
+	if err != nil {
+		return err
+	}
+	if _, err := fn.OpenBuf(); err != nil {
+		return err
+	}
+	mv.FileNode = fn
+	mv.SetVersions(base, ours, theirs, fn.Buf)
+	if len(mv.Conflicts) == 0 && bytes.Equal(bytes.TrimSpace(fn.Buf.Text()), bytes.TrimSpace(ours)) {
+		mv.ReMerge()
+	}
+	return nil
+}
+
+// Config configures the toolbar, the version panes and the result pane
+func (mv *MergeView) Config() {
+	mv.Lay = gi.LayoutVert
+	config := kit.TypeAndNameList{}
+	config.Add(gi.KiT_ToolBar, "toolbar")
+	config.Add(gi.KiT_SplitView, "splitview")
+	mods, updt := mv.ConfigChildren(config, false)
+	if !mods {
+		updt = mv.UpdateStart()
+	}
+	mv.ConfigToolbar()
+	split := mv.ChildByName("splitview", 1).(*gi.SplitView)
+	split.Dim = gi.Y
+	config = kit.TypeAndNameList{}
+	config.Add(gi.KiT_SplitView, "versions")
+	config.Add(gi.KiT_Layout, "pane-result")
+	if smods, _ := split.ConfigChildren(config, false); smods {
+		vers := split.Child(0).(*gi.SplitView)
+		vers.Dim = gi.X
+		for _, pn := range MergeViewPanes {
+			pane := vers.AddNewChild(gi.KiT_Layout, "pane-"+pn[0]).(*gi.Layout)
+			mv.configPane(pane, pn[1], false)
+		}
+		vers.SetSplits(1, 1, 1)
+		mv.configPane(split.Child(1).(*gi.Layout), "Result", true)
+		split.SetSplits(.5, .5)
+	}
+	tvs := mv.TextViews()
+	for i, tb := range []*TextBuf{mv.BufBase, mv.BufOurs, mv.BufTheirs, mv.Result} {
+		if tvs[i].Buf != tb {
+			tvs[i].SetBuf(tb)
+		}
+	}
+	mv.UpdateEnd(updt)
+}
+
+// configPane configures given pane, with a title label above a scrolling
+// text view
+func (mv *MergeView) configPane(pane *gi.Layout, title string, editable bool) {
+	pane.Lay = gi.LayoutVert
+	pane.SetStretchMaxWidth()
+	pane.SetStretchMaxHeight()
+	lbl := pane.AddNewChild(gi.KiT_Label, "title").(*gi.Label)
+	lbl.SetText(title)
+	lbl.SetProp("font-weight", gi.WeightBold)
+	ly := pane.AddNewChild(gi.KiT_Layout, "text-lay").(*gi.Layout)
+	ly.SetStretchMaxWidth()
+	ly.SetStretchMaxHeight()
+	ly.SetMinPrefWidth(units.NewValue(20, units.Ch))
+	ly.SetMinPrefHeight(units.NewValue(10, units.Ch))
+	tv := ly.AddNewChild(KiT_TextView, "text").(*TextView)
+	if !editable {
+		tv.SetInactive()
+	}
+}
+
+// ConfigToolbar adds the actions to the toolbar, if not already done
+func (mv *MergeView) ConfigToolbar() {
+	tb := mv.ToolBar()
+	if tb.HasChildren() {
+		return
+	}
+	tb.SetStretchMaxWidth()
+	tb.AddAction(gi.ActOpts{Label: "Prev", Icon: "widget-wedge-up", Tooltip: "go to the previous conflict",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(len(mv.Conflicts) > 0)
+		}}, mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		mvv := recv.Embed(KiT_MergeView).(*MergeView)
+		mvv.PrevConflict()
+	})
+	tb.AddAction(gi.ActOpts{Label: "Next", Icon: "widget-wedge-down", Tooltip: "go to the next conflict",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(len(mv.Conflicts) > 0)
+		}}, mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		mvv := recv.Embed(KiT_MergeView).(*MergeView)
+		mvv.NextConflict()
+	})
+	lbl := tb.AddNewChild(gi.KiT_Label, "conflicts").(*gi.Label)
+	lbl.SetMinPrefWidth(units.NewValue(16, units.Ch))
+	tb.AddNewChild(gi.KiT_Separator, "sep-accept")
+	tb.AddAction(gi.ActOpts{Label: "Accept Ours", Icon: "step-bkwd", Tooltip: "resolve the current conflict with our (local) version",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(mv.CurConflict >= 0)
+		}}, mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		mvv := recv.Embed(KiT_MergeView).(*MergeView)
+		mvv.Accept(mvv.CurConflict, true, false)
+	})
+	tb.AddAction(gi.ActOpts{Label: "Accept Theirs", Icon: "step-fwd", Tooltip: "resolve the current conflict with their (incoming) version",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(mv.CurConflict >= 0)
+		}}, mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		mvv := recv.Embed(KiT_MergeView).(*MergeView)
+		mvv.Accept(mvv.CurConflict, false, true)
+	})
+	tb.AddAction(gi.ActOpts{Label: "Accept Both", Icon: "plus", Tooltip: "resolve the current conflict with both versions, ours first",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(mv.CurConflict >= 0)
+		}}, mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		mvv := recv.Embed(KiT_MergeView).(*MergeView)
+		mvv.Accept(mvv.CurConflict, true, true)
+	})
+	tb.AddNewChild(gi.KiT_Separator, "sep-save")
+	tb.AddAction(gi.ActOpts{Label: "Re-Merge", Icon: "update", Tooltip: "replace the result with the merge computed from the base, ours and theirs versions -- can be undone"},
+		mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			mvv := recv.Embed(KiT_MergeView).(*MergeView)
+			mvv.ReMerge()
+		})
+	tb.AddAction(gi.ActOpts{Label: "Save", Icon: "file-save", Tooltip: "save the result to its file -- if no conflicts are left, the file is marked as resolved in version control",
+		UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(mv.Result != nil && mv.Result.Filename != "")
+		}}, mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		mvv := recv.Embed(KiT_MergeView).(*MergeView)
+		mvv.SaveResult()
+	})
+}
+
+// ToolBar returns the toolbar
+func (mv *MergeView) ToolBar() *gi.ToolBar {
+	return mv.ChildByName("toolbar", 0).(*gi.ToolBar)
+}
+
+// TextViews returns the text views showing the base, ours, theirs and result
+// buffers, in that order
+func (mv *MergeView) TextViews() [4]*TextView {
+	split := mv.ChildByName("splitview", 1).(*gi.SplitView)
+	vers := split.Child(0).(*gi.SplitView)
+	var tvs [4]*TextView
+	for i := range MergeViewPanes {
+		tvs[i] = vers.Child(i).ChildByName("text-lay", 1).Child(0).(*TextView)
+	}
+	tvs[3] = split.Child(1).ChildByName("text-lay", 1).Child(0).(*TextView)
+	return tvs
+}
+
+// ResultView returns the text view of the result
+func (mv *MergeView) ResultView() *TextView {
+	return mv.TextViews()[3]
+}
+
+// UpdateVersions highlights the lines of ours and theirs that were changed
+// from the base, and the base lines that either changed
+func (mv *MergeView) UpdateVersions() {
+	if mv.BufBase == nil {
+		return
+	}
+	tvs := mv.TextViews()
+	for _, tv := range tvs[:3] {
+		tv.ClearLineColors()
+	}
+	for i, tb := range []*TextBuf{mv.BufOurs, mv.BufTheirs} {
+		tv := tvs[i+1]
+		for _, df := range mv.BufBase.DiffBufs(tb) {
+			if df.Tag == 'e' {
+				continue
+			}
+			clr := DiffChangeColor
+			switch df.Tag {
+			case 'd':
+				clr = DiffDeleteColor
+			case 'i':
+				clr = DiffInsertColor
+			}
+			for ln := df.I1; ln < df.I2; ln++ {
+				tvs[0].SetLineColor(ln, DiffChangeColor)
+			}
+			for ln := df.J1; ln < df.J2; ln++ {
+				tv.SetLineColor(ln, clr)
+			}
+		}
+	}
+	for _, tv := range tvs[:3] {
+		tv.UpdateSig()
+	}
+}
+
+// UpdateConflicts finds the conflicts marked in the result, and highlights
+// them
+func (mv *MergeView) UpdateConflicts() {
+	if mv.Result == nil || !mv.HasChildren() {
+		return
+	}
+	mv.Conflicts = mv.Result.MergeConflicts()
+	tv := mv.ResultView()
+	tv.ClearLineColors()
+	for _, mc := range mv.Conflicts {
+		for _, ln := range []int{mc.St, mc.Base, mc.Mid, mc.Ed} {
+			if ln >= 0 {
+				tv.SetLineColor(ln, MergeMarkerColor)
+			}
+		}
+		st, ed := mc.Ours()
+		for ln := st; ln < ed; ln++ {
+			tv.SetLineColor(ln, MergeOursColor)
+		}
+		st, ed = mc.BaseLines()
+		for ln := st; ln < ed; ln++ {
+			tv.SetLineColor(ln, DiffFillerColor)
+		}
+		st, ed = mc.Theirs()
+		for ln := st; ln < ed; ln++ {
+			tv.SetLineColor(ln, MergeTheirsColor)
+		}
+	}
+	if mv.CurConflict >= len(mv.Conflicts) {
+		mv.CurConflict = len(mv.Conflicts) - 1
+	}
+	if mv.CurConflict < 0 && len(mv.Conflicts) > 0 {
+		mv.CurConflict = 0
+	}
+	mv.UpdateLabel()
+	tv.UpdateSig()
+}
+
+// UpdateLabel updates the toolbar label showing the current conflict
+func (mv *MergeView) UpdateLabel() {
+	lbl := mv.ToolBar().ChildByName("conflicts", 2).(*gi.Label)
+	if len(mv.Conflicts) == 0 {
+		lbl.SetText("no conflicts")
+	} else {
+		lbl.SetText(fmt.Sprintf("conflict %v of %v", mv.CurConflict+1, len(mv.Conflicts)))
+	}
+}
+
+// GoToConflict makes given conflict the current one, and moves the result
+// cursor to it
+func (mv *MergeView) GoToConflict(idx int) {
+	if idx < 0 || idx >= len(mv.Conflicts) {
+		return
+	}
+	mv.CurConflict = idx
+	mv.UpdateLabel()
+	mv.ResultView().SetCursorShow(TextPos{Ln: mv.Conflicts[idx].St})
+}
+
+// NextConflict goes to the next conflict, wrapping around to the first
+func (mv *MergeView) NextConflict() {
+	if len(mv.Conflicts) == 0 {
+		return
+	}
+	mv.GoToConflict((mv.CurConflict + 1) % len(mv.Conflicts))
+}
+
+// PrevConflict goes to the previous conflict, wrapping around to the last
+func (mv *MergeView) PrevConflict() {
+	if len(mv.Conflicts) == 0 {
+		return
+	}
+	idx := mv.CurConflict - 1
+	if idx < 0 {
+		idx = len(mv.Conflicts) - 1
+	}
+	mv.GoToConflict(idx)
+}
+
+// Accept resolves given conflict with our version, their version, or both
+// (ours first) -- the edit can be undone in the result
+func (mv *MergeView) Accept(idx int, ours, theirs bool) bool {
+	if idx < 0 || idx >= len(mv.Conflicts) {
+		return false
+	}
+	mv.Result.MergeResolve(mv.Conflicts[idx], ours, theirs)
+	mv.UpdateConflicts()
+	mv.GoToConflict(mv.CurConflict)
+	return true
+}
+
+// ReMerge replaces the result with the merge computed from the base, ours
+// and theirs versions, with the conflicts marked -- the edit can be undone in
+// the result
+func (mv *MergeView) ReMerge() {
+	if mv.BufBase == nil || mv.Result == nil {
+		return
+	}
+	lines, _ := Merge3Lines(mv.BufBase.lineStrings(), mv.BufOurs.lineStrings(), mv.BufTheirs.lineStrings(), "ours", "theirs")
+	mb := &TextBuf{}
+	mb.InitName(mb, "merge-result")
+	mb.SetText([]byte(strings.Join(lines, "\n")))
+	if mv.Result.NumLines() == 0 || mb.NumLines() == 0 {
+		mv.Result.SetText(mb.Text())
+	} else {
+		mv.Result.PatchFromBufUndo(mb, mv.Result.DiffBufs(mb), true)
+	}
+	mv.CurConflict = -1
+	mv.UpdateConflicts()
+	mv.GoToConflict(0)
+}
+
+// SaveResult saves the result to its file, asking first if any conflicts
+// are left -- a conflicted FileNode is marked as resolved when saved without
+// any (see FileNodeBufSigRecv)
+func (mv *MergeView) SaveResult() {
+	if mv.Result == nil || mv.Result.Filename == "" {
+		return
+	}
+	if len(mv.Conflicts) == 0 {
+		mv.Result.Save()
+		return
+	}
+	gi.ChoiceDialog(mv.Viewport, gi.DlgOpts{Title: "Conflicts Remain",
+		Prompt: fmt.Sprintf("The result still has %v unresolved conflict(s) -- save anyway?  The file will remain conflicted in version control.", len(mv.Conflicts))},
+		[]string{"Save", "Cancel"},
+		mv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			if sig == 0 {
+				mvv := recv.Embed(KiT_MergeView).(*MergeView)
+				mvv.Result.Save()
+			}
+		})
+}
@@ -8,6 +8,9 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,22 +20,51 @@ import (
 // and otherwise has the exact same visible bytes as the input
 type OutBufMarkupFunc func(line []byte) []byte
 
+// OutDiag is a diagnostic parsed from a line of output, e.g., a compiler error
+type OutDiag struct {
+	File     string         `desc:"file name as reported in the output"`
+	Ln       int            `desc:"0-based line number"`
+	Ch       int            `desc:"0-based char position -- -1 if not reported, in which case the whole line is marked"`
+	Severity DiagSeverities `desc:"severity"`
+	Msg      string         `desc:"the message"`
+}
+
+// OutBufDiagFunc parses a line of output (raw, not escaped) for a
+// diagnostic -- returns false if the line is not one
+type OutBufDiagFunc func(line []byte) (OutDiag, bool)
+
+// OutBufDiagBufFunc returns the TextBuf to add diagnostics for given file
+// name (as reported in the output) to -- nil if the file is not open
+type OutBufDiagBufFunc func(fname string) *TextBuf
+
 // OutBuf is a TextBuf that records the output from an io.Reader using
 // bufio.Scanner -- optimized to combine fast chunks of output into
 // large blocks of updating.  Also supports arbitrary markup function
 // that operates on each line of output bytes.
 type OutBuf struct {
-	Out        io.Reader        `desc:"the output that we are reading from, as an io.Reader"`
-	Buf        *TextBuf         `desc:"the TextBuf that we output to"`
-	BatchMSec  int              `desc:"default 200: how many milliseconds to wait while batching output"`
-	MarkupFun  OutBufMarkupFunc `desc:"optional markup function that adds html tags to given line of output -- essential that it ONLY adds tags, and otherwise has the exact same visible bytes as the input"`
-	CurOutLns  [][]byte         `desc:"current buffered output raw lines -- not yet sent to Buf"`
-	CurOutMus  [][]byte         `desc:"current buffered output markup lines -- not yet sent to Buf"`
-	Mu         sync.Mutex       `desc:"mutex protecting updating of CurOutLns and Buf, and timer"`
-	LastOut    time.Time        `desc:"time when last output was sent to buffer"`
-	AfterTimer *time.Timer      `desc:"time.AfterFunc that is started after new input is received and not immediately output -- ensures that it will get output if no further burst happens"`
+	Out        io.Reader         `desc:"the output that we are reading from, as an io.Reader"`
+	Buf        *TextBuf          `desc:"the TextBuf that we output to"`
+	BatchMSec  int               `desc:"default 200: how many milliseconds to wait while batching output"`
+	MarkupFun  OutBufMarkupFunc  `desc:"optional markup function that adds html tags to given line of output -- essential that it ONLY adds tags, and otherwise has the exact same visible bytes as the input"`
+	CurOutLns  [][]byte          `desc:"current buffered output raw lines -- not yet sent to Buf"`
+	CurOutMus  [][]byte          `desc:"current buffered output markup lines -- not yet sent to Buf"`
+	Mu         sync.Mutex        `desc:"mutex protecting updating of CurOutLns and Buf, and timer"`
+	LastOut    time.Time         `desc:"time when last output was sent to buffer"`
+	AfterTimer *time.Timer       `desc:"time.AfterFunc that is started after new input is received and not immediately output -- ensures that it will get output if no further burst happens"`
+	DiagSrc    string            `desc:"Source for diagnostics parsed from the output -- the previous ones from this source are cleared from each buffer when the first new one is added"`
+	DiagFun    OutBufDiagFunc    `desc:"optional function that parses diagnostics from lines of output -- see InitDiags"`
+	DiagBufFun OutBufDiagBufFunc `desc:"function that returns the buffer to add diagnostics to for a given file"`
+	DiagBufs   map[*TextBuf]bool `desc:"buffers that have had diagnostics added in this run -- value is true if needing a refresh"`
 }
 
+// outDiagBufs records, for each diagnostic source, all the buffers that have
+// had diagnostics added from that source, by any OutBuf -- these are all
+// cleared when a new run for that source starts
+var outDiagBufs = struct {
+	sync.Mutex
+	bufs map[string]map[*TextBuf]struct{}
+}{bufs: make(map[string]map[*TextBuf]struct{})}
+
 // Init sets the various params and prepares for running
 func (ob *OutBuf) Init(out io.Reader, buf *TextBuf, batchMSec int, markup OutBufMarkupFunc) {
 	ob.Out = out
@@ -45,8 +77,155 @@ func (ob *OutBuf) Init(out io.Reader, buf *TextBuf, batchMSec int, markup OutBuf
 	}
 }
 
-// MonOut monitors the output and updates the TextBuf
+// InitDiags sets up parsing of diagnostics (errors, warnings) from the
+// output, e.g., for compiler output, which are added to the buffers
+// returned by bufFun, with given Source.  If parse is nil, ParseFileLineDiag
+// is used.
+func (ob *OutBuf) InitDiags(src string, parse OutBufDiagFunc, bufFun OutBufDiagBufFunc) {
+	if parse == nil {
+		parse = ParseFileLineDiag
+	}
+	ob.DiagSrc = src
+	ob.DiagFun = parse
+	ob.DiagBufFun = bufFun
+	ob.DiagBufs = make(map[*TextBuf]bool)
+}
+
+// OutDiag parses given raw line of output for a diagnostic, and adds it to
+// the relevant buffer if found -- MUST be called under mutex protection
+func (ob *OutBuf) OutDiag(line []byte) {
+	if ob.DiagFun == nil || ob.DiagBufFun == nil {
+		return
+	}
+	od, ok := ob.DiagFun(line)
+	if !ok {
+		return
+	}
+	tb := ob.DiagBufFun(od.File)
+	if tb == nil {
+		return
+	}
+	if ob.DiagBufs == nil {
+		ob.DiagBufs = make(map[*TextBuf]bool)
+	}
+	if _, has := ob.DiagBufs[tb]; !has {
+		tb.ClearDiags(ob.DiagSrc)
+		outDiagBufs.Lock()
+		sb := outDiagBufs.bufs[ob.DiagSrc]
+		if sb == nil {
+			sb = make(map[*TextBuf]struct{})
+			outDiagBufs.bufs[ob.DiagSrc] = sb
+		}
+		sb[tb] = struct{}{}
+		outDiagBufs.Unlock()
+	}
+	ob.DiagBufs[tb] = true
+	tb.AddDiag(tb.DiagLineReg(od.Ln, od.Ch), od.Severity, od.Msg, ob.DiagSrc)
+}
+
+// ResetDiags clears the diagnostics of our DiagSrc from all the buffers that
+// have them from a previous run, by this or any other OutBuf -- called when
+// a new run starts, so that a clean run leaves no stale diagnostics behind
+func (ob *OutBuf) ResetDiags() {
+	if ob.DiagFun == nil {
+		return
+	}
+	ob.Mu.Lock()
+	ob.DiagBufs = make(map[*TextBuf]bool)
+	ob.Mu.Unlock()
+	outDiagBufs.Lock()
+	sb := outDiagBufs.bufs[ob.DiagSrc]
+	delete(outDiagBufs.bufs, ob.DiagSrc)
+	outDiagBufs.Unlock()
+	for tb := range sb {
+		tb.ClearDiags(ob.DiagSrc)
+		tb.runOnEventLoop(tb.RefreshViews)
+	}
+}
+
+// FileLineDiagRegexp matches the standard compiler diagnostic format:
+// file:line[:col]: message -- the file can start with a Windows drive letter
+var FileLineDiagRegexp = regexp.MustCompile(`^\s*((?:[A-Za-z]:)?[^:\s][^:]*):(\d+)(?::(\d+))?:\s*(.*)$`)
+
+// ParseFileLineDiag is an OutBufDiagFunc for the standard compiler format:
+// file:line[:col]: [error: | warning: | note: ]message, as output by go,
+// gcc, clang, rustc etc -- line and col are 1-based
+func ParseFileLineDiag(line []byte) (OutDiag, bool) {
+	var od OutDiag
+	m := FileLineDiagRegexp.FindSubmatch(line)
+	if m == nil {
+		return od, false
+	}
+	od.File = string(m[1])
+	ln, _ := strconv.Atoi(string(m[2]))
+	od.Ln = ln - 1
+	od.Ch = -1
+	if len(m[3]) > 0 {
+		ch, _ := strconv.Atoi(string(m[3]))
+		od.Ch = ch - 1
+	}
+	msg := string(m[4])
+	lmsg := strings.ToLower(msg)
+	switch {
+	case strings.HasPrefix(lmsg, "warning"):
+		od.Severity = DiagWarning
+	case strings.HasPrefix(lmsg, "note"), strings.HasPrefix(lmsg, "info"):
+		od.Severity = DiagInfo
+	default:
+		od.Severity = DiagError
+	}
+	if ci := strings.Index(lmsg, ": "); ci > 0 && diagSevPrefix(lmsg[:ci]) {
+		msg = msg[ci+2:]
+	}
+	od.Msg = msg
+	return od, true
+}
+
+// FileLineLinkMarkup is an OutBufMarkupFunc that makes the file:line[:col]
+// at the start of a line in the standard compiler diagnostic format into a
+// file: link to that position, e.g., file:///src/main.go#L12C3 -- relative
+// file names give relative links, e.g., file:main.go#L12
+func FileLineLinkMarkup(line []byte) []byte {
+	m := FileLineDiagRegexp.FindSubmatchIndex(line)
+	if m == nil {
+		return line
+	}
+	fname := strings.Replace(string(line[m[2]:m[3]]), `\`, "/", -1)
+	switch {
+	case strings.HasPrefix(fname, "/"):
+		fname = "//" + fname
+	case len(fname) > 1 && fname[1] == ':': // drive letter
+		fname = "///" + fname
+	}
+	href := "file:" + fname + "#L" + string(line[m[4]:m[5]])
+	ed := m[5]
+	if m[6] >= 0 {
+		href += "C" + string(line[m[6]:m[7]])
+		ed = m[7]
+	}
+	var b bytes.Buffer
+	b.Write(line[:m[2]])
+	b.WriteString(`<a href="` + href + `">`)
+	b.Write(line[m[2]:ed])
+	b.WriteString("</a>")
+	b.Write(line[ed:])
+	return b.Bytes()
+}
+
+// diagSevPrefix returns true if given message prefix just names the
+// severity, e.g., "error" or "warning", and thus can be stripped from the message
+func diagSevPrefix(pfx string) bool {
+	switch pfx {
+	case "error", "fatal error", "warning", "note", "info":
+		return true
+	}
+	return false
+}
+
+// MonOut monitors the output and updates the TextBuf -- this is a new run,
+// so any diagnostics from previous runs are cleared first
 func (ob *OutBuf) MonOut() {
+	ob.ResetDiags()
 	outscan := bufio.NewScanner(ob.Out) // line at a time
 	ob.CurOutLns = make([][]byte, 0, 100)
 	ob.CurOutMus = make([][]byte, 0, 100)
@@ -58,6 +237,7 @@ func (ob *OutBuf) MonOut() {
 			ob.AfterTimer.Stop()
 			ob.AfterTimer = nil
 		}
+		ob.OutDiag(b)
 		ob.CurOutLns = append(ob.CurOutLns, bc)
 		mup := bc
 		if ob.MarkupFun != nil {
@@ -96,6 +276,12 @@ func (ob *OutBuf) OutToBuf() {
 	mlns = append(mlns, lfb...)
 	ob.Buf.AppendTextMarkup(tlns, mlns, false, true) // no undo, yes signal
 	ob.Buf.AutoScrollViews()
+	for tb, updt := range ob.DiagBufs {
+		if updt {
+			tb.runOnEventLoop(tb.RefreshViews)
+			ob.DiagBufs[tb] = false
+		}
+	}
 	ob.CurOutLns = make([][]byte, 0, 100)
 	ob.CurOutMus = make([][]byte, 0, 100)
 }
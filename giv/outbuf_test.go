@@ -0,0 +1,92 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseFileLineDiag(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		od   OutDiag
+	}{
+		{"main.go:12:3: undefined: x", true, OutDiag{"main.go", 11, 2, DiagError, "undefined: x"}},
+		{"  ./a/b.go:7: warning: unused", true, OutDiag{"./a/b.go", 6, -1, DiagWarning, "unused"}},
+		{"/src/x.c:1:1: note: here", true, OutDiag{"/src/x.c", 0, 0, DiagInfo, "here"}},
+		{"x.c:2:5: fatal error: no file", true, OutDiag{"x.c", 1, 4, DiagError, "no file"}},
+		{`C:\src\main.go:12:3: error: bad`, true, OutDiag{`C:\src\main.go`, 11, 2, DiagError, "bad"}},
+		{"d:/src/main.go:4: oops", true, OutDiag{"d:/src/main.go", 3, -1, DiagError, "oops"}},
+		{"a:12: short name", true, OutDiag{"a", 11, -1, DiagError, "short name"}},
+		{"ok  \tgithub.com/goki/gi\t0.1s", false, OutDiag{}},
+		{"FAIL: no line number", false, OutDiag{}},
+		{"C:\\no\\line: x", false, OutDiag{}},
+		{"", false, OutDiag{}},
+	}
+	for _, tt := range tests {
+		od, ok := ParseFileLineDiag([]byte(tt.line))
+		if ok != tt.ok {
+			t.Errorf("%q: ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && od != tt.od {
+			t.Errorf("%q: got %+v, want %+v", tt.line, od, tt.od)
+		}
+	}
+}
+
+var markupTagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+func TestFileLineLinkMarkup(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"main.go:12:3: undefined: x", `<a href="file:main.go#L12C3">main.go:12:3</a>: undefined: x`},
+		{"  /src/a.go:7: warning: unused", `  <a href="file:///src/a.go#L7">/src/a.go:7</a>: warning: unused`},
+		{`C:\src\b.go:4:1: bad`, `<a href="file:///C:/src/b.go#L4C1">C:\src\b.go:4:1</a>: bad`},
+		{"no diagnostic here", "no diagnostic here"},
+	}
+	for _, tt := range tests {
+		got := string(FileLineLinkMarkup([]byte(tt.line)))
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.line, got, tt.want)
+		}
+		if vis := markupTagRegexp.ReplaceAllString(got, ""); vis != tt.line {
+			t.Errorf("%q: markup changed the visible text to %q", tt.line, vis)
+		}
+	}
+}
+
+func TestOutBufMonOut(t *testing.T) {
+	out := "building\nmain.go:2:1: undefined: x\nlib.go:1: other file\n"
+	ob := &OutBuf{}
+	buf := &TextBuf{}
+	buf.InitName(buf, "out")
+	src := &TextBuf{}
+	src.InitName(src, "main")
+	src.SetText([]byte("package main\nx\n"))
+	src.AddDiag(TextRegion{Start: TextPos{Ln: 0}, End: TextPos{Ln: 0, Ch: 1}}, DiagError, "stale", "build")
+	ob.Init(strings.NewReader(out), buf, 0, FileLineLinkMarkup)
+	ob.InitDiags("build", nil, func(fname string) *TextBuf {
+		if fname == "main.go" {
+			return src
+		}
+		return nil
+	})
+	ob.MonOut()
+	if got := string(buf.Text()); !strings.HasPrefix(got, out) {
+		t.Errorf("output text %q, want %q", got, out)
+	}
+	if got, want := string(buf.Markup[1]), `<a href="file:main.go#L2C1">main.go:2:1</a>: undefined: x`; got != want {
+		t.Errorf("output markup %q, want %q", got, want)
+	}
+	if len(src.Diags) != 1 || src.Diags[0].Msg != "undefined: x" || src.Diags[0].Reg.Start.Ln != 1 {
+		t.Errorf("diagnostics %+v, want one for undefined: x on line 1", src.Diags)
+	}
+}
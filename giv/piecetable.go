@@ -0,0 +1,346 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// PieceTable is a piece-table store of text, used by TextBuf for files too
+// large to hold in memory (see TextBufLargeFileSize).  The original file is
+// never loaded -- it is indexed by line once, and read on demand -- and all
+// edits are appended to an Add buffer, with the current text described by a
+// sequence of Pieces referring into either one.  It is not safe for
+// concurrent use -- TextBuf guards it with LinesMu.
+type PieceTable struct {
+	Filename  string   `desc:"name of the original file"`
+	File      *os.File `json:"-" xml:"-" desc:"original file, which is read on demand"`
+	OrigSize  int64    `desc:"size of original file"`
+	OrigLines []int64  `json:"-" xml:"-" desc:"offset in the original file of the start of each line after the first, i.e., one past each newline"`
+	OrigCRLF  bool     `desc:"the original file has at least one CRLF line ending -- lines always include their raw bytes, so such files should not be edited in a PieceTable"`
+	Add       []byte   `json:"-" xml:"-" desc:"buffer of all the text added by edits"`
+	Pieces    []Piece  `json:"-" xml:"-" desc:"the pieces of the original file and Add buffer that make up the current text, in order"`
+	starts    []int64  // offset of the start of each piece in the current text
+	lfs       []int    // number of newlines in the current text before each piece
+	size      int64    // total size of current text
+	nlf       int      // total number of newlines in current text
+	lastLF    bool     // current text ends in a newline
+}
+
+// Piece is one contiguous region of the original file or the Add buffer
+type Piece struct {
+	Add bool  `desc:"piece is in the Add buffer, otherwise in the original file"`
+	Off int64 `desc:"starting offset in the source"`
+	Len int64 `desc:"length in bytes"`
+	NLF int   `desc:"number of newlines in the piece"`
+}
+
+// PieceTableChunk is the size of the chunks the original file is read in
+// when indexing its lines
+var PieceTableChunk = 1 << 20
+
+// OpenPieceTable opens given file as a new PieceTable, indexing its lines
+func OpenPieceTable(filename string) (*PieceTable, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	pt := &PieceTable{Filename: filename, File: fp}
+	if err = pt.IndexOrig(); err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return pt, nil
+}
+
+// IndexOrig reads through the original file, recording the start of each
+// line, and resets the pieces to the whole file
+func (pt *PieceTable) IndexOrig() error {
+	pt.OrigLines = pt.OrigLines[:0]
+	pt.OrigCRLF = false
+	buf := make([]byte, PieceTableChunk)
+	off := int64(0)
+	prvCR := false // last byte of the previous chunk is a CR
+	for {
+		n, err := pt.File.ReadAt(buf, off)
+		b := buf[:n]
+		bo := 0
+		for {
+			i := bytes.IndexByte(b[bo:], '\n')
+			if i < 0 {
+				break
+			}
+			if lf := bo + i; (lf > 0 && b[lf-1] == '\r') || (lf == 0 && prvCR) {
+				pt.OrigCRLF = true
+			}
+			bo += i + 1
+			pt.OrigLines = append(pt.OrigLines, off+int64(bo))
+		}
+		if n > 0 {
+			prvCR = b[n-1] == '\r'
+		}
+		off += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	pt.OrigSize = off
+	pt.Add = nil
+	pt.Pieces = nil
+	if off > 0 {
+		pt.Pieces = []Piece{{Off: 0, Len: off, NLF: len(pt.OrigLines)}}
+	}
+	pt.reindex()
+	return nil
+}
+
+// Close closes the original file
+func (pt *PieceTable) Close() error {
+	if pt.File == nil {
+		return nil
+	}
+	err := pt.File.Close()
+	pt.File = nil
+	return err
+}
+
+// reindex updates the start offset and newline count of each piece, and the
+// totals
+func (pt *PieceTable) reindex() {
+	np := len(pt.Pieces)
+	pt.starts = pt.starts[:0]
+	pt.lfs = pt.lfs[:0]
+	off := int64(0)
+	nlf := 0
+	for i := 0; i < np; i++ {
+		pt.starts = append(pt.starts, off)
+		pt.lfs = append(pt.lfs, nlf)
+		off += pt.Pieces[i].Len
+		nlf += pt.Pieces[i].NLF
+	}
+	pt.size = off
+	pt.nlf = nlf
+	pt.lastLF = false
+	if np > 0 {
+		var lb [1]byte
+		if _, err := pt.ReadAt(lb[:], off-1); err == nil {
+			pt.lastLF = lb[0] == '\n'
+		}
+	}
+}
+
+// Size returns the total size of the current text in bytes
+func (pt *PieceTable) Size() int64 {
+	return pt.size
+}
+
+// NumLines returns the number of lines in the current text -- a final
+// newline does not start a new line, and empty text has one blank line, as
+// in TextBuf
+func (pt *PieceTable) NumLines() int {
+	if pt.size == 0 {
+		return 1
+	}
+	if pt.lastLF {
+		return pt.nlf
+	}
+	return pt.nlf + 1
+}
+
+// origNLF returns the number of newlines in given region of the original file
+func (pt *PieceTable) origNLF(off, n int64) int {
+	return pt.origLineIdx(off+n) - pt.origLineIdx(off)
+}
+
+// origLineIdx returns the index of the first entry in OrigLines after off
+func (pt *PieceTable) origLineIdx(off int64) int {
+	return sort.Search(len(pt.OrigLines), func(i int) bool {
+		return pt.OrigLines[i] > off
+	})
+}
+
+// pieceNLF returns the number of newlines in given piece
+func (pt *PieceTable) pieceNLF(p *Piece) int {
+	if p.Add {
+		return bytes.Count(pt.Add[p.Off:p.Off+p.Len], []byte("\n"))
+	}
+	return pt.origNLF(p.Off, p.Len)
+}
+
+// pieceAt returns the index of the piece containing given offset --
+// len(Pieces) if at or past the end
+func (pt *PieceTable) pieceAt(off int64) int {
+	return sort.Search(len(pt.Pieces), func(i int) bool {
+		return pt.starts[i]+pt.Pieces[i].Len > off
+	})
+}
+
+// LineStart returns the offset of the start of given line (0-based) in the
+// current text -- the size of the text if past the last line
+func (pt *PieceTable) LineStart(ln int) int64 {
+	if ln <= 0 {
+		return 0
+	}
+	if ln > pt.nlf {
+		return pt.size
+	}
+	// piece containing the ln'th newline
+	i := sort.Search(len(pt.Pieces), func(i int) bool {
+		return pt.lfs[i]+pt.Pieces[i].NLF >= ln
+	})
+	p := &pt.Pieces[i]
+	k := ln - pt.lfs[i] // 1-based index of newline within piece
+	if p.Add {
+		b := pt.Add[p.Off : p.Off+p.Len]
+		bo := 0
+		for ; k > 0; k-- {
+			bo += bytes.IndexByte(b[bo:], '\n') + 1
+		}
+		return pt.starts[i] + int64(bo)
+	}
+	oi := pt.origLineIdx(p.Off) + k - 1
+	return pt.starts[i] + pt.OrigLines[oi] - p.Off
+}
+
+// LineBytes returns the text of given line, without the newline -- the line
+// after a final newline is valid, and empty
+func (pt *PieceTable) LineBytes(ln int) ([]byte, error) {
+	if ln < 0 || ln > pt.nlf {
+		return nil, fmt.Errorf("giv.PieceTable LineBytes: line %v out of range", ln)
+	}
+	st := pt.LineStart(ln)
+	ed := pt.size
+	if ln < pt.nlf {
+		ed = pt.LineStart(ln+1) - 1
+	}
+	b := make([]byte, ed-st)
+	if _, err := pt.ReadAt(b, st); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadAt reads len(b) bytes of the current text starting at given offset,
+// implementing io.ReaderAt
+func (pt *PieceTable) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("giv.PieceTable ReadAt: negative offset")
+	}
+	n := 0
+	for i := pt.pieceAt(off); i < len(pt.Pieces) && n < len(b); i++ {
+		p := &pt.Pieces[i]
+		po := off + int64(n) - pt.starts[i]
+		m := int(p.Len - po)
+		if m > len(b)-n {
+			m = len(b) - n
+		}
+		if p.Add {
+			copy(b[n:n+m], pt.Add[p.Off+po:])
+		} else {
+			if _, err := pt.File.ReadAt(b[n:n+m], p.Off+po); err != nil {
+				return n, err
+			}
+		}
+		n += m
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Reader returns a reader over the whole current text, for streaming
+// through it, e.g., for searching -- the text must not be edited while it
+// is in use
+func (pt *PieceTable) Reader() io.Reader {
+	return io.NewSectionReader(pt, 0, pt.size)
+}
+
+// WriteTo writes the whole current text to given writer, implementing
+// io.WriterTo
+func (pt *PieceTable) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, pt.Reader())
+}
+
+// Bytes returns a copy of the whole current text -- for large files, use
+// Reader or WriteTo instead where possible
+func (pt *PieceTable) Bytes() []byte {
+	b := make([]byte, pt.size)
+	pt.ReadAt(b, 0)
+	return b
+}
+
+// split ensures that a piece starts at given offset, splitting the piece
+// containing it if needed, and returns the index of that piece --
+// len(Pieces) if at the end
+func (pt *PieceTable) split(off int64) int {
+	i := pt.pieceAt(off)
+	if i >= len(pt.Pieces) || pt.starts[i] == off {
+		return i
+	}
+	p := pt.Pieces[i]
+	rel := off - pt.starts[i]
+	lp := Piece{Add: p.Add, Off: p.Off, Len: rel}
+	lp.NLF = pt.pieceNLF(&lp)
+	rp := Piece{Add: p.Add, Off: p.Off + rel, Len: p.Len - rel, NLF: p.NLF - lp.NLF}
+	pt.Pieces = append(pt.Pieces, Piece{})
+	copy(pt.Pieces[i+2:], pt.Pieces[i+1:])
+	pt.Pieces[i] = lp
+	pt.Pieces[i+1] = rp
+	pt.reindex()
+	return i + 1
+}
+
+// Insert inserts given text at given offset
+func (pt *PieceTable) Insert(off int64, text []byte) {
+	if len(text) == 0 {
+		return
+	}
+	if off > pt.size {
+		off = pt.size
+	}
+	ao := int64(len(pt.Add))
+	pt.Add = append(pt.Add, text...)
+	np := Piece{Add: true, Off: ao, Len: int64(len(text)), NLF: bytes.Count(text, []byte("\n"))}
+	i := pt.split(off)
+	if i > 0 { // typing extends the previous added piece
+		pp := &pt.Pieces[i-1]
+		if pp.Add && pp.Off+pp.Len == ao {
+			pp.Len += np.Len
+			pp.NLF += np.NLF
+			pt.reindex()
+			return
+		}
+	}
+	pt.Pieces = append(pt.Pieces, Piece{})
+	copy(pt.Pieces[i+1:], pt.Pieces[i:])
+	pt.Pieces[i] = np
+	pt.reindex()
+}
+
+// Delete deletes n bytes starting at given offset
+func (pt *PieceTable) Delete(off, n int64) {
+	if off < 0 {
+		n += off
+		off = 0
+	}
+	if off+n > pt.size {
+		n = pt.size - off
+	}
+	if n <= 0 {
+		return
+	}
+	i := pt.split(off)
+	j := pt.split(off + n)
+	pt.Pieces = append(pt.Pieces[:i], pt.Pieces[j:]...)
+	pt.reindex()
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goki/ki/ints"
+)
+
+// writeTestFile writes given text to a new file in a temporary directory,
+// returning its name and a func removing the directory
+func writeTestFile(t *testing.T, name string, txt []byte) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "giv-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(fn, txt, 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return fn, func() { os.RemoveAll(dir) }
+}
+
+// checkPieceTable compares the text, lines and line starts of the piece
+// table against those of the model text
+func checkPieceTable(t *testing.T, step string, pt *PieceTable, model []byte) {
+	t.Helper()
+	if got := pt.Bytes(); !bytes.Equal(got, model) {
+		t.Fatalf("%v: Bytes = %q, want %q", step, got, model)
+	}
+	if pt.Size() != int64(len(model)) {
+		t.Fatalf("%v: Size = %v, want %v", step, pt.Size(), len(model))
+	}
+	lns := bytes.Split(model, []byte("\n"))
+	nln := len(lns)
+	if nln > 1 && len(lns[nln-1]) == 0 {
+		nln--
+	}
+	if got := pt.NumLines(); got != nln {
+		t.Fatalf("%v: NumLines = %v, want %v", step, got, nln)
+	}
+	off := int64(0)
+	for ln, lb := range lns {
+		if got := pt.LineStart(ln); got != off {
+			t.Fatalf("%v: LineStart(%v) = %v, want %v", step, ln, got, off)
+		}
+		got, err := pt.LineBytes(ln)
+		if err != nil {
+			t.Fatalf("%v: LineBytes(%v): %v", step, ln, err)
+		}
+		if !bytes.Equal(got, lb) {
+			t.Fatalf("%v: LineBytes(%v) = %q, want %q", step, ln, got, lb)
+		}
+		off += int64(len(lb)) + 1
+	}
+	if got := pt.LineStart(len(lns) + 1); got != int64(len(model)) {
+		t.Fatalf("%v: LineStart past end = %v, want %v", step, got, len(model))
+	}
+}
+
+func TestPieceTableEdits(t *testing.T) {
+	defer func(sz int) { PieceTableChunk = sz }(PieceTableChunk)
+	PieceTableChunk = 7 // lines span chunks
+
+	model := []byte("first line\nsecond\r\n\nfourth line here\nlast")
+	fn, rm := writeTestFile(t, "pt.txt", model)
+	defer rm()
+	pt, err := OpenPieceTable(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pt.Close()
+	if !pt.OrigCRLF {
+		t.Errorf("OrigCRLF = false, want true")
+	}
+	checkPieceTable(t, "open", pt, model)
+
+	inserts := []string{"x", "\n", "ab\ncd", "\n\n", "new line\n", "é€"}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		if len(model) > 0 && rnd.Intn(5) < 2 {
+			off := rnd.Intn(len(model))
+			n := 1 + rnd.Intn(ints.MinInt(len(model)-off, 12))
+			pt.Delete(int64(off), int64(n))
+			model = append(model[:off:off], model[off+n:]...)
+			checkPieceTable(t, "delete", pt, model)
+			continue
+		}
+		off := rnd.Intn(len(model) + 1)
+		txt := []byte(inserts[rnd.Intn(len(inserts))])
+		pt.Insert(int64(off), txt)
+		nm := append([]byte{}, model[:off]...)
+		nm = append(nm, txt...)
+		model = append(nm, model[off:]...)
+		checkPieceTable(t, "insert", pt, model)
+	}
+
+	pt.Delete(0, pt.Size())
+	checkPieceTable(t, "delete all", pt, nil)
+	pt.Insert(0, []byte("a\n"))
+	checkPieceTable(t, "insert into empty", pt, []byte("a\n"))
+}
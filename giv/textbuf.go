@@ -17,6 +17,7 @@ import (
 
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/histyle"
+	"github.com/goki/gi/lsp"
 	"github.com/goki/gi/spell"
 	"github.com/goki/ki/indent"
 	"github.com/goki/ki/ints"
@@ -42,7 +43,10 @@ type TextBufOpts struct {
 	Completion   bool   `desc:"use the completion system to suggest options while typing"`
 	SpellCorrect bool   `desc:"use spell checking to suggest corrections while typing"`
 	EmacsUndo    bool   `desc:"use emacs-style undo, where after a non-undo command, all the current undo actions are added to the undo stack, such that a subsequent undo is actually a redo"`
+	PersistUndo  bool   `desc:"save the undo history when saving the file, alongside the autosave file, and restore it when the file is re-opened with the same contents, so edits made before opening can be undone"`
 	DepthColor   bool   `desc:"colorize the background according to nesting depth"`
+	LSP          bool   `desc:"use a language server for completion, diagnostics, definitions, references and renaming, if one is configured and installed for the file type -- see lsp.Servers"`
+	AutoReload   bool   `desc:"reload the file when it is changed on disk by another program, if there are no unsaved changes -- otherwise the user is asked what to do -- requires something watching the file, e.g., a FileTree -- see FileModExternal"`
 	CommentLn    string `desc:"character(s) that start a single-line comment -- if empty then multi-line comment syntax will be used"`
 	CommentSt    string `desc:"character(s) that start a multi-line comment or one that requires both start and end"`
 	CommentEd    string `desc:"character(s) that end a multi-line comment or one that requires both start and end"`
@@ -111,35 +115,55 @@ func (tb *TextBufOpts) ConfigSupported(sup filecat.Supported) bool {
 // saving buffers to files.  Unlike GUI Widgets, its methods are generally
 // signaling, without an explicit Action suffix.  Internally, the buffer
 // represents new lines using \n = LF, but saving and loading can deal with
-// Windows/DOS CRLF format.
+// Windows/DOS CRLF format, and encodings other than UTF-8 -- see Encoding and
+// LineEnding.
 type TextBuf struct {
 	ki.Node
-	Txt          []byte           `json:"-" xml:"text" desc:"the current value of the entire text being edited -- using []byte slice for greater efficiency"`
-	Autosave     bool             `desc:"if true, auto-save file after changes (in a separate routine)"`
-	Opts         TextBufOpts      `desc:"options for how text editing / viewing works"`
-	Filename     gi.FileName      `json:"-" xml:"-" desc:"filename of file last loaded or saved"`
-	Info         FileInfo         `desc:"full info about file"`
-	PiState      pi.FileState     `desc:"Pi parsing state info for file"`
-	Hi           HiMarkup         `desc:"syntax highlighting markup parameters (language, style, etc)"`
-	NLines       int              `json:"-" xml:"-" desc:"number of lines"`
-	Lines        [][]rune         `json:"-" xml:"-" desc:"the live lines of text being edited, with latest modifications -- encoded as runes per line, which is necessary for one-to-one rune / glyph rendering correspondence -- all TextPos positions etc are in *rune* indexes, not byte indexes!"`
-	LineBytes    [][]byte         `json:"-" xml:"-" desc:"the live lines of text being edited, with latest modifications -- encoded in bytes per line translated from Lines, and used for input to markup -- essential to use Lines and not LineBytes when dealing with TextPos positions, which are in runes"`
-	Tags         []lex.Line       `json:"extra custom tagged regions for each line"`
-	HiTags       []lex.Line       `json:"syntax highlighting tags -- auto-generated"`
-	Markup       [][]byte         `json:"-" xml:"-" desc:"marked-up version of the edit text lines, after being run through the syntax highlighting process etc -- this is what is actually rendered"`
-	ByteOffs     []int            `json:"-" xml:"-" desc:"offsets for start of each line in Txt []byte slice -- this is NOT updated with edits -- call SetByteOffs to set it when needed -- used for re-generating the Txt in LinesToBytes, and set on initial open in BytesToLines"`
-	TotalBytes   int              `json:"-" xml:"-" desc:"total bytes in document -- see ByteOffs for when it is updated"`
-	LinesMu      sync.RWMutex     `json:"-" xml:"-" desc:"mutex for updating lines"`
-	MarkupMu     sync.RWMutex     `json:"-" xml:"-" desc:"mutex for updating markup"`
-	TextBufSig   ki.Signal        `json:"-" xml:"-" view:"-" desc:"signal for buffer -- see TextBufSignals for the types"`
-	Views        []*TextView      `json:"-" xml:"-" desc:"the TextViews that are currently viewing this buffer"`
-	Undos        []*TextBufEdit   `json:"-" xml:"-" desc:"undo stack of edits"`
-	UndoUndos    []*TextBufEdit   `json:"-" xml:"-" desc:"undo stack of *undo* edits -- added to "`
-	UndoPos      int              `json:"-" xml:"-" desc:"undo position"`
-	PosHistory   []TextPos        `json:"-" xml:"-" desc:"history of cursor positions -- can move back through them"`
-	Complete     *gi.Complete     `json:"-" xml:"-" desc:"functions and data for text completion"`
-	SpellCorrect *gi.SpellCorrect `json:"-" xml:"-" desc:"functions and data for spelling correction"`
-	CurView      *TextView        `json:"-" xml:"-" desc:"current textview -- e.g., the one that initiated Complete or Correct process -- update cursor position in this view -- is reset to nil after usage always"`
+	Txt           []byte            `json:"-" xml:"text" desc:"the current value of the entire text being edited -- using []byte slice for greater efficiency"`
+	Autosave      bool              `desc:"if true, auto-save file after changes (in a separate routine)"`
+	Opts          TextBufOpts       `desc:"options for how text editing / viewing works"`
+	Filename      gi.FileName       `json:"-" xml:"-" desc:"filename of file last loaded or saved"`
+	Info          FileInfo          `desc:"full info about file"`
+	Encoding      TextEncodings     `desc:"encoding of the file, detected on opening, and used for saving -- the text itself is always UTF-8"`
+	BOM           bool              `desc:"file starts with a byte-order mark, for UTF-8 and UTF-16 encodings"`
+	LineEnding    LineEndings       `desc:"line ending style of the file, detected on opening, and used for saving -- the lines themselves always end with LF"`
+	MixedLineEnds bool              `json:"-" xml:"-" desc:"file had both LF and CRLF line endings when opened -- LineEnding is the most common one"`
+	PiState       pi.FileState      `desc:"Pi parsing state info for file"`
+	Hi            HiMarkup          `desc:"syntax highlighting markup parameters (language, style, etc)"`
+	NLines        int               `json:"-" xml:"-" desc:"number of lines"`
+	Lines         [][]rune          `json:"-" xml:"-" desc:"the live lines of text being edited, with latest modifications -- encoded as runes per line, which is necessary for one-to-one rune / glyph rendering correspondence -- all TextPos positions etc are in *rune* indexes, not byte indexes!"`
+	LineBytes     [][]byte          `json:"-" xml:"-" desc:"the live lines of text being edited, with latest modifications -- encoded in bytes per line translated from Lines, and used for input to markup -- essential to use Lines and not LineBytes when dealing with TextPos positions, which are in runes"`
+	Tags          []lex.Line        `json:"extra custom tagged regions for each line"`
+	HiTags        []lex.Line        `json:"syntax highlighting tags -- auto-generated"`
+	Markup        [][]byte          `json:"-" xml:"-" desc:"marked-up version of the edit text lines, after being run through the syntax highlighting process etc -- this is what is actually rendered"`
+	ByteOffs      []int             `json:"-" xml:"-" desc:"offsets for start of each line in Txt []byte slice -- this is NOT updated with edits -- call SetByteOffs to set it when needed -- used for re-generating the Txt in LinesToBytes, and set on initial open in BytesToLines"`
+	TotalBytes    int               `json:"-" xml:"-" desc:"total bytes in document -- see ByteOffs for when it is updated"`
+	LinesMu       sync.RWMutex      `json:"-" xml:"-" desc:"mutex for updating lines"`
+	MarkupMu      sync.RWMutex      `json:"-" xml:"-" desc:"mutex for updating markup"`
+	TextBufSig    ki.Signal         `json:"-" xml:"-" view:"-" desc:"signal for buffer -- see TextBufSignals for the types"`
+	Views         []*TextView       `json:"-" xml:"-" desc:"the TextViews that are currently viewing this buffer"`
+	Undos         []*TextBufEdit    `json:"-" xml:"-" desc:"undo stack of edits"`
+	UndoUndos     []*TextBufEdit    `json:"-" xml:"-" desc:"undo stack of *undo* edits -- added to "`
+	UndoPos       int               `json:"-" xml:"-" desc:"undo position"`
+	UndoGp        int               `json:"-" xml:"-" desc:"current undo group counter -- incremented for each new undo step"`
+	UndoGpDepth   int               `json:"-" xml:"-" desc:"depth of nested UndoGroupStart calls -- while > 0, all edits are saved in the same undo group"`
+	UndoBranches  []*TextUndoBranch `json:"-" xml:"-" desc:"alternative undo histories, saved when new edits are made after undoing -- see UndoBranchSwitch"`
+	FileHash      string            `json:"-" xml:"-" desc:"hash of the file contents when last opened or saved, if Opts.PersistUndo -- keys the persistent undo history"`
+	PosHistory    []TextPos         `json:"-" xml:"-" desc:"history of cursor positions -- can move back through them"`
+	Complete      *gi.Complete      `json:"-" xml:"-" desc:"functions and data for text completion"`
+	SpellCorrect  *gi.SpellCorrect  `json:"-" xml:"-" desc:"functions and data for spelling correction"`
+	CurView       *TextView         `json:"-" xml:"-" desc:"current textview -- e.g., the one that initiated Complete or Correct process -- update cursor position in this view -- is reset to nil after usage always"`
+	LSP           *lsp.Client       `json:"-" xml:"-" desc:"language server client this buffer is open in, if Opts.LSP and a server is available -- see LSPStart"`
+	LSPURI        string            `json:"-" xml:"-" desc:"URI of the file in the language server"`
+	Diags         []TextDiag        `json:"-" xml:"-" desc:"diagnostics (errors, warnings etc) for regions of the text, from language servers, build output etc -- sorted by position and updated through edits -- use AddDiag, SetDiags"`
+	DiagsMu       sync.Mutex        `json:"-" xml:"-" desc:"mutex for updating Diags, which can arrive asynchronously"`
+	Large         *PieceTable       `json:"-" xml:"-" desc:"in large-file mode, the piece table holding the text, with only the lines in use loaded into Lines -- see IsLarge, TextBufLargeFileSize"`
+	LargeSt       int               `json:"-" xml:"-" desc:"in large-file mode, the first of the lines loaded into Lines, LineBytes, Markup, Tags, HiTags and ByteOffs, which then hold only the loaded lines, indexed by line - LargeSt -- always 0 otherwise"`
+	VcsBase       *TextBuf          `json:"-" xml:"-" desc:"the version of the file in version control (the last commit), if set with SetVcsBase -- the changes relative to it are marked in the TextView gutter"`
+	VcsDiffs      TextDiffs         `json:"-" xml:"-" desc:"the differences from this buffer to VcsBase -- recomputed in the background after edits, see VcsUpdate"`
+	VcsMu         sync.Mutex        `json:"-" xml:"-" desc:"mutex for VcsBase and VcsDiffs, which are updated in the background"`
+	vcsStale      bool
+	vcsTimer      *time.Timer
 }
 
 var KiT_TextBuf = kit.Types.AddType(&TextBuf{}, TextBufProps)
@@ -183,6 +207,10 @@ const (
 	// with a mutex
 	TextBufMarkUpdt
 
+	// TextBufSaved signals that the buffer has been saved to its file --
+	// data is the filename
+	TextBufSaved
+
 	TextBufSignalsN
 )
 
@@ -241,9 +269,12 @@ func (tb *TextBuf) EditDone() {
 
 // Text returns the current text as a []byte array, applying all current
 // changes -- calls EditDone and will generate that signal if there have been
-// changes
+// changes.  In large-file mode, this is a new copy of the whole text.
 func (tb *TextBuf) Text() []byte {
 	tb.EditDone()
+	if tb.Large != nil {
+		return tb.LinesToBytesCopy()
+	}
 	return tb.Txt
 }
 
@@ -268,32 +299,35 @@ func (tb *TextBuf) IsValidLine(ln int) bool {
 
 // Line is the concurrent-safe accessor to specific Line of Lines runes
 func (tb *TextBuf) Line(ln int) []rune {
+	tb.LargeLoad(ln, ln)
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
 	if ln >= tb.NLines || ln < 0 {
 		return nil
 	}
-	return tb.Lines[ln]
+	return tb.lineLocked(ln)
 }
 
 // LineLen is the concurrent-safe accessor to length of specific Line of Lines runes
 func (tb *TextBuf) LineLen(ln int) int {
+	tb.LargeLoad(ln, ln)
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
 	if ln >= tb.NLines || ln < 0 {
 		return 0
 	}
-	return len(tb.Lines[ln])
+	return len(tb.lineLocked(ln))
 }
 
 // BytesLine is the concurrent-safe accessor to specific Line of LineBytes
 func (tb *TextBuf) BytesLine(ln int) []byte {
+	tb.LargeLoad(ln, ln)
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
-	if ln >= tb.NLines || ln < 0 {
+	if ln >= tb.NLines || ln < 0 || !tb.lineLoaded(ln) {
 		return nil
 	}
-	return tb.LineBytes[ln]
+	return tb.LineBytes[ln-tb.LargeSt]
 }
 
 // SetHiStyle sets the highlighting style -- needs to be protected by mutex
@@ -319,28 +353,30 @@ func (tb *TextBuf) Refresh() {
 	tb.TextBufSig.Emit(tb.This(), int64(TextBufNew), tb.Txt)
 }
 
-// todo: use https://github.com/andybalholm/crlf to deal with cr/lf etc --
-// internally just use lf = \n
-
 // New initializes a new buffer with n blank lines
 func (tb *TextBuf) New(nlines int) {
 	tb.Defaults()
 	nlines = ints.MaxInt(nlines, 1)
 	tb.LinesMu.Lock()
 	tb.MarkupMu.Lock()
-	tb.Lines = make([][]rune, nlines)
-	tb.LineBytes = make([][]byte, nlines)
-	tb.Tags = make([]lex.Line, nlines)
-	tb.HiTags = make([]lex.Line, nlines)
-	tb.Markup = make([][]byte, nlines)
-
-	if cap(tb.ByteOffs) >= nlines {
-		tb.ByteOffs = tb.ByteOffs[:nlines]
+	nld := nlines
+	if tb.Large != nil {
+		nld = 0 // loaded as needed by LargeLoad
+	}
+	tb.LargeSt = 0
+	tb.Lines = make([][]rune, nld)
+	tb.LineBytes = make([][]byte, nld)
+	tb.Tags = make([]lex.Line, nld)
+	tb.HiTags = make([]lex.Line, nld)
+	tb.Markup = make([][]byte, nld)
+
+	if cap(tb.ByteOffs) >= nld {
+		tb.ByteOffs = tb.ByteOffs[:nld]
 	} else {
-		tb.ByteOffs = make([]int, nlines)
+		tb.ByteOffs = make([]int, nld)
 	}
 
-	if nlines == 1 { // this is used for a new blank doc
+	if nlines == 1 && tb.Large == nil { // this is used for a new blank doc
 		tb.ByteOffs[0] = 0 // by definition
 		tb.Lines[0] = []rune("")
 		tb.LineBytes[0] = []byte("")
@@ -414,6 +450,27 @@ func (tb *TextBuf) FileModCheck() bool {
 	return false
 }
 
+// FileModExternal is called when the file may have been changed on disk by
+// another program, e.g., by a FileTree watching the file system: if it was,
+// the buffer is reloaded if Opts.AutoReload and there are no unsaved changes,
+// and otherwise the user is asked what to do, as in FileModCheck.  Returns
+// true if the file was changed.
+func (tb *TextBuf) FileModExternal() bool {
+	if tb.Filename == "" {
+		return false
+	}
+	info, err := os.Stat(string(tb.Filename))
+	if err != nil || info.ModTime() == time.Time(tb.Info.ModTime) {
+		return false
+	}
+	if tb.Opts.AutoReload && !tb.IsChanged() {
+		tb.Revert()
+		return true
+	}
+	tb.ClearFlag(int(TextBufFileModOk)) // ask again, for this new change
+	return tb.FileModCheck()
+}
+
 // Open loads text from a file into the buffer
 func (tb *TextBuf) Open(filename gi.FileName) error {
 	tb.Defaults()
@@ -425,28 +482,62 @@ func (tb *TextBuf) Open(filename gi.FileName) error {
 		return err
 	}
 	tb.SetName(string(filename)) // todo: modify in any way?
+	tb.MixedLineEndsWarn()
+	tb.UndoReset()
+	if tb.Opts.PersistUndo {
+		tb.UndoLoadFile()
+	}
 
 	// markup the first 100 lines
 	mxhi := ints.MinInt(100, tb.NLines-1)
-	tb.MarkupLinesLock(0, mxhi)
+	if tb.Large != nil {
+		tb.LargeLoad(0, mxhi)
+	} else {
+		tb.MarkupLinesLock(0, mxhi)
+	}
 
 	// update views
 	tb.TextBufSig.Emit(tb.This(), int64(TextBufNew), tb.Txt)
 
 	// do slow full update in background
 	tb.ReMarkup()
+	if tb.Opts.LSP && tb.Large == nil {
+		go tb.LSPStart()
+	}
 	return nil
 }
 
 // OpenFile just loads a file into the buffer -- doesn't do any markup or
-// notification -- for temp bufs
+// notification -- for temp bufs.  UTF-8 files of TextBufLargeFileSize or
+// more, without a BOM or any CRLF line endings, are opened in large-file
+// mode -- see OpenLarge -- as their raw bytes are edited in place
 func (tb *TextBuf) OpenFile(filename gi.FileName) error {
+	tb.FileHash = ""
+	if info, err := os.Stat(string(filename)); err == nil && TextBufLargeFileSize > 0 && info.Size() >= TextBufLargeFileSize {
+		if enc, bom, le := sniffFile(string(filename)); enc == EncUTF8 && !bom && le == LineEndLF {
+			if err = tb.OpenLarge(filename); err != nil {
+				return err
+			}
+			if !tb.Large.OrigCRLF {
+				tb.Encoding, tb.BOM, tb.LineEnding, tb.MixedLineEnds = enc, bom, le, false
+				return nil
+			}
+			tb.CloseLarge() // CRLF further in -- needs normal mode
+		}
+	}
 	fp, err := os.Open(string(filename))
 	if err != nil {
 		return err
 	}
-	tb.Txt, err = ioutil.ReadAll(fp)
+	b, err := ioutil.ReadAll(fp)
 	fp.Close()
+	if err != nil {
+		return err
+	}
+	if tb.Opts.PersistUndo {
+		tb.FileHash = FileHash(b)
+	}
+	tb.Txt = tb.DecodeFile(b, EncUTF8, false)
 	tb.Filename = filename
 	tb.Stat()
 	tb.BytesToLines()
@@ -463,9 +554,10 @@ func (tb *TextBuf) Revert() bool {
 	}
 
 	didDiff := false
-	if tb.NLines < TextBufDiffRevertLines {
+	if tb.Large == nil && tb.NLines < TextBufDiffRevertLines {
 		ob := &TextBuf{}
 		ob.InitName(ob, "revert-tmp")
+		ob.Opts.PersistUndo = tb.Opts.PersistUndo // for the FileHash
 		err := ob.OpenFile(tb.Filename)
 		if err != nil {
 			vp := tb.ViewportFromView()
@@ -476,16 +568,20 @@ func (tb *TextBuf) Revert() bool {
 			return false
 		}
 		tb.Stat() // "own" the new file..
-		if ob.NLines < TextBufDiffRevertLines {
+		if ob.Large == nil && ob.NLines < TextBufDiffRevertLines {
 			diffs := tb.DiffBufs(ob)
 			if len(diffs) < TextBufDiffRevertDiffs {
 				tb.PatchFromBuf(ob, diffs, true) // true = send sigs for each update -- better than full, assuming changes are minor
+				tb.Encoding, tb.BOM = ob.Encoding, ob.BOM
+				tb.LineEnding, tb.MixedLineEnds = ob.LineEnding, ob.MixedLineEnds
+				tb.FileHash = ob.FileHash
 				didDiff = true
 			}
 		}
 	}
 	if !didDiff {
 		tb.OpenFile(tb.Filename)
+		tb.LSPResync()
 	}
 	tb.ClearChanged()
 	tb.AutoSaveDelete()
@@ -532,7 +628,22 @@ func (tb *TextBuf) SaveAs(filename gi.FileName) {
 
 // SaveFile writes current buffer to file, with no prompting, etc
 func (tb *TextBuf) SaveFile(filename gi.FileName) error {
-	err := ioutil.WriteFile(string(filename), tb.Txt, 0644)
+	var err error
+	if tb.Large != nil {
+		err = tb.LargeWriteFile(filename)
+	} else {
+		var b []byte
+		b, err = tb.EncodeFile(tb.Txt)
+		if err != nil {
+			gi.PromptDialog(nil, gi.DlgOpts{Title: "Text could not be Encoded", Prompt: err.Error() + " -- file was not saved: use the Encoding context menu item to choose another encoding, e.g., UTF-8"}, true, false, nil, nil)
+			log.Println(err)
+			return err
+		}
+		err = ioutil.WriteFile(string(filename), b, 0644)
+		if err == nil && tb.Opts.PersistUndo {
+			tb.FileHash = FileHash(b)
+		}
+	}
 	if err != nil {
 		gi.PromptDialog(nil, gi.DlgOpts{Title: "Could not Save to File", Prompt: err.Error()}, true, false, nil, nil)
 		log.Println(err)
@@ -540,6 +651,13 @@ func (tb *TextBuf) SaveFile(filename gi.FileName) error {
 		tb.Filename = filename
 		tb.SetName(string(filename)) // todo: modify in any way?
 		tb.Stat()
+		tb.LSPDidSave()
+		tb.TextBufSig.Emit(tb.This(), int64(TextBufSaved), filename)
+		if tb.Opts.PersistUndo {
+			if uerr := tb.UndoSaveFile(); uerr != nil {
+				log.Printf("giv.TextBuf: Could not save undo history for file: %v, error: %v\n", filename, uerr)
+			}
+		}
 	}
 	return err
 }
@@ -617,8 +735,11 @@ func (tb *TextBuf) Close(afterFun func(canceled bool)) bool {
 	for _, tve := range tb.Views {
 		tve.SetBuf(nil) // automatically disconnects signals, views
 	}
+	tb.LSPStop()
+	tb.CloseLarge()
 	tb.New(1)
 	tb.Filename = ""
+	tb.Encoding, tb.BOM, tb.LineEnding, tb.MixedLineEnds = EncUTF8, false, LineEndLF, false
 	tb.ClearChanged()
 	if afterFun != nil {
 		afterFun(false)
@@ -660,8 +781,13 @@ func (tb *TextBuf) AutoSave() error {
 	}
 	tb.SetFlag(int(TextBufAutoSaving))
 	asfn := tb.AutoSaveFilename()
-	b := tb.LinesToBytesCopy()
-	err := ioutil.WriteFile(asfn, b, 0644)
+	var err error
+	if tb.Large != nil {
+		err = tb.LargeWriteFile(gi.FileName(asfn))
+	} else {
+		b := tb.LinesToBytesCopy()
+		err = ioutil.WriteFile(asfn, b, 0644)
+	}
 	if err != nil {
 		log.Printf("giv.TextBuf: Could not AutoSave file: %v, error: %v\n", asfn, err)
 	}
@@ -690,14 +816,11 @@ func (tb *TextBuf) AutoSaveCheck() bool {
 
 // EndPos returns the ending position at end of buffer
 func (tb *TextBuf) EndPos() TextPos {
-	tb.LinesMu.RLock()
-	defer tb.LinesMu.RUnlock()
-
-	if tb.NLines == 0 {
+	nln := tb.NumLines()
+	if nln == 0 {
 		return TextPosZero
 	}
-	ed := TextPos{tb.NLines - 1, len(tb.Lines[tb.NLines-1])}
-	return ed
+	return TextPos{nln - 1, tb.LineLen(nln - 1)}
 }
 
 // AppendText appends new text to end of buffer, using insert, returns edit
@@ -752,7 +875,7 @@ func (tb *TextBuf) AppendTextMarkup(text []byte, markup []byte, saveUndo, signal
 		el = ints.MinInt(st+len(msplt)-1, el)
 	}
 	for ln := st; ln <= el; ln++ {
-		tb.Markup[ln] = msplt[ln-st]
+		tb.Markup[ln-tb.LargeSt] = msplt[ln-st]
 	}
 	if signal {
 		tb.TextBufSig.Emit(tb.This(), int64(TextBufInsert), tbe)
@@ -782,7 +905,7 @@ func (tb *TextBuf) AppendTextLineMarkup(text []byte, markup []byte, saveUndo, si
 		efft = tcpy
 	}
 	tbe := tb.InsertText(ed, efft, saveUndo, false)
-	tb.Markup[tbe.Reg.Start.Ln] = markup
+	tb.Markup[tbe.Reg.Start.Ln-tb.LargeSt] = markup
 	if signal {
 		tb.TextBufSig.Emit(tb.This(), int64(TextBufInsert), tbe)
 	}
@@ -836,6 +959,23 @@ func (tb *TextBuf) RefreshViews() {
 	}
 }
 
+// runOnEventLoop runs given function on the event loop of the window of the
+// first view of the buffer, where it is safe to update the buffer and its
+// views -- for updates from other goroutines.  It is run directly if the
+// buffer is not viewed in a window.
+func (tb *TextBuf) runOnEventLoop(fun func()) {
+	for _, tv := range tb.Views {
+		if tv == nil || tv.This() == nil || tv.Viewport == nil {
+			continue
+		}
+		if win := tv.Viewport.Win; win != nil && !win.IsClosed() {
+			win.RunOnEventLoop(fun)
+			return
+		}
+	}
+	fun()
+}
+
 // BatchUpdateStart call this when starting a batch of updates to the buffer --
 // it blocks the window updates for views until all the updates are done,
 // and calls AutoSaveOff.  Calls UpdateStart on Buf too.
@@ -883,9 +1023,10 @@ func (tb *TextBuf) SetByteOffs() {
 	tb.TotalBytes = bo
 }
 
-// LinesToBytes converts current Lines back to the Txt slice of bytes.
+// LinesToBytes converts current Lines back to the Txt slice of bytes.  In
+// large-file mode, Txt is not used, and is left empty.
 func (tb *TextBuf) LinesToBytes() {
-	if tb.NLines == 0 {
+	if tb.NLines == 0 || tb.Large != nil {
 		if tb.Txt != nil {
 			tb.Txt = tb.Txt[:0]
 		}
@@ -918,6 +1059,9 @@ func (tb *TextBuf) LinesToBytes() {
 func (tb *TextBuf) LinesToBytesCopy() []byte {
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
+	if tb.Large != nil {
+		return tb.Large.Bytes()
+	}
 
 	txt := bytes.Join(tb.LineBytes, []byte("\n"))
 	txt = append(txt, '\n')
@@ -927,6 +1071,7 @@ func (tb *TextBuf) LinesToBytesCopy() []byte {
 // BytesToLines converts current Txt bytes into lines, and initializes markup
 // with raw text
 func (tb *TextBuf) BytesToLines() {
+	tb.CloseLarge()
 	if len(tb.Txt) == 0 {
 		tb.New(1)
 		return
@@ -999,36 +1144,20 @@ func NewFileSearchMatch(rn []rune, st, ed, ln int) FileSearchMatch {
 // returning number of occurrences and specific match position list.
 // column positions are in runes
 func (tb *TextBuf) Search(find []byte, ignoreCase bool) (int, []FileSearchMatch) {
+	if tb.Large != nil {
+		return tb.LargeSearch(find, ignoreCase)
+	}
 	fr := bytes.Runes(find)
-	fsz := len(fr)
-	if fsz == 0 {
+	if len(fr) == 0 {
 		return 0, nil
 	}
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
-	cnt := 0
 	var matches []FileSearchMatch
 	for ln, rn := range tb.Lines {
-		sz := len(rn)
-		ci := 0
-		for ci < sz {
-			var i int
-			if ignoreCase {
-				i = runes.IndexFold(rn[ci:], fr)
-			} else {
-				i = runes.Index(rn[ci:], fr)
-			}
-			if i < 0 {
-				break
-			}
-			i += ci
-			ci = i + fsz
-			mat := NewFileSearchMatch(rn, i, ci, ln)
-			matches = append(matches, mat)
-			cnt++
-		}
+		matches = searchLine(matches, rn, fr, ln, ignoreCase)
 	}
-	return cnt, matches
+	return len(matches), matches
 }
 
 /////////////////////////////////////////////////////////////////////////////
@@ -1165,6 +1294,7 @@ type TextBufEdit struct {
 	Reg    TextRegion `desc:"region for the edit (start is same for previous and current, end is in original pre-delete text for a delete, and in new lines data for an insert.  Also contains the Time stamp for this edit."`
 	Delete bool       `desc:"action is either a deletion or an insertion"`
 	Text   [][]rune   `desc:"text to be inserted"`
+	Group  int        `desc:"undo group that this edit belongs to -- consecutive edits with the same (non-zero) group are undone and redone together as a single step"`
 }
 
 // ToBytes returns the Text of this edit record to a byte string, with
@@ -1318,6 +1448,7 @@ func PunctGpMatch(r rune) (match rune, right bool) {
 
 // FindScopeMatch finds the brace or parenthesis that is the partner of the one passed to function
 func (tb *TextBuf) FindScopeMatch(r rune, st TextPos) (en TextPos, found bool) {
+	tb.LargeLoad(st.Ln-MaxScopeLines, st.Ln+MaxScopeLines)
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
 
@@ -1396,6 +1527,7 @@ func (tb *TextBuf) FindScopeMatch(r rune, st TextPos) (en TextPos, found bool) {
 
 // ValidPos returns a position that is in a valid range
 func (tb *TextBuf) ValidPos(pos TextPos) TextPos {
+	tb.LargeLoad(pos.Ln, pos.Ln)
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
 
@@ -1405,8 +1537,8 @@ func (tb *TextBuf) ValidPos(pos TextPos) TextPos {
 	if pos.Ln < 0 {
 		pos.Ln = 0
 	}
-	pos.Ln = ints.MinInt(pos.Ln, len(tb.Lines)-1)
-	llen := len(tb.Lines[pos.Ln])
+	pos.Ln = ints.MinInt(pos.Ln, tb.NLines-1)
+	llen := len(tb.lineLocked(pos.Ln))
 	pos.Ch = ints.MinInt(pos.Ch, llen)
 	if pos.Ch < 0 {
 		pos.Ch = 0
@@ -1431,9 +1563,13 @@ func (tb *TextBuf) DeleteText(st, ed TextPos, saveUndo, signal bool) *TextBufEdi
 	tbe := tb.Region(st, ed)
 	tb.SetChanged()
 	tb.LinesMu.Lock()
+	if tb.Large != nil {
+		tb.Large.Delete(tb.LargeOff(st), int64(len(tbe.ToBytes())))
+	}
 	tbe.Delete = true
+	sl, el := st.Ln-tb.LargeSt, ed.Ln-tb.LargeSt // indexes of loaded lines
 	if ed.Ln == st.Ln {
-		tb.Lines[st.Ln] = append(tb.Lines[st.Ln][:st.Ch], tb.Lines[st.Ln][ed.Ch:]...)
+		tb.Lines[sl] = append(tb.Lines[sl][:st.Ch], tb.Lines[sl][ed.Ch:]...)
 		tb.LinesMu.Unlock()
 		if saveUndo {
 			tb.SaveUndo(tbe)
@@ -1441,26 +1577,29 @@ func (tb *TextBuf) DeleteText(st, ed TextPos, saveUndo, signal bool) *TextBufEdi
 		tb.LinesEdited(tbe)
 	} else {
 		// first get chars on start and end
-		stln := st.Ln + 1
-		cpln := st.Ln
-		tb.Lines[st.Ln] = tb.Lines[st.Ln][:st.Ch]
-		eoedl := len(tb.Lines[ed.Ln][ed.Ch:])
+		stln := sl + 1
+		cpln := sl
+		tb.Lines[sl] = tb.Lines[sl][:st.Ch]
+		eoedl := len(tb.Lines[el][ed.Ch:])
 		var eoed []rune
 		if eoedl > 0 { // save it
 			eoed = make([]rune, eoedl)
-			copy(eoed, tb.Lines[ed.Ln][ed.Ch:])
+			copy(eoed, tb.Lines[el][ed.Ch:])
 		}
-		tb.Lines = append(tb.Lines[:stln], tb.Lines[ed.Ln+1:]...)
+		tb.Lines = append(tb.Lines[:stln], tb.Lines[el+1:]...)
 		if eoed != nil {
 			tb.Lines[cpln] = append(tb.Lines[cpln], eoed...)
 		}
-		tb.NLines = len(tb.Lines)
+		tb.NLines -= ed.Ln - st.Ln
 		tb.LinesMu.Unlock()
 		if saveUndo {
 			tb.SaveUndo(tbe)
 		}
 		tb.LinesDeleted(tbe)
 	}
+	tb.DiagsAdjust(tbe)
+	tb.VcsAdjust(tbe)
+	tb.LSPDidChange(tbe)
 
 	if signal {
 		tb.TextBufSig.Emit(tb.This(), int64(TextBufDelete), tbe)
@@ -1477,24 +1616,28 @@ func (tb *TextBuf) InsertText(st TextPos, text []byte, saveUndo, signal bool) *T
 	if len(text) == 0 {
 		return nil
 	}
-	if len(tb.Lines) == 0 {
+	if tb.NumLines() == 0 {
 		tb.New(1)
 	}
 	st = tb.ValidPos(st)
 	tb.FileModCheck()
 	tb.LinesMu.Lock()
 	tb.SetChanged()
+	if tb.Large != nil {
+		tb.Large.Insert(tb.LargeOff(st), text)
+	}
 	lns := bytes.Split(text, []byte("\n"))
 	sz := len(lns)
 	rs := bytes.Runes(lns[0])
 	rsz := len(rs)
 	ed := st
+	sl := st.Ln - tb.LargeSt // index of loaded line
 	var tbe *TextBufEdit
 	if sz == 1 {
-		nt := append(tb.Lines[st.Ln], rs...) // first append to end to extend capacity
-		copy(nt[st.Ch+rsz:], nt[st.Ch:])     // move stuff to end
-		copy(nt[st.Ch:], rs)                 // copy into position
-		tb.Lines[st.Ln] = nt
+		nt := append(tb.Lines[sl], rs...) // first append to end to extend capacity
+		copy(nt[st.Ch+rsz:], nt[st.Ch:])  // move stuff to end
+		copy(nt[st.Ch:], rs)              // copy into position
+		tb.Lines[sl] = nt
 		ed.Ch += rsz
 		tb.LinesMu.Unlock()
 		tbe = tb.Region(st, ed)
@@ -1503,31 +1646,32 @@ func (tb *TextBuf) InsertText(st TextPos, text []byte, saveUndo, signal bool) *T
 		}
 		tb.LinesEdited(tbe)
 	} else {
-		if tb.Lines[st.Ln] == nil {
-			tb.Lines[st.Ln] = []rune("")
+		if tb.Lines[sl] == nil {
+			tb.Lines[sl] = []rune("")
 		}
-		eostl := len(tb.Lines[st.Ln][st.Ch:]) // end of starting line
+		eostl := len(tb.Lines[sl][st.Ch:]) // end of starting line
 		var eost []rune
 		if eostl > 0 { // save it
 			eost = make([]rune, eostl)
-			copy(eost, tb.Lines[st.Ln][st.Ch:])
+			copy(eost, tb.Lines[sl][st.Ch:])
 		}
-		tb.Lines[st.Ln] = append(tb.Lines[st.Ln][:st.Ch], rs...)
+		tb.Lines[sl] = append(tb.Lines[sl][:st.Ch], rs...)
 		nsz := sz - 1
 		tmp := make([][]rune, nsz)
 		for i := 1; i < sz; i++ {
 			tmp[i-1] = bytes.Runes(lns[i])
 		}
-		stln := st.Ln + 1
+		stln := sl + 1
 		nt := append(tb.Lines, tmp...) // first append to end to extend capacity
 		copy(nt[stln+nsz:], nt[stln:]) // move stuff to end
 		copy(nt[stln:], tmp)           // copy into position
 		tb.Lines = nt
-		tb.NLines = len(tb.Lines)
+		tb.NLines += nsz
 		ed.Ln += nsz
-		ed.Ch = len(tb.Lines[ed.Ln])
+		el := sl + nsz
+		ed.Ch = len(tb.Lines[el])
 		if eost != nil {
-			tb.Lines[ed.Ln] = append(tb.Lines[ed.Ln], eost...)
+			tb.Lines[el] = append(tb.Lines[el], eost...)
 		}
 		tb.LinesMu.Unlock()
 		tbe = tb.Region(st, ed)
@@ -1536,6 +1680,9 @@ func (tb *TextBuf) InsertText(st TextPos, text []byte, saveUndo, signal bool) *T
 		}
 		tb.LinesInserted(tbe)
 	}
+	tb.DiagsAdjust(tbe)
+	tb.VcsAdjust(tbe)
+	tb.LSPDidChange(tbe)
 	if signal {
 		tb.TextBufSig.Emit(tb.This(), int64(TextBufInsert), tbe)
 	}
@@ -1558,38 +1705,39 @@ func (tb *TextBuf) Region(st, ed TextPos) *TextBufEdit {
 		return nil
 	}
 	tbe := &TextBufEdit{Reg: NewTextRegionPos(st, ed)}
+	tb.LargeLoad(st.Ln, ed.Ln)
 	tb.LinesMu.RLock()
 	defer tb.LinesMu.RUnlock()
 	if ed.Ln == st.Ln {
 		sz := ed.Ch - st.Ch
 		tbe.Text = make([][]rune, 1)
 		tbe.Text[0] = make([]rune, sz)
-		copy(tbe.Text[0][:sz], tb.Lines[st.Ln][st.Ch:ed.Ch])
+		copy(tbe.Text[0][:sz], tb.lineLocked(st.Ln)[st.Ch:ed.Ch])
 	} else {
 		// first get chars on start and end
 		nlns := (ed.Ln - st.Ln) + 1
 		tbe.Text = make([][]rune, nlns)
 		stln := st.Ln
 		if st.Ch > 0 {
-			ec := len(tb.Lines[st.Ln])
+			ec := len(tb.lineLocked(st.Ln))
 			sz := ec - st.Ch
 			if sz > 0 {
 				tbe.Text[0] = make([]rune, sz)
-				copy(tbe.Text[0][0:sz], tb.Lines[st.Ln][st.Ch:])
+				copy(tbe.Text[0][0:sz], tb.lineLocked(st.Ln)[st.Ch:])
 			}
 			stln++
 		}
 		edln := ed.Ln
-		if ed.Ch < len(tb.Lines[ed.Ln]) {
+		if ed.Ch < len(tb.lineLocked(ed.Ln)) {
 			tbe.Text[ed.Ln-st.Ln] = make([]rune, ed.Ch)
-			copy(tbe.Text[ed.Ln-st.Ln], tb.Lines[ed.Ln][:ed.Ch])
+			copy(tbe.Text[ed.Ln-st.Ln], tb.lineLocked(ed.Ln)[:ed.Ch])
 			edln--
 		}
 		for ln := stln; ln <= edln; ln++ {
 			ti := ln - st.Ln
-			sz := len(tb.Lines[ln])
+			sz := len(tb.lineLocked(ln))
 			tbe.Text[ti] = make([]rune, sz)
-			copy(tbe.Text[ti], tb.Lines[ln])
+			copy(tbe.Text[ti], tb.lineLocked(ln))
 		}
 	}
 	return tbe
@@ -1618,11 +1766,11 @@ func (tb *TextBuf) SavePosHistory(pos TextPos) bool {
 // LinesInserted inserts new lines in Markup corresponding to lines
 // inserted in Lines text.  Locks and unlocks the Markup mutex
 func (tb *TextBuf) LinesInserted(tbe *TextBufEdit) {
-	stln := tbe.Reg.Start.Ln + 1
 	nsz := (tbe.Reg.End.Ln - tbe.Reg.Start.Ln)
 
 	tb.LinesMu.Lock()
 	tb.MarkupMu.Lock()
+	stln := tbe.Reg.Start.Ln + 1 - tb.LargeSt
 
 	// LineBytes
 	tmplb := make([][]byte, nsz)
@@ -1659,15 +1807,17 @@ func (tb *TextBuf) LinesInserted(tbe *TextBufEdit) {
 	copy(nof[stln:], tmpof)
 	tb.ByteOffs = nof
 
-	tb.PiState.Src.LinesInserted(stln, nsz)
+	if tb.Large == nil {
+		tb.PiState.Src.LinesInserted(stln, nsz)
+	}
 
 	st, ed := tbe.Reg.Start.Ln, tbe.Reg.End.Ln
-	bo := tb.ByteOffs[st]
-	for ln := st; ln <= ed; ln++ {
-		tb.LineBytes[ln] = []byte(string(tb.Lines[ln]))
-		tb.Markup[ln] = HTMLEscapeBytes(tb.LineBytes[ln])
-		tb.ByteOffs[ln] = bo
-		bo += len(tb.LineBytes[ln]) + 1
+	bo := tb.ByteOffs[st-tb.LargeSt]
+	for li := st - tb.LargeSt; li <= ed-tb.LargeSt; li++ {
+		tb.LineBytes[li] = []byte(string(tb.Lines[li]))
+		tb.Markup[li] = HTMLEscapeBytes(tb.LineBytes[li])
+		tb.ByteOffs[li] = bo
+		bo += len(tb.LineBytes[li]) + 1
 	}
 	tb.MarkupLines(st, ed)
 	tb.MarkupMu.Unlock()
@@ -1680,8 +1830,8 @@ func (tb *TextBuf) LinesDeleted(tbe *TextBufEdit) {
 	tb.LinesMu.Lock()
 	tb.MarkupMu.Lock()
 
-	stln := tbe.Reg.Start.Ln
-	edln := tbe.Reg.End.Ln
+	stln := tbe.Reg.Start.Ln - tb.LargeSt
+	edln := tbe.Reg.End.Ln - tb.LargeSt
 
 	tb.LineBytes = append(tb.LineBytes[:stln], tb.LineBytes[edln:]...)
 	tb.Markup = append(tb.Markup[:stln], tb.Markup[edln:]...)
@@ -1689,12 +1839,13 @@ func (tb *TextBuf) LinesDeleted(tbe *TextBufEdit) {
 	tb.HiTags = append(tb.HiTags[:stln], tb.HiTags[edln:]...)
 	tb.ByteOffs = append(tb.ByteOffs[:stln], tb.ByteOffs[edln:]...)
 
-	tb.PiState.Src.LinesDeleted(stln, edln)
+	if tb.Large == nil {
+		tb.PiState.Src.LinesDeleted(stln, edln)
+	}
 
-	st := tbe.Reg.Start.Ln
-	tb.LineBytes[st] = []byte(string(tb.Lines[st]))
-	tb.Markup[st] = HTMLEscapeBytes(tb.LineBytes[st])
-	tb.MarkupLines(st, st)
+	tb.LineBytes[stln] = []byte(string(tb.Lines[stln]))
+	tb.Markup[stln] = HTMLEscapeBytes(tb.LineBytes[stln])
+	tb.MarkupLines(tbe.Reg.Start.Ln, tbe.Reg.Start.Ln)
 	tb.MarkupMu.Unlock()
 	tb.LinesMu.Unlock()
 	// probably don't need to do global markup here..
@@ -1707,9 +1858,9 @@ func (tb *TextBuf) LinesEdited(tbe *TextBufEdit) {
 	tb.MarkupMu.Lock()
 
 	st, ed := tbe.Reg.Start.Ln, tbe.Reg.End.Ln
-	for ln := st; ln <= ed; ln++ {
-		tb.LineBytes[ln] = []byte(string(tb.Lines[ln]))
-		tb.Markup[ln] = HTMLEscapeBytes(tb.LineBytes[ln])
+	for li := st - tb.LargeSt; li <= ed-tb.LargeSt; li++ {
+		tb.LineBytes[li] = []byte(string(tb.Lines[li]))
+		tb.Markup[li] = HTMLEscapeBytes(tb.LineBytes[li])
 	}
 	tb.MarkupLines(st, ed)
 	tb.MarkupMu.Unlock()
@@ -1724,7 +1875,7 @@ func (tb *TextBuf) IsMarkingUp() bool {
 
 // ReMarkup runs re-markup on text in background
 func (tb *TextBuf) ReMarkup() {
-	if !tb.Hi.HasHi() || tb.NLines == 0 {
+	if !tb.Hi.HasHi() || tb.NLines == 0 || tb.Large != nil {
 		return
 	}
 	if tb.IsMarkingUp() {
@@ -1735,12 +1886,16 @@ func (tb *TextBuf) ReMarkup() {
 
 // AdjustedTags updates tag positions for edits
 func (tb *TextBuf) AdjustedTags(ln int) lex.Line {
-	sz := len(tb.Tags[ln])
+	if !tb.lineLoaded(ln) {
+		return nil
+	}
+	tags := tb.Tags[ln-tb.LargeSt]
+	sz := len(tags)
 	if sz == 0 {
-		return tb.Tags[ln]
+		return tags
 	}
 	ntags := make(lex.Line, 0, sz)
-	for _, tg := range tb.Tags[ln] {
+	for _, tg := range tags {
 		reg := TextRegion{Start: TextPos{Ln: ln, Ch: tg.St}, End: TextPos{Ln: ln, Ch: tg.Ed}}
 		reg.Time = tg.Time
 		reg = tb.AdjustReg(reg)
@@ -1757,7 +1912,7 @@ func (tb *TextBuf) AdjustedTags(ln int) lex.Line {
 // calling MarkupMu mutex when setting the marked-up lines with the result --
 // designed to be called in a separate goroutine
 func (tb *TextBuf) MarkupAllLines() {
-	if !tb.Hi.HasHi() || tb.NLines == 0 {
+	if !tb.Hi.HasHi() || tb.NLines == 0 || tb.Large != nil {
 		return
 	}
 	if tb.IsMarkingUp() {
@@ -1822,13 +1977,17 @@ func (tb *TextBuf) MarkupLines(st, ed int) bool {
 	}
 	allgood := true
 	for ln := st; ln <= ed; ln++ {
-		ltxt := tb.LineBytes[ln]
+		if !tb.lineLoaded(ln) {
+			continue
+		}
+		li := ln - tb.LargeSt
+		ltxt := tb.LineBytes[li]
 		mt, err := tb.Hi.MarkupTagsLine(ln, ltxt)
 		if err == nil {
-			tb.HiTags[ln] = mt
-			tb.Markup[ln] = tb.Hi.MarkupLine(ltxt, mt, tb.AdjustedTags(ln))
+			tb.HiTags[li] = mt
+			tb.Markup[li] = tb.Hi.MarkupLine(ltxt, mt, tb.AdjustedTags(ln))
 		} else {
-			tb.Markup[ln] = HTMLEscapeBytes(ltxt)
+			tb.Markup[li] = HTMLEscapeBytes(ltxt)
 			allgood = false
 		}
 	}
@@ -1845,18 +2004,50 @@ func (tb *TextBuf) MarkupLinesLock(st, ed int) bool {
 /////////////////////////////////////////////////////////////////////////////
 //   Undo
 
-// SaveUndo saves given edit to undo stack
+// SaveUndo saves given edit to undo stack -- the edit is assigned to the
+// currently open undo group if UndoGroupStart has been called, and otherwise
+// to a new group of its own.  If there are undone edits, they are saved as
+// an alternative history in UndoBranches.
 func (tb *TextBuf) SaveUndo(tbe *TextBufEdit) {
 	if tb.UndoPos < len(tb.Undos) {
+		tb.UndoBranchSave()
 		// fmt.Printf("undo resetting to pos: %v len was: %v\n", tb.UndoPos, len(tb.Undos))
 		tb.Undos = tb.Undos[:tb.UndoPos]
 	}
+	if tb.UndoGpDepth == 0 {
+		tb.UndoGp++
+	}
+	tbe.Group = tb.UndoGp
 	// fmt.Printf("save undo pos: %v: %v\n", tb.UndoPos, string(tbe.ToBytes()))
 	tb.Undos = append(tb.Undos, tbe)
 	tb.UndoPos = len(tb.Undos)
 }
 
-// Undo undoes next item on the undo stack, and returns that record -- nil if no more
+// UndoGroupStart starts a group of edits that are undone and redone together
+// as a single step -- must be matched by a call to UndoGroupEnd.  Calls can
+// be nested, in which case the outermost group defines the step.
+func (tb *TextBuf) UndoGroupStart() {
+	if tb.UndoGpDepth == 0 {
+		tb.UndoGp++
+	}
+	tb.UndoGpDepth++
+}
+
+// UndoGroupEnd ends a group of edits started by UndoGroupStart
+func (tb *TextBuf) UndoGroupEnd() {
+	if tb.UndoGpDepth > 0 {
+		tb.UndoGpDepth--
+	}
+}
+
+// InUndoGroup returns true if an undo group is currently open
+func (tb *TextBuf) InUndoGroup() bool {
+	return tb.UndoGpDepth > 0
+}
+
+// Undo undoes next step on the undo stack, and returns the first record of
+// that step -- nil if no more.  All consecutive edits in the same undo group
+// are undone together.
 func (tb *TextBuf) Undo() *TextBufEdit {
 	if tb.UndoPos == 0 {
 		tb.ClearChanged()
@@ -1868,20 +2059,43 @@ func (tb *TextBuf) Undo() *TextBufEdit {
 	if tbe == nil {
 		return nil
 	}
+	gp := tbe.Group
+	ugp := 0
+	if tb.Opts.EmacsUndo {
+		tb.UndoGp++
+		ugp = tb.UndoGp
+	}
+	for {
+		tb.UndoEdit(tbe, ugp)
+		if gp == 0 || tb.UndoPos == 0 {
+			break
+		}
+		ptbe := tb.Undos[tb.UndoPos-1]
+		if ptbe == nil || ptbe.Group != gp {
+			break
+		}
+		tb.UndoPos--
+		tbe = ptbe
+	}
+	return tbe
+}
+
+// UndoEdit applies the inverse of given edit record, without saving to the
+// undo stack -- for EmacsUndo, the inverse edit is saved to UndoUndos with
+// given group
+func (tb *TextBuf) UndoEdit(tbe *TextBufEdit, ugp int) {
+	var utbe *TextBufEdit
 	if tbe.Delete {
 		// fmt.Printf("undo pos: %v undoing delete at: %v text: %v\n", tb.UndoPos, tbe.Reg, string(tbe.ToBytes()))
-		tbe := tb.InsertText(tbe.Reg.Start, tbe.ToBytes(), false, true) // don't save to reg und
-		if tb.Opts.EmacsUndo {
-			tb.UndoUndos = append(tb.UndoUndos, tbe)
-		}
+		utbe = tb.InsertText(tbe.Reg.Start, tbe.ToBytes(), false, true) // don't save to reg und
 	} else {
 		// fmt.Printf("undo pos: %v undoing insert at: %v text: %v\n", tb.UndoPos, tbe.Reg, string(tbe.ToBytes()))
-		tbe := tb.DeleteText(tbe.Reg.Start, tbe.Reg.End, false, true)
-		if tb.Opts.EmacsUndo {
-			tb.UndoUndos = append(tb.UndoUndos, tbe)
-		}
+		utbe = tb.DeleteText(tbe.Reg.Start, tbe.Reg.End, false, true)
+	}
+	if tb.Opts.EmacsUndo && utbe != nil {
+		utbe.Group = ugp
+		tb.UndoUndos = append(tb.UndoUndos, utbe)
 	}
-	return tbe
 }
 
 // EmacsUndoSave if EmacsUndo mode is active, saves the UndoUndos to the regular Undo stack
@@ -1898,18 +2112,31 @@ func (tb *TextBuf) EmacsUndoSave() {
 	tb.UndoUndos = nil
 }
 
-// Redo redoes next item on the undo stack, and returns that record, nil if no more
+// Redo redoes next step on the undo stack, and returns the last record of
+// that step, nil if no more.  All consecutive edits in the same undo group
+// are redone together.
 func (tb *TextBuf) Redo() *TextBufEdit {
 	if tb.UndoPos >= len(tb.Undos) {
 		return nil
 	}
 	tbe := tb.Undos[tb.UndoPos]
-	if tbe.Delete {
-		tb.DeleteText(tbe.Reg.Start, tbe.Reg.End, false, true)
-	} else {
-		tb.InsertText(tbe.Reg.Start, tbe.ToBytes(), false, true)
+	gp := tbe.Group
+	for {
+		if tbe.Delete {
+			tb.DeleteText(tbe.Reg.Start, tbe.Reg.End, false, true)
+		} else {
+			tb.InsertText(tbe.Reg.Start, tbe.ToBytes(), false, true)
+		}
+		tb.UndoPos++
+		if gp == 0 || tb.UndoPos >= len(tb.Undos) {
+			break
+		}
+		ntbe := tb.Undos[tb.UndoPos]
+		if ntbe == nil || ntbe.Group != gp {
+			break
+		}
+		tbe = ntbe
 	}
-	tb.UndoPos++
 	return tbe
 }
 
@@ -1949,13 +2176,15 @@ func (tb *TextBuf) AddTag(ln, st, ed int, tag token.Tokens) {
 	if !tb.IsValidLine(ln) {
 		return
 	}
+	tb.LargeLoad(ln, ln)
 	tr := lex.NewLex(token.KeyToken{Tok: tag}, st, ed)
 	tr.Time.Now()
-	if len(tb.Tags[ln]) == 0 {
-		tb.Tags[ln] = append(tb.Tags[ln], tr)
+	li := ln - tb.LargeSt
+	if len(tb.Tags[li]) == 0 {
+		tb.Tags[li] = append(tb.Tags[li], tr)
 	} else {
-		tb.Tags[ln] = tb.AdjustedTags(ln) // must re-adjust before adding new ones!
-		tb.Tags[ln].AddSort(tr)
+		tb.Tags[li] = tb.AdjustedTags(ln) // must re-adjust before adding new ones!
+		tb.Tags[li].AddSort(tr)
 	}
 	tb.MarkupLinesLock(ln, ln)
 }
@@ -1970,8 +2199,10 @@ func (tb *TextBuf) TagAt(pos TextPos) (reg lex.Lex, ok bool) {
 	if !tb.IsValidLine(pos.Ln) {
 		return
 	}
-	tb.Tags[pos.Ln] = tb.AdjustedTags(pos.Ln) // re-adjust for current info
-	for _, t := range tb.Tags[pos.Ln] {
+	tb.LargeLoad(pos.Ln, pos.Ln)
+	li := pos.Ln - tb.LargeSt
+	tb.Tags[li] = tb.AdjustedTags(pos.Ln) // re-adjust for current info
+	for _, t := range tb.Tags[li] {
 		if t.St >= pos.Ch && t.Ed < pos.Ch {
 			return t, true
 		}
@@ -1985,13 +2216,15 @@ func (tb *TextBuf) RemoveTag(pos TextPos, tag token.Tokens) (reg lex.Lex, ok boo
 	if !tb.IsValidLine(pos.Ln) {
 		return
 	}
-	tb.Tags[pos.Ln] = tb.AdjustedTags(pos.Ln) // re-adjust for current info
-	for i, t := range tb.Tags[pos.Ln] {
+	tb.LargeLoad(pos.Ln, pos.Ln)
+	li := pos.Ln - tb.LargeSt
+	tb.Tags[li] = tb.AdjustedTags(pos.Ln) // re-adjust for current info
+	for i, t := range tb.Tags[li] {
 		if t.ContainsPos(pos.Ch) {
 			if tag > 0 && t.Tok.Tok != tag {
 				continue
 			}
-			tb.Tags[pos.Ln] = append(tb.Tags[pos.Ln][:i], tb.Tags[pos.Ln][i+1:]...)
+			tb.Tags[li] = append(tb.Tags[li][:i], tb.Tags[li][i+1:]...)
 			reg = t
 			ok = true
 			break
@@ -2014,11 +2247,11 @@ func (tb *TextBuf) LineIndent(ln int, tabSz int) (n int, ichr indent.Char) {
 	defer tb.LinesMu.RUnlock()
 
 	ichr = indent.Tab
-	sz := len(tb.Lines[ln])
+	txt := tb.lineLocked(ln)
+	sz := len(txt)
 	if sz == 0 {
 		return
 	}
-	txt := tb.Lines[ln]
 	if txt[0] == ' ' {
 		ichr = indent.Space
 		n = 1
@@ -2085,12 +2318,12 @@ func (tb *TextBuf) PrevLineIndent(ln int) (n int, ichr indent.Char, txt string)
 	defer tb.LinesMu.RUnlock()
 	ln--
 	for ln >= 0 {
-		if len(tb.Lines[ln]) == 0 {
+		if len(tb.lineLocked(ln)) == 0 {
 			ln--
 			continue
 		}
 		n, ichr = tb.LineIndent(ln, tabSz)
-		txt = strings.TrimSpace(string(tb.Lines[ln]))
+		txt = strings.TrimSpace(string(tb.lineLocked(ln)))
 		if cmidx := strings.Index(txt, comst); cmidx > 0 {
 			txt = strings.TrimSpace(txt[:cmidx])
 		}
@@ -2113,7 +2346,7 @@ func (tb *TextBuf) AutoIndent(ln int, indents, unindents []string) (tbe *TextBuf
 
 	li, _, prvln := tb.PrevLineIndent(ln)
 	tb.LinesMu.RLock()
-	curln := strings.TrimSpace(string(tb.Lines[ln]))
+	curln := strings.TrimSpace(string(tb.lineLocked(ln)))
 	tb.LinesMu.RUnlock()
 	ind := false
 	und := false
@@ -2228,7 +2461,7 @@ func (tb *TextBuf) CommentRegion(st, ed int) {
 		if doCom {
 			tb.InsertText(TextPos{Ln: ln, Ch: ch}, []byte(comst), true, true)
 			if comed != "" {
-				lln := len(tb.Lines[ln])
+				lln := tb.LineLen(ln)
 				tb.InsertText(TextPos{Ln: ln, Ch: lln}, []byte(comed), true, true)
 			}
 		} else {
@@ -2255,8 +2488,8 @@ func (tb *TextBuf) SetCompleter(data interface{}, matchFun complete.MatchFunc, e
 	if matchFun == nil || editFun == nil {
 		if tb.Complete != nil {
 			tb.Complete.CompleteSig.Disconnect(tb.This())
+			tb.Complete.Destroy()
 		}
-		tb.Complete.Destroy()
 		tb.Complete = nil
 		return
 	}
@@ -2490,6 +2723,19 @@ func PrintDiffs(diffs TextDiffs) {
 // determines whether each patch is signaled -- if an overall signal will be
 // sent at the end, then that would not be necessary (typical)
 func (tb *TextBuf) PatchFromBuf(ob *TextBuf, diffs TextDiffs, signal bool) bool {
+	return tb.patchFromBuf(ob, diffs, false, signal)
+}
+
+// PatchFromBufUndo is PatchFromBuf with the edits saved on the undo stack,
+// as one step, so the patch can be undone -- e.g., for applying a single
+// change in a DiffView
+func (tb *TextBuf) PatchFromBufUndo(ob *TextBuf, diffs TextDiffs, signal bool) bool {
+	tb.UndoGroupStart()
+	defer tb.UndoGroupEnd()
+	return tb.patchFromBuf(ob, diffs, true, signal)
+}
+
+func (tb *TextBuf) patchFromBuf(ob *TextBuf, diffs TextDiffs, saveUndo, signal bool) bool {
 	bufUpdt, winUpdt, autoSave := tb.BatchUpdateStart()
 	defer tb.BatchUpdateEnd(bufUpdt, winUpdt, autoSave)
 
@@ -2499,26 +2745,86 @@ func (tb *TextBuf) PatchFromBuf(ob *TextBuf, diffs TextDiffs, signal bool) bool
 		df := diffs[i]
 		switch df.Tag {
 		case 'r':
-			tb.DeleteText(TextPos{Ln: df.I1}, TextPos{Ln: df.I2}, false, signal)
-			// fmt.Printf("patch rep del: %v %v\n", tbe.Reg, string(tbe.ToBytes()))
-			ot := ob.Region(TextPos{Ln: df.J1}, TextPos{Ln: df.J2})
-			tb.InsertText(TextPos{Ln: df.I1}, ot.ToBytes(), false, signal)
-			// fmt.Printf("patch rep ins: %v %v\n", tbe.Reg, string(tbe.ToBytes()))
+			atEnd := df.I2 >= tb.NumLines()
+			tb.deleteLines(df.I1, df.I2, saveUndo, signal)
+			tb.insertLines(df.I1, ob.linesBytes(df.J1, df.J2), atEnd, saveUndo, signal)
 			mods = true
 		case 'd':
-			tb.DeleteText(TextPos{Ln: df.I1}, TextPos{Ln: df.I2}, false, signal)
-			// fmt.Printf("patch del: %v %v\n", tbe.Reg, string(tbe.ToBytes()))
+			tb.deleteLines(df.I1, df.I2, saveUndo, signal)
 			mods = true
 		case 'i':
-			ot := ob.Region(TextPos{Ln: df.J1}, TextPos{Ln: df.J2})
-			tb.InsertText(TextPos{Ln: df.I1}, ot.ToBytes(), false, signal)
-			// fmt.Printf("patch ins: %v %v\n", tbe.Reg, string(tbe.ToBytes()))
+			atEnd := df.I1 >= tb.NumLines()
+			tb.insertLines(df.I1, ob.linesBytes(df.J1, df.J2), atEnd, saveUndo, signal)
 			mods = true
 		}
 	}
 	return mods
 }
 
+// newTextBufLike returns a new buffer with given text, and the file info,
+// highlighting and options of given buffer, if not nil -- for showing other
+// versions of its file, without LSP or persistent undo
+func newTextBufLike(name string, txt []byte, like *TextBuf) *TextBuf {
+	tb := &TextBuf{}
+	tb.InitName(tb, name)
+	if like != nil {
+		tb.Opts = like.Opts
+		tb.Opts.LSP = false
+		tb.Opts.PersistUndo = false
+		tb.Info = like.Info
+		tb.SetHiStyle(like.Hi.Style)
+	}
+	tb.SetText(txt)
+	return tb
+}
+
+// linesBytes returns the text of given range of lines [st, ed), without a
+// newline after the last one
+func (tb *TextBuf) linesBytes(st, ed int) []byte {
+	tb.LargeLoad(st, ed-1)
+	tb.LinesMu.RLock()
+	defer tb.LinesMu.RUnlock()
+	ed = ints.MinInt(ed, tb.NLines)
+	var b []byte
+	for ln := st; ln < ed; ln++ {
+		if ln > st {
+			b = append(b, '\n')
+		}
+		b = append(b, []byte(string(tb.lineLocked(ln)))...)
+	}
+	return b
+}
+
+// deleteLines deletes given range of whole lines [st, ed) -- if they run to
+// the end of the buffer, the newline before them is deleted, as the last line
+// has none
+func (tb *TextBuf) deleteLines(st, ed int, saveUndo, signal bool) {
+	if ed < tb.NumLines() {
+		tb.DeleteText(TextPos{Ln: st}, TextPos{Ln: ed}, saveUndo, signal)
+		return
+	}
+	if st == 0 {
+		tb.DeleteText(TextPos{}, tb.EndPos(), saveUndo, signal)
+		return
+	}
+	tb.DeleteText(TextPos{Ln: st - 1, Ch: tb.LineLen(st - 1)}, tb.EndPos(), saveUndo, signal)
+}
+
+// insertLines inserts given lines of text (without a final newline) as whole
+// lines at given line -- atEnd indicates that they go at the end of the
+// buffer (after any deleteLines to the end), so need a newline before them
+// instead of after, unless the buffer is empty
+func (tb *TextBuf) insertLines(ln int, text []byte, atEnd, saveUndo, signal bool) {
+	switch {
+	case !atEnd:
+		tb.InsertText(TextPos{Ln: ln}, append(text, '\n'), saveUndo, signal)
+	case ln == 0:
+		tb.InsertText(TextPos{}, text, saveUndo, signal)
+	default:
+		tb.InsertText(tb.EndPos(), append([]byte("\n"), text...), saveUndo, signal)
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////
 //   TextBufList, TextBufs
 
@@ -0,0 +1,78 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"strings"
+	"testing"
+)
+
+// textBufLines returns the lines of the buffer, joined with newlines
+func textBufLines(tb *TextBuf) string {
+	lns := make([]string, tb.NumLines())
+	for ln := range lns {
+		lns[ln] = string(tb.Line(ln))
+	}
+	return strings.Join(lns, "\n")
+}
+
+func TestPatchFromBuf(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		tags string // diff ops expected, in order
+	}{
+		{"insert start", "c\nd\n", "a\nb\nc\nd\n", "i"},
+		{"insert middle", "a\nd\n", "a\nb\nc\nd\n", "i"},
+		{"insert end", "a\nb\n", "a\nb\nc\nd\n", "i"},
+		{"delete start", "a\nb\nc\nd\n", "c\nd\n", "d"},
+		{"delete middle", "a\nb\nc\nd\n", "a\nd\n", "d"},
+		{"delete end", "a\nb\nc\nd\n", "a\nb\n", "d"},
+		{"replace start", "a\nb\nc\n", "x\ny\nz\nb\nc\n", "r"},
+		{"replace middle", "a\nb\nc\n", "a\nx\nc\n", "r"},
+		{"replace end", "a\nb\nc\n", "a\nb\nx\ny\n", "r"},
+		{"replace all", "a\nb\n", "x\n", "r"},
+		{"mixed", "a\nb\nc\nd\ne\nf\n", "x\na\nc\nd\ny\nf\ng\n", "idri"},
+		{"no final newline", "a\nb", "a\nx\nb", "i"},
+	}
+	for _, tt := range tests {
+		a := newTextBufLike("a", []byte(tt.a), nil)
+		b := newTextBufLike("b", []byte(tt.b), nil)
+		alns, blns := textBufLines(a), textBufLines(b)
+		diffs := a.DiffBufs(b)
+		tags := ""
+		for _, df := range diffs {
+			if df.Tag != 'e' {
+				tags += string(df.Tag)
+			}
+		}
+		if tags != tt.tags {
+			t.Errorf("%v: diff ops %q, want %q", tt.name, tags, tt.tags)
+		}
+		if !a.PatchFromBufUndo(b, diffs, true) {
+			t.Errorf("%v: PatchFromBufUndo = false", tt.name)
+		}
+		if got, want := textBufLines(a), blns; got != want {
+			t.Errorf("%v: patched text %q, want %q", tt.name, got, want)
+		}
+		a.Undo() // one step
+		if got := textBufLines(a); got != alns {
+			t.Errorf("%v: after undo %q, want %q", tt.name, got, alns)
+		}
+		a.Redo()
+		if got, want := textBufLines(a), blns; got != want {
+			t.Errorf("%v: after redo %q, want %q", tt.name, got, want)
+		}
+
+		c := newTextBufLike("c", []byte(tt.a), nil)
+		c.PatchFromBuf(b, diffs, false)
+		if got, want := textBufLines(c), blns; got != want {
+			t.Errorf("%v: PatchFromBuf text %q, want %q", tt.name, got, want)
+		}
+		if len(c.Undos) != 0 {
+			t.Errorf("%v: PatchFromBuf saved %v undos", tt.name, len(c.Undos))
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/goki/ki/kit"
+)
+
+// DiagSeverities are the severity levels of diagnostics, most severe first
+type DiagSeverities int32
+
+const (
+	// DiagError is an error that must be fixed
+	DiagError DiagSeverities = iota
+
+	// DiagWarning is a warning about a likely problem
+	DiagWarning
+
+	// DiagInfo is information about the code
+	DiagInfo
+
+	// DiagHint is a suggestion, e.g., a simplification
+	DiagHint
+
+	DiagSeveritiesN
+)
+
+//go:generate stringer -type=DiagSeverities
+
+var KiT_DiagSeverities = kit.Enums.AddEnumAltLower(DiagSeveritiesN, false, nil, "Diag")
+
+func (ev DiagSeverities) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *DiagSeverities) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// TextDiag is a diagnostic (error, warning, etc) attached to a region of a
+// TextBuf, from any source, e.g., a language server or build output
+type TextDiag struct {
+	Reg      TextRegion     `desc:"region of text the diagnostic applies to -- kept updated through edits"`
+	Severity DiagSeverities `desc:"how serious it is"`
+	Msg      string         `desc:"the message"`
+	Source   string         `desc:"what produced it, e.g., a language server or build command -- SetDiags replaces all the diagnostics from a given source"`
+}
+
+// String returns the diagnostic as severity: message (source)
+func (td *TextDiag) String() string {
+	s := strings.ToLower(strings.TrimPrefix(td.Severity.String(), "Diag")) + ": " + td.Msg
+	if td.Source != "" {
+		s += " (" + td.Source + ")"
+	}
+	return s
+}
+
+// Contains returns true if the diagnostic region contains given position,
+// inclusive of the end
+func (td *TextDiag) Contains(pos TextPos) bool {
+	return !pos.IsLess(td.Reg.Start) && !td.Reg.End.IsLess(pos)
+}
+
+// AddDiag adds a diagnostic for given region -- zero-width regions are
+// extended by one char so there is something to mark.  Call RefreshViews
+// after adding a batch of them.
+func (tb *TextBuf) AddDiag(reg TextRegion, sev DiagSeverities, msg, src string) {
+	if reg.IsNil() {
+		reg.End = reg.Start
+		reg.End.Ch++
+	}
+	if reg.Time.IsZero() {
+		reg.TimeNow()
+	}
+	tb.DiagsMu.Lock()
+	tb.Diags = append(tb.Diags, TextDiag{Reg: reg, Severity: sev, Msg: msg, Source: src})
+	tb.sortDiags()
+	tb.DiagsMu.Unlock()
+}
+
+// SetDiags replaces all the diagnostics from given source with the given
+// ones (which should have that Source).  Call RefreshViews to update.
+func (tb *TextBuf) SetDiags(src string, diags []TextDiag) {
+	tb.DiagsMu.Lock()
+	tb.clearDiags(src)
+	for _, d := range diags {
+		if d.Reg.IsNil() {
+			d.Reg.End = d.Reg.Start
+			d.Reg.End.Ch++
+		}
+		if d.Reg.Time.IsZero() {
+			d.Reg.TimeNow()
+		}
+		tb.Diags = append(tb.Diags, d)
+	}
+	tb.sortDiags()
+	tb.DiagsMu.Unlock()
+}
+
+// ClearDiags removes all the diagnostics from given source, or all of them
+// if src is empty.  Call RefreshViews to update.
+func (tb *TextBuf) ClearDiags(src string) {
+	tb.DiagsMu.Lock()
+	tb.clearDiags(src)
+	tb.DiagsMu.Unlock()
+}
+
+func (tb *TextBuf) clearDiags(src string) {
+	if src == "" {
+		tb.Diags = nil
+		return
+	}
+	nd := tb.Diags[:0]
+	for _, d := range tb.Diags {
+		if d.Source != src {
+			nd = append(nd, d)
+		}
+	}
+	tb.Diags = nd
+}
+
+// sortDiags keeps diags sorted by starting position -- must be called
+// under DiagsMu lock
+func (tb *TextBuf) sortDiags() {
+	sort.SliceStable(tb.Diags, func(i, j int) bool {
+		return tb.Diags[i].Reg.Start.IsLess(tb.Diags[j].Reg.Start)
+	})
+}
+
+// HasDiags returns true if there are any diagnostics
+func (tb *TextBuf) HasDiags() bool {
+	tb.DiagsMu.Lock()
+	defer tb.DiagsMu.Unlock()
+	return len(tb.Diags) > 0
+}
+
+// DiagsAt returns the diagnostics covering given position, most severe first
+func (tb *TextBuf) DiagsAt(pos TextPos) []TextDiag {
+	tb.DiagsMu.Lock()
+	defer tb.DiagsMu.Unlock()
+	var ds []TextDiag
+	for _, d := range tb.Diags {
+		if pos.IsLess(d.Reg.Start) {
+			break
+		}
+		if d.Contains(pos) {
+			ds = append(ds, d)
+		}
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		return ds[i].Severity < ds[j].Severity
+	})
+	return ds
+}
+
+// DiagsLineSeverity returns the most severe diagnostic severity on given
+// line, and false if there are none
+func (tb *TextBuf) DiagsLineSeverity(ln int) (DiagSeverities, bool) {
+	tb.DiagsMu.Lock()
+	defer tb.DiagsMu.Unlock()
+	sev := DiagSeveritiesN
+	for _, d := range tb.Diags {
+		if d.Reg.Start.Ln > ln {
+			break
+		}
+		if d.Reg.End.Ln >= ln && d.Severity < sev {
+			sev = d.Severity
+		}
+	}
+	return sev, sev != DiagSeveritiesN
+}
+
+// NextDiag returns the first diagnostic starting after given position, and
+// false if none
+func (tb *TextBuf) NextDiag(pos TextPos) (TextDiag, bool) {
+	tb.DiagsMu.Lock()
+	defer tb.DiagsMu.Unlock()
+	for _, d := range tb.Diags {
+		if pos.IsLess(d.Reg.Start) {
+			return d, true
+		}
+	}
+	return TextDiag{}, false
+}
+
+// PrevDiag returns the last diagnostic starting before given position, and
+// false if none
+func (tb *TextBuf) PrevDiag(pos TextPos) (TextDiag, bool) {
+	tb.DiagsMu.Lock()
+	defer tb.DiagsMu.Unlock()
+	for i := len(tb.Diags) - 1; i >= 0; i-- {
+		d := tb.Diags[i]
+		if d.Reg.Start.IsLess(pos) {
+			return d, true
+		}
+	}
+	return TextDiag{}, false
+}
+
+// DiagsAdjust updates the diagnostic regions for given edit, removing any
+// whose text was deleted -- called automatically by InsertText and DeleteText
+func (tb *TextBuf) DiagsAdjust(tbe *TextBufEdit) {
+	if tbe == nil {
+		return
+	}
+	tb.DiagsMu.Lock()
+	defer tb.DiagsMu.Unlock()
+	if len(tb.Diags) == 0 {
+		return
+	}
+	nd := tb.Diags[:0]
+	for _, d := range tb.Diags {
+		d.Reg = tbe.AdjustReg(d.Reg)
+		if d.Reg.IsNil() {
+			continue
+		}
+		nd = append(nd, d)
+	}
+	tb.Diags = nd
+}
+
+// DiagLineReg returns the region to mark for a diagnostic reported at given
+// line and char, as is typical of compiler output: the word starting at ch,
+// or the whole line (minus leading space) if ch < 0
+func (tb *TextBuf) DiagLineReg(ln, ch int) TextRegion {
+	if !tb.IsValidLine(ln) {
+		return TextRegionNil
+	}
+	tb.LargeLoad(ln, ln)
+	tb.LinesMu.RLock()
+	defer tb.LinesMu.RUnlock()
+	txt := tb.lineLocked(ln)
+	sz := len(txt)
+	reg := NewTextRegion(ln, 0, ln, sz)
+	if ch < 0 {
+		for reg.Start.Ch < sz && unicode.IsSpace(txt[reg.Start.Ch]) {
+			reg.Start.Ch++
+		}
+		return reg
+	}
+	if ch > sz {
+		ch = sz
+	}
+	reg.Start.Ch = ch
+	ed := ch
+	for ed < sz && (txt[ed] == '_' || unicode.IsLetter(txt[ed]) || unicode.IsDigit(txt[ed])) {
+		ed++
+	}
+	if ed == ch {
+		ed = ch + 1
+	}
+	reg.End.Ch = ed
+	return reg
+}
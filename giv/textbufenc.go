@@ -0,0 +1,367 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+)
+
+// TextEncodings are the text encodings that files can be read and written in
+// -- internally, TextBuf text is always UTF-8
+type TextEncodings int32
+
+const (
+	// EncUTF8 is UTF-8, the default
+	EncUTF8 TextEncodings = iota
+
+	// EncUTF16LE is little-endian UTF-16, as commonly used on Windows
+	EncUTF16LE
+
+	// EncUTF16BE is big-endian UTF-16
+	EncUTF16BE
+
+	// EncLatin1 is ISO-8859-1 (Latin-1), with one byte per char
+	EncLatin1
+
+	// EncWindows1252 is the Windows Western code page, which is Latin-1 with
+	// printable chars in place of most of the 0x80-0x9F control chars
+	EncWindows1252
+
+	TextEncodingsN
+)
+
+//go:generate stringer -type=TextEncodings
+
+var KiT_TextEncodings = kit.Enums.AddEnumAltLower(TextEncodingsN, false, nil, "Enc")
+
+func (ev TextEncodings) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *TextEncodings) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// TextEncodingNames are the names of the encodings as shown to users
+var TextEncodingNames = [TextEncodingsN]string{
+	EncUTF8:        "UTF-8",
+	EncUTF16LE:     "UTF-16 LE",
+	EncUTF16BE:     "UTF-16 BE",
+	EncLatin1:      "ISO-8859-1",
+	EncWindows1252: "Windows-1252",
+}
+
+// LineEndings are the styles of line endings that files can be read and
+// written with -- internally, TextBuf lines always end with LF
+type LineEndings int32
+
+const (
+	// LineEndLF is a single line feed (\n), as on Unix and Mac
+	LineEndLF LineEndings = iota
+
+	// LineEndCRLF is carriage return, line feed (\r\n), as on Windows
+	LineEndCRLF
+
+	LineEndingsN
+)
+
+//go:generate stringer -type=LineEndings
+
+var KiT_LineEndings = kit.Enums.AddEnumAltLower(LineEndingsN, false, nil, "LineEnd")
+
+func (ev LineEndings) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *LineEndings) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// LineEndingNames are the names of the line endings as shown to users
+var LineEndingNames = [LineEndingsN]string{
+	LineEndLF:   "LF",
+	LineEndCRLF: "CRLF",
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// win1252 has the chars for bytes 0x80-0x9F in Windows-1252 -- the five
+// undefined ones map to the same code point, as in Latin-1
+var win1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// TextEncodingSniffSize is the number of bytes at the start of a file that
+// are examined to detect UTF-16 without a byte-order mark
+var TextEncodingSniffSize = 4096
+
+// DetectEncoding returns the encoding of given raw file contents, and
+// whether they start with a byte-order mark (BOM).  Without a BOM, UTF-16 is
+// detected from the pattern of zero bytes in ASCII text, and text that is
+// not valid UTF-8 is taken to be Windows-1252 if it has any of its extra
+// chars, and Latin-1 otherwise.
+func DetectEncoding(b []byte) (TextEncodings, bool) {
+	switch {
+	case bytes.HasPrefix(b, bomUTF8):
+		return EncUTF8, true
+	case bytes.HasPrefix(b, bomUTF16LE):
+		return EncUTF16LE, true
+	case bytes.HasPrefix(b, bomUTF16BE):
+		return EncUTF16BE, true
+	}
+	sn := b
+	if len(sn) > TextEncodingSniffSize {
+		sn = sn[:TextEncodingSniffSize]
+	}
+	npr := len(sn) / 2
+	if npr > 0 {
+		evz, odz := 0, 0
+		for i := 0; i+1 < len(sn); i += 2 {
+			if sn[i] == 0 {
+				evz++
+			}
+			if sn[i+1] == 0 {
+				odz++
+			}
+		}
+		switch {
+		case odz > npr/4 && evz <= npr/16:
+			return EncUTF16LE, false
+		case evz > npr/4 && odz <= npr/16:
+			return EncUTF16BE, false
+		}
+	}
+	if utf8.Valid(b) {
+		return EncUTF8, false
+	}
+	for _, c := range b {
+		if c >= 0x80 && c <= 0x9F {
+			return EncWindows1252, false
+		}
+	}
+	return EncLatin1, false
+}
+
+// DecodeText returns given raw text in given encoding as UTF-8, skipping any
+// byte-order mark
+func DecodeText(b []byte, enc TextEncodings) []byte {
+	switch enc {
+	case EncUTF16LE, EncUTF16BE:
+		if bytes.HasPrefix(b, bomUTF16LE) || bytes.HasPrefix(b, bomUTF16BE) {
+			b = b[2:]
+		}
+		u16 := make([]uint16, len(b)/2)
+		for i := range u16 {
+			if enc == EncUTF16LE {
+				u16[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+			} else {
+				u16[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+			}
+		}
+		return []byte(string(utf16.Decode(u16)))
+	case EncLatin1, EncWindows1252:
+		rs := make([]rune, len(b))
+		for i, c := range b {
+			if enc == EncWindows1252 && c >= 0x80 && c <= 0x9F {
+				rs[i] = win1252[c-0x80]
+			} else {
+				rs[i] = rune(c)
+			}
+		}
+		return []byte(string(rs))
+	}
+	return bytes.TrimPrefix(b, bomUTF8)
+}
+
+// EncodeText returns given UTF-8 text in given encoding, starting with a
+// byte-order mark if bom is set (only for UTF-8 and UTF-16).  Chars that
+// cannot be represented in a single-byte encoding are replaced with '?', and
+// an error is returned describing the first one.
+func EncodeText(b []byte, enc TextEncodings, bom bool) ([]byte, error) {
+	switch enc {
+	case EncUTF16LE, EncUTF16BE:
+		u16 := utf16.Encode(bytes.Runes(b))
+		eb := make([]byte, 0, 2*len(u16)+2)
+		if bom {
+			if enc == EncUTF16LE {
+				eb = append(eb, bomUTF16LE...)
+			} else {
+				eb = append(eb, bomUTF16BE...)
+			}
+		}
+		for _, u := range u16 {
+			if enc == EncUTF16LE {
+				eb = append(eb, byte(u), byte(u>>8))
+			} else {
+				eb = append(eb, byte(u>>8), byte(u))
+			}
+		}
+		return eb, nil
+	case EncLatin1, EncWindows1252:
+		var err error
+		eb := make([]byte, 0, len(b))
+		ln := 1
+		for _, r := range string(b) {
+			c, ok := encodeByte(r, enc)
+			if !ok && err == nil {
+				err = fmt.Errorf("char %q on line %v cannot be represented in %v", r, ln, TextEncodingNames[enc])
+			}
+			if r == '\n' {
+				ln++
+			}
+			eb = append(eb, c)
+		}
+		return eb, err
+	}
+	if bom {
+		eb := make([]byte, 0, len(b)+3)
+		eb = append(eb, bomUTF8...)
+		return append(eb, b...), nil
+	}
+	return b, nil
+}
+
+// encodeByte returns the single-byte encoding of given rune, and false if
+// it has none, in which case it is '?'
+func encodeByte(r rune, enc TextEncodings) (byte, bool) {
+	if enc == EncWindows1252 {
+		if r >= 0x80 && r <= 0x9F {
+			if win1252[r-0x80] == r { // undefined ones map to themselves
+				return byte(r), true
+			}
+			return '?', false
+		}
+		for i, wr := range win1252 {
+			if wr == r {
+				return byte(0x80 + i), true
+			}
+		}
+	}
+	if r < 0x100 {
+		return byte(r), true
+	}
+	return '?', false
+}
+
+// DetectLineEndings returns the line-ending style of given text -- the most
+// common one if both are present, in which case mixed is true
+func DetectLineEndings(b []byte) (le LineEndings, mixed bool) {
+	nlf := bytes.Count(b, []byte("\n"))
+	ncrlf := bytes.Count(b, []byte("\r\n"))
+	nlf -= ncrlf
+	if ncrlf > nlf {
+		le = LineEndCRLF
+	}
+	return le, nlf > 0 && ncrlf > 0
+}
+
+// DecodeFile sets the Encoding, BOM, LineEnding and MixedLineEnds of the
+// buffer from given raw file contents, and returns them as UTF-8 with LF line
+// endings -- Encoding is used as given if force is set
+func (tb *TextBuf) DecodeFile(b []byte, enc TextEncodings, force bool) []byte {
+	denc, bom := DetectEncoding(b)
+	if !force {
+		enc = denc
+	}
+	tb.Encoding = enc
+	tb.BOM = bom && (enc == denc)
+	txt := DecodeText(b, enc)
+	tb.LineEnding, tb.MixedLineEnds = DetectLineEndings(txt)
+	return bytes.Replace(txt, []byte("\r\n"), []byte("\n"), -1)
+}
+
+// EncodeFile returns given UTF-8 text with LF line endings in the Encoding,
+// BOM and LineEnding of the buffer, for saving -- see EncodeText for errors
+func (tb *TextBuf) EncodeFile(txt []byte) ([]byte, error) {
+	if tb.LineEnding == LineEndCRLF {
+		txt = bytes.Replace(txt, []byte("\n"), []byte("\r\n"), -1)
+	}
+	return EncodeText(txt, tb.Encoding, tb.BOM)
+}
+
+// SetEncoding sets the encoding the file will be saved in -- the text is
+// unchanged, but the buffer is marked as changed so it will be saved
+func (tb *TextBuf) SetEncoding(enc TextEncodings, bom bool) {
+	if enc != EncUTF8 && enc != EncUTF16LE && enc != EncUTF16BE {
+		bom = false
+	}
+	if tb.Encoding == enc && tb.BOM == bom {
+		return
+	}
+	tb.Encoding = enc
+	tb.BOM = bom
+	tb.SetChanged()
+}
+
+// SetLineEnding sets the line ending style the file will be saved with --
+// the buffer is marked as changed so it will be saved
+func (tb *TextBuf) SetLineEnding(le LineEndings) {
+	if tb.LineEnding == le && !tb.MixedLineEnds {
+		return
+	}
+	tb.LineEnding = le
+	tb.MixedLineEnds = false
+	tb.SetChanged()
+}
+
+// ReopenEncoding re-opens the file, decoding it in given encoding instead of
+// the detected one, e.g., if it was not detected correctly -- any changes are
+// lost
+func (tb *TextBuf) ReopenEncoding(enc TextEncodings) error {
+	if tb.Filename == "" {
+		return fmt.Errorf("giv.TextBuf ReopenEncoding: no file")
+	}
+	if tb.Large != nil {
+		return fmt.Errorf("giv.TextBuf ReopenEncoding: large files can only be opened as UTF-8")
+	}
+	b, err := ioutil.ReadFile(string(tb.Filename))
+	if err != nil {
+		return err
+	}
+	tb.AutoSaveDelete()
+	tb.Txt = tb.DecodeFile(b, enc, true)
+	tb.BytesToLines()
+	tb.ClearChanged()
+	tb.Refresh()
+	tb.ReMarkup()
+	tb.LSPResync()
+	return nil
+}
+
+// MixedLineEndsWarn warns the user that the file just opened has mixed line
+// endings, which will all be saved in the most common style
+func (tb *TextBuf) MixedLineEndsWarn() {
+	vp := tb.ViewportFromView()
+	if vp == nil || !tb.MixedLineEnds {
+		return
+	}
+	gi.PromptDialog(vp, gi.DlgOpts{Title: "Mixed Line Endings",
+		Prompt: fmt.Sprintf("File: %v has both LF and CRLF line endings -- all lines will be saved with %v, the most common.  Use the Line Endings context menu item to choose.", tb.Filename, LineEndingNames[tb.LineEnding])},
+		true, false, nil, nil)
+}
+
+// sniffFile returns the encoding and line endings of given file from its
+// start, without reading all of it
+func sniffFile(filename string) (TextEncodings, bool, LineEndings) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return EncUTF8, false, LineEndLF
+	}
+	defer fp.Close()
+	b := make([]byte, PieceTableChunk)
+	n, _ := fp.Read(b)
+	b = b[:n]
+	for i := 0; i < 3 && len(b) > 0 && !utf8.Valid(b); i++ { // chunk may end mid-char
+		b = b[:len(b)-1]
+	}
+	enc, bom := DetectEncoding(b)
+	le, _ := DetectLineEndings(b)
+	return enc, bom, le
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		enc  TextEncodings
+		bom  bool
+	}{
+		{"empty", nil, EncUTF8, false},
+		{"ascii", []byte("hello\nworld\n"), EncUTF8, false},
+		{"utf8", []byte("caf\xc3\xa9 \xe2\x82\xac\n"), EncUTF8, false},
+		{"utf8 bom", []byte("\xef\xbb\xbfhello"), EncUTF8, true},
+		{"utf16le bom", []byte("\xff\xfeh\x00i\x00"), EncUTF16LE, true},
+		{"utf16be bom", []byte("\xfe\xff\x00h\x00i"), EncUTF16BE, true},
+		{"utf16le", []byte("h\x00e\x00l\x00l\x00o\x00\n\x00"), EncUTF16LE, false},
+		{"utf16be", []byte("\x00h\x00e\x00l\x00l\x00o\x00\n"), EncUTF16BE, false},
+		{"latin1", []byte("caf\xe9\n"), EncLatin1, false},
+		{"windows1252", []byte("\x93quoted\x94 \x80 caf\xe9\n"), EncWindows1252, false},
+	}
+	for _, tt := range tests {
+		enc, bom := DetectEncoding(tt.in)
+		if enc != tt.enc || bom != tt.bom {
+			t.Errorf("%v: DetectEncoding = %v, %v, want %v, %v", tt.name, enc, bom, tt.enc, tt.bom)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		enc  TextEncodings
+		want string
+	}{
+		{"utf8", []byte("caf\xc3\xa9"), EncUTF8, "café"},
+		{"utf8 bom", []byte("\xef\xbb\xbfcaf\xc3\xa9"), EncUTF8, "café"},
+		{"utf16le", []byte("c\x00a\x00f\x00\xe9\x00"), EncUTF16LE, "café"},
+		{"utf16le bom", []byte("\xff\xfec\x00\xe9\x00"), EncUTF16LE, "cé"},
+		{"utf16be", []byte("\x00c\x00a\x00f\x00\xe9"), EncUTF16BE, "café"},
+		{"utf16be bom", []byte("\xfe\xff\x00c\x00\xe9"), EncUTF16BE, "cé"},
+		{"utf16 surrogates", []byte("\x3d\xd8\x00\xde"), EncUTF16LE, "\U0001F600"},
+		{"latin1", []byte("caf\xe9 \x80"), EncLatin1, "café \u0080"},
+		{"windows1252", []byte("\x93q\x94 \x80 caf\xe9"), EncWindows1252, "“q” € café"},
+		{"windows1252 undefined", []byte("\x81\x8d"), EncWindows1252, "\u0081\u008d"},
+	}
+	for _, tt := range tests {
+		got := string(DecodeText(tt.in, tt.enc))
+		if got != tt.want {
+			t.Errorf("%v: DecodeText = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		enc    TextEncodings
+		bom    bool
+		want   []byte
+		hasErr bool
+	}{
+		{"utf8", "café", EncUTF8, false, []byte("caf\xc3\xa9"), false},
+		{"utf8 bom", "hi", EncUTF8, true, []byte("\xef\xbb\xbfhi"), false},
+		{"utf16le", "cé", EncUTF16LE, false, []byte("c\x00\xe9\x00"), false},
+		{"utf16le bom", "cé", EncUTF16LE, true, []byte("\xff\xfec\x00\xe9\x00"), false},
+		{"utf16be bom", "cé", EncUTF16BE, true, []byte("\xfe\xff\x00c\x00\xe9"), false},
+		{"utf16 surrogates", "\U0001F600", EncUTF16LE, false, []byte("\x3d\xd8\x00\xde"), false},
+		{"latin1", "café", EncLatin1, true, []byte("caf\xe9"), false},
+		{"latin1 unrepresentable", "a€b", EncLatin1, false, []byte("a?b"), true},
+		{"windows1252", "“q” € café", EncWindows1252, false, []byte("\x93q\x94 \x80 caf\xe9"), false},
+		{"windows1252 unrepresentable", "x\u0080y", EncWindows1252, false, []byte("x?y"), true},
+	}
+	for _, tt := range tests {
+		got, err := EncodeText([]byte(tt.in), tt.enc, tt.bom)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%v: EncodeText = %q, want %q", tt.name, got, tt.want)
+		}
+		if (err != nil) != tt.hasErr {
+			t.Errorf("%v: EncodeText error = %v, want error: %v", tt.name, err, tt.hasErr)
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	txt := "line one\ncafé “quoted” €\n"
+	for enc := EncUTF8; enc < TextEncodingsN; enc++ {
+		if enc == EncLatin1 {
+			continue // no “” or €
+		}
+		for _, bom := range []bool{false, true} {
+			b, err := EncodeText([]byte(txt), enc, bom)
+			if err != nil {
+				t.Errorf("%v bom: %v: EncodeText error: %v", enc, bom, err)
+				continue
+			}
+			if got := string(DecodeText(b, enc)); got != txt {
+				t.Errorf("%v bom: %v: round trip = %q, want %q", enc, bom, got, txt)
+			}
+		}
+	}
+}
+
+func TestDetectLineEndings(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		le    LineEndings
+		mixed bool
+	}{
+		{"empty", "", LineEndLF, false},
+		{"no newline", "abc", LineEndLF, false},
+		{"lf", "a\nb\nc\n", LineEndLF, false},
+		{"crlf", "a\r\nb\r\nc\r\n", LineEndCRLF, false},
+		{"bare cr", "a\rb\r", LineEndLF, false},
+		{"mostly lf", "a\nb\nc\r\n", LineEndLF, true},
+		{"mostly crlf", "a\r\nb\r\nc\n", LineEndCRLF, true},
+		{"tie", "a\nb\r\n", LineEndLF, true},
+	}
+	for _, tt := range tests {
+		le, mixed := DetectLineEndings([]byte(tt.in))
+		if le != tt.le || mixed != tt.mixed {
+			t.Errorf("%v: DetectLineEndings = %v, %v, want %v, %v", tt.name, le, mixed, tt.le, tt.mixed)
+		}
+	}
+}
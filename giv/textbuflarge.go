@@ -0,0 +1,262 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ints"
+	"github.com/goki/ki/runes"
+	"github.com/goki/pi/filecat"
+	"github.com/goki/pi/lex"
+)
+
+// TextBufLargeFileSize is the file size in bytes at or above which files are
+// opened in large-file mode, backed by a PieceTable instead of being read
+// into memory -- 0 turns large-file mode off
+var TextBufLargeFileSize = int64(20 * 1024 * 1024)
+
+// TextBufLargeMaxLines is the maximum number of lines kept loaded in memory
+// in large-file mode -- beyond this, lines far from those being viewed are
+// unloaded again
+var TextBufLargeMaxLines = 10000
+
+// IsLarge returns true if the buffer is in large-file mode, where the text is
+// stored in the Large PieceTable, and only a window of the lines being viewed
+// or edited is loaded into Lines, LineBytes and Markup (see LargeSt).  Syntax
+// highlighting is line-by-line only, with no parsing, and the language
+// server and diff-based revert are not used.
+func (tb *TextBuf) IsLarge() bool {
+	return tb.Large != nil
+}
+
+// OpenLarge opens given file in large-file mode -- see IsLarge
+func (tb *TextBuf) OpenLarge(filename gi.FileName) error {
+	pt, err := OpenPieceTable(string(filename))
+	if err != nil {
+		return err
+	}
+	tb.CloseLarge()
+	tb.Txt = nil
+	tb.Filename = filename
+	tb.Stat()
+	tb.Info.Sup = filecat.NoSupport // no parsing -- chroma only, by line
+	tb.Hi.PiLang = nil
+	tb.SetCompleter(nil, nil, nil)
+	tb.Large = pt
+	tb.New(pt.NumLines())
+	return nil
+}
+
+// CloseLarge closes the Large PieceTable if in large-file mode, leaving the
+// buffer in normal mode
+func (tb *TextBuf) CloseLarge() {
+	if tb.Large == nil {
+		return
+	}
+	tb.Large.Close()
+	tb.Large = nil
+	tb.LargeSt = 0
+}
+
+// lineLoaded returns true if given line is loaded into Lines etc -- always
+// true for valid lines outside of large-file mode.  LinesMu must be locked.
+func (tb *TextBuf) lineLoaded(ln int) bool {
+	return ln >= tb.LargeSt && ln < tb.LargeSt+len(tb.Lines)
+}
+
+// lineLocked returns the runes of given line, or nil if it is not loaded --
+// LinesMu must be locked
+func (tb *TextBuf) lineLocked(ln int) []rune {
+	if !tb.lineLoaded(ln) {
+		return nil
+	}
+	return tb.Lines[ln-tb.LargeSt]
+}
+
+// LargeLoad ensures that the given range of lines (inclusive) is loaded and
+// marked up, in large-file mode -- does nothing otherwise.  The loaded lines
+// are always one contiguous window, which is extended to include the range
+// -- if that would exceed TextBufLargeMaxLines, the window is instead moved
+// to a margin around the range, unloading all the other lines, in which case
+// it returns true.
+func (tb *TextBuf) LargeLoad(st, ed int) bool {
+	if tb.Large == nil {
+		return false
+	}
+	tb.LinesMu.Lock()
+	defer tb.LinesMu.Unlock()
+	st = ints.MinInt(ints.MaxInt(st, 0), tb.NLines-1)
+	ed = ints.MinInt(ed, tb.NLines-1)
+	if st > ed {
+		return false
+	}
+	lst := tb.LargeSt
+	led := lst + len(tb.Lines) - 1
+	if len(tb.Lines) > 0 && st >= lst && ed <= led {
+		return false
+	}
+	nst, ned := st, ed
+	if len(tb.Lines) > 0 {
+		nst = ints.MinInt(st, lst)
+		ned = ints.MaxInt(ed, led)
+	}
+	evict := ned-nst+1 > TextBufLargeMaxLines
+	if evict {
+		mrg := TextBufLargeMaxLines / 4
+		nst = ints.MaxInt(st-mrg, 0)
+		ned = ints.MinInt(ed+mrg, tb.NLines-1)
+	}
+	tb.MarkupMu.Lock()
+	tb.largeWindow(nst, ned)
+	tb.MarkupMu.Unlock()
+	return evict
+}
+
+// largeWindow makes the lines from st to ed (inclusive) the loaded window,
+// keeping those already loaded and reading the others from the PieceTable --
+// must be called under LinesMu and MarkupMu locks
+func (tb *TextBuf) largeWindow(st, ed int) {
+	n := ed - st + 1
+	lines := make([][]rune, n)
+	lbs := make([][]byte, n)
+	mus := make([][]byte, n)
+	tags := make([]lex.Line, n)
+	hitags := make([]lex.Line, n)
+	offs := make([]int, n)
+	var nw []int
+	for ln := st; ln <= ed; ln++ {
+		li := ln - st
+		if tb.lineLoaded(ln) {
+			oi := ln - tb.LargeSt
+			lines[li] = tb.Lines[oi]
+			lbs[li] = tb.LineBytes[oi]
+			mus[li] = tb.Markup[oi]
+			tags[li] = tb.Tags[oi]
+			hitags[li] = tb.HiTags[oi]
+			continue
+		}
+		lb, err := tb.Large.LineBytes(ln)
+		if err != nil {
+			log.Printf("giv.TextBuf LargeLoad: %v\n", err)
+		}
+		lbs[li] = lb
+		lines[li] = bytes.Runes(lb)
+		mus[li] = HTMLEscapeBytes(lb)
+		nw = append(nw, ln)
+	}
+	tb.Lines, tb.LineBytes, tb.Markup = lines, lbs, mus
+	tb.Tags, tb.HiTags, tb.ByteOffs = tags, hitags, offs
+	tb.LargeSt = st
+	for _, ln := range nw {
+		tb.MarkupLines(ln, ln)
+	}
+}
+
+// LargeOff returns the byte offset in the Large PieceTable of given position
+// -- the line must be loaded, and LinesMu locked
+func (tb *TextBuf) LargeOff(pos TextPos) int64 {
+	return tb.Large.LineStart(pos.Ln) + int64(len(string(tb.Lines[pos.Ln-tb.LargeSt][:pos.Ch])))
+}
+
+// LargeWriteFile writes the current text to given file in large-file mode,
+// streaming it from the PieceTable -- if it is the file being edited, the
+// text is first written to a temporary file, which then replaces it, and
+// the PieceTable is re-opened on the new file
+func (tb *TextBuf) LargeWriteFile(filename gi.FileName) error {
+	tb.LinesMu.Lock()
+	defer tb.LinesMu.Unlock()
+	pt := tb.Large
+	dir, fn := filepath.Split(string(filename))
+	fp, err := ioutil.TempFile(dir, "."+fn+"-")
+	if err != nil {
+		return err
+	}
+	tmp := fp.Name()
+	bw := bufio.NewWriterSize(fp, PieceTableChunk)
+	_, err = pt.WriteTo(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	fp.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Chmod(tmp, 0644)
+	same := false
+	if fi, err := os.Stat(string(filename)); err == nil {
+		if ofi, err := pt.File.Stat(); err == nil {
+			same = os.SameFile(fi, ofi)
+		}
+	}
+	if err = os.Rename(tmp, string(filename)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if !same {
+		return nil
+	}
+	npt, err := OpenPieceTable(string(filename))
+	if err != nil {
+		log.Printf("giv.TextBuf LargeWriteFile: could not re-open file: %v\n", err)
+		return err
+	}
+	pt.Close()
+	tb.Large = npt
+	return nil
+}
+
+// LargeSearch looks for a string (no regexp) in large-file mode, streaming
+// through the whole text in the PieceTable -- see Search
+func (tb *TextBuf) LargeSearch(find []byte, ignoreCase bool) (int, []FileSearchMatch) {
+	fr := bytes.Runes(find)
+	if len(fr) == 0 {
+		return 0, nil
+	}
+	tb.LinesMu.RLock()
+	defer tb.LinesMu.RUnlock()
+	var matches []FileSearchMatch
+	rd := bufio.NewReaderSize(tb.Large.Reader(), PieceTableChunk)
+	for ln := 0; ; ln++ {
+		lb, err := rd.ReadBytes('\n')
+		lb = bytes.TrimSuffix(lb, []byte("\n"))
+		if len(lb) > 0 && (ignoreCase || bytes.Contains(lb, find)) {
+			matches = searchLine(matches, bytes.Runes(lb), fr, ln, ignoreCase)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return len(matches), matches
+}
+
+// searchLine appends the matches for fr in given line of runes to matches
+func searchLine(matches []FileSearchMatch, rn, fr []rune, ln int, ignoreCase bool) []FileSearchMatch {
+	fsz := len(fr)
+	sz := len(rn)
+	ci := 0
+	for ci < sz {
+		var i int
+		if ignoreCase {
+			i = runes.IndexFold(rn[ci:], fr)
+		} else {
+			i = runes.Index(rn[ci:], fr)
+		}
+		if i < 0 {
+			break
+		}
+		i += ci
+		ci = i + fsz
+		matches = append(matches, NewFileSearchMatch(rn, i, ci, ln))
+	}
+	return matches
+}
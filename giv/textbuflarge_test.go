@@ -0,0 +1,195 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/goki/gi/gi"
+)
+
+// testIconMgr is a gi.IconMgr with no icons, for file info without
+// importing svg, which imports giv
+type testIconMgr struct{}
+
+func (im *testIconMgr) IsValid(iconName string) bool               { return false }
+func (im *testIconMgr) SetIcon(ic *gi.Icon, iconName string) error { return nil }
+func (im *testIconMgr) IconList(alphaSort bool) []gi.IconName      { return nil }
+
+// testOpenBuf returns a new buffer opened on given file, with PersistUndo
+// as given -- nil if it could not be opened.  The file should not be of a
+// supported language, as those need the spelling model.
+func testOpenBuf(t *testing.T, fn string, persistUndo bool) *TextBuf {
+	t.Helper()
+	if gi.TheIconMgr == nil {
+		gi.TheIconMgr = &testIconMgr{}
+	}
+	tb := &TextBuf{}
+	tb.InitName(tb, "tb")
+	tb.Opts.PersistUndo = persistUndo
+	if err := tb.Open(gi.FileName(fn)); err != nil {
+		t.Error(err)
+		return nil
+	}
+	return tb
+}
+
+// testLargeBuf returns a buffer opened in large-file mode on a file of nln
+// numbered lines, and a func closing it and removing the file
+func testLargeBuf(t *testing.T, nln int) (*TextBuf, func()) {
+	t.Helper()
+	var b bytes.Buffer
+	for ln := 0; ln < nln; ln++ {
+		fmt.Fprintf(&b, "line %d\n", ln)
+	}
+	fn, rm := writeTestFile(t, "large.lines", b.Bytes())
+	tb := testOpenBuf(t, fn, false)
+	if tb == nil {
+		rm()
+		t.FailNow()
+	}
+	if !tb.IsLarge() {
+		tb.CloseLarge()
+		rm()
+		t.Fatal("file not opened in large-file mode")
+	}
+	return tb, func() { tb.CloseLarge(); rm() }
+}
+
+// checkLargeWindow checks that the loaded window of lines is as given
+func checkLargeWindow(t *testing.T, step string, tb *TextBuf, st, n int) {
+	t.Helper()
+	if tb.LargeSt != st || len(tb.Lines) != n {
+		t.Errorf("%v: window = %v, %v lines, want %v, %v lines", step, tb.LargeSt, len(tb.Lines), st, n)
+	}
+	if len(tb.LineBytes) != n || len(tb.Markup) != n || len(tb.Tags) != n || len(tb.HiTags) != n || len(tb.ByteOffs) != n {
+		t.Errorf("%v: per-line slices not sized to the %v loaded lines", step, n)
+	}
+}
+
+func TestTextBufLargeLoad(t *testing.T) {
+	defer func(sz int64, mx int) {
+		TextBufLargeFileSize, TextBufLargeMaxLines = sz, mx
+	}(TextBufLargeFileSize, TextBufLargeMaxLines)
+	TextBufLargeFileSize = 1
+	TextBufLargeMaxLines = 200
+
+	nln := 1000
+	tb, done := testLargeBuf(t, nln)
+	defer done()
+	if tb.NumLines() != nln {
+		t.Fatalf("NumLines = %v, want %v", tb.NumLines(), nln)
+	}
+	checkLargeWindow(t, "open", tb, 0, 101) // Open loads the first 100 lines
+
+	if tb.LargeLoad(20, 30) {
+		t.Errorf("LargeLoad of loaded lines evicted")
+	}
+	checkLargeWindow(t, "loaded", tb, 0, 101)
+	if tb.LargeLoad(0, 150) { // only the 50 new lines count
+		t.Errorf("LargeLoad extending window within max evicted")
+	}
+	checkLargeWindow(t, "extend", tb, 0, 151)
+	if !tb.LargeLoad(500, 510) {
+		t.Errorf("LargeLoad beyond max did not evict")
+	}
+	checkLargeWindow(t, "evict", tb, 450, 111) // margin of max / 4 around
+	if tb.lineLoaded(0) || tb.lineLoaded(449) || !tb.lineLoaded(560) || tb.lineLoaded(561) {
+		t.Errorf("lines loaded outside of window")
+	}
+	if tb.LargeLoad(440, 449) { // just before window
+		t.Errorf("LargeLoad extending window down evicted")
+	}
+	checkLargeWindow(t, "extend down", tb, 440, 121)
+	if !tb.LargeLoad(nln+10, nln+20) { // clipped to last line
+		t.Errorf("LargeLoad past end did not evict")
+	}
+	checkLargeWindow(t, "end", tb, nln-1-50, 51)
+
+	for _, ln := range []int{0, 5, 999, 450, 7} {
+		if got, want := string(tb.Line(ln)), fmt.Sprintf("line %d", ln); got != want {
+			t.Errorf("Line(%v) = %q, want %q", ln, got, want)
+		}
+		if len(tb.Lines) > TextBufLargeMaxLines {
+			t.Errorf("Line(%v): %v lines loaded, more than max", ln, len(tb.Lines))
+		}
+	}
+}
+
+func TestTextBufLargeEdit(t *testing.T) {
+	defer func(sz int64, mx int) {
+		TextBufLargeFileSize, TextBufLargeMaxLines = sz, mx
+	}(TextBufLargeFileSize, TextBufLargeMaxLines)
+	TextBufLargeFileSize = 1
+	TextBufLargeMaxLines = 40
+
+	nln := 300
+	tb, done := testLargeBuf(t, nln)
+	defer done()
+
+	tb.LargeLoad(200, 210)
+	tb.InsertText(TextPos{Ln: 205, Ch: 4}, []byte("X\nY"), true, true)
+	if tb.NumLines() != nln+1 {
+		t.Errorf("NumLines after insert = %v, want %v", tb.NumLines(), nln+1)
+	}
+	tb.Line(10) // moves the window away
+	if tb.lineLoaded(205) {
+		t.Fatalf("edited line still loaded")
+	}
+	want := []string{"line 204", "lineX", "Y 205", "line 206"}
+	for i, w := range want {
+		if got := string(tb.Line(204 + i)); got != w {
+			t.Errorf("after insert: Line(%v) = %q, want %q", 204+i, got, w)
+		}
+	}
+
+	tb.Line(100)
+	tb.DeleteText(TextPos{Ln: 205, Ch: 4}, TextPos{Ln: 206, Ch: 1}, true, true)
+	if tb.NumLines() != nln {
+		t.Errorf("NumLines after delete = %v, want %v", tb.NumLines(), nln)
+	}
+	tb.Line(10)
+	for ln := 203; ln <= 207; ln++ {
+		if got, want := string(tb.Line(ln)), fmt.Sprintf("line %d", ln); got != want {
+			t.Errorf("after delete: Line(%v) = %q, want %q", ln, got, want)
+		}
+	}
+	if got := string(tb.Large.Bytes()[tb.Large.LineStart(205):tb.Large.LineStart(206)]); got != "line 205\n" {
+		t.Errorf("piece table line 205 = %q", got)
+	}
+}
+
+func TestTextViewLargeLayout(t *testing.T) {
+	defer func(sz int64, mx int) {
+		TextBufLargeFileSize, TextBufLargeMaxLines = sz, mx
+	}(TextBufLargeFileSize, TextBufLargeMaxLines)
+	TextBufLargeFileSize = 1
+	TextBufLargeMaxLines = 200
+
+	tv := testTextView(t, "")
+	tb, done := testLargeBuf(t, 100000)
+	defer done()
+	tv.SetBuf(tb)
+	if tv.NLines != 100000 || tv.Offs != nil || len(tv.Renders) != 0 {
+		t.Errorf("layout of all lines: %v lines, %v offsets, %v renders -- want 100000, 0, 0", tv.NLines, len(tv.Offs), len(tv.Renders))
+	}
+	if got, want := tv.LineOff(5000), 5000*tv.LineHeight; got != want {
+		t.Errorf("LineOff(5000) = %v, want %v", got, want)
+	}
+	tv.LayoutVisLines(5000, 5010)
+	if tv.rendSt != tb.LargeSt || len(tv.Renders) != len(tb.Lines) {
+		t.Errorf("renders window = %v, %v lines, want buffer window %v, %v lines", tv.rendSt, len(tv.Renders), tb.LargeSt, len(tb.Lines))
+	}
+	for ln := 5000; ln <= 5010; ln++ {
+		if len(tv.LineRender(ln).Spans) == 0 {
+			t.Errorf("line %v not laid out", ln)
+		}
+	}
+	if len(tv.LineRender(10).Spans) != 0 {
+		t.Errorf("line outside of window laid out")
+	}
+}
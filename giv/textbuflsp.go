@@ -0,0 +1,427 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/goki/gi/lsp"
+	"github.com/goki/pi/complete"
+)
+
+// LSPRootMarkers are the files or directories that mark the root of a
+// workspace for a language server -- the root is the nearest enclosing
+// directory of a file that contains one of these, or the file's own
+// directory if none
+var LSPRootMarkers = []string{".git", "go.mod", "Cargo.toml", "setup.py", "pyproject.toml", "package.json", "compile_commands.json"}
+
+// LSPClients are the running language server clients, keyed by file type
+// and workspace root directory -- shared by all buffers for that type and root
+var LSPClients = map[string]*lsp.Client{}
+
+// LSPBufs are the buffers that are open in a language server, keyed by URI
+var LSPBufs = map[string]*TextBuf{}
+
+// LSPMu protects LSPClients and LSPBufs
+var LSPMu sync.Mutex
+
+// LSPRootDir returns the workspace root directory for given file, using
+// LSPRootMarkers
+func LSPRootDir(fname string) string {
+	dir, _ := filepath.Abs(filepath.Dir(fname))
+	for d := dir; ; {
+		for _, mk := range LSPRootMarkers {
+			if _, err := os.Stat(filepath.Join(d, mk)); err == nil {
+				return d
+			}
+		}
+		pd := filepath.Dir(d)
+		if pd == d {
+			break
+		}
+		d = pd
+	}
+	return dir
+}
+
+// LSPShutdownAll shuts down all the running language servers -- call at
+// the end of the app
+func LSPShutdownAll() {
+	LSPMu.Lock()
+	cls := LSPClients
+	LSPClients = map[string]*lsp.Client{}
+	LSPBufs = map[string]*TextBuf{}
+	LSPMu.Unlock()
+	for _, cl := range cls {
+		cl.Shutdown()
+	}
+}
+
+// LSPStart opens this buffer's file in the language server for its file
+// type, starting the server if not already running for the workspace --
+// returns false if there is no filename, no server is configured or
+// installed, or it failed to start.  Completion then uses the server.
+// Called automatically in a separate goroutine when a file is opened with
+// Opts.LSP on: the server is started there, and the buffer is then opened in
+// it on the event loop of the window viewing the buffer.  Diagnostics from
+// the server are added with the server command as their Source.
+func (tb *TextBuf) LSPStart() bool {
+	tb.LinesMu.RLock()
+	has := tb.LSP != nil
+	tb.LinesMu.RUnlock()
+	if has {
+		return true
+	}
+	if tb.Filename == "" {
+		return false
+	}
+	sc, ok := lsp.ServerFor(tb.Info.Sup)
+	if !ok {
+		return false
+	}
+	fname := string(tb.Filename)
+	root := LSPRootDir(fname)
+	key := tb.Info.Sup.String() + ":" + root
+
+	LSPMu.Lock()
+	cl, has := LSPClients[key]
+	LSPMu.Unlock()
+	if !has {
+		var err error
+		cl, err = lsp.Start(sc, root)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+		cl.DiagsFunc = LSPDiagsRecv
+		cl.EditFunc = LSPEditRecv
+		LSPMu.Lock()
+		if ocl, has := LSPClients[key]; has { // someone else got there first
+			LSPMu.Unlock()
+			cl.Shutdown()
+			cl = ocl
+		} else {
+			LSPClients[key] = cl
+			LSPMu.Unlock()
+		}
+	}
+
+	tb.runOnEventLoop(func() {
+		if tb.LSP != nil || string(tb.Filename) != fname { // started or closed since
+			return
+		}
+		uri := lsp.FileURI(fname)
+		tb.LinesMu.Lock()
+		txt := tb.LinesText()
+		tb.LSP = cl
+		tb.LSPURI = uri
+		tb.LinesMu.Unlock()
+
+		LSPMu.Lock()
+		LSPBufs[uri] = tb
+		LSPMu.Unlock()
+		cl.DidOpen(uri, sc.LangID, txt)
+		tb.SetCompleter(tb, CompleteLSP, CompleteTextEdit)
+	})
+	return true
+}
+
+// LSPStop closes this buffer's file in the language server, and clears
+// the diagnostics -- the server keeps running for other buffers
+func (tb *TextBuf) LSPStop() {
+	if tb.LSP == nil {
+		return
+	}
+	tb.LSP.DidClose(tb.LSPURI)
+	LSPMu.Lock()
+	delete(LSPBufs, tb.LSPURI)
+	LSPMu.Unlock()
+	tb.ClearDiags(tb.LSP.Config.Cmd)
+	tb.LinesMu.Lock()
+	tb.LSP = nil
+	tb.LSPURI = ""
+	tb.LinesMu.Unlock()
+	if tb.Complete != nil && tb.Complete.Context == tb {
+		tb.SetCompleter(nil, nil, nil)
+		tb.ConfigSupported()
+	}
+}
+
+// LinesText returns the current text of the lines, joined with newlines --
+// must be called under LinesMu lock
+func (tb *TextBuf) LinesText() []byte {
+	var b bytes.Buffer
+	for ln, l := range tb.Lines {
+		if ln > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(string(l))
+	}
+	return b.Bytes()
+}
+
+// LSPPos returns the language server position for given text position --
+// LSP counts characters in UTF-16 code units
+func (tb *TextBuf) LSPPos(pos TextPos) lsp.Position {
+	tb.LinesMu.RLock()
+	defer tb.LinesMu.RUnlock()
+	if pos.Ln < 0 || pos.Ln >= len(tb.Lines) {
+		return lsp.Position{Line: pos.Ln, Character: pos.Ch}
+	}
+	ln := tb.Lines[pos.Ln]
+	if pos.Ch > len(ln) {
+		pos.Ch = len(ln)
+	}
+	return lsp.Position{Line: pos.Ln, Character: lsp.UTF16Len(ln[:pos.Ch])}
+}
+
+// LSPTextPos returns the text position for given language server position
+func (tb *TextBuf) LSPTextPos(pos lsp.Position) TextPos {
+	tb.LinesMu.RLock()
+	defer tb.LinesMu.RUnlock()
+	if pos.Line < 0 || pos.Line >= len(tb.Lines) {
+		return TextPos{Ln: pos.Line, Ch: pos.Character}
+	}
+	return TextPos{Ln: pos.Line, Ch: lsp.RuneIndex(tb.Lines[pos.Line], pos.Character)}
+}
+
+// LSPRegion returns the text region for given language server range, with
+// the time stamp set to now
+func (tb *TextBuf) LSPRegion(rg lsp.Range) TextRegion {
+	return NewTextRegionPos(tb.LSPTextPos(rg.Start), tb.LSPTextPos(rg.End))
+}
+
+// LSPDidChange sends given edit to the language server, if open in one --
+// called automatically by InsertText and DeleteText
+func (tb *TextBuf) LSPDidChange(tbe *TextBufEdit) {
+	if tb.LSP == nil || tbe == nil {
+		return
+	}
+	switch tb.LSP.SyncKind {
+	case lsp.SyncNone:
+		return
+	case lsp.SyncFull:
+		tb.LSPResync()
+		return
+	}
+	// chars before start are unchanged by the edit, so start is valid now
+	st := tb.LSPPos(tbe.Reg.Start)
+	ch := lsp.TextDocumentContentChangeEvent{Range: &lsp.Range{Start: st, End: st}}
+	if tbe.Delete {
+		nl := len(tbe.Text)
+		if nl <= 1 {
+			if nl == 1 {
+				ch.Range.End.Character += lsp.UTF16Len(tbe.Text[0])
+			}
+		} else {
+			ch.Range.End = lsp.Position{Line: st.Line + nl - 1, Character: lsp.UTF16Len(tbe.Text[nl-1])}
+		}
+	} else {
+		ch.Text = string(tbe.ToBytes())
+	}
+	tb.LSP.DidChange(tb.LSPURI, []lsp.TextDocumentContentChangeEvent{ch})
+}
+
+// LSPResync sends the entire current text to the language server, after
+// the buffer has been reloaded without going through InsertText / DeleteText
+func (tb *TextBuf) LSPResync() {
+	if tb.LSP == nil || tb.LSP.SyncKind == lsp.SyncNone {
+		return
+	}
+	tb.LinesMu.RLock()
+	txt := tb.LinesText()
+	tb.LinesMu.RUnlock()
+	tb.LSP.DidChange(tb.LSPURI, []lsp.TextDocumentContentChangeEvent{{Text: string(txt)}})
+}
+
+// LSPDidSave tells the language server the file was saved
+func (tb *TextBuf) LSPDidSave() {
+	if tb.LSP == nil {
+		return
+	}
+	tb.LSP.DidSave(tb.LSPURI)
+}
+
+// LSPDiagsRecv is the lsp.DiagsFunc that receives published diagnostics
+// and sets them as the Diags for the buffer for that URI -- called from the
+// reader goroutine of the client, so the buffer is updated on the event loop
+func LSPDiagsRecv(uri string, diags []lsp.Diagnostic) {
+	LSPMu.Lock()
+	tb, ok := LSPBufs[uri]
+	LSPMu.Unlock()
+	if !ok {
+		return
+	}
+	tb.runOnEventLoop(func() {
+		if tb.LSP == nil || tb.LSPURI != uri {
+			return
+		}
+		src := tb.LSP.Config.Cmd
+		tds := make([]TextDiag, len(diags))
+		for i, d := range diags {
+			tds[i] = TextDiag{Reg: tb.LSPRegion(d.Range), Severity: LSPDiagSeverity(d.Severity), Msg: d.Message, Source: src}
+			if d.Source != "" && d.Source != src {
+				tds[i].Msg = d.Source + ": " + d.Message
+			}
+		}
+		tb.SetDiags(src, tds)
+		tb.RefreshViews()
+	})
+}
+
+// LSPDiagSeverity returns the DiagSeverities for given LSP severity
+func LSPDiagSeverity(sev lsp.DiagnosticSeverity) DiagSeverities {
+	switch sev {
+	case lsp.SevWarning:
+		return DiagWarning
+	case lsp.SevInfo:
+		return DiagInfo
+	case lsp.SevHint:
+		return DiagHint
+	}
+	return DiagError
+}
+
+// LSPApplyEdits applies given edits from the language server, as one undo
+// step -- edits are all relative to the text before any of them are applied
+func (tb *TextBuf) LSPApplyEdits(eds []lsp.TextEdit) {
+	type regEdit struct {
+		reg  TextRegion
+		text string
+	}
+	res := make([]regEdit, len(eds))
+	for i, ed := range eds {
+		res[i] = regEdit{tb.LSPRegion(ed.Range), ed.NewText}
+	}
+	sort.SliceStable(res, func(i, j int) bool { // last first, so earlier ones stay valid
+		return res[j].reg.Start.IsLess(res[i].reg.Start)
+	})
+	tb.UndoGroupStart()
+	for _, re := range res {
+		if re.reg.Start.IsLess(re.reg.End) {
+			tb.DeleteText(re.reg.Start, re.reg.End, true, true)
+		}
+		if re.text != "" {
+			tb.InsertText(re.reg.Start, []byte(re.text), true, true)
+		}
+	}
+	tb.UndoGroupEnd()
+}
+
+// LSPApplyWorkspaceEdit applies edits across files, e.g., from a rename --
+// files open in a buffer are edited there, and others are edited directly
+// on disk.  Returns false if any file could not be edited.  Must be called
+// on the event loop if any of the files are open -- see LSPEditRecv.
+func LSPApplyWorkspaceEdit(we *lsp.WorkspaceEdit) bool {
+	ok := true
+	for uri, eds := range we.AllChanges() {
+		LSPMu.Lock()
+		tb, has := LSPBufs[uri]
+		LSPMu.Unlock()
+		if has {
+			tb.LSPApplyEdits(eds)
+			continue
+		}
+		if err := LSPEditFile(lsp.URIFile(uri), eds); err != nil {
+			log.Println(err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// LSPEditRecv is the lsp.ApplyEditFunc for workspace edits requested by
+// the server -- called from the reader goroutine of the client, so the edits
+// are applied with LSPApplyWorkspaceEdit on the event loop of the window
+// viewing one of the open buffers it edits (directly if none), and are
+// reported as applied without waiting, as the event loop may itself be
+// waiting for a reply from the server
+func LSPEditRecv(we *lsp.WorkspaceEdit) bool {
+	var tb *TextBuf
+	LSPMu.Lock()
+	for uri := range we.AllChanges() {
+		if tb = LSPBufs[uri]; tb != nil {
+			break
+		}
+	}
+	LSPMu.Unlock()
+	if tb == nil {
+		return LSPApplyWorkspaceEdit(we)
+	}
+	tb.runOnEventLoop(func() {
+		LSPApplyWorkspaceEdit(we)
+	})
+	return true
+}
+
+// LSPEditFile applies given edits directly to a file that is not open
+func LSPEditFile(fname string, eds []lsp.TextEdit) error {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	tb := &TextBuf{}
+	tb.InitName(tb, "lsp-edit-tmp")
+	tb.SetText(b)
+	tb.LSPApplyEdits(eds)
+	tb.LinesMu.RLock()
+	txt := tb.LinesText()
+	tb.LinesMu.RUnlock()
+	info, err := os.Stat(fname)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, txt, info.Mode())
+}
+
+// CompleteLSP gets completions from the language server -- the data must be
+// the *TextBuf, which must have been started with LSPStart
+func CompleteLSP(data interface{}, text string, posLn, posCh int) (md complete.MatchData) {
+	tb, ok := data.(*TextBuf)
+	if !ok || tb.LSP == nil {
+		return md
+	}
+	items, err := tb.LSP.Completion(tb.LSPURI, tb.LSPPos(TextPos{Ln: posLn, Ch: posCh}))
+	if err != nil {
+		log.Printf("CompleteLSP: %v\n", err)
+		return md
+	}
+	md.Seed = LSPSeed(text)
+	lseed := strings.ToLower(md.Seed)
+	for i := range items {
+		it := &items[i]
+		ft := it.FilterText
+		if ft == "" {
+			ft = it.Label
+		}
+		if lseed != "" && !strings.HasPrefix(strings.ToLower(ft), lseed) {
+			continue
+		}
+		md.Matches = append(md.Matches, complete.Completion{Text: it.CompletionText(), Label: it.Label, Desc: it.Detail})
+	}
+	return md
+}
+
+// LSPSeed returns the identifier being typed at the end of given text
+func LSPSeed(text string) string {
+	rs := []rune(text)
+	st := len(rs)
+	for st > 0 {
+		r := rs[st-1]
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			break
+		}
+		st--
+	}
+	return string(rs[st:])
+}
@@ -9,9 +9,9 @@ import (
 
 var _ = errors.New("dummy error")
 
-const _TextBufSignals_name = "TextBufDoneTextBufNewTextBufInsertTextBufDeleteTextBufMarkUpdtTextBufSignalsN"
+const _TextBufSignals_name = "TextBufDoneTextBufNewTextBufInsertTextBufDeleteTextBufMarkUpdtTextBufSavedTextBufSignalsN"
 
-var _TextBufSignals_index = [...]uint8{0, 11, 21, 34, 47, 62, 77}
+var _TextBufSignals_index = [...]uint8{0, 11, 21, 34, 47, 62, 74, 89}
 
 func (i TextBufSignals) String() string {
 	if i < 0 || i >= TextBufSignals(len(_TextBufSignals_index)-1) {
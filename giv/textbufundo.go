@@ -0,0 +1,272 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TextBufUndoMax is the maximum number of edits saved in the persistent undo
+// history of a file (see TextBufOpts.PersistUndo) -- the oldest are dropped
+var TextBufUndoMax = 2000
+
+// TextBufUndoMaxBranches is the maximum number of alternative undo histories
+// kept for a buffer -- the oldest are dropped
+var TextBufUndoMaxBranches = 20
+
+// TextUndoBranch is an alternative undo history for a buffer: when new edits
+// are made after undoing, the edits that were undone are not lost, but kept
+// in a branch, which can be switched back to with UndoBranchSwitch
+type TextUndoBranch struct {
+	Undos []*TextBufEdit `desc:"the full undo stack of this history -- it shares the edits up to where it diverged with the current one"`
+	Pos   int            `desc:"undo position in Undos to return to when switching to this history"`
+	Time  time.Time      `desc:"when this history was left"`
+}
+
+// UndoReset clears the undo stack and alternative histories
+func (tb *TextBuf) UndoReset() {
+	tb.Undos = nil
+	tb.UndoUndos = nil
+	tb.UndoPos = 0
+	tb.UndoGp = 0
+	tb.UndoGpDepth = 0
+	tb.UndoBranches = nil
+}
+
+// UndoBranchSave saves the current undo stack as an alternative history --
+// called by SaveUndo when new edits are made after undoing, so switching
+// back to it redoes all of the edits that were undone
+func (tb *TextBuf) UndoBranchSave() {
+	br := &TextUndoBranch{Undos: make([]*TextBufEdit, len(tb.Undos)), Pos: len(tb.Undos), Time: time.Now()}
+	copy(br.Undos, tb.Undos)
+	tb.UndoBranches = append(tb.UndoBranches, br)
+	if n := len(tb.UndoBranches) - TextBufUndoMaxBranches; n > 0 {
+		tb.UndoBranches = tb.UndoBranches[n:]
+	}
+}
+
+// undoCommon returns the number of edits at the start of the two undo
+// stacks that are the same
+func undoCommon(a, b []*TextBufEdit) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// UndoBranchSwitch switches to the alternative undo history at given index
+// in UndoBranches, by undoing back to where it diverged from the current
+// one and redoing its edits -- the current history is saved as a branch in
+// turn.  Not available with EmacsUndo, which never discards any edits.
+func (tb *TextBuf) UndoBranchSwitch(idx int) error {
+	if idx < 0 || idx >= len(tb.UndoBranches) {
+		return fmt.Errorf("giv.TextBuf UndoBranchSwitch: index %v out of range", idx)
+	}
+	if tb.Opts.EmacsUndo {
+		return fmt.Errorf("giv.TextBuf UndoBranchSwitch: not available with EmacsUndo")
+	}
+	br := tb.UndoBranches[idx]
+	cur := &TextUndoBranch{Undos: tb.Undos, Pos: tb.UndoPos, Time: time.Now()}
+	cp := undoCommon(tb.Undos, br.Undos)
+	for tb.UndoPos > cp && tb.Undo() != nil {
+	}
+	for tb.UndoPos < cp && tb.Redo() != nil {
+	}
+	tb.UndoBranches = append(tb.UndoBranches[:idx], tb.UndoBranches[idx+1:]...)
+	if len(cur.Undos) > cp {
+		tb.UndoBranches = append(tb.UndoBranches, cur)
+	}
+	tb.Undos = br.Undos
+	for tb.UndoPos < br.Pos && tb.Redo() != nil {
+	}
+	return nil
+}
+
+// UndoBranchLabel returns a description of the alternative undo history at
+// given index, relative to the current one, for choosing among them
+func (tb *TextBuf) UndoBranchLabel(idx int) string {
+	br := tb.UndoBranches[idx]
+	cp := undoCommon(tb.Undos, br.Undos)
+	s := fmt.Sprintf("%v: after edit %v, %v different edits", br.Time.Format("15:04:05"), cp, br.Pos-cp)
+	if cp < len(br.Undos) {
+		txt := []rune(string(br.Undos[cp].ToBytes()))
+		if len(txt) > 30 {
+			txt = append(txt[:30], '…')
+		}
+		op := "insert"
+		if br.Undos[cp].Delete {
+			op = "delete"
+		}
+		s += fmt.Sprintf(", first: %v %q", op, string(txt))
+	}
+	return s
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Persistent Undo
+
+// TextBufUndoFile is the persistent undo history of a file, saved alongside
+// it by UndoSaveFile and restored by UndoLoadFile, if the file has the same
+// path and contents (hash) as when it was saved
+type TextBufUndoFile struct {
+	Path     string              `desc:"absolute path of the file"`
+	Hash     string              `desc:"hash of the contents of the file at the undo position"`
+	Edits    []TextBufUndoEdit   `desc:"all the distinct edits in all the histories"`
+	Undos    []int               `desc:"the undo stack, as indexes into Edits"`
+	UndoPos  int                 `desc:"undo position"`
+	UndoGp   int                 `desc:"undo group counter"`
+	Branches []TextBufUndoBranch `desc:"alternative undo histories"`
+}
+
+// TextBufUndoEdit is a TextBufEdit as saved in a TextBufUndoFile
+type TextBufUndoEdit struct {
+	Reg    TextRegion
+	Delete bool
+	Text   []string
+	Group  int
+}
+
+// TextBufUndoBranch is a TextUndoBranch as saved in a TextBufUndoFile
+type TextBufUndoBranch struct {
+	Undos []int
+	Pos   int
+	Time  time.Time
+}
+
+// FileHash returns the hash of given file contents used to key the
+// persistent undo history
+func FileHash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// UndoFilename returns the name of the file the undo history is saved in,
+// in the same directory as the file, as for AutoSaveFilename
+func (tb *TextBuf) UndoFilename() string {
+	return tb.AutoSaveFilename() + ".undo"
+}
+
+// UndoSaveFile saves the undo history to UndoFilename, keyed by the path of
+// the file and FileHash, which must be for the contents at the current undo
+// position, as when it has just been saved -- the oldest edits beyond
+// TextBufUndoMax are dropped
+func (tb *TextBuf) UndoSaveFile() error {
+	if tb.Filename == "" || tb.FileHash == "" {
+		return nil
+	}
+	path, _ := filepath.Abs(string(tb.Filename))
+	uf := &TextBufUndoFile{Path: path, Hash: tb.FileHash, UndoGp: tb.UndoGp}
+	idx := make(map[*TextBufEdit]int)
+	st := len(tb.Undos) - TextBufUndoMax
+	if st > 0 { // keep whole groups
+		for st < len(tb.Undos) && tb.Undos[st].Group != 0 && tb.Undos[st].Group == tb.Undos[st-1].Group {
+			st++
+		}
+	} else {
+		st = 0
+	}
+	if st > tb.UndoPos { // can't redo from before the start
+		st = tb.UndoPos
+	}
+	drop := make(map[*TextBufEdit]bool)
+	for _, tbe := range tb.Undos[:st] {
+		drop[tbe] = true
+	}
+	addStack := func(undos []*TextBufEdit) []int {
+		var ui []int
+		for _, tbe := range undos {
+			if drop[tbe] {
+				continue
+			}
+			i, has := idx[tbe]
+			if !has {
+				i = len(uf.Edits)
+				idx[tbe] = i
+				ue := TextBufUndoEdit{Reg: tbe.Reg, Delete: tbe.Delete, Group: tbe.Group, Text: make([]string, len(tbe.Text))}
+				for li, ln := range tbe.Text {
+					ue.Text[li] = string(ln)
+				}
+				uf.Edits = append(uf.Edits, ue)
+			}
+			ui = append(ui, i)
+		}
+		return ui
+	}
+	uf.Undos = addStack(tb.Undos)
+	uf.UndoPos = tb.UndoPos - st
+	for _, br := range tb.UndoBranches {
+		if undoCommon(tb.Undos, br.Undos) < st { // diverged before start
+			continue
+		}
+		uf.Branches = append(uf.Branches, TextBufUndoBranch{Undos: addStack(br.Undos), Pos: br.Pos - st, Time: br.Time})
+	}
+	b, err := json.Marshal(uf)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(tb.UndoFilename(), b, 0644)
+}
+
+// UndoLoadFile restores the undo history from UndoFilename, if it is for the
+// same path and contents (FileHash) as the file just opened -- so the user
+// can undo edits made before it was opened -- and returns true if so
+func (tb *TextBuf) UndoLoadFile() bool {
+	if tb.Filename == "" || tb.FileHash == "" {
+		return false
+	}
+	b, err := ioutil.ReadFile(tb.UndoFilename())
+	if err != nil {
+		return false
+	}
+	uf := &TextBufUndoFile{}
+	if err = json.Unmarshal(b, uf); err != nil {
+		return false
+	}
+	path, _ := filepath.Abs(string(tb.Filename))
+	if uf.Path != path || uf.Hash != tb.FileHash {
+		return false
+	}
+	edits := make([]*TextBufEdit, len(uf.Edits))
+	for i, ue := range uf.Edits {
+		tbe := &TextBufEdit{Reg: ue.Reg, Delete: ue.Delete, Group: ue.Group, Text: make([][]rune, len(ue.Text))}
+		for li, ln := range ue.Text {
+			tbe.Text[li] = []rune(ln)
+		}
+		edits[i] = tbe
+	}
+	stack := func(ui []int) []*TextBufEdit {
+		undos := make([]*TextBufEdit, 0, len(ui))
+		for _, i := range ui {
+			if i >= 0 && i < len(edits) {
+				undos = append(undos, edits[i])
+			}
+		}
+		return undos
+	}
+	tb.UndoReset()
+	tb.Undos = stack(uf.Undos)
+	tb.UndoPos = uf.UndoPos
+	if tb.UndoPos > len(tb.Undos) {
+		tb.UndoPos = len(tb.Undos)
+	}
+	tb.UndoGp = uf.UndoGp
+	for _, ub := range uf.Branches {
+		tb.UndoBranches = append(tb.UndoBranches, &TextUndoBranch{Undos: stack(ub.Undos), Pos: ub.Pos, Time: ub.Time})
+	}
+	return true
+}
+
+// UndoDeleteFile deletes any persistent undo history file
+func (tb *TextBuf) UndoDeleteFile() {
+	os.Remove(tb.UndoFilename())
+}
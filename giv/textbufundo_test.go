@@ -0,0 +1,121 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// undoTestText returns the text of the buffer, for comparing
+func undoTestText(tb *TextBuf) string {
+	return string(tb.Text())
+}
+
+func TestUndoBranchSwitch(t *testing.T) {
+	tb := &TextBuf{}
+	tb.InitName(tb, "tb")
+	tb.SetText([]byte("one\ntwo\n"))
+	orig := undoTestText(tb)
+
+	tb.InsertText(TextPos{Ln: 0, Ch: 3}, []byte(" A"), true, true)
+	withA := undoTestText(tb)
+	tb.Undo()
+	tb.InsertText(TextPos{Ln: 1, Ch: 0}, []byte("B "), true, true)
+	withB := undoTestText(tb)
+	if len(tb.UndoBranches) != 1 {
+		t.Fatalf("UndoBranches = %v, want 1 after edit when undone", len(tb.UndoBranches))
+	}
+	if err := tb.UndoBranchSwitch(0); err != nil {
+		t.Fatal(err)
+	}
+	if got := undoTestText(tb); got != withA {
+		t.Errorf("after switch: %q, want %q", got, withA)
+	}
+	if len(tb.UndoBranches) != 1 {
+		t.Fatalf("UndoBranches = %v, want 1 after switch", len(tb.UndoBranches))
+	}
+	if err := tb.UndoBranchSwitch(0); err != nil {
+		t.Fatal(err)
+	}
+	if got := undoTestText(tb); got != withB {
+		t.Errorf("after switch back: %q, want %q", got, withB)
+	}
+	tb.Undo()
+	if got := undoTestText(tb); got != orig {
+		t.Errorf("after undo: %q, want %q", got, orig)
+	}
+	if err := tb.UndoBranchSwitch(1); err == nil {
+		t.Errorf("UndoBranchSwitch out of range: no error")
+	}
+}
+
+func TestUndoPersist(t *testing.T) {
+	orig := "one\ntwo\nthree\n"
+	fn, rm := writeTestFile(t, "undo.lines", []byte(orig))
+	defer rm()
+
+	tb := testOpenBuf(t, fn, true)
+	if tb == nil {
+		t.FailNow()
+	}
+	tb.InsertText(TextPos{Ln: 0, Ch: 3}, []byte(" A"), true, true)
+	withA := undoTestText(tb)
+	tb.Undo()
+	tb.DeleteText(TextPos{Ln: 1, Ch: 0}, TextPos{Ln: 2, Ch: 0}, true, true)
+	tb.InsertText(TextPos{Ln: 0, Ch: 0}, []byte("zero\n"), true, true)
+	saved := undoTestText(tb)
+	if err := tb.Save(); err != nil {
+		t.Fatal(err)
+	}
+	defer tb.UndoDeleteFile()
+	if _, err := os.Stat(tb.UndoFilename()); err != nil {
+		t.Fatalf("undo file not saved: %v", err)
+	}
+
+	// reopen: the history is restored, and undo goes back past the save
+	rb := testOpenBuf(t, fn, true)
+	if rb == nil {
+		t.FailNow()
+	}
+	if got := undoTestText(rb); got != saved {
+		t.Fatalf("reopened: %q, want %q", got, saved)
+	}
+	if len(rb.Undos) != 2 || rb.UndoPos != 2 || len(rb.UndoBranches) != 1 {
+		t.Fatalf("reopened: %v undos at %v, %v branches -- want 2 at 2, 1", len(rb.Undos), rb.UndoPos, len(rb.UndoBranches))
+	}
+	rb.Undo()
+	rb.Undo()
+	if got := undoTestText(rb); got != orig {
+		t.Errorf("undo after reopen: %q, want %q", got, orig)
+	}
+	rb.Redo()
+	rb.Redo()
+	if got := undoTestText(rb); got != saved {
+		t.Errorf("redo after reopen: %q, want %q", got, saved)
+	}
+	if err := rb.UndoBranchSwitch(0); err != nil {
+		t.Fatal(err)
+	}
+	if got := undoTestText(rb); got != withA {
+		t.Errorf("switch after reopen: %q, want %q", got, withA)
+	}
+
+	// changed on disk: the history is for other contents, so not restored
+	if err := ioutil.WriteFile(fn, []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cb := testOpenBuf(t, fn, true)
+	if cb == nil {
+		t.FailNow()
+	}
+	if len(cb.Undos) != 0 || cb.UndoPos != 0 || len(cb.UndoBranches) != 0 {
+		t.Errorf("history restored for different contents: %v undos at %v, %v branches", len(cb.Undos), cb.UndoPos, len(cb.UndoBranches))
+	}
+	if cb.UndoLoadFile() {
+		t.Errorf("UndoLoadFile = true for different contents")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"time"
+
+	"github.com/goki/ki/ints"
+	"github.com/goki/ki/kit"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// TextVcsChanges are the kinds of change to a line relative to the version
+// of the file in version control, as marked in the TextView gutter
+type TextVcsChanges int32
+
+const (
+	// TextVcsUnchanged is a line that has not changed
+	TextVcsUnchanged TextVcsChanges = iota
+
+	// TextVcsAdded is a line that was added
+	TextVcsAdded
+
+	// TextVcsModified is a line that was changed
+	TextVcsModified
+
+	// TextVcsDeleted is a line just after lines that were deleted -- or
+	// the last line, if they were at the end
+	TextVcsDeleted
+
+	TextVcsChangesN
+)
+
+//go:generate stringer -type=TextVcsChanges
+
+var KiT_TextVcsChanges = kit.Enums.AddEnumAltLower(TextVcsChangesN, false, nil, "TextVcs")
+
+func (ev TextVcsChanges) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *TextVcsChanges) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// TextBufVcsDelay is the delay after the last edit before the changes
+// relative to the version control base are recomputed, in the background
+var TextBufVcsDelay = 500 * time.Millisecond
+
+// SetVcsBase sets the version of the file in version control (the last
+// commit) that the changes marked in the gutter are relative to -- nil
+// clears it, so no changes are marked.  The changes are computed in the
+// background, and the views are updated when done.
+func (tb *TextBuf) SetVcsBase(txt []byte) {
+	var vb *TextBuf
+	if txt != nil {
+		vb = newTextBufLike("vcs-base", txt, nil)
+	}
+	tb.VcsMu.Lock()
+	tb.VcsBase = vb
+	tb.VcsDiffs = nil
+	tb.vcsStale = vb != nil
+	tb.VcsMu.Unlock()
+	if vb != nil {
+		tb.vcsDelayUpdate(0)
+	} else {
+		tb.TextBufSig.Emit(tb.This(), int64(TextBufMarkUpdt), nil)
+	}
+}
+
+// HasVcsBase returns true if the buffer has a version control base, so its
+// changes are marked in the gutter
+func (tb *TextBuf) HasVcsBase() bool {
+	tb.VcsMu.Lock()
+	defer tb.VcsMu.Unlock()
+	return tb.VcsBase != nil
+}
+
+// VcsAdjust notes that the changes relative to the version control base
+// need to be updated after given edit -- they are recomputed in the
+// background after TextBufVcsDelay with no further edits, so that this is
+// done once per burst of edits, and never during rendering
+func (tb *TextBuf) VcsAdjust(tbe *TextBufEdit) {
+	tb.VcsMu.Lock()
+	has := tb.VcsBase != nil
+	if has {
+		tb.vcsStale = true
+	}
+	tb.VcsMu.Unlock()
+	if has {
+		tb.vcsDelayUpdate(TextBufVcsDelay)
+	}
+}
+
+// vcsDelayUpdate (re)starts the timer for VcsUpdate after given delay,
+// which then signals the views to refresh -- from another goroutine, as for
+// markup, so the views just note that they need a refresh
+func (tb *TextBuf) vcsDelayUpdate(delay time.Duration) {
+	tb.VcsMu.Lock()
+	defer tb.VcsMu.Unlock()
+	if tb.vcsTimer != nil {
+		tb.vcsTimer.Stop()
+	}
+	tb.vcsTimer = time.AfterFunc(delay, func() {
+		if tb.VcsUpdate() {
+			tb.TextBufSig.Emit(tb.This(), int64(TextBufMarkUpdt), nil)
+		}
+	})
+}
+
+// VcsUpdate recomputes the differences from the version control base, if
+// there have been edits since they were last computed -- returns true if
+// recomputed.  This is normally done in the background after edits, but
+// must be called directly before using VcsDiffs to change the text.  The
+// lines are never locked while VcsMu is held, as rendering does the reverse.
+func (tb *TextBuf) VcsUpdate() bool {
+	tb.VcsMu.Lock()
+	vb := tb.VcsBase
+	if !tb.vcsStale || vb == nil {
+		tb.VcsMu.Unlock()
+		return false
+	}
+	tb.vcsStale = false
+	tb.VcsMu.Unlock()
+	var diffs TextDiffs
+	if !tb.IsLarge() && tb.NumLines() > 0 && vb.NumLines() > 0 { // DiffBufs needs lines on both sides
+		diffs = tb.DiffBufs(vb)
+	}
+	tb.VcsMu.Lock()
+	if tb.VcsBase == vb && !tb.vcsStale { // else edited since -- will be redone
+		tb.VcsDiffs = diffs
+	}
+	tb.VcsMu.Unlock()
+	return true
+}
+
+// VcsLineChange returns the kind of change to given line relative to the
+// version control base, and the index in VcsDiffs of the change (-1 if
+// unchanged) -- for use with VcsHunkText and VcsRevertHunk.  This only
+// looks up the last computed VcsDiffs, so it is fast enough for rendering --
+// they can be out of date for TextBufVcsDelay after an edit.
+func (tb *TextBuf) VcsLineChange(ln int) (TextVcsChanges, int) {
+	lastLn := tb.NumLines() - 1
+	tb.VcsMu.Lock()
+	defer tb.VcsMu.Unlock()
+	if tb.VcsBase == nil {
+		return TextVcsUnchanged, -1
+	}
+	for i, df := range tb.VcsDiffs {
+		if df.I1 > ln {
+			break
+		}
+		switch df.Tag {
+		case 'd':
+			if ln < df.I2 {
+				return TextVcsAdded, i
+			}
+		case 'r':
+			if ln < df.I2 {
+				return TextVcsModified, i
+			}
+		case 'i':
+			if ints.MinInt(df.I1, lastLn) == ln {
+				return TextVcsDeleted, i
+			}
+		}
+	}
+	return TextVcsUnchanged, -1
+}
+
+// VcsDiff returns given change in VcsDiffs, and false if there is none
+func (tb *TextBuf) VcsDiff(idx int) (difflib.OpCode, bool) {
+	tb.VcsMu.Lock()
+	defer tb.VcsMu.Unlock()
+	if idx < 0 || idx >= len(tb.VcsDiffs) {
+		return difflib.OpCode{}, false
+	}
+	return tb.VcsDiffs[idx], true
+}
+
+// VcsDeletedAtEnd returns true if lines were deleted at the end of the
+// file, relative to the version control base -- marked after the last line
+func (tb *TextBuf) VcsDeletedAtEnd() bool {
+	nln := tb.NumLines()
+	tb.VcsMu.Lock()
+	defer tb.VcsMu.Unlock()
+	nd := len(tb.VcsDiffs)
+	return nd > 0 && tb.VcsDiffs[nd-1].Tag == 'i' && tb.VcsDiffs[nd-1].I1 >= nln
+}
+
+// VcsHunkText returns the original text, in the version control base, of
+// given change in VcsDiffs -- empty for added lines
+func (tb *TextBuf) VcsHunkText(idx int) []byte {
+	df, ok := tb.VcsDiff(idx)
+	if !ok {
+		return nil
+	}
+	tb.VcsMu.Lock()
+	vb := tb.VcsBase
+	tb.VcsMu.Unlock()
+	if vb == nil {
+		return nil
+	}
+	return vb.linesBytes(df.J1, df.J2)
+}
+
+// VcsRevertHunk reverts given change in VcsDiffs to the original text in the
+// version control base -- the edit can be undone.  VcsUpdate must have been
+// called since the last edit, so that the change is current.
+func (tb *TextBuf) VcsRevertHunk(idx int) bool {
+	df, ok := tb.VcsDiff(idx)
+	if !ok {
+		return false
+	}
+	tb.VcsMu.Lock()
+	vb := tb.VcsBase
+	tb.VcsMu.Unlock()
+	if vb == nil {
+		return false
+	}
+	tb.PatchFromBufUndo(vb, TextDiffs{df}, true) // edits update the diffs
+	return true
+}
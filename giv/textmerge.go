@@ -0,0 +1,212 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Merge conflict markers, at the start of a line, as written by version
+// control systems and Merge3Lines -- the base section is optional
+const (
+	MergeMarkerOurs   = "<<<<<<<"
+	MergeMarkerBase   = "|||||||"
+	MergeMarkerMid    = "======="
+	MergeMarkerTheirs = ">>>>>>>"
+)
+
+// MergeConflict is a region of text with conflict markers, as left by a
+// version control merge or Merge3Lines, in terms of the lines of the markers
+type MergeConflict struct {
+	St   int `desc:"line of the <<<<<<< marker, starting our version"`
+	Base int `desc:"line of the ||||||| marker, starting the base version -- -1 if none"`
+	Mid  int `desc:"line of the ======= marker, starting their version"`
+	Ed   int `desc:"line of the >>>>>>> marker, ending the conflict"`
+}
+
+// Ours returns the range of lines [st, ed) of our version
+func (mc *MergeConflict) Ours() (st, ed int) {
+	if mc.Base >= 0 {
+		return mc.St + 1, mc.Base
+	}
+	return mc.St + 1, mc.Mid
+}
+
+// BaseLines returns the range of lines [st, ed) of the base version -- empty
+// if none
+func (mc *MergeConflict) BaseLines() (st, ed int) {
+	if mc.Base < 0 {
+		return mc.Mid, mc.Mid
+	}
+	return mc.Base + 1, mc.Mid
+}
+
+// Theirs returns the range of lines [st, ed) of their version
+func (mc *MergeConflict) Theirs() (st, ed int) {
+	return mc.Mid + 1, mc.Ed
+}
+
+// isMergeMarker returns true if line starts with given marker, followed by
+// the end of the line or a space
+func isMergeMarker(ln []rune, mark string) bool {
+	if len(ln) < len(mark) || string(ln[:len(mark)]) != mark {
+		return false
+	}
+	return len(ln) == len(mark) || ln[len(mark)] == ' '
+}
+
+// ParseMergeConflicts returns the conflicts marked in given lines --
+// incomplete or malformed ones are skipped
+func ParseMergeConflicts(lines [][]rune) []MergeConflict {
+	var mcs []MergeConflict
+	mc := MergeConflict{St: -1}
+	for ln, l := range lines {
+		switch {
+		case isMergeMarker(l, MergeMarkerOurs):
+			mc = MergeConflict{St: ln, Base: -1, Mid: -1}
+		case mc.St < 0:
+		case isMergeMarker(l, MergeMarkerBase) && mc.Base < 0 && mc.Mid < 0:
+			mc.Base = ln
+		case isMergeMarker(l, MergeMarkerMid) && mc.Mid < 0:
+			mc.Mid = ln
+		case isMergeMarker(l, MergeMarkerTheirs) && mc.Mid >= 0:
+			mc.Ed = ln
+			mcs = append(mcs, mc)
+			mc = MergeConflict{St: -1}
+		}
+	}
+	return mcs
+}
+
+// MergeConflicts returns the conflicts marked in the buffer
+func (tb *TextBuf) MergeConflicts() []MergeConflict {
+	tb.LinesMu.RLock()
+	defer tb.LinesMu.RUnlock()
+	if tb.Large != nil {
+		return nil
+	}
+	return ParseMergeConflicts(tb.Lines)
+}
+
+// lineStrings returns the lines of the buffer as strings
+func (tb *TextBuf) lineStrings() []string {
+	tb.LinesMu.RLock()
+	defer tb.LinesMu.RUnlock()
+	strs := make([]string, len(tb.Lines))
+	for i, l := range tb.Lines {
+		strs[i] = string(l)
+	}
+	return strs
+}
+
+// MergeResolve resolves given conflict marked in the buffer, replacing it
+// with our version, their version, or both (ours first), as one undoable
+// edit
+func (tb *TextBuf) MergeResolve(mc MergeConflict, ours, theirs bool) {
+	var text []byte
+	if ost, oed := mc.Ours(); ours && oed > ost {
+		text = tb.linesBytes(ost, oed)
+	}
+	if tst, ted := mc.Theirs(); theirs && ted > tst {
+		if text != nil {
+			text = append(text, '\n')
+		}
+		text = append(text, tb.linesBytes(tst, ted)...)
+	}
+	bufUpdt, winUpdt, autoSave := tb.BatchUpdateStart()
+	defer tb.BatchUpdateEnd(bufUpdt, winUpdt, autoSave)
+	tb.UndoGroupStart()
+	defer tb.UndoGroupEnd()
+	atEnd := mc.Ed+1 >= tb.NumLines()
+	tb.deleteLines(mc.St, mc.Ed+1, true, true)
+	if text != nil {
+		tb.insertLines(mc.St, text, atEnd, true, true)
+	}
+}
+
+// Merge3Lines merges the changes from base to ours and from base to theirs,
+// returning the merged lines, and the number of conflicts, where both
+// changed the same lines differently -- these are marked with conflict
+// markers, labeled with given names, including the base version.
+func Merge3Lines(base, ours, theirs []string, oursLabel, theirsLabel string) ([]string, int) {
+	type change struct {
+		theirs bool
+		op     difflib.OpCode
+	}
+	var chs []change
+	for _, op := range difflib.NewMatcherWithJunk(base, ours, false, nil).GetOpCodes() {
+		if op.Tag != 'e' {
+			chs = append(chs, change{false, op})
+		}
+	}
+	for _, op := range difflib.NewMatcherWithJunk(base, theirs, false, nil).GetOpCodes() {
+		if op.Tag != 'e' {
+			chs = append(chs, change{true, op})
+		}
+	}
+	sort.SliceStable(chs, func(i, j int) bool {
+		return chs[i].op.I1 < chs[j].op.I1
+	})
+	// version returns the lines of base [lo, hi) with given changes applied
+	version := func(vers []string, cl []change, lo, hi int) []string {
+		var out []string
+		pos := lo
+		for _, c := range cl {
+			out = append(out, base[pos:c.op.I1]...)
+			out = append(out, vers[c.op.J1:c.op.J2]...)
+			pos = c.op.I2
+		}
+		return append(out, base[pos:hi]...)
+	}
+	var out []string
+	ncf := 0
+	pos := 0
+	for i := 0; i < len(chs); {
+		// cluster of overlapping or adjacent changes
+		lo, hi := chs[i].op.I1, chs[i].op.I2
+		j := i + 1
+		for j < len(chs) && chs[j].op.I1 <= hi {
+			if chs[j].op.I2 > hi {
+				hi = chs[j].op.I2
+			}
+			j++
+		}
+		var ocl, tcl []change
+		for _, c := range chs[i:j] {
+			if c.theirs {
+				tcl = append(tcl, c)
+			} else {
+				ocl = append(ocl, c)
+			}
+		}
+		out = append(out, base[pos:lo]...)
+		ov := version(ours, ocl, lo, hi)
+		tv := version(theirs, tcl, lo, hi)
+		switch {
+		case len(tcl) == 0:
+			out = append(out, ov...)
+		case len(ocl) == 0:
+			out = append(out, tv...)
+		case strings.Join(ov, "\n") == strings.Join(tv, "\n") && len(ov) == len(tv):
+			out = append(out, ov...) // same change on both sides
+		default:
+			ncf++
+			out = append(out, MergeMarkerOurs+" "+oursLabel)
+			out = append(out, ov...)
+			out = append(out, MergeMarkerBase+" base")
+			out = append(out, base[lo:hi]...)
+			out = append(out, MergeMarkerMid)
+			out = append(out, tv...)
+			out = append(out, MergeMarkerTheirs+" "+theirsLabel)
+		}
+		pos = hi
+		i = j
+	}
+	out = append(out, base[pos:]...)
+	return out, ncf
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mergeLines returns the lines of given text, one per line
+func mergeLines(txt string) []string {
+	if txt == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(txt, "\n"), "\n")
+}
+
+// mergeRunes returns the lines of given text as runes
+func mergeRunes(txt string) [][]rune {
+	var rl [][]rune
+	for _, l := range mergeLines(txt) {
+		rl = append(rl, []rune(l))
+	}
+	return rl
+}
+
+func TestMerge3Lines(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflicts          int
+	}{
+		{"unchanged", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\n", 0},
+		{"ours only", "a\nb\nc\n", "a\nB\nc\n", "a\nb\nc\n", "a\nB\nc\n", 0},
+		{"theirs only", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\nd\n", "a\nb\nc\nd\n", 0},
+		{"clean", "a\nb\nc\nd\ne\n", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", 0},
+		{"clean delete and insert", "a\nb\nc\nd\ne\n", "a\nc\nd\ne\n", "a\nb\nc\nd\nx\ne\n", "a\nc\nd\nx\ne\n", 0},
+		{"same change", "a\nb\nc\n", "a\nX\nc\n", "a\nX\nc\n", "a\nX\nc\n", 0},
+		{"same delete", "a\nb\nc\n", "a\nc\n", "a\nc\n", "a\nc\n", 0},
+		{"overlapping", "a\nb\nc\n", "a\nO\nc\n", "a\nT\nc\n",
+			"a\n<<<<<<< ours\nO\n||||||| base\nb\n=======\nT\n>>>>>>> theirs\nc\n", 1},
+		{"overlapping ranges", "a\nb\nc\nd\n", "a\nO\nO\nd\n", "a\nb\nT\nT\n",
+			"a\n<<<<<<< ours\nO\nO\nd\n||||||| base\nb\nc\nd\n=======\nb\nT\nT\n>>>>>>> theirs\n", 1},
+		{"adjacent", "a\nb\nc\nd\n", "a\nO\nc\nd\n", "a\nb\nT\nd\n",
+			"a\n<<<<<<< ours\nO\nc\n||||||| base\nb\nc\n=======\nb\nT\n>>>>>>> theirs\nd\n", 1},
+		{"insert same place", "a\nb\n", "a\no\nb\n", "a\nt\nb\n",
+			"a\n<<<<<<< ours\no\n||||||| base\n=======\nt\n>>>>>>> theirs\nb\n", 1},
+		{"two conflicts", "a\nb\nc\nd\ne\n", "O\nb\nc\nd\nO\n", "T\nb\nc\nd\nT\n",
+			"<<<<<<< ours\nO\n||||||| base\na\n=======\nT\n>>>>>>> theirs\nb\nc\nd\n<<<<<<< ours\nO\n||||||| base\ne\n=======\nT\n>>>>>>> theirs\n", 2},
+	}
+	for _, tt := range tests {
+		got, ncf := Merge3Lines(mergeLines(tt.base), mergeLines(tt.ours), mergeLines(tt.theirs), "ours", "theirs")
+		if ncf != tt.conflicts {
+			t.Errorf("%v: %v conflicts, want %v", tt.name, ncf, tt.conflicts)
+		}
+		if !reflect.DeepEqual(got, mergeLines(tt.want)) {
+			t.Errorf("%v: merged:\n%v\nwant:\n%v", tt.name, strings.Join(got, "\n"), tt.want)
+		}
+	}
+}
+
+func TestParseMergeConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		txt  string
+		want []MergeConflict
+	}{
+		{"none", "a\nb\n", nil},
+		{"two way", "a\n<<<<<<< HEAD\nb\n=======\nc\n>>>>>>> branch\nd\n",
+			[]MergeConflict{{St: 1, Base: -1, Mid: 3, Ed: 5}}},
+		{"with base", "<<<<<<<\nb\n|||||||\nx\n=======\nc\n>>>>>>>\n",
+			[]MergeConflict{{St: 0, Base: 2, Mid: 4, Ed: 6}}},
+		{"empty sides", "<<<<<<< a\n=======\n>>>>>>> b\n",
+			[]MergeConflict{{St: 0, Base: -1, Mid: 1, Ed: 2}}},
+		{"two", "<<<<<<<\na\n=======\nb\n>>>>>>>\nx\n<<<<<<<\nc\n=======\n>>>>>>>\n",
+			[]MergeConflict{{St: 0, Base: -1, Mid: 2, Ed: 4}, {St: 6, Base: -1, Mid: 8, Ed: 9}}},
+		{"not markers", "<<<<<<<<\na\n=======x\n>>>>>>>\n", nil},
+		{"no mid", "<<<<<<<\na\n>>>>>>>\n", nil},
+		{"unterminated", "<<<<<<<\na\n=======\nb\n", nil},
+		{"restarted", "<<<<<<<\na\n<<<<<<<\nb\n=======\nc\n>>>>>>>\n",
+			[]MergeConflict{{St: 2, Base: -1, Mid: 4, Ed: 6}}},
+	}
+	for _, tt := range tests {
+		got := ParseMergeConflicts(mergeRunes(tt.txt))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeConflictRoundTrip(t *testing.T) {
+	base := mergeLines("a\nb\nc\nd\ne\nf\n")
+	ours := mergeLines("a\nO1\nO2\nc\nd\ne\nO\n")
+	theirs := mergeLines("a\nT\nc\nd\ne\nT\n")
+	merged, ncf := Merge3Lines(base, ours, theirs, "ours", "theirs")
+	if ncf != 2 {
+		t.Fatalf("%v conflicts, want 2", ncf)
+	}
+	mtxt := strings.Join(merged, "\n") + "\n"
+
+	tb := newTextBufLike("merged", []byte(mtxt), nil)
+	mcs := tb.MergeConflicts()
+	if len(mcs) != ncf {
+		t.Fatalf("parsed %v conflicts, want %v", len(mcs), ncf)
+	}
+	sides := []struct{ ours, base, theirs []string }{
+		{[]string{"O1", "O2"}, []string{"b"}, []string{"T"}},
+		{[]string{"O"}, []string{"f"}, []string{"T"}},
+	}
+	for i, mc := range mcs {
+		st, ed := mc.Ours()
+		if got := merged[st:ed]; !reflect.DeepEqual(got, sides[i].ours) {
+			t.Errorf("conflict %v: ours %v, want %v", i, got, sides[i].ours)
+		}
+		st, ed = mc.BaseLines()
+		if got := merged[st:ed]; !reflect.DeepEqual(got, sides[i].base) {
+			t.Errorf("conflict %v: base %v, want %v", i, got, sides[i].base)
+		}
+		st, ed = mc.Theirs()
+		if got := merged[st:ed]; !reflect.DeepEqual(got, sides[i].theirs) {
+			t.Errorf("conflict %v: theirs %v, want %v", i, got, sides[i].theirs)
+		}
+	}
+
+	resolves := []struct {
+		name         string
+		ours, theirs bool
+		want         string
+	}{
+		{"ours", true, false, "a\nO1\nO2\nc\nd\ne\nO"},
+		{"theirs", false, true, "a\nT\nc\nd\ne\nT"},
+		{"both", true, true, "a\nO1\nO2\nT\nc\nd\ne\nO\nT"},
+		{"neither", false, false, "a\nc\nd\ne"},
+	}
+	for _, rs := range resolves {
+		rb := newTextBufLike("merged", []byte(mtxt), nil)
+		for mcs := rb.MergeConflicts(); len(mcs) > 0; mcs = rb.MergeConflicts() {
+			rb.MergeResolve(mcs[0], rs.ours, rs.theirs)
+		}
+		if got := textBufLines(rb); got != rs.want {
+			t.Errorf("resolve %v: %q, want %q", rs.name, got, rs.want)
+		}
+		rb.Undo()
+		rb.Undo()
+		if got, want := textBufLines(rb), strings.TrimSuffix(mtxt, "\n"); got != want {
+			t.Errorf("resolve %v: after undo %q, want %q", rs.name, got, want)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/goki/gi/oswin/mimedata"
 	"github.com/goki/gi/oswin/mouse"
 	"github.com/goki/gi/units"
+	"github.com/goki/gi/vci"
 	"github.com/goki/ki/indent"
 	"github.com/goki/ki/ints"
 	"github.com/goki/ki/ki"
@@ -44,8 +45,10 @@ type TextView struct {
 	CursorWidth    units.Value               `xml:"cursor-width" desc:"width of cursor -- set from cursor-width property (inherited)"`
 	LineIcons      map[int]gi.IconName       `desc:"icons for each line -- use SetLineIcon and DeleteLineIcon"`
 	NLines         int                       `json:"-" xml:"-" desc:"number of lines in the view -- sync'd with the Buf after edits, but always reflects storage size of Renders etc"`
-	Renders        []gi.TextRender           `json:"-" xml:"-" desc:"renders of the text lines, with one render per line (each line could visibly wrap-around, so these are logical lines, not display lines)"`
-	Offs           []float32                 `json:"-" xml:"-" desc:"starting offsets for top of each line"`
+	Renders        []gi.TextRender           `json:"-" xml:"-" desc:"renders of the text lines, with one render per line (each line could visibly wrap-around, so these are logical lines, not display lines) -- in large-file mode, only for the window of lines loaded in the buffer, starting at line rendSt -- use LineRender"`
+	Offs           []float32                 `json:"-" xml:"-" desc:"starting offsets for top of each line -- nil in large-file mode, where all lines have the same height -- use LineOff"`
+	Folds          []TextFold                `json:"-" xml:"-" desc:"foldable regions of lines, sorted by starting line -- computed from the pi parse Ast if available, and otherwise from indentation"`
+	Blame          vci.Blame                 `json:"-" xml:"-" desc:"blame annotations for each line, shown in the line number gutter if non-nil -- use SetBlame"`
 	LineNoDigs     int                       `json:"-" xml:"-" desc:"number of line number digits needed"`
 	LineNoOff      float32                   `json:"-" xml:"-" desc:"horizontal offset for start of text after line numbers"`
 	LineNoRender   gi.TextRender             `json:"-" xml:"-" desc:"render for line numbers"`
@@ -60,10 +63,18 @@ type TextView struct {
 	PrevSelectReg  TextRegion                `json:"-" xml:"-" desc:"previous selection region, that was actually rendered -- needed to update render"`
 	Highlights     []TextRegion              `json:"-" xml:"-" desc:"highlighted regions, e.g., for search results"`
 	Scopelights    []TextRegion              `json:"-" xml:"-" desc:"highlighted regions, specific to scope markers"`
+	LineColors     map[int]gi.Color          `json:"-" xml:"-" desc:"background colors for whole lines, e.g., for showing differences in a DiffView -- use SetLineColor and ClearLineColors"`
+	ColorRegions   []TextColorRegion         `json:"-" xml:"-" desc:"regions highlighted in their own background color, drawn over any LineColors, e.g., for the changed text within lines in a DiffView"`
 	SelectMode     bool                      `json:"-" xml:"-" desc:"if true, select text as cursor moves"`
+	Cursors        []TextCursor              `json:"-" xml:"-" desc:"additional cursors beyond the main CursorPos, each with its own selection -- cursor movement and edits apply at all cursors, as a single undo step"`
+	BoxSelect      bool                      `json:"-" xml:"-" desc:"if true, in rectangular (box) selection mode: cursor movement or dragging selects a box from BoxStart, as a cursor with a selection on each line"`
+	BoxStart       TextPos                   `json:"-" xml:"-" desc:"anchor position of the rectangular selection box"`
+	BoxEnd         TextPos                   `json:"-" xml:"-" desc:"moving corner of the rectangular selection box, opposite BoxStart"`
 	ForceComplete  bool                      `json:"-" xml:"-" desc:"if true, complete regardless of any disqualifying reasons"`
 	ISearch        ISearch                   `json:"-" xml:"-" desc:"interactive search data"`
 	QReplace       QReplace                  `json:"-" xml:"-" desc:"query replace data"`
+	Vim            VimState                  `json:"-" xml:"-" desc:"vim modal editing state -- only used when gi.Prefs.EditMode is gi.EditVim"`
+	VimRender      gi.TextRender             `json:"-" xml:"-" desc:"render of the vim status line"`
 	TextViewSig    ki.Signal                 `json:"-" xml:"-" view:"-" desc:"signal for text view -- see TextViewSignals for the types"`
 	LinkSig        ki.Signal                 `json:"-" xml:"-" view:"-" desc:"signal for clicking on a link -- data is a string of the URL -- if nobody receiving this signal, calls TextLinkHandler then URLHandler"`
 	StateStyles    [TextViewStatesN]gi.Style `json:"-" xml:"-" desc:"normal style and focus style"`
@@ -76,6 +87,9 @@ type TextView struct {
 	lastRecenter   int
 	lastAutoInsert rune
 	lastFilename   gi.FileName
+	foldHidden     []TextRegion
+	rendSt         int
+	noRender       gi.TextRender
 }
 
 var KiT_TextView = kit.Types.AddType(&TextView{}, TextViewProps)
@@ -132,6 +146,14 @@ const (
 	// QReplace.* members for current state
 	TextViewQReplace
 
+	// TextViewVimMode is emitted when the vim editing mode changes -- data is
+	// the new VimModes mode
+	TextViewVimMode
+
+	// TextViewVimCmd is emitted for vim : commands that are not handled by the
+	// TextView itself (including q to quit) -- data is the command string
+	TextViewVimCmd
+
 	// TextViewSignalsN is the number of TextViewSignals
 	TextViewSignalsN
 )
@@ -302,6 +324,7 @@ func (tv *TextView) SetBuf(buf *TextBuf) {
 	// had := false
 	if tv.Buf != nil {
 		// had = true
+		tv.VimUndoGroupEnd()
 		tv.Buf.DeleteView(tv)
 	}
 	tv.Buf = buf
@@ -325,6 +348,14 @@ func (tv *TextView) LinesInserted(tbe *TextBufEdit) {
 	stln := tbe.Reg.Start.Ln + 1
 	nsz := (tbe.Reg.End.Ln - tbe.Reg.Start.Ln)
 
+	if tv.Buf.IsLarge() { // renders are re-synced to the buffer in LayoutVisLines
+		tv.Renders = tv.Renders[:0]
+		tv.NLines += nsz
+		tv.LayoutAllLines(false)
+		tv.RenderAllLines()
+		return
+	}
+
 	// Renders
 	tmprn := make([]gi.TextRender, nsz)
 	nrn := append(tv.Renders, tmprn...)
@@ -351,6 +382,14 @@ func (tv *TextView) LinesDeleted(tbe *TextBufEdit) {
 	edln := tbe.Reg.End.Ln
 	dsz := edln - stln
 
+	if tv.Buf.IsLarge() { // renders are re-synced to the buffer in LayoutVisLines
+		tv.Renders = tv.Renders[:0]
+		tv.NLines -= dsz
+		tv.LayoutAllLines(false)
+		tv.RenderAllLines()
+		return
+	}
+
 	tv.Renders = append(tv.Renders[:stln], tv.Renders[edln:]...)
 	tv.Offs = append(tv.Offs[:stln], tv.Offs[edln:]...)
 
@@ -377,6 +416,8 @@ func TextViewBufSigRecv(rvwki, sbufki ki.Ki, sig int64, data interface{}) {
 			return
 		}
 		tbe := data.(*TextBufEdit)
+		tv.FoldsAdjust(tbe)
+		tv.BlameAdjust(tbe)
 		// fmt.Printf("tv %v got %v\n", tv.Nm, tbe.Reg.Start)
 		if tbe.Reg.Start.Ln != tbe.Reg.End.Ln {
 			// fmt.Printf("tv %v lines insert %v - %v\n", tv.Nm, tbe.Reg.Start, tbe.Reg.End)
@@ -396,6 +437,8 @@ func TextViewBufSigRecv(rvwki, sbufki ki.Ki, sig int64, data interface{}) {
 			return
 		}
 		tbe := data.(*TextBufEdit)
+		tv.FoldsAdjust(tbe)
+		tv.BlameAdjust(tbe)
 		if tbe.Reg.Start.Ln != tbe.Reg.End.Ln {
 			tv.LinesDeleted(tbe)
 		} else {
@@ -493,16 +536,6 @@ func (tv *TextView) LayoutAllLines(inLayout bool) bool {
 
 	tv.NLines = tv.Buf.NumLines()
 	nln := tv.NLines
-	if cap(tv.Renders) >= nln {
-		tv.Renders = tv.Renders[:nln]
-	} else {
-		tv.Renders = make([]gi.TextRender, nln)
-	}
-	if cap(tv.Offs) >= nln {
-		tv.Offs = tv.Offs[:nln]
-	} else {
-		tv.Offs = make([]float32, nln)
-	}
 
 	tv.VisSizes()
 	sz := tv.RenderSz
@@ -514,6 +547,31 @@ func (tv *TextView) LayoutAllLines(inLayout bool) bool {
 	off := float32(0)
 	mxwd := sz.X // always start with our render size
 
+	tv.FoldsUpdate()
+	if tv.Buf.IsLarge() { // only visible lines are laid out, in LayoutVisLines
+		tv.Renders = []gi.TextRender{} // non-nil: laid out
+		tv.Offs = nil
+		tv.rendSt = 0
+		off = float32(nln) * tv.LineHeight
+		extraHalf := tv.LineHeight * 0.5 * float32(tv.VisSize.Y)
+		nwSz := gi.Vec2D{mxwd, off + extraHalf}.ToPointCeil()
+		if inLayout {
+			tv.LinesSize = nwSz
+			return tv.SetSize()
+		}
+		return tv.ResizeIfNeeded(nwSz)
+	}
+	tv.rendSt = 0
+	if cap(tv.Renders) >= nln {
+		tv.Renders = tv.Renders[:nln]
+	} else {
+		tv.Renders = make([]gi.TextRender, nln)
+	}
+	if cap(tv.Offs) >= nln {
+		tv.Offs = tv.Offs[:nln]
+	} else {
+		tv.Offs = make([]float32, nln)
+	}
 	tv.Buf.MarkupMu.RLock()
 	tv.HasLinks = false
 	for ln := 0; ln < nln; ln++ {
@@ -523,7 +581,7 @@ func (tv *TextView) LayoutAllLines(inLayout bool) bool {
 			tv.HasLinks = true
 		}
 		tv.Offs[ln] = off
-		lsz := gi.Max32(tv.Renders[ln].Size.Y, tv.LineHeight)
+		lsz := tv.LineLayoutHeight(ln)
 		off += lsz
 		mxwd = gi.Max32(mxwd, tv.Renders[ln].Size.X)
 	}
@@ -603,14 +661,21 @@ func (tv *TextView) LayoutLines(st, ed int, isDel bool) bool {
 	sty := &tv.Sty
 	fst := sty.Font
 	fst.BgColor.SetColor(nil)
+	if tv.Buf.IsLarge() { // laid out again when visible, in LayoutVisLines
+		for ln := st; ln <= ed; ln++ {
+			*tv.LineRender(ln) = gi.TextRender{}
+		}
+		return false
+	}
 	mxwd := float32(tv.LinesSize.X)
 	rerend := false
+	lsz := tv.RenderSz
 
 	tv.Buf.MarkupMu.RLock()
 	for ln := st; ln <= ed; ln++ {
 		curspans := len(tv.Renders[ln].Spans)
 		tv.Renders[ln].SetHTMLPre(tv.Buf.Markup[ln], &fst, &sty.Text, &sty.UnContext, tv.CSS)
-		tv.Renders[ln].LayoutStdLR(&sty.Text, &sty.Font, &sty.UnContext, tv.RenderSz)
+		tv.Renders[ln].LayoutStdLR(&sty.Text, &sty.Font, &sty.UnContext, lsz)
 		if !tv.HasLinks && len(tv.Renders[ln].Links) > 0 {
 			tv.HasLinks = true
 		}
@@ -631,7 +696,7 @@ func (tv *TextView) LayoutLines(st, ed int, isDel bool) bool {
 		off := tv.Offs[ofst]
 		for ln := ofst; ln < tv.NLines; ln++ {
 			tv.Offs[ln] = off
-			lsz := gi.Max32(tv.Renders[ln].Size.Y, tv.LineHeight)
+			lsz := tv.LineLayoutHeight(ln)
 			off += lsz
 		}
 		extraHalf := tv.LineHeight * 0.5 * float32(tv.VisSize.Y)
@@ -645,6 +710,71 @@ func (tv *TextView) LayoutLines(st, ed int, isDel bool) bool {
 	return rerend
 }
 
+// LayoutVisLines loads and lays out any of the given range of lines that
+// have not yet been, in large-file mode, where only the visible lines are
+// laid out -- Renders are kept for the same window of lines as is loaded
+// in the buffer, so those the buffer unloaded to make room are also freed
+func (tv *TextView) LayoutVisLines(st, ed int) {
+	tb := tv.Buf
+	if tb == nil || !tb.IsLarge() {
+		return
+	}
+	tb.LargeLoad(st, ed)
+	tb.MarkupMu.RLock()
+	defer tb.MarkupMu.RUnlock()
+	bst, bn := tb.LargeSt, len(tb.Markup)
+	if bst != tv.rendSt || bn != len(tv.Renders) {
+		nrn := make([]gi.TextRender, bn)
+		for li := range nrn {
+			if oi := bst + li - tv.rendSt; oi >= 0 && oi < len(tv.Renders) {
+				nrn[li] = tv.Renders[oi]
+			}
+		}
+		tv.Renders = nrn
+		tv.rendSt = bst
+	}
+	sty := &tv.Sty
+	fst := sty.Font
+	fst.BgColor.SetColor(nil)
+	lsz := tv.RenderSz
+	lsz.X = 0 // no wrapping -- lines are fixed height
+	for ln := ints.MaxInt(st, bst); ln <= ed && ln < bst+bn; ln++ {
+		rn := &tv.Renders[ln-bst]
+		if len(rn.Spans) > 0 {
+			continue
+		}
+		rn.SetHTMLPre(tb.Markup[ln-bst], &fst, &sty.Text, &sty.UnContext, tv.CSS)
+		rn.LayoutStdLR(&sty.Text, &sty.Font, &sty.UnContext, lsz)
+		if !tv.HasLinks && len(rn.Links) > 0 {
+			tv.HasLinks = true
+		}
+	}
+}
+
+// LineRender returns the render of given line -- an empty render if it has
+// not been laid out, as in large-file mode for lines outside of the window
+// loaded in the buffer
+func (tv *TextView) LineRender(ln int) *gi.TextRender {
+	li := ln - tv.rendSt
+	if li < 0 || li >= len(tv.Renders) {
+		tv.noRender = gi.TextRender{}
+		return &tv.noRender
+	}
+	return &tv.Renders[li]
+}
+
+// LineOff returns the starting offset for the top of given line -- computed
+// directly in large-file mode, where all lines have the same height
+func (tv *TextView) LineOff(ln int) float32 {
+	if tv.Buf != nil && tv.Buf.IsLarge() {
+		return float32(ln) * tv.LineHeight
+	}
+	if len(tv.Offs) == 0 {
+		return 0
+	}
+	return tv.Offs[ints.MinInt(ints.MaxInt(ln, 0), len(tv.Offs)-1)]
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 //  Cursor Navigation
 
@@ -664,20 +794,20 @@ func (tv *TextView) ValidateCursor() {
 
 // WrappedLines returns the number of wrapped lines (spans) for given line number
 func (tv *TextView) WrappedLines(ln int) int {
-	if ln >= len(tv.Renders) {
+	if ln >= tv.NLines {
 		return 0
 	}
-	return len(tv.Renders[ln].Spans)
+	return len(tv.LineRender(ln).Spans)
 }
 
 // WrappedLineNo returns the wrapped line number (span index) and rune index
 // within that span of the given character position within line in position,
 // and false if out of range (last valid position returned in that case -- still usable).
 func (tv *TextView) WrappedLineNo(pos TextPos) (si, ri int, ok bool) {
-	if pos.Ln >= len(tv.Renders) {
+	if pos.Ln >= tv.NLines {
 		return 0, 0, false
 	}
-	return tv.Renders[pos.Ln].RuneSpanPos(pos.Ch)
+	return tv.LineRender(pos.Ln).RuneSpanPos(pos.Ch)
 }
 
 // SetCursor sets a new cursor position, enforcing it in range
@@ -688,6 +818,7 @@ func (tv *TextView) SetCursor(pos TextPos) {
 	}
 	tv.ClearScopelights()
 	tv.CursorPos = tv.Buf.ValidPos(pos)
+	tv.UnfoldLine(tv.CursorPos.Ln)
 	tv.CursorMovedSig()
 	txt := tv.Buf.Line(tv.CursorPos.Ln)
 	ch := tv.CursorPos.Ch
@@ -898,13 +1029,13 @@ func (tv *TextView) CursorDown(steps int) {
 			si, ri, _ := tv.WrappedLineNo(pos)
 			if si < wln-1 {
 				si++
-				mxlen := ints.MinInt(len(tv.Renders[pos.Ln].Spans[si].Text), tv.CursorCol)
+				mxlen := ints.MinInt(len(tv.LineRender(pos.Ln).Spans[si].Text), tv.CursorCol)
 				if tv.CursorCol < mxlen {
 					ri = tv.CursorCol
 				} else {
 					ri = mxlen
 				}
-				nwc, _ := tv.Renders[pos.Ln].SpanPosToRuneIdx(si, ri)
+				nwc, _ := tv.LineRender(pos.Ln).SpanPosToRuneIdx(si, ri)
 				if si == wln-1 && ri == mxlen {
 					nwc++
 				}
@@ -919,6 +1050,7 @@ func (tv *TextView) CursorDown(steps int) {
 				pos.Ln = tv.NLines - 1
 				break
 			}
+			pos.Ln = tv.FoldSkipDown(pos.Ln)
 			mxlen := ints.MinInt(tv.Buf.LineLen(pos.Ln), tv.CursorCol)
 			if tv.CursorCol < mxlen {
 				pos.Ch = tv.CursorCol
@@ -1043,7 +1175,7 @@ func (tv *TextView) CursorUp(steps int) {
 			if si > 0 {
 				ri = tv.CursorCol
 				// fmt.Printf("up cursorcol: %v\n", tv.CursorCol)
-				nwc, _ := tv.Renders[pos.Ln].SpanPosToRuneIdx(si-1, ri)
+				nwc, _ := tv.LineRender(pos.Ln).SpanPosToRuneIdx(si-1, ri)
 				pos.Ch = nwc
 				gotwrap = true
 			}
@@ -1054,10 +1186,11 @@ func (tv *TextView) CursorUp(steps int) {
 				pos.Ln = 0
 				break
 			}
+			pos.Ln = tv.FoldSkipUp(pos.Ln)
 			if wln := tv.WrappedLines(pos.Ln); wln > 1 { // just entered end of wrapped line
 				si := wln - 1
 				ri := tv.CursorCol
-				nwc, _ := tv.Renders[pos.Ln].SpanPosToRuneIdx(si, ri)
+				nwc, _ := tv.LineRender(pos.Ln).SpanPosToRuneIdx(si, ri)
 				pos.Ch = nwc
 			} else {
 				mxlen := ints.MinInt(tv.Buf.LineLen(pos.Ln), tv.CursorCol)
@@ -1125,7 +1258,7 @@ func (tv *TextView) CursorStartLine() {
 		si, ri, _ := tv.WrappedLineNo(pos)
 		if si > 0 {
 			ri = 0
-			nwc, _ := tv.Renders[pos.Ln].SpanPosToRuneIdx(si, ri)
+			nwc, _ := tv.LineRender(pos.Ln).SpanPosToRuneIdx(si, ri)
 			pos.Ch = nwc
 			tv.CursorPos = pos
 			tv.CursorCol = ri
@@ -1170,9 +1303,9 @@ func (tv *TextView) CursorEndLine() {
 	gotwrap := false
 	if wln := tv.WrappedLines(pos.Ln); wln > 1 {
 		si, ri, _ := tv.WrappedLineNo(pos)
-		ri = len(tv.Renders[pos.Ln].Spans[si].Text) - 1
-		nwc, _ := tv.Renders[pos.Ln].SpanPosToRuneIdx(si, ri)
-		if si == len(tv.Renders[pos.Ln].Spans)-1 { // last span
+		ri = len(tv.LineRender(pos.Ln).Spans[si].Text) - 1
+		nwc, _ := tv.LineRender(pos.Ln).SpanPosToRuneIdx(si, ri)
+		if si == len(tv.LineRender(pos.Ln).Spans)-1 { // last span
 			ri++
 			nwc++
 		}
@@ -1291,7 +1424,7 @@ func (tv *TextView) CursorKill() {
 	atEnd := false
 	if wln := tv.WrappedLines(pos.Ln); wln > 1 {
 		si, ri, _ := tv.WrappedLineNo(pos)
-		llen := len(tv.Renders[pos.Ln].Spans[si].Text)
+		llen := len(tv.LineRender(pos.Ln).Spans[si].Text)
 		if si == wln-1 {
 			llen--
 		}
@@ -1337,12 +1470,12 @@ func (tv *TextView) JumpToLine(ln int) {
 // FindNextLink finds next link after given position, returns false if no such links
 func (tv *TextView) FindNextLink(pos TextPos) (TextPos, TextRegion, bool) {
 	for ln := pos.Ln; ln < tv.NLines; ln++ {
-		if len(tv.Renders[ln].Links) == 0 {
+		if len(tv.LineRender(ln).Links) == 0 {
 			pos.Ch = 0
 			pos.Ln = ln + 1
 			continue
 		}
-		rend := &tv.Renders[ln]
+		rend := tv.LineRender(ln)
 		si, ri, _ := rend.RuneSpanPos(pos.Ch)
 		for ti := range rend.Links {
 			tl := &rend.Links[ti]
@@ -1363,7 +1496,7 @@ func (tv *TextView) FindNextLink(pos TextPos) (TextPos, TextRegion, bool) {
 // FindPrevLink finds previous link before given position, returns false if no such links
 func (tv *TextView) FindPrevLink(pos TextPos) (TextPos, TextRegion, bool) {
 	for ln := pos.Ln - 1; ln >= 0; ln-- {
-		if len(tv.Renders[ln].Links) == 0 {
+		if len(tv.LineRender(ln).Links) == 0 {
 			if ln-1 >= 0 {
 				pos.Ch = tv.Buf.LineLen(ln-1) - 2
 			} else {
@@ -1372,7 +1505,7 @@ func (tv *TextView) FindPrevLink(pos TextPos) (TextPos, TextRegion, bool) {
 			}
 			continue
 		}
-		rend := &tv.Renders[ln]
+		rend := tv.LineRender(ln)
 		si, ri, _ := rend.RuneSpanPos(pos.Ch)
 		nl := len(rend.Links)
 		for ti := nl - 1; ti >= 0; ti-- {
@@ -2409,6 +2542,33 @@ func (tv *TextView) MakeContextMenu(m *gi.Menu) {
 				txf.Clear()
 			})
 	}
+	m.AddSeparator("sep-fold")
+	ac = m.AddAction(gi.ActOpts{Label: "Toggle Fold"},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.FoldToggleAtCursor()
+		})
+	ac.SetActiveState(tv.FoldEnclosing(tv.CursorPos.Ln) >= 0)
+	m.AddAction(gi.ActOpts{Label: "Fold All"},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.FoldAll()
+		})
+	ac = m.AddAction(gi.ActOpts{Label: "Unfold All"},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.UnfoldAll()
+		})
+	ac.SetActiveState(tv.HasFolded())
+	if !tv.IsInactive() && tv.Buf != nil && len(tv.Buf.UndoBranches) > 0 {
+		m.AddAction(gi.ActOpts{Label: "Undo History..."},
+			tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+				txf := recv.Embed(KiT_TextView).(*TextView)
+				txf.UndoHistoryChooser()
+			})
+	}
+	tv.EncodingContextMenu(m)
+	tv.LSPContextMenu(m)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -2780,20 +2940,20 @@ func (tv *TextView) ScrollCursorToHorizCenter() bool {
 func (tv *TextView) CharStartPos(pos TextPos) gi.Vec2D {
 	spos := tv.RenderStartPos()
 	spos.X += tv.LineNoOff
-	if pos.Ln >= len(tv.Offs) {
-		if len(tv.Offs) > 0 {
-			pos.Ln = len(tv.Offs) - 1
+	if pos.Ln >= tv.NLines {
+		if tv.NLines > 0 {
+			pos.Ln = tv.NLines - 1
 		} else {
 			return spos
 		}
 	} else {
-		spos.Y += tv.Offs[pos.Ln] + gi.FixedToFloat32(tv.Sty.Font.Face.Metrics().Descent)
+		spos.Y += tv.LineOff(pos.Ln) + gi.FixedToFloat32(tv.Sty.Font.Face.Metrics().Descent)
 	}
-	if len(tv.Renders[pos.Ln].Spans) > 0 {
+	if len(tv.LineRender(pos.Ln).Spans) > 0 {
 		// note: Y from rune pos is baseline
-		rrp, _, _, _ := tv.Renders[pos.Ln].RuneRelPos(pos.Ch)
+		rrp, _, _, _ := tv.LineRender(pos.Ln).RuneRelPos(pos.Ch)
 		spos.X += rrp.X
-		spos.Y += rrp.Y - tv.Renders[pos.Ln].Spans[0].RelPos.Y // relative
+		spos.Y += rrp.Y - tv.LineRender(pos.Ln).Spans[0].RelPos.Y // relative
 	}
 	return spos
 }
@@ -2808,13 +2968,13 @@ func (tv *TextView) CharEndPos(pos TextPos) gi.Vec2D {
 		spos.X += tv.LineNoOff
 		return spos
 	}
-	spos.Y += tv.Offs[pos.Ln] + gi.FixedToFloat32(tv.Sty.Font.Face.Metrics().Descent)
+	spos.Y += tv.LineOff(pos.Ln) + gi.FixedToFloat32(tv.Sty.Font.Face.Metrics().Descent)
 	spos.X += tv.LineNoOff
-	if len(tv.Renders[pos.Ln].Spans) > 0 {
+	if len(tv.LineRender(pos.Ln).Spans) > 0 {
 		// note: Y from rune pos is baseline
-		rrp, _, _, _ := tv.Renders[pos.Ln].RuneEndPos(pos.Ch)
+		rrp, _, _, _ := tv.LineRender(pos.Ln).RuneEndPos(pos.Ch)
 		spos.X += rrp.X
-		spos.Y += rrp.Y - tv.Renders[pos.Ln].Spans[0].RelPos.Y // relative
+		spos.Y += rrp.Y - tv.LineRender(pos.Ln).Spans[0].RelPos.Y // relative
 	}
 	spos.Y += tv.LineHeight // end of that line
 	return spos
@@ -3019,17 +3179,18 @@ func (tv *TextView) RenderDepthBg(stln, edln int) {
 	lstdp := 0
 	for ln := stln; ln <= edln; ln++ {
 		lst := tv.CharStartPos(TextPos{Ln: ln}).Y // note: charstart pos includes descent
-		led := lst + math32.Max(tv.Renders[ln].Size.Y, tv.LineHeight)
+		led := lst + math32.Max(tv.LineRender(ln).Size.Y, tv.LineHeight)
 		if int(math32.Ceil(led)) < tv.VpBBox.Min.Y {
 			continue
 		}
 		if int(math32.Floor(lst)) > tv.VpBBox.Max.Y {
 			continue
 		}
-		if ln >= len(tv.Buf.HiTags) { // may be out of sync
+		hi := ln - tv.Buf.LargeSt
+		if hi < 0 || hi >= len(tv.Buf.HiTags) { // may be out of sync
 			continue
 		}
-		ht := tv.Buf.HiTags[ln]
+		ht := tv.Buf.HiTags[hi]
 		lsted := 0
 		for ti := range ht {
 			lx := &ht[ti]
@@ -3051,6 +3212,7 @@ func (tv *TextView) RenderDepthBg(stln, edln int) {
 // RenderSelect renders the selection region as a selected background color
 // -- always called within context of outer RenderLines or RenderAllLines
 func (tv *TextView) RenderSelect() {
+	tv.RenderMultiCursors()
 	if !tv.HasSelection() {
 		return
 	}
@@ -3070,6 +3232,54 @@ func (tv *TextView) RenderHighlights(stln, edln int) {
 	}
 }
 
+// TextColorRegion is a region of text highlighted in a given color -- see
+// TextView ColorRegions
+type TextColorRegion struct {
+	Reg   TextRegion
+	Color gi.Color
+}
+
+// SetLineColor sets the background color of given line -- see LineColors
+func (tv *TextView) SetLineColor(ln int, clr gi.Color) {
+	if tv.LineColors == nil {
+		tv.LineColors = make(map[int]gi.Color)
+	}
+	tv.LineColors[ln] = clr
+}
+
+// ClearLineColors clears all the LineColors and ColorRegions
+func (tv *TextView) ClearLineColors() {
+	tv.LineColors = nil
+	tv.ColorRegions = nil
+}
+
+// RenderLineColors renders the LineColors and ColorRegions within given line
+// range
+// -- always called within context of outer RenderLines or RenderAllLines
+func (tv *TextView) RenderLineColors(stln, edln int) {
+	if len(tv.LineColors) == 0 && len(tv.ColorRegions) == 0 {
+		return
+	}
+	sty := &tv.Sty
+	cspec := sty.Font.BgColor
+	for ln := stln; ln <= edln && ln < tv.NLines; ln++ {
+		clr, has := tv.LineColors[ln]
+		if !has || tv.LineFolded(ln) {
+			continue
+		}
+		cspec.Color = clr
+		tv.RenderRegionToEnd(TextPos{Ln: ln}, sty, &cspec)
+	}
+	for _, cr := range tv.ColorRegions {
+		reg := cr.Reg
+		if reg.Start.Ln > edln || reg.End.Ln < stln {
+			continue
+		}
+		cspec.Color = cr.Color
+		tv.RenderRegionBoxSty(reg, sty, &cspec)
+	}
+}
+
 // RenderScopelights renders a highlight background color for regions
 // in the Scopelights list
 // -- always called within context of outer RenderLines or RenderAllLines
@@ -3220,7 +3430,7 @@ func (tv *TextView) VisSizes() {
 	tv.LineNoDigs = ints.MaxInt(1+int(math32.Log10(float32(tv.NLines))), 3)
 	if tv.Buf != nil && tv.Buf.Opts.LineNos {
 		tv.SetFlag(int(TextViewHasLineNos))
-		tv.LineNoOff = float32(tv.LineNoDigs+3)*sty.Font.Ch + spc // space for icon
+		tv.LineNoOff = float32(tv.LineNoDigs+3)*sty.Font.Ch + spc + tv.VcsMarkerWidth() + tv.BlameWidth() // space for icon
 	} else {
 		tv.ClearFlag(int(TextViewHasLineNos))
 		tv.LineNoOff = 0
@@ -3263,38 +3473,52 @@ func (tv *TextView) RenderAllLinesInBounds() {
 	pos = tv.RenderStartPos()
 	stln := -1
 	edln := -1
-	for ln := 0; ln < tv.NLines; ln++ {
-		lst := pos.Y + tv.Offs[ln]
-		led := lst + math32.Max(tv.Renders[ln].Size.Y, tv.LineHeight)
-		if int(math32.Ceil(led)) < tv.VpBBox.Min.Y {
-			continue
-		}
-		if int(math32.Floor(lst)) > tv.VpBBox.Max.Y {
-			continue
+	if tv.Buf != nil && tv.Buf.IsLarge() && tv.LineHeight > 0 { // fixed line heights
+		stln = ints.MaxInt(int((float32(tv.VpBBox.Min.Y)-pos.Y)/tv.LineHeight), 0)
+		edln = ints.MinInt(int((float32(tv.VpBBox.Max.Y)-pos.Y)/tv.LineHeight), tv.NLines-1)
+		if stln > edln {
+			stln, edln = -1, -1
 		}
-		if stln < 0 {
-			stln = ln
+	} else {
+		for ln := 0; ln < tv.NLines; ln++ {
+			lst := pos.Y + tv.LineOff(ln)
+			led := lst + math32.Max(tv.LineRender(ln).Size.Y, tv.LineHeight)
+			if int(math32.Ceil(led)) < tv.VpBBox.Min.Y {
+				continue
+			}
+			if int(math32.Floor(lst)) > tv.VpBBox.Max.Y {
+				continue
+			}
+			if stln < 0 {
+				stln = ln
+			}
+			edln = ln
 		}
-		edln = ln
 	}
 
 	if stln < 0 || edln < 0 { // shouldn't happen.
 		rs.Unlock()
 		return
 	}
+	tv.LayoutVisLines(stln, edln)
 
 	if tv.HasLineNos() {
 		tv.RenderLineNosBoxAll()
 
 		for ln := stln; ln <= edln; ln++ {
+			if tv.LineFolded(ln) {
+				continue
+			}
 			tv.RenderLineNo(ln)
 		}
 	}
 
+	tv.RenderLineColors(stln, edln)
 	tv.RenderDepthBg(stln, edln)
 	tv.RenderHighlights(stln, edln)
 	tv.RenderScopelights(stln, edln)
 	tv.RenderSelect()
+	tv.RenderDiags(stln, edln)
 	if tv.HasLineNos() {
 		tbb := tv.VpBBox
 		tbb.Min.X += int(tv.LineNoOff)
@@ -3303,16 +3527,20 @@ func (tv *TextView) RenderAllLinesInBounds() {
 		rs.Lock()
 	}
 	for ln := stln; ln <= edln; ln++ {
-		lst := pos.Y + tv.Offs[ln]
+		if tv.LineFolded(ln) {
+			continue
+		}
+		lst := pos.Y + tv.LineOff(ln)
 		lp := pos
 		lp.Y = lst
 		lp.X += tv.LineNoOff
-		tv.Renders[ln].Render(rs, lp) // not top pos -- already has baseline offset
+		tv.LineRender(ln).Render(rs, lp) // not top pos -- already has baseline offset
 	}
 	rs.Unlock()
 	if tv.HasLineNos() {
 		rs.PopBounds()
 	}
+	tv.RenderVimStatus()
 }
 
 // RenderLineNosBoxAll renders the background for the line numbers in a darker shade
@@ -3370,6 +3598,10 @@ func (tv *TextView) RenderLineNo(ln int) {
 	pos.Y = lst + gi.FixedToFloat32(sty.Font.Face.Metrics().Ascent) - +gi.FixedToFloat32(sty.Font.Face.Metrics().Descent)
 	pos.X = float32(tv.VpBBox.Min.X) + spc
 	tv.LineNoRender.Render(rs, pos)
+	tv.RenderFoldMarker(ln)
+	tv.RenderDiagIcon(ln)
+	tv.RenderVcsMarker(ln)
+	tv.RenderBlame(ln)
 	// if ic, ok := tv.LineIcons[ln]; ok {
 	// 	// todo: render icon!
 	// }
@@ -3417,7 +3649,7 @@ func (tv *TextView) RenderLines(st, ed int) bool {
 	visEd := -1
 	for ln := st; ln <= ed; ln++ {
 		lst := tv.CharStartPos(TextPos{Ln: ln}).Y // note: charstart pos includes descent
-		led := lst + math32.Max(tv.Renders[ln].Size.Y, tv.LineHeight)
+		led := lst + math32.Max(tv.LineRender(ln).Size.Y, tv.LineHeight)
 		if int(math32.Ceil(led)) < tv.VpBBox.Min.Y {
 			continue
 		}
@@ -3435,20 +3667,26 @@ func (tv *TextView) RenderLines(st, ed int) bool {
 		boxMax = lp
 	}
 	if !(visSt < 0 && visEd < 0) {
+		tv.LayoutVisLines(visSt, visEd)
 		rs.Lock()
 		boxMin.X = float32(tv.VpBBox.Min.X) // go all the way
 		boxMax.X = float32(tv.VpBBox.Max.X) // go all the way
 		pc.FillBox(rs, boxMin, boxMax.Sub(boxMin), &sty.Font.BgColor)
 		// fmt.Printf("lns: st: %v ed: %v vis st: %v ed %v box: min %v max: %v\n", st, ed, visSt, visEd, boxMin, boxMax)
 
+		tv.RenderLineColors(visSt, visEd)
 		tv.RenderDepthBg(visSt, visEd)
 		tv.RenderHighlights(visSt, visEd)
 		tv.RenderScopelights(visSt, visEd)
 		tv.RenderSelect()
+		tv.RenderDiags(visSt, visEd)
 		tv.RenderLineNosBox(visSt, visEd)
 
 		if tv.HasLineNos() {
 			for ln := visSt; ln <= visEd; ln++ {
+				if tv.LineFolded(ln) {
+					continue
+				}
 				tv.RenderLineNo(ln)
 			}
 			tbb := tv.VpBBox
@@ -3458,11 +3696,14 @@ func (tv *TextView) RenderLines(st, ed int) bool {
 			rs.Lock()
 		}
 		for ln := visSt; ln <= visEd; ln++ {
-			lst := pos.Y + tv.Offs[ln]
+			if tv.LineFolded(ln) {
+				continue
+			}
+			lst := pos.Y + tv.LineOff(ln)
 			lp := pos
 			lp.Y = lst
 			lp.X += tv.LineNoOff
-			tv.Renders[ln].Render(rs, lp) // not top pos -- already has baseline offset
+			tv.LineRender(ln).Render(rs, lp) // not top pos -- already has baseline offset
 		}
 		rs.Unlock()
 		if tv.HasLineNos() {
@@ -3474,6 +3715,9 @@ func (tv *TextView) RenderLines(st, ed int) bool {
 		tWinBBox := tv.WinBBox.Add(vprel)
 		vp.Win.UploadVpRegion(vp, tBBox, tWinBBox)
 		// fmt.Printf("tbbox: %v  twinbbox: %v\n", tBBox, tWinBBox)
+		if vbb := tv.RenderVimStatus(); vbb != image.ZR {
+			vp.Win.UploadVpRegion(vp, vbb, tv.WinBBox.Add(vbb.Min.Sub(tv.VpBBox.Min)))
+		}
 	}
 	tv.PopBounds()
 	tv.RenderScrolls()
@@ -3549,7 +3793,7 @@ func (tv *TextView) PixelToCursor(pt image.Point) TextPos {
 		for ln := stln; ln < tv.NLines; ln++ {
 			ls := tv.CharStartPos(TextPos{Ln: ln}).Y - yoff
 			es := ls
-			es += math32.Max(tv.Renders[ln].Size.Y, tv.LineHeight)
+			es += tv.LineLayoutHeight(ln)
 			if pt.Y >= int(math32.Floor(ls)) && pt.Y < int(math32.Ceil(es)) {
 				got = true
 				cln = ln
@@ -3575,26 +3819,26 @@ func (tv *TextView) PixelToCursor(pt image.Point) TextPos {
 
 	si := 0
 	spoff := 0
-	nspan := len(tv.Renders[cln].Spans)
+	nspan := len(tv.LineRender(cln).Spans)
 	lstY := tv.CharStartPos(TextPos{Ln: cln}).Y - yoff
 	if nspan > 1 {
 		si = int((float32(pt.Y) - lstY) / tv.LineHeight)
 		si = ints.MinInt(si, nspan-1)
 		si = ints.MaxInt(si, 0)
 		for i := 0; i < si; i++ {
-			spoff += len(tv.Renders[cln].Spans[i].Text)
+			spoff += len(tv.LineRender(cln).Spans[i].Text)
 		}
 		// fmt.Printf("si: %v  spoff: %v\n", si, spoff)
 	}
 
 	ri := sc
-	rsz := len(tv.Renders[cln].Spans[si].Text)
+	rsz := len(tv.LineRender(cln).Spans[si].Text)
 	if rsz == 0 {
 		return TextPos{Ln: cln, Ch: spoff}
 	}
 	// fmt.Printf("sc: %v  rsz: %v\n", sc, rsz)
 
-	c, _ := tv.Renders[cln].SpanPosToRuneIdx(si, rsz-1) // end
+	c, _ := tv.LineRender(cln).SpanPosToRuneIdx(si, rsz-1) // end
 	rsp := math32.Floor(tv.CharStartPos(TextPos{Ln: cln, Ch: c}).X - xoff)
 	rep := math32.Ceil(tv.CharEndPos(TextPos{Ln: cln, Ch: c}).X - xoff)
 	if int(rep) < pt.X { // end of line
@@ -3608,7 +3852,7 @@ func (tv *TextView) PixelToCursor(pt image.Point) TextPos {
 	got := false
 	if ri < rsz {
 		for rii := ri; rii < rsz; rii++ {
-			c, _ := tv.Renders[cln].SpanPosToRuneIdx(si, rii)
+			c, _ := tv.LineRender(cln).SpanPosToRuneIdx(si, rii)
 			rsp = math32.Floor(tv.CharStartPos(TextPos{Ln: cln, Ch: c}).X - xoff)
 			rep = math32.Ceil(tv.CharEndPos(TextPos{Ln: cln, Ch: c}).X - xoff)
 			// fmt.Printf("trying c: %v for pt: %v xoff: %v rsp: %v, rep: %v\n", c, pt, xoff, rsp, rep)
@@ -3630,7 +3874,7 @@ func (tv *TextView) PixelToCursor(pt image.Point) TextPos {
 		ri = rsz - 1
 		// fmt.Printf("too big: %v\n", ri)
 		for rii := ri; rii >= 0; rii-- {
-			c, _ := tv.Renders[cln].SpanPosToRuneIdx(si, rii)
+			c, _ := tv.LineRender(cln).SpanPosToRuneIdx(si, rii)
 			rsp := math32.Floor(tv.CharStartPos(TextPos{Ln: cln, Ch: c}).X - xoff)
 			rep := math32.Ceil(tv.CharEndPos(TextPos{Ln: cln, Ch: c}).X - xoff)
 			// fmt.Printf("too big: trying c: %v for pt: %v rsp: %v, rep: %v\n", c, pt, rsp, rep)
@@ -3760,6 +4004,19 @@ func (tv *TextView) KeyInput(kt *key.ChordEvent) {
 	if tv.Buf == nil || tv.Buf.NumLines() == 0 {
 		return
 	}
+	// vim commands come before multiple cursors, which only get the keys
+	// typed in vim insert mode
+	vimOn := tv.VimOn() && !tv.ISearch.On && !tv.QReplace.On
+	vimIns := tv.Vim.Mode == VimInsert
+	if vimOn && !vimIns && tv.VimKeyInput(kt) {
+		return
+	}
+	if (tv.BoxSelect || tv.HasMultiCursors()) && !tv.ISearch.On && !tv.QReplace.On && tv.MultiKeyInput(kt) {
+		return
+	}
+	if vimOn && vimIns && tv.VimKeyInput(kt) {
+		return
+	}
 
 	// cancelAll cancels search, completer, and..
 	cancelAll := func() {
@@ -3877,6 +4134,18 @@ func (tv *TextView) KeyInput(kt *key.ChordEvent) {
 		cancelAll()
 		kt.SetProcessed()
 		tv.SelectAll()
+	case gi.KeyFunMultiCursorNext:
+		cancelAll()
+		kt.SetProcessed()
+		tv.AddCursorNextMatch()
+	case gi.KeyFunMultiCursorLines:
+		cancelAll()
+		kt.SetProcessed()
+		tv.SplitSelectionLines()
+	case gi.KeyFunBoxSelectMode:
+		cancelAll()
+		kt.SetProcessed()
+		tv.BoxSelectModeToggle()
 	case gi.KeyFunCopy:
 		cancelAll()
 		kt.SetProcessed()
@@ -3899,6 +4168,14 @@ func (tv *TextView) KeyInput(kt *key.ChordEvent) {
 		kt.SetProcessed()
 		cancelAll()
 		tv.JumpToLinePrompt()
+	case gi.KeyFunProblemNext:
+		cancelAll()
+		kt.SetProcessed()
+		tv.CursorNextDiag(true)
+	case gi.KeyFunProblemPrev:
+		cancelAll()
+		kt.SetProcessed()
+		tv.CursorPrevDiag(true)
 	case gi.KeyFunHistPrev:
 		cancelAll()
 		kt.SetProcessed()
@@ -4137,14 +4414,14 @@ func (tv *TextView) OpenLink(tl *gi.TextLink) {
 // LinkAt returns link at given cursor position, if one exists there --
 // returns true and the link if there is a link, and false otherwise
 func (tv *TextView) LinkAt(pos TextPos) (*gi.TextLink, bool) {
-	if !(pos.Ln < len(tv.Renders) && len(tv.Renders[pos.Ln].Links) > 0) {
+	if !(pos.Ln < tv.NLines && len(tv.LineRender(pos.Ln).Links) > 0) {
 		return nil, false
 	}
 	cpos := tv.CharStartPos(pos).ToPointCeil()
 	cpos.Y += 2
 	cpos.X += 2
 	lpos := tv.CharStartPos(TextPos{Ln: pos.Ln})
-	rend := &tv.Renders[pos.Ln]
+	rend := tv.LineRender(pos.Ln)
 	for ti := range rend.Links {
 		tl := &rend.Links[ti]
 		tlb := tl.Bounds(rend, lpos)
@@ -4160,7 +4437,7 @@ func (tv *TextView) LinkAt(pos TextPos) (*gi.TextLink, bool) {
 func (tv *TextView) OpenLinkAt(pos TextPos) (*gi.TextLink, bool) {
 	tl, ok := tv.LinkAt(pos)
 	if ok {
-		rend := &tv.Renders[pos.Ln]
+		rend := tv.LineRender(pos.Ln)
 		st, _ := rend.SpanPosToRuneIdx(tl.StartSpan, tl.StartIdx)
 		ed, _ := rend.SpanPosToRuneIdx(tl.EndSpan, tl.EndIdx)
 		reg := NewTextRegion(pos.Ln, st, pos.Ln, ed)
@@ -4184,10 +4461,26 @@ func (tv *TextView) MouseEvent(me *mouse.Event) {
 	}
 	pt := tv.PointToRelPos(me.Pos())
 	newPos := tv.PixelToCursor(pt)
+	if me.Action == mouse.Press {
+		tv.VimUndoGroupEnd() // a click ends the current insert undo step
+	}
 	switch me.Button {
 	case mouse.Left:
 		if me.Action == mouse.Press {
 			me.SetProcessed()
+			if me.HasAnyModifier(key.Alt) && !tv.IsInactive() {
+				tv.AddCursor(newPos)
+				return
+			}
+			tv.MultiCursorsReset()
+			if tv.VcsMarkerAt(pt.X, newPos.Ln) {
+				tv.VcsHunkDialog(newPos.Ln) // click on vcs change marker in gutter
+				return
+			}
+			if tv.HasLineNos() && pt.X < int(tv.LineNoOff) && tv.FoldAt(newPos.Ln) >= 0 {
+				tv.FoldToggle(newPos.Ln) // click on fold marker in gutter
+				return
+			}
 			if _, got := tv.OpenLinkAt(newPos); got {
 			} else {
 				tv.SetCursorFromMouse(pt, newPos, me.SelectMode())
@@ -4231,6 +4524,25 @@ func (tv *TextView) MouseEvent(me *mouse.Event) {
 	}
 }
 
+// HoverEvent shows a tooltip for the text under the mouse, from HoverText
+// (diagnostics, language server info), falling back on the Tooltip
+func (tv *TextView) HoverEvent() {
+	tv.ConnectEvent(oswin.MouseHoverEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
+		me := d.(*mouse.HoverEvent)
+		txf := recv.Embed(KiT_TextView).(*TextView)
+		mpos := me.Pos()
+		tt := txf.HoverText(txf.PixelToCursor(txf.PointToRelPos(mpos)))
+		if tt == "" {
+			tt = txf.Tooltip
+		}
+		if tt == "" {
+			return
+		}
+		me.SetProcessed()
+		gi.PopupTooltip(tt, mpos.X, mpos.Y+int(txf.LineHeight), txf.Viewport, txf.Nm)
+	})
+}
+
 // MouseMoveEvent
 func (tv *TextView) MouseMoveEvent() {
 	if !tv.HasLinks {
@@ -4246,9 +4558,9 @@ func (tv *TextView) MouseMoveEvent() {
 			return
 		}
 		pos := tv.RenderStartPos()
-		pos.Y += tv.Offs[mpos.Ln]
+		pos.Y += tvv.LineOff(mpos.Ln)
 		pos.X += tv.LineNoOff
-		rend := &tvv.Renders[mpos.Ln]
+		rend := tvv.LineRender(mpos.Ln)
 		inLink := false
 		for _, tl := range rend.Links {
 			tlb := tl.Bounds(rend, pos)
@@ -4268,17 +4580,25 @@ func (tv *TextView) MouseMoveEvent() {
 
 // TextViewEvents sets connections between mouse and key events and actions
 func (tv *TextView) TextViewEvents() {
-	tv.HoverTooltipEvent()
+	tv.HoverEvent()
 	tv.MouseMoveEvent()
 	tv.ConnectEvent(oswin.MouseDragEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
 		me := d.(*mouse.DragEvent)
 		me.SetProcessed()
 		txf := recv.Embed(KiT_TextView).(*TextView)
+		pt := txf.PointToRelPos(me.Pos())
+		newPos := txf.PixelToCursor(pt)
+		if txf.BoxSelect || me.HasAnyModifier(key.Alt) {
+			if !txf.BoxSelect {
+				txf.BoxSelectStart(txf.CursorPos)
+			}
+			txf.BoxSelectUpdate(newPos)
+			txf.AutoScroll(pt.Add(txf.WinBBox.Min))
+			return
+		}
 		if !txf.SelectMode {
 			txf.SelectModeToggle()
 		}
-		pt := txf.PointToRelPos(me.Pos())
-		newPos := txf.PixelToCursor(pt)
 		txf.SetCursorFromMouse(pt, newPos, mouse.SelectOne)
 	})
 	tv.ConnectEvent(oswin.MouseEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
@@ -4433,6 +4753,7 @@ func (tv *TextView) FocusChanged2D(change gi.FocusChanges) {
 	switch change {
 	case gi.FocusLost:
 		tv.ClearFlag(int(TextViewFocusActive))
+		tv.VimUndoGroupEnd()
 		// tv.EditDone()
 		tv.UpdateSig()
 		// fmt.Printf("lost focus: %v\n", tv.Nm)
@@ -0,0 +1,107 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"fmt"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/vci"
+)
+
+// TextViewBlameChars is the width of the blame annotations in the line
+// number gutter, in characters: revision, author and date
+var TextViewBlameChars = 32
+
+// SetBlame shows given blame annotations, one per line, in the line number
+// gutter -- they are kept aligned with the lines through edits, with
+// inserted lines left blank, until ClearBlame
+func (tv *TextView) SetBlame(bl vci.Blame) {
+	tv.Blame = bl
+	tv.Refresh()
+}
+
+// ClearBlame removes the blame annotations from the gutter
+func (tv *TextView) ClearBlame() {
+	if tv.Blame == nil {
+		return
+	}
+	tv.Blame = nil
+	tv.Refresh()
+}
+
+// HasBlame returns true if blame annotations are shown in the gutter
+func (tv *TextView) HasBlame() bool {
+	return tv.Blame != nil
+}
+
+// BlameWidth returns the extra width of the gutter for the blame
+// annotations, if any
+func (tv *TextView) BlameWidth() float32 {
+	if tv.Blame == nil {
+		return 0
+	}
+	return float32(TextViewBlameChars) * tv.Sty.Font.Ch
+}
+
+// BlameAdjust keeps the blame annotations aligned with the lines after
+// given edit that inserts or deletes lines
+func (tv *TextView) BlameAdjust(tbe *TextBufEdit) {
+	nl := tbe.Reg.End.Ln - tbe.Reg.Start.Ln
+	if tv.Blame == nil || nl == 0 {
+		return
+	}
+	st := tbe.Reg.Start.Ln + 1
+	if st > len(tv.Blame) {
+		return
+	}
+	if tbe.Delete {
+		ed := st + nl
+		if ed > len(tv.Blame) {
+			ed = len(tv.Blame)
+		}
+		tv.Blame = append(tv.Blame[:st], tv.Blame[ed:]...)
+		return
+	}
+	tv.Blame = append(tv.Blame[:st], append(make(vci.Blame, nl), tv.Blame[st:]...)...)
+}
+
+// BlameString returns the blame annotation for given line, as shown in the
+// gutter -- empty for lines that are not committed
+func (tv *TextView) BlameString(ln int) string {
+	if ln < 0 || ln >= len(tv.Blame) || tv.Blame[ln].Rev == "" {
+		return ""
+	}
+	bl := &tv.Blame[ln]
+	rev := bl.Rev
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	auth := []rune(bl.Author)
+	if len(auth) > 12 {
+		auth = auth[:12]
+	}
+	return fmt.Sprintf("%-7s %-12s %s", rev, string(auth), bl.Date.Format("2006-01-02"))
+}
+
+// RenderBlame renders the blame annotation for given line in the gutter,
+// after the line number and icons -- called within context of other render
+func (tv *TextView) RenderBlame(ln int) {
+	bs := tv.BlameString(ln)
+	if bs == "" {
+		return
+	}
+	sty := &tv.Sty
+	spc := sty.BoxSpace()
+	fst := sty.Font
+	fst.BgColor.SetColor(nil)
+	rs := &tv.Viewport.Render
+	tv.LineNoRender.SetString(bs, &fst, &sty.UnContext, &sty.Text, true, 0, 0)
+	pos := tv.RenderStartPos()
+	lst := tv.CharStartPos(TextPos{Ln: ln}).Y // note: charstart pos includes descent
+	pos.Y = lst + gi.FixedToFloat32(sty.Font.Face.Metrics().Ascent) - +gi.FixedToFloat32(sty.Font.Face.Metrics().Descent)
+	pos.X = float32(tv.VpBBox.Min.X) + spc + float32(tv.LineNoDigs+3)*sty.Font.Ch + tv.VcsMarkerWidth()
+	tv.LineNoRender.Render(rs, pos)
+}
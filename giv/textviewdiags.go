@@ -0,0 +1,208 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"strings"
+
+	"github.com/goki/gi/gi"
+)
+
+// DiagColors are the colors for squiggly underlines and gutter icons for
+// each diagnostic severity
+var DiagColors = [DiagSeveritiesN]gi.Color{
+	DiagError:   {R: 230, G: 0, B: 0, A: 255},
+	DiagWarning: {R: 230, G: 160, B: 0, A: 255},
+	DiagInfo:    {R: 0, G: 120, B: 230, A: 255},
+	DiagHint:    {R: 128, G: 128, B: 128, A: 255},
+}
+
+// DiagColor returns the color for given severity
+func DiagColor(sev DiagSeverities) gi.Color {
+	if sev < 0 || sev >= DiagSeveritiesN {
+		sev = DiagError
+	}
+	return DiagColors[sev]
+}
+
+// HoverText returns the text to show in a tooltip for given position: the
+// diagnostic messages if there are any there, otherwise the language server
+// hover info, if available
+func (tv *TextView) HoverText(pos TextPos) string {
+	if tv.Buf == nil || pos.Ln >= tv.NLines || pos.Ch >= tv.Buf.LineLen(pos.Ln) {
+		return ""
+	}
+	tb := tv.Buf
+	if ds := tb.DiagsAt(pos); len(ds) > 0 {
+		return DiagsText(ds)
+	}
+	if tb.LSP == nil {
+		return ""
+	}
+	hv, _, err := tb.LSP.Hover(tb.LSPURI, tb.LSPPos(pos))
+	if err != nil {
+		return ""
+	}
+	return hv
+}
+
+// DiagsText returns the diagnostics as text, one per line
+func DiagsText(ds []TextDiag) string {
+	strs := make([]string, len(ds))
+	for i := range ds {
+		strs[i] = ds[i].String()
+	}
+	return strings.Join(strs, "\n")
+}
+
+// CursorNextDiag moves cursor to the start of the next diagnostic, and shows
+// its message. wraparound wraps around to top of buffer if none found --
+// returns true if found
+func (tv *TextView) CursorNextDiag(wraparound bool) bool {
+	if tv.NLines == 0 || tv.Buf == nil {
+		return false
+	}
+	tv.ValidateCursor()
+	d, has := tv.Buf.NextDiag(tv.CursorPos)
+	if !has {
+		if !wraparound {
+			return false
+		}
+		d, has = tv.Buf.NextDiag(TextPos{Ln: -1}) // wraparound
+		if !has {
+			return false
+		}
+	}
+	tv.CursorToDiag(d)
+	return true
+}
+
+// CursorPrevDiag moves cursor to the start of the previous diagnostic, and
+// shows its message. wraparound wraps around to bottom of buffer if none
+// found -- returns true if found
+func (tv *TextView) CursorPrevDiag(wraparound bool) bool {
+	if tv.NLines == 0 || tv.Buf == nil {
+		return false
+	}
+	tv.ValidateCursor()
+	d, has := tv.Buf.PrevDiag(tv.CursorPos)
+	if !has {
+		if !wraparound {
+			return false
+		}
+		d, has = tv.Buf.PrevDiag(TextPos{Ln: tv.NLines}) // wraparound
+		if !has {
+			return false
+		}
+	}
+	tv.CursorToDiag(d)
+	return true
+}
+
+// CursorToDiag moves the cursor to the start of given diagnostic, and pops
+// up a tooltip with all the diagnostics there
+func (tv *TextView) CursorToDiag(d TextDiag) {
+	updt := tv.Viewport.Win.UpdateStart()
+	tv.SetCursorShow(d.Reg.Start)
+	tv.SavePosHistory(tv.CursorPos)
+	tv.Viewport.Win.UpdateEnd(updt)
+	ds := tv.Buf.DiagsAt(d.Reg.Start)
+	if len(ds) == 0 {
+		return
+	}
+	pos := tv.CharStartPos(d.Reg.Start).ToPointFloor()
+	pos.Y += int(tv.LineHeight)
+	gi.PopupTooltip(DiagsText(ds), pos.X, pos.Y, tv.Viewport, tv.Nm)
+}
+
+// RenderDiags renders squiggly underlines for the diagnostics within given
+// line range
+// -- always called within context of outer RenderLines or RenderAllLines
+func (tv *TextView) RenderDiags(stln, edln int) {
+	tb := tv.Buf
+	if tb == nil {
+		return
+	}
+	tb.DiagsMu.Lock()
+	diags := make([]TextDiag, len(tb.Diags))
+	copy(diags, tb.Diags)
+	tb.DiagsMu.Unlock()
+	for _, d := range diags {
+		reg := d.Reg
+		if stln >= 0 && (reg.Start.Ln > edln || reg.End.Ln < stln) {
+			continue
+		}
+		tv.RenderSquiggle(reg, DiagColor(d.Severity))
+	}
+}
+
+// RenderSquiggle renders a squiggly underline under given region
+func (tv *TextView) RenderSquiggle(reg TextRegion, clr gi.Color) {
+	rs := &tv.Viewport.Render
+	pc := &rs.Paint
+	ex := float32(tv.VpBBox.Max.X)
+	for ln := reg.Start.Ln; ln <= reg.End.Ln && ln < tv.NLines; ln++ {
+		if tv.LineFolded(ln) {
+			continue
+		}
+		st := TextPos{Ln: ln}
+		if ln == reg.Start.Ln {
+			st.Ch = reg.Start.Ch
+		}
+		ed := TextPos{Ln: ln, Ch: tv.Buf.LineLen(ln)}
+		if ln == reg.End.Ln {
+			ed.Ch = reg.End.Ch
+		}
+		spos := tv.CharStartPos(st)
+		epos := tv.CharStartPos(ed)
+		if epos.Y != spos.Y { // wrapped -- just go to the end
+			epos.X = ex
+		}
+		if epos.X <= spos.X {
+			epos.X = spos.X + tv.FontHeight*0.5
+		}
+		y := spos.Y + tv.LineHeight - 2
+		if int(y) < tv.VpBBox.Min.Y || int(y) > tv.VpBBox.Max.Y {
+			continue
+		}
+		pc.NewSubPath(rs)
+		pc.MoveTo(rs, spos.X, y)
+		up := true
+		for x := spos.X + 2; x <= epos.X; x += 2 {
+			if up {
+				pc.LineTo(rs, x, y-1.5)
+			} else {
+				pc.LineTo(rs, x, y)
+			}
+			up = !up
+		}
+	}
+	pc.StrokeStyle.SetColor(clr)
+	pc.StrokeStyle.Width.Dots = 1
+	pc.Stroke(rs)
+}
+
+// RenderDiagIcon renders a dot in the line number gutter in the color of
+// the most severe diagnostic on given line, if any -- it goes in the space
+// after the line number and fold marker
+func (tv *TextView) RenderDiagIcon(ln int) {
+	if tv.Buf == nil {
+		return
+	}
+	sev, has := tv.Buf.DiagsLineSeverity(ln)
+	if !has {
+		return
+	}
+	sty := &tv.Sty
+	spc := sty.BoxSpace()
+	rs := &tv.Viewport.Render
+	pc := &rs.Paint
+	ch := sty.Font.Ch
+	x := float32(tv.VpBBox.Min.X) + spc + float32(tv.LineNoDigs+2)*ch + 0.4*ch
+	y := tv.CharStartPos(TextPos{Ln: ln}).Y + 0.5*tv.LineHeight
+	pc.DrawCircle(rs, x, y, 0.3*ch)
+	pc.FillStyle.SetColor(DiagColor(sev))
+	pc.Fill(rs)
+}
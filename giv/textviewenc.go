@@ -0,0 +1,105 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"fmt"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// EncodingContextMenu adds actions showing the encoding and line endings of
+// the buffer to the context menu, which choose new ones
+func (tv *TextView) EncodingContextMenu(m *gi.Menu) {
+	tb := tv.Buf
+	if tb == nil {
+		return
+	}
+	m.AddSeparator("sep-enc")
+	enc := TextEncodingNames[tb.Encoding]
+	if tb.BOM {
+		enc += " BOM"
+	}
+	ac := m.AddAction(gi.ActOpts{Label: "Encoding: " + enc + "..."},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.ChooseEncoding()
+		})
+	ac.SetInactiveState(tb.IsLarge())
+	le := LineEndingNames[tb.LineEnding]
+	if tb.MixedLineEnds {
+		le += " (mixed)"
+	}
+	ac = m.AddAction(gi.ActOpts{Label: "Line Endings: " + le + "..."},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.ChooseLineEnding()
+		})
+	ac.SetInactiveState(tb.IsLarge() || tv.IsInactive())
+}
+
+// ChooseEncoding pops up a menu of encodings, and then asks whether to save
+// the file in the chosen one, or re-open the file in it
+func (tv *TextView) ChooseEncoding() {
+	tb := tv.Buf
+	if tb == nil {
+		return
+	}
+	var strs []string
+	for enc := EncUTF8; enc < TextEncodingsN; enc++ {
+		strs = append(strs, TextEncodingNames[enc])
+		if enc == EncUTF8 || enc == EncUTF16LE || enc == EncUTF16BE {
+			strs = append(strs, TextEncodingNames[enc]+" BOM")
+		}
+	}
+	cur := TextEncodingNames[tb.Encoding]
+	if tb.BOM {
+		cur += " BOM"
+	}
+	gi.StringsChooserPopup(strs, cur, tv, func(recv, send ki.Ki, sig int64, data interface{}) {
+		ac := send.(*gi.Action)
+		nm := ac.Text
+		enc, bom := EncUTF8, false
+		for e := EncUTF8; e < TextEncodingsN; e++ {
+			if nm == TextEncodingNames[e] {
+				enc = e
+			} else if nm == TextEncodingNames[e]+" BOM" {
+				enc, bom = e, true
+			}
+		}
+		if tb.Filename == "" || tv.IsInactive() {
+			tb.SetEncoding(enc, bom)
+			return
+		}
+		gi.ChoiceDialog(tv.Viewport, gi.DlgOpts{Title: "Change Encoding",
+			Prompt: fmt.Sprintf("Save the file in %v from now on, or re-open it from disk as %v, e.g., if its encoding was not detected correctly (losing any changes)?", nm, TextEncodingNames[enc])},
+			[]string{"Save As " + nm, "Re-Open As " + TextEncodingNames[enc], "Cancel"},
+			tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+				switch sig {
+				case 0:
+					tb.SetEncoding(enc, bom)
+				case 1:
+					if err := tb.ReopenEncoding(enc); err != nil {
+						gi.PromptDialog(tv.Viewport, gi.DlgOpts{Title: "File could not be Re-Opened", Prompt: err.Error()}, true, false, nil, nil)
+					}
+				}
+			})
+	})
+}
+
+// ChooseLineEnding pops up a menu of line ending styles for saving the file
+func (tv *TextView) ChooseLineEnding() {
+	tb := tv.Buf
+	if tb == nil {
+		return
+	}
+	strs := LineEndingNames[:]
+	gi.StringsChooserPopup(strs, LineEndingNames[tb.LineEnding], tv, func(recv, send ki.Ki, sig int64, data interface{}) {
+		ac := send.(*gi.Action)
+		idx := ac.Data.(int)
+		tb.SetLineEnding(LineEndings(idx))
+	})
+}
@@ -0,0 +1,450 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"sort"
+	"unicode"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/pi/parse"
+)
+
+// TextFold is a foldable region of lines in a TextView: the first line of
+// the region remains visible as the header of the fold, and the remaining
+// lines are hidden when it is folded
+type TextFold struct {
+	Reg    TextRegion `desc:"region of the fold: Start is the end of the header line, and End is the end of the last line hidden by the fold -- tracked through edits"`
+	Folded bool       `desc:"if true, the lines after the header are currently hidden"`
+}
+
+// StLn returns the header line of the fold
+func (tf *TextFold) StLn() int {
+	return tf.Reg.Start.Ln
+}
+
+// EdLn returns the last line of the fold
+func (tf *TextFold) EdLn() int {
+	return tf.Reg.End.Ln
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   TextBuf fold regions
+
+// FoldRegions returns the regions of lines that can be folded, sorted by
+// starting line, with at most one region starting on any line: Start.Ln is
+// the header line and End.Ln is the last line of the region.  If the pi
+// parser is being used, regions come from the multi-line nodes of the
+// parsed Ast, and otherwise they are computed from indentation -- see
+// FoldRegionsIndent.  There are none in large-file mode, as that would
+// require loading all the lines.
+func (tb *TextBuf) FoldRegions() []TextRegion {
+	if tb.IsLarge() {
+		return nil
+	}
+	if tb.Hi.UsingPi() {
+		tb.MarkupMu.RLock()
+		regs := tb.FoldRegionsAst(&tb.PiState.Ast)
+		tb.MarkupMu.RUnlock()
+		if len(regs) > 0 {
+			return regs
+		}
+	}
+	return tb.FoldRegionsIndent()
+}
+
+// FoldRegionsAst returns fold regions for all the nodes in the given
+// pi parse Ast that span multiple lines -- a trailing line with only a
+// closing bracket remains visible after the fold
+func (tb *TextBuf) FoldRegionsAst(ast *parse.Ast) []TextRegion {
+	ends := make(map[int]int)
+	nln := tb.NumLines()
+	var walk func(an *parse.Ast)
+	walk = func(an *parse.Ast) {
+		st := an.SrcReg.St.Ln
+		ed := an.SrcReg.Ed.Ln
+		if ed >= nln {
+			ed = nln - 1
+		}
+		if ed > st && tb.foldCloseLine(ed) {
+			ed--
+		}
+		if ed > st && st >= 0 {
+			if ced, has := ends[st]; !has || ed > ced {
+				ends[st] = ed
+			}
+		}
+		for i := range an.Kids {
+			if ch, ok := an.ChildAst(i); ok {
+				walk(ch)
+			}
+		}
+	}
+	for i := range ast.Kids {
+		if ch, ok := ast.ChildAst(i); ok {
+			walk(ch)
+		}
+	}
+	return tb.foldRegionsFromEnds(ends)
+}
+
+// FoldRegionsIndent returns fold regions based on indentation: each line
+// followed by more-indented lines starts a region extending through the
+// last of those lines (blank lines within the region are included, and
+// trailing blank lines are not)
+func (tb *TextBuf) FoldRegionsIndent() []TextRegion {
+	nln := tb.NumLines()
+	ind := make([]int, nln)
+	for ln := 0; ln < nln; ln++ {
+		ind[ln] = tb.foldIndentWidth(ln)
+	}
+	ends := make(map[int]int)
+	for ln := 0; ln < nln; ln++ {
+		if ind[ln] < 0 {
+			continue
+		}
+		ed := ln
+		for nl := ln + 1; nl < nln; nl++ {
+			if ind[nl] < 0 {
+				continue
+			}
+			if ind[nl] <= ind[ln] {
+				break
+			}
+			ed = nl
+		}
+		if ed > ln {
+			ends[ln] = ed
+		}
+	}
+	return tb.foldRegionsFromEnds(ends)
+}
+
+// foldRegionsFromEnds returns the sorted fold regions for given map of
+// start to end lines
+func (tb *TextBuf) foldRegionsFromEnds(ends map[int]int) []TextRegion {
+	regs := make([]TextRegion, 0, len(ends))
+	for st, ed := range ends {
+		regs = append(regs, TextRegion{Start: TextPos{Ln: st, Ch: tb.LineLen(st)}, End: TextPos{Ln: ed, Ch: tb.LineLen(ed)}})
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].Start.Ln < regs[j].Start.Ln
+	})
+	return regs
+}
+
+// foldIndentWidth returns the width of the leading white space on given
+// line, with tabs counting as the tab size -- -1 for blank lines
+func (tb *TextBuf) foldIndentWidth(ln int) int {
+	tabSz := tb.Opts.TabSize
+	if tabSz <= 0 {
+		tabSz = 4
+	}
+	wd := 0
+	for _, r := range tb.Line(ln) {
+		switch {
+		case r == '\t':
+			wd += tabSz
+		case unicode.IsSpace(r):
+			wd++
+		default:
+			return wd
+		}
+	}
+	return -1
+}
+
+// foldCloseLine returns true if given line has only closing brackets (and
+// punctuation following them, e.g., "})" or "},")
+func (tb *TextBuf) foldCloseLine(ln int) bool {
+	got := false
+	for _, r := range tb.Line(ln) {
+		switch {
+		case unicode.IsSpace(r):
+		case r == '}' || r == ')' || r == ']':
+			got = true
+		case got && (r == ',' || r == ';'):
+		default:
+			return false
+		}
+	}
+	return got
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   TextView folds
+
+// FoldsUpdate recomputes the fold regions from the buffer, keeping the
+// folded state of existing folds that start on the same line
+func (tv *TextView) FoldsUpdate() {
+	if tv.Buf == nil {
+		tv.Folds = nil
+		tv.foldsHiddenUpdate()
+		return
+	}
+	folded := make(map[int]bool)
+	for _, f := range tv.Folds {
+		if f.Folded {
+			folded[f.StLn()] = true
+		}
+	}
+	regs := tv.Buf.FoldRegions()
+	tv.Folds = make([]TextFold, len(regs))
+	for i, reg := range regs {
+		tv.Folds[i] = TextFold{Reg: reg, Folded: folded[reg.Start.Ln]}
+	}
+	tv.foldsHiddenUpdate()
+}
+
+// FoldsAdjust adjusts the fold regions for given buffer edit -- folds whose
+// lines are deleted are removed
+func (tv *TextView) FoldsAdjust(tbe *TextBufEdit) {
+	if len(tv.Folds) == 0 {
+		return
+	}
+	nf := tv.Folds[:0]
+	for _, f := range tv.Folds {
+		f.Reg = tbe.AdjustReg(f.Reg)
+		if f.Reg.IsNil() || f.EdLn() <= f.StLn() {
+			continue
+		}
+		nf = append(nf, f)
+	}
+	tv.Folds = nf
+	tv.foldsHiddenUpdate()
+}
+
+// FoldAt returns the index of the fold starting at given line, -1 if none
+func (tv *TextView) FoldAt(ln int) int {
+	idx := sort.Search(len(tv.Folds), func(i int) bool {
+		return tv.Folds[i].StLn() >= ln
+	})
+	if idx < len(tv.Folds) && tv.Folds[idx].StLn() == ln {
+		return idx
+	}
+	return -1
+}
+
+// FoldEnclosing returns the index of the innermost fold containing given
+// line (including its header line), -1 if none
+func (tv *TextView) FoldEnclosing(ln int) int {
+	fi := -1
+	for i := range tv.Folds {
+		f := &tv.Folds[i]
+		if f.StLn() > ln {
+			break
+		}
+		if f.EdLn() >= ln {
+			fi = i
+		}
+	}
+	return fi
+}
+
+// foldsHiddenUpdate updates the sorted, non-overlapping ranges of lines
+// hidden by the folded regions, used by LineFolded -- must be called
+// whenever the folds or their folded state change
+func (tv *TextView) foldsHiddenUpdate() {
+	tv.foldHidden = tv.foldHidden[:0]
+	for i := range tv.Folds {
+		f := &tv.Folds[i]
+		if !f.Folded {
+			continue
+		}
+		st, ed := f.StLn()+1, f.EdLn()
+		if nh := len(tv.foldHidden); nh > 0 && st <= tv.foldHidden[nh-1].End.Ln+1 {
+			if ed > tv.foldHidden[nh-1].End.Ln {
+				tv.foldHidden[nh-1].End.Ln = ed
+			}
+			continue
+		}
+		tv.foldHidden = append(tv.foldHidden, TextRegion{Start: TextPos{Ln: st}, End: TextPos{Ln: ed}})
+	}
+}
+
+// LineFolded returns true if given line is hidden within a folded region
+func (tv *TextView) LineFolded(ln int) bool {
+	idx := sort.Search(len(tv.foldHidden), func(i int) bool {
+		return tv.foldHidden[i].End.Ln >= ln
+	})
+	return idx < len(tv.foldHidden) && tv.foldHidden[idx].Start.Ln <= ln
+}
+
+// HasFolded returns true if any region is currently folded
+func (tv *TextView) HasFolded() bool {
+	for i := range tv.Folds {
+		if tv.Folds[i].Folded {
+			return true
+		}
+	}
+	return false
+}
+
+// LineLayoutHeight returns the height of given line in the layout -- zero
+// if the line is hidden in a folded region
+func (tv *TextView) LineLayoutHeight(ln int) float32 {
+	if tv.LineFolded(ln) {
+		return 0
+	}
+	if tv.Buf != nil && tv.Buf.IsLarge() {
+		return tv.LineHeight
+	}
+	return gi.Max32(tv.LineRender(ln).Size.Y, tv.LineHeight)
+}
+
+// FoldSkipDown returns the first line at or after given one that is not
+// hidden in a folded region -- the last visible line if none after
+func (tv *TextView) FoldSkipDown(ln int) int {
+	for ln < tv.NLines && tv.LineFolded(ln) {
+		ln++
+	}
+	if ln >= tv.NLines {
+		ln = tv.FoldSkipUp(tv.NLines - 1)
+	}
+	return ln
+}
+
+// FoldSkipUp returns the first line at or before given one that is not
+// hidden in a folded region
+func (tv *TextView) FoldSkipUp(ln int) int {
+	for ln > 0 && tv.LineFolded(ln) {
+		ln--
+	}
+	return ln
+}
+
+// FoldsRelayout recomputes the line offsets after folds have changed, and
+// re-renders
+func (tv *TextView) FoldsRelayout() {
+	if tv.Renders == nil || tv.NLines == 0 || tv.Buf == nil || tv.Buf.IsLarge() { // no folds in large-file mode
+		return
+	}
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	off := float32(0)
+	for ln := 0; ln < tv.NLines; ln++ {
+		tv.Offs[ln] = off
+		off += tv.LineLayoutHeight(ln)
+	}
+	extraHalf := tv.LineHeight * 0.5 * float32(tv.VisSize.Y)
+	nwSz := gi.Vec2D{float32(tv.LinesSize.X), off + extraHalf}.ToPointCeil()
+	tv.ResizeIfNeeded(nwSz)
+	tv.RenderAllLines()
+}
+
+// FoldSet sets the folded state of the fold at given index
+func (tv *TextView) FoldSet(idx int, folded bool) {
+	if idx < 0 || idx >= len(tv.Folds) || tv.Folds[idx].Folded == folded {
+		return
+	}
+	tv.Folds[idx].Folded = folded
+	tv.foldsHiddenUpdate()
+	if folded && tv.LineFolded(tv.CursorPos.Ln) {
+		tv.CursorPos = TextPos{Ln: tv.Folds[idx].StLn()}
+	}
+	tv.FoldsRelayout()
+	tv.RenderCursor(true)
+}
+
+// FoldToggle toggles the folded state of the fold starting at given line,
+// or if there is none, the innermost fold containing that line -- returns
+// false if there is no such fold
+func (tv *TextView) FoldToggle(ln int) bool {
+	idx := tv.FoldAt(ln)
+	if idx < 0 {
+		idx = tv.FoldEnclosing(ln)
+	}
+	if idx < 0 {
+		return false
+	}
+	tv.FoldSet(idx, !tv.Folds[idx].Folded)
+	return true
+}
+
+// FoldToggleAtCursor toggles the fold at the cursor line
+func (tv *TextView) FoldToggleAtCursor() {
+	tv.FoldToggle(tv.CursorPos.Ln)
+}
+
+// FoldAll folds all the fold regions, after updating them from the buffer
+func (tv *TextView) FoldAll() {
+	tv.FoldsUpdate()
+	for i := range tv.Folds {
+		tv.Folds[i].Folded = true
+	}
+	tv.foldsHiddenUpdate()
+	if tv.LineFolded(tv.CursorPos.Ln) {
+		idx := tv.FoldEnclosing(tv.CursorPos.Ln)
+		for idx >= 0 && tv.LineFolded(tv.Folds[idx].StLn()) {
+			idx = tv.FoldEnclosing(tv.Folds[idx].StLn() - 1)
+		}
+		if idx >= 0 {
+			tv.CursorPos = TextPos{Ln: tv.Folds[idx].StLn()}
+		} else {
+			tv.CursorPos = TextPos{Ln: tv.FoldSkipUp(tv.CursorPos.Ln)}
+		}
+	}
+	tv.FoldsRelayout()
+	tv.SetCursorShow(tv.CursorPos)
+}
+
+// UnfoldAll unfolds all the folded regions
+func (tv *TextView) UnfoldAll() {
+	if !tv.HasFolded() {
+		return
+	}
+	for i := range tv.Folds {
+		tv.Folds[i].Folded = false
+	}
+	tv.foldsHiddenUpdate()
+	tv.FoldsRelayout()
+	tv.SetCursorShow(tv.CursorPos)
+}
+
+// UnfoldLine unfolds any folded regions hiding given line, so that it is
+// visible -- returns true if anything was unfolded
+func (tv *TextView) UnfoldLine(ln int) bool {
+	got := false
+	for i := range tv.Folds {
+		f := &tv.Folds[i]
+		if f.StLn() >= ln {
+			break
+		}
+		if f.Folded && f.EdLn() >= ln {
+			f.Folded = false
+			got = true
+		}
+	}
+	if got {
+		tv.foldsHiddenUpdate()
+		tv.FoldsRelayout()
+	}
+	return got
+}
+
+// RenderFoldMarker renders the fold marker in the line number gutter for
+// given line, if it starts a fold: + if folded and - if not -- called
+// within context of other render, after the line number
+func (tv *TextView) RenderFoldMarker(ln int) {
+	idx := tv.FoldAt(ln)
+	if idx < 0 {
+		return
+	}
+	sty := &tv.Sty
+	spc := sty.BoxSpace()
+	fst := sty.Font
+	fst.BgColor.SetColor(nil)
+	rs := &tv.Viewport.Render
+	mk := "-"
+	if tv.Folds[idx].Folded {
+		mk = "+"
+	}
+	tv.LineNoRender.SetString(mk, &fst, &sty.UnContext, &sty.Text, true, 0, 0)
+	pos := tv.RenderStartPos()
+	lst := tv.CharStartPos(TextPos{Ln: ln}).Y // note: charstart pos includes descent
+	pos.Y = lst + gi.FixedToFloat32(sty.Font.Face.Metrics().Ascent) - +gi.FixedToFloat32(sty.Font.Face.Metrics().Descent)
+	pos.X = float32(tv.VpBBox.Min.X) + spc + float32(tv.LineNoDigs+1)*sty.Font.Ch
+	tv.LineNoRender.Render(rs, pos)
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const foldTestText = `func a() {
+	if x {
+		y()
+	}
+	z()
+}
+
+func b() {
+	w()
+}
+`
+
+// lineFoldedScan is LineFolded computed directly from the folds
+func lineFoldedScan(tv *TextView, ln int) bool {
+	for _, f := range tv.Folds {
+		if f.Folded && f.StLn() < ln && f.EdLn() >= ln {
+			return true
+		}
+	}
+	return false
+}
+
+func checkLineFolded(t *testing.T, tv *TextView, what string) {
+	t.Helper()
+	for ln := 0; ln < tv.NLines; ln++ {
+		if got, want := tv.LineFolded(ln), lineFoldedScan(tv, ln); got != want {
+			t.Errorf("%v: LineFolded(%v) = %v, want %v", what, ln, got, want)
+		}
+	}
+}
+
+func TestFoldRegionsIndent(t *testing.T) {
+	tv := testTextView(t, foldTestText)
+	regs := tv.Buf.FoldRegionsIndent()
+	want := [][2]int{{0, 4}, {1, 2}, {7, 8}}
+	if len(regs) != len(want) {
+		t.Fatalf("got %v regions, want %v: %v", len(regs), len(want), regs)
+	}
+	for i, reg := range regs {
+		if reg.Start.Ln != want[i][0] || reg.End.Ln != want[i][1] {
+			t.Errorf("region %v: lines %v-%v, want %v-%v", i, reg.Start.Ln, reg.End.Ln, want[i][0], want[i][1])
+		}
+	}
+}
+
+func TestLineFolded(t *testing.T) {
+	tv := testTextView(t, foldTestText)
+	tv.FoldsUpdate()
+	checkLineFolded(t, tv, "none folded")
+
+	tv.FoldToggle(1)
+	checkLineFolded(t, tv, "inner folded")
+	if !tv.LineFolded(2) || tv.LineFolded(1) || tv.LineFolded(3) {
+		t.Errorf("inner fold hides wrong lines")
+	}
+	tv.FoldToggle(0)
+	checkLineFolded(t, tv, "nested folded")
+	if got := tv.FoldSkipDown(1); got != 5 {
+		t.Errorf("FoldSkipDown(1) = %v, want 5", got)
+	}
+	if got := tv.FoldSkipUp(4); got != 0 {
+		t.Errorf("FoldSkipUp(4) = %v, want 0", got)
+	}
+	if !tv.UnfoldLine(2) || tv.LineFolded(2) {
+		t.Errorf("UnfoldLine(2) did not show line 2")
+	}
+	checkLineFolded(t, tv, "unfold line")
+
+	tv.FoldAll()
+	checkLineFolded(t, tv, "fold all")
+	if tv.LineFolded(tv.CursorPos.Ln) {
+		t.Errorf("cursor left on folded line %v", tv.CursorPos.Ln)
+	}
+	tv.UnfoldAll()
+	checkLineFolded(t, tv, "unfold all")
+	if tv.HasFolded() {
+		t.Errorf("folded regions after UnfoldAll")
+	}
+}
+
+func TestLineFoldedAdjust(t *testing.T) {
+	tv := testTextView(t, foldTestText)
+	tv.FoldsUpdate()
+	tv.FoldToggle(7)
+	tbe := tv.Buf.DeleteText(TextPos{Ln: 1}, TextPos{Ln: 4}, true, true)
+	tv.FoldsAdjust(tbe) // view is not visible, so not signaled
+	if f := tv.FoldAt(4); f < 0 || !tv.Folds[f].Folded {
+		t.Fatalf("folded region did not move up with the deleted lines: %v", tv.Folds)
+	}
+	checkLineFolded(t, tv, "after delete")
+	if !tv.LineFolded(5) || tv.LineFolded(6) {
+		t.Errorf("adjusted fold hides wrong lines")
+	}
+}
+
+func TestLineFoldedRandom(t *testing.T) {
+	tv := testTextView(t, foldTestText)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		nf := rnd.Intn(6)
+		tv.Folds = tv.Folds[:0]
+		st := 0
+		for j := 0; j < nf && st < tv.NLines-1; j++ {
+			ed := st + 1 + rnd.Intn(tv.NLines-st-1)
+			tv.Folds = append(tv.Folds, TextFold{Reg: TextRegion{Start: TextPos{Ln: st}, End: TextPos{Ln: ed}}, Folded: rnd.Intn(2) == 0})
+			st += rnd.Intn(3)
+		}
+		tv.foldsHiddenUpdate()
+		checkLineFolded(t, tv, "random")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/lsp"
+	"github.com/goki/ki/ki"
+)
+
+// HasLSP returns true if the buffer is open in a language server
+func (tv *TextView) HasLSP() bool {
+	return tv.Buf != nil && tv.Buf.LSP != nil
+}
+
+// LSPDefinition moves the cursor to the definition of the symbol at the
+// cursor, using the language server -- if it is in another file, the
+// location is sent as a file:// link with the region, using OpenLink
+func (tv *TextView) LSPDefinition() bool {
+	if !tv.HasLSP() {
+		return false
+	}
+	tb := tv.Buf
+	locs, err := tb.LSP.Definition(tb.LSPURI, tb.LSPPos(tv.CursorPos))
+	if err != nil {
+		log.Printf("giv.TextView LSPDefinition: %v\n", err)
+		return false
+	}
+	if len(locs) == 0 {
+		return false
+	}
+	loc := locs[0]
+	if lsp.URIFile(loc.URI) == lsp.URIFile(tb.LSPURI) {
+		reg := tb.LSPRegion(loc.Range)
+		tv.SavePosHistory(tv.CursorPos)
+		tv.SetCursorShow(reg.Start)
+		tv.SavePosHistory(reg.Start)
+		return true
+	}
+	tl := &gi.TextLink{URL: loc.URI + lspRangeLink(loc.Range)}
+	tv.OpenLink(tl)
+	return true
+}
+
+// lspRangeLink returns the #LxxCxx-LxxCxx link suffix for given range --
+// characters are used as-is, as the file is not open to convert them
+func lspRangeLink(rg lsp.Range) string {
+	return fmt.Sprintf("#L%dC%d-L%dC%d", rg.Start.Line+1, rg.Start.Character+1, rg.End.Line+1, rg.End.Character+1)
+}
+
+// LSPReferences finds all the references to the symbol at the cursor using
+// the language server, highlights those in this buffer, and returns all of
+// them, including those in other files
+func (tv *TextView) LSPReferences() []lsp.Location {
+	if !tv.HasLSP() {
+		return nil
+	}
+	tb := tv.Buf
+	locs, err := tb.LSP.References(tb.LSPURI, tb.LSPPos(tv.CursorPos), true)
+	if err != nil {
+		log.Printf("giv.TextView LSPReferences: %v\n", err)
+		return nil
+	}
+	fname := lsp.URIFile(tb.LSPURI)
+	var hi []TextRegion
+	for _, loc := range locs {
+		if lsp.URIFile(loc.URI) == fname {
+			hi = append(hi, tb.LSPRegion(loc.Range))
+		}
+	}
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	prevh := tv.Highlights
+	tv.Highlights = hi
+	tv.UpdateHighlights(prevh)
+	return locs
+}
+
+// LSPRename renames the symbol at the cursor to newName everywhere, using
+// the language server -- open buffers are edited in place and other files
+// directly on disk
+func (tv *TextView) LSPRename(newName string) error {
+	if !tv.HasLSP() {
+		return fmt.Errorf("giv.TextView LSPRename: no language server for buffer")
+	}
+	tb := tv.Buf
+	we, err := tb.LSP.Rename(tb.LSPURI, tb.LSPPos(tv.CursorPos), newName)
+	if err != nil {
+		return err
+	}
+	if we == nil {
+		return fmt.Errorf("giv.TextView LSPRename: nothing to rename at cursor")
+	}
+	if !LSPApplyWorkspaceEdit(we) {
+		return fmt.Errorf("giv.TextView LSPRename: not all files could be edited")
+	}
+	return nil
+}
+
+// LSPRenamePrompt prompts for a new name for the symbol at the cursor, and
+// renames it with LSPRename
+func (tv *TextView) LSPRenamePrompt() {
+	if !tv.HasLSP() {
+		return
+	}
+	wr := tv.WordAt()
+	cur := string(tv.Buf.Region(wr.Start, wr.End).ToBytes())
+	gi.StringPromptDialog(tv.Viewport, cur, "New name..",
+		gi.DlgOpts{Title: "Rename Symbol", Prompt: "New name for symbol, in all files where it is used"},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			dlg := send.(*gi.Dialog)
+			if sig == int64(gi.DialogAccepted) {
+				val := gi.StringPromptDialogValue(dlg)
+				if val == "" || val == cur {
+					return
+				}
+				if err := tv.LSPRename(val); err != nil {
+					gi.PromptDialog(tv.Viewport, gi.DlgOpts{Title: "Rename Failed", Prompt: err.Error()}, true, false, nil, nil)
+				}
+			}
+		})
+}
+
+// LSPContextMenu adds the language server actions to the context menu
+func (tv *TextView) LSPContextMenu(m *gi.Menu) {
+	if !tv.HasLSP() {
+		return
+	}
+	m.AddSeparator("sep-lsp")
+	m.AddAction(gi.ActOpts{Label: "Go To Definition"},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.LSPDefinition()
+		})
+	m.AddAction(gi.ActOpts{Label: "Find References"},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.LSPReferences()
+		})
+	ac := m.AddAction(gi.ActOpts{Label: "Rename Symbol..."},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			txf := recv.Embed(KiT_TextView).(*TextView)
+			txf.LSPRenamePrompt()
+		})
+	ac.SetInactiveState(tv.IsInactive())
+}
@@ -0,0 +1,620 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"sort"
+	"unicode"
+
+	"github.com/chewxy/math32"
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/key"
+	"github.com/goki/gi/oswin/mimedata"
+	"github.com/goki/ki/indent"
+	"github.com/goki/ki/ints"
+	"github.com/goki/pi/filecat"
+)
+
+// TextCursor is an additional cursor in a TextView, beyond the main cursor
+// (CursorPos), with its own selection -- see TextView.Cursors
+type TextCursor struct {
+	Pos      TextPos    `desc:"cursor position"`
+	Sel      TextRegion `desc:"selected region for this cursor -- TextRegionNil if none"`
+	SelStart TextPos    `desc:"starting point of the selection, which is extended by shift-movement"`
+	Col      int        `desc:"desired column for up / down movement of this cursor"`
+}
+
+// HasSel returns true if the cursor has a selected region
+func (tc *TextCursor) HasSel() bool {
+	return tc.Sel.Start.IsLess(tc.Sel.End)
+}
+
+// HasMultiCursors returns true if there are additional cursors beyond the
+// main cursor
+func (tv *TextView) HasMultiCursors() bool {
+	return len(tv.Cursors) > 0
+}
+
+// MainCursor returns the state of the main cursor (CursorPos, SelectReg) as a TextCursor
+func (tv *TextView) MainCursor() TextCursor {
+	return TextCursor{Pos: tv.CursorPos, Sel: tv.SelectReg, SelStart: tv.SelectStart, Col: tv.CursorCol}
+}
+
+// AllCursors returns all of the cursors, starting with the main cursor,
+// followed by the additional Cursors -- returns a new slice
+func (tv *TextView) AllCursors() []TextCursor {
+	cs := make([]TextCursor, 0, len(tv.Cursors)+1)
+	cs = append(cs, tv.MainCursor())
+	return append(cs, tv.Cursors...)
+}
+
+// SetAllCursors sets the main cursor from the first element, and the
+// additional Cursors from the rest -- any cursor at the same position as an
+// earlier one is removed, so cursors that run into each other merge
+func (tv *TextView) SetAllCursors(cs []TextCursor) {
+	if len(cs) == 0 {
+		tv.Cursors = nil
+		return
+	}
+	mc := cs[0]
+	tv.CursorPos = tv.Buf.ValidPos(mc.Pos)
+	tv.SelectReg = mc.Sel
+	tv.SelectStart = mc.SelStart
+	tv.CursorCol = mc.Col
+	tv.PrevSelectReg = TextRegionNil
+	var ext []TextCursor
+	for _, c := range cs[1:] {
+		c.Pos = tv.Buf.ValidPos(c.Pos)
+		dup := c.Pos == tv.CursorPos
+		for _, e := range ext {
+			if e.Pos == c.Pos {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			ext = append(ext, c)
+		}
+	}
+	tv.Cursors = ext
+}
+
+// SortedCursors returns all of the cursors in buffer order
+func (tv *TextView) SortedCursors() []TextCursor {
+	cs := tv.AllCursors()
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Pos.IsLess(cs[j].Pos)
+	})
+	return cs
+}
+
+// MultiCursorsReset removes all the additional cursors and their
+// selections, and turns off BoxSelect mode, leaving only the main cursor
+func (tv *TextView) MultiCursorsReset() {
+	tv.BoxSelect = false
+	if !tv.HasMultiCursors() {
+		return
+	}
+	tv.Cursors = nil
+	tv.RenderAllLines()
+}
+
+// AddCursor adds a cursor at given position, which becomes the main cursor,
+// with the previous main cursor kept as an additional one -- if there is
+// already a cursor at that position, it is removed instead (unless it is
+// the only one)
+func (tv *TextView) AddCursor(pos TextPos) {
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	pos = tv.Buf.ValidPos(pos)
+	cs := tv.AllCursors()
+	for i, c := range cs {
+		if c.Pos != pos {
+			continue
+		}
+		if len(cs) > 1 {
+			cs = append(cs[:i], cs[i+1:]...)
+			tv.SetAllCursors(cs)
+		}
+		tv.RenderAllLines()
+		tv.RenderCursor(true)
+		return
+	}
+	nc := TextCursor{Pos: pos, Sel: TextRegionNil, SelStart: pos, Col: pos.Ch}
+	tv.SetAllCursors(append([]TextCursor{nc}, cs...))
+	tv.RenderAllLines()
+	tv.RenderCursor(true)
+}
+
+// AddCursorNextMatch selects the word at the cursor if there is no
+// selection, and otherwise adds a cursor selecting the next occurrence of
+// the selected text after the main cursor (wrapping around at the end),
+// which becomes the main cursor -- selections spanning lines are not
+// supported
+func (tv *TextView) AddCursorNextMatch() {
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	if !tv.HasSelection() {
+		if tv.SelectWord() {
+			tv.CursorPos = tv.SelectReg.End
+			tv.SetCursorCol(tv.CursorPos)
+			tv.RenderSelectLines()
+			tv.RenderCursor(true)
+		}
+		return
+	}
+	if tv.SelectReg.Start.Ln != tv.SelectReg.End.Ln {
+		return
+	}
+	find := tv.Selection().ToBytes()
+	_, matches := tv.Buf.Search(find, false)
+	if len(matches) == 0 {
+		return
+	}
+	cs := tv.AllCursors()
+	taken := func(reg TextRegion) bool {
+		for _, c := range cs {
+			if c.HasSel() && c.Sel.Start == reg.Start {
+				return true
+			}
+		}
+		return false
+	}
+	after := tv.SelectReg.End
+	mi := -1
+	for i := range matches {
+		reg := matches[i].Reg
+		if !reg.Start.IsLess(after) && !taken(reg) {
+			mi = i
+			break
+		}
+	}
+	if mi < 0 { // wrap around
+		for i := range matches {
+			if !taken(matches[i].Reg) {
+				mi = i
+				break
+			}
+		}
+	}
+	if mi < 0 {
+		return
+	}
+	reg := matches[mi].Reg
+	nc := TextCursor{Pos: reg.End, Sel: TextRegion{Start: reg.Start, End: reg.End}, SelStart: reg.Start, Col: reg.End.Ch}
+	tv.SetAllCursors(append([]TextCursor{nc}, cs...))
+	tv.SetCursorShow(tv.CursorPos)
+	tv.RenderAllLines()
+}
+
+// SplitSelectionLines splits the current selection into one cursor for each
+// line it spans, each selecting the part of its line within the selection,
+// with the cursor at the end
+func (tv *TextView) SplitSelectionLines() {
+	if !tv.HasSelection() || tv.SelectReg.Start.Ln == tv.SelectReg.End.Ln {
+		return
+	}
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	sel := tv.SelectReg
+	var cs []TextCursor
+	for ln := sel.End.Ln; ln >= sel.Start.Ln; ln-- {
+		st := TextPos{Ln: ln}
+		ed := TextPos{Ln: ln, Ch: tv.Buf.LineLen(ln)}
+		if ln == sel.Start.Ln {
+			st = sel.Start
+		}
+		if ln == sel.End.Ln {
+			ed = sel.End
+			if ed.Ch == 0 && ln > sel.Start.Ln { // selection ends at start of line
+				continue
+			}
+		}
+		c := TextCursor{Pos: ed, Sel: TextRegionNil, SelStart: st, Col: ed.Ch}
+		if st.IsLess(ed) {
+			c.Sel = TextRegion{Start: st, End: ed}
+		}
+		cs = append(cs, c)
+	}
+	tv.SelectMode = false
+	tv.SetAllCursors(cs)
+	tv.SetCursorShow(tv.CursorPos)
+	tv.RenderAllLines()
+}
+
+// ForEachCursor calls given function once for each cursor, with the main
+// cursor state (CursorPos, SelectReg, etc) set to that cursor, so that any
+// of the standard single-cursor movement and editing functions can be used.
+// Cursors are visited from the end of the buffer backward, and all the
+// other cursors are adjusted for the edits made at each one.  All of the
+// edits are saved as a single undo step.  The function is passed the index
+// of the cursor in buffer order.
+func (tv *TextView) ForEachCursor(fun func(idx int)) {
+	tb := tv.Buf
+	cs := tv.AllCursors()
+	ord := make([]int, len(cs))
+	for i := range ord {
+		ord[i] = i
+	}
+	sort.Slice(ord, func(i, j int) bool {
+		return cs[ord[j]].Pos.IsLess(cs[ord[i]].Pos)
+	})
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tb.UndoGroupStart()
+	for oi, ci := range ord {
+		c := cs[ci]
+		tv.CursorPos = tb.ValidPos(c.Pos)
+		tv.SelectReg = c.Sel
+		tv.SelectStart = c.SelStart
+		tv.CursorCol = c.Col
+		tv.PrevSelectReg = TextRegionNil
+		up := tb.UndoPos
+		fun(len(ord) - 1 - oi)
+		cs[ci] = tv.MainCursor()
+		if tb.UndoPos <= up {
+			continue
+		}
+		for _, tbe := range tb.Undos[up:tb.UndoPos] {
+			for j := range cs {
+				if j == ci {
+					continue
+				}
+				oc := &cs[j]
+				oc.Pos = tbe.AdjustPos(oc.Pos, AdjustPosDelStart)
+				oc.SelStart = tbe.AdjustPos(oc.SelStart, AdjustPosDelStart)
+				if oc.HasSel() {
+					oc.Sel = tbe.AdjustReg(oc.Sel)
+				}
+			}
+		}
+	}
+	tb.UndoGroupEnd()
+	tv.SetAllCursors(cs)
+	tv.SetCursorShow(tv.CursorPos)
+	tv.RenderAllLines()
+}
+
+// MultiSelectionText returns the text of all the cursor selections, in
+// buffer order, separated by newlines -- nil if there are none
+func (tv *TextView) MultiSelectionText() []byte {
+	var b bytes.Buffer
+	n := 0
+	for _, c := range tv.SortedCursors() {
+		if !c.HasSel() {
+			continue
+		}
+		tbe := tv.Buf.Region(c.Sel.Start, c.Sel.End)
+		if tbe == nil {
+			continue
+		}
+		if n > 0 {
+			b.WriteByte('\n')
+		}
+		b.Write(tbe.ToBytes())
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	return b.Bytes()
+}
+
+// MultiCopy copies the text of all the cursor selections to the clipboard,
+// one per line -- see MultiSelectionText
+func (tv *TextView) MultiCopy() []byte {
+	cb := tv.MultiSelectionText()
+	if cb == nil {
+		return nil
+	}
+	TextViewClipHistAdd(cb)
+	oswin.TheApp.ClipBoard(tv.Viewport.Win.OSWin).Write(mimedata.NewTextBytes(cb))
+	return cb
+}
+
+// MultiCut copies the text of all the cursor selections to the clipboard,
+// and then deletes the selections
+func (tv *TextView) MultiCut() {
+	if tv.MultiCopy() == nil {
+		return
+	}
+	tv.ForEachCursor(func(idx int) {
+		if tv.HasSelection() {
+			org := tv.SelectReg.Start
+			tv.DeleteSelection()
+			tv.SetCursor(org)
+		}
+	})
+}
+
+// MultiPaste pastes the text on the clipboard at each cursor -- if the text
+// has the same number of lines as there are cursors, each cursor gets one
+// line, in buffer order, and otherwise each gets all of the text
+func (tv *TextView) MultiPaste() {
+	data := oswin.TheApp.ClipBoard(tv.Viewport.Win.OSWin).Read([]string{filecat.TextPlain})
+	if data == nil {
+		return
+	}
+	tv.MultiInsert(data.TypeData(filecat.TextPlain))
+}
+
+// MultiInsert inserts given text at each cursor, replacing any selections
+// -- if the text has the same number of lines as there are cursors, each
+// cursor gets one line, in buffer order, and otherwise each gets all of the
+// text
+func (tv *TextView) MultiInsert(txt []byte) {
+	lns := bytes.Split(bytes.TrimSuffix(txt, []byte("\n")), []byte("\n"))
+	per := len(lns) == len(tv.Cursors)+1
+	tv.ForEachCursor(func(idx int) {
+		if per {
+			tv.InsertAtCursor(lns[idx])
+		} else {
+			tv.InsertAtCursor(txt)
+		}
+	})
+}
+
+// MultiKeyAction performs the action for given key function at the current
+// (main) cursor -- called by ForEachCursor for each cursor in turn
+func (tv *TextView) MultiKeyAction(kf gi.KeyFuns, kt *key.ChordEvent) {
+	switch kf {
+	case gi.KeyFunMoveRight:
+		tv.ShiftSelect(kt)
+		tv.CursorForward(1)
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunWordRight:
+		tv.ShiftSelect(kt)
+		tv.CursorForwardWord(1)
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunMoveLeft:
+		tv.ShiftSelect(kt)
+		tv.CursorBackward(1)
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunWordLeft:
+		tv.ShiftSelect(kt)
+		tv.CursorBackwardWord(1)
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunMoveUp:
+		tv.ShiftSelect(kt)
+		tv.CursorUp(1)
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunMoveDown:
+		tv.ShiftSelect(kt)
+		tv.CursorDown(1)
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunHome:
+		tv.ShiftSelect(kt)
+		tv.CursorStartLine()
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunEnd:
+		tv.ShiftSelect(kt)
+		tv.CursorEndLine()
+		tv.ShiftSelectExtend(kt)
+	case gi.KeyFunBackspace:
+		tv.CursorBackspace(1)
+	case gi.KeyFunDelete:
+		tv.CursorDelete(1)
+	case gi.KeyFunBackspaceWord:
+		tv.CursorBackspaceWord(1)
+	case gi.KeyFunDeleteWord:
+		tv.CursorDeleteWord(1)
+	case gi.KeyFunKill:
+		tv.CursorKill()
+	case gi.KeyFunEnter:
+		tv.InsertAtCursor([]byte("\n"))
+		if tv.Buf.Opts.AutoIndent {
+			tbe, _, cpos := tv.Buf.AutoIndent(tv.CursorPos.Ln, DefaultIndentStrings, DefaultUnindentStrings)
+			if tbe != nil {
+				tv.SetCursor(TextPos{Ln: tbe.Reg.End.Ln, Ch: cpos})
+			}
+		}
+	case gi.KeyFunFocusNext:
+		tv.InsertAtCursor(indent.Bytes(tv.Buf.Opts.IndentChar(), 1, tv.Sty.Text.TabSize))
+	case gi.KeyFunNil:
+		tv.InsertAtCursor([]byte(string(kt.Rune)))
+	}
+}
+
+// MultiKeyInput handles key input when there are multiple cursors, or in
+// BoxSelect mode: cursor movement and editing keys apply at every cursor,
+// copy, cut and paste work on all cursor selections, and Escape returns to a
+// single cursor -- any other key also returns to a single cursor, and is
+// then processed normally.  Returns true if the key was handled.
+func (tv *TextView) MultiKeyInput(kt *key.ChordEvent) bool {
+	kf := gi.KeyFun(kt.Chord())
+	switch kf {
+	case gi.KeyFunMultiCursorNext, gi.KeyFunMultiCursorLines, gi.KeyFunBoxSelectMode:
+		return false
+	case gi.KeyFunAbort, gi.KeyFunCancelSelect:
+		kt.SetProcessed()
+		tv.CancelComplete()
+		updt := tv.Viewport.Win.UpdateStart()
+		tv.MultiCursorsReset()
+		tv.SelectReset()
+		tv.Viewport.Win.UpdateEnd(updt)
+		return true
+	case gi.KeyFunMoveRight, gi.KeyFunWordRight, gi.KeyFunMoveLeft, gi.KeyFunWordLeft,
+		gi.KeyFunMoveUp, gi.KeyFunMoveDown, gi.KeyFunHome, gi.KeyFunEnd:
+		kt.SetProcessed()
+		tv.CancelComplete()
+		if tv.BoxSelect {
+			tv.BoxSelectMove(kf)
+			return true
+		}
+		tv.ForEachCursor(func(idx int) { tv.MultiKeyAction(kf, kt) })
+		return true
+	case gi.KeyFunCopy:
+		kt.SetProcessed()
+		tv.BoxSelect = false
+		tv.MultiCopy()
+		return true
+	}
+	if tv.IsInactive() {
+		tv.MultiCursorsReset()
+		return false
+	}
+	switch kf {
+	case gi.KeyFunCut:
+		kt.SetProcessed()
+		tv.BoxSelect = false
+		tv.MultiCut()
+		return true
+	case gi.KeyFunPaste:
+		kt.SetProcessed()
+		tv.BoxSelect = false
+		tv.MultiPaste()
+		return true
+	case gi.KeyFunBackspace, gi.KeyFunDelete, gi.KeyFunBackspaceWord, gi.KeyFunDeleteWord,
+		gi.KeyFunKill, gi.KeyFunEnter, gi.KeyFunFocusNext:
+		if kt.HasAnyModifier(key.Control, key.Meta) && (kf == gi.KeyFunEnter || kf == gi.KeyFunFocusNext) {
+			break
+		}
+		kt.SetProcessed()
+		tv.CancelComplete()
+		tv.BoxSelect = false
+		tv.ForEachCursor(func(idx int) { tv.MultiKeyAction(kf, kt) })
+		return true
+	case gi.KeyFunNil:
+		if !unicode.IsPrint(kt.Rune) || kt.HasAnyModifier(key.Control, key.Meta) {
+			return false
+		}
+		kt.SetProcessed()
+		tv.BoxSelect = false
+		tv.ForEachCursor(func(idx int) { tv.MultiKeyAction(kf, kt) })
+		return true
+	}
+	tv.MultiCursorsReset()
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+//    Box (rectangular) selection
+
+// BoxSelectModeToggle toggles BoxSelect mode, in which cursor movement
+// selects a rectangular box from the position where the mode started
+func (tv *TextView) BoxSelectModeToggle() {
+	if tv.BoxSelect {
+		tv.BoxSelect = false
+		return
+	}
+	tv.BoxSelectStart(tv.CursorPos)
+}
+
+// BoxSelectStart turns on BoxSelect mode, with the box anchored at given position
+func (tv *TextView) BoxSelectStart(pos TextPos) {
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tv.MultiCursorsReset()
+	tv.SelectReset()
+	tv.BoxSelect = true
+	tv.BoxStart = tv.Buf.ValidPos(pos)
+	tv.BoxEnd = tv.BoxStart
+	tv.SavePosHistory(tv.BoxStart)
+}
+
+// BoxSelectUpdate updates the rectangular selection to extend from BoxStart
+// to given position: there is a cursor on each line in the box, selecting
+// the part of the line within its columns, and the main cursor is on the
+// line of the given position.  Columns are in characters, and lines that
+// are too short have their selection clipped to the end of the line.
+func (tv *TextView) BoxSelectUpdate(pos TextPos) {
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	pos.Ln = ints.MinInt(ints.MaxInt(pos.Ln, 0), tv.Buf.NumLines()-1)
+	pos.Ch = ints.MaxInt(pos.Ch, 0)
+	tv.BoxEnd = pos
+	stln, edln := tv.BoxStart.Ln, pos.Ln
+	if edln < stln {
+		stln, edln = edln, stln
+	}
+	stch, edch := tv.BoxStart.Ch, pos.Ch
+	left := edch < stch // cursor is on the left side of the box
+	if left {
+		stch, edch = edch, stch
+	}
+	cs := make([]TextCursor, 0, edln-stln+1)
+	mi := 0
+	for ln := stln; ln <= edln; ln++ {
+		llen := tv.Buf.LineLen(ln)
+		st := TextPos{Ln: ln, Ch: ints.MinInt(stch, llen)}
+		ed := TextPos{Ln: ln, Ch: ints.MinInt(edch, llen)}
+		c := TextCursor{Pos: ed, Sel: TextRegionNil, SelStart: st, Col: pos.Ch}
+		if left {
+			c.Pos, c.SelStart = st, ed
+		}
+		if st.IsLess(ed) {
+			c.Sel = TextRegion{Start: st, End: ed}
+		}
+		if ln == pos.Ln {
+			mi = len(cs)
+		}
+		cs = append(cs, c)
+	}
+	cs[0], cs[mi] = cs[mi], cs[0]
+	tv.SetAllCursors(cs)
+	tv.ScrollCursorToCenterIfHidden()
+	tv.RenderAllLines()
+	tv.RenderCursor(true)
+}
+
+// BoxSelectMove moves the corner of the box selection opposite BoxStart
+// according to given movement key function
+func (tv *TextView) BoxSelectMove(kf gi.KeyFuns) {
+	pos := tv.BoxEnd
+	switch kf {
+	case gi.KeyFunMoveRight:
+		pos.Ch++
+	case gi.KeyFunMoveLeft:
+		pos.Ch--
+	case gi.KeyFunMoveUp:
+		pos.Ln--
+	case gi.KeyFunMoveDown:
+		pos.Ln++
+	case gi.KeyFunHome:
+		pos.Ch = 0
+	case gi.KeyFunEnd:
+		pos.Ch = tv.Buf.LineLen(pos.Ln)
+	case gi.KeyFunWordRight, gi.KeyFunWordLeft:
+		tv.CursorPos = tv.Buf.ValidPos(pos)
+		tv.SelectReg = TextRegionNil
+		if kf == gi.KeyFunWordRight {
+			tv.CursorForwardWord(1)
+		} else {
+			tv.CursorBackwardWord(1)
+		}
+		pos = tv.CursorPos
+	}
+	tv.BoxSelectUpdate(pos)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+//    Rendering
+
+// RenderMultiCursors renders the selections of the additional cursors, and
+// the cursors themselves as vertical bars (the main cursor is a sprite) --
+// always called within context of outer RenderLines or RenderAllLines
+func (tv *TextView) RenderMultiCursors() {
+	if !tv.HasMultiCursors() {
+		return
+	}
+	for _, c := range tv.Cursors {
+		if c.HasSel() {
+			tv.RenderRegionBox(c.Sel, TextViewSel)
+		}
+	}
+	rs := &tv.Viewport.Render
+	pc := &rs.Paint
+	sty := &tv.StateStyles[TextViewActive]
+	wd := math32.Max(tv.CursorWidth.Dots, 2)
+	for _, c := range tv.Cursors {
+		if c.Pos.Ln >= tv.NLines {
+			continue
+		}
+		spos := tv.CharStartPos(c.Pos)
+		pc.FillBoxColor(rs, spos, gi.Vec2D{wd, tv.FontHeight}, sty.Font.Color)
+	}
+}
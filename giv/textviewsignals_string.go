@@ -9,9 +9,9 @@ import (
 
 var _ = errors.New("dummy error")
 
-const _TextViewSignals_name = "TextViewDoneTextViewSelectedTextViewCursorMovedTextViewISearchTextViewQReplaceTextViewSignalsN"
+const _TextViewSignals_name = "TextViewDoneTextViewSelectedTextViewCursorMovedTextViewISearchTextViewQReplaceTextViewVimModeTextViewVimCmdTextViewSignalsN"
 
-var _TextViewSignals_index = [...]uint8{0, 12, 28, 47, 62, 78, 94}
+var _TextViewSignals_index = [...]uint8{0, 12, 28, 47, 62, 78, 93, 107, 123}
 
 func (i TextViewSignals) String() string {
 	if i < 0 || i >= TextViewSignals(len(_TextViewSignals_index)-1) {
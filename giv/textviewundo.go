@@ -0,0 +1,34 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// UndoHistoryChooser pops up a menu of the alternative undo histories of the
+// buffer (see TextBuf.UndoBranches), and switches to the one chosen
+func (tv *TextView) UndoHistoryChooser() {
+	tb := tv.Buf
+	if tb == nil || len(tb.UndoBranches) == 0 {
+		return
+	}
+	strs := make([]string, len(tb.UndoBranches))
+	for i := range tb.UndoBranches {
+		strs[i] = tb.UndoBranchLabel(i)
+	}
+	gi.StringsChooserPopup(strs, "", tv, func(recv, send ki.Ki, sig int64, data interface{}) {
+		ac := send.(*gi.Action)
+		idx := ac.Data.(int)
+		updt := tv.Viewport.Win.UpdateStart()
+		defer tv.Viewport.Win.UpdateEnd(updt)
+		if err := tb.UndoBranchSwitch(idx); err != nil {
+			gi.PromptDialog(tv.Viewport, gi.DlgOpts{Title: "Could not Switch Undo History", Prompt: err.Error()}, true, false, nil, nil)
+			return
+		}
+		tv.SetCursorShow(tb.ValidPos(tv.CursorPos))
+	})
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"fmt"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// TextVcsColors are the colors of the gutter markers for each kind of
+// change relative to the version in version control
+var TextVcsColors = [TextVcsChangesN]gi.Color{
+	TextVcsAdded:    {R: 40, G: 170, B: 40, A: 255},
+	TextVcsModified: {R: 40, G: 110, B: 220, A: 255},
+	TextVcsDeleted:  {R: 210, G: 40, B: 40, A: 255},
+}
+
+// TextViewVcsMarkerWidth is the width of the gutter space for the version
+// control change markers, in chars
+var TextViewVcsMarkerWidth = float32(0.75)
+
+// VcsMarkerWidth returns the extra width of the gutter for the version
+// control change markers, if the buffer has a version control base
+func (tv *TextView) VcsMarkerWidth() float32 {
+	if tv.Buf == nil || !tv.Buf.HasVcsBase() {
+		return 0
+	}
+	return TextViewVcsMarkerWidth * tv.Sty.Font.Ch
+}
+
+// vcsMarkerX returns the horizontal position of the version control change
+// markers in the gutter, relative to the start of the view -- in their own
+// space after the icons, before any blame annotations
+func (tv *TextView) vcsMarkerX() float32 {
+	return tv.Sty.BoxSpace() + (float32(tv.LineNoDigs)+3.15)*tv.Sty.Font.Ch
+}
+
+// RenderVcsMarker renders the marker in the gutter for the change to given
+// line relative to the version in version control, if any: a bar for added
+// and modified lines, and a wedge at lines after deleted ones -- called
+// within context of other render
+func (tv *TextView) RenderVcsMarker(ln int) {
+	if tv.Buf == nil || !tv.Buf.HasVcsBase() {
+		return
+	}
+	chg, _ := tv.Buf.VcsLineChange(ln)
+	if chg == TextVcsUnchanged {
+		return
+	}
+	rs := &tv.Viewport.Render
+	pc := &rs.Paint
+	ch := tv.Sty.Font.Ch
+	x := float32(tv.VpBBox.Min.X) + tv.vcsMarkerX()
+	y := tv.CharStartPos(TextPos{Ln: ln}).Y
+	clr := TextVcsColors[chg]
+	if chg != TextVcsDeleted {
+		pc.FillBoxColor(rs, gi.Vec2D{x, y}, gi.Vec2D{0.2 * ch, tv.LineHeight}, clr)
+		return
+	}
+	if ln == tv.NLines-1 && tv.Buf.VcsDeletedAtEnd() {
+		y += tv.LineHeight
+	}
+	pc.MoveTo(rs, x, y-0.3*ch)
+	pc.LineTo(rs, x+0.4*ch, y)
+	pc.LineTo(rs, x, y+0.3*ch)
+	pc.ClosePath(rs)
+	pc.FillStyle.SetColor(clr)
+	pc.Fill(rs)
+}
+
+// VcsMarkerAt returns true if given point, relative to the view, is on a
+// version control change marker in the gutter, for given line
+func (tv *TextView) VcsMarkerAt(pt int, ln int) bool {
+	if !tv.HasLineNos() || pt >= int(tv.LineNoOff) || tv.Buf == nil || !tv.Buf.HasVcsBase() {
+		return false
+	}
+	x := tv.vcsMarkerX()
+	if float32(pt) < x-0.15*tv.Sty.Font.Ch || float32(pt) > x+0.55*tv.Sty.Font.Ch {
+		return false
+	}
+	chg, _ := tv.Buf.VcsLineChange(ln)
+	return chg != TextVcsUnchanged
+}
+
+// VcsHunkDialog shows the original text of the change at given line,
+// relative to the version in version control, in a dialog where Ok reverts
+// the change -- which can be undone
+func (tv *TextView) VcsHunkDialog(ln int) {
+	tv.Buf.VcsUpdate() // must be current, as Ok changes the text
+	chg, idx := tv.Buf.VcsLineChange(ln)
+	df, ok := tv.Buf.VcsDiff(idx)
+	if !ok {
+		return
+	}
+	var prompt string
+	switch chg {
+	case TextVcsAdded:
+		prompt = fmt.Sprintf("Lines %v-%v were added -- Ok removes them", df.I1+1, df.I2)
+	case TextVcsModified:
+		prompt = fmt.Sprintf("Lines %v-%v were changed from the original text shown -- Ok reverts them", df.I1+1, df.I2)
+	default:
+		prompt = fmt.Sprintf("The original text shown was deleted before line %v -- Ok restores it", df.I1+1)
+	}
+	tb := newTextBufLike("vcs-hunk", tv.Buf.VcsHunkText(idx), tv.Buf)
+	TextViewDialog(tv.Viewport, tb, DlgOpts{Title: "Revert Change?", Prompt: prompt, Ok: true, Cancel: true},
+		tv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			if sig != int64(gi.DialogAccepted) {
+				return
+			}
+			tvv := recv.Embed(KiT_TextView).(*TextView)
+			tvv.Buf.VcsUpdate()
+			if _, idx := tvv.Buf.VcsLineChange(ln); idx >= 0 { // could have been edited since
+				tvv.Buf.VcsRevertHunk(idx)
+			}
+		})
+}
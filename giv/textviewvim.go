@@ -0,0 +1,2341 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/chewxy/math32"
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/key"
+	"github.com/goki/gi/oswin/mimedata"
+	"github.com/goki/ki/ints"
+	"github.com/goki/ki/kit"
+	"github.com/goki/pi/filecat"
+)
+
+// VimModes are the modes of vim-style modal editing in the TextView, which
+// is active when gi.Prefs.EditMode is gi.EditVim
+type VimModes int32
+
+const (
+	// VimNormal is normal mode, where keys are motions, operators and commands
+	VimNormal VimModes = iota
+
+	// VimInsert is insert mode, where keys are processed by the standard
+	// KeyMap, until Escape returns to normal mode
+	VimInsert
+
+	// VimVisual is character-wise visual selection mode
+	VimVisual
+
+	// VimVisualLine is line-wise visual selection mode
+	VimVisualLine
+
+	// VimCmdLine is entering a : command, or a / or ? search, on the
+	// command line shown at the bottom of the view
+	VimCmdLine
+
+	VimModesN
+)
+
+//go:generate stringer -type=VimModes
+
+var KiT_VimModes = kit.Enums.AddEnumAltLower(VimModesN, false, nil, "Vim")
+
+// VimRegister holds the contents of one vim register
+type VimRegister struct {
+	Text  []byte `desc:"text contents of the register"`
+	Lines bool   `desc:"if true, the text is a set of whole lines (ending in a newline), which are put as new lines above or below the cursor line"`
+}
+
+// VimRegisters are the vim registers, shared across all TextViews, indexed
+// by register name: '"' is the unnamed register, '0' the last yank, '1'..'9'
+// the most recent multi-line deletes, '-' the last small delete, and 'a'..'z'
+// are named registers (appended to using 'A'..'Z').  The '+' and '*'
+// registers map directly onto the system clipboard, and '_' discards.
+var VimRegisters = map[rune]*VimRegister{}
+
+// VimCmdHist is the history of : commands, most recent last
+var VimCmdHist []string
+
+// VimCmdHistMax is the maximum number of : commands to keep in VimCmdHist
+var VimCmdHistMax = 100
+
+// VimState holds the state of vim-style modal editing for a TextView
+type VimState struct {
+	Mode       VimModes         `json:"-" xml:"-" desc:"current mode"`
+	Count      int              `json:"-" xml:"-" desc:"count typed before the current command or motion -- 0 if none"`
+	OpCount    int              `json:"-" xml:"-" desc:"count typed before the pending operator -- multiplies Count"`
+	Op         rune             `json:"-" xml:"-" desc:"pending operator: d, c, y, <, >, or ~ u U for g~ gu gU -- 0 if none"`
+	Pending    rune             `json:"-" xml:"-" desc:"pending prefix awaiting one more key: \" g r f t F T z, or i a for text objects -- 0 if none"`
+	Reg        rune             `json:"-" xml:"-" desc:"register selected with \" for the next command -- 0 for the unnamed register"`
+	VisStart   TextPos          `json:"-" xml:"-" desc:"position where visual mode selection started"`
+	VisLines   [2]int           `json:"-" xml:"-" desc:"start and end lines of the last visual selection, used for the '<,'> command range"`
+	FindCmd    rune             `json:"-" xml:"-" desc:"last f, t, F, or T command, repeated by ; and ,"`
+	FindRune   rune             `json:"-" xml:"-" desc:"character searched for by the last f, t, F, or T command"`
+	SearchStr  string           `json:"-" xml:"-" desc:"last / or ? search string"`
+	SearchBack bool             `json:"-" xml:"-" desc:"last search was backward, with ?"`
+	CmdPrefix  rune             `json:"-" xml:"-" desc:"command line prefix: ':' for commands, '/' or '?' for search"`
+	CmdLine    string           `json:"-" xml:"-" desc:"command line text being typed"`
+	CmdHistIdx int              `json:"-" xml:"-" desc:"position within VimCmdHist while browsing it with up / down"`
+	Msg        string           `json:"-" xml:"-" desc:"message shown in the status line, e.g., an error -- cleared on the next key"`
+	Keys       []key.ChordEvent `json:"-" xml:"-" desc:"keys of the command currently being entered -- saved as LastChange if it changes the buffer"`
+	Change     bool             `json:"-" xml:"-" desc:"the command currently being entered changes the buffer"`
+	LastChange []key.ChordEvent `json:"-" xml:"-" desc:"keys of the last command that changed the buffer -- replayed by ."`
+	InsKeys    []key.ChordEvent `json:"-" xml:"-" desc:"keys typed in the current insert session -- replayed for a count on the insert command"`
+	InsCount   int              `json:"-" xml:"-" desc:"count given to the command that started the current insert session"`
+	Replaying  bool             `json:"-" xml:"-" desc:"currently replaying keys for . or an insert count -- nothing is recorded"`
+	InsUndo    bool             `json:"-" xml:"-" desc:"an undo group is open for the current insert session -- it is closed whenever insert mode ends or is interrupted, e.g., by a mouse click or focus loss"`
+}
+
+// Reset resets all the pending command state (count, operator, prefix, register)
+func (vs *VimState) Reset() {
+	vs.Count = 0
+	vs.OpCount = 0
+	vs.Op = 0
+	vs.Pending = 0
+	vs.Reg = 0
+}
+
+// IsPending returns true if a command is partially entered
+func (vs *VimState) IsPending() bool {
+	return vs.Count > 0 || vs.OpCount > 0 || vs.Op != 0 || vs.Pending != 0 || vs.Reg != 0
+}
+
+// HasCount returns true if a count was given for the current command
+func (vs *VimState) HasCount() bool {
+	return vs.Count > 0 || vs.OpCount > 0
+}
+
+// CountVal returns the effective count for the current command -- the
+// operator count times the motion count, and 1 if neither was given
+func (vs *VimState) CountVal() int {
+	cnt := 1
+	if vs.OpCount > 0 {
+		cnt *= vs.OpCount
+	}
+	if vs.Count > 0 {
+		cnt *= vs.Count
+	}
+	return cnt
+}
+
+// IsVisual returns true if in one of the visual modes
+func (vs *VimState) IsVisual() bool {
+	return vs.Mode == VimVisual || vs.Mode == VimVisualLine
+}
+
+// PendingString returns the keys of the partially entered command, for display
+func (vs *VimState) PendingString() string {
+	if !vs.IsPending() {
+		return ""
+	}
+	var b strings.Builder
+	if vs.Reg != 0 {
+		b.WriteRune('"')
+		b.WriteRune(vs.Reg)
+	}
+	if vs.OpCount > 0 {
+		b.WriteString(strconv.Itoa(vs.OpCount))
+	}
+	switch vs.Op {
+	case 0:
+	case '~', 'u', 'U':
+		b.WriteRune('g')
+		b.WriteRune(vs.Op)
+	default:
+		b.WriteRune(vs.Op)
+	}
+	if vs.Count > 0 {
+		b.WriteString(strconv.Itoa(vs.Count))
+	}
+	if vs.Pending != 0 {
+		b.WriteRune(vs.Pending)
+	}
+	return b.String()
+}
+
+// vimMotionTypes determine how the region covered by a motion is computed
+// when it is used with an operator
+type vimMotionTypes int
+
+const (
+	// vimExclusive motions do not include the character at the end position
+	vimExclusive vimMotionTypes = iota
+
+	// vimInclusive motions include the character at the end position
+	vimInclusive
+
+	// vimLinewise motions operate on all of the lines spanned
+	vimLinewise
+)
+
+/////////////////////////////////////////////////////////////////////////////
+//   Mode
+
+// VimOn returns true if vim-style modal editing is active for this view --
+// set by gi.Prefs.EditMode, and only for active (editable) views
+func (tv *TextView) VimOn() bool {
+	return gi.Prefs.EditMode == gi.EditVim && !tv.IsInactive()
+}
+
+// VimSetMode sets the vim mode, updating the selection and cursor for the
+// new mode, and sends the TextViewVimMode signal
+func (tv *TextView) VimSetMode(mode VimModes) {
+	vs := &tv.Vim
+	if vs.Mode == mode {
+		return
+	}
+	prv := vs.Mode
+	vs.Mode = mode
+	if prv == VimInsert {
+		tv.VimUndoGroupEnd()
+	}
+	switch mode {
+	case VimVisual, VimVisualLine:
+		if prv != VimVisual && prv != VimVisualLine {
+			vs.VisStart = tv.CursorPos
+		}
+		tv.VimSelectUpdate()
+	case VimNormal:
+		if prv == VimVisual || prv == VimVisualLine {
+			tv.VimSaveVisLines()
+			tv.SelectReset()
+		}
+		tv.VimClampCursor()
+	}
+	tv.TextViewSig.Emit(tv.This(), int64(TextViewVimMode), mode)
+	tv.VimRenderStatus()
+}
+
+// VimClampCursor keeps the cursor on a character of the line, as is
+// required in normal and visual modes (only insert mode can be at the end)
+func (tv *TextView) VimClampCursor() {
+	if tv.Vim.Mode == VimInsert || tv.Buf == nil {
+		return
+	}
+	llen := tv.Buf.LineLen(tv.CursorPos.Ln)
+	if llen > 0 && tv.CursorPos.Ch >= llen {
+		tv.SetCursorShow(TextPos{Ln: tv.CursorPos.Ln, Ch: llen - 1})
+	}
+}
+
+// VimStartInsert enters insert mode, for a command with given count --
+// everything typed until Escape is a single undo step, so an undo group is
+// started, and ended by VimUndoGroupEnd when insert mode ends
+func (tv *TextView) VimStartInsert(cnt int) {
+	vs := &tv.Vim
+	tv.VimUndoGroupStart()
+	vs.InsCount = cnt
+	vs.InsKeys = nil
+	vs.Change = true
+	tv.VimSetMode(VimInsert)
+}
+
+// VimInsertDone finishes an insert session on Escape: repeats the inserted
+// keys for any count, closes the undo group, and returns to normal mode
+func (tv *TextView) VimInsertDone() {
+	vs := &tv.Vim
+	if vs.InsCount > 1 && len(vs.InsKeys) > 0 {
+		keys := vs.InsKeys
+		rp := vs.Replaying
+		vs.Replaying = true
+		for i := 1; i < vs.InsCount; i++ {
+			tv.VimReplay(keys)
+		}
+		vs.Replaying = rp
+	}
+	vs.InsCount = 0
+	vs.InsKeys = nil
+	tv.VimUndoGroupEnd()
+	tv.CancelComplete()
+	if tv.CursorPos.Ch > 0 {
+		tv.SetCursorShow(TextPos{Ln: tv.CursorPos.Ln, Ch: tv.CursorPos.Ch - 1})
+	}
+	tv.SetCursorCol(tv.CursorPos)
+	tv.VimSetMode(VimNormal)
+}
+
+// VimUndoGroupStart opens the undo group of an insert session, if not
+// already open
+func (tv *TextView) VimUndoGroupStart() {
+	vs := &tv.Vim
+	if vs.InsUndo || tv.Buf == nil {
+		return
+	}
+	tv.Buf.UndoGroupStart()
+	vs.InsUndo = true
+}
+
+// VimUndoGroupEnd closes the undo group of an insert session, if open --
+// called on every way out of insert mode, and when insert mode is
+// interrupted by a mouse click, focus loss, or a change of buffer, so that
+// later edits are not merged into the same undo step
+func (tv *TextView) VimUndoGroupEnd() {
+	vs := &tv.Vim
+	if !vs.InsUndo {
+		return
+	}
+	vs.InsUndo = false
+	if tv.Buf != nil {
+		tv.Buf.UndoGroupEnd()
+	}
+}
+
+// VimReplay replays the given keys through KeyInput, as if typed
+func (tv *TextView) VimReplay(keys []key.ChordEvent) {
+	for i := range keys {
+		ke := keys[i]
+		ke.Processed = false
+		tv.KeyInput(&ke)
+	}
+}
+
+// VimSelectUpdate updates the selection from the visual mode start to the
+// cursor, including the character under the cursor, or whole lines in
+// visual line mode
+func (tv *TextView) VimSelectUpdate() {
+	st, ed, _ := tv.VimVisualRegion()
+	tv.SelectReg.Start = st
+	tv.SelectReg.End = ed
+	tv.RenderSelectLines()
+}
+
+// VimVisualRegion returns the region covered by the current visual mode
+// selection, and whether it is line-wise
+func (tv *TextView) VimVisualRegion() (st, ed TextPos, lines bool) {
+	vs := &tv.Vim
+	tb := tv.Buf
+	st, ed = vs.VisStart, tv.CursorPos
+	if ed.IsLess(st) {
+		st, ed = ed, st
+	}
+	if vs.Mode == VimVisualLine {
+		st.Ch = 0
+		ed.Ch = tb.LineLen(ed.Ln)
+		return st, ed, true
+	}
+	ed.Ch++
+	if ed.Ch > tb.LineLen(ed.Ln) {
+		if ed.Ln < tb.NumLines()-1 {
+			ed = TextPos{Ln: ed.Ln + 1}
+		} else {
+			ed.Ch = tb.LineLen(ed.Ln)
+		}
+	}
+	return st, ed, false
+}
+
+// VimSaveVisLines records the lines of the current visual selection, for
+// use as the '<,'> command range
+func (tv *TextView) VimSaveVisLines() {
+	vs := &tv.Vim
+	st, ed := vs.VisStart, tv.CursorPos
+	if ed.IsLess(st) {
+		st, ed = ed, st
+	}
+	vs.VisLines = [2]int{st.Ln, ed.Ln}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Key input
+
+// VimKeyInput handles key input in vim mode -- returns true if the key was
+// handled, and otherwise the standard KeyInput processing continues, e.g.,
+// for typing text in insert mode
+func (tv *TextView) VimKeyInput(kt *key.ChordEvent) bool {
+	vs := &tv.Vim
+	if vs.Mode != VimCmdLine && !vs.Replaying {
+		vs.Keys = append(vs.Keys, *kt)
+	}
+	hadMsg := vs.Msg != ""
+	vs.Msg = ""
+	handled := false
+	switch vs.Mode {
+	case VimInsert:
+		handled = tv.VimInsertKey(kt)
+	case VimCmdLine:
+		tv.VimCmdLineKey(kt)
+		handled = true
+	default:
+		handled = tv.VimNormalKey(kt)
+	}
+	if handled {
+		kt.SetProcessed()
+	}
+	if vs.Mode == VimNormal && !vs.IsPending() {
+		if vs.Change && !vs.Replaying {
+			vs.LastChange = vs.Keys
+		}
+		vs.Keys = nil
+		vs.Change = false
+	}
+	if handled || hadMsg {
+		tv.VimRenderStatus()
+	}
+	return handled
+}
+
+// VimInsertKey handles a key in insert mode -- only Escape is handled here,
+// and all other keys are recorded and passed on to the standard processing
+func (tv *TextView) VimInsertKey(kt *key.ChordEvent) bool {
+	vs := &tv.Vim
+	if kt.Code == key.CodeEscape || kt.Chord() == "Control+[" {
+		tv.VimInsertDone()
+		return true
+	}
+	tv.VimUndoGroupStart() // new undo step after an interruption
+	if !vs.Replaying {
+		vs.InsKeys = append(vs.InsKeys, *kt)
+	}
+	return false
+}
+
+// vimNormalStdKeyFun returns true if given standard key function can be
+// used as-is in normal and visual modes -- functions that edit text are
+// blocked, while movement and other non-editing functions are allowed
+func vimNormalStdKeyFun(kf gi.KeyFuns) bool {
+	switch kf {
+	case gi.KeyFunBackspace, gi.KeyFunDelete, gi.KeyFunKill, gi.KeyFunBackspaceWord,
+		gi.KeyFunDeleteWord, gi.KeyFunCut, gi.KeyFunPaste, gi.KeyFunPasteHist,
+		gi.KeyFunEnter, gi.KeyFunFocusNext, gi.KeyFunComplete, gi.KeyFunReplace,
+		gi.KeyFunNil:
+		return false
+	}
+	return true
+}
+
+// VimNormalKey handles a key in normal or visual mode -- returns false if
+// the key should be processed by the standard KeyInput instead
+func (tv *TextView) VimNormalKey(kt *key.ChordEvent) bool {
+	vs := &tv.Vim
+	if kt.Code == key.CodeEscape || kt.Chord() == "Control+[" {
+		switch {
+		case vs.IsPending():
+			vs.Reset()
+		case vs.IsVisual():
+			tv.VimSetMode(VimNormal)
+		default:
+			tv.EscPressed()
+		}
+		return true
+	}
+	if vs.Pending != 0 {
+		tv.VimPendingKey(kt)
+		return true
+	}
+	r := kt.Rune
+	if kt.HasAnyModifier(key.Control, key.Meta, key.Alt) || !unicode.IsPrint(r) {
+		return tv.VimNormalChord(kt)
+	}
+	cnt := vs.CountVal()
+	cur := tv.CursorPos
+	tb := tv.Buf
+	switch {
+	case r >= '1' && r <= '9', r == '0' && vs.Count > 0:
+		vs.Count = vs.Count*10 + int(r-'0')
+		return true
+	}
+	switch r {
+	case '"', 'g', 'r', 'f', 't', 'F', 'T', 'z':
+		vs.Pending = r
+	case 'i', 'a':
+		if vs.Op != 0 || vs.IsVisual() {
+			vs.Pending = r // text object
+			return true
+		}
+		if r == 'a' && tb.LineLen(cur.Ln) > 0 {
+			tv.SetCursorShow(TextPos{Ln: cur.Ln, Ch: cur.Ch + 1})
+		}
+		tv.VimStartInsert(cnt)
+		vs.Reset()
+	case 'I':
+		tv.SetCursorShow(tv.VimFirstNonBlank(cur.Ln))
+		tv.VimStartInsert(cnt)
+		vs.Reset()
+	case 'A':
+		tv.SetCursorShow(TextPos{Ln: cur.Ln, Ch: tb.LineLen(cur.Ln)})
+		tv.VimStartInsert(cnt)
+		vs.Reset()
+	case 'o', 'O':
+		if vs.IsVisual() { // go to other end of selection
+			cur, vs.VisStart = vs.VisStart, cur
+			tv.VimMoveCursor(cur, false)
+			vs.Reset()
+			return true
+		}
+		tv.VimOpenLine(r == 'O', cnt)
+		vs.Reset()
+	case 'd', 'c', 'y', '<', '>':
+		tv.VimOperator(r)
+	case '~':
+		if vs.Op == '~' {
+			tv.VimOperator(r)
+			return true
+		}
+		if vs.IsVisual() {
+			st, ed, _ := tv.VimVisualRegion()
+			tv.VimSetMode(VimNormal)
+			tv.VimApplyOp('~', st, ed, false)
+			vs.Reset()
+			return true
+		}
+		ed := TextPos{Ln: cur.Ln, Ch: ints.MinInt(cur.Ch+cnt, tb.LineLen(cur.Ln))}
+		if cur.Ch < ed.Ch {
+			vs.Change = true
+			tv.VimChangeCase('~', cur, ed)
+			tv.SetCursorShow(ed)
+			tv.SetCursorCol(tv.CursorPos)
+			tv.VimClampCursor()
+		}
+		vs.Reset()
+	case 'u', 'U':
+		switch {
+		case vs.Op == r:
+			tv.VimOperator(r)
+		case vs.IsVisual():
+			st, ed, _ := tv.VimVisualRegion()
+			tv.VimSetMode(VimNormal)
+			tv.VimApplyOp(r, st, ed, false)
+			vs.Reset()
+		case r == 'u':
+			for i := 0; i < cnt; i++ {
+				tv.Undo()
+			}
+			tv.VimClampCursor()
+			vs.Reset()
+		default:
+			vs.Reset()
+		}
+	case 'x', 'X':
+		if vs.IsVisual() {
+			tv.VimOperator('d')
+			return true
+		}
+		llen := tb.LineLen(cur.Ln)
+		st, ed := cur, cur
+		if r == 'x' {
+			ed.Ch = ints.MinInt(cur.Ch+cnt, llen)
+		} else {
+			st.Ch = ints.MaxInt(cur.Ch-cnt, 0)
+		}
+		if st.Ch < ed.Ch {
+			tv.VimApplyOp('d', st, ed, false)
+		}
+		vs.Reset()
+	case 's':
+		if vs.IsVisual() {
+			tv.VimOperator('c')
+			return true
+		}
+		ed := TextPos{Ln: cur.Ln, Ch: ints.MinInt(cur.Ch+cnt, tb.LineLen(cur.Ln))}
+		tv.VimApplyOp('c', cur, ed, false)
+		vs.Reset()
+	case 'S', 'C', 'D', 'Y':
+		if vs.IsVisual() {
+			st, ed, _ := tv.VimVisualRegion()
+			tv.VimSetMode(VimNormal)
+			op := unicode.ToLower(r)
+			if op == 's' {
+				op = 'c'
+			}
+			tv.VimApplyOp(op, st, ed, true)
+			vs.Reset()
+			return true
+		}
+		edln := ints.MinInt(cur.Ln+cnt-1, tb.NumLines()-1)
+		switch r {
+		case 'S':
+			tv.VimApplyOp('c', TextPos{Ln: cur.Ln}, TextPos{Ln: edln}, true)
+		case 'Y':
+			tv.VimApplyOp('y', TextPos{Ln: cur.Ln}, TextPos{Ln: edln}, true)
+		default:
+			op := unicode.ToLower(r)
+			tv.VimApplyOp(op, cur, TextPos{Ln: edln, Ch: tb.LineLen(edln)}, false)
+		}
+		vs.Reset()
+	case 'p', 'P':
+		tv.VimPut(r == 'P', cnt)
+		vs.Reset()
+	case 'J':
+		if vs.IsVisual() {
+			st, ed, _ := tv.VimVisualRegion()
+			tv.VimSetMode(VimNormal)
+			tv.CursorPos = st
+			tv.VimJoinLines(ints.MaxInt(ed.Ln-st.Ln+1, 2), true)
+		} else {
+			tv.VimJoinLines(ints.MaxInt(cnt, 2), true)
+		}
+		vs.Reset()
+	case 'v', 'V':
+		mode := VimVisual
+		if r == 'V' {
+			mode = VimVisualLine
+		}
+		if vs.Mode == mode {
+			tv.VimSetMode(VimNormal)
+		} else {
+			tv.VimSetMode(mode)
+		}
+		vs.Reset()
+	case ':':
+		tv.VimStartCmdLine(':')
+	case '/', '?':
+		tv.VimStartCmdLine(r)
+	case '*', '#':
+		wr := tv.VimWordAt(cur)
+		if wr.Start != wr.End {
+			vs.SearchStr = string(tb.Line(cur.Ln)[wr.Start.Ch:wr.End.Ch])
+			vs.SearchBack = r == '#'
+			if pos, ok := tv.VimSearchPos(vs.SearchBack, cnt); ok {
+				tv.VimDoMotion(pos, vimExclusive, false)
+				return true
+			}
+		}
+		vs.Reset()
+	case '.':
+		tv.VimRepeat(cnt)
+		vs.Reset()
+	default:
+		if pos, mt, keepCol, ok := tv.VimMotion(r, cnt); ok {
+			tv.VimDoMotion(pos, mt, keepCol)
+		} else {
+			vs.Reset()
+		}
+	}
+	return true
+}
+
+// VimNormalChord handles a non-printable key or a modified chord in normal
+// or visual mode -- returns false if the key should be processed by the
+// standard KeyInput instead
+func (tv *TextView) VimNormalChord(kt *key.ChordEvent) bool {
+	vs := &tv.Vim
+	cnt := vs.CountVal()
+	var mr rune // equivalent motion key
+	switch kt.Chord() {
+	case "Control+R":
+		for i := 0; i < cnt; i++ {
+			tv.Redo()
+		}
+		tv.VimClampCursor()
+		vs.Reset()
+		return true
+	case "Control+F", "PageDown":
+		tv.CursorPageDown(cnt)
+		tv.VimAfterStdMove()
+		return true
+	case "Control+B", "PageUp":
+		tv.CursorPageUp(cnt)
+		tv.VimAfterStdMove()
+		return true
+	case "Control+D":
+		tv.CursorDown(ints.MaxInt(tv.VisSize.Y/2, 1) * cnt)
+		tv.VimAfterStdMove()
+		return true
+	case "Control+U":
+		tv.CursorUp(ints.MaxInt(tv.VisSize.Y/2, 1) * cnt)
+		tv.VimAfterStdMove()
+		return true
+	case "LeftArrow", "DeleteBackspace":
+		mr = 'h'
+	case "RightArrow":
+		mr = 'l'
+	case "UpArrow":
+		mr = 'k'
+	case "DownArrow":
+		mr = 'j'
+	case "ReturnEnter", "KeypadEnter":
+		mr = '+'
+	case "Home":
+		mr = '0'
+	case "End":
+		mr = '$'
+	}
+	if mr != 0 {
+		if pos, mt, keepCol, ok := tv.VimMotion(mr, cnt); ok {
+			tv.VimDoMotion(pos, mt, keepCol)
+		}
+		return true
+	}
+	kf := gi.KeyFun(kt.Chord())
+	if !vimNormalStdKeyFun(kf) {
+		return true // swallow editing keys
+	}
+	vs.Reset()
+	return false
+}
+
+// VimAfterStdMove updates state after the cursor was moved by one of the
+// standard cursor movement functions
+func (tv *TextView) VimAfterStdMove() {
+	tv.VimClampCursor()
+	if tv.Vim.IsVisual() {
+		tv.VimSelectUpdate()
+	}
+	tv.Vim.Reset()
+}
+
+// VimPendingKey handles the key following a prefix key (g, r, f, t, etc)
+func (tv *TextView) VimPendingKey(kt *key.ChordEvent) {
+	vs := &tv.Vim
+	pend := vs.Pending
+	vs.Pending = 0
+	r := kt.Rune
+	if !unicode.IsPrint(r) || kt.HasAnyModifier(key.Control, key.Meta, key.Alt) {
+		vs.Reset()
+		return
+	}
+	cnt := vs.CountVal()
+	switch pend {
+	case '"':
+		vs.Reg = r
+	case 'r':
+		tv.VimReplaceChars(r, cnt)
+		vs.Reset()
+	case 'f', 't', 'F', 'T':
+		vs.FindCmd = pend
+		vs.FindRune = r
+		if pos, ok := tv.VimFindChar(pend, r, cnt, false); ok {
+			mt := vimInclusive
+			if pend == 'F' || pend == 'T' {
+				mt = vimExclusive
+			}
+			tv.VimDoMotion(pos, mt, false)
+		} else {
+			vs.Reset()
+		}
+	case 'i', 'a':
+		st, ed, lines, ok := tv.VimTextObject(pend == 'i', r)
+		if !ok || st == ed {
+			vs.Reset()
+			return
+		}
+		if vs.IsVisual() {
+			vs.VisStart = st
+			if lines {
+				tv.VimSetMode(VimVisualLine)
+			} else {
+				ed.Ch--
+				if ed.Ch < 0 && ed.Ln > st.Ln {
+					ed = TextPos{Ln: ed.Ln - 1, Ch: tv.Buf.LineLen(ed.Ln - 1)}
+				}
+			}
+			tv.SetCursorShow(ed)
+			tv.VimSelectUpdate()
+		} else if vs.Op != 0 {
+			tv.VimApplyOp(vs.Op, st, ed, lines)
+		}
+		vs.Reset()
+	case 'g':
+		switch r {
+		case 'g', 'e', 'E', '_':
+			if pos, mt, keepCol, ok := tv.VimMotion(r+0x10000, cnt); ok { // g-prefixed motion
+				tv.VimDoMotion(pos, mt, keepCol)
+				return
+			}
+			vs.Reset()
+		case '~', 'u', 'U':
+			if vs.IsVisual() {
+				st, ed, _ := tv.VimVisualRegion()
+				tv.VimSetMode(VimNormal)
+				tv.VimApplyOp(r, st, ed, false)
+				vs.Reset()
+				return
+			}
+			tv.VimOperator(r)
+		case 'J':
+			tv.VimJoinLines(ints.MaxInt(cnt, 2), false)
+			vs.Reset()
+		default:
+			vs.Reset()
+		}
+	case 'z':
+		switch r {
+		case 'z', '.':
+			tv.ScrollCursorToVertCenter()
+		case 't':
+			tv.ScrollCursorToTop()
+		case 'b', '-':
+			tv.ScrollCursorToBottom()
+		}
+		vs.Reset()
+	}
+}
+
+// VimOperator handles an operator key: if in visual mode it applies to the
+// selection, if the same operator is pending it applies to whole lines (dd,
+// yy, etc), and otherwise it waits for a motion or text object
+func (tv *TextView) VimOperator(op rune) {
+	vs := &tv.Vim
+	if vs.IsVisual() {
+		st, ed, lines := tv.VimVisualRegion()
+		tv.VimSetMode(VimNormal)
+		tv.VimApplyOp(op, st, ed, lines)
+		vs.Reset()
+		return
+	}
+	if vs.Op == op {
+		cur := tv.CursorPos
+		edln := ints.MinInt(cur.Ln+vs.CountVal()-1, tv.Buf.NumLines()-1)
+		tv.VimApplyOp(op, TextPos{Ln: cur.Ln}, TextPos{Ln: edln}, true)
+		vs.Reset()
+		return
+	}
+	if vs.Op != 0 {
+		vs.Reset()
+		return
+	}
+	vs.Op = op
+	vs.OpCount = vs.Count
+	vs.Count = 0
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Motions
+
+// vimRune returns the rune at given position, or a newline if at the end of
+// the line
+func (tv *TextView) vimRune(pos TextPos) rune {
+	txt := tv.Buf.Line(pos.Ln)
+	if pos.Ch < 0 || pos.Ch >= len(txt) {
+		return '\n'
+	}
+	return txt[pos.Ch]
+}
+
+// vimNextPos returns the position after given one, treating the end of each
+// line as a position (for the newline) -- false if at end of buffer
+func (tv *TextView) vimNextPos(pos TextPos) (TextPos, bool) {
+	if pos.Ch < tv.Buf.LineLen(pos.Ln) {
+		pos.Ch++
+		return pos, true
+	}
+	if pos.Ln < tv.Buf.NumLines()-1 {
+		return TextPos{Ln: pos.Ln + 1}, true
+	}
+	return pos, false
+}
+
+// vimPrevPos returns the position before given one -- false if at start of buffer
+func (tv *TextView) vimPrevPos(pos TextPos) (TextPos, bool) {
+	if pos.Ch > 0 {
+		pos.Ch = ints.MinInt(pos.Ch-1, tv.Buf.LineLen(pos.Ln))
+		return pos, true
+	}
+	if pos.Ln > 0 {
+		return TextPos{Ln: pos.Ln - 1, Ch: tv.Buf.LineLen(pos.Ln - 1)}, true
+	}
+	return pos, false
+}
+
+// vimCharClass returns the vim character class of given rune for word
+// motions: 0 = space, 1 = punctuation, 2 = word characters -- bigword
+// (W, B, E) treats all non-space as the same class
+func vimCharClass(r rune, bigword bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case bigword:
+		return 2
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 2
+	}
+	return 1
+}
+
+// vimIsBlankLine returns true if given line is empty
+func (tv *TextView) vimIsBlankLine(ln int) bool {
+	return tv.Buf.LineLen(ln) == 0
+}
+
+// VimFirstNonBlank returns the position of the first non-blank character on given line
+func (tv *TextView) VimFirstNonBlank(ln int) TextPos {
+	txt := tv.Buf.Line(ln)
+	for i, r := range txt {
+		if !unicode.IsSpace(r) {
+			return TextPos{Ln: ln, Ch: i}
+		}
+	}
+	return TextPos{Ln: ln, Ch: ints.MaxInt(len(txt)-1, 0)}
+}
+
+// VimWordForward returns the start of the next word after given position (w, W)
+func (tv *TextView) VimWordForward(pos TextPos, bigword bool) TextPos {
+	p := pos
+	cls := vimCharClass(tv.vimRune(p), bigword)
+	if cls != 0 {
+		for {
+			np, ok := tv.vimNextPos(p)
+			if !ok {
+				return np
+			}
+			p = np
+			if vimCharClass(tv.vimRune(p), bigword) != cls {
+				break
+			}
+		}
+	}
+	for vimCharClass(tv.vimRune(p), bigword) == 0 {
+		np, ok := tv.vimNextPos(p)
+		if !ok {
+			return p
+		}
+		p = np
+		if p.Ch == 0 && tv.vimIsBlankLine(p.Ln) {
+			return p // empty lines count as words
+		}
+	}
+	return p
+}
+
+// VimWordEnd returns the end of the word at or after given position (e, E)
+func (tv *TextView) VimWordEnd(pos TextPos, bigword bool) TextPos {
+	p, ok := tv.vimNextPos(pos)
+	if !ok {
+		return pos
+	}
+	for vimCharClass(tv.vimRune(p), bigword) == 0 {
+		if p, ok = tv.vimNextPos(p); !ok {
+			return pos
+		}
+	}
+	cls := vimCharClass(tv.vimRune(p), bigword)
+	for {
+		np, ok := tv.vimNextPos(p)
+		if !ok || vimCharClass(tv.vimRune(np), bigword) != cls {
+			return p
+		}
+		p = np
+	}
+}
+
+// VimWordBackward returns the start of the word at or before given position (b, B)
+func (tv *TextView) VimWordBackward(pos TextPos, bigword bool) TextPos {
+	p, ok := tv.vimPrevPos(pos)
+	if !ok {
+		return pos
+	}
+	for vimCharClass(tv.vimRune(p), bigword) == 0 {
+		if p.Ch == 0 && tv.vimIsBlankLine(p.Ln) {
+			return p
+		}
+		if p, ok = tv.vimPrevPos(p); !ok {
+			return TextPosZero
+		}
+	}
+	cls := vimCharClass(tv.vimRune(p), bigword)
+	for {
+		np, ok := tv.vimPrevPos(p)
+		if !ok || vimCharClass(tv.vimRune(np), bigword) != cls {
+			return p
+		}
+		p = np
+	}
+}
+
+// VimWordEndBackward returns the end of the previous word before given position (ge, gE)
+func (tv *TextView) VimWordEndBackward(pos TextPos, bigword bool) TextPos {
+	p := pos
+	cls := vimCharClass(tv.vimRune(p), bigword)
+	for {
+		np, ok := tv.vimPrevPos(p)
+		if !ok {
+			return p
+		}
+		p = np
+		if vimCharClass(tv.vimRune(p), bigword) != cls {
+			break
+		}
+	}
+	for vimCharClass(tv.vimRune(p), bigword) == 0 {
+		np, ok := tv.vimPrevPos(p)
+		if !ok {
+			return p
+		}
+		p = np
+	}
+	return p
+}
+
+// VimParagraph returns the position of the blank line ending the paragraph
+// after (or before, if back) the given line ({ and })
+func (tv *TextView) VimParagraph(ln int, back bool) TextPos {
+	nl := tv.Buf.NumLines()
+	if back {
+		for ln > 0 && tv.vimIsBlankLine(ln) {
+			ln--
+		}
+		for ln > 0 && !tv.vimIsBlankLine(ln) {
+			ln--
+		}
+		return TextPos{Ln: ln}
+	}
+	for ln < nl-1 && tv.vimIsBlankLine(ln) {
+		ln++
+	}
+	for ln < nl-1 && !tv.vimIsBlankLine(ln) {
+		ln++
+	}
+	if !tv.vimIsBlankLine(ln) {
+		return TextPos{Ln: ln, Ch: tv.Buf.LineLen(ln)}
+	}
+	return TextPos{Ln: ln}
+}
+
+// vimFindOpen finds the unmatched open bracket enclosing given position,
+// scanning backward -- a close bracket at pos itself is not counted
+func (tv *TextView) vimFindOpen(open, close rune, pos TextPos) (TextPos, bool) {
+	if tv.vimRune(pos) == open {
+		return pos, true
+	}
+	depth := 0
+	p := pos
+	for {
+		np, ok := tv.vimPrevPos(p)
+		if !ok {
+			return pos, false
+		}
+		p = np
+		switch tv.vimRune(p) {
+		case close:
+			depth++
+		case open:
+			if depth == 0 {
+				return p, true
+			}
+			depth--
+		}
+	}
+}
+
+// vimFindClose finds the close bracket matching the open bracket at given
+// position, scanning forward
+func (tv *TextView) vimFindClose(open, close rune, pos TextPos) (TextPos, bool) {
+	depth := 0
+	p := pos
+	for {
+		np, ok := tv.vimNextPos(p)
+		if !ok {
+			return pos, false
+		}
+		p = np
+		switch tv.vimRune(p) {
+		case open:
+			depth++
+		case close:
+			if depth == 0 {
+				return p, true
+			}
+			depth--
+		}
+	}
+}
+
+// VimMatchBracket returns the position of the bracket matching the first
+// bracket at or after the cursor on the current line (%)
+func (tv *TextView) VimMatchBracket() (TextPos, bool) {
+	cur := tv.CursorPos
+	txt := tv.Buf.Line(cur.Ln)
+	for ch := cur.Ch; ch < len(txt); ch++ {
+		r := txt[ch]
+		match, right := PunctGpMatch(r)
+		if match == 0 {
+			continue
+		}
+		pos := TextPos{Ln: cur.Ln, Ch: ch}
+		if right {
+			return tv.vimFindOpen(match, r, pos)
+		}
+		return tv.vimFindClose(r, match, pos)
+	}
+	return cur, false
+}
+
+// VimFindChar finds the cnt'th occurrence of given rune on the current line,
+// for the f, t, F, T commands -- rep is true for a ; or , repeat, which
+// skips an adjacent match for t and T
+func (tv *TextView) VimFindChar(cmd, r rune, cnt int, rep bool) (TextPos, bool) {
+	cur := tv.CursorPos
+	txt := tv.Buf.Line(cur.Ln)
+	ch := cur.Ch
+	switch cmd {
+	case 'f', 't':
+		if cmd == 't' && rep {
+			ch++
+		}
+		for i := 0; i < cnt; i++ {
+			ch++
+			for ch < len(txt) && txt[ch] != r {
+				ch++
+			}
+			if ch >= len(txt) {
+				return cur, false
+			}
+		}
+		if cmd == 't' {
+			ch--
+		}
+	case 'F', 'T':
+		if cmd == 'T' && rep {
+			ch--
+		}
+		for i := 0; i < cnt; i++ {
+			ch--
+			for ch >= 0 && txt[ch] != r {
+				ch--
+			}
+			if ch < 0 {
+				return cur, false
+			}
+		}
+		if cmd == 'T' {
+			ch++
+		}
+	}
+	return TextPos{Ln: cur.Ln, Ch: ch}, true
+}
+
+// VimSearchPos returns the start of the cnt'th match of the last search
+// string after (or before, if back) the cursor, wrapping around the buffer
+// -- all matches are highlighted
+func (tv *TextView) VimSearchPos(back bool, cnt int) (TextPos, bool) {
+	vs := &tv.Vim
+	if vs.SearchStr == "" {
+		vs.Msg = "E35: No previous regular expression"
+		return tv.CursorPos, false
+	}
+	matches, ok := tv.FindMatches(vs.SearchStr, HasUpperCase(vs.SearchStr))
+	if !ok {
+		vs.Msg = "E486: Pattern not found: " + vs.SearchStr
+		return tv.CursorPos, false
+	}
+	pos := tv.CursorPos
+	for i := 0; i < cnt; i++ {
+		found := false
+		if back {
+			for mi := len(matches) - 1; mi >= 0; mi-- {
+				if matches[mi].Reg.Start.IsLess(pos) {
+					pos = matches[mi].Reg.Start
+					found = true
+					break
+				}
+			}
+			if !found {
+				pos = matches[len(matches)-1].Reg.Start
+			}
+		} else {
+			for _, m := range matches {
+				if pos.IsLess(m.Reg.Start) {
+					pos = m.Reg.Start
+					found = true
+					break
+				}
+			}
+			if !found {
+				pos = matches[0].Reg.Start
+			}
+		}
+		if !found {
+			vs.Msg = "search hit end, continuing at other end"
+		}
+	}
+	return pos, true
+}
+
+// VimMotion computes the target of the motion for given key (g-prefixed
+// motions have 0x10000 added to the rune) with given count -- returns the
+// motion type, whether the cursor column should be kept (vertical motions),
+// and false if not a motion or the motion failed
+func (tv *TextView) VimMotion(r rune, cnt int) (pos TextPos, mt vimMotionTypes, keepCol, ok bool) {
+	vs := &tv.Vim
+	tb := tv.Buf
+	cur := tv.CursorPos
+	nl := tb.NumLines()
+	pos = cur
+	mt = vimExclusive
+	ok = true
+	switch r {
+	case 'h':
+		pos.Ch = ints.MaxInt(cur.Ch-cnt, 0)
+	case 'l', ' ':
+		pos.Ch = ints.MinInt(cur.Ch+cnt, tb.LineLen(cur.Ln))
+		if vs.Op == 0 && tb.LineLen(cur.Ln) > 0 {
+			pos.Ch = ints.MinInt(pos.Ch, tb.LineLen(cur.Ln)-1)
+		}
+	case 'j':
+		pos.Ln = ints.MinInt(cur.Ln+cnt, nl-1)
+		mt, keepCol = vimLinewise, true
+	case 'k':
+		pos.Ln = ints.MaxInt(cur.Ln-cnt, 0)
+		mt, keepCol = vimLinewise, true
+	case '+':
+		pos = tv.VimFirstNonBlank(ints.MinInt(cur.Ln+cnt, nl-1))
+		mt = vimLinewise
+	case '-':
+		pos = tv.VimFirstNonBlank(ints.MaxInt(cur.Ln-cnt, 0))
+		mt = vimLinewise
+	case '_', '_' + 0x10000:
+		pos = tv.VimFirstNonBlank(ints.MinInt(cur.Ln+cnt-1, nl-1))
+		mt = vimLinewise
+	case '0':
+		pos.Ch = 0
+	case '^':
+		pos = tv.VimFirstNonBlank(cur.Ln)
+	case '$':
+		pos.Ln = ints.MinInt(cur.Ln+cnt-1, nl-1)
+		pos.Ch = tb.LineLen(pos.Ln)
+	case 'w', 'W':
+		big := r == 'W'
+		if vs.Op == 'c' && vimCharClass(tv.vimRune(cur), big) != 0 { // cw is ce
+			for i := 0; i < cnt; i++ {
+				if i == 0 && vimCharClass(tv.vimRune(TextPos{Ln: pos.Ln, Ch: pos.Ch + 1}), big) != vimCharClass(tv.vimRune(pos), big) {
+					continue // already at end of word
+				}
+				pos = tv.VimWordEnd(pos, big)
+			}
+			mt = vimInclusive
+			break
+		}
+		for i := 0; i < cnt; i++ {
+			pos = tv.VimWordForward(pos, big)
+		}
+		if vs.Op != 0 && pos.Ln > cur.Ln && pos.Ch == 0 { // do not operate across the line end
+			pos = TextPos{Ln: pos.Ln - 1, Ch: tb.LineLen(pos.Ln - 1)}
+		}
+	case 'b', 'B':
+		for i := 0; i < cnt; i++ {
+			pos = tv.VimWordBackward(pos, r == 'B')
+		}
+	case 'e', 'E':
+		for i := 0; i < cnt; i++ {
+			pos = tv.VimWordEnd(pos, r == 'E')
+		}
+		mt = vimInclusive
+	case 'e' + 0x10000, 'E' + 0x10000:
+		for i := 0; i < cnt; i++ {
+			pos = tv.VimWordEndBackward(pos, r == 'E'+0x10000)
+		}
+		mt = vimInclusive
+	case 'G':
+		ln := nl - 1
+		if vs.HasCount() {
+			ln = ints.MinInt(cnt-1, nl-1)
+		}
+		pos = tv.VimFirstNonBlank(ln)
+		mt = vimLinewise
+		tv.SavePosHistory(cur)
+	case 'g' + 0x10000:
+		ln := 0
+		if vs.HasCount() {
+			ln = ints.MinInt(cnt-1, nl-1)
+		}
+		pos = tv.VimFirstNonBlank(ln)
+		mt = vimLinewise
+		tv.SavePosHistory(cur)
+	case 'H', 'L', 'M':
+		stln := tv.FirstVisibleLine(0)
+		edln := tv.LastVisibleLine(stln)
+		switch r {
+		case 'H':
+			pos = tv.VimFirstNonBlank(ints.MinInt(stln+cnt-1, edln))
+		case 'L':
+			pos = tv.VimFirstNonBlank(ints.MaxInt(edln-cnt+1, stln))
+		default:
+			pos = tv.VimFirstNonBlank((stln + edln) / 2)
+		}
+		mt = vimLinewise
+	case '{', '}':
+		for i := 0; i < cnt; i++ {
+			pos = tv.VimParagraph(pos.Ln, r == '{')
+		}
+	case '%':
+		pos, ok = tv.VimMatchBracket()
+		mt = vimInclusive
+	case ';', ',':
+		if vs.FindCmd == 0 {
+			ok = false
+			break
+		}
+		cmd := vs.FindCmd
+		if r == ',' {
+			switch cmd {
+			case 'f':
+				cmd = 'F'
+			case 'F':
+				cmd = 'f'
+			case 't':
+				cmd = 'T'
+			case 'T':
+				cmd = 't'
+			}
+		}
+		pos, ok = tv.VimFindChar(cmd, vs.FindRune, cnt, true)
+		if cmd == 'f' || cmd == 't' {
+			mt = vimInclusive
+		}
+	case 'n', 'N':
+		back := vs.SearchBack
+		if r == 'N' {
+			back = !back
+		}
+		pos, ok = tv.VimSearchPos(back, cnt)
+	default:
+		ok = false
+	}
+	return
+}
+
+// VimDoMotion moves the cursor to given motion target, or applies the
+// pending operator over the region covered by the motion
+func (tv *TextView) VimDoMotion(pos TextPos, mt vimMotionTypes, keepCol bool) {
+	vs := &tv.Vim
+	if vs.Op != 0 {
+		op := vs.Op
+		st, ed := tv.CursorPos, pos
+		if ed.IsLess(st) {
+			st, ed = ed, st
+		}
+		if mt == vimInclusive {
+			ed.Ch = ints.MinInt(ed.Ch+1, tv.Buf.LineLen(ed.Ln))
+		}
+		tv.VimApplyOp(op, st, ed, mt == vimLinewise)
+		vs.Reset()
+		return
+	}
+	tv.VimMoveCursor(pos, keepCol)
+	vs.Reset()
+}
+
+// VimMoveCursor moves the cursor to given position, keeping the target
+// column for vertical motions, and updates any visual mode selection
+func (tv *TextView) VimMoveCursor(pos TextPos, keepCol bool) {
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	if keepCol {
+		pos.Ch = tv.CursorCol
+	}
+	llen := tv.Buf.LineLen(pos.Ln)
+	if pos.Ch >= llen {
+		pos.Ch = ints.MaxInt(llen-1, 0)
+	}
+	tv.SetCursorShow(pos)
+	if !keepCol {
+		tv.SetCursorCol(tv.CursorPos)
+	}
+	if tv.Vim.IsVisual() {
+		tv.VimSelectUpdate()
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Text objects
+
+// VimWordAt returns the region of the word (of one character class) at
+// given position
+func (tv *TextView) VimWordAt(pos TextPos) TextRegion {
+	st, ed := tv.vimWordObject(pos, true, false)
+	return NewTextRegionPos(st, ed)
+}
+
+// vimWordObject returns the inner (iw) or outer (aw) word at given position
+func (tv *TextView) vimWordObject(pos TextPos, inner, bigword bool) (st, ed TextPos) {
+	txt := tv.Buf.Line(pos.Ln)
+	sz := len(txt)
+	if sz == 0 {
+		return pos, pos
+	}
+	ch := ints.MinInt(pos.Ch, sz-1)
+	cls := vimCharClass(txt[ch], bigword)
+	s := ch
+	for s > 0 && vimCharClass(txt[s-1], bigword) == cls {
+		s--
+	}
+	e := ch + 1
+	for e < sz && vimCharClass(txt[e], bigword) == cls {
+		e++
+	}
+	if !inner {
+		if cls != 0 { // include trailing space, or leading if none
+			te := e
+			for te < sz && vimCharClass(txt[te], bigword) == 0 {
+				te++
+			}
+			if te > e {
+				e = te
+			} else {
+				for s > 0 && vimCharClass(txt[s-1], bigword) == 0 {
+					s--
+				}
+			}
+		} else if e < sz { // on space: include following word
+			ncls := vimCharClass(txt[e], bigword)
+			for e < sz && vimCharClass(txt[e], bigword) == ncls {
+				e++
+			}
+		}
+	}
+	return TextPos{Ln: pos.Ln, Ch: s}, TextPos{Ln: pos.Ln, Ch: e}
+}
+
+// vimQuoteObject returns the inner or outer quoted string at or after the
+// cursor on the current line, for given quote character
+func (tv *TextView) vimQuoteObject(q rune, inner bool) (st, ed TextPos, ok bool) {
+	cur := tv.CursorPos
+	txt := tv.Buf.Line(cur.Ln)
+	var qs []int
+	for i, r := range txt {
+		if r == q && (i == 0 || txt[i-1] != '\\') {
+			qs = append(qs, i)
+		}
+	}
+	for i := 0; i+1 < len(qs); i += 2 {
+		s, e := qs[i], qs[i+1]
+		if cur.Ch > e {
+			continue
+		}
+		if inner {
+			return TextPos{Ln: cur.Ln, Ch: s + 1}, TextPos{Ln: cur.Ln, Ch: e}, true
+		}
+		e++
+		for e < len(txt) && unicode.IsSpace(txt[e]) {
+			e++
+		}
+		return TextPos{Ln: cur.Ln, Ch: s}, TextPos{Ln: cur.Ln, Ch: e}, true
+	}
+	return cur, cur, false
+}
+
+// vimParaObject returns the lines of the inner or outer paragraph at the cursor
+func (tv *TextView) vimParaObject(inner bool) (st, ed TextPos) {
+	cur := tv.CursorPos
+	nl := tv.Buf.NumLines()
+	blank := tv.vimIsBlankLine(cur.Ln)
+	stln, edln := cur.Ln, cur.Ln
+	for stln > 0 && tv.vimIsBlankLine(stln-1) == blank {
+		stln--
+	}
+	for edln < nl-1 && tv.vimIsBlankLine(edln+1) == blank {
+		edln++
+	}
+	if !inner {
+		for edln < nl-1 && tv.vimIsBlankLine(edln+1) != blank {
+			edln++
+		}
+	}
+	return TextPos{Ln: stln}, TextPos{Ln: edln}
+}
+
+// VimTextObject returns the region of the text object for given key after
+// i (inner) or a: w W for words, s for sentence (treated as a word), p for
+// paragraphs, brackets ( ) b [ ] { } B < >, and quotes " ' `
+func (tv *TextView) VimTextObject(inner bool, r rune) (st, ed TextPos, lines, ok bool) {
+	cur := tv.CursorPos
+	switch r {
+	case 'w', 'W', 's':
+		st, ed = tv.vimWordObject(cur, inner, r == 'W')
+		return st, ed, false, true
+	case 'p':
+		st, ed = tv.vimParaObject(inner)
+		return st, ed, true, true
+	case '"', '\'', '`':
+		st, ed, ok = tv.vimQuoteObject(r, inner)
+		return st, ed, false, ok
+	}
+	var open, close rune
+	switch r {
+	case '(', ')', 'b':
+		open, close = '(', ')'
+	case '[', ']':
+		open, close = '[', ']'
+	case '{', '}', 'B':
+		open, close = '{', '}'
+	case '<', '>':
+		open, close = '<', '>'
+	default:
+		return cur, cur, false, false
+	}
+	if st, ok = tv.vimFindOpen(open, close, cur); !ok {
+		return cur, cur, false, false
+	}
+	if ed, ok = tv.vimFindClose(open, close, st); !ok {
+		return cur, cur, false, false
+	}
+	if inner {
+		st, _ = tv.vimNextPos(st)
+		if st.Ch == tv.Buf.LineLen(st.Ln) && st.Ln < ed.Ln { // block: start on next line
+			st = TextPos{Ln: st.Ln + 1}
+		}
+	} else {
+		ed.Ch++
+	}
+	return st, ed, false, true
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Operators and editing
+
+// VimApplyOp applies operator op (d, c, y, <, >, ~, u, U) to the region
+// from st to ed, which covers whole lines if lines is true
+func (tv *TextView) VimApplyOp(op rune, st, ed TextPos, lines bool) {
+	vs := &tv.Vim
+	tb := tv.Buf
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	if lines {
+		st.Ch = 0
+		ed.Ch = tb.LineLen(ed.Ln)
+	}
+	if op != 'y' {
+		vs.Change = true
+	}
+	cur := tv.CursorPos
+	switch op {
+	case 'y':
+		tv.VimYank(st, ed, lines)
+		if lines {
+			if st.Ln != cur.Ln {
+				tv.SetCursorShow(TextPos{Ln: st.Ln, Ch: cur.Ch})
+			}
+		} else {
+			tv.SetCursorShow(st)
+		}
+	case 'd':
+		tv.VimDelete(st, ed, lines)
+		if lines {
+			tv.SetCursorShow(tv.VimFirstNonBlank(ints.MinInt(st.Ln, tb.NumLines()-1)))
+		} else {
+			tv.SetCursorShow(st)
+		}
+	case 'c':
+		tb.UndoGroupStart()
+		if lines {
+			tv.VimSetRegister(tv.vimLinesText(st.Ln, ed.Ln), true, false)
+			tb.DeleteText(TextPos{Ln: st.Ln}, ed, true, true)
+			tv.SetCursorShow(TextPos{Ln: st.Ln})
+			if tb.Opts.AutoIndent {
+				_, _, cpos := tb.AutoIndent(st.Ln, DefaultIndentStrings, DefaultUnindentStrings)
+				tv.SetCursorShow(TextPos{Ln: st.Ln, Ch: cpos})
+			}
+		} else {
+			tv.VimDelete(st, ed, false)
+			tv.SetCursorShow(st)
+		}
+		tv.VimStartInsert(1)
+		tb.UndoGroupEnd()
+	case '<', '>':
+		tb.UndoGroupStart()
+		edln := ed.Ln
+		if !lines && ed.Ch == 0 && ed.Ln > st.Ln {
+			edln--
+		}
+		for ln := st.Ln; ln <= edln; ln++ {
+			if tb.LineLen(ln) == 0 {
+				continue
+			}
+			n, _ := tb.LineIndent(ln, tb.Opts.TabSize)
+			if op == '>' {
+				n++
+			} else if n > 0 {
+				n--
+			} else {
+				continue
+			}
+			tb.IndentLine(ln, n)
+		}
+		tb.UndoGroupEnd()
+		tv.SetCursorShow(tv.VimFirstNonBlank(st.Ln))
+	case '~', 'u', 'U':
+		tv.VimChangeCase(op, st, ed)
+		tv.SetCursorShow(st)
+	}
+	tv.SetCursorCol(tv.CursorPos)
+	tv.VimClampCursor()
+}
+
+// vimLinesText returns the text of given range of lines (inclusive), each
+// ending in a newline
+func (tv *TextView) vimLinesText(stln, edln int) []byte {
+	var b bytes.Buffer
+	for ln := stln; ln <= edln; ln++ {
+		b.WriteString(string(tv.Buf.Line(ln)))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// VimYank copies the region into the current register
+func (tv *TextView) VimYank(st, ed TextPos, lines bool) {
+	if lines {
+		tv.VimSetRegister(tv.vimLinesText(st.Ln, ed.Ln), true, true)
+		return
+	}
+	tbe := tv.Buf.Region(st, ed)
+	if tbe != nil {
+		tv.VimSetRegister(tbe.ToBytes(), false, true)
+	}
+}
+
+// VimDelete deletes the region, saving the deleted text into the current
+// register -- for whole lines, the line endings are deleted as well
+func (tv *TextView) VimDelete(st, ed TextPos, lines bool) *TextBufEdit {
+	tb := tv.Buf
+	if !lines {
+		tbe := tb.DeleteText(st, ed, true, true)
+		if tbe != nil {
+			tv.VimSetRegister(tbe.ToBytes(), false, false)
+		}
+		return tbe
+	}
+	tv.VimSetRegister(tv.vimLinesText(st.Ln, ed.Ln), true, false)
+	edch := tb.LineLen(ed.Ln)
+	switch {
+	case ed.Ln+1 < tb.NumLines():
+		return tb.DeleteText(TextPos{Ln: st.Ln}, TextPos{Ln: ed.Ln + 1}, true, true)
+	case st.Ln > 0:
+		return tb.DeleteText(TextPos{Ln: st.Ln - 1, Ch: tb.LineLen(st.Ln - 1)}, TextPos{Ln: ed.Ln, Ch: edch}, true, true)
+	default:
+		return tb.DeleteText(TextPos{}, TextPos{Ln: ed.Ln, Ch: edch}, true, true)
+	}
+}
+
+// VimChangeCase changes the case of the text in the region: ~ toggles, u
+// lowers and U uppers
+func (tv *TextView) VimChangeCase(op rune, st, ed TextPos) {
+	tb := tv.Buf
+	tbe := tb.Region(st, ed)
+	if tbe == nil {
+		return
+	}
+	txt := []rune(string(tbe.ToBytes()))
+	for i, r := range txt {
+		switch op {
+		case '~':
+			if unicode.IsUpper(r) {
+				txt[i] = unicode.ToLower(r)
+			} else {
+				txt[i] = unicode.ToUpper(r)
+			}
+		case 'u':
+			txt[i] = unicode.ToLower(r)
+		case 'U':
+			txt[i] = unicode.ToUpper(r)
+		}
+	}
+	nb := []byte(string(txt))
+	if bytes.Equal(nb, tbe.ToBytes()) {
+		return
+	}
+	tb.UndoGroupStart()
+	tb.DeleteText(st, ed, true, true)
+	tb.InsertText(st, nb, true, true)
+	tb.UndoGroupEnd()
+}
+
+// VimReplaceChars replaces cnt characters at the cursor with given rune (r)
+func (tv *TextView) VimReplaceChars(r rune, cnt int) {
+	tb := tv.Buf
+	cur := tv.CursorPos
+	if cur.Ch+cnt > tb.LineLen(cur.Ln) {
+		return
+	}
+	tv.Vim.Change = true
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tb.UndoGroupStart()
+	tb.DeleteText(cur, TextPos{Ln: cur.Ln, Ch: cur.Ch + cnt}, true, true)
+	tb.InsertText(cur, []byte(strings.Repeat(string(r), cnt)), true, true)
+	tb.UndoGroupEnd()
+	tv.SetCursorShow(TextPos{Ln: cur.Ln, Ch: cur.Ch + cnt - 1})
+}
+
+// VimJoinLines joins n lines starting at the cursor line (J), replacing
+// line breaks and leading white space with a single space if spaces is true
+// (J), and nothing otherwise (gJ)
+func (tv *TextView) VimJoinLines(n int, spaces bool) {
+	tb := tv.Buf
+	ln := tv.CursorPos.Ln
+	if ln+1 >= tb.NumLines() {
+		return
+	}
+	tv.Vim.Change = true
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tb.UndoGroupStart()
+	pos := tv.CursorPos
+	for i := 0; i < n-1 && ln+1 < tb.NumLines(); i++ {
+		llen := tb.LineLen(ln)
+		nxt := tb.Line(ln + 1)
+		ws := 0
+		sep := ""
+		if spaces {
+			for ws < len(nxt) && unicode.IsSpace(nxt[ws]) {
+				ws++
+			}
+			if llen > 0 && ws < len(nxt) && nxt[ws] != ')' && !unicode.IsSpace(tb.Line(ln)[llen-1]) {
+				sep = " "
+			}
+		}
+		tb.DeleteText(TextPos{Ln: ln, Ch: llen}, TextPos{Ln: ln + 1, Ch: ws}, true, true)
+		if sep != "" {
+			tb.InsertText(TextPos{Ln: ln, Ch: llen}, []byte(sep), true, true)
+		}
+		pos = TextPos{Ln: ln, Ch: llen}
+	}
+	tb.UndoGroupEnd()
+	tv.SetCursorShow(pos)
+	tv.SetCursorCol(tv.CursorPos)
+}
+
+// VimOpenLine opens a new line below (or above) the cursor line, with
+// auto-indent, and enters insert mode (o, O)
+func (tv *TextView) VimOpenLine(above bool, cnt int) {
+	tb := tv.Buf
+	ln := tv.CursorPos.Ln
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tb.UndoGroupStart()
+	if above {
+		tb.InsertText(TextPos{Ln: ln}, []byte("\n"), true, true)
+	} else {
+		tb.InsertText(TextPos{Ln: ln, Ch: tb.LineLen(ln)}, []byte("\n"), true, true)
+		ln++
+	}
+	tv.SetCursorShow(TextPos{Ln: ln})
+	if tb.Opts.AutoIndent {
+		_, _, cpos := tb.AutoIndent(ln, DefaultIndentStrings, DefaultUnindentStrings)
+		tv.SetCursorShow(TextPos{Ln: ln, Ch: cpos})
+	}
+	tv.VimStartInsert(cnt)
+	tb.UndoGroupEnd()
+}
+
+// VimPut puts the text of the current register after (or before) the
+// cursor, or as new lines below (or above) the cursor line for line-wise
+// text, cnt times (p, P) -- in visual mode, the text replaces the selection
+func (tv *TextView) VimPut(before bool, cnt int) {
+	vs := &tv.Vim
+	rg := tv.VimGetRegister()
+	if rg == nil || len(rg.Text) == 0 {
+		vs.Msg = "E353: Nothing in register"
+		return
+	}
+	tb := tv.Buf
+	vs.Change = true
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tb.UndoGroupStart()
+	defer tb.UndoGroupEnd()
+	if vs.IsVisual() {
+		st, ed, lines := tv.VimVisualRegion()
+		tv.VimSetMode(VimNormal)
+		tv.VimDelete(st, ed, lines)
+		if lines && !rg.Lines {
+			tb.InsertText(TextPos{Ln: st.Ln}, []byte("\n"), true, true)
+		}
+		tv.SetCursorShow(st)
+		before = true
+	}
+	txt := bytes.Repeat(rg.Text, cnt)
+	cur := tv.CursorPos
+	if rg.Lines {
+		if !bytes.HasSuffix(txt, []byte("\n")) {
+			txt = append(txt, '\n')
+		}
+		ln := cur.Ln
+		if !before {
+			ln++
+		}
+		if ln >= tb.NumLines() {
+			ep := tb.EndPos()
+			tb.InsertText(ep, append([]byte("\n"), txt[:len(txt)-1]...), true, true)
+		} else {
+			tb.InsertText(TextPos{Ln: ln}, txt, true, true)
+		}
+		tv.SetCursorShow(tv.VimFirstNonBlank(ln))
+	} else {
+		pos := cur
+		if !before && tb.LineLen(cur.Ln) > 0 {
+			pos.Ch++
+		}
+		tbe := tb.InsertText(pos, txt, true, true)
+		if tbe != nil {
+			ep := tbe.Reg.End
+			if ep.Ch > 0 {
+				ep.Ch--
+			}
+			tv.SetCursorShow(ep)
+		}
+	}
+	tv.SetCursorCol(tv.CursorPos)
+}
+
+// VimRepeat repeats the last change cnt times (.) as a single undo step
+func (tv *TextView) VimRepeat(cnt int) {
+	vs := &tv.Vim
+	keys := vs.LastChange
+	if len(keys) == 0 || vs.Replaying {
+		return
+	}
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tv.Buf.UndoGroupStart()
+	vs.Reset()
+	vs.Replaying = true
+	for i := 0; i < cnt; i++ {
+		tv.VimReplay(keys)
+	}
+	vs.Replaying = false
+	tv.Buf.UndoGroupEnd()
+	vs.Change = false
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Registers
+
+// VimSetRegister stores text into the register selected for the current
+// command, and into the unnamed register -- yanks also go into register 0,
+// and deletes shift through registers 1..9 (or - for small deletes)
+func (tv *TextView) VimSetRegister(txt []byte, lines, yank bool) {
+	reg := tv.Vim.Reg
+	if reg == '_' {
+		return
+	}
+	rg := &VimRegister{Text: append([]byte{}, txt...), Lines: lines}
+	switch {
+	case reg >= 'A' && reg <= 'Z':
+		lr := unicode.ToLower(reg)
+		if prv, ok := VimRegisters[lr]; ok {
+			rg = &VimRegister{Text: append(append([]byte{}, prv.Text...), txt...), Lines: prv.Lines || lines}
+		}
+		VimRegisters[lr] = rg
+	case reg == '+' || reg == '*':
+		oswin.TheApp.ClipBoard(tv.Viewport.Win.OSWin).Write(mimedata.NewTextBytes(txt))
+	case reg != 0 && reg != '"':
+		VimRegisters[reg] = rg
+	case yank:
+		VimRegisters['0'] = rg
+	case lines || bytes.IndexByte(txt, '\n') >= 0:
+		for i := '9'; i > '1'; i-- {
+			if prv, ok := VimRegisters[i-1]; ok {
+				VimRegisters[i] = prv
+			}
+		}
+		VimRegisters['1'] = rg
+	default:
+		VimRegisters['-'] = rg
+	}
+	VimRegisters['"'] = rg
+	TextViewClipHistAdd(rg.Text)
+}
+
+// VimGetRegister returns the register selected for the current command --
+// the unnamed register if none -- nil if empty
+func (tv *TextView) VimGetRegister() *VimRegister {
+	reg := tv.Vim.Reg
+	switch {
+	case reg == '+' || reg == '*':
+		data := oswin.TheApp.ClipBoard(tv.Viewport.Win.OSWin).Read([]string{filecat.TextPlain})
+		if data == nil {
+			return nil
+		}
+		txt := data.TypeData(filecat.TextPlain)
+		return &VimRegister{Text: txt, Lines: bytes.HasSuffix(txt, []byte("\n"))}
+	case reg >= 'A' && reg <= 'Z':
+		reg = unicode.ToLower(reg)
+	case reg == 0:
+		reg = '"'
+	}
+	return VimRegisters[reg]
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Command line
+
+// VimStartCmdLine starts entering a command on the command line, with given
+// prefix: ':' for commands, or '/' and '?' for search
+func (tv *TextView) VimStartCmdLine(prefix rune) {
+	vs := &tv.Vim
+	vs.CmdPrefix = prefix
+	vs.CmdLine = ""
+	vs.CmdHistIdx = len(VimCmdHist)
+	if vs.IsVisual() && prefix == ':' {
+		vs.CmdLine = "'<,'>"
+		tv.VimSetMode(VimNormal)
+	} else if vs.HasCount() && prefix == ':' {
+		vs.CmdLine = fmt.Sprintf(".,.+%v", vs.CountVal()-1)
+	}
+	vs.Reset()
+	tv.VimSetMode(VimCmdLine)
+}
+
+// VimCmdLineKey handles a key while entering a command line
+func (tv *TextView) VimCmdLineKey(kt *key.ChordEvent) {
+	vs := &tv.Vim
+	switch kt.Chord() {
+	case "Escape", "Control+[", "Control+C":
+		vs.CmdLine = ""
+		tv.VimSetMode(VimNormal)
+	case "ReturnEnter", "KeypadEnter":
+		cmd := vs.CmdLine
+		vs.CmdLine = ""
+		tv.VimSetMode(VimNormal)
+		if vs.CmdPrefix == ':' {
+			tv.VimExecCmd(cmd)
+		} else {
+			if cmd != "" {
+				vs.SearchStr = cmd
+			}
+			vs.SearchBack = vs.CmdPrefix == '?'
+			if pos, ok := tv.VimSearchPos(vs.SearchBack, 1); ok {
+				tv.SavePosHistory(tv.CursorPos)
+				tv.VimMoveCursor(pos, false)
+			}
+		}
+	case "DeleteBackspace":
+		if vs.CmdLine == "" {
+			tv.VimSetMode(VimNormal)
+			return
+		}
+		rs := []rune(vs.CmdLine)
+		vs.CmdLine = string(rs[:len(rs)-1])
+	case "UpArrow", "DownArrow":
+		if vs.CmdPrefix != ':' || len(VimCmdHist) == 0 {
+			return
+		}
+		if kt.Chord() == "UpArrow" {
+			vs.CmdHistIdx = ints.MaxInt(vs.CmdHistIdx-1, 0)
+		} else {
+			vs.CmdHistIdx = ints.MinInt(vs.CmdHistIdx+1, len(VimCmdHist))
+		}
+		if vs.CmdHistIdx < len(VimCmdHist) {
+			vs.CmdLine = VimCmdHist[vs.CmdHistIdx]
+		} else {
+			vs.CmdLine = ""
+		}
+	default:
+		if unicode.IsPrint(kt.Rune) && !kt.HasAnyModifier(key.Control, key.Meta, key.Alt) {
+			vs.CmdLine += string(kt.Rune)
+		}
+	}
+}
+
+// vimParseAddr parses one line address at the start of s -- a number, . for
+// the cursor line, $ for the last line, '< or '> for the last visual
+// selection, each with optional +n / -n offsets -- returns the 0-based line
+// and the remaining string, and false if there was no address
+func (tv *TextView) vimParseAddr(s string) (ln int, rest string, ok bool) {
+	vs := &tv.Vim
+	switch {
+	case s == "":
+		return 0, s, false
+	case s[0] == '.':
+		ln, s, ok = tv.CursorPos.Ln, s[1:], true
+	case s[0] == '$':
+		ln, s, ok = tv.Buf.NumLines()-1, s[1:], true
+	case strings.HasPrefix(s, "'<"):
+		ln, s, ok = vs.VisLines[0], s[2:], true
+	case strings.HasPrefix(s, "'>"):
+		ln, s, ok = vs.VisLines[1], s[2:], true
+	case s[0] >= '0' && s[0] <= '9':
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		n, _ := strconv.Atoi(s[:i])
+		ln, s, ok = n-1, s[i:], true
+	case s[0] == '+' || s[0] == '-':
+		ln, ok = tv.CursorPos.Ln, true
+	default:
+		return 0, s, false
+	}
+	for len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		sign := 1
+		if s[0] == '-' {
+			sign = -1
+		}
+		i := 1
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		n := 1
+		if i > 1 {
+			n, _ = strconv.Atoi(s[1:i])
+		}
+		ln += sign * n
+		s = s[i:]
+	}
+	return ln, s, ok
+}
+
+// VimParseRange parses the line range at the start of a : command, returning
+// the 0-based start and end lines (the cursor line if none), the remaining
+// command, and whether a range was given
+func (tv *TextView) VimParseRange(cmd string) (st, ed int, rest string, has bool) {
+	st = tv.CursorPos.Ln
+	ed = st
+	if strings.HasPrefix(cmd, "%") {
+		return 0, tv.Buf.NumLines() - 1, cmd[1:], true
+	}
+	ln, rest, ok := tv.vimParseAddr(cmd)
+	if !ok {
+		return st, ed, cmd, false
+	}
+	st, ed = ln, ln
+	if strings.HasPrefix(rest, ",") {
+		if ln2, rest2, ok2 := tv.vimParseAddr(rest[1:]); ok2 {
+			ed, rest = ln2, rest2
+		}
+	}
+	nl := tv.Buf.NumLines()
+	st = ints.MinInt(ints.MaxInt(st, 0), nl-1)
+	ed = ints.MinInt(ints.MaxInt(ed, 0), nl-1)
+	if ed < st {
+		st, ed = ed, st
+	}
+	return st, ed, rest, true
+}
+
+// VimExecCmd executes a : command: a line number to jump to, w [file], q,
+// wq, x, [range]s/pat/repl/[gi], [range]d, [range]y, [range]> and <, noh, u
+// and red -- any other command is sent as a TextViewVimCmd signal
+func (tv *TextView) VimExecCmd(cmd string) {
+	vs := &tv.Vim
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return
+	}
+	if len(VimCmdHist) == 0 || VimCmdHist[len(VimCmdHist)-1] != cmd {
+		VimCmdHist = append(VimCmdHist, cmd)
+		if len(VimCmdHist) > VimCmdHistMax {
+			VimCmdHist = VimCmdHist[len(VimCmdHist)-VimCmdHistMax:]
+		}
+	}
+	st, ed, rest, has := tv.VimParseRange(cmd)
+	rest = strings.TrimSpace(rest)
+	i := 0
+	for i < len(rest) && unicode.IsLetter(rune(rest[i])) {
+		i++
+	}
+	name := rest[:i]
+	args := rest[i:]
+	if name == "" && len(rest) > 0 && (rest[0] == '<' || rest[0] == '>') {
+		name = rest[:1]
+		args = rest[1:]
+	}
+	if name == "s" || name == "substitute" {
+		tv.VimSubstitute(st, ed, args)
+		return
+	}
+	force := strings.HasPrefix(args, "!")
+	if force {
+		args = args[1:]
+	}
+	args = strings.TrimSpace(args)
+	switch name {
+	case "":
+		if has {
+			tv.JumpToLine(ed + 1)
+			tv.VimMoveCursor(tv.VimFirstNonBlank(ed), false)
+			return
+		}
+	case "w", "write":
+		tv.VimWrite(args, force)
+		return
+	case "q", "quit":
+		tv.VimQuit(force)
+		return
+	case "wq", "x", "xit":
+		if tv.VimWrite(args, force) {
+			tv.VimQuit(force)
+		}
+		return
+	case "d", "delete", "y", "yank", "<", ">":
+		op := rune(name[0])
+		vs.Change = op != 'y'
+		tv.VimApplyOp(op, TextPos{Ln: st}, TextPos{Ln: ed}, true)
+		return
+	case "noh", "nohlsearch":
+		tv.Highlights = nil
+		tv.RenderAllLines()
+		return
+	case "u", "undo":
+		tv.Undo()
+		tv.VimClampCursor()
+		return
+	case "red", "redo":
+		tv.Redo()
+		tv.VimClampCursor()
+		return
+	}
+	if len(tv.TextViewSig.Cons) > 0 {
+		tv.TextViewSig.Emit(tv.This(), int64(TextViewVimCmd), cmd)
+		return
+	}
+	vs.Msg = "E492: Not an editor command: " + cmd
+}
+
+// VimWrite saves the buffer, to given file name if non-empty -- an existing
+// file of that name is only overwritten if forced with ! -- returns false if
+// it could not be saved, with the reason in the status message
+func (tv *TextView) VimWrite(fname string, force bool) bool {
+	vs := &tv.Vim
+	tb := tv.Buf
+	if fname != "" {
+		if _, err := os.Stat(fname); err == nil && !force && gi.FileName(fname) != tb.Filename {
+			vs.Msg = "E13: File exists (add ! to override)"
+			return false
+		}
+		if err := tb.SaveFile(gi.FileName(fname)); err != nil {
+			vs.Msg = err.Error()
+			return false
+		}
+		vs.Msg = fmt.Sprintf("%q %vL written", fname, tb.NumLines())
+		return true
+	}
+	if tb.Filename == "" {
+		vs.Msg = "E32: No file name"
+		return false
+	}
+	if err := tb.Save(); err != nil {
+		vs.Msg = err.Error()
+		return false
+	}
+	vs.Msg = fmt.Sprintf("%q %vL written", string(tb.Filename), tb.NumLines())
+	return true
+}
+
+// VimQuit handles the q command: unless forced, the buffer must not have
+// unsaved changes -- sends a TextViewVimCmd signal with "q" if there are
+// receivers, and otherwise closes the window
+func (tv *TextView) VimQuit(force bool) {
+	if !force && tv.Buf.IsChanged() {
+		tv.Vim.Msg = "E37: No write since last change (add ! to override)"
+		return
+	}
+	if len(tv.TextViewSig.Cons) > 0 {
+		tv.TextViewSig.Emit(tv.This(), int64(TextViewVimCmd), "q")
+		return
+	}
+	if win := tv.ParentWindow(); win != nil {
+		win.CloseReq()
+	}
+}
+
+// vimSplitSubst splits the arguments of a substitute command into the
+// pattern, replacement and flags, using the first character as delimiter
+func vimSplitSubst(args string) (pat, repl, flags string, ok bool) {
+	if args == "" {
+		return
+	}
+	delim := args[0]
+	var parts []string
+	var cur strings.Builder
+	for i := 1; i < len(args); i++ {
+		c := args[i]
+		if c == '\\' && i+1 < len(args) && args[i+1] == delim {
+			cur.WriteByte(delim)
+			i++
+			continue
+		}
+		if c == delim && len(parts) < 2 {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	parts = append(parts, cur.String())
+	if len(parts) < 2 {
+		return parts[0], "", "", true
+	}
+	if len(parts) < 3 {
+		return parts[0], parts[1], "", true
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// vimReplTmpl converts a vim replacement string (& and \0..\9 for matches)
+// into a Go regexp template
+func vimReplTmpl(repl string) string {
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		switch {
+		case c == '&':
+			b.WriteString("${0}")
+		case c == '$':
+			b.WriteString("$$")
+		case c == '\\' && i+1 < len(repl):
+			i++
+			n := repl[i]
+			if n >= '0' && n <= '9' {
+				b.WriteString("${" + string(n) + "}")
+			} else if n == 't' {
+				b.WriteByte('\t')
+			} else {
+				b.WriteByte(n)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// VimSubstitute does the s/pat/repl/flags command over given lines -- the
+// pattern uses Go regexp syntax, and flags are g (all matches in each line)
+// and i (ignore case) -- all substitutions are a single undo step
+func (tv *TextView) VimSubstitute(stln, edln int, args string) {
+	vs := &tv.Vim
+	tb := tv.Buf
+	pat, repl, flags, ok := vimSplitSubst(args)
+	if !ok || pat == "" {
+		if pat == "" && vs.SearchStr != "" {
+			pat = regexp.QuoteMeta(vs.SearchStr)
+		} else {
+			vs.Msg = "E35: No previous regular expression"
+			return
+		}
+	}
+	if strings.Contains(flags, "i") {
+		pat = "(?i)" + pat
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		vs.Msg = "E486: " + err.Error()
+		return
+	}
+	tmpl := vimReplTmpl(repl)
+	global := strings.Contains(flags, "g")
+	updt := tv.Viewport.Win.UpdateStart()
+	defer tv.Viewport.Win.UpdateEnd(updt)
+	tb.UndoGroupStart()
+	defer tb.UndoGroupEnd()
+	nsub := 0
+	nln := 0
+	last := -1
+	for ln := stln; ln <= edln && ln < tb.NumLines(); ln++ {
+		lstr := string(tb.Line(ln))
+		var nstr string
+		nmatch := 1
+		if global {
+			nmatch = len(re.FindAllStringIndex(lstr, -1))
+			nstr = re.ReplaceAllString(lstr, tmpl)
+		} else {
+			loc := re.FindStringSubmatchIndex(lstr)
+			if loc == nil {
+				continue
+			}
+			dst := re.ExpandString(nil, tmpl, lstr, loc)
+			nstr = lstr[:loc[0]] + string(dst) + lstr[loc[1]:]
+		}
+		if nstr == lstr {
+			continue
+		}
+		nsub += nmatch
+		nln++
+		last = ln
+		tb.DeleteText(TextPos{Ln: ln}, TextPos{Ln: ln, Ch: tb.LineLen(ln)}, true, true)
+		tb.InsertText(TextPos{Ln: ln}, []byte(nstr), true, true)
+	}
+	if nsub == 0 {
+		vs.Msg = "E486: Pattern not found: " + pat
+		return
+	}
+	vs.Change = true
+	if nsub > 1 {
+		vs.Msg = fmt.Sprintf("%v substitutions on %v lines", nsub, nln)
+	}
+	tv.VimMoveCursor(tv.VimFirstNonBlank(last), false)
+}
+
+/////////////////////////////////////////////////////////////////////////////
+//   Status line
+
+// VimStatusString returns the text shown in the vim status line: the
+// command line being entered, or any message, or the current mode, followed
+// by the keys of any partially-entered command
+func (tv *TextView) VimStatusString() string {
+	vs := &tv.Vim
+	if vs.Mode == VimCmdLine {
+		return string(vs.CmdPrefix) + vs.CmdLine
+	}
+	st := vs.Msg
+	if st == "" {
+		switch vs.Mode {
+		case VimInsert:
+			st = "-- INSERT --"
+		case VimVisual:
+			st = "-- VISUAL --"
+		case VimVisualLine:
+			st = "-- VISUAL LINE --"
+		}
+	}
+	if ps := vs.PendingString(); ps != "" {
+		st += "    " + ps
+	}
+	return st
+}
+
+// RenderVimStatus renders the vim status line at the bottom of the visible
+// part of the view -- called in the context of other rendering, after
+// PushBounds -- returns the box rendered, which is empty if vim is not on
+func (tv *TextView) RenderVimStatus() image.Rectangle {
+	if !tv.VimOn() {
+		return image.ZR
+	}
+	rs := &tv.Viewport.Render
+	pc := &rs.Paint
+	sty := &tv.Sty
+	spc := sty.BoxSpace()
+	bb := tv.VpBBox
+	lh := int(math32.Ceil(tv.LineHeight))
+	if lh == 0 || bb.Dy() < 2*lh {
+		return image.ZR
+	}
+	bb.Min.Y = bb.Max.Y - lh
+	rs.Lock()
+	clr := sty.Font.BgColor.Color.Highlight(20)
+	pc.FillBoxColor(rs, gi.NewVec2DFmPoint(bb.Min), gi.NewVec2DFmPoint(bb.Size()), clr)
+	fst := sty.Font
+	fst.BgColor.SetColor(nil)
+	tv.VimRender.SetString(tv.VimStatusString(), &fst, &sty.UnContext, &sty.Text, true, 0, 0)
+	pos := gi.NewVec2DFmPoint(bb.Min)
+	pos.X += spc + tv.LineNoOff
+	tv.VimRender.RenderTopPos(rs, pos)
+	rs.Unlock()
+	return bb
+}
+
+// VimRenderStatus renders the vim status line and uploads it to the window --
+// called whenever the mode or command line changes
+func (tv *TextView) VimRenderStatus() {
+	if tv == nil || tv.This() == nil || tv.Viewport == nil {
+		return
+	}
+	if !tv.This().(gi.Node2D).IsVisible() {
+		return
+	}
+	vp := tv.Viewport
+	updt := vp.Win.UpdateStart()
+	rs := &vp.Render
+	rs.PushBounds(tv.VpBBox)
+	bb := tv.RenderVimStatus()
+	rs.PopBounds()
+	if bb != image.ZR {
+		vp.Win.UploadVpRegion(vp, bb, tv.WinBBox.Add(bb.Min.Sub(tv.VpBBox.Min)))
+	}
+	vp.Win.UpdateEnd(updt)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/driver/offscreen"
+	"github.com/goki/gi/oswin/key"
+)
+
+// testTextView returns a text view of a buffer with given text, in a
+// viewport and window that are not opened, with vim mode on
+func testTextView(t *testing.T, txt string) *TextView {
+	t.Helper()
+	offscreen.Main(func(app oswin.App) {
+		gi.FontLibrary.InitFontPaths(app.FontPaths()...)
+	})
+	gi.SetActiveKeyMapName(gi.DefaultKeyMap)
+	gi.Prefs.EditMode = gi.EditVim
+	win := &gi.Window{}
+	win.InitName(win, "win")
+	vp := &gi.Viewport2D{}
+	vp.InitName(vp, "vp")
+	vp.Win = win
+	sz := image.Point{400, 300}
+	vp.Pixels = image.NewRGBA(image.Rectangle{Max: sz})
+	vp.Render.Init(sz.X, sz.Y, vp.Pixels)
+	vp.Geom.Size = sz
+	ly := vp.AddNewChild(gi.KiT_Layout, "ly").(*gi.Layout)
+	tb := &TextBuf{}
+	tb.InitName(tb, "tb")
+	tb.SetText([]byte(txt))
+	tv := ly.AddNewChild(KiT_TextView, "tv").(*TextView)
+	tv.Viewport = vp
+	tv.SetBuf(tb)
+	return tv
+}
+
+// vimType sends the keys to the view, as if typed -- <Esc> and <CR> are
+// escape and return
+func vimType(tv *TextView, keys string) {
+	for keys != "" {
+		ke := &key.ChordEvent{}
+		ke.Action = key.Press
+		switch {
+		case strings.HasPrefix(keys, "<Esc>"):
+			ke.Rune, ke.Code = -1, key.CodeEscape
+			keys = keys[5:]
+		case strings.HasPrefix(keys, "<CR>"):
+			ke.Rune, ke.Code = '\r', key.CodeReturnEnter
+			keys = keys[4:]
+		default:
+			r := []rune(keys)[0]
+			ke.Rune = r
+			keys = keys[len(string(r)):]
+		}
+		tv.KeyInput(ke)
+	}
+}
+
+func TestVimKeys(t *testing.T) {
+	const txt = "one (two) x\ntwo\nthree\nfour\nfive\n"
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"x", "2x", "e (two) x\ntwo\nthree\nfour\nfive\n"},
+		{"dd", "dd", "two\nthree\nfour\nfive\n"},
+		{"dd count", "2dd", "three\nfour\nfive\n"},
+		{"dd repeat", "dd.", "three\nfour\nfive\n"},
+		{"d motion", "d2j", "four\nfive\n"},
+		{"dw repeat count", "dw3.", "x\ntwo\nthree\nfour\nfive\n"},
+		{"cc", "ccxx<Esc>", "xx\ntwo\nthree\nfour\nfive\n"},
+		{"cc count", "2ccxx<Esc>", "xx\nthree\nfour\nfive\n"},
+		{"cj", "cjxx<Esc>", "xx\nthree\nfour\nfive\n"},
+		{"S count", "3Sxx<Esc>", "xx\nfour\nfive\n"},
+		{"yy p", "yyjp", "one (two) x\ntwo\none (two) x\nthree\nfour\nfive\n"},
+		{"yy count", "2yyGp", "one (two) x\ntwo\nthree\nfour\nfive\none (two) x\ntwo\n"},
+		{"dd p count", "jdd2p", "one (two) x\nthree\ntwo\ntwo\nfour\nfive\n"},
+		{"iw", "diw", " (two) x\ntwo\nthree\nfour\nfive\n"},
+		{"yiw P", "wwyiwjP", "one (two) x\ntwtwoo\nthree\nfour\nfive\n"},
+		{"i(", "wwdi(", "one () x\ntwo\nthree\nfour\nfive\n"},
+		{"a(", "wwda(", "one  x\ntwo\nthree\nfour\nfive\n"},
+		{"ci(", "wwci(z<Esc>", "one (z) x\ntwo\nthree\nfour\nfive\n"},
+		{"registers", "\"ayyj\"bddj\"ap\"bp", "one (two) x\nthree\nfour\none (two) x\ntwo\nfive\n"},
+		{"undo", "3ddu", txt},
+		{"undo insert", "ixyz<Esc>u", txt},
+		{"undo change", "2ccxx<Esc>u", txt},
+		{"undo one insert", "ixyz<Esc>ja<CR>q<Esc>u", "xyzone (two) x\ntwo\nthree\nfour\nfive\n"},
+		{"undo two inserts", "ixyz<Esc>ja<CR>q<Esc>uu", txt},
+	}
+	for _, tt := range tests {
+		tv := testTextView(t, txt)
+		vimType(tv, tt.keys)
+		if got := vimText(tv); got != tt.want {
+			t.Errorf("%v: %q gives %q, want %q", tt.name, tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestVimChangeLinesCursor(t *testing.T) {
+	tv := testTextView(t, "one\ntwo\nthree\n")
+	vimType(tv, "j2ccxx")
+	if tv.CursorPos != (TextPos{Ln: 1, Ch: 2}) {
+		t.Errorf("cursor after 2cc: %v, want 1:2", tv.CursorPos)
+	}
+	vimType(tv, "<Esc>")
+	if got, want := vimText(tv), "one\nxx\n"; got != want {
+		t.Errorf("2cc on last lines: %q, want %q", got, want)
+	}
+}
+
+func vimText(tv *TextView) string {
+	return string(tv.Buf.Text())
+}
+
+func TestVimMultiCursorKeys(t *testing.T) {
+	tv := testTextView(t, "one\ntwo\nthree\n")
+	tv.AddCursor(TextPos{Ln: 1})
+	if !tv.HasMultiCursors() {
+		t.Fatal("no multiple cursors")
+	}
+	vimType(tv, "x")
+	if got, want := vimText(tv), "one\nwo\nthree\n"; got != want {
+		t.Errorf("normal mode x with cursors: %q, want %q", got, want)
+	}
+	vimType(tv, "iz")
+	if got, want := vimText(tv), "zone\nzwo\nthree\n"; got != want {
+		t.Errorf("insert mode with cursors: %q, want %q", got, want)
+	}
+}
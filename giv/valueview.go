@@ -834,7 +834,7 @@ func (vi *ViewIFace) PrefsDbgView(prefs *gi.PrefsDebug) {
 // VersCtrlSystems is a list of supported Version Control Systems -- use these
 // names in commands to select commands for the current VCS for this project
 // (i.e., use shortest version of name, typically three letters)
-var VersCtrlSystems = []string{"Git", "SVN"}
+var VersCtrlSystems = []string{"Git", "SVN", "Hg", "Bzr"}
 
 // VersCtrlName is the name of a version control system
 type VersCtrlName string
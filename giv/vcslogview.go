@@ -0,0 +1,279 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package giv
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/units"
+	"github.com/goki/gi/vci"
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+)
+
+// VcsLogView is a log browser for a file in version control: it shows the
+// commits of the file, most recent first, and the toolbar shows the file as
+// of the selected commit, or the differences between that and its current
+// contents (double-click also shows the differences).  It also shows the
+// current branch, and switches branches, and stashes and restores
+// uncommitted changes, which apply to the whole repository.
+type VcsLogView struct {
+	gi.Frame
+	Repo   vci.Repo    `json:"-" xml:"-" desc:"the version control repository"`
+	File   string      `desc:"the file the log is of -- the whole repository if empty, in which case the file views are not available"`
+	Log    vci.Log     `desc:"the commits of the file, most recent first"`
+	Buf    *TextBuf    `json:"-" xml:"-" desc:"buffer of the current contents of the file, if open -- used for the differences, and its file info and highlighting for the versions shown"`
+	RepoMu *sync.Mutex `json:"-" xml:"-" desc:"mutex protecting the repository and its caches, if shared, e.g., FileTree.RepoMu -- locked around the commands that change the working tree"`
+}
+
+var KiT_VcsLogView = kit.Types.AddType(&VcsLogView{}, VcsLogViewProps)
+
+var VcsLogViewProps = ki.Props{
+	"background-color": &gi.Prefs.Colors.Background,
+	"color":            &gi.Prefs.Colors.Font,
+	"max-width":        -1,
+	"max-height":       -1,
+}
+
+// SetRepo sets the repository and file to show the log of, with buffer of
+// its current contents if open (else nil), and updates the view
+func (lv *VcsLogView) SetRepo(repo vci.Repo, file string, buf *TextBuf) error {
+	lv.Repo = repo
+	lv.File = file
+	lv.Buf = buf
+	lv.Config()
+	return lv.UpdateLog()
+}
+
+// Config configures the toolbar and the table of commits
+func (lv *VcsLogView) Config() {
+	lv.Lay = gi.LayoutVert
+	config := kit.TypeAndNameList{}
+	config.Add(gi.KiT_ToolBar, "toolbar")
+	config.Add(KiT_TableView, "log")
+	mods, updt := lv.ConfigChildren(config, false)
+	if !mods {
+		updt = lv.UpdateStart()
+	}
+	lv.ConfigToolbar()
+	tv := lv.TableView()
+	if mods {
+		tv.SetInactive()
+		tv.TableViewSig.Connect(lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			if sig == int64(TableViewDoubleClicked) {
+				lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+				lvv.DiffRev(lvv.TableView().SelectedIdx)
+			}
+		})
+	}
+	lv.UpdateEnd(updt)
+}
+
+// ConfigToolbar adds the actions to the toolbar, if not already done
+func (lv *VcsLogView) ConfigToolbar() {
+	tb := lv.ToolBar()
+	if tb.HasChildren() {
+		return
+	}
+	tb.SetStretchMaxWidth()
+	hasSel := func(act *gi.Action) {
+		act.SetActiveStateUpdt(lv.File != "" && lv.TableView().SelectedIdx >= 0)
+	}
+	tb.AddAction(gi.ActOpts{Label: "View", Icon: "file-text", Tooltip: "view the file as of the selected commit", UpdateFunc: hasSel},
+		lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+			lvv.ViewRev(lvv.TableView().SelectedIdx)
+		})
+	tb.AddAction(gi.ActOpts{Label: "Diff", Icon: "edit", Tooltip: "show the differences between the file as of the selected commit and its current contents", UpdateFunc: hasSel},
+		lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+			lvv.DiffRev(lvv.TableView().SelectedIdx)
+		})
+	tb.AddNewChild(gi.KiT_Separator, "sep-branch")
+	lbl := tb.AddNewChild(gi.KiT_Label, "branch").(*gi.Label)
+	lbl.SetMinPrefWidth(units.NewValue(16, units.Ch))
+	tb.AddAction(gi.ActOpts{Label: "Branch...", Icon: "widget-wedge-down", Tooltip: "switch to another local branch"},
+		lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+			lvv.SwitchBranch()
+		})
+	tb.AddAction(gi.ActOpts{Label: "Stash...", Icon: "file-save", Tooltip: "save the uncommitted changes in the repository with a message, reverting them"},
+		lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+			lvv.Stash()
+		})
+	tb.AddAction(gi.ActOpts{Label: "Pop Stash", Icon: "file-open", Tooltip: "restore the most recently stashed changes, removing the stash"},
+		lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+			lvv.StashPop()
+		})
+	tb.AddNewChild(gi.KiT_Separator, "sep-refresh")
+	tb.AddAction(gi.ActOpts{Label: "Refresh", Icon: "update", Tooltip: "reload the log"},
+		lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+			lvv.ErrorPrompt("Could not Reload Log", lvv.UpdateLog())
+		})
+}
+
+// ToolBar returns the toolbar
+func (lv *VcsLogView) ToolBar() *gi.ToolBar {
+	return lv.ChildByName("toolbar", 0).(*gi.ToolBar)
+}
+
+// TableView returns the table of commits
+func (lv *VcsLogView) TableView() *TableView {
+	return lv.ChildByName("log", 1).(*TableView)
+}
+
+// UpdateLog reloads the log, and the current branch
+func (lv *VcsLogView) UpdateLog() error {
+	if lv.Repo == nil {
+		return nil
+	}
+	lg, err := lv.Repo.Log(lv.File)
+	if err != nil {
+		return err
+	}
+	updt := lv.UpdateStart()
+	lv.Log = lg
+	lv.TableView().SetSlice(&lv.Log, nil)
+	lbl := lv.ToolBar().ChildByName("branch", 3).(*gi.Label)
+	if cur, err := lv.Repo.Current(); err == nil {
+		lbl.SetText("branch: " + cur)
+	} else {
+		lbl.SetText("")
+	}
+	lv.UpdateEnd(updt)
+	return nil
+}
+
+// ErrorPrompt shows given error, if not nil, in a dialog with given title
+func (lv *VcsLogView) ErrorPrompt(title string, err error) {
+	if err != nil {
+		gi.PromptDialog(lv.Viewport, gi.DlgOpts{Title: title, Prompt: err.Error()}, true, false, nil, nil)
+	}
+}
+
+// RevBuf returns a new buffer with the file as of given commit, with the
+// file info and highlighting of the current buffer, if any
+func (lv *VcsLogView) RevBuf(idx int) (*TextBuf, error) {
+	if lv.File == "" || idx < 0 || idx >= len(lv.Log) {
+		return nil, fmt.Errorf("giv.VcsLogView: no commit selected")
+	}
+	txt, err := lv.Repo.Show(lv.File, lv.Log[idx].Rev)
+	if err != nil {
+		return nil, err
+	}
+	return newTextBufLike("rev-"+lv.Log[idx].Rev, txt, lv.Buf), nil
+}
+
+// revTitle returns the title for a view of the file as of given commit
+func (lv *VcsLogView) revTitle(idx int) string {
+	rev := lv.Log[idx].Rev
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	return fmt.Sprintf("%v @ %v", filepath.Base(lv.File), rev)
+}
+
+// ViewRev shows the file as of given commit, in a dialog
+func (lv *VcsLogView) ViewRev(idx int) {
+	tb, err := lv.RevBuf(idx)
+	if err != nil {
+		lv.ErrorPrompt("Could not View File", err)
+		return
+	}
+	TextViewDialog(lv.Viewport, tb, DlgOpts{Title: lv.revTitle(idx), Prompt: lv.Log[idx].Message}, nil, nil)
+}
+
+// DiffRev shows the differences between the file as of given commit and
+// its current contents, in a DiffViewDialog
+func (lv *VcsLogView) DiffRev(idx int) {
+	tb, err := lv.RevBuf(idx)
+	if err != nil {
+		lv.ErrorPrompt("Could not Diff File", err)
+		return
+	}
+	cur := lv.Buf
+	if cur == nil {
+		cur = &TextBuf{}
+		cur.InitName(cur, "current")
+		fn := lv.File
+		if !filepath.IsAbs(fn) {
+			fn = filepath.Join(lv.Repo.LocalPath(), fn)
+		}
+		if err := cur.Open(gi.FileName(fn)); err != nil {
+			lv.ErrorPrompt("Could not Diff File", err)
+			return
+		}
+	}
+	DiffViewDialog(lv.Viewport, tb, cur, DlgOpts{Title: "Diff: " + lv.revTitle(idx) + " <-> current"}, nil, nil)
+}
+
+// SwitchBranch pops up a chooser of the local branches, and switches to the
+// one selected
+func (lv *VcsLogView) SwitchBranch() {
+	brs, err := lv.Repo.LocalBranches()
+	if err != nil {
+		lv.ErrorPrompt("Could not List Branches", err)
+		return
+	}
+	cur, _ := lv.Repo.Current()
+	gi.StringsChooserPopup(brs, cur, lv.ToolBar(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ac := send.(*gi.Action)
+		if ac.Text == cur {
+			return
+		}
+		lv.lockRepo()
+		err := lv.Repo.SwitchBranch(ac.Text)
+		lv.unlockRepo()
+		if err != nil {
+			lv.ErrorPrompt("Could not Switch Branch", err)
+			return
+		}
+		lv.ErrorPrompt("Could not Reload Log", lv.UpdateLog())
+	})
+}
+
+// Stash prompts for a message, and stashes the uncommitted changes
+func (lv *VcsLogView) Stash() {
+	gi.StringPromptDialog(lv.Viewport, "", "Message..",
+		gi.DlgOpts{Title: "Stash Changes", Prompt: "Message describing the changes to stash -- they are reverted, and can be restored with Pop Stash"},
+		lv.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			dlg := send.(*gi.Dialog)
+			if sig == int64(gi.DialogAccepted) {
+				lvv := recv.Embed(KiT_VcsLogView).(*VcsLogView)
+				lvv.lockRepo()
+				err := lvv.Repo.Stash(gi.StringPromptDialogValue(dlg))
+				lvv.unlockRepo()
+				lvv.ErrorPrompt("Could not Stash Changes", err)
+			}
+		})
+}
+
+// StashPop restores the most recently stashed changes
+func (lv *VcsLogView) StashPop() {
+	lv.lockRepo()
+	err := lv.Repo.StashPop()
+	lv.unlockRepo()
+	lv.ErrorPrompt("Could not Pop Stash", err)
+}
+
+// lockRepo locks the RepoMu mutex, if set
+func (lv *VcsLogView) lockRepo() {
+	if lv.RepoMu != nil {
+		lv.RepoMu.Lock()
+	}
+}
+
+// unlockRepo unlocks the RepoMu mutex, if set
+func (lv *VcsLogView) unlockRepo() {
+	if lv.RepoMu != nil {
+		lv.RepoMu.Unlock()
+	}
+}
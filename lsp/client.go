@@ -0,0 +1,372 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lsp is a Language Server Protocol client, which runs a language
+// server as a sub-process and talks JSON-RPC with it over stdio, to provide
+// completion, diagnostics, definitions, references and renaming for
+// languages beyond those supported directly in GoPi.
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// TextDocumentSyncKind is how the server wants document changes sent
+type TextDocumentSyncKind int
+
+const (
+	// SyncNone means the server does not want changes at all
+	SyncNone TextDocumentSyncKind = iota
+
+	// SyncFull means the entire document is sent on each change
+	SyncFull
+
+	// SyncIncremental means only the changed ranges are sent
+	SyncIncremental
+)
+
+// DiagsFunc is called when the server publishes the full set of diagnostics
+// for a document -- called from the reading goroutine
+type DiagsFunc func(uri string, diags []Diagnostic)
+
+// ApplyEditFunc is called when the server asks the client to apply a
+// workspace edit (workspace/applyEdit) -- returns true if applied
+type ApplyEditFunc func(edit *WorkspaceEdit) bool
+
+// Client is a Language Server Protocol client connected to one server
+// process, typically shared by all open documents of a given language
+// under a given root directory
+type Client struct {
+	Config    ServerConfig         `desc:"the configuration the server was started with"`
+	RootDir   string               `desc:"root directory of the workspace, sent on initialize"`
+	Cmd       *exec.Cmd            `json:"-" desc:"the running server process -- nil if connected some other way (e.g., for testing)"`
+	Conn      *Conn                `json:"-" desc:"the JSON-RPC connection to the server"`
+	SyncKind  TextDocumentSyncKind `desc:"how the server wants document changes, from its capabilities"`
+	Caps      json.RawMessage      `json:"-" desc:"raw server capabilities returned from initialize"`
+	DiagsFunc DiagsFunc            `json:"-" desc:"called when diagnostics are published for a document"`
+	EditFunc  ApplyEditFunc        `json:"-" desc:"called when the server requests a workspace edit"`
+	mu        sync.Mutex
+	versions  map[string]int
+}
+
+// stdioRWC joins the stdin / stdout pipes of a server into one io.ReadWriteCloser
+type stdioRWC struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (rw *stdioRWC) Close() error {
+	err := rw.WriteCloser.Close()
+	if rerr := rw.ReadCloser.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Start launches the server given by config as a sub-process, talking over
+// its stdin / stdout, and initializes it for given workspace root directory
+func Start(cfg ServerConfig, rootDir string) (*Client, error) {
+	cmd := exec.Command(cfg.Cmd, cfg.Args...)
+	cmd.Dir = rootDir
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("lsp: could not start language server %v: %v", cfg.Cmd, err)
+	}
+	cl := NewClient(cfg, rootDir, &stdioRWC{ReadCloser: out, WriteCloser: in})
+	cl.Cmd = cmd
+	if err := cl.Initialize(); err != nil {
+		cl.Shutdown()
+		return nil, err
+	}
+	return cl, nil
+}
+
+// NewClient returns a new client talking to a server over given connection
+// -- Initialize must be called before anything else
+func NewClient(cfg ServerConfig, rootDir string, rwc io.ReadWriteCloser) *Client {
+	cl := &Client{Config: cfg, RootDir: rootDir}
+	cl.versions = make(map[string]int)
+	cl.Conn = NewConn(rwc, cl.Handle)
+	return cl
+}
+
+// Handle handles requests and notifications from the server
+func (cl *Client) Handle(method string, params json.RawMessage, hasID bool) (interface{}, error) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var pd PublishDiagnosticsParams
+		if err := json.Unmarshal(params, &pd); err != nil {
+			return nil, err
+		}
+		if cl.DiagsFunc != nil {
+			cl.DiagsFunc(pd.URI, pd.Diagnostics)
+		}
+	case "workspace/applyEdit":
+		var ap struct {
+			Edit WorkspaceEdit `json:"edit"`
+		}
+		if err := json.Unmarshal(params, &ap); err != nil {
+			return nil, err
+		}
+		applied := cl.EditFunc != nil && cl.EditFunc(&ap.Edit)
+		return map[string]bool{"applied": applied}, nil
+	case "workspace/configuration":
+		var cp struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &cp)
+		return make([]interface{}, len(cp.Items)), nil // no settings for anything
+	}
+	return nil, nil // everything else (progress, registration, log messages) is accepted and ignored
+}
+
+// Initialize performs the initialize handshake and records the server
+// capabilities
+func (cl *Client) Initialize() error {
+	params := map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   FileURI(cl.RootDir),
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"synchronization":    map[string]interface{}{"didSave": true},
+				"completion":         map[string]interface{}{"completionItem": map[string]interface{}{"snippetSupport": false}},
+				"hover":              map[string]interface{}{"contentFormat": []string{"plaintext"}},
+				"publishDiagnostics": map[string]interface{}{},
+				"definition":         map[string]interface{}{},
+				"references":         map[string]interface{}{},
+				"rename":             map[string]interface{}{},
+			},
+			"workspace": map[string]interface{}{"applyEdit": true, "configuration": true},
+		},
+	}
+	var res struct {
+		Capabilities json.RawMessage `json:"capabilities"`
+	}
+	tout := cl.Conn.Timeout
+	cl.Conn.Timeout = 30 * time.Second // servers can be slow to start up
+	err := cl.Conn.Call("initialize", params, &res)
+	cl.Conn.Timeout = tout
+	if err != nil {
+		return err
+	}
+	cl.Caps = res.Capabilities
+	cl.SyncKind = syncKind(res.Capabilities)
+	return cl.Conn.Notify("initialized", struct{}{})
+}
+
+// syncKind gets the textDocumentSync kind from the capabilities, which can
+// be either a number or an object with a change field
+func syncKind(caps json.RawMessage) TextDocumentSyncKind {
+	var cp struct {
+		TextDocumentSync json.RawMessage `json:"textDocumentSync"`
+	}
+	if json.Unmarshal(caps, &cp) != nil || len(cp.TextDocumentSync) == 0 {
+		return SyncFull
+	}
+	var kind TextDocumentSyncKind
+	if json.Unmarshal(cp.TextDocumentSync, &kind) == nil {
+		return kind
+	}
+	var opts struct {
+		Change TextDocumentSyncKind `json:"change"`
+	}
+	json.Unmarshal(cp.TextDocumentSync, &opts)
+	return opts.Change
+}
+
+// Version returns the current version number of given open document
+func (cl *Client) Version(uri string) int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.versions[uri]
+}
+
+// IsOpen returns true if given document has been opened with DidOpen
+func (cl *Client) IsOpen(uri string) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	_, has := cl.versions[uri]
+	return has
+}
+
+// DidOpen tells the server that given document is open, with given text
+func (cl *Client) DidOpen(uri, langID string, text []byte) error {
+	cl.mu.Lock()
+	cl.versions[uri] = 1
+	cl.mu.Unlock()
+	return cl.Conn.Notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: langID, Version: 1, Text: string(text)},
+	})
+}
+
+// DidChange sends changes to given open document, incrementing its version
+func (cl *Client) DidChange(uri string, changes []TextDocumentContentChangeEvent) error {
+	cl.mu.Lock()
+	cl.versions[uri]++
+	vers := cl.versions[uri]
+	cl.mu.Unlock()
+	return cl.Conn.Notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   VersionedTextDocumentIdentifier{URI: uri, Version: vers},
+		"contentChanges": changes,
+	})
+}
+
+// DidSave tells the server that given document was saved
+func (cl *Client) DidSave(uri string) error {
+	return cl.Conn.Notify("textDocument/didSave", map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+	})
+}
+
+// DidClose tells the server that given document is no longer open
+func (cl *Client) DidClose(uri string) error {
+	cl.mu.Lock()
+	delete(cl.versions, uri)
+	cl.mu.Unlock()
+	return cl.Conn.Notify("textDocument/didClose", map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+	})
+}
+
+func posParams(uri string, pos Position) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: pos}
+}
+
+// Completion returns the completion items at given position
+func (cl *Client) Completion(uri string, pos Position) ([]CompletionItem, error) {
+	var res json.RawMessage
+	if err := cl.Conn.Call("textDocument/completion", posParams(uri, pos), &res); err != nil {
+		return nil, err
+	}
+	res = bytes.TrimSpace(res)
+	if len(res) > 0 && res[0] == '[' {
+		var items []CompletionItem
+		err := json.Unmarshal(res, &items)
+		return items, err
+	}
+	var lst CompletionList
+	if len(res) == 0 || bytes.Equal(res, []byte("null")) {
+		return nil, nil
+	}
+	err := json.Unmarshal(res, &lst)
+	return lst.Items, err
+}
+
+// Hover returns the hover text at given position, and the range it applies to
+// if the server provided one
+func (cl *Client) Hover(uri string, pos Position) (string, *Range, error) {
+	var res *Hover
+	if err := cl.Conn.Call("textDocument/hover", posParams(uri, pos), &res); err != nil || res == nil {
+		return "", nil, err
+	}
+	return MarkupText(res.Contents), res.Range, nil
+}
+
+// Definition returns the location(s) where the symbol at given position is defined
+func (cl *Client) Definition(uri string, pos Position) ([]Location, error) {
+	var res json.RawMessage
+	if err := cl.Conn.Call("textDocument/definition", posParams(uri, pos), &res); err != nil {
+		return nil, err
+	}
+	return locations(res)
+}
+
+// References returns the locations of all references to the symbol at given
+// position, including its declaration if inclDecl
+func (cl *Client) References(uri string, pos Position, inclDecl bool) ([]Location, error) {
+	params := map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+		"position":     pos,
+		"context":      map[string]bool{"includeDeclaration": inclDecl},
+	}
+	var res json.RawMessage
+	if err := cl.Conn.Call("textDocument/references", params, &res); err != nil {
+		return nil, err
+	}
+	return locations(res)
+}
+
+// Rename returns the edits needed to rename the symbol at given position to
+// newName -- the client is responsible for applying them
+func (cl *Client) Rename(uri string, pos Position, newName string) (*WorkspaceEdit, error) {
+	params := map[string]interface{}{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+		"position":     pos,
+		"newName":      newName,
+	}
+	var res *WorkspaceEdit
+	if err := cl.Conn.Call("textDocument/rename", params, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// locations decodes the various forms of location results: null, a single
+// Location, a list of Locations, or a list of LocationLinks
+func locations(res json.RawMessage) ([]Location, error) {
+	res = bytes.TrimSpace(res)
+	if len(res) == 0 || bytes.Equal(res, []byte("null")) {
+		return nil, nil
+	}
+	if res[0] == '{' {
+		var loc Location
+		err := json.Unmarshal(res, &loc)
+		return []Location{loc}, err
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(res, &raws); err != nil {
+		return nil, err
+	}
+	locs := make([]Location, 0, len(raws))
+	for _, r := range raws {
+		var ll LocationLink
+		if json.Unmarshal(r, &ll) == nil && ll.TargetURI != "" {
+			locs = append(locs, Location{URI: ll.TargetURI, Range: ll.TargetSelectionRange})
+			continue
+		}
+		var loc Location
+		if err := json.Unmarshal(r, &loc); err != nil {
+			return locs, err
+		}
+		locs = append(locs, loc)
+	}
+	return locs, nil
+}
+
+// Shutdown asks the server to shut down and exit, closes the connection, and
+// waits for the process to finish
+func (cl *Client) Shutdown() error {
+	if !cl.Conn.IsClosed() {
+		cl.Conn.Call("shutdown", nil, nil)
+		cl.Conn.Notify("exit", nil)
+		cl.Conn.Close()
+	}
+	if cl.Cmd == nil || cl.Cmd.Process == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- cl.Cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		cl.Cmd.Process.Kill()
+		return <-done
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+// pipeRWC is one end of an in-process connection
+type pipeRWC struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (pp *pipeRWC) Close() error {
+	pp.PipeReader.Close()
+	return pp.PipeWriter.Close()
+}
+
+// stubServer runs a minimal language server on the other end of a pipe,
+// using a Conn for its side of the protocol
+func stubServer(t *testing.T) (*Client, chan []Diagnostic) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	var srv *Conn
+	srv = NewConn(&pipeRWC{sr, sw}, func(method string, params json.RawMessage, hasID bool) (interface{}, error) {
+		switch method {
+		case "initialize":
+			return map[string]interface{}{"capabilities": map[string]interface{}{"textDocumentSync": map[string]int{"change": 2}}}, nil
+		case "textDocument/didOpen":
+			var p struct {
+				TextDocument TextDocumentItem `json:"textDocument"`
+			}
+			json.Unmarshal(params, &p)
+			srv.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI,
+				Diagnostics: []Diagnostic{{Range: Range{Position{1, 2}, Position{1, 5}}, Severity: SevError, Message: "undefined: foo", Source: "stub"}}})
+		case "textDocument/completion":
+			return CompletionList{Items: []CompletionItem{{Label: "Println"}, {Label: "Printf", InsertText: "Printf"}}}, nil
+		case "textDocument/definition":
+			return []LocationLink{{TargetURI: "file:///a.go", TargetSelectionRange: Range{Position{3, 5}, Position{3, 8}}}}, nil
+		case "textDocument/references":
+			return []Location{{URI: "file:///a.go", Range: Range{Position{3, 5}, Position{3, 8}}}, {URI: "file:///b.go", Range: Range{Position{0, 1}, Position{0, 4}}}}, nil
+		case "textDocument/rename":
+			return WorkspaceEdit{Changes: map[string][]TextEdit{"file:///a.go": {{Range: Range{Position{3, 5}, Position{3, 8}}, NewText: "bar"}}}}, nil
+		case "textDocument/hover":
+			return Hover{Contents: json.RawMessage(`{"kind":"plaintext","value":"func foo()"}`)}, nil
+		}
+		return nil, nil
+	})
+	dch := make(chan []Diagnostic, 1)
+	cl := NewClient(ServerConfig{LangID: "go"}, "/tmp", &pipeRWC{cr, cw})
+	cl.DiagsFunc = func(uri string, diags []Diagnostic) { dch <- diags }
+	if err := cl.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return cl, dch
+}
+
+func TestClient(t *testing.T) {
+	cl, dch := stubServer(t)
+	defer cl.Conn.Close()
+	if cl.SyncKind != SyncIncremental {
+		t.Errorf("sync kind: %v != %v", cl.SyncKind, SyncIncremental)
+	}
+	uri := "file:///a.go"
+	cl.DidOpen(uri, "go", []byte("package a\nfunc foo() {}\n"))
+	select {
+	case diags := <-dch:
+		if len(diags) != 1 || diags[0].Message != "undefined: foo" || diags[0].Range.Start.Character != 2 {
+			t.Errorf("bad diagnostics: %v", diags)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no diagnostics published")
+	}
+	items, err := cl.Completion(uri, Position{1, 3})
+	if err != nil || len(items) != 2 || items[1].CompletionText() != "Printf" {
+		t.Errorf("bad completion: %v %v", items, err)
+	}
+	locs, err := cl.Definition(uri, Position{1, 6})
+	if err != nil || len(locs) != 1 || locs[0].Range.Start.Line != 3 {
+		t.Errorf("bad definition: %v %v", locs, err)
+	}
+	locs, err = cl.References(uri, Position{1, 6}, true)
+	if err != nil || len(locs) != 2 || locs[1].URI != "file:///b.go" {
+		t.Errorf("bad references: %v %v", locs, err)
+	}
+	we, err := cl.Rename(uri, Position{1, 6}, "bar")
+	if err != nil || we == nil || we.AllChanges()[uri][0].NewText != "bar" {
+		t.Errorf("bad rename: %v %v", we, err)
+	}
+	hv, _, err := cl.Hover(uri, Position{1, 6})
+	if err != nil || hv != "func foo()" {
+		t.Errorf("bad hover: %v %v", hv, err)
+	}
+}
+
+func TestUTF16(t *testing.T) {
+	rs := []rune("a😀b")
+	if n := UTF16Len(rs); n != 4 {
+		t.Errorf("UTF16Len: %v != 4", n)
+	}
+	if i := RuneIndex(rs, 3); i != 2 {
+		t.Errorf("RuneIndex: %v != 2", i)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned for calls on a connection that has been closed
+	ErrClosed = errors.New("lsp: connection closed")
+
+	// ErrTimeout is returned when the server does not respond to a call in time
+	ErrTimeout = errors.New("lsp: call timed out")
+)
+
+// Message is a JSON-RPC 2.0 message: a request has ID and Method, a
+// notification has only Method, and a response has ID and Result or Error
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *RespError       `json:"error,omitempty"`
+}
+
+// IsResponse returns true if this message is a response to one of our requests
+func (ms *Message) IsResponse() bool {
+	return ms.ID != nil && ms.Method == ""
+}
+
+// RespError is the error object in a JSON-RPC response
+type RespError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (re *RespError) Error() string {
+	return fmt.Sprintf("lsp: error %d: %s", re.Code, re.Message)
+}
+
+// HandlerFunc handles a request or notification sent by the server -- for
+// requests (hasID = true) the returned result is sent back as the response.
+// It is called from the reading goroutine, so it must not itself Call the
+// server (notifications are fine).
+type HandlerFunc func(method string, params json.RawMessage, hasID bool) (result interface{}, err error)
+
+// Conn is a JSON-RPC 2.0 connection using the LSP base protocol framing
+// (Content-Length headers) over an io.ReadWriteCloser, typically the stdin /
+// stdout of a language server process.  Read must be running for calls to
+// return -- it is started by NewConn.
+type Conn struct {
+	Timeout time.Duration `desc:"how long to wait for a response in Call"`
+	Handler HandlerFunc   `desc:"handler for requests and notifications from the server"`
+	rwc     io.ReadWriteCloser
+	rd      *bufio.Reader
+	wmu     sync.Mutex
+	mu      sync.Mutex
+	seq     int64
+	pending map[int64]chan *Message
+	done    chan struct{}
+	closed  bool
+}
+
+// NewConn returns a new connection on given reader-writer, and starts
+// reading messages in a separate goroutine
+func NewConn(rwc io.ReadWriteCloser, handler HandlerFunc) *Conn {
+	cn := &Conn{Timeout: 5 * time.Second, Handler: handler, rwc: rwc}
+	cn.rd = bufio.NewReader(rwc)
+	cn.pending = make(map[int64]chan *Message)
+	cn.done = make(chan struct{})
+	go cn.ReadLoop()
+	return cn
+}
+
+// Call sends a request and waits for the response, which is decoded into
+// result if non-nil
+func (cn *Conn) Call(method string, params, result interface{}) error {
+	cn.mu.Lock()
+	if cn.closed {
+		cn.mu.Unlock()
+		return ErrClosed
+	}
+	cn.seq++
+	id := cn.seq
+	rch := make(chan *Message, 1)
+	cn.pending[id] = rch
+	cn.mu.Unlock()
+
+	rid := json.RawMessage(strconv.FormatInt(id, 10))
+	ms := &Message{ID: &rid, Method: method}
+	err := cn.setParams(ms, params)
+	if err == nil {
+		err = cn.Write(ms)
+	}
+	if err != nil {
+		cn.forget(id)
+		return err
+	}
+	timer := time.NewTimer(cn.Timeout)
+	defer timer.Stop()
+	select {
+	case rm := <-rch:
+		if rm.Error != nil {
+			return rm.Error
+		}
+		if result == nil || len(rm.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(rm.Result, result)
+	case <-timer.C:
+		cn.forget(id)
+		cn.Notify("$/cancelRequest", map[string]int64{"id": id})
+		return ErrTimeout
+	case <-cn.done:
+		return ErrClosed
+	}
+}
+
+// Notify sends a notification, which has no response
+func (cn *Conn) Notify(method string, params interface{}) error {
+	ms := &Message{Method: method}
+	if err := cn.setParams(ms, params); err != nil {
+		return err
+	}
+	return cn.Write(ms)
+}
+
+func (cn *Conn) setParams(ms *Message, params interface{}) error {
+	if params == nil {
+		return nil
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	ms.Params = b
+	return nil
+}
+
+func (cn *Conn) forget(id int64) {
+	cn.mu.Lock()
+	delete(cn.pending, id)
+	cn.mu.Unlock()
+}
+
+// Write writes one message with Content-Length framing
+func (cn *Conn) Write(ms *Message) error {
+	ms.JSONRPC = "2.0"
+	b, err := json.Marshal(ms)
+	if err != nil {
+		return err
+	}
+	cn.wmu.Lock()
+	defer cn.wmu.Unlock()
+	if _, err = fmt.Fprintf(cn.rwc, "Content-Length: %d\r\n\r\n", len(b)); err != nil {
+		return err
+	}
+	_, err = cn.rwc.Write(b)
+	return err
+}
+
+// Read reads one framed message
+func (cn *Conn) Read() (*Message, error) {
+	clen := -1
+	for {
+		ln, err := cn.rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		ln = strings.TrimSpace(ln)
+		if ln == "" {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(ln), "content-length:") {
+			clen, err = strconv.Atoi(strings.TrimSpace(ln[len("content-length:"):]))
+			if err != nil {
+				return nil, fmt.Errorf("lsp: bad Content-Length header: %v", ln)
+			}
+		}
+	}
+	if clen < 0 {
+		return nil, errors.New("lsp: message missing Content-Length header")
+	}
+	b := make([]byte, clen)
+	if _, err := io.ReadFull(cn.rd, b); err != nil {
+		return nil, err
+	}
+	ms := &Message{}
+	if err := json.Unmarshal(b, ms); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// ReadLoop reads messages until the connection is closed, dispatching
+// responses to waiting calls and everything else to the Handler
+func (cn *Conn) ReadLoop() {
+	defer cn.Close()
+	for {
+		ms, err := cn.Read()
+		if err != nil {
+			if err != io.EOF && !cn.IsClosed() {
+				log.Printf("lsp: read error: %v\n", err)
+			}
+			return
+		}
+		if ms.IsResponse() {
+			id, err := strconv.ParseInt(string(*ms.ID), 10, 64)
+			if err != nil {
+				continue // not one of ours
+			}
+			cn.mu.Lock()
+			rch, ok := cn.pending[id]
+			delete(cn.pending, id)
+			cn.mu.Unlock()
+			if ok {
+				rch <- ms
+			}
+			continue
+		}
+		cn.handle(ms) // in order, so diagnostics are never applied out of sequence
+	}
+}
+
+// handle calls the Handler for given request or notification, and sends the
+// response for requests
+func (cn *Conn) handle(ms *Message) {
+	var res interface{}
+	var err error
+	if cn.Handler != nil {
+		res, err = cn.Handler(ms.Method, ms.Params, ms.ID != nil)
+	}
+	if ms.ID == nil {
+		return
+	}
+	rm := &Message{ID: ms.ID, Result: json.RawMessage("null")}
+	if err != nil {
+		rm.Error = &RespError{Code: -32603, Message: err.Error()}
+		rm.Result = nil
+	} else if res != nil {
+		if b, err := json.Marshal(res); err == nil {
+			rm.Result = b
+		}
+	}
+	cn.Write(rm)
+}
+
+// IsClosed returns true if the connection has been closed
+func (cn *Conn) IsClosed() bool {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	return cn.closed
+}
+
+// Close closes the connection, causing any pending calls to return ErrClosed
+func (cn *Conn) Close() error {
+	cn.mu.Lock()
+	if cn.closed {
+		cn.mu.Unlock()
+		return nil
+	}
+	cn.closed = true
+	close(cn.done)
+	cn.mu.Unlock()
+	return cn.rwc.Close()
+}
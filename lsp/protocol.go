@@ -0,0 +1,277 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// This file contains the subset of the Language Server Protocol types that
+// we actually use -- see https://microsoft.github.io/language-server-protocol
+// for the full specification.  Positions are in LSP terms: 0-based lines and
+// UTF-16 code unit character offsets.
+
+// Position is a zero-based line and UTF-16 character offset within a document
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a start / end pair of positions -- end is exclusive
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range within a given document
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// LocationLink is the alternative form of definition result that some
+// servers return
+type LocationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetRange          Range  `json:"targetRange"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+// TextEdit is a replacement of the text in given range with new text
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// TextDocumentIdentifier identifies a document by URI
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// VersionedTextDocumentIdentifier identifies a specific version of a document
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+// TextDocumentItem is the full document sent on open
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+// TextDocumentEdit is a set of edits for one versioned document
+type TextDocumentEdit struct {
+	TextDocument VersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                      `json:"edits"`
+}
+
+// WorkspaceEdit is a set of edits across multiple documents, e.g., from a
+// rename -- servers use either Changes or DocumentChanges
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit    `json:"documentChanges,omitempty"`
+}
+
+// AllChanges returns the edits organized by document URI, merging both
+// forms of WorkspaceEdit
+func (we *WorkspaceEdit) AllChanges() map[string][]TextEdit {
+	ch := make(map[string][]TextEdit)
+	for uri, eds := range we.Changes {
+		ch[uri] = append(ch[uri], eds...)
+	}
+	for _, de := range we.DocumentChanges {
+		ch[de.TextDocument.URI] = append(ch[de.TextDocument.URI], de.Edits...)
+	}
+	return ch
+}
+
+// TextDocumentContentChangeEvent is one change to a document -- if Range is
+// nil then Text is the entire new document
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+// TextDocumentPositionParams are the params for all position-based requests
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// DiagnosticSeverity is the severity of a Diagnostic
+type DiagnosticSeverity int
+
+const (
+	// SevNone is used when the server does not specify a severity
+	SevNone DiagnosticSeverity = iota
+
+	// SevError reports an error
+	SevError
+
+	// SevWarning reports a warning
+	SevWarning
+
+	// SevInfo reports an information message
+	SevInfo
+
+	// SevHint reports a hint
+	SevHint
+)
+
+var sevNames = [...]string{"", "error", "warning", "info", "hint"}
+
+func (ds DiagnosticSeverity) String() string {
+	if ds < 0 || int(ds) >= len(sevNames) {
+		return ""
+	}
+	return sevNames[ds]
+}
+
+// Diagnostic is an error, warning etc reported by the server for a region
+// of a document
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity,omitempty"`
+	Code     interface{}        `json:"code,omitempty"`
+	Source   string             `json:"source,omitempty"`
+	Message  string             `json:"message"`
+}
+
+// PublishDiagnosticsParams are sent by the server in the
+// textDocument/publishDiagnostics notification -- always the full set of
+// diagnostics for the document
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     int          `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// CompletionItem is one completion option
+type CompletionItem struct {
+	Label         string          `json:"label"`
+	Kind          int             `json:"kind,omitempty"`
+	Detail        string          `json:"detail,omitempty"`
+	Documentation json.RawMessage `json:"documentation,omitempty"`
+	SortText      string          `json:"sortText,omitempty"`
+	FilterText    string          `json:"filterText,omitempty"`
+	InsertText    string          `json:"insertText,omitempty"`
+	TextEdit      *TextEdit       `json:"textEdit,omitempty"`
+}
+
+// CompletionText returns the text to insert for this item
+func (ci *CompletionItem) CompletionText() string {
+	switch {
+	case ci.TextEdit != nil:
+		return ci.TextEdit.NewText
+	case ci.InsertText != "":
+		return ci.InsertText
+	}
+	return ci.Label
+}
+
+// CompletionList is the list form of completion results
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+// MarkupContent is the structured form of hover and documentation text
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of a hover request
+type Hover struct {
+	Contents json.RawMessage `json:"contents"`
+	Range    *Range          `json:"range,omitempty"`
+}
+
+// MarkupText returns the plain text from any of the several forms of
+// markup that servers use for hover and documentation: a string, a
+// MarkupContent, a MarkedString {language, value}, or a list of these
+func MarkupText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var mc MarkupContent
+	if json.Unmarshal(raw, &mc) == nil && mc.Value != "" {
+		return mc.Value
+	}
+	var lst []json.RawMessage
+	if json.Unmarshal(raw, &lst) == nil {
+		strs := make([]string, 0, len(lst))
+		for _, r := range lst {
+			if t := MarkupText(r); t != "" {
+				strs = append(strs, t)
+			}
+		}
+		return strings.Join(strs, "\n")
+	}
+	return ""
+}
+
+//////////////////////////////////////////////////////////////////////////////
+//  URIs and UTF-16 offsets
+
+// FileURI returns the file:// URI for given file path, which is made absolute
+func FileURI(fname string) string {
+	if abs, err := filepath.Abs(fname); err == nil {
+		fname = abs
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(fname)}
+	return u.String()
+}
+
+// URIFile returns the local file path for given file:// URI -- returns the
+// uri unchanged if it cannot be parsed as such
+func URIFile(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// UTF16Len returns the number of UTF-16 code units for given runes, which is
+// how LSP counts characters
+func UTF16Len(rs []rune) int {
+	n := 0
+	for _, r := range rs {
+		n += runeLen16(r)
+	}
+	return n
+}
+
+// RuneIndex returns the rune index within given line corresponding to given
+// UTF-16 offset -- clipped to the length of the line
+func RuneIndex(rs []rune, u16 int) int {
+	n := 0
+	for i, r := range rs {
+		if n >= u16 {
+			return i
+		}
+		n += runeLen16(r)
+	}
+	return len(rs)
+}
+
+// runeLen16 returns the number of UTF-16 code units needed for given rune
+func runeLen16(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
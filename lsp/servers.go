@@ -0,0 +1,49 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"os/exec"
+
+	"github.com/goki/pi/filecat"
+)
+
+// ServerConfig specifies how to run a language server over stdio
+type ServerConfig struct {
+	Cmd    string   `desc:"command to run -- must be on the PATH or an absolute path"`
+	Args   []string `desc:"arguments to the command, e.g., --stdio for servers that need it"`
+	LangID string   `desc:"LSP languageId for documents of this type"`
+}
+
+// Available returns true if the server command can be found
+func (sc *ServerConfig) Available() bool {
+	if sc.Cmd == "" {
+		return false
+	}
+	_, err := exec.LookPath(sc.Cmd)
+	return err == nil
+}
+
+// Servers are the language servers to use for each supported file type --
+// can be modified by apps to add or change servers.  Only used if the
+// command is actually installed.
+var Servers = map[filecat.Supported]ServerConfig{
+	filecat.Go:         {Cmd: "gopls", LangID: "go"},
+	filecat.C:          {Cmd: "clangd", LangID: "cpp"},
+	filecat.Python:     {Cmd: "pylsp", LangID: "python"},
+	filecat.Rust:       {Cmd: "rust-analyzer", LangID: "rust"},
+	filecat.JavaScript: {Cmd: "typescript-language-server", Args: []string{"--stdio"}, LangID: "javascript"},
+	filecat.Java:       {Cmd: "jdtls", LangID: "java"},
+}
+
+// ServerFor returns the configuration for given file type, and false if
+// none is configured or the server is not installed
+func ServerFor(sup filecat.Supported) (ServerConfig, bool) {
+	sc, ok := Servers[sup]
+	if !ok || !sc.Available() {
+		return sc, false
+	}
+	return sc, true
+}
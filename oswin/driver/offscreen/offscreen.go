@@ -0,0 +1,155 @@
+// Copyright 2018 The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package offscreen provides a pure-Go oswin App that has no screens or
+// windows, only in-memory Images -- it is used for rendering to image files
+// from command-line tools, build scripts and tests, without a display.
+package offscreen
+
+import (
+	"errors"
+	"image"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/clip"
+	"github.com/goki/gi/oswin/cursor"
+)
+
+// ErrNoWindows is returned for any attempt to create a window or texture.
+var ErrNoWindows = errors.New("offscreen: windows and textures are not available")
+
+// Main is called by the program's main function to run an offscreen
+// application -- it sets oswin.TheApp and calls f, returning when f returns.
+func Main(f func(oswin.App)) {
+	oswin.TheApp = theApp
+	f(theApp)
+}
+
+var theApp = &appImpl{name: "GoGi"}
+
+type appImpl struct {
+	mu            sync.Mutex
+	name          string
+	about         string
+	quitting      bool
+	quitReqFunc   func()
+	quitCleanFunc func()
+}
+
+func (app *appImpl) NewImage(size image.Point) (oswin.Image, error) {
+	return &imageImpl{size: size, rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+func (app *appImpl) NewTexture(win oswin.Window, size image.Point) (oswin.Texture, error) {
+	return nil, ErrNoWindows
+}
+
+func (app *appImpl) NewWindow(opts *oswin.NewWindowOptions) (oswin.Window, error) {
+	return nil, ErrNoWindows
+}
+
+func (app *appImpl) NScreens() int                         { return 0 }
+func (app *appImpl) Screen(scrN int) *oswin.Screen         { return nil }
+func (app *appImpl) NWindows() int                         { return 0 }
+func (app *appImpl) Window(win int) oswin.Window           { return nil }
+func (app *appImpl) WindowByName(name string) oswin.Window { return nil }
+func (app *appImpl) WindowInFocus() oswin.Window           { return nil }
+func (app *appImpl) ContextWindow() oswin.Window           { return nil }
+func (app *appImpl) ClipBoard(win oswin.Window) clip.Board { return nil }
+func (app *appImpl) Cursor(win oswin.Window) cursor.Cursor { return nil }
+func (app *appImpl) OpenURL(url string)                    {}
+func (app *appImpl) SetQuitReqFunc(fun func())             { app.quitReqFunc = fun }
+func (app *appImpl) SetQuitCleanFunc(fun func())           { app.quitCleanFunc = fun }
+func (app *appImpl) IsQuitting() bool                      { return app.quitting }
+func (app *appImpl) Name() string                          { return app.name }
+func (app *appImpl) SetName(name string)                   { app.name = name }
+func (app *appImpl) About() string                         { return app.about }
+func (app *appImpl) SetAbout(about string)                 { app.about = about }
+
+// Platform returns the platform of the host os, so that text and key
+// handling behave as they would in a window on the same machine.
+func (app *appImpl) Platform() oswin.Platforms {
+	switch runtime.GOOS {
+	case "darwin":
+		return oswin.MacOS
+	case "windows":
+		return oswin.Windows
+	default:
+		return oswin.LinuxX11
+	}
+}
+
+func (app *appImpl) PrefsDir() string {
+	usr, err := user.Current()
+	if err != nil {
+		log.Print(err)
+		return os.TempDir()
+	}
+	return filepath.Join(usr.HomeDir, ".config")
+}
+
+func (app *appImpl) GoGiPrefsDir() string {
+	pdir := filepath.Join(app.PrefsDir(), "GoGi")
+	os.MkdirAll(pdir, 0755)
+	return pdir
+}
+
+func (app *appImpl) AppPrefsDir() string {
+	pdir := filepath.Join(app.PrefsDir(), app.Name())
+	os.MkdirAll(pdir, 0755)
+	return pdir
+}
+
+func (app *appImpl) FontPaths() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"/System/Library/Fonts", "/Library/Fonts"}
+	case "windows":
+		return []string{"C:\\Windows\\Fonts"}
+	default:
+		return []string{"/usr/share/fonts/truetype"}
+	}
+}
+
+func (app *appImpl) QuitReq() {
+	if app.quitReqFunc != nil {
+		app.quitReqFunc()
+	} else {
+		app.Quit()
+	}
+}
+
+func (app *appImpl) QuitClean() {
+	app.mu.Lock()
+	app.quitting = true
+	app.mu.Unlock()
+	if app.quitCleanFunc != nil {
+		app.quitCleanFunc()
+	}
+}
+
+func (app *appImpl) Quit() {
+	app.QuitClean()
+}
+
+// imageImpl is an Image backed by ordinary Go memory.
+type imageImpl struct {
+	size image.Point
+	rgba *image.RGBA
+}
+
+func (b *imageImpl) Release()                {}
+func (b *imageImpl) Size() image.Point       { return b.size }
+func (b *imageImpl) Bounds() image.Rectangle { return image.Rectangle{Max: b.size} }
+func (b *imageImpl) RGBA() *image.RGBA       { return b.rgba }
+
+// check for interface implementation
+var _ oswin.App = &appImpl{}
+var _ oswin.Image = &imageImpl{}
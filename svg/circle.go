@@ -24,6 +24,7 @@ func (g *Circle) Render2D() {
 	}
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.Lock()
 	rs.PushXForm(pc.XForm)
 	pc.DrawCircle(rs, g.Pos.X, g.Pos.Y, g.Radius)
@@ -32,6 +33,8 @@ func (g *Circle) Render2D() {
 
 	g.ComputeBBoxSVG()
 	g.Render2DChildren()
-
+	if clip {
+		g.PopClipMask()
+	}
 	rs.PopXFormLock()
 }
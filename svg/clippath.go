@@ -5,14 +5,307 @@
 package svg
 
 import (
+	"image"
+	"log"
+
+	"github.com/goki/gi/gi"
 	"github.com/goki/ki/kit"
 )
 
-// todo: needs to be impl
-
-// ClipPath is used for holding a path that renders as a clip path
+// ClipPath is used for holding a path that renders as a clip path -- its
+// children are rendered as the geometry of the clip, and the element
+// referring to it with a clip-path="url(#name)" property is only drawn
+// where they are -- see NodeBase.PushClipMask
 type ClipPath struct {
 	NodeBase
+	Units ClipUnits `xml:"clipPathUnits" desc:"coordinate system for the contents of the clip path"`
+	inUse bool      `desc:"currently rendering -- prevents infinite recursion for clip paths that refer to themselves"`
 }
 
 var KiT_ClipPath = kit.Types.AddType(&ClipPath{}, nil)
+
+// ClipUnits specifies the coordinate system for the contents of clipPath and
+// mask elements
+type ClipUnits int32
+
+const (
+	// ClipUserSpace is the user coordinate system in place for the element
+	// referring to the clip path or mask (userSpaceOnUse)
+	ClipUserSpace ClipUnits = iota
+
+	// ClipObjectBBox is the bounding box of the element referring to the
+	// clip path or mask, with 0..1 spanning the box (objectBoundingBox)
+	ClipObjectBBox
+
+	ClipUnitsN
+)
+
+//go:generate stringer -type=ClipUnits
+
+var KiT_ClipUnits = kit.Enums.AddEnumAltLower(ClipUnitsN, false, nil, "Clip")
+
+func (ev ClipUnits) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *ClipUnits) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// ParseClipUnits parses the svg clipPathUnits, maskUnits and
+// maskContentUnits values, returning dflt if empty
+func ParseClipUnits(s string, dflt ClipUnits) ClipUnits {
+	switch s {
+	case "userSpaceOnUse":
+		return ClipUserSpace
+	case "objectBoundingBox":
+		return ClipObjectBBox
+	}
+	return dflt
+}
+
+// SVGString returns the svg name of the units
+func (ev ClipUnits) SVGString() string {
+	if ev == ClipObjectBBox {
+		return "objectBoundingBox"
+	}
+	return "userSpaceOnUse"
+}
+
+// ClipUnitsXForm returns the transform for rendering contents in given
+// units, for an element with given render bounding box, rendered with given
+// current transform -- for ClipObjectBBox, the box is mapped back into the
+// user space of the element, which is only exact if the transform has no
+// rotation or skew -- NodeUnitsXForm is exact for the basic shapes
+func ClipUnitsXForm(units ClipUnits, xf gi.Matrix2D, bbox image.Rectangle) gi.Matrix2D {
+	if units != ClipObjectBBox {
+		return xf
+	}
+	min, max := renderBBoxUser(bbox, xf)
+	return ObjBBoxXForm(min, max, xf)
+}
+
+// NodeUnitsXForm returns the transform for rendering contents in given units,
+// for given element rendered with given current transform -- for
+// ClipObjectBBox, 0..1 spans the bounding box of the element in its own user
+// space (see UserBBox), so the contents follow any rotation or skew of it
+func NodeUnitsXForm(units ClipUnits, xf gi.Matrix2D, nd gi.Node2D) gi.Matrix2D {
+	if units != ClipObjectBBox {
+		return xf
+	}
+	min, max := UserBBox(nd, xf)
+	return ObjBBoxXForm(min, max, xf)
+}
+
+// ObjBBoxXForm returns the transform for objectBoundingBox units, with 0..1
+// spanning the given box in user space, which is rendered with transform xf
+func ObjBBoxXForm(min, max gi.Vec2D, xf gi.Matrix2D) gi.Matrix2D {
+	return gi.Scale2D(max.X-min.X, max.Y-min.Y).Multiply(gi.Translate2D(min.X, min.Y)).Multiply(xf)
+}
+
+// UserBBox returns the bounding box of the geometry of given element in its
+// own user space, which is rendered with transform xf -- groups are the union
+// of their children, and elements other than the basic shapes use their
+// render bounding box mapped back through xf
+func UserBBox(nd gi.Node2D, xf gi.Matrix2D) (min, max gi.Vec2D) {
+	var pts []gi.Vec2D
+	switch g := nd.(type) {
+	case *Rect:
+		pts = []gi.Vec2D{g.Pos, g.Pos.Add(g.Size)}
+	case *Circle:
+		r := gi.Vec2D{g.Radius, g.Radius}
+		pts = []gi.Vec2D{g.Pos.Sub(r), g.Pos.Add(r)}
+	case *Ellipse:
+		pts = []gi.Vec2D{g.Pos.Sub(g.Radii), g.Pos.Add(g.Radii)}
+	case *Line:
+		pts = []gi.Vec2D{g.Start, g.End}
+	case *Polyline:
+		pts = g.Points
+	case *Polygon:
+		pts = g.Points
+	case *Path:
+		for _, pl := range PathDataFlatten(g.Data, 0) {
+			pts = append(pts, pl.Pts...)
+		}
+	case *Group:
+		for _, kid := range g.Kids {
+			knd, ok := kid.(gi.Node2D)
+			if !ok {
+				continue
+			}
+			ksg, ok := kid.(NodeSVG)
+			if !ok {
+				continue
+			}
+			kxf := ksg.AsSVGNode().Pnt.XForm
+			kmin, kmax := UserBBox(knd, kxf.Multiply(xf))
+			pts = append(pts, kxf.TransformPointVec2D(kmin), kxf.TransformPointVec2D(kmax),
+				kxf.TransformPointVec2D(gi.Vec2D{kmin.X, kmax.Y}), kxf.TransformPointVec2D(gi.Vec2D{kmax.X, kmin.Y}))
+		}
+	}
+	if len(pts) == 0 {
+		return renderBBoxUser(nd.AsNode2D().BBox, xf)
+	}
+	min, max = pts[0], pts[0]
+	for _, pt := range pts[1:] {
+		min.SetMin(pt)
+		max.SetMax(pt)
+	}
+	return
+}
+
+// renderBBoxUser returns the box spanning the corners of given render
+// bounding box mapped back into the user space of transform xf
+func renderBBoxUser(bbox image.Rectangle, xf gi.Matrix2D) (min, max gi.Vec2D) {
+	inv := xf.Inverse()
+	min = inv.TransformPointVec2D(gi.NewVec2DFmPoint(bbox.Min))
+	max = min
+	for _, pt := range []image.Point{{bbox.Max.X, bbox.Min.Y}, bbox.Max, {bbox.Min.X, bbox.Max.Y}} {
+		up := inv.TransformPointVec2D(gi.NewVec2DFmPoint(pt))
+		min.SetMin(up)
+		max.SetMax(up)
+	}
+	return
+}
+
+// Render2D does nothing -- clip paths are only rendered for the elements
+// that refer to them, by ClipMask
+func (cp *ClipPath) Render2D() {
+}
+
+// ClipMask renders the clip path for given element, rendered with the
+// current transform, into a new mask the size of the render image, returning
+// nil if it is already being rendered (i.e., it refers to itself).  If the
+// clip path itself has a clip-path property, that clip is intersected with
+// this one.
+func (cp *ClipPath) ClipMask(rs *gi.RenderState, nd gi.Node2D) *image.Alpha {
+	if cp.inUse {
+		log.Printf("svg.ClipPath: %v refers to itself\n", cp.PathUnique())
+		return nil
+	}
+	if cp.Viewport == nil {
+		cp.This().(gi.Node2D).Init2D()
+	}
+	cp.inUse = true
+	defer func() { cp.inUse = false }()
+	rs.PushImage()
+	renderContents(rs, cp.AsSVGNode(), NodeUnitsXForm(cp.Units, rs.XForm, nd))
+	mask := cp.Pnt.AsMask(rs)
+	rs.PopImage()
+	if ccp := cp.ClipPath(); ccp != nil {
+		mask = IntersectMasks(mask, ccp.ClipMask(rs, nd))
+	}
+	return mask
+}
+
+// renderContents renders the children of given clip path or mask node into
+// the current image, with given transform in place of the current one
+func renderContents(rs *gi.RenderState, g *NodeBase, xf gi.Matrix2D) {
+	rs.RenderMu.Lock()
+	sxf := rs.XForm
+	rs.XForm = g.Pnt.XForm.Multiply(xf)
+	rs.RenderMu.Unlock()
+	g.Render2DChildren()
+	rs.RenderMu.Lock()
+	rs.XForm = sxf
+	rs.RenderMu.Unlock()
+}
+
+// IntersectMasks returns the intersection of the two masks, multiplying
+// their values -- either can be nil, meaning no mask
+func IntersectMasks(a, b *image.Alpha) *image.Alpha {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	for i := range a.Pix {
+		a.Pix[i] = uint8(uint32(a.Pix[i]) * uint32(b.Pix[i]) / 255)
+	}
+	return a
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//  Applying clip paths and masks
+
+// URLProp returns the element referred to by the given property, which can
+// be a url(#name) string, or a pointer to the element itself -- returns nil
+// if not set or none
+func (g *NodeBase) URLProp(prop string) gi.Node2D {
+	pv, ok := g.Props[prop]
+	if !ok {
+		return nil
+	}
+	if nd, ok := pv.(gi.Node2D); ok {
+		return nd
+	}
+	url, ok := pv.(string)
+	if !ok {
+		log.Printf("gi.svg %v property should be a string url or pointer to element, instead is: %T\n", prop, pv)
+		return nil
+	}
+	return g.FindSVGURL(url)
+}
+
+// ClipPath returns the clip path referred to by the clip-path property, or
+// nil if none
+func (g *NodeBase) ClipPath() *ClipPath {
+	nd := g.URLProp("clip-path")
+	if nd == nil {
+		return nil
+	}
+	cp, ok := nd.(*ClipPath)
+	if !ok {
+		log.Printf("gi.svg Found element named: %v but isn't a ClipPath type, instead is: %T", nd.Name(), nd)
+		return nil
+	}
+	return cp
+}
+
+// Mask returns the mask referred to by the mask property, or nil if none
+func (g *NodeBase) Mask() *Mask {
+	nd := g.URLProp("mask")
+	if nd == nil {
+		return nil
+	}
+	mk, ok := nd.(*Mask)
+	if !ok {
+		log.Printf("gi.svg Found element named: %v but isn't a Mask type, instead is: %T", nd.Name(), nd)
+		return nil
+	}
+	return mk
+}
+
+// PushClipMask starts rendering the node into a separate layer if it has a
+// filter, clip-path or mask property -- returns true if so, in which case
+// PopClipMask must be called after rendering the node, prior to popping its
+// transform.  Must not be called with the render mutex locked.  A layer is
+// used rather than setting the mask with Paint.SetMask, as that only applies
+// to images drawn with DrawImage, not to fills, strokes or text, and filters
+// need the rendered node as an image anyway.
+func (g *NodeBase) PushClipMask() bool {
+	if g.Filter() == nil && g.ClipPath() == nil && g.Mask() == nil {
+		return false
+	}
+	g.Viewport.Render.PushImage()
+	return true
+}
+
+// PopClipMask applies the filter of the node to the layer it was rendered
+// into after PushClipMask returned true, and draws the result into the prior
+// image through its clip path and mask, in that order as in the svg spec.
+// Must not be called with the render mutex locked.
+func (g *NodeBase) PopClipMask() {
+	rs := &g.Viewport.Render
+	layer := rs.PopImage()
+	if layer == nil {
+		return
+	}
+	if flt := g.Filter(); flt != nil {
+		layer = flt.FilterImage(layer, g.BBox, rs.XForm)
+	}
+	var mask *image.Alpha
+	if cp := g.ClipPath(); cp != nil {
+		mask = cp.ClipMask(rs, g.This().(gi.Node2D))
+	}
+	if mk := g.Mask(); mk != nil {
+		mask = IntersectMasks(mask, mk.MaskMask(rs, g.This().(gi.Node2D)))
+	}
+	rs.DrawLayer(layer, mask)
+}
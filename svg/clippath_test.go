@@ -0,0 +1,138 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+// renderSVGPixels renders given svg text of size 100x100, returning the image
+// -- elements are not stroked unless they say so
+func renderSVGPixels(t *testing.T, txt string) *image.RGBA {
+	sv := readSVG(t, []byte(`<svg width="100" height="100" viewBox="0 0 100 100"><g stroke="none">`+txt+`</g></svg>`))
+	renderPixels(&sv.Viewport2D, image.Point{100, 100})
+	return sv.Pixels
+}
+
+// pixelAlpha is a point of a rendered image with its expected alpha
+type pixelAlpha struct {
+	x, y   int
+	lo, hi uint8
+}
+
+// checkAlphas checks the alpha of the given pixels of the image
+func checkAlphas(t *testing.T, name string, img *image.RGBA, pas []pixelAlpha) {
+	t.Helper()
+	for _, pa := range pas {
+		a := img.RGBAAt(pa.x, pa.y).A
+		if a < pa.lo || a > pa.hi {
+			t.Errorf("%v: alpha at %v,%v = %v, want %v..%v", name, pa.x, pa.y, a, pa.lo, pa.hi)
+		}
+	}
+}
+
+// in and out are the alphas of pixels inside and outside of a clip or mask
+func in(x, y int) pixelAlpha  { return pixelAlpha{x, y, 255, 255} }
+func out(x, y int) pixelAlpha { return pixelAlpha{x, y, 0, 0} }
+
+func TestClipPathRender(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+		pas  []pixelAlpha
+	}{
+		{"none", `<rect x="10" y="10" width="80" height="80" fill="red"/>`,
+			[]pixelAlpha{in(15, 15), in(85, 85), out(5, 5)}},
+		{"user space", `<clipPath id="c"><rect x="20" y="20" width="40" height="40"/></clipPath>
+			<rect x="10" y="10" width="80" height="80" fill="red" clip-path="url(#c)"/>`,
+			[]pixelAlpha{in(25, 25), in(55, 55), out(15, 15), out(65, 65)}},
+		{"circle", `<clipPath id="c"><circle cx="50" cy="50" r="20"/></clipPath>
+			<rect x="10" y="10" width="80" height="80" fill="red" clip-path="url(#c)"/>`,
+			[]pixelAlpha{in(50, 50), in(50, 32), out(33, 33), out(50, 75)}},
+		{"object bbox", `<clipPath id="c" clipPathUnits="objectBoundingBox"><rect x="0" y="0" width="0.5" height="1"/></clipPath>
+			<rect x="20" y="20" width="60" height="60" fill="red" clip-path="url(#c)"/>`,
+			[]pixelAlpha{in(25, 25), in(45, 75), out(55, 25), out(75, 75)}},
+		{"object bbox rotated", `<clipPath id="c" clipPathUnits="objectBoundingBox"><rect x="0" y="0" width="0.5" height="1"/></clipPath>
+			<rect x="20" y="40" width="60" height="20" fill="red" clip-path="url(#c)" transform="rotate(90 50 50)"/>`,
+			[]pixelAlpha{in(45, 25), in(55, 45), out(45, 55), out(55, 75)}},
+		{"object bbox group", `<clipPath id="c" clipPathUnits="objectBoundingBox"><rect x="0" y="0" width="1" height="0.5"/></clipPath>
+			<g clip-path="url(#c)"><rect x="10" y="10" width="20" height="20" fill="red"/><rect x="70" y="70" width="20" height="20" fill="red"/></g>`,
+			[]pixelAlpha{in(15, 15), in(25, 25), out(75, 75), out(85, 85)}},
+		{"nested", `<clipPath id="c2"><rect x="0" y="0" width="40" height="100"/></clipPath>
+			<clipPath id="c" clip-path="url(#c2)"><rect x="20" y="20" width="60" height="60"/></clipPath>
+			<rect x="10" y="10" width="80" height="80" fill="red" clip-path="url(#c)"/>`,
+			[]pixelAlpha{in(25, 25), in(35, 75), out(45, 45), out(15, 15)}},
+		{"self", `<clipPath id="c" clip-path="url(#c)"><rect x="20" y="20" width="40" height="40"/></clipPath>
+			<rect x="10" y="10" width="80" height="80" fill="red" clip-path="url(#c)"/>`,
+			[]pixelAlpha{in(25, 25), out(15, 15)}},
+	}
+	for _, tt := range tests {
+		checkAlphas(t, tt.name, renderSVGPixels(t, tt.svg), tt.pas)
+	}
+}
+
+func TestMaskRender(t *testing.T) {
+	half := func(x, y int) pixelAlpha { return pixelAlpha{x, y, 120, 136} }
+	tests := []struct {
+		name string
+		svg  string
+		pas  []pixelAlpha
+	}{
+		{"user space", `<mask id="m" maskUnits="userSpaceOnUse" x="0" y="0" width="100" height="100">
+			<rect x="0" y="0" width="50" height="100" fill="white"/><rect x="50" y="0" width="50" height="100" fill="#808080"/></mask>
+			<rect x="10" y="10" width="80" height="80" fill="red" mask="url(#m)"/>`,
+			[]pixelAlpha{in(25, 25), half(75, 75), out(5, 5)}},
+		{"black", `<mask id="m"><rect x="0" y="0" width="100" height="100" fill="black"/></mask>
+			<rect x="10" y="10" width="80" height="80" fill="red" mask="url(#m)"/>`,
+			[]pixelAlpha{out(25, 25), out(75, 75)}},
+		{"default region", `<mask id="m"><rect x="-100" y="-100" width="300" height="300" fill="white"/></mask>
+			<rect x="20" y="20" width="50" height="50" fill="red" stroke="red" stroke-width="20" mask="url(#m)"/>`,
+			[]pixelAlpha{in(17, 17), in(72, 72), out(12, 12), out(78, 78)}}, // 10% around the geometry, not the stroke
+		{"object bbox region", `<mask id="m" x="0" y="0" width="0.5" height="1"><rect x="0" y="0" width="100" height="100" fill="white"/></mask>
+			<rect x="20" y="20" width="60" height="60" fill="red" mask="url(#m)"/>`,
+			[]pixelAlpha{in(25, 25), in(45, 75), out(55, 25), out(75, 75)}},
+		{"object bbox region rotated", `<mask id="m" x="0" y="0" width="0.5" height="1"><rect x="0" y="0" width="100" height="100" fill="white"/></mask>
+			<rect x="20" y="40" width="60" height="20" fill="red" mask="url(#m)" transform="rotate(90 50 50)"/>`,
+			[]pixelAlpha{in(45, 25), in(55, 45), out(45, 55), out(55, 75)}},
+		{"object bbox contents", `<mask id="m" maskContentUnits="objectBoundingBox"><rect x="0.5" y="0" width="0.5" height="1" fill="white"/></mask>
+			<rect x="20" y="20" width="60" height="60" fill="red" mask="url(#m)"/>`,
+			[]pixelAlpha{out(25, 25), out(45, 75), in(55, 25), in(75, 75)}},
+		{"with clip", `<clipPath id="c"><rect x="0" y="0" width="100" height="50"/></clipPath>
+			<mask id="m" clip-path="url(#c)"><rect x="0" y="0" width="100" height="100" fill="white"/></mask>
+			<rect x="10" y="10" width="80" height="80" fill="red" mask="url(#m)"/>`,
+			[]pixelAlpha{in(25, 25), out(25, 75)}},
+	}
+	for _, tt := range tests {
+		checkAlphas(t, tt.name, renderSVGPixels(t, tt.svg), tt.pas)
+	}
+}
+
+func TestUserBBox(t *testing.T) {
+	sv := readSVG(t, []byte(`<svg width="100" height="100" viewBox="0 0 100 100">
+		<rect id="r" x="20" y="40" width="60" height="20" transform="rotate(30 50 50)"/>
+		<circle id="c" cx="50" cy="50" r="10"/>
+		<path id="p" d="M10 20 L30 20 L30 60 Z"/>
+		<g id="g" transform="scale(2)"><rect x="5" y="5" width="10" height="10"/><rect x="10" y="10" width="10" height="10" transform="translate(10 0)"/></g>
+		</svg>`))
+	renderPixels(&sv.Viewport2D, image.Point{100, 100})
+	tests := []struct {
+		name     string
+		min, max string
+	}{
+		{"r", "(20, 40)", "(80, 60)"},
+		{"c", "(40, 40)", "(60, 60)"},
+		{"p", "(10, 20)", "(30, 60)"},
+		{"g", "(5, 5)", "(30, 20)"},
+	}
+	for _, tt := range tests {
+		nd := sv.FindNamedElement(tt.name)
+		min, max := UserBBox(nd, nd.(NodeSVG).AsSVGNode().Pnt.XForm)
+		if fmt.Sprint(min) != tt.min || fmt.Sprint(max) != tt.max {
+			t.Errorf("UserBBox(%v) = %v, %v, want %v, %v", tt.name, min, max, tt.min, tt.max)
+		}
+	}
+}
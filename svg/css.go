@@ -0,0 +1,203 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// ElementName returns the svg element name of given node, which is matched
+// by type selectors in css style sheets
+func ElementName(node gi.Node2D) string {
+	switch nd := node.(type) {
+	case *Group:
+		return "g"
+	case *TextPath:
+		return "textPath"
+	case *Text:
+		if nd.IsSpan() {
+			return "tspan"
+		}
+		return "text"
+	case *Filter:
+		return nd.FilterType
+	case *Flow:
+		return nd.FlowType
+	}
+	return strings.ToLower(node.Type().Name())
+}
+
+// cssSelector is one compound selector within a css selector, e.g.,
+// rect.big#name, along with the combinator relating it to the one to its left
+type cssSelector struct {
+	elem    string
+	id      string
+	classes []string
+	child   bool // must be a direct child of the one to the left, not just a descendant
+}
+
+// cssSpecificity is the specificity of a selector: ids, classes, elements
+type cssSpecificity [3]int
+
+// less returns true if sp has lower precedence than o
+func (sp cssSpecificity) less(o cssSpecificity) bool {
+	for i := range sp {
+		if sp[i] != o[i] {
+			return sp[i] < o[i]
+		}
+	}
+	return false
+}
+
+// parseCSSSelector parses the css selector into its compound selectors, from
+// left to right -- returns false for selectors that are not supported:
+// anything beyond element, .class, #id and * selectors, combined by
+// descendant and > child combinators
+func parseCSSSelector(sel string) ([]cssSelector, cssSpecificity, bool) {
+	var sels []cssSelector
+	var sp cssSpecificity
+	child := false
+	for _, cs := range strings.Fields(strings.Replace(sel, ">", " > ", -1)) {
+		if cs == ">" {
+			if len(sels) == 0 || child {
+				return nil, sp, false
+			}
+			child = true
+			continue
+		}
+		if strings.ContainsAny(cs, ":[+~") {
+			return nil, sp, false
+		}
+		s := cssSelector{child: child}
+		child = false
+		for i, part := range splitCompoundSelector(cs) {
+			switch {
+			case part == "", part == ".", part == "#":
+				return nil, sp, false
+			case part[0] == '#':
+				s.id = part[1:]
+				sp[0]++
+			case part[0] == '.':
+				s.classes = append(s.classes, part[1:])
+				sp[1]++
+			case i == 0:
+				if part != "*" {
+					s.elem = part
+					sp[2]++
+				}
+			default:
+				return nil, sp, false
+			}
+		}
+		sels = append(sels, s)
+	}
+	if len(sels) == 0 || child {
+		return nil, sp, false
+	}
+	return sels, sp, true
+}
+
+// splitCompoundSelector splits a compound selector such as rect.big#name
+// into its parts, each starting with its . or # prefix
+func splitCompoundSelector(cs string) []string {
+	var parts []string
+	st := 0
+	for i := 1; i < len(cs); i++ {
+		if cs[i] == '.' || cs[i] == '#' {
+			parts = append(parts, cs[st:i])
+			st = i
+		}
+	}
+	return append(parts, cs[st:])
+}
+
+// matches returns true if the compound selector matches given node
+func (s *cssSelector) matches(node gi.Node2D) bool {
+	if s.elem != "" && !strings.EqualFold(s.elem, ElementName(node)) {
+		return false
+	}
+	if s.id != "" && s.id != node.Name() {
+		return false
+	}
+	if len(s.classes) > 0 {
+		ncls := strings.Fields(node.AsNode2D().Class)
+		for _, cl := range s.classes {
+			has := false
+			for _, ncl := range ncls {
+				if ncl == cl {
+					has = true
+					break
+				}
+			}
+			if !has {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchCSSSelector returns true if the selector, as parsed by
+// parseCSSSelector, matches given node within its tree
+func matchCSSSelector(sels []cssSelector, node gi.Node2D) bool {
+	last := len(sels) - 1
+	if !sels[last].matches(node) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+	child := sels[last].child
+	for par := node.Parent(); par != nil; par = par.Parent() {
+		pgi, _ := gi.KiToNode2D(par)
+		if pgi == nil {
+			return false
+		}
+		if matchCSSSelector(sels[:last], pgi) {
+			return true
+		}
+		if child {
+			return false
+		}
+	}
+	return false
+}
+
+// CSSSelectors returns the selectors (keys) in the css properties that match
+// given node, in increasing order of specificity, so the properties of later
+// ones take precedence when applied in order -- selectors of equal
+// specificity are ordered by name
+func CSSSelectors(node gi.Node2D, css ki.Props) []string {
+	type match struct {
+		sel string
+		sp  cssSpecificity
+	}
+	var ms []match
+	for sel, pv := range css {
+		if _, ok := pv.(ki.Props); !ok {
+			continue
+		}
+		sels, sp, ok := parseCSSSelector(sel)
+		if !ok || !matchCSSSelector(sels, node) {
+			continue
+		}
+		ms = append(ms, match{sel, sp})
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].sp != ms[j].sp {
+			return ms[i].sp.less(ms[j].sp)
+		}
+		return ms[i].sel < ms[j].sel
+	})
+	keys := make([]string, len(ms))
+	for i := range ms {
+		keys[i] = ms[i].sel
+	}
+	return keys
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+func TestParseCSSSelector(t *testing.T) {
+	tests := []struct {
+		sel  string
+		want []cssSelector
+		sp   cssSpecificity
+		ok   bool
+	}{
+		{"rect", []cssSelector{{elem: "rect"}}, cssSpecificity{0, 0, 1}, true},
+		{"*", []cssSelector{{}}, cssSpecificity{0, 0, 0}, true},
+		{".big", []cssSelector{{classes: []string{"big"}}}, cssSpecificity{0, 1, 0}, true},
+		{"#name", []cssSelector{{id: "name"}}, cssSpecificity{1, 0, 0}, true},
+		{"rect.big.red#name", []cssSelector{{elem: "rect", id: "name", classes: []string{"big", "red"}}}, cssSpecificity{1, 2, 1}, true},
+		{"*.big", []cssSelector{{classes: []string{"big"}}}, cssSpecificity{0, 1, 0}, true},
+		{"g rect", []cssSelector{{elem: "g"}, {elem: "rect"}}, cssSpecificity{0, 0, 2}, true},
+		{"g > .big", []cssSelector{{elem: "g"}, {classes: []string{"big"}, child: true}}, cssSpecificity{0, 1, 1}, true},
+		{"svg g>rect", []cssSelector{{elem: "svg"}, {elem: "g"}, {elem: "rect", child: true}}, cssSpecificity{0, 0, 3}, true},
+		{"#a  .b\trect", []cssSelector{{id: "a"}, {classes: []string{"b"}}, {elem: "rect"}}, cssSpecificity{1, 1, 1}, true},
+		{"", nil, cssSpecificity{}, false},
+		{"> rect", nil, cssSpecificity{}, false},
+		{"g >", nil, cssSpecificity{}, false},
+		{"g > > rect", nil, cssSpecificity{}, false},
+		{"rect:hover", nil, cssSpecificity{}, false},
+		{"rect[x]", nil, cssSpecificity{}, false},
+		{"g + rect", nil, cssSpecificity{}, false},
+		{"g ~ rect", nil, cssSpecificity{}, false},
+		{"rect.", nil, cssSpecificity{}, false},
+		{"rect..big", nil, cssSpecificity{}, false},
+		{"#", nil, cssSpecificity{}, false},
+	}
+	for _, tt := range tests {
+		sels, sp, ok := parseCSSSelector(tt.sel)
+		if ok != tt.ok {
+			t.Errorf("%q: ok %v, want %v", tt.sel, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !reflect.DeepEqual(sels, tt.want) || sp != tt.sp {
+			t.Errorf("%q: %+v specificity %v, want %+v specificity %v", tt.sel, sels, sp, tt.want, tt.sp)
+		}
+	}
+}
+
+func TestCSSSpecificityLess(t *testing.T) {
+	tests := []struct {
+		a, b cssSpecificity
+		less bool
+	}{
+		{cssSpecificity{0, 0, 1}, cssSpecificity{0, 1, 0}, true},
+		{cssSpecificity{0, 9, 9}, cssSpecificity{1, 0, 0}, true},
+		{cssSpecificity{0, 1, 2}, cssSpecificity{0, 1, 1}, false},
+		{cssSpecificity{1, 1, 1}, cssSpecificity{1, 1, 1}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.less(tt.b); got != tt.less {
+			t.Errorf("%v.less(%v) = %v, want %v", tt.a, tt.b, got, tt.less)
+		}
+	}
+}
+
+func TestCSSSelectors(t *testing.T) {
+	sv := readSVG(t, []byte(`<svg width="100" height="100">
+		<g id="outer" class="top">
+			<g id="inner">
+				<rect id="r" class="big red" x="0" y="0" width="10" height="10"/>
+				<text id="t" x="0" y="10">a<tspan id="s">b</tspan></text>
+			</g>
+			<circle id="c" class="big" cx="5" cy="5" r="5"/>
+		</g>
+		</svg>`))
+	css := ki.Props{}
+	for _, sel := range []string{"rect", "*", ".big", "#r", "rect.big.red", ".red.big", "g rect", "g > rect",
+		".top rect", ".top > rect", "#outer > g > rect", "g g rect", "circle", "g > circle", "g g circle",
+		"text", "tspan", "text > tspan", "rect:hover", ".nonesuch"} {
+		css[sel] = ki.Props{"fill": "red"}
+	}
+	css["notprops"] = "fill: red"
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{"r", []string{"*", "rect", "g > rect", "g rect", "g g rect", ".big", ".top rect", ".red.big", "rect.big.red", "#r", "#outer > g > rect"}},
+		{"c", []string{"*", "circle", "g > circle", ".big"}},
+		{"t", []string{"*", "text"}},
+		{"s", []string{"*", "tspan", "text > tspan"}},
+		{"outer", []string{"*"}},
+	}
+	for _, tt := range tests {
+		nd := sv.FindNamedElement(tt.id)
+		if got := CSSSelectors(nd, css); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestStyleCSSPrecedence(t *testing.T) {
+	sv := readSVG(t, []byte(`<svg width="100" height="100" viewBox="0 0 100 100">
+		<style>
+			rect { fill: #00f; stroke-width: 3 }
+			.warn { fill: #0f0 }
+			#r3 { fill: #ff0 }
+			g rect.warn { stroke-width: 5 }
+			g > rect { fill-opacity: 0.5 }
+		</style>
+		<rect id="r1" x="0" y="0" width="10" height="10" fill="#f00" stroke-width="1"/>
+		<rect id="r2" class="warn" x="0" y="0" width="10" height="10" fill="#f00"/>
+		<rect id="r3" class="warn" x="0" y="0" width="10" height="10" fill="#f00" style="stroke-width: 7"/>
+		<rect id="r4" class="warn" x="0" y="0" width="10" height="10" style="fill: #f0f"/>
+		<g><rect id="r5" class="warn" x="0" y="0" width="10" height="10" fill="#f00" stroke="#000"/></g>
+		</svg>`))
+	renderPixels(&sv.Viewport2D, image.Point{100, 100})
+	tests := []struct {
+		id      string
+		fill    gi.Color
+		stroke  float32
+		opacity float32
+	}{
+		{"r1", gi.Color{0, 0, 255, 255}, 3, 1},   // css over presentation attributes
+		{"r2", gi.Color{0, 255, 0, 255}, 3, 1},   // class over element
+		{"r3", gi.Color{255, 255, 0, 255}, 7, 1}, // id over class, inline over css
+		{"r4", gi.Color{255, 0, 255, 255}, 3, 1}, // inline over class
+		{"r5", gi.Color{0, 255, 0, 255}, 5, 0.5}, // descendant and child selectors
+	}
+	for _, tt := range tests {
+		g := sv.FindNamedElement(tt.id).(NodeSVG).AsSVGNode()
+		pc := &g.Pnt
+		if pc.FillStyle.Color.Color != tt.fill {
+			t.Errorf("%v: fill %v, want %v", tt.id, pc.FillStyle.Color.Color, tt.fill)
+		}
+		if pc.StrokeStyle.Width.Dots != tt.stroke {
+			t.Errorf("%v: stroke-width %v, want %v", tt.id, pc.StrokeStyle.Width.Dots, tt.stroke)
+		}
+		if pc.FillStyle.Opacity != tt.opacity {
+			t.Errorf("%v: fill-opacity %v, want %v", tt.id, pc.FillStyle.Opacity, tt.opacity)
+		}
+	}
+}
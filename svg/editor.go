@@ -6,33 +6,98 @@ package svg
 
 import (
 	"fmt"
+	"image"
+	"image/color"
 
+	"github.com/chewxy/math32"
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/giv"
 	"github.com/goki/gi/oswin"
 	"github.com/goki/gi/oswin/cursor"
+	"github.com/goki/gi/oswin/key"
 	"github.com/goki/gi/oswin/mouse"
 	"github.com/goki/ki/ki"
 	"github.com/goki/ki/kit"
 )
 
-// Editor supports editing of SVG elements
+// Editor supports editing of SVG elements: elements are selected by clicking
+// on them (double-click selects within groups) or by dragging a rubber band
+// around them, and the selection can then be moved, scaled and rotated using
+// the handles on its bounding box, which writes back to the element
+// transforms.  A single selected Path shows handles for each of its points,
+// which edit its data.  Dragging with the middle button or with Alt pans the
+// view, and the scroll wheel zooms.  All edits can be undone and redone.
+// SelectSig is emitted when the selection changes, e.g., to sync it with a
+// tree view of the svg.
 type Editor struct {
 	SVG
-	Trans         gi.Vec2D `desc:"view translation offset (from dragging)"`
-	Scale         float32  `desc:"view scaling (from zooming)"`
-	SetDragCursor bool     `desc:"has dragging cursor been set yet?"`
+	Trans         gi.Vec2D     `desc:"view translation offset (from dragging)"`
+	Scale         float32      `desc:"view scaling (from zooming)"`
+	SetDragCursor bool         `desc:"has dragging cursor been set yet?"`
+	Selected      []gi.Node2D  `json:"-" xml:"-" view:"-" desc:"currently selected elements"`
+	SnapGrid      bool         `desc:"snap moved and scaled elements and path points to the grid, and rotations to 15 degree increments"`
+	ShowGrid      bool         `desc:"draw the grid"`
+	GridSize      float32      `desc:"size of the grid, in the user coordinates of the svg -- 0 = 10"`
+	SelectSig     ki.Signal    `json:"-" xml:"-" view:"-" desc:"signal for when the selection changes -- has no signal types, data is the selected elements"`
+	Undos         []EditorUndo `json:"-" xml:"-" view:"-" desc:"record of edits, for undo and redo"`
+	UndoPos       int          `json:"-" xml:"-" view:"-" desc:"position in Undos after the last edit that is in effect -- edits before it can be undone, and from it on redone"`
+	drag          editorDrag   `desc:"state of the current mouse drag"`
 }
 
 var KiT_Editor = kit.Types.AddType(&Editor{}, nil)
 
+// editorDragModes are the things that a mouse drag can do in the Editor
+type editorDragModes int32
+
+const (
+	editorDragNone editorDragModes = iota
+	editorDragPan
+	editorDragRubber
+	editorDragMove
+	editorDragScale
+	editorDragRotate
+	editorDragPoint
+)
+
+// editorDrag records the state of the current mouse drag in the Editor --
+// edits are always computed from the state at the start of the drag
+type editorDrag struct {
+	mode   editorDragModes
+	start  gi.Vec2D      // start of the drag, in render coords
+	cur    gi.Vec2D      // current point of the drag, in render coords
+	handle int           // index of the handle or path point being dragged
+	bbMin  gi.Vec2D      // selection bounding box at start
+	bbMax  gi.Vec2D      // selection bounding box at start
+	xforms []gi.Matrix2D // transforms of the selected elements at start
+	pars   []gi.Matrix2D // full transforms of the parents of the selected elements
+	data   []PathData    // data of the path at start, for editing points
+	undo   *EditorUndo   // state of the selected elements at start
+}
+
+// EditorHandleSize is the half-size of the handles for editing the selection
+var EditorHandleSize = float32(4)
+
+// EditorRotateOffset is the distance of the rotate handle above the selection
+var EditorRotateOffset = float32(20)
+
+// EditorRotateHandle is the handle index of the rotate handle -- the other
+// handles are the corners and edge midpoints of the selection bounding box,
+// in clockwise order from the top-left, 0-7
+const EditorRotateHandle = 8
+
+// EditorSelColor is the color used for drawing the selection and its handles
+var EditorSelColor = color.RGBA{0, 120, 215, 255}
+
+// EditorGridColor is the color used for drawing the grid
+var EditorGridColor = color.RGBA{200, 200, 200, 255}
+
 // EditorEvents handles svg editing events
 func (svg *Editor) EditorEvents() {
 	svg.ConnectEvent(oswin.MouseDragEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
 		me := d.(*mouse.DragEvent)
 		me.SetProcessed()
 		ssvg := recv.Embed(KiT_Editor).(*Editor)
-		if ssvg.IsDragging() {
+		if ssvg.drag.mode == editorDragPan {
 			if !ssvg.SetDragCursor {
 				oswin.TheApp.Cursor(ssvg.Viewport.Win.OSWin).Push(cursor.HandOpen)
 				ssvg.SetDragCursor = true
@@ -43,13 +108,9 @@ func (svg *Editor) EditorEvents() {
 			ssvg.SetTransform()
 			ssvg.SetFullReRender()
 			ssvg.UpdateSig()
-		} else {
-			if ssvg.SetDragCursor {
-				oswin.TheApp.Cursor(ssvg.Viewport.Win.OSWin).Pop()
-				ssvg.SetDragCursor = false
-			}
+			return
 		}
-
+		ssvg.DragTo(ssvg.RenderPoint(me.Where))
 	})
 	svg.ConnectEvent(oswin.MouseScrollEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
 		me := d.(*mouse.ScrollEvent)
@@ -75,23 +136,60 @@ func (svg *Editor) EditorEvents() {
 			oswin.TheApp.Cursor(ssvg.Viewport.Win.OSWin).Pop()
 			ssvg.SetDragCursor = false
 		}
-		obj := ssvg.FirstContainingPoint(me.Where, true)
-		if me.Action == mouse.Release && me.Button == mouse.Right {
+		pt := ssvg.RenderPoint(me.Where)
+		switch {
+		case me.Button == mouse.Right:
+			if me.Action == mouse.Release {
+				me.SetProcessed()
+				if obj := ssvg.ElementAt(pt); obj != nil {
+					giv.StructViewDialog(ssvg.Viewport, obj, giv.DlgOpts{Title: "SVG Element View"}, nil, nil)
+				}
+			}
+		case me.Action == mouse.Press:
 			me.SetProcessed()
-			if obj != nil {
-				giv.StructViewDialog(ssvg.Viewport, obj, giv.DlgOpts{Title: "SVG Element View"}, nil, nil)
+			ssvg.GrabFocus()
+			if me.Button == mouse.Middle || me.HasAnyModifier(key.Alt) {
+				ssvg.drag.mode = editorDragPan
+				return
 			}
+			ssvg.DragStart(pt, me.HasAnyModifier(key.Shift, key.Control, key.Meta))
+		case me.Action == mouse.DoubleClick:
+			me.SetProcessed()
+			if obj := ssvg.ElementAt(pt); obj != nil {
+				ssvg.SetSelected([]gi.Node2D{obj})
+			}
+		case me.Action == mouse.Release:
+			me.SetProcessed()
+			ssvg.DragEnd()
 		}
 	})
 	svg.ConnectEvent(oswin.MouseHoverEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
 		me := d.(*mouse.HoverEvent)
 		me.SetProcessed()
 		ssvg := recv.Embed(KiT_Editor).(*Editor)
-		obj := ssvg.FirstContainingPoint(me.Where, true)
+		obj := ssvg.ElementAt(ssvg.RenderPoint(me.Where))
 		if obj != nil {
 			pos := me.Where
 			ttxt := fmt.Sprintf("element name: %v -- use right mouse click to edit", obj.Name())
-			gi.PopupTooltip(obj.Name(), pos.X, pos.Y, svg.Viewport, ttxt)
+			gi.PopupTooltip(ttxt, pos.X, pos.Y, ssvg.Viewport, obj.Name())
+		}
+	})
+	svg.ConnectEvent(oswin.KeyChordEvent, gi.RegPri, func(recv, send ki.Ki, sig int64, d interface{}) {
+		kt := d.(*key.ChordEvent)
+		ssvg := recv.Embed(KiT_Editor).(*Editor)
+		switch gi.KeyFun(kt.Chord()) {
+		case gi.KeyFunUndo:
+			kt.SetProcessed()
+			ssvg.Undo()
+		case gi.KeyFunRedo:
+			kt.SetProcessed()
+			ssvg.Redo()
+		case gi.KeyFunAbort:
+			kt.SetProcessed()
+			ssvg.SetSelected(nil)
+		case gi.KeyFunSelectAll:
+			kt.SetProcessed()
+			ssvg.SetSelected(ssvg.TopElements())
 		}
 	})
 }
@@ -113,6 +211,420 @@ func (svg *Editor) SetTransform() {
 	svg.SetProp("transform", fmt.Sprintf("translate(%v,%v) scale(%v,%v)", svg.Trans.X, svg.Trans.Y, svg.Scale, svg.Scale))
 }
 
+// OpenXML opens svg file, clearing the selection and the undo record
+func (svg *Editor) OpenXML(filename string) error {
+	svg.Undos = nil
+	svg.UndoPos = 0
+	svg.drag = editorDrag{}
+	err := svg.SVG.OpenXML(filename)
+	svg.setSelected(nil)
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////
+//  Coordinates
+
+// RenderPoint returns the point in the render coordinates of the svg
+// (i.e., its pixels) of given point in window coordinates, e.g., from events
+func (svg *Editor) RenderPoint(pt image.Point) gi.Vec2D {
+	return gi.NewVec2DFmPoint(pt.Sub(svg.WinBBox.Min))
+}
+
+// ParentXForm returns the full transform from the coordinates of the parent
+// of given element to the render coordinates of the svg -- the full
+// transform of the element itself is its own transform multiplied by this
+func (svg *Editor) ParentXForm(node gi.Node2D) gi.Matrix2D {
+	xf := gi.Identity2D()
+	for par := node.Parent(); par != nil && par != svg.This(); par = par.Parent() {
+		if pp, ok := par.(gi.Painter); ok {
+			xf = xf.Multiply(pp.Paint().XForm)
+		}
+	}
+	return xf.Multiply(svg.Pnt.XForm)
+}
+
+// GridSpacing returns the grid size, in user coordinates of the svg
+func (svg *Editor) GridSpacing() float32 {
+	if svg.GridSize <= 0 {
+		return 10
+	}
+	return svg.GridSize
+}
+
+// SnapPoint returns given point in render coords snapped to the grid, which
+// is in the user coordinates of the svg -- returns the point unchanged if
+// SnapGrid is off
+func (svg *Editor) SnapPoint(pt gi.Vec2D) gi.Vec2D {
+	if !svg.SnapGrid {
+		return pt
+	}
+	gs := svg.GridSpacing()
+	up := svg.Pnt.XForm.Inverse().TransformPointVec2D(pt)
+	up.X = gs * math32.Floor(up.X/gs+0.5)
+	up.Y = gs * math32.Floor(up.Y/gs+0.5)
+	return svg.Pnt.XForm.TransformPointVec2D(up)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////
+//  Selection
+
+// editorSkipNode returns true for nodes that are not rendered directly, and
+// are thus not selectable
+func editorSkipNode(k ki.Ki) bool {
+	switch k.(type) {
+	case *ClipPath, *Mask, *Filter, *Marker, *Symbol, *Pattern:
+		return true
+	}
+	return false
+}
+
+// ElementAt returns the top-most leaf element that contains given point in
+// render coords, i.e., the last one rendered -- text is selected as a whole
+func (svg *Editor) ElementAt(pt gi.Vec2D) gi.Node2D {
+	ipt := pt.ToPointFloor()
+	var rval gi.Node2D
+	svg.FuncDownMeFirst(0, svg.This(), func(k ki.Ki, level int, d interface{}) bool {
+		if k == svg.This() {
+			return true
+		}
+		if k == svg.Defs.This() || editorSkipNode(k) {
+			return false
+		}
+		gii, ni := gi.KiToNode2D(k)
+		if ni == nil {
+			return false
+		}
+		_, istxt := k.(*Text)
+		if !istxt && k.HasChildren() {
+			return true
+		}
+		if ipt.In(ni.BBox) {
+			rval = gii
+		}
+		return !istxt
+	})
+	return rval
+}
+
+// TopElement returns the ancestor of given element that is directly within
+// the svg, which is selected by clicking on the element
+func (svg *Editor) TopElement(node gi.Node2D) gi.Node2D {
+	for par := node.Parent(); par != nil && par != svg.This(); par = par.Parent() {
+		if pgi, _ := gi.KiToNode2D(par); pgi != nil {
+			node = pgi
+		}
+	}
+	return node
+}
+
+// TopElements returns all the selectable elements directly within the svg
+func (svg *Editor) TopElements() []gi.Node2D {
+	var els []gi.Node2D
+	for _, k := range svg.Kids {
+		if editorSkipNode(k) {
+			continue
+		}
+		if gii, _ := gi.KiToNode2D(k); gii != nil {
+			els = append(els, gii)
+		}
+	}
+	return els
+}
+
+// IsElementSelected returns true if given element is selected
+func (svg *Editor) IsElementSelected(node gi.Node2D) bool {
+	return svg.selIndex(node) >= 0
+}
+
+// selIndex returns the index of the element in Selected, or -1
+func (svg *Editor) selIndex(node gi.Node2D) int {
+	for i, sn := range svg.Selected {
+		if sn.This() == node.This() {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetSelected sets the selected elements, emitting SelectSig, and updates
+// the display
+func (svg *Editor) SetSelected(nodes []gi.Node2D) {
+	svg.setSelected(nodes)
+	svg.UpdateSig()
+}
+
+// setSelected sets the selected elements and emits SelectSig
+func (svg *Editor) setSelected(nodes []gi.Node2D) {
+	svg.Selected = nodes
+	svg.SelectSig.Emit(svg.This(), 0, nodes)
+}
+
+// ToggleSelected adds given element to the selection, or removes it if it is
+// already selected
+func (svg *Editor) ToggleSelected(node gi.Node2D) {
+	sl := append([]gi.Node2D{}, svg.Selected...)
+	if i := svg.selIndex(node); i >= 0 {
+		sl = append(sl[:i], sl[i+1:]...)
+	} else {
+		sl = append(sl, node)
+	}
+	svg.SetSelected(sl)
+}
+
+// SelectionBBox returns the bounding box of the selected elements, in render
+// coords -- false if there is no selection
+func (svg *Editor) SelectionBBox() (min, max gi.Vec2D, ok bool) {
+	var bb image.Rectangle
+	for _, sn := range svg.Selected {
+		bb = bb.Union(sn.AsNode2D().BBox)
+	}
+	if bb.Empty() {
+		return
+	}
+	return gi.NewVec2DFmPoint(bb.Min), gi.NewVec2DFmPoint(bb.Max), true
+}
+
+// SelectedPath returns the selected path if it is the only selected element,
+// whose points are then edited
+func (svg *Editor) SelectedPath() *Path {
+	if len(svg.Selected) != 1 {
+		return nil
+	}
+	p, _ := svg.Selected[0].(*Path)
+	return p
+}
+
+////////////////////////////////////////////////////////////////////////////////////////
+//  Dragging
+
+// HandlePos returns the position of given handle for the bounding box
+func HandlePos(min, max gi.Vec2D, handle int) gi.Vec2D {
+	if handle == EditorRotateHandle {
+		return gi.Vec2D{0.5 * (min.X + max.X), min.Y - EditorRotateOffset}
+	}
+	fx := [8]float32{0, .5, 1, 1, 1, .5, 0, 0}
+	fy := [8]float32{0, 0, 0, .5, 1, 1, 1, .5}
+	return gi.Vec2D{min.X + fx[handle]*(max.X-min.X), min.Y + fy[handle]*(max.Y-min.Y)}
+}
+
+// handleHit returns true if given point is on the handle at given position
+func handleHit(pt, hp gi.Vec2D) bool {
+	hs := EditorHandleSize + 1
+	return math32.Abs(pt.X-hp.X) <= hs && math32.Abs(pt.Y-hp.Y) <= hs
+}
+
+// PathPoints returns the points of the path in render coords, in the order
+// of PathDataIterFunc
+func (svg *Editor) PathPoints(p *Path) []gi.Vec2D {
+	xf := p.Pnt.XForm.Multiply(svg.ParentXForm(p))
+	var pts []gi.Vec2D
+	PathDataIterFunc(p.Data, func(idx int, cmd PathCmds, ptIdx int, cx, cy float32) bool {
+		pts = append(pts, xf.TransformPointVec2D(gi.Vec2D{cx, cy}))
+		return true
+	})
+	return pts
+}
+
+// handleAt returns the selection handle at given point in render coords,
+// and the drag mode it starts -- for a single selected path, its points are
+// checked first
+func (svg *Editor) handleAt(pt gi.Vec2D) (int, editorDragModes) {
+	if p := svg.SelectedPath(); p != nil {
+		for i, pp := range svg.PathPoints(p) {
+			if handleHit(pt, pp) {
+				return i, editorDragPoint
+			}
+		}
+	}
+	min, max, ok := svg.SelectionBBox()
+	if !ok {
+		return -1, editorDragNone
+	}
+	if handleHit(pt, HandlePos(min, max, EditorRotateHandle)) {
+		return EditorRotateHandle, editorDragRotate
+	}
+	for h := 0; h < 8; h++ {
+		if handleHit(pt, HandlePos(min, max, h)) {
+			return h, editorDragScale
+		}
+	}
+	return -1, editorDragNone
+}
+
+// DragStart starts a drag at given point in render coords: on a handle of
+// the selection it edits the selection with it, on an element it selects it
+// (adding to or toggling the selection if extend is set) and moves the
+// selection, and elsewhere it starts a rubber band selection
+func (svg *Editor) DragStart(pt gi.Vec2D, extend bool) {
+	dr := &svg.drag
+	*dr = editorDrag{start: pt, cur: pt, handle: -1}
+	dr.handle, dr.mode = svg.handleAt(pt)
+	if dr.mode == editorDragNone {
+		if el := svg.ElementAt(pt); el != nil {
+			el = svg.TopElement(el)
+			switch {
+			case extend:
+				svg.ToggleSelected(el)
+			case !svg.IsElementSelected(el):
+				svg.SetSelected([]gi.Node2D{el})
+			}
+			if svg.IsElementSelected(el) {
+				dr.mode = editorDragMove
+			}
+		} else {
+			if !extend {
+				svg.SetSelected(nil)
+			}
+			dr.mode = editorDragRubber
+			return
+		}
+	}
+	if dr.mode == editorDragNone {
+		return
+	}
+	dr.bbMin, dr.bbMax, _ = svg.SelectionBBox()
+	dr.undo = svg.NewUndo(svg.Selected)
+	for _, sn := range svg.Selected {
+		dr.xforms = append(dr.xforms, sn.This().(gi.Painter).Paint().XForm)
+		dr.pars = append(dr.pars, svg.ParentXForm(sn))
+	}
+	if dr.mode == editorDragPoint {
+		dr.data = append([]PathData{}, svg.SelectedPath().Data...)
+	}
+}
+
+// DragTo continues the current drag to given point in render coords,
+// applying the edit to the selection relative to the start of the drag
+func (svg *Editor) DragTo(pt gi.Vec2D) {
+	dr := &svg.drag
+	dr.cur = pt
+	del := pt.Sub(dr.start)
+	switch dr.mode {
+	case editorDragNone, editorDragPan:
+		return
+	case editorDragRubber:
+		svg.UpdateSig()
+		return
+	case editorDragMove:
+		del = svg.SnapPoint(dr.bbMin.Add(del)).Sub(dr.bbMin)
+		svg.applyDelta(gi.Translate2D(del.X, del.Y))
+	case editorDragScale:
+		anc := HandlePos(dr.bbMin, dr.bbMax, (dr.handle+4)%8)
+		hp0 := HandlePos(dr.bbMin, dr.bbMax, dr.handle)
+		hp := svg.SnapPoint(hp0.Add(del))
+		sx, sy := float32(1), float32(1)
+		if hp0.X != anc.X {
+			sx = editorMinScale((hp.X - anc.X) / (hp0.X - anc.X))
+		}
+		if hp0.Y != anc.Y {
+			sy = editorMinScale((hp.Y - anc.Y) / (hp0.Y - anc.Y))
+		}
+		svg.applyDelta(gi.Translate2D(-anc.X, -anc.Y).Multiply(gi.Scale2D(sx, sy)).Multiply(gi.Translate2D(anc.X, anc.Y)))
+	case editorDragRotate:
+		ctr := dr.bbMin.Add(dr.bbMax).MulVal(0.5)
+		ang := math32.Atan2(pt.Y-ctr.Y, pt.X-ctr.X) - math32.Atan2(dr.start.Y-ctr.Y, dr.start.X-ctr.X)
+		if svg.SnapGrid {
+			snap := gi.Radians(15)
+			ang = snap * math32.Floor(ang/snap+0.5)
+		}
+		svg.applyDelta(gi.Translate2D(-ctr.X, -ctr.Y).Multiply(gi.Rotate2D(ang)).Multiply(gi.Translate2D(ctr.X, ctr.Y)))
+	case editorDragPoint:
+		p := svg.SelectedPath()
+		if p == nil {
+			return
+		}
+		xf := dr.xforms[0].Multiply(dr.pars[0])
+		var pt0 gi.Vec2D
+		np := 0
+		PathDataIterFunc(dr.data, func(idx int, cmd PathCmds, ptIdx int, cx, cy float32) bool {
+			if np == dr.handle {
+				pt0 = gi.Vec2D{cx, cy}
+				return false
+			}
+			np++
+			return true
+		})
+		npt := xf.Inverse().TransformPointVec2D(svg.SnapPoint(xf.TransformPointVec2D(pt0).Add(del)))
+		data := append([]PathData{}, dr.data...)
+		PathDataMovePoint(data, dr.handle, npt.X-pt0.X, npt.Y-pt0.Y)
+		p.Data = data
+		p.DataStr = PathDataString(data)
+	}
+	svg.SetFullReRender()
+	svg.UpdateSig()
+}
+
+// editorMinScale keeps scaling factors away from zero, which would make the
+// transforms singular
+func editorMinScale(sc float32) float32 {
+	if math32.Abs(sc) < 0.01 {
+		if sc < 0 {
+			return -0.01
+		}
+		return 0.01
+	}
+	return sc
+}
+
+// applyDelta sets the transforms of the selected elements to their
+// transforms at the start of the drag, followed by given transform in render
+// coords
+func (svg *Editor) applyDelta(del gi.Matrix2D) {
+	dr := &svg.drag
+	for i, sn := range svg.Selected {
+		if i >= len(dr.xforms) {
+			break
+		}
+		par := dr.pars[i]
+		SetNodeXForm(sn, dr.xforms[i].Multiply(par).Multiply(del).Multiply(par.Inverse()))
+	}
+}
+
+// SetNodeXForm sets the transform of given element, both for rendering and
+// in its transform property, which is saved
+func SetNodeXForm(node gi.Node2D, xf gi.Matrix2D) {
+	pc := node.This().(gi.Painter).Paint()
+	pc.XForm = xf
+	node.SetProp("transform", fmt.Sprintf("matrix(%g,%g,%g,%g,%g,%g)", xf.XX, xf.YX, xf.XY, xf.YY, xf.X0, xf.Y0))
+}
+
+// DragEnd ends the current drag: a rubber band selects the elements within
+// it, and edits are recorded for undo
+func (svg *Editor) DragEnd() {
+	dr := &svg.drag
+	switch dr.mode {
+	case editorDragRubber:
+		min, max := dr.start.Min(dr.cur), dr.start.Max(dr.cur)
+		rb := image.Rectangle{min.ToPointFloor(), max.ToPointCeil()}
+		var sl []gi.Node2D
+		for _, el := range svg.TopElements() {
+			if svg.IsElementSelected(el) {
+				continue
+			}
+			bb := el.AsNode2D().BBox
+			if !bb.Empty() && bb.In(rb) {
+				sl = append(sl, el)
+			}
+		}
+		svg.SetSelected(append(svg.Selected, sl...))
+	case editorDragMove, editorDragScale, editorDragRotate, editorDragPoint:
+		if dr.cur != dr.start && dr.undo != nil {
+			dr.undo.SetAfter()
+			svg.AddUndo(*dr.undo)
+		}
+	}
+	*dr = editorDrag{}
+	svg.UpdateSig()
+}
+
+////////////////////////////////////////////////////////////////////////////////////////
+//  Rendering
+
+func (svg *Editor) Init2D() {
+	svg.SVG.Init2D()
+	svg.SetFlag(int(gi.CanFocus))
+}
+
 func (svg *Editor) Render2D() {
 	if svg.PushBounds() {
 		rs := &svg.Render
@@ -120,10 +632,97 @@ func (svg *Editor) Render2D() {
 		if svg.Fill {
 			svg.FillViewport()
 		}
+		if svg.ShowGrid {
+			svg.RenderGrid()
+		}
 		rs.PushXForm(svg.Pnt.XForm)
 		svg.Render2DChildren() // we must do children first, then us!
-		svg.PopBounds()
 		rs.PopXForm()
+		svg.RenderSelection()
+		svg.PopBounds()
 		svg.RenderViewport2D() // update our parent image
 	}
 }
+
+// editorPaint returns a paint for drawing the selection, grid etc in given color
+func editorPaint(clr color.Color) *gi.Paint {
+	pc := &gi.Paint{}
+	pc.Defaults()
+	pc.FillStyle.SetColor(nil)
+	pc.StrokeStyle.SetColor(clr)
+	pc.StrokeStyle.Width.Dots = 1
+	return pc
+}
+
+// RenderGrid draws the grid lines, at GridSpacing in the user coordinates of
+// the svg, over the visible area
+func (svg *Editor) RenderGrid() {
+	rs := &svg.Render
+	xf := svg.Pnt.XForm
+	gsz := xf.TransformVectorVec2D(gi.Vec2D{svg.GridSpacing(), svg.GridSpacing()})
+	if math32.Abs(gsz.X) < 4 || math32.Abs(gsz.Y) < 4 { // too dense to be useful
+		return
+	}
+	sz := gi.NewVec2DFmPoint(svg.Geom.Size)
+	inv := xf.Inverse()
+	umin := inv.TransformPointVec2D(gi.Vec2DZero)
+	umax := inv.TransformPointVec2D(sz)
+	umin, umax = umin.Min(umax), umin.Max(umax)
+	gs := svg.GridSpacing()
+	pc := editorPaint(EditorGridColor)
+	rs.Lock()
+	for x := gs * math32.Floor(umin.X/gs); x <= umax.X; x += gs {
+		px, _ := xf.TransformPoint(x, 0)
+		pc.DrawLine(rs, px, 0, px, sz.Y)
+	}
+	for y := gs * math32.Floor(umin.Y/gs); y <= umax.Y; y += gs {
+		_, py := xf.TransformPoint(0, y)
+		pc.DrawLine(rs, 0, py, sz.X, py)
+	}
+	pc.Stroke(rs)
+	rs.Unlock()
+}
+
+// RenderSelection draws the bounding box of the selection with its handles,
+// the points of a selected path, and the rubber band while selecting
+func (svg *Editor) RenderSelection() {
+	rs := &svg.Render
+	pc := editorPaint(EditorSelColor)
+	hs := EditorHandleSize
+	rs.Lock()
+	defer rs.Unlock()
+	if svg.drag.mode == editorDragRubber {
+		min, max := svg.drag.start.Min(svg.drag.cur), svg.drag.start.Max(svg.drag.cur)
+		pc.StrokeStyle.Dashes = []float64{4, 4}
+		pc.DrawRectangle(rs, min.X, min.Y, max.X-min.X, max.Y-min.Y)
+		pc.Stroke(rs)
+		pc.StrokeStyle.Dashes = nil
+	}
+	min, max, ok := svg.SelectionBBox()
+	if !ok {
+		return
+	}
+	pc.StrokeStyle.Dashes = []float64{4, 4}
+	pc.DrawRectangle(rs, min.X, min.Y, max.X-min.X, max.Y-min.Y)
+	pc.Stroke(rs)
+	pc.StrokeStyle.Dashes = nil
+	tc := HandlePos(min, max, 1)
+	rh := HandlePos(min, max, EditorRotateHandle)
+	pc.DrawLine(rs, tc.X, tc.Y, rh.X, rh.Y)
+	pc.Stroke(rs)
+	pc.FillStyle.SetColor(color.White)
+	for h := 0; h < 8; h++ {
+		hp := HandlePos(min, max, h)
+		pc.DrawRectangle(rs, hp.X-hs, hp.Y-hs, 2*hs, 2*hs)
+		pc.FillStrokeClear(rs)
+	}
+	pc.DrawCircle(rs, rh.X, rh.Y, hs)
+	pc.FillStrokeClear(rs)
+	if p := svg.SelectedPath(); p != nil {
+		pc.FillStyle.SetColor(EditorSelColor)
+		for _, pp := range svg.PathPoints(p) {
+			pc.DrawCircle(rs, pp.X, pp.Y, hs-1)
+			pc.FillStrokeClear(rs)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+const editorSVG = `<svg width="100" height="100" viewBox="0 0 100 100">
+  <g id="grp" transform="translate(10,20) scale(2)">
+    <rect id="box" x="0" y="0" width="10" height="10" transform="rotate(30)"/>
+  </g>
+  <rect id="other" x="60" y="60" width="10" height="10"/>
+</svg>
+`
+
+// readEditor returns an editor with editorSVG, rendered
+func readEditor(t *testing.T) *Editor {
+	t.Helper()
+	ed := &Editor{}
+	ed.InitName(ed, "ed")
+	if err := ed.ReadXML(bytes.NewReader([]byte(editorSVG))); err != nil {
+		t.Fatal(err)
+	}
+	renderPixels(&ed.Viewport2D, image.Point{100, 100})
+	return ed
+}
+
+// vecNear returns true if the vectors are equal, to within rounding
+func vecNear(a, b gi.Vec2D) bool {
+	d := a.Sub(b)
+	return d.X*d.X+d.Y*d.Y < 1e-6
+}
+
+func TestEditorApplyDelta(t *testing.T) {
+	ed := readEditor(t)
+	box := ed.FindNamedElement("box")
+	ed.SetSelected([]gi.Node2D{box})
+	min, max, ok := ed.SelectionBBox()
+	if !ok {
+		t.Fatal("no selection bbox")
+	}
+	ed.DragStart(HandlePos(min, max, 4), false)
+	if ed.drag.mode != editorDragScale {
+		t.Fatalf("drag on handle did not start scaling: %v", ed.drag.mode)
+	}
+	xf0 := box.This().(gi.Painter).Paint().XForm
+	par := ed.ParentXForm(box)
+	del := gi.Rotate2D(0.5).Multiply(gi.Translate2D(3, -4))
+	ed.applyDelta(del)
+	xf := box.This().(gi.Painter).Paint().XForm
+	for _, pt := range []gi.Vec2D{{0, 0}, {10, 0}, {0, 10}, {10, 10}} {
+		want := del.TransformPointVec2D(xf0.Multiply(par).TransformPointVec2D(pt))
+		got := xf.Multiply(par).TransformPointVec2D(pt)
+		if !vecNear(got, want) {
+			t.Errorf("point %v renders at %v, want %v", pt, got, want)
+		}
+	}
+	if tp, _ := box.Prop("transform"); tp == nil {
+		t.Errorf("transform property not set")
+	}
+}
+
+func TestEditorUndoRedo(t *testing.T) {
+	ed := readEditor(t)
+	nsig := 0
+	ed.SelectSig.Connect(ed.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		nsig++
+	})
+	if ed.Undo() || ed.Redo() {
+		t.Fatal("undo or redo with no edits")
+	}
+	other := ed.FindNamedElement("other")
+	bb := other.AsNode2D().BBox
+	start := gi.NewVec2DFmPoint(bb.Min.Add(bb.Max).Div(2))
+	ed.DragStart(start, false)
+	if len(ed.Selected) != 1 || ed.Selected[0] != other {
+		t.Fatalf("drag did not select the element: %v", ed.Selected)
+	}
+	ed.DragTo(start.Add(gi.Vec2D{5, 0}))
+	ed.DragEnd()
+	if len(ed.Undos) != 1 || ed.UndoPos != 1 {
+		t.Fatalf("move not recorded: %d undos, at %d", len(ed.Undos), ed.UndoPos)
+	}
+	moved, _ := other.Prop("transform")
+	if moved == nil {
+		t.Fatal("move did not set the transform")
+	}
+
+	ed.SetSelected(nil)
+	nsig = 0
+	if !ed.Undo() {
+		t.Fatal("undo failed")
+	}
+	if tp, ok := other.Prop("transform"); ok {
+		t.Errorf("undo left the transform: %v", tp)
+	}
+	if xf := other.This().(gi.Painter).Paint().XForm; xf != gi.Identity2D() {
+		t.Errorf("undo left the render transform: %v", xf)
+	}
+	if len(ed.Selected) != 1 || ed.Selected[0] != other || nsig != 1 {
+		t.Errorf("undo did not select the element: %v, %d signals", ed.Selected, nsig)
+	}
+	if ed.Undo() {
+		t.Errorf("second undo with one edit")
+	}
+
+	if !ed.Redo() {
+		t.Fatal("redo failed")
+	}
+	if tp, _ := other.Prop("transform"); tp != moved {
+		t.Errorf("redo set transform %v, want %v", tp, moved)
+	}
+	if ed.Redo() {
+		t.Errorf("second redo with one edit")
+	}
+
+	ed.Undo()
+	ed.AddUndo(EditorUndo{Desc: "new edit"})
+	if len(ed.Undos) != 1 || ed.Undos[0].Desc != "new edit" || ed.Redo() {
+		t.Errorf("new edit did not discard the undone edit")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"github.com/goki/gi/gi"
+)
+
+// EditorNodeState is the editable state of an element, recorded for undo
+type EditorNodeState struct {
+	XForm interface{} `desc:"transform property of the element -- nil if not set"`
+	Data  []PathData  `desc:"path data, for paths"`
+}
+
+// NodeState returns the editable state of given element
+func NodeState(node gi.Node2D) EditorNodeState {
+	st := EditorNodeState{}
+	st.XForm, _ = node.Prop("transform")
+	if p, ok := node.(*Path); ok {
+		st.Data = append([]PathData{}, p.Data...)
+	}
+	return st
+}
+
+// SetNodeState restores the editable state of given element
+func SetNodeState(node gi.Node2D, st EditorNodeState) {
+	pc := node.This().(gi.Painter).Paint()
+	pc.XForm = gi.Identity2D()
+	switch xf := st.XForm.(type) {
+	case nil:
+		node.DeleteProp("transform")
+	case string:
+		pc.XForm.SetString(xf)
+		node.SetProp("transform", xf)
+	case gi.Matrix2D:
+		pc.XForm = xf
+		node.SetProp("transform", xf)
+	default:
+		node.SetProp("transform", xf)
+	}
+	if p, ok := node.(*Path); ok && st.Data != nil {
+		p.Data = append([]PathData{}, st.Data...)
+		p.DataStr = PathDataString(p.Data)
+	}
+}
+
+// EditorUndo records one edit of the Editor, with the state of the edited
+// elements before and after it
+type EditorUndo struct {
+	Desc   string            `desc:"description of the edit"`
+	Nodes  []gi.Node2D       `desc:"the edited elements"`
+	Before []EditorNodeState `desc:"state of the elements before the edit"`
+	After  []EditorNodeState `desc:"state of the elements after the edit"`
+}
+
+// NewUndo returns a new undo record for editing given elements, with their
+// current state as the state before the edit -- call SetAfter when done
+func (svg *Editor) NewUndo(nodes []gi.Node2D) *EditorUndo {
+	ud := &EditorUndo{Nodes: append([]gi.Node2D{}, nodes...)}
+	for _, nd := range nodes {
+		ud.Before = append(ud.Before, NodeState(nd))
+	}
+	return ud
+}
+
+// SetAfter records the current state of the elements as the state after the
+// edit
+func (ud *EditorUndo) SetAfter() {
+	ud.After = nil
+	for _, nd := range ud.Nodes {
+		ud.After = append(ud.After, NodeState(nd))
+	}
+}
+
+// AddUndo adds given edit to the undo record, discarding any edits that had
+// been undone
+func (svg *Editor) AddUndo(ud EditorUndo) {
+	svg.Undos = append(svg.Undos[:svg.UndoPos], ud)
+	svg.UndoPos = len(svg.Undos)
+}
+
+// Undo undoes the last edit that is in effect, returning false if there are
+// none
+func (svg *Editor) Undo() bool {
+	if svg.UndoPos == 0 {
+		return false
+	}
+	svg.UndoPos--
+	ud := &svg.Undos[svg.UndoPos]
+	svg.applyUndo(ud.Nodes, ud.Before)
+	return true
+}
+
+// Redo redoes the last edit that was undone, returning false if there are
+// none
+func (svg *Editor) Redo() bool {
+	if svg.UndoPos >= len(svg.Undos) {
+		return false
+	}
+	ud := &svg.Undos[svg.UndoPos]
+	svg.UndoPos++
+	svg.applyUndo(ud.Nodes, ud.After)
+	return true
+}
+
+// applyUndo sets the state of given elements, and selects them
+func (svg *Editor) applyUndo(nodes []gi.Node2D, sts []EditorNodeState) {
+	for i, nd := range nodes {
+		if i < len(sts) {
+			SetNodeState(nd, sts[i])
+		}
+	}
+	svg.setSelected(append([]gi.Node2D{}, nodes...))
+	svg.SetFullReRender()
+	svg.UpdateSig()
+}
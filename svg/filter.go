@@ -5,13 +5,506 @@
 package svg
 
 import (
+	"image"
+	"image/draw"
+	"log"
+	"math"
+
+	"github.com/chewxy/math32"
+	"github.com/goki/gi/gi"
 	"github.com/goki/ki/kit"
 )
 
-// Filter represents SVG filter* elements
+// Filter represents SVG filter* elements -- the filter element itself, with
+// FilterType filter, and the fe* filter primitives within it, e.g.,
+// feGaussianBlur, whose attributes are stored as properties.  An element
+// with a filter="url(#name)" property is rendered into a separate layer,
+// which is then processed by the primitives of the filter, in order -- see
+// FilterImage
 type Filter struct {
 	NodeBase
 	FilterType string
 }
 
 var KiT_Filter = kit.Types.AddType(&Filter{}, nil)
+
+// Render2D does nothing -- filters are only applied to the elements that
+// refer to them, by FilterImage
+func (f *Filter) Render2D() {
+}
+
+// Attr returns the value of given attribute of the filter, or dflt if not
+// set
+func (f *Filter) Attr(nm, dflt string) string {
+	if pv, ok := f.Props[nm]; ok {
+		return kit.ToString(pv)
+	}
+	return dflt
+}
+
+// AttrFloats returns the numbers in given attribute of the filter, or dflt if
+// not set -- at least as many numbers as in dflt are returned, repeating the
+// last one given, as for stdDeviation
+func (f *Filter) AttrFloats(nm string, dflt ...float32) []float32 {
+	pv, ok := f.Props[nm]
+	if !ok {
+		return dflt
+	}
+	fs := gi.ReadPoints(kit.ToString(pv))
+	if len(fs) == 0 {
+		return dflt
+	}
+	for len(fs) < len(dflt) {
+		fs = append(fs, fs[len(fs)-1])
+	}
+	return fs
+}
+
+// AttrFloat returns the number in given attribute of the filter, or dflt if
+// not set
+func (f *Filter) AttrFloat(nm string, dflt float32) float32 {
+	return f.AttrFloats(nm, dflt)[0]
+}
+
+// Filter returns the filter referred to by the filter property, or nil if
+// none
+func (g *NodeBase) Filter() *Filter {
+	nd := g.URLProp("filter")
+	if nd == nil {
+		return nil
+	}
+	flt, ok := nd.(*Filter)
+	if !ok || flt.FilterType != "filter" {
+		log.Printf("gi.svg Found element named: %v but isn't a filter element, instead is: %T", nd.Name(), nd)
+		return nil
+	}
+	return flt
+}
+
+// filterContext is the state for applying a filter to an image
+type filterContext struct {
+	src     *image.RGBA            `desc:"the source graphic"`
+	region  image.Rectangle        `desc:"filter region in image coordinates -- everything outside it is transparent"`
+	bbox    image.Rectangle        `desc:"bounding box of the element being filtered"`
+	xf      gi.Matrix2D            `desc:"transform of the element being filtered"`
+	units   ClipUnits              `desc:"primitiveUnits of the filter"`
+	results map[string]*image.RGBA `desc:"named results of primitives"`
+	last    *image.RGBA            `desc:"result of the last primitive"`
+	warned  map[string]bool        `desc:"unsupported primitives that have been logged"`
+}
+
+// FilterImage applies the primitives of the filter to given image, rendered
+// for an element with given bounding box using given transform, and returns
+// the result, which is transparent outside of the filter region
+func (f *Filter) FilterImage(src *image.RGBA, bbox image.Rectangle, xf gi.Matrix2D) *image.RGBA {
+	fc := &filterContext{src: src, bbox: bbox, xf: xf, results: make(map[string]*image.RGBA)}
+	fc.units = ParseClipUnits(f.Attr("primitiveUnits", ""), ClipUserSpace)
+	units := ParseClipUnits(f.Attr("filterUnits", ""), ClipObjectBBox)
+	var pos, sz gi.Vec2D
+	pos.X, _ = parseClipLen(f.Attr("x", "-10%"))
+	pos.Y, _ = parseClipLen(f.Attr("y", "-10%"))
+	sz.X, _ = parseClipLen(f.Attr("width", "120%"))
+	sz.Y, _ = parseClipLen(f.Attr("height", "120%"))
+	rxf := ClipUnitsXForm(units, xf, bbox)
+	rmin := rxf.TransformPointVec2D(pos)
+	rmax := rxf.TransformPointVec2D(pos.Add(sz))
+	fc.region = image.Rect(int(rmin.X), int(rmin.Y), int(rmax.X+0.5), int(rmax.Y+0.5)).Intersect(src.Bounds())
+
+	for _, kid := range f.Kids {
+		fe, ok := kid.(*Filter)
+		if !ok {
+			continue
+		}
+		out := fc.apply(fe)
+		if rn := fe.Attr("result", ""); rn != "" {
+			fc.results[rn] = out
+		}
+		fc.last = out
+	}
+	if fc.last == nil {
+		fc.last = fc.input("SourceGraphic")
+	}
+	return fc.last
+}
+
+// newImage returns a new transparent image for a result
+func (fc *filterContext) newImage() *image.RGBA {
+	return image.NewRGBA(fc.src.Bounds())
+}
+
+// input returns the image for given in or in2 attribute value
+func (fc *filterContext) input(in string) *image.RGBA {
+	switch in {
+	case "":
+		if fc.last != nil {
+			return fc.last
+		}
+		return fc.input("SourceGraphic")
+	case "SourceGraphic":
+		img := fc.newImage()
+		draw.Draw(img, fc.region, fc.src, fc.region.Min, draw.Src)
+		return img
+	case "SourceAlpha":
+		img := fc.newImage()
+		r := fc.region
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			so := fc.src.PixOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Pix[so+3] = fc.src.Pix[so+3]
+				so += 4
+			}
+		}
+		return img
+	}
+	if img, ok := fc.results[in]; ok {
+		return img
+	}
+	if !fc.warned[in] { // BackgroundImage etc, or misspelled result name
+		log.Printf("svg.Filter: input not supported or not found: %v\n", in)
+		if fc.warned == nil {
+			fc.warned = make(map[string]bool)
+		}
+		fc.warned[in] = true
+	}
+	return fc.newImage()
+}
+
+// lengths returns given lengths in primitive units as image pixels
+func (fc *filterContext) lengths(x, y float32) (float32, float32) {
+	if fc.units == ClipObjectBBox {
+		return x * float32(fc.bbox.Dx()), y * float32(fc.bbox.Dy())
+	}
+	sx, sy := fc.xf.ExtractScale()
+	return x * math32.Abs(sx), y * math32.Abs(sy)
+}
+
+// apply applies given filter primitive, returning its result
+func (fc *filterContext) apply(fe *Filter) *image.RGBA {
+	in := fc.input(fe.Attr("in", ""))
+	switch fe.FilterType {
+	case "feGaussianBlur":
+		sd := fe.AttrFloats("stdDeviation", 0, 0)
+		sx, sy := fc.lengths(sd[0], sd[1])
+		return fc.blur(in, sx, sy)
+	case "feOffset":
+		dx, dy := fc.lengths(fe.AttrFloat("dx", 0), fe.AttrFloat("dy", 0))
+		return fc.offset(in, dx, dy)
+	case "feFlood":
+		return fc.flood(fe)
+	case "feMerge":
+		out := fc.newImage()
+		for _, kid := range fe.Kids {
+			if mn, ok := kid.(*Filter); ok && mn.FilterType == "feMergeNode" {
+				draw.Draw(out, fc.region, fc.input(mn.Attr("in", "")), fc.region.Min, draw.Over)
+			}
+		}
+		return out
+	case "feComposite":
+		return fc.composite(fe, in, fc.input(fe.Attr("in2", "")))
+	case "feColorMatrix":
+		return fc.colorMatrix(fe, in)
+	case "feBlend":
+		return fc.blend(fe.Attr("mode", "normal"), in, fc.input(fe.Attr("in2", "")))
+	case "feDropShadow":
+		sd := fe.AttrFloats("stdDeviation", 2, 2)
+		sx, sy := fc.lengths(sd[0], sd[1])
+		dx, dy := fc.lengths(fe.AttrFloat("dx", 2), fe.AttrFloat("dy", 2))
+		shad := fc.offset(fc.blur(fc.alpha(in), sx, sy), dx, dy)
+		shad = fc.composite(nil, fc.flood(fe), shad) // flood in shadow
+		draw.Draw(shad, fc.region, in, fc.region.Min, draw.Over)
+		return shad
+	}
+	if !fc.warned[fe.FilterType] {
+		log.Printf("svg.Filter: filter primitive not supported: %v\n", fe.FilterType)
+		if fc.warned == nil {
+			fc.warned = make(map[string]bool)
+		}
+		fc.warned[fe.FilterType] = true
+	}
+	return in
+}
+
+// alpha returns the alpha channel of given image, as black
+func (fc *filterContext) alpha(img *image.RGBA) *image.RGBA {
+	out := fc.newImage()
+	for i := 3; i < len(img.Pix); i += 4 {
+		out.Pix[i] = img.Pix[i]
+	}
+	return out
+}
+
+// flood returns the region filled with the flood-color and flood-opacity of
+// given primitive -- the color is not premultiplied, so its own alpha and the
+// opacity are both applied to it
+func (fc *filterContext) flood(fe *Filter) *image.RGBA {
+	clr, err := gi.ColorFromString(fe.Attr("flood-color", "black"), nil)
+	if err != nil {
+		log.Printf("svg.Filter: %v\n", err)
+	}
+	al := clamp01(fe.AttrFloat("flood-opacity", 1)) * float32(clr.A) / 255
+	if clr.IsNil() {
+		al = 0
+	}
+	pc := [4]uint8{uint8(float32(clr.R)*al + 0.5), uint8(float32(clr.G)*al + 0.5), uint8(float32(clr.B)*al + 0.5), uint8(255*al + 0.5)}
+	out := fc.newImage()
+	r := fc.region
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		o := out.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			copy(out.Pix[o:o+4], pc[:])
+			o += 4
+		}
+	}
+	return out
+}
+
+// offset returns given image offset by given number of pixels
+func (fc *filterContext) offset(img *image.RGBA, dx, dy float32) *image.RGBA {
+	out := fc.newImage()
+	r := fc.region
+	d := image.Point{int(math32.Floor(dx + 0.5)), int(math32.Floor(dy + 0.5))}
+	sr := r.Sub(d).Intersect(r)
+	dp := sr.Min.Add(d)
+	draw.Draw(out, image.Rectangle{Min: dp, Max: dp.Add(sr.Size())}, img, sr.Min, draw.Src)
+	return out
+}
+
+// blur returns given image blurred with given standard deviations in
+// pixels, approximating a gaussian by three box blurs, as in the svg spec
+func (fc *filterContext) blur(img *image.RGBA, sx, sy float32) *image.RGBA {
+	out := fc.newImage()
+	draw.Draw(out, fc.region, img, fc.region.Min, draw.Src)
+	boxBlur3(out, fc.region, sx, true)
+	boxBlur3(out, fc.region, sy, false)
+	return out
+}
+
+// boxBlur3 applies the three box blurs approximating a gaussian blur with
+// given standard deviation, in given direction
+func boxBlur3(img *image.RGBA, r image.Rectangle, sd float32, horiz bool) {
+	d := int(math.Floor(float64(sd)*3*math.Sqrt(2*math.Pi)/4 + 0.5))
+	if d < 2 {
+		return
+	}
+	if d%2 == 1 {
+		hd := (d - 1) / 2
+		for i := 0; i < 3; i++ {
+			boxBlur(img, r, hd, hd, horiz)
+		}
+		return
+	}
+	hd := d / 2
+	boxBlur(img, r, hd, hd-1, horiz)
+	boxBlur(img, r, hd-1, hd, horiz)
+	boxBlur(img, r, hd, hd, horiz)
+}
+
+// boxBlur blurs the image within region r in place, averaging over the
+// window from lo pixels before to hi pixels after each pixel, horizontally
+// or vertically -- pixels outside of r are transparent
+func boxBlur(img *image.RGBA, r image.Rectangle, lo, hi int, horiz bool) {
+	n := lo + hi + 1
+	lines, cnt, step := r.Dx(), r.Dy(), img.Stride
+	if horiz {
+		lines, cnt, step = r.Dy(), r.Dx(), 4
+	}
+	buf := make([]uint8, cnt*4)
+	for l := 0; l < lines; l++ {
+		st := img.PixOffset(r.Min.X+l, r.Min.Y)
+		if horiz {
+			st = img.PixOffset(r.Min.X, r.Min.Y+l)
+		}
+		for i := 0; i < cnt; i++ {
+			copy(buf[i*4:i*4+4], img.Pix[st+i*step:st+i*step+4])
+		}
+		var sum [4]int
+		for j := 0; j <= hi && j < cnt; j++ {
+			for c := 0; c < 4; c++ {
+				sum[c] += int(buf[j*4+c])
+			}
+		}
+		for i := 0; i < cnt; i++ {
+			o := st + i*step
+			for c := 0; c < 4; c++ {
+				img.Pix[o+c] = uint8((sum[c] + n/2) / n)
+			}
+			if k := i - lo; k >= 0 {
+				for c := 0; c < 4; c++ {
+					sum[c] -= int(buf[k*4+c])
+				}
+			}
+			if k := i + hi + 1; k < cnt {
+				for c := 0; c < 4; c++ {
+					sum[c] += int(buf[k*4+c])
+				}
+			}
+		}
+	}
+}
+
+// pixelFunc computes the result pixel from pixels a and b, as premultiplied
+// values in 0..1, with alpha last
+type pixelFunc func(a, b [4]float32) [4]float32
+
+// combine returns the result of combining images a and b pixel by pixel
+// within the region
+func (fc *filterContext) combine(a, b *image.RGBA, fun pixelFunc) *image.RGBA {
+	out := fc.newImage()
+	r := fc.region
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		o := out.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var pa, pb [4]float32
+			for c := 0; c < 4; c++ {
+				pa[c] = float32(a.Pix[o+c]) / 255
+				pb[c] = float32(b.Pix[o+c]) / 255
+			}
+			pr := fun(pa, pb)
+			al := clamp01(pr[3])
+			for c := 0; c < 3; c++ {
+				out.Pix[o+c] = uint8(math32.Min(clamp01(pr[c]), al)*255 + 0.5)
+			}
+			out.Pix[o+3] = uint8(al*255 + 0.5)
+			o += 4
+		}
+	}
+	return out
+}
+
+func clamp01(v float32) float32 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 1:
+		return 1
+	}
+	return v
+}
+
+// composite returns the Porter-Duff or arithmetic composite of a with b,
+// using the operator of given feComposite primitive -- a nil primitive
+// is the in operator
+func (fc *filterContext) composite(fe *Filter, a, b *image.RGBA) *image.RGBA {
+	op := "in"
+	if fe != nil {
+		op = fe.Attr("operator", "over")
+	}
+	var fa, fb func(aa, ab float32) float32 // fractions of a and b in the result
+	switch op {
+	case "in":
+		fa = func(aa, ab float32) float32 { return ab }
+		fb = func(aa, ab float32) float32 { return 0 }
+	case "out":
+		fa = func(aa, ab float32) float32 { return 1 - ab }
+		fb = func(aa, ab float32) float32 { return 0 }
+	case "atop":
+		fa = func(aa, ab float32) float32 { return ab }
+		fb = func(aa, ab float32) float32 { return 1 - aa }
+	case "xor":
+		fa = func(aa, ab float32) float32 { return 1 - ab }
+		fb = func(aa, ab float32) float32 { return 1 - aa }
+	case "arithmetic":
+		k1, k2 := fe.AttrFloat("k1", 0), fe.AttrFloat("k2", 0)
+		k3, k4 := fe.AttrFloat("k3", 0), fe.AttrFloat("k4", 0)
+		return fc.combine(a, b, func(pa, pb [4]float32) [4]float32 {
+			var pr [4]float32
+			for c := 0; c < 4; c++ {
+				pr[c] = k1*pa[c]*pb[c] + k2*pa[c] + k3*pb[c] + k4
+			}
+			return pr
+		})
+	default: // over
+		fa = func(aa, ab float32) float32 { return 1 }
+		fb = func(aa, ab float32) float32 { return 1 - aa }
+	}
+	return fc.combine(a, b, func(pa, pb [4]float32) [4]float32 {
+		sa, sb := fa(pa[3], pb[3]), fb(pa[3], pb[3])
+		var pr [4]float32
+		for c := 0; c < 4; c++ {
+			pr[c] = pa[c]*sa + pb[c]*sb
+		}
+		return pr
+	})
+}
+
+// blend returns a blended over b using given blend mode
+func (fc *filterContext) blend(mode string, a, b *image.RGBA) *image.RGBA {
+	var fun func(ca, cb, qa, qb float32) float32
+	switch mode {
+	case "multiply":
+		fun = func(ca, cb, qa, qb float32) float32 { return (1-qa)*cb + (1-qb)*ca + ca*cb }
+	case "screen":
+		fun = func(ca, cb, qa, qb float32) float32 { return cb + ca - ca*cb }
+	case "darken":
+		fun = func(ca, cb, qa, qb float32) float32 { return math32.Min((1-qa)*cb+ca, (1-qb)*ca+cb) }
+	case "lighten":
+		fun = func(ca, cb, qa, qb float32) float32 { return math32.Max((1-qa)*cb+ca, (1-qb)*ca+cb) }
+	default: // normal
+		fun = func(ca, cb, qa, qb float32) float32 { return (1-qa)*cb + ca }
+	}
+	return fc.combine(a, b, func(pa, pb [4]float32) [4]float32 {
+		var pr [4]float32
+		for c := 0; c < 3; c++ {
+			pr[c] = fun(pa[c], pb[c], pa[3], pb[3])
+		}
+		pr[3] = 1 - (1-pa[3])*(1-pb[3])
+		return pr
+	})
+}
+
+// colorMatrix returns the image transformed by the color matrix of given
+// feColorMatrix primitive, applied to non-premultiplied colors
+func (fc *filterContext) colorMatrix(fe *Filter, img *image.RGBA) *image.RGBA {
+	var m [20]float32 // 4 rows of r, g, b, a, offset
+	typ := fe.Attr("type", "matrix")
+	switch typ {
+	case "saturate":
+		s := fe.AttrFloat("values", 1)
+		m = [20]float32{
+			0.213 + 0.787*s, 0.715 - 0.715*s, 0.072 - 0.072*s, 0, 0,
+			0.213 - 0.213*s, 0.715 + 0.285*s, 0.072 - 0.072*s, 0, 0,
+			0.213 - 0.213*s, 0.715 - 0.715*s, 0.072 + 0.928*s, 0, 0,
+			0, 0, 0, 1, 0}
+	case "hueRotate":
+		ang := gi.Radians(fe.AttrFloat("values", 0))
+		cs, sn := math32.Cos(ang), math32.Sin(ang)
+		m = [20]float32{
+			0.213 + cs*0.787 - sn*0.213, 0.715 - cs*0.715 - sn*0.715, 0.072 - cs*0.072 + sn*0.928, 0, 0,
+			0.213 - cs*0.213 + sn*0.143, 0.715 + cs*0.285 + sn*0.140, 0.072 - cs*0.072 - sn*0.283, 0, 0,
+			0.213 - cs*0.213 - sn*0.787, 0.715 - cs*0.715 + sn*0.715, 0.072 + cs*0.928 + sn*0.072, 0, 0,
+			0, 0, 0, 1, 0}
+	case "luminanceToAlpha":
+		m = [20]float32{
+			0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0,
+			0.2125, 0.7154, 0.0721, 0, 0}
+	default: // matrix
+		vals := fe.AttrFloats("values")
+		if len(vals) != 20 {
+			if len(vals) > 0 {
+				log.Printf("svg.Filter: feColorMatrix values must have 20 numbers, has: %v\n", len(vals))
+			}
+			return img
+		}
+		copy(m[:], vals)
+	}
+	return fc.combine(img, img, func(pa, pb [4]float32) [4]float32 {
+		var uc [4]float32 // unpremultiplied
+		if pa[3] > 0 {
+			for c := 0; c < 3; c++ {
+				uc[c] = pa[c] / pa[3]
+			}
+		}
+		uc[3] = pa[3]
+		var pr [4]float32
+		for r := 0; r < 4; r++ {
+			rw := m[r*5 : r*5+5]
+			pr[r] = clamp01(rw[0]*uc[0] + rw[1]*uc[1] + rw[2]*uc[2] + rw[3]*uc[3] + rw[4])
+		}
+		for c := 0; c < 3; c++ {
+			pr[c] *= pr[3]
+		}
+		return pr
+	})
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/goki/gi/gi"
+)
+
+// readFilter returns the filter named f in given svg text of filter elements
+func readFilter(t *testing.T, txt string) *Filter {
+	t.Helper()
+	sv := readSVG(t, []byte(`<svg width="10" height="10"><defs>`+txt+`</defs></svg>`))
+	f, ok := sv.FindNamedElement("f").(*Filter)
+	if !ok {
+		t.Fatalf("filter f not found in: %v", txt)
+	}
+	return f
+}
+
+// filterSrc returns a new source image of given size, with pixels given by fun
+func filterSrc(w, h int, fun func(x, y int) color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, fun(x, y))
+		}
+	}
+	return img
+}
+
+// filterPixel is a pixel of a filtered image with its expected color
+type filterPixel struct {
+	x, y int
+	clr  color.RGBA
+}
+
+// checkFilterPixels checks the given pixels of the image, to within 1 for
+// rounding
+func checkFilterPixels(t *testing.T, name string, img *image.RGBA, fps []filterPixel) {
+	t.Helper()
+	near := func(a, b uint8) bool { return int(a) <= int(b)+1 && int(b) <= int(a)+1 }
+	for _, fp := range fps {
+		c := img.RGBAAt(fp.x, fp.y)
+		if !near(c.R, fp.clr.R) || !near(c.G, fp.clr.G) || !near(c.B, fp.clr.B) || !near(c.A, fp.clr.A) {
+			t.Errorf("%v: pixel at %v,%v = %v, want %v", name, fp.x, fp.y, c, fp.clr)
+		}
+	}
+}
+
+var (
+	fRed   = color.RGBA{255, 0, 0, 255}
+	fBlue  = color.RGBA{0, 0, 255, 255}
+	fWhite = color.RGBA{255, 255, 255, 255}
+	fNone  = color.RGBA{}
+)
+
+// leftRed is a source image with the left half opaque red
+func leftRed(x, y int) color.RGBA {
+	if x < 5 {
+		return fRed
+	}
+	return fNone
+}
+
+// userFilter is a filter whose region is the whole 10x10 image
+const userFilter = `<filter id="f" filterUnits="userSpaceOnUse" x="0" y="0" width="10" height="10">`
+
+func TestFilterImageRegion(t *testing.T) {
+	src := filterSrc(20, 20, func(x, y int) color.RGBA { return fRed })
+	f := readFilter(t, `<filter id="f"></filter>`)
+	out := f.FilterImage(src, image.Rect(5, 5, 15, 15), gi.Identity2D())
+	checkFilterPixels(t, "default region", out, []filterPixel{
+		{3, 3, fNone}, {4, 4, fRed}, {15, 15, fRed}, {16, 16, fNone}, {10, 3, fNone}})
+
+	f = readFilter(t, `<filter id="f" x="0" y="0" width="0.5" height="1"></filter>`)
+	out = f.FilterImage(src, image.Rect(5, 5, 15, 15), gi.Identity2D())
+	checkFilterPixels(t, "object bbox region", out, []filterPixel{
+		{5, 5, fRed}, {9, 14, fRed}, {10, 5, fNone}, {4, 5, fNone}})
+
+	f = readFilter(t, `<filter id="f" filterUnits="userSpaceOnUse" x="2" y="2" width="4" height="4"></filter>`)
+	out = f.FilterImage(src, image.Rect(5, 5, 15, 15), gi.Scale2D(2, 2))
+	checkFilterPixels(t, "user space region", out, []filterPixel{
+		{4, 4, fRed}, {11, 11, fRed}, {3, 3, fNone}, {12, 12, fNone}})
+}
+
+func TestFilterOffset(t *testing.T) {
+	src := filterSrc(10, 10, func(x, y int) color.RGBA {
+		if x == 5 && y == 5 {
+			return fRed
+		}
+		return fNone
+	})
+	f := readFilter(t, userFilter+`<feOffset dx="2" dy="-1"/></filter>`)
+	out := f.FilterImage(src, src.Bounds(), gi.Identity2D())
+	checkFilterPixels(t, "offset", out, []filterPixel{{7, 4, fRed}, {5, 5, fNone}})
+
+	out = f.FilterImage(src, src.Bounds(), gi.Scale2D(2, 2)) // in user units
+	checkFilterPixels(t, "scaled offset", out, []filterPixel{{9, 3, fRed}, {7, 4, fNone}})
+}
+
+func TestFilterBlur(t *testing.T) {
+	dot := filterSrc(10, 10, func(x, y int) color.RGBA {
+		if x == 5 && y == 5 {
+			return fWhite
+		}
+		return fNone
+	})
+	// stdDeviation 1 is box blurs of 2, 2, 3 pixels: a dot of 255 becomes
+	// 128 128, then 64 128 64, then 21 64 85 64 21
+	row := []uint8{0, 0, 0, 21, 64, 85, 64, 21, 0, 0}
+	tests := []struct {
+		name, sd string
+		horiz    bool
+	}{
+		{"horizontal", "1 0", true},
+		{"vertical", "0 1", false},
+	}
+	for _, tt := range tests {
+		f := readFilter(t, userFilter+`<feGaussianBlur stdDeviation="`+tt.sd+`"/></filter>`)
+		out := f.FilterImage(dot, dot.Bounds(), gi.Identity2D())
+		var fps []filterPixel
+		for i, v := range row {
+			fp := filterPixel{i, 5, color.RGBA{v, v, v, v}}
+			if !tt.horiz {
+				fp.x, fp.y = 5, i
+			}
+			fps = append(fps, fp)
+		}
+		fps = append(fps, filterPixel{4, 4, fNone})
+		checkFilterPixels(t, tt.name, out, fps)
+	}
+
+	f := readFilter(t, userFilter+`<feGaussianBlur stdDeviation="1"/></filter>`)
+	out := f.FilterImage(dot, dot.Bounds(), gi.Identity2D())
+	checkFilterPixels(t, "both", out, []filterPixel{ // product of the rows
+		{5, 5, color.RGBA{28, 28, 28, 28}}, {4, 5, color.RGBA{21, 21, 21, 21}}, {3, 3, color.RGBA{2, 2, 2, 2}}})
+
+	f = readFilter(t, userFilter+`<feGaussianBlur stdDeviation="0.2"/></filter>`)
+	out = f.FilterImage(dot, dot.Bounds(), gi.Identity2D())
+	checkFilterPixels(t, "too small", out, []filterPixel{{5, 5, fWhite}, {4, 5, fNone}})
+}
+
+func TestFilterFlood(t *testing.T) {
+	src := filterSrc(10, 10, leftRed)
+	tests := []struct {
+		name, attrs string
+		clr         color.RGBA
+	}{
+		{"opaque", `flood-color="blue"`, fBlue},
+		{"opacity", `flood-color="blue" flood-opacity="0.5"`, color.RGBA{0, 0, 128, 128}},
+		{"color alpha", `flood-color="#00ff0080"`, color.RGBA{0, 128, 0, 128}},
+		{"color alpha and opacity", `flood-color="#00ff0080" flood-opacity="0.5"`, color.RGBA{0, 64, 0, 64}},
+		{"default", ``, color.RGBA{0, 0, 0, 255}},
+	}
+	for _, tt := range tests {
+		f := readFilter(t, userFilter+`<feFlood `+tt.attrs+`/></filter>`)
+		out := f.FilterImage(src, src.Bounds(), gi.Identity2D())
+		checkFilterPixels(t, tt.name, out, []filterPixel{{2, 2, tt.clr}, {7, 7, tt.clr}})
+	}
+}
+
+func TestFilterComposite(t *testing.T) {
+	src := filterSrc(10, 10, leftRed)
+	// SourceGraphic composited with half transparent blue
+	hblue := color.RGBA{0, 0, 128, 128}
+	tests := []struct {
+		op          string
+		left, right color.RGBA
+	}{
+		{`operator="over"`, fRed, hblue},
+		{`operator="in"`, color.RGBA{128, 0, 0, 128}, fNone},
+		{`operator="out"`, color.RGBA{127, 0, 0, 127}, fNone},
+		{`operator="atop"`, color.RGBA{128, 0, 0, 128}, hblue},
+		{`operator="xor"`, color.RGBA{127, 0, 0, 127}, hblue},
+		{`operator="arithmetic" k2="0.5" k3="0.5"`, color.RGBA{128, 0, 64, 191}, color.RGBA{0, 0, 64, 64}},
+		{`operator="arithmetic" k1="1"`, color.RGBA{0, 0, 0, 128}, fNone},
+	}
+	for _, tt := range tests {
+		f := readFilter(t, userFilter+`<feFlood flood-color="blue" flood-opacity="0.5" result="b"/>
+			<feComposite in="SourceGraphic" in2="b" `+tt.op+`/></filter>`)
+		out := f.FilterImage(src, src.Bounds(), gi.Identity2D())
+		checkFilterPixels(t, tt.op, out, []filterPixel{{2, 2, tt.left}, {7, 7, tt.right}})
+	}
+}
+
+func TestFilterColorMatrix(t *testing.T) {
+	src := filterSrc(10, 10, func(x, y int) color.RGBA {
+		if x < 5 {
+			return fRed
+		}
+		return color.RGBA{128, 0, 0, 128} // half transparent red
+	})
+	tests := []struct {
+		name, attrs string
+		left, right color.RGBA
+	}{
+		{"saturate", `type="saturate" values="0"`, color.RGBA{54, 54, 54, 255}, color.RGBA{27, 27, 27, 128}},
+		{"saturate 1", `type="saturate" values="1"`, fRed, color.RGBA{128, 0, 0, 128}},
+		{"hue rotate", `type="hueRotate" values="180"`, color.RGBA{0, 109, 109, 255}, color.RGBA{0, 55, 55, 128}},
+		{"luminance to alpha", `type="luminanceToAlpha"`, color.RGBA{0, 0, 0, 54}, color.RGBA{0, 0, 0, 54}},
+		{"matrix", `values="0 0 1 0 0  0 1 0 0 0  1 0 0 0 0  0 0 0 1 0"`, fBlue, color.RGBA{0, 0, 128, 128}},
+		{"matrix offset", `values="1 0 0 0 0  0 1 0 0 1  0 0 1 0 0  0 0 0 1 0"`, color.RGBA{255, 255, 0, 255}, color.RGBA{128, 128, 0, 128}},
+		{"bad matrix", `values="1 0 0"`, fRed, color.RGBA{128, 0, 0, 128}},
+	}
+	for _, tt := range tests {
+		f := readFilter(t, userFilter+`<feColorMatrix `+tt.attrs+`/></filter>`)
+		out := f.FilterImage(src, src.Bounds(), gi.Identity2D())
+		checkFilterPixels(t, tt.name, out, []filterPixel{{2, 2, tt.left}, {7, 7, tt.right}})
+	}
+}
+
+func TestFilterBlend(t *testing.T) {
+	src := filterSrc(10, 10, leftRed)
+	// SourceGraphic blended over opaque blue
+	tests := []struct {
+		mode string
+		left color.RGBA
+	}{
+		{"normal", fRed},
+		{"multiply", color.RGBA{0, 0, 0, 255}},
+		{"screen", color.RGBA{255, 0, 255, 255}},
+		{"darken", color.RGBA{0, 0, 0, 255}},
+		{"lighten", color.RGBA{255, 0, 255, 255}},
+	}
+	for _, tt := range tests {
+		f := readFilter(t, userFilter+`<feFlood flood-color="blue" result="b"/>
+			<feBlend in="SourceGraphic" in2="b" mode="`+tt.mode+`"/></filter>`)
+		out := f.FilterImage(src, src.Bounds(), gi.Identity2D())
+		checkFilterPixels(t, tt.mode, out, []filterPixel{{2, 2, tt.left}, {7, 7, fBlue}})
+	}
+}
+
+func TestFilterDropShadow(t *testing.T) {
+	src := filterSrc(10, 10, func(x, y int) color.RGBA {
+		if x >= 2 && x < 4 && y >= 2 && y < 4 {
+			return fWhite
+		}
+		return fNone
+	})
+	shad := color.RGBA{128, 0, 0, 128}
+	for _, attrs := range []string{`flood-color="red" flood-opacity="0.5"`, `flood-color="#ff000080"`} {
+		f := readFilter(t, userFilter+`<feDropShadow dx="3" dy="3" stdDeviation="0" `+attrs+`/></filter>`)
+		out := f.FilterImage(src, src.Bounds(), gi.Identity2D())
+		checkFilterPixels(t, attrs, out, []filterPixel{
+			{2, 2, fWhite}, {3, 3, fWhite}, {5, 5, shad}, {6, 6, shad}, {4, 4, fNone}, {7, 7, fNone}})
+	}
+
+	f := readFilter(t, userFilter+`<feDropShadow dx="0" dy="0" stdDeviation="1"/></filter>`)
+	out := f.FilterImage(src, src.Bounds(), gi.Identity2D())
+	// the 2 pixel square blurs to 21 85 149 149 85 21 in each direction
+	checkFilterPixels(t, "blurred", out, []filterPixel{
+		{2, 2, fWhite}, {1, 2, color.RGBA{0, 0, 0, 50}}, {0, 2, color.RGBA{0, 0, 0, 13}}, {1, 1, color.RGBA{0, 0, 0, 28}}})
+}
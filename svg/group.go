@@ -46,10 +46,14 @@ func (g *Group) Render2D() {
 	}
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.PushXFormLock(pc.XForm)
 
 	g.Render2DChildren()
 	g.ComputeBBoxSVG()
+	if clip {
+		g.PopClipMask()
+	}
 
 	rs.PopXFormLock()
 }
@@ -11,16 +11,22 @@
 package svg
 
 import (
+	"bufio"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/units"
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+	"github.com/srwiley/rasterx"
 	"golang.org/x/net/html/charset"
 )
 
@@ -101,10 +107,9 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 	inDef := false
 	inCSS := false
 	var curCSS *gi.StyleSheet
-	inTxt := false
-	var curTxt *Text
-	inTspn := false
-	var curTspn *Text
+	var cssStr string        // accumulated style sheet text, parsed at the end
+	var txtStack []*Text     // current text element and spans within it
+	var lastRun *Text        // last text element or span that got character data
 	var defPrevPar gi.Node2D // previous parent before a def encountered
 
 	for {
@@ -126,6 +131,7 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 		switch se := t.(type) {
 		case xml.StartElement:
 			nm := se.Name.Local
+			styleAttrLast(se.Attr)
 			switch {
 			case nm == "svg":
 				if curPar != svg.This() {
@@ -357,24 +363,23 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 						return err
 					}
 				}
-			case nm == "tspan":
-				fallthrough
-			case nm == "text":
+			case nm == "text" || nm == "tspan" || nm == "textPath":
+				var par ki.Ki = curPar
+				if len(txtStack) > 0 {
+					par = txtStack[len(txtStack)-1].This()
+				}
 				var txt *Text
-				if se.Name.Local == "text" {
-					txt = curPar.AddNewChild(KiT_Text, "txt").(*Text)
-					inTxt = true
-					curTxt = txt
-				} else {
-					if inTxt && curTxt != nil {
-						txt = curTxt.AddNewChild(KiT_Text, "tspan").(*Text)
-						txt.Pos = curTxt.Pos
-					} else {
-						txt = curPar.AddNewChild(KiT_Text, "tspan").(*Text)
-					}
-					inTspn = true
-					curTspn = txt
+				var tpath *TextPath
+				switch nm {
+				case "text":
+					txt = par.AddNewChild(KiT_Text, "txt").(*Text)
+				case "tspan":
+					txt = par.AddNewChild(KiT_Text, "tspan").(*Text)
+				default:
+					tpath = par.AddNewChild(KiT_TextPath, "textPath").(*TextPath)
+					txt = &tpath.Text
 				}
+				txtStack = append(txtStack, txt)
 				for _, attr := range se.Attr {
 					if txt.SetStdXMLAttr(attr.Name.Local, attr.Value) {
 						continue
@@ -382,18 +387,31 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 					switch attr.Name.Local {
 					case "x":
 						pts := gi.ReadPoints(attr.Value)
-						if len(pts) > 1 {
+						if len(pts) > 1 || (len(pts) == 1 && txt.IsSpan()) {
 							txt.CharPosX = pts
 						} else if len(pts) == 1 {
 							txt.Pos.X = pts[0]
 						}
 					case "y":
 						pts := gi.ReadPoints(attr.Value)
-						if len(pts) > 1 {
+						if len(pts) > 1 || (len(pts) == 1 && txt.IsSpan()) {
 							txt.CharPosY = pts
 						} else if len(pts) == 1 {
 							txt.Pos.Y = pts[0]
 						}
+					case "href":
+						if tpath != nil {
+							tpath.Href = attr.Value
+						} else {
+							txt.SetProp(attr.Name.Local, attr.Value)
+						}
+					case "startOffset":
+						if tpath != nil {
+							tpath.StartPct = strings.HasSuffix(attr.Value, "%")
+							tpath.StartOffset, err = parseClipLen(attr.Value)
+						} else {
+							txt.SetProp(attr.Name.Local, attr.Value)
+						}
 					case "dx":
 						pts := gi.ReadPoints(attr.Value)
 						if len(pts) > 0 {
@@ -410,8 +428,9 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 							txt.CharRots = pts
 						}
 					case "textLength":
-						tl, err := gi.ParseFloat32(attr.Value)
-						if err != nil {
+						var tl float32
+						tl, err = gi.ParseFloat32(attr.Value)
+						if err == nil {
 							txt.TextLength = tl
 						}
 					case "lengthAdjust":
@@ -486,7 +505,9 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 				}
 				inCSS = true
 				curCSS = sty
-				// style code shows up in CharData below
+				cssStr = ""
+				// style code shows up in CharData below, possibly in several
+				// pieces (e.g., CDATA), so it is parsed at the end element
 			case nm == "clipPath":
 				curPar = curPar.AddNewChild(KiT_ClipPath, "clip-path").(gi.Node2D)
 				cp := curPar.(*ClipPath)
@@ -495,10 +516,40 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 						continue
 					}
 					switch attr.Name.Local {
+					case "clipPathUnits":
+						cp.Units = ParseClipUnits(attr.Value, ClipUserSpace)
 					default:
 						cp.SetProp(attr.Name.Local, attr.Value)
 					}
 				}
+			case nm == "mask":
+				curPar = curPar.AddNewChild(KiT_Mask, "mask").(gi.Node2D)
+				mk := curPar.(*Mask)
+				mk.Defaults()
+				for _, attr := range se.Attr {
+					if mk.SetStdXMLAttr(attr.Name.Local, attr.Value) {
+						continue
+					}
+					switch attr.Name.Local {
+					case "x":
+						mk.Pos.X, err = parseClipLen(attr.Value)
+					case "y":
+						mk.Pos.Y, err = parseClipLen(attr.Value)
+					case "width":
+						mk.Size.X, err = parseClipLen(attr.Value)
+					case "height":
+						mk.Size.Y, err = parseClipLen(attr.Value)
+					case "maskUnits":
+						mk.Units = ParseClipUnits(attr.Value, ClipObjectBBox)
+					case "maskContentUnits":
+						mk.ContentUnits = ParseClipUnits(attr.Value, ClipUserSpace)
+					default:
+						mk.SetProp(attr.Name.Local, attr.Value)
+					}
+					if err != nil {
+						return err
+					}
+				}
 			case nm == "marker":
 				curPar = curPar.AddNewChild(KiT_Marker, "marker").(gi.Node2D)
 				mrk := curPar.(*Marker)
@@ -518,7 +569,7 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 						szx, err = gi.ParseFloat32(attr.Value)
 					case "markerHeight":
 						szy, err = gi.ParseFloat32(attr.Value)
-					case "matrixUnits":
+					case "markerUnits", "matrixUnits":
 						if attr.Value == "strokeWidth" {
 							mrk.Units = StrokeWidth
 						} else {
@@ -545,21 +596,87 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 				mrk.RefPos.Set(rx, ry)
 				mrk.Size.Set(szx, szy)
 			case nm == "use":
-				link := gi.XMLAttr("href", se.Attr)
-				itm := curPar.FindNamedElement(link)
-				if itm != nil {
-					cln := itm.Clone().(gi.Node2D)
-					if cln != nil {
-						curPar.AddChild(cln)
-						for _, attr := range se.Attr {
-							if cln.AsNode2D().SetStdXMLAttr(attr.Name.Local, attr.Value) {
-								continue
-							}
-							switch attr.Name.Local {
-							default:
-								cln.SetProp(attr.Name.Local, attr.Value)
-							}
-						}
+				curPar = curPar.AddNewChild(KiT_Use, "use").(gi.Node2D)
+				us := curPar.(*Use)
+				for _, attr := range se.Attr {
+					if us.SetStdXMLAttr(attr.Name.Local, attr.Value) {
+						continue
+					}
+					switch attr.Name.Local {
+					case "href": // also xlink:href
+						us.Href = attr.Value
+					case "x":
+						us.Pos.X, err = gi.ParseFloat32(attr.Value)
+					case "y":
+						us.Pos.Y, err = gi.ParseFloat32(attr.Value)
+					case "width":
+						us.Size.X, err = gi.ParseFloat32(attr.Value)
+					case "height":
+						us.Size.Y, err = gi.ParseFloat32(attr.Value)
+					default:
+						us.SetProp(attr.Name.Local, attr.Value)
+					}
+					if err != nil {
+						return err
+					}
+				}
+			case nm == "symbol":
+				curPar = curPar.AddNewChild(KiT_Symbol, "symbol").(gi.Node2D)
+				sy := curPar.(*Symbol)
+				sy.ViewBox.PreserveAspectRatio.SetString("")
+				for _, attr := range se.Attr {
+					if sy.SetStdXMLAttr(attr.Name.Local, attr.Value) {
+						continue
+					}
+					switch attr.Name.Local {
+					case "viewBox":
+						err = readViewBox(&sy.ViewBox, attr.Value)
+					case "preserveAspectRatio":
+						sy.ViewBox.PreserveAspectRatio.SetString(attr.Value)
+					case "width":
+						sy.Size.X, err = gi.ParseFloat32(attr.Value)
+					case "height":
+						sy.Size.Y, err = gi.ParseFloat32(attr.Value)
+					default:
+						sy.SetProp(attr.Name.Local, attr.Value)
+					}
+					if err != nil {
+						return err
+					}
+				}
+			case nm == "pattern":
+				curPar = curPar.AddNewChild(KiT_Pattern, "pattern").(gi.Node2D)
+				pt := curPar.(*Pattern)
+				pt.Defaults()
+				pt.ViewBox.PreserveAspectRatio.SetString("")
+				for _, attr := range se.Attr {
+					if pt.SetStdXMLAttr(attr.Name.Local, attr.Value) {
+						continue
+					}
+					switch attr.Name.Local {
+					case "x":
+						pt.Pos.X, err = parseClipLen(attr.Value)
+					case "y":
+						pt.Pos.Y, err = parseClipLen(attr.Value)
+					case "width":
+						pt.Size.X, err = parseClipLen(attr.Value)
+					case "height":
+						pt.Size.Y, err = parseClipLen(attr.Value)
+					case "patternUnits":
+						pt.Units = ParseClipUnits(attr.Value, ClipObjectBBox)
+					case "patternContentUnits":
+						pt.ContentUnits = ParseClipUnits(attr.Value, ClipUserSpace)
+					case "patternTransform":
+						pt.PatXForm.SetString(attr.Value)
+					case "viewBox":
+						err = readViewBox(&pt.ViewBox, attr.Value)
+					case "preserveAspectRatio":
+						pt.ViewBox.PreserveAspectRatio.SetString(attr.Value)
+					default:
+						pt.SetProp(attr.Name.Local, attr.Value)
+					}
+					if err != nil {
+						return err
 					}
 				}
 			case nm == "Work":
@@ -635,14 +752,24 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 			case "desc":
 				inDesc = false
 			case "style":
+				if curCSS != nil && curCSS.ParseString(cssStr) == nil {
+					// style sheets apply to the whole svg, wherever they are
+					if cp := curCSS.CSSProps(); cp != nil {
+						gi.AggCSS(&curSvg.CSS, cp)
+					}
+				}
 				inCSS = false
 				curCSS = nil
-			case "text":
-				inTxt = false
-				curTxt = nil
-			case "tspan":
-				inTspn = false
-				curTspn = nil
+			case "text", "tspan", "textPath":
+				if len(txtStack) > 0 {
+					txtStack = txtStack[:len(txtStack)-1]
+				}
+				if len(txtStack) == 0 {
+					if lastRun != nil { // trailing space is not rendered
+						lastRun.Text = strings.TrimRight(lastRun.Text, " ")
+					}
+					lastRun = nil
+				}
 			case "defs":
 				if inDef {
 					inDef = false
@@ -655,7 +782,6 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 			case "polygon":
 			case "polyline":
 			case "path":
-			case "use":
 			case "linearGradient":
 			case "radialGradient":
 			default:
@@ -675,8 +801,31 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 				}
 			}
 		case xml.CharData:
+			if len(txtStack) > 0 {
+				str := collapseTextSpace(string(se))
+				if lastRun == nil || strings.HasSuffix(lastRun.Text, " ") {
+					str = strings.TrimLeft(str, " ")
+				}
+				if str == "" {
+					continue
+				}
+				if str == " " { // space between spans
+					lastRun.Text += str
+					continue
+				}
+				txt := txtStack[len(txtStack)-1]
+				if txt.HasChildren() { // text following a span goes in its own span
+					txt = txt.AddNewChild(KiT_Text, "tspan").(*Text)
+				}
+				txt.Text += str
+				lastRun = txt
+				continue
+			}
 			// (ok, md := curPar.(*MetaData2D); ok)
 			trspc := strings.TrimSpace(string(se))
+			if trspc == "" { // e.g., indentation between elements
+				continue
+			}
 			switch {
 			// case :
 			// 	md.MetaData = string(se)
@@ -684,22 +833,616 @@ func (svg *SVG) UnmarshalXML(decoder *xml.Decoder, se xml.StartElement) error {
 				curSvg.Title += trspc
 			case inDesc:
 				curSvg.Desc += trspc
-			case inTspn && curTspn != nil:
-				curTspn.Text = trspc
-			case inTxt && curTxt != nil:
-				curTxt.Text = trspc
 			case inCSS && curCSS != nil:
-				curCSS.ParseString(trspc)
-				cp := curCSS.CSSProps()
-				if cp != nil {
-					if inDef && defPrevPar != nil {
-						defPrevPar.AsNode2D().CSS = cp
-					} else {
-						curPar.AsNode2D().CSS = cp
-					}
-				}
+				cssStr += string(se)
 			}
 		}
 	}
 	return nil
 }
+
+// readViewBox sets the viewbox from the svg viewBox attribute value
+func readViewBox(vb *ViewBox, val string) error {
+	pts := gi.ReadPoints(val)
+	if len(pts) != 4 {
+		return paramMismatchError
+	}
+	vb.Min.Set(pts[0], pts[1])
+	vb.Size.Set(pts[2], pts[3])
+	return nil
+}
+
+// parseClipLen parses a mask region coordinate, where percentages are
+// fractions of the bounding box
+func parseClipLen(s string) (float32, error) {
+	if strings.HasSuffix(s, "%") {
+		f, err := gi.ParseFloat32(strings.TrimSuffix(s, "%"))
+		return f / 100, err
+	}
+	return gi.ParseFloat32(s)
+}
+
+// styleAttrLast moves any style attribute to the end of the attributes, so
+// that the inline style properties it sets override the presentation
+// attributes of the same name
+func styleAttrLast(attrs []xml.Attr) {
+	for i, attr := range attrs {
+		if attr.Name.Local == "style" {
+			copy(attrs[i:], attrs[i+1:])
+			attrs[len(attrs)-1] = attr
+			return
+		}
+	}
+}
+
+// collapseTextSpace applies the default svg white space handling to the
+// character data of text: newlines are removed, tabs become spaces, and runs
+// of spaces are collapsed into one
+func collapseTextSpace(s string) string {
+	s = strings.NewReplacer("\n", "", "\r", "", "\t", " ").Replace(s)
+	var sb strings.Builder
+	sp := false
+	for _, r := range s {
+		if r == ' ' && sp {
+			continue
+		}
+		sp = r == ' '
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+////////////////////////////////////////////////////////////////////////////////////////
+//  Writing
+
+// SaveXML saves the svg to a XML-encoded file, using WriteXML
+func (svg *SVG) SaveXML(filename string) error {
+	fp, err := os.Create(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer fp.Close()
+	bw := bufio.NewWriter(fp)
+	err = svg.WriteXML(bw, true)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteXML writes XML-formatted SVG output to io.Writer, with an xml header,
+// and indented if indent is true -- ReadXML reads it back into the same SVG
+// scenegraph.  The contents of text elements are never indented, as the
+// whitespace would add to the text.
+func (svg *SVG) WriteXML(writer io.Writer, indent bool) error {
+	enc := xml.NewEncoder(writer)
+	ind := ""
+	if indent {
+		ind = "  "
+		enc.Indent("", ind)
+	}
+	err := enc.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)})
+	if err != nil {
+		return err
+	}
+	if indent {
+		enc.EncodeToken(xml.CharData("\n"))
+	}
+	err = svg.marshalXML(enc, true, ind)
+	if err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// MarshalXML marshals the svg using xml.Encoder, as the top-level svg
+// element -- the inverse of UnmarshalXML.  The given start element is
+// ignored.  Use WriteXML for indented output, which keeps the indentation
+// out of text elements.
+func (svg *SVG) MarshalXML(enc *xml.Encoder, se xml.StartElement) error {
+	return svg.marshalXML(enc, true, "")
+}
+
+// marshalXML marshals the svg, which is the top-level one if top -- indent
+// is the indentation the encoder was set to, if any
+func (svg *SVG) marshalXML(enc *xml.Encoder, top bool, indent string) error {
+	se := xmlStart("svg", svg.AsNode2D(), "svg", true)
+	if vb := svg.ViewBox; vb.Size != gi.Vec2DZero {
+		xmlAddAttr(&se, "width", xmlFloat(vb.Size.X))
+		xmlAddAttr(&se, "height", xmlFloat(vb.Size.Y))
+		xmlAddAttr(&se, "viewBox", xmlFloats([]float32{vb.Min.X, vb.Min.Y, vb.Size.X, vb.Size.Y}))
+	}
+	props := svg.Props
+	if top { // with the defaults, in the same order as when read back
+		props = make(ki.Props, len(svg.Props)+2)
+		props["xmlns"] = "http://www.w3.org/2000/svg"
+		props["version"] = "1.1"
+		for key, val := range svg.Props {
+			props[key] = val
+		}
+	}
+	xmlAddProps(&se, props, nil)
+	if err := enc.EncodeToken(se); err != nil {
+		return err
+	}
+	if svg.Title != "" {
+		if err := xmlTextElement(enc, "title", svg.Title); err != nil {
+			return err
+		}
+	}
+	if svg.Desc != "" {
+		if err := xmlTextElement(enc, "desc", svg.Desc); err != nil {
+			return err
+		}
+	}
+	if svg.Defs.HasChildren() {
+		ds := xml.StartElement{Name: xml.Name{Local: "defs"}}
+		if err := enc.EncodeToken(ds); err != nil {
+			return err
+		}
+		for _, kid := range svg.Defs.Kids {
+			if err := marshalXMLNode(enc, kid, indent); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(ds.End()); err != nil {
+			return err
+		}
+	}
+	for _, kid := range svg.Kids {
+		if err := marshalXMLNode(enc, kid, indent); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(se.End())
+}
+
+// MarshalXMLNode marshals given node of an svg scenegraph, and its children,
+// using xml.Encoder, as the corresponding svg element -- node types that
+// have no svg element are skipped, with a log message
+func MarshalXMLNode(enc *xml.Encoder, k ki.Ki) error {
+	return marshalXMLNode(enc, k, "")
+}
+
+// marshalXMLNode is MarshalXMLNode where indent is the indentation the
+// encoder was set to, if any, which is turned off within text elements
+func marshalXMLNode(enc *xml.Encoder, k ki.Ki, indent string) error {
+	var se xml.StartElement
+	var txt string
+	noIndent := false
+	switch nd := k.(type) {
+	case *SVG:
+		return nd.marshalXML(enc, false, indent)
+	case *gi.Gradient:
+		return marshalXMLGradient(enc, nd)
+	case *Group:
+		se = xmlStart("g", nd.AsNode2D(), "g", true)
+	case *Rect:
+		se = xmlStart("rect", nd.AsNode2D(), "rect", true)
+		xmlAddAttr(&se, "x", xmlFloat(nd.Pos.X))
+		xmlAddAttr(&se, "y", xmlFloat(nd.Pos.Y))
+		xmlAddAttr(&se, "width", xmlFloat(nd.Size.X))
+		xmlAddAttr(&se, "height", xmlFloat(nd.Size.Y))
+		if nd.Radius != gi.Vec2DZero {
+			xmlAddAttr(&se, "rx", xmlFloat(nd.Radius.X))
+			xmlAddAttr(&se, "ry", xmlFloat(nd.Radius.Y))
+		}
+	case *Circle:
+		se = xmlStart("circle", nd.AsNode2D(), "circle", true)
+		xmlAddAttr(&se, "cx", xmlFloat(nd.Pos.X))
+		xmlAddAttr(&se, "cy", xmlFloat(nd.Pos.Y))
+		xmlAddAttr(&se, "r", xmlFloat(nd.Radius))
+	case *Ellipse:
+		se = xmlStart("ellipse", nd.AsNode2D(), "ellipse", true)
+		xmlAddAttr(&se, "cx", xmlFloat(nd.Pos.X))
+		xmlAddAttr(&se, "cy", xmlFloat(nd.Pos.Y))
+		xmlAddAttr(&se, "rx", xmlFloat(nd.Radii.X))
+		xmlAddAttr(&se, "ry", xmlFloat(nd.Radii.Y))
+	case *Line:
+		se = xmlStart("line", nd.AsNode2D(), "line", true)
+		xmlAddAttr(&se, "x1", xmlFloat(nd.Start.X))
+		xmlAddAttr(&se, "y1", xmlFloat(nd.Start.Y))
+		xmlAddAttr(&se, "x2", xmlFloat(nd.End.X))
+		xmlAddAttr(&se, "y2", xmlFloat(nd.End.Y))
+	case *Polygon:
+		se = xmlStart("polygon", nd.AsNode2D(), "polygon", true)
+		xmlAddAttr(&se, "points", xmlPoints(nd.Points))
+	case *Polyline:
+		se = xmlStart("polyline", nd.AsNode2D(), "polyline", true)
+		xmlAddAttr(&se, "points", xmlPoints(nd.Points))
+	case *Path:
+		se = xmlStart("path", nd.AsNode2D(), "path", true)
+		if len(nd.Data) > 0 {
+			xmlAddAttr(&se, "d", PathDataString(nd.Data))
+		} else {
+			xmlAddAttr(&se, "d", nd.DataStr)
+		}
+	case *TextPath:
+		se = xmlStart("textPath", nd.AsNode2D(), "textPath", true)
+		xmlAddAttr(&se, "href", nd.Href)
+		if nd.StartPct {
+			xmlAddAttr(&se, "startOffset", xmlFloat(nd.StartOffset*100)+"%")
+		} else if nd.StartOffset != 0 {
+			xmlAddAttr(&se, "startOffset", xmlFloat(nd.StartOffset))
+		}
+		xmlAddTextPos(&se, &nd.Text)
+		txt = nd.Text.Text
+	case *Text:
+		if nd.IsSpan() {
+			se = xmlStart("tspan", nd.AsNode2D(), "tspan", true)
+		} else {
+			se = xmlStart("text", nd.AsNode2D(), "txt", true)
+			noIndent = indent != ""
+		}
+		xmlAddTextPos(&se, nd)
+		txt = nd.Text
+	case *gi.StyleSheet:
+		se = xmlStart("style", nd.AsNode2D(), "style", true)
+		if nd.Sheet != nil {
+			txt = nd.Sheet.String()
+		}
+	case *Use:
+		se = xmlStart("use", nd.AsNode2D(), "use", true)
+		xmlAddAttr(&se, "href", nd.Href)
+		if nd.Pos != gi.Vec2DZero {
+			xmlAddAttr(&se, "x", xmlFloat(nd.Pos.X))
+			xmlAddAttr(&se, "y", xmlFloat(nd.Pos.Y))
+		}
+		if nd.Size != gi.Vec2DZero {
+			xmlAddAttr(&se, "width", xmlFloat(nd.Size.X))
+			xmlAddAttr(&se, "height", xmlFloat(nd.Size.Y))
+		}
+	case *Symbol:
+		se = xmlStart("symbol", nd.AsNode2D(), "symbol", true)
+		xmlAddViewBox(&se, &nd.ViewBox)
+		if nd.Size != gi.Vec2DZero {
+			xmlAddAttr(&se, "width", xmlFloat(nd.Size.X))
+			xmlAddAttr(&se, "height", xmlFloat(nd.Size.Y))
+		}
+	case *Pattern:
+		se = xmlStart("pattern", nd.AsNode2D(), "pattern", true)
+		xmlAddAttr(&se, "x", xmlFloat(nd.Pos.X))
+		xmlAddAttr(&se, "y", xmlFloat(nd.Pos.Y))
+		xmlAddAttr(&se, "width", xmlFloat(nd.Size.X))
+		xmlAddAttr(&se, "height", xmlFloat(nd.Size.Y))
+		xmlAddAttr(&se, "patternUnits", nd.Units.SVGString())
+		xmlAddAttr(&se, "patternContentUnits", nd.ContentUnits.SVGString())
+		if m := nd.PatXForm; m != (gi.Matrix2D{}) && m != gi.Identity2D() {
+			xmlAddAttr(&se, "patternTransform", xmlPropString(m))
+		}
+		xmlAddViewBox(&se, &nd.ViewBox)
+	case *ClipPath:
+		se = xmlStart("clipPath", nd.AsNode2D(), "clip-path", true)
+		if nd.Units != ClipUserSpace {
+			xmlAddAttr(&se, "clipPathUnits", nd.Units.SVGString())
+		}
+	case *Mask:
+		se = xmlStart("mask", nd.AsNode2D(), "mask", true)
+		xmlAddAttr(&se, "x", xmlFloat(nd.Pos.X))
+		xmlAddAttr(&se, "y", xmlFloat(nd.Pos.Y))
+		xmlAddAttr(&se, "width", xmlFloat(nd.Size.X))
+		xmlAddAttr(&se, "height", xmlFloat(nd.Size.Y))
+		xmlAddAttr(&se, "maskUnits", nd.Units.SVGString())
+		xmlAddAttr(&se, "maskContentUnits", nd.ContentUnits.SVGString())
+	case *Marker:
+		se = xmlStart("marker", nd.AsNode2D(), "marker", true)
+		xmlAddAttr(&se, "refX", xmlFloat(nd.RefPos.X))
+		xmlAddAttr(&se, "refY", xmlFloat(nd.RefPos.Y))
+		xmlAddAttr(&se, "markerWidth", xmlFloat(nd.Size.X))
+		xmlAddAttr(&se, "markerHeight", xmlFloat(nd.Size.Y))
+		if nd.Units == UserSpaceOnUse {
+			xmlAddAttr(&se, "markerUnits", "userSpaceOnUse")
+		} else {
+			xmlAddAttr(&se, "markerUnits", "strokeWidth")
+		}
+		if vb := nd.ViewBox; vb.Size != gi.Vec2DZero {
+			xmlAddAttr(&se, "viewBox", xmlFloats([]float32{vb.Min.X, vb.Min.Y, vb.Size.X, vb.Size.Y}))
+		}
+		if nd.Orient != "" {
+			xmlAddAttr(&se, "orient", nd.Orient)
+		}
+	case *Flow:
+		se = xmlStart(nd.FlowType, nd.AsNode2D(), nd.FlowType, nd.Class != nd.FlowType)
+	case *Filter:
+		se = xmlStart(nd.FilterType, nd.AsNode2D(), nd.FilterType, nd.Class != nd.FilterType)
+	case *gi.MetaData2D:
+		se = xmlStart(nd.Class, nd.AsNode2D(), nd.Class, false)
+	default:
+		log.Printf("svg.MarshalXMLNode: no svg element for node: %v of type: %v\n", k.PathUnique(), k.Type().Name())
+		return nil
+	}
+	var inline []string
+	if sn, ok := k.(NodeSVG); ok {
+		inline = sn.AsSVGNode().InlineStyle
+	}
+	xmlAddProps(&se, *k.Properties(), inline)
+	if err := enc.EncodeToken(se); err != nil {
+		return err
+	}
+	kidIndent := indent
+	if noIndent { // whitespace within text is part of the text
+		enc.Indent("", "")
+		kidIndent = ""
+	}
+	if txt != "" {
+		if err := enc.EncodeToken(xml.CharData(txt)); err != nil {
+			return err
+		}
+	}
+	for _, kid := range *k.Children() {
+		if err := marshalXMLNode(enc, kid, kidIndent); err != nil {
+			return err
+		}
+	}
+	if noIndent {
+		enc.Indent("", indent)
+	}
+	return enc.EncodeToken(se.End())
+}
+
+// marshalXMLGradient marshals the gradient as a linearGradient or
+// radialGradient element, with its stops
+func marshalXMLGradient(enc *xml.Encoder, gr *gi.Gradient) error {
+	g := gr.Grad.Gradient
+	if g == nil {
+		return nil
+	}
+	var se xml.StartElement
+	if g.IsRadial {
+		se = xmlStart("radialGradient", gr.AsNode2D(), "rad-grad", true)
+		xmlAddAttr(&se, "cx", xmlFloat64(g.Points[0]))
+		xmlAddAttr(&se, "cy", xmlFloat64(g.Points[1]))
+		xmlAddAttr(&se, "fx", xmlFloat64(g.Points[2]))
+		xmlAddAttr(&se, "fy", xmlFloat64(g.Points[3]))
+		xmlAddAttr(&se, "r", xmlFloat64(g.Points[4]))
+	} else {
+		se = xmlStart("linearGradient", gr.AsNode2D(), "lin-grad", true)
+		xmlAddAttr(&se, "x1", xmlFloat64(g.Points[0]))
+		xmlAddAttr(&se, "y1", xmlFloat64(g.Points[1]))
+		xmlAddAttr(&se, "x2", xmlFloat64(g.Points[2]))
+		xmlAddAttr(&se, "y2", xmlFloat64(g.Points[3]))
+	}
+	if g.Units == rasterx.UserSpaceOnUse {
+		xmlAddAttr(&se, "gradientUnits", "userSpaceOnUse")
+	} else {
+		xmlAddAttr(&se, "gradientUnits", "objectBoundingBox")
+	}
+	switch g.Spread {
+	case rasterx.ReflectSpread:
+		xmlAddAttr(&se, "spreadMethod", "reflect")
+	case rasterx.RepeatSpread:
+		xmlAddAttr(&se, "spreadMethod", "repeat")
+	}
+	if m := g.Matrix; m != rasterx.Identity {
+		xmlAddAttr(&se, "gradientTransform", fmt.Sprintf("matrix(%v,%v,%v,%v,%v,%v)", xmlFloat64(m.A), xmlFloat64(m.B),
+			xmlFloat64(m.C), xmlFloat64(m.D), xmlFloat64(m.E), xmlFloat64(m.F)))
+	}
+	if err := enc.EncodeToken(se); err != nil {
+		return err
+	}
+	for _, st := range g.Stops {
+		ss := xml.StartElement{Name: xml.Name{Local: "stop"}}
+		xmlAddAttr(&ss, "offset", xmlFloat64(st.Offset))
+		var clr gi.Color
+		if st.StopColor != nil {
+			clr.SetColor(st.StopColor)
+		}
+		clr.A = 255 // opacity is separate
+		xmlAddAttr(&ss, "stop-color", xmlColor(clr))
+		if st.Opacity != 1 {
+			xmlAddAttr(&ss, "stop-opacity", xmlFloat64(st.Opacity))
+		}
+		if err := enc.EncodeToken(ss); err != nil {
+			return err
+		}
+		if err := enc.EncodeToken(ss.End()); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(se.End())
+}
+
+// xmlStart returns the start element of given name for given node, with its
+// name as the id, unless it is the default name dflt that the node gets when
+// read without an id, and its class if cls
+func xmlStart(nm string, nb *gi.Node2DBase, dflt string, cls bool) xml.StartElement {
+	se := xml.StartElement{Name: xml.Name{Local: nm}}
+	if nb.Nm != "" && nb.Nm != dflt {
+		xmlAddAttr(&se, "id", nb.Nm)
+	}
+	if cls && nb.Class != "" {
+		xmlAddAttr(&se, "class", nb.Class)
+	}
+	return se
+}
+
+// xmlAddAttr adds given attribute to start element
+func xmlAddAttr(se *xml.StartElement, nm, val string) {
+	se.Attr = append(se.Attr, xml.Attr{Name: xml.Name{Local: nm}, Value: val})
+}
+
+// xmlAddViewBox adds the viewBox and preserveAspectRatio attributes, if the
+// viewbox is set
+func xmlAddViewBox(se *xml.StartElement, vb *ViewBox) {
+	if vb.Size == gi.Vec2DZero {
+		return
+	}
+	xmlAddAttr(se, "viewBox", xmlFloats([]float32{vb.Min.X, vb.Min.Y, vb.Size.X, vb.Size.Y}))
+	xmlAddAttr(se, "preserveAspectRatio", vb.PreserveAspectRatio.SVGString())
+}
+
+// xmlAddTextPos adds the position attributes of a text element or span --
+// the single x, y Pos is only used for text, not spans
+func xmlAddTextPos(se *xml.StartElement, nd *Text) {
+	switch {
+	case len(nd.CharPosX) > 1 || nd.IsSpan():
+		if len(nd.CharPosX) > 0 {
+			xmlAddAttr(se, "x", xmlFloats(nd.CharPosX))
+		}
+	default:
+		xmlAddAttr(se, "x", xmlFloat(nd.Pos.X))
+	}
+	switch {
+	case len(nd.CharPosY) > 1 || nd.IsSpan():
+		if len(nd.CharPosY) > 0 {
+			xmlAddAttr(se, "y", xmlFloats(nd.CharPosY))
+		}
+	default:
+		xmlAddAttr(se, "y", xmlFloat(nd.Pos.Y))
+	}
+	if len(nd.CharPosDX) > 0 {
+		xmlAddAttr(se, "dx", xmlFloats(nd.CharPosDX))
+	}
+	if len(nd.CharPosDY) > 0 {
+		xmlAddAttr(se, "dy", xmlFloats(nd.CharPosDY))
+	}
+	if len(nd.CharRots) > 0 {
+		xmlAddAttr(se, "rotate", xmlFloats(nd.CharRots))
+	}
+	if nd.TextLength != 0 {
+		xmlAddAttr(se, "textLength", xmlFloat(nd.TextLength))
+		if nd.AdjustGlyphs {
+			xmlAddAttr(se, "lengthAdjust", "spacingAndGlyphs")
+		}
+	}
+}
+
+// xmlHasAttr returns true if start element has given attribute
+func xmlHasAttr(se *xml.StartElement, nm string) bool {
+	for _, attr := range se.Attr {
+		if attr.Name.Local == nm {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlAddProps adds the properties of a node to its start element, sorted by
+// name, as the reader sets them from attributes -- style properties are
+// valid svg presentation attributes, except for vendor-specific ones
+// starting with -, which go in a style attribute, along with the inline
+// style properties, which must keep their precedence over css.
+// Sub-properties, e.g., for CSS selectors, and properties that are already
+// attributes are skipped.
+func xmlAddProps(se *xml.StartElement, props ki.Props, inline []string) {
+	keys := make([]string, 0, len(props))
+	for key, val := range props {
+		if _, ok := val.(ki.Props); ok || key == "" || xmlHasAttr(se, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sty []string
+	for _, key := range keys {
+		vs := xmlPropString(props[key])
+		if strings.HasPrefix(key, "-") || xmlInInline(key, inline) {
+			sty = append(sty, key+":"+vs)
+			continue
+		}
+		xmlAddAttr(se, key, vs)
+	}
+	if len(sty) > 0 {
+		xmlAddAttr(se, "style", strings.Join(sty, ";"))
+	}
+}
+
+// xmlInInline returns true if key is among the inline style properties
+func xmlInInline(key string, inline []string) bool {
+	for _, k := range inline {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlPropString returns the svg string for given property value, which is
+// a string when read, but can be any type when set in code
+func xmlPropString(val interface{}) string {
+	switch vv := val.(type) {
+	case string:
+		return vv
+	case gi.Color:
+		return xmlColor(vv)
+	case *gi.Color:
+		return xmlColor(*vv)
+	case units.Value:
+		return xmlUnits(vv)
+	case *units.Value:
+		return xmlUnits(*vv)
+	case gi.Matrix2D:
+		return fmt.Sprintf("matrix(%v,%v,%v,%v,%v,%v)", xmlFloat(vv.XX), xmlFloat(vv.YX), xmlFloat(vv.XY), xmlFloat(vv.YY), xmlFloat(vv.X0), xmlFloat(vv.Y0))
+	case float32:
+		return xmlFloat(vv)
+	case float64:
+		return xmlFloat64(vv)
+	case gi.Node2D: // e.g., a Marker, ClipPath or Mask
+		return "url(#" + vv.Name() + ")"
+	}
+	return kit.ToString(val)
+}
+
+// xmlColor returns the svg string for given color: #rrggbb, with aa alpha if
+// not opaque, or none if nil
+func xmlColor(c gi.Color) string {
+	switch {
+	case c.IsNil():
+		return "none"
+	case c.A == 255:
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// xmlUnits returns the svg string for given units value
+func xmlUnits(v units.Value) string {
+	if v.Un == units.Pct {
+		return xmlFloat(v.Val) + "%"
+	}
+	return xmlFloat(v.Val) + units.UnitNames[v.Un]
+}
+
+// xmlFloat returns the shortest string that parses back to the same
+// float32, without exponent
+func xmlFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+// xmlFloat64 returns the shortest string that parses back to the same
+// float64, without exponent
+func xmlFloat64(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// xmlFloats returns the numbers separated by spaces
+func xmlFloats(fs []float32) string {
+	ss := make([]string, len(fs))
+	for i, f := range fs {
+		ss[i] = xmlFloat(f)
+	}
+	return strings.Join(ss, " ")
+}
+
+// xmlPoints returns the points as x,y pairs separated by spaces
+func xmlPoints(pts []gi.Vec2D) string {
+	ss := make([]string, len(pts))
+	for i, p := range pts {
+		ss[i] = xmlFloat(p.X) + "," + xmlFloat(p.Y)
+	}
+	return strings.Join(ss, " ")
+}
+
+// xmlTextElement encodes an element of given name with text content
+func xmlTextElement(enc *xml.Encoder, nm, txt string) error {
+	se := xml.StartElement{Name: xml.Name{Local: nm}}
+	if err := enc.EncodeToken(se); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(xml.CharData(txt)); err != nil {
+		return err
+	}
+	return enc.EncodeToken(se.End())
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// roundTripSVG has each kind of element and attribute that must survive
+// being written and read back
+const roundTripSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 200 100">
+  <title>Round trip</title>
+  <desc>Elements that must survive writing and reading</desc>
+  <style>.warn { fill: #f00; stroke-width: 2 } #big { opacity: 0.5 }</style>
+  <defs>
+    <linearGradient id="lin" x1="0" y1="0" x2="1" y2="0" spreadMethod="reflect">
+      <stop offset="0" stop-color="#ff0000"/>
+      <stop offset="1" stop-color="#0000ff" stop-opacity="0.5"/>
+    </linearGradient>
+    <radialGradient id="rad" cx="50" cy="40" r="30" fx="45" fy="35" gradientUnits="userSpaceOnUse" gradientTransform="matrix(1,0,0,2,10,20)">
+      <stop offset="0" stop-color="#ffffff"/>
+      <stop offset="0.5" stop-color="#ffff00"/>
+      <stop offset="1" stop-color="#008000"/>
+    </radialGradient>
+    <marker id="arrow" refX="5" refY="2.5" markerWidth="10" markerHeight="5" orient="auto" viewBox="0 0 10 5">
+      <path d="M 0 0 L 10 2.5 L 0 5 z"/>
+    </marker>
+  </defs>
+  <g id="grp" transform="translate(10,20) rotate(30) scale(2)" class="warn">
+    <rect id="big" x="1" y="2" width="30" height="40" rx="3" ry="4" fill="url(#lin)"/>
+    <circle cx="50" cy="50" r="10.5" fill="url(#rad)" style="stroke:#000;stroke-width:1.5"/>
+    <path id="arc" d="M 10 10 C 20 0 30 0 40 10 S 60 20 70 10 Q 80 0 90 10 T 110 10 A 5 5 0 0 1 120 10 Z" stroke="#000" marker-end="url(#arrow)"/>
+  </g>
+  <text id="caption" x="10" y="90" font-size="12">Hello <tspan id="bold" font-weight="bold">bold</tspan> world</text>
+</svg>
+`
+
+// readSVG reads the svg from given text
+func readSVG(t *testing.T, txt []byte) *SVG {
+	t.Helper()
+	sv := &SVG{}
+	sv.InitName(sv, "svg")
+	if err := sv.ReadXML(bytes.NewReader(txt)); err != nil {
+		t.Fatal(err)
+	}
+	return sv
+}
+
+// writeSVG writes the svg
+func writeSVG(t *testing.T, sv *SVG) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := sv.WriteXML(&b, true); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestWriteXMLRoundTrip(t *testing.T) {
+	sv1 := readSVG(t, []byte(roundTripSVG))
+	out1 := writeSVG(t, sv1)
+	sv2 := readSVG(t, out1)
+	out2 := writeSVG(t, sv2)
+	if !bytes.Equal(out1, out2) {
+		t.Errorf("second write differs from first:\n%s\n---\n%s", out1, out2)
+	}
+	if len(sv1.CSS) == 0 {
+		t.Errorf("style sheet was not read")
+	}
+	if !reflect.DeepEqual(sv1.CSS, sv2.CSS) {
+		t.Errorf("css differs: %v vs. %v", sv1.CSS, sv2.CSS)
+	}
+	if len(sv1.Defs.Kids) != 3 {
+		t.Errorf("expected 3 defs, got %d", len(sv1.Defs.Kids))
+	}
+	compareNodes(t, sv1.Defs.This(), sv2.Defs.This())
+	if len(sv1.Kids) != len(sv2.Kids) { // svg itself gains the default version
+		t.Fatalf("%d vs. %d elements", len(sv1.Kids), len(sv2.Kids))
+	}
+	for i := range sv1.Kids {
+		compareNodes(t, sv1.Kids[i], sv2.Kids[i])
+	}
+
+	for _, nm := range []string{"lin", "rad"} {
+		gr, ok := sv2.FindNamedElement(nm).(*gi.Gradient)
+		if !ok || gr.Grad.Gradient == nil {
+			t.Errorf("gradient %v not found", nm)
+			continue
+		}
+		if gr.Grad.Gradient.IsRadial != (nm == "rad") {
+			t.Errorf("gradient %v: wrong IsRadial", nm)
+		}
+	}
+	if g := sv2.FindNamedElement("grp"); g == nil {
+		t.Errorf("group not found")
+	} else if xf, _ := g.Prop("transform"); xmlPropString(xf) != "translate(10,20) rotate(30) scale(2)" {
+		t.Errorf("group transform not preserved: %v", xmlPropString(xf))
+	}
+	if txt, ok := sv2.FindNamedElement("caption").(*Text); !ok || len(txt.Kids) != 2 || txt.Kids[0].Name() != "bold" {
+		t.Errorf("text spans not preserved")
+	} else if txt.Text != "Hello " || txt.Kids[1].(*Text).Text != " world" {
+		t.Errorf("text not preserved: %q %q", txt.Text, txt.Kids[1].(*Text).Text)
+	}
+}
+
+// compareNodes checks that the two trees have the same elements and values
+func compareNodes(t *testing.T, a, b ki.Ki) {
+	t.Helper()
+	if a.Type() != b.Type() || a.Name() != b.Name() {
+		t.Errorf("node %v (%v) differs from %v (%v)", a.Path(), a.Type().Name(), b.Path(), b.Type().Name())
+		return
+	}
+	pa, pb := *a.Properties(), *b.Properties()
+	if len(pa) != len(pb) {
+		t.Errorf("node %v: props %v vs. %v", a.Path(), pa, pb)
+	}
+	for key, val := range pa {
+		if xmlPropString(val) != xmlPropString(pb[key]) {
+			t.Errorf("node %v: prop %v: %v vs. %v", a.Path(), key, xmlPropString(val), xmlPropString(pb[key]))
+		}
+	}
+	switch na := a.(type) {
+	case *gi.Gradient:
+		nb := b.(*gi.Gradient)
+		if !reflect.DeepEqual(na.Grad.Gradient, nb.Grad.Gradient) {
+			t.Errorf("gradient %v: %+v vs. %+v", a.Name(), na.Grad.Gradient, nb.Grad.Gradient)
+		}
+	case *Marker:
+		nb := b.(*Marker)
+		if na.RefPos != nb.RefPos || na.Size != nb.Size || na.Orient != nb.Orient || na.ViewBox != nb.ViewBox {
+			t.Errorf("marker %v: %+v vs. %+v", a.Name(), na, nb)
+		}
+	case *Path:
+		nb := b.(*Path)
+		if !reflect.DeepEqual(na.Data, nb.Data) {
+			t.Errorf("path %v: %v vs. %v", a.Name(), na.Data, nb.Data)
+		}
+	case *Text:
+		nb := b.(*Text)
+		if na.Text != nb.Text || na.Pos != nb.Pos {
+			t.Errorf("text %v: %q at %v vs. %q at %v", a.Name(), na.Text, na.Pos, nb.Text, nb.Pos)
+		}
+	case *gi.StyleSheet:
+		nb := b.(*gi.StyleSheet)
+		if (na.Sheet == nil) != (nb.Sheet == nil) {
+			t.Errorf("style sheet %v not preserved", a.Name())
+		}
+	}
+	ka, kb := *a.Children(), *b.Children()
+	if len(ka) != len(kb) {
+		t.Errorf("node %v: %d vs. %d children", a.Path(), len(ka), len(kb))
+		return
+	}
+	for i := range ka {
+		compareNodes(t, ka[i], kb[i])
+	}
+}
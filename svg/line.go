@@ -25,6 +25,7 @@ func (g *Line) Render2D() {
 	}
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.Lock()
 	rs.PushXForm(pc.XForm)
 	pc.DrawLine(rs, g.Start.X, g.Start.Y, g.End.X, g.End.Y)
@@ -42,5 +43,8 @@ func (g *Line) Render2D() {
 	rs.Unlock()
 
 	g.Render2DChildren()
+	if clip {
+		g.PopClipMask()
+	}
 	rs.PopXFormLock()
 }
@@ -0,0 +1,106 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"image"
+	"log"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+)
+
+// Mask is used for holding the contents of a mask -- the element referring
+// to it with a mask="url(#name)" property is drawn with the luminance of
+// the rendered contents as its opacity, within the mask region -- see
+// NodeBase.PushClipMask
+type Mask struct {
+	NodeBase
+	Pos          gi.Vec2D  `xml:"{x,y}" desc:"position of the mask region, in Units"`
+	Size         gi.Vec2D  `xml:"{width,height}" desc:"size of the mask region, in Units"`
+	Units        ClipUnits `xml:"maskUnits" desc:"coordinate system for the mask region"`
+	ContentUnits ClipUnits `xml:"maskContentUnits" desc:"coordinate system for the contents of the mask"`
+	inUse        bool      `desc:"currently rendering -- prevents infinite recursion for masks that refer to themselves"`
+}
+
+var KiT_Mask = kit.Types.AddType(&Mask{}, nil)
+
+// Defaults sets the svg defaults: a region 10% larger than the bounding box
+// of the element on each side, and contents in user space
+func (mk *Mask) Defaults() {
+	mk.Pos = gi.Vec2D{-0.1, -0.1}
+	mk.Size = gi.Vec2D{1.2, 1.2}
+	mk.Units = ClipObjectBBox
+	mk.ContentUnits = ClipUserSpace
+}
+
+// Render2D does nothing -- masks are only rendered for the elements that
+// refer to them, by MaskMask
+func (mk *Mask) Render2D() {
+}
+
+// MaskMask renders the mask for given element, rendered with the current
+// transform, into a new mask the size of the render image, returning nil if
+// it is already being rendered (i.e., it refers to itself).  The luminance
+// of the contents is the mask, so it is computed here rather than with
+// Paint.AsMask, which takes the alpha.  If the mask itself has a clip-path
+// or mask property, those are intersected with this one.
+func (mk *Mask) MaskMask(rs *gi.RenderState, nd gi.Node2D) *image.Alpha {
+	if mk.inUse {
+		log.Printf("svg.Mask: %v refers to itself\n", mk.PathUnique())
+		return nil
+	}
+	if mk.Viewport == nil {
+		mk.This().(gi.Node2D).Init2D()
+	}
+	mk.inUse = true
+	defer func() { mk.inUse = false }()
+	rs.PushImage()
+	renderContents(rs, mk.AsSVGNode(), NodeUnitsXForm(mk.ContentUnits, rs.XForm, nd))
+	layer := rs.PopImage()
+
+	b := layer.Bounds()
+	mask := image.NewAlpha(b)
+	inReg := func(x, y int) bool { return true } // no region = everywhere
+	reg := b
+	if !mk.Size.IsZero() {
+		// the region is a rectangle in its units, which can be rotated or
+		// skewed in the image, so pixels are mapped back into it
+		rxf := NodeUnitsXForm(mk.Units, rs.XForm, nd)
+		inv := rxf.Inverse()
+		rmin := rxf.TransformPointVec2D(mk.Pos)
+		rmax := rmin
+		for _, pt := range []gi.Vec2D{{mk.Pos.X + mk.Size.X, mk.Pos.Y}, mk.Pos.Add(mk.Size), {mk.Pos.X, mk.Pos.Y + mk.Size.Y}} {
+			tp := rxf.TransformPointVec2D(pt)
+			rmin.SetMin(tp)
+			rmax.SetMax(tp)
+		}
+		reg = image.Rect(int(rmin.X), int(rmin.Y), int(rmax.X+0.5), int(rmax.Y+0.5)).Intersect(b)
+		inReg = func(x, y int) bool {
+			up := inv.TransformPointVec2D(gi.Vec2D{float32(x) + 0.5, float32(y) + 0.5}).Sub(mk.Pos)
+			return up.X >= 0 && up.Y >= 0 && up.X <= mk.Size.X && up.Y <= mk.Size.Y
+		}
+	}
+	for y := reg.Min.Y; y < reg.Max.Y; y++ {
+		lo := layer.PixOffset(reg.Min.X, y)
+		mo := mask.PixOffset(reg.Min.X, y)
+		for x := reg.Min.X; x < reg.Max.X; x++ {
+			if inReg(x, y) {
+				p := layer.Pix[lo : lo+4 : lo+4]
+				// luminance of premultiplied color is luminance times alpha
+				mask.Pix[mo] = uint8((2125*uint32(p[0]) + 7154*uint32(p[1]) + 721*uint32(p[2])) / 10000)
+			}
+			lo += 4
+			mo++
+		}
+	}
+	if cp := mk.ClipPath(); cp != nil {
+		mask = IntersectMasks(mask, cp.ClipMask(rs, nd))
+	}
+	if mmk := mk.Mask(); mmk != nil {
+		mask = IntersectMasks(mask, mmk.MaskMask(rs, nd))
+	}
+	return mask
+}
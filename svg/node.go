@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"image"
 	"log"
+	"sort"
 	"strings"
 
 	"github.com/goki/gi/gi"
@@ -19,7 +20,8 @@ import (
 // layout logic -- just renders into parent SVG viewport
 type NodeBase struct {
 	gi.Node2DBase
-	Pnt gi.Paint `json:"-" xml:"-" desc:"full paint information for this node"`
+	Pnt         gi.Paint `json:"-" xml:"-" desc:"full paint information for this node"`
+	InlineStyle []string `desc:"names of the properties that were set by an inline style attribute, which take precedence over css style sheet rules -- other properties are presentation attributes, which css rules override"`
 }
 
 var KiT_NodeBase = kit.Types.AddType(&NodeBase{}, NodeBaseProps)
@@ -28,10 +30,50 @@ var NodeBaseProps = ki.Props{
 	"base-type": true, // excludes type from user selections
 }
 
+// NodeSVG is implemented by all svg elements, which embed NodeBase
+type NodeSVG interface {
+	// AsSVGNode returns the NodeBase of the element
+	AsSVGNode() *NodeBase
+}
+
 func (g *NodeBase) AsSVGNode() *NodeBase {
 	return g
 }
 
+// SetStdXMLAttr sets standard attributes of node given XML-style name /
+// attribute values -- the properties of an inline style attribute are
+// recorded in InlineStyle, for their precedence over css
+func (g *NodeBase) SetStdXMLAttr(name, val string) bool {
+	if name != "style" {
+		return g.Node2DBase.SetStdXMLAttr(name, val)
+	}
+	var sp ki.Props
+	gi.SetStylePropsXML(val, &sp)
+	keys := make([]string, 0, len(sp))
+	for key := range sp {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		g.SetProp(key, sp[key])
+		if !g.IsInlineStyle(key) {
+			g.InlineStyle = append(g.InlineStyle, key)
+		}
+	}
+	return true
+}
+
+// IsInlineStyle returns true if given property was set by an inline style
+// attribute
+func (g *NodeBase) IsInlineStyle(key string) bool {
+	for _, k := range g.InlineStyle {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
 // Paint satisfies the painter interface
 func (g *NodeBase) Paint() *gi.Paint {
 	return &g.Pnt
@@ -69,24 +111,41 @@ func StyleSVG(gii gi.Node2D) {
 
 	pc.StyleSet = false // this is always first call, restart
 
+	// precedence is: presentation attributes, then css, then inline style
+	props := *gii.Properties()
+	var inline ki.Props
+	if sn, ok := gii.(NodeSVG); ok && len(sn.AsSVGNode().InlineStyle) > 0 {
+		sg := sn.AsSVGNode()
+		pres := make(ki.Props, len(props))
+		inline = make(ki.Props, len(sg.InlineStyle))
+		for key, val := range props {
+			if sg.IsInlineStyle(key) {
+				inline[key] = val
+			} else {
+				pres[key] = val
+			}
+		}
+		props = pres
+	}
+
 	pp := g.ParentPaint()
 	if pp != nil {
 		pc.CopyStyleFrom(pp)
-		pc.SetStyleProps(pp, *gii.Properties(), g.Viewport)
-	} else {
-		pc.SetStyleProps(nil, *gii.Properties(), g.Viewport)
 	}
-	// pc.SetUnitContext(g.Viewport, gi.Vec2DZero)
-	pc.ToDots() // we always inherit parent's unit context -- SVG sets it once-and-for-all
+	pc.SetStyleProps(pp, props, g.Viewport)
 
+	g.CSSAgg = nil
 	pagg := g.ParentCSSAgg()
 	if pagg != nil {
 		gi.AggCSS(&g.CSSAgg, *pagg)
-	} else {
-		g.CSSAgg = nil
 	}
 	gi.AggCSS(&g.CSSAgg, g.CSS)
 	StyleCSS(gii, g.CSSAgg)
+	if len(inline) > 0 {
+		pc.SetStyleProps(pp, inline, g.Viewport)
+	}
+	// pc.SetUnitContext(g.Viewport, gi.Vec2DZero)
+	pc.ToDots() // we always inherit parent's unit context -- SVG sets it once-and-for-all
 	if pc.HasNoStrokeOrFill() {
 		pc.Off = true
 	} else {
@@ -124,15 +183,13 @@ func ApplyCSSSVG(node gi.Node2D, key string, css ki.Props) bool {
 	return true
 }
 
-// StyleCSS applies css style properties to given SVG node, parsing
-// out type, .class, and #name selectors
+// StyleCSS applies css style properties to given SVG node, for each of the
+// selectors that match it, in order of increasing specificity -- see
+// CSSSelectors
 func StyleCSS(node gi.Node2D, css ki.Props) {
-	tyn := strings.ToLower(node.Type().Name()) // type is most general, first
-	ApplyCSSSVG(node, tyn, css)
-	cln := "." + strings.ToLower(node.AsNode2D().Class) // then class
-	ApplyCSSSVG(node, cln, css)
-	idnm := "#" + strings.ToLower(node.Name()) // then name
-	ApplyCSSSVG(node, idnm, css)
+	for _, sel := range CSSSelectors(node, css) {
+		ApplyCSSSVG(node, sel, css)
+	}
 }
 
 func (g *NodeBase) Style2D() {
@@ -191,10 +248,14 @@ func (g *NodeBase) Render2D() {
 	}
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.PushXFormLock(pc.XForm)
 	// render path elements, then compute bbox, then fill / stroke
 	g.ComputeBBoxSVG()
 	g.Render2DChildren()
+	if clip {
+		g.PopClipMask()
+	}
 	rs.PopXFormLock()
 }
 
@@ -9,6 +9,7 @@ import (
 	"log"
 	"math"
 	"strconv"
+	"strings"
 	"unicode"
 
 	"github.com/chewxy/math32"
@@ -51,6 +52,7 @@ func (g *Path) Render2D() {
 
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.Lock()
 	rs.PushXForm(pc.XForm)
 	PathDataRender(g.Data, pc, rs)
@@ -90,6 +92,9 @@ func (g *Path) Render2D() {
 	}
 
 	g.Render2DChildren()
+	if clip {
+		g.PopClipMask()
+	}
 	rs.PopXFormLock()
 }
 
@@ -401,7 +406,7 @@ func PathDataIterFunc(data []PathData, fun func(idx int, cmd PathCmds, ptIdx int
 		case Pcm:
 			cx += PathDataNext(data, &i)
 			cy += PathDataNext(data, &i)
-			if !fun(i, cmd, 0, cx, cy) {
+			if !fun(i-2, cmd, 0, cx, cy) {
 				return
 			}
 			for np := 1; np < n/2; np++ {
@@ -566,6 +571,58 @@ func PathDataIterFunc(data []PathData, fun func(idx int, cmd PathCmds, ptIdx int
 	return
 }
 
+// PathDataIsRel returns true if the path command uses coordinates relative to
+// the current point, i.e., it is lower case
+func PathDataIsRel(cmd PathCmds) bool {
+	return cmd < PcErr && cmd%2 == 1
+}
+
+// PathDataMovePoint moves the point of given index in the path data, in the
+// order of points visited by PathDataIterFunc, by given amount, in the
+// coordinates of the path -- the relative coordinates of the following
+// command are compensated so that the rest of the path stays in place
+func PathDataMovePoint(data []PathData, pt int, dx, dy float32) {
+	type cmdIdx struct {
+		idx int
+		cmd PathCmds
+	}
+	var pts []cmdIdx
+	PathDataIterFunc(data, func(idx int, cmd PathCmds, ptIdx int, cx, cy float32) bool {
+		pts = append(pts, cmdIdx{idx, cmd})
+		return pt >= len(pts)-1 // need the following one too
+	})
+	if pt < 0 || pt >= len(pts) {
+		return
+	}
+	pathDataShift(data, pts[pt].idx, pts[pt].cmd, dx, dy)
+	if pt+1 == len(pts) || !PathDataIsRel(pts[pt+1].cmd) {
+		return
+	}
+	nx := pts[pt+1]
+	switch nx.cmd { // all coords relative to the moved point
+	case Pcc:
+		pathDataShift(data, nx.idx-4, nx.cmd, -dx, -dy)
+		fallthrough
+	case Pcs, Pcq:
+		pathDataShift(data, nx.idx-2, nx.cmd, -dx, -dy)
+	}
+	pathDataShift(data, nx.idx, nx.cmd, -dx, -dy)
+}
+
+// pathDataShift shifts the coordinates at given index in the path data, for
+// given command, by given amount
+func pathDataShift(data []PathData, idx int, cmd PathCmds, dx, dy float32) {
+	switch cmd {
+	case PcH, Pch:
+		data[idx] += PathData(dx)
+	case PcV, Pcv:
+		data[idx] += PathData(dy)
+	default:
+		data[idx] += PathData(dx)
+		data[idx+1] += PathData(dy)
+	}
+}
+
 // PathDataMinMax traverses the path data and extracts the min and max point coords
 func PathDataMinMax(data []PathData) (min, max gi.Vec2D) {
 	PathDataIterFunc(data, func(idx int, cmd PathCmds, ptIdx int, cx, cy float32) bool {
@@ -710,6 +767,37 @@ func PathDecodeCmd(r rune) PathCmds {
 	}
 }
 
+// PathCmdRune returns the rune for given path command -- inverse of PathCmdMap
+func PathCmdRune(cmd PathCmds) rune {
+	for r, pc := range PathCmdMap {
+		if pc == cmd {
+			return r
+		}
+	}
+	return '?'
+}
+
+// PathDataString returns the string representation of the path data, in
+// the svg path format that PathDataParse parses
+func PathDataString(data []PathData) string {
+	var sb strings.Builder
+	sz := len(data)
+	for i := 0; i < sz; {
+		cmd, n := PathDataNextCmd(data, &i)
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteRune(PathCmdRune(cmd))
+		for np := 0; np < n; np++ {
+			if np > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(strconv.FormatFloat(float64(PathDataNext(data, &i)), 'f', -1, 32))
+		}
+	}
+	return sb.String()
+}
+
 // PathDataParse parses a string representation of the path data into compiled path data
 func PathDataParse(d string) ([]PathData, error) {
 	var pd []PathData
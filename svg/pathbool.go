@@ -0,0 +1,362 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"math"
+	"sort"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+)
+
+// PathBoolOps are the boolean operations combining the areas of two paths,
+// with PathDataBool
+type PathBoolOps int32
+
+const (
+	// PathUnion is the area within either path
+	PathUnion PathBoolOps = iota
+
+	// PathIntersect is the area within both paths
+	PathIntersect
+
+	// PathDifference is the area within the first path but not the second
+	PathDifference
+
+	// PathXor is the area within either path but not both
+	PathXor
+
+	PathBoolOpsN
+)
+
+//go:generate stringer -type=PathBoolOps
+
+var KiT_PathBoolOps = kit.Enums.AddEnumAltLower(PathBoolOpsN, false, nil, "Path")
+
+func (ev PathBoolOps) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *PathBoolOps) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+// In returns whether a point is within the result of the operation, given
+// whether it is within each of the paths
+func (op PathBoolOps) In(a, b bool) bool {
+	switch op {
+	case PathIntersect:
+		return a && b
+	case PathDifference:
+		return a && !b
+	case PathXor:
+		return a != b
+	}
+	return a || b
+}
+
+// PathDataBool returns path data for the area resulting from the boolean
+// operation on the areas of the two paths, each filled according to its fill
+// rule.  Curves are flattened to within PathFlattenTol, so the result has
+// only lines, in closed subpaths that are oriented so that it can be filled
+// with either fill rule.  Either path can be nil, so, e.g., the union of a
+// path with nil removes its overlaps.
+func PathDataBool(op PathBoolOps, a []PathData, arule gi.FillRule, b []PathData, brule gi.FillRule) []PathData {
+	return PathPolysData(PathPolysBool(op, PathDataFlatten(a, 0), arule, PathDataFlatten(b, 0), brule))
+}
+
+// PathBool returns path data for the area resulting from the boolean
+// operation on the areas of the two paths, using their fill rules, in the
+// coordinates of path a -- b is transformed into them, assuming that both
+// paths are in the same parent
+func PathBool(op PathBoolOps, a, b *Path) []PathData {
+	pb := PathDataFlatten(b.Data, 0)
+	xf := pathXForm(b).Multiply(pathXForm(a).Inverse())
+	for _, pl := range pb {
+		for i, pt := range pl.Pts {
+			pl.Pts[i] = xf.TransformPointVec2D(pt)
+		}
+	}
+	return PathPolysData(PathPolysBool(op, PathDataFlatten(a.Data, 0), a.Pnt.FillStyle.Rule, pb, b.Pnt.FillStyle.Rule))
+}
+
+// pathXForm returns the transform of the path, which is the identity if it
+// has not been styled yet
+func pathXForm(p *Path) gi.Matrix2D {
+	if p.Pnt.XForm == (gi.Matrix2D{}) {
+		return gi.Identity2D()
+	}
+	return p.Pnt.XForm
+}
+
+// boolPt is a point in the computation of boolean operations, which is done
+// in float64 for robustness
+type boolPt struct {
+	x, y float64
+}
+
+// boolSeg is a line segment of a path in boolean operations
+type boolSeg struct {
+	a, b boolPt
+}
+
+// boolCross returns the cross product of b-a and p-a, which is positive if
+// p is to the left of the line from a to b, in y-up terms
+func boolCross(a, b, p boolPt) float64 {
+	return (b.x-a.x)*(p.y-a.y) - (p.x-a.x)*(b.y-a.y)
+}
+
+// boolShape is the area of a path, as the segments of all its subpaths,
+// which are closed for filling
+type boolShape struct {
+	segs []boolSeg
+	rule gi.FillRule
+}
+
+func newBoolShape(polys []PathPoly, rule gi.FillRule) *boolShape {
+	bs := &boolShape{rule: rule}
+	for _, pl := range polys {
+		np := len(pl.Pts)
+		for i := 0; i < np; i++ {
+			p1, p2 := pl.Pts[i], pl.Pts[(i+1)%np]
+			if p1 != p2 {
+				bs.segs = append(bs.segs, boolSeg{boolPt{float64(p1.X), float64(p1.Y)}, boolPt{float64(p2.X), float64(p2.Y)}})
+			}
+		}
+	}
+	return bs
+}
+
+// inside returns true if the point is within the area, by its fill rule
+func (bs *boolShape) inside(p boolPt) bool {
+	wn := 0
+	for _, s := range bs.segs {
+		if s.a.y <= p.y {
+			if s.b.y > p.y && boolCross(s.a, s.b, p) > 0 {
+				wn++
+			}
+		} else if s.b.y <= p.y && boolCross(s.a, s.b, p) < 0 {
+			wn--
+		}
+	}
+	if bs.rule == gi.FillRuleEvenOdd {
+		return wn%2 != 0
+	}
+	return wn != 0
+}
+
+// boolTouch returns the points where the two segments touch, where either
+// one of them must be split -- points within eps of an end point are
+// snapped to it
+func boolTouch(p, q boolSeg, eps float64) []boolPt {
+	dx, dy := p.b.x-p.a.x, p.b.y-p.a.y
+	ex, ey := q.b.x-q.a.x, q.b.y-q.a.y
+	dl, el := math.Hypot(dx, dy), math.Hypot(ex, ey)
+	den := dx*ey - dy*ex
+	if math.Abs(den) <= 1e-12*dl*el { // parallel -- touch only if collinear and overlapping
+		if math.Abs(boolCross(p.a, p.b, q.a))/dl > eps {
+			return nil
+		}
+		var pts []boolPt
+		on := func(s boolSeg, sl float64, pt boolPt) bool {
+			t := ((pt.x-s.a.x)*(s.b.x-s.a.x) + (pt.y-s.a.y)*(s.b.y-s.a.y)) / (sl * sl)
+			return t > 0 && t < 1
+		}
+		for _, pt := range []boolPt{q.a, q.b} {
+			if on(p, dl, pt) {
+				pts = append(pts, pt)
+			}
+		}
+		for _, pt := range []boolPt{p.a, p.b} {
+			if on(q, el, pt) {
+				pts = append(pts, pt)
+			}
+		}
+		return pts
+	}
+	wx, wy := q.a.x-p.a.x, q.a.y-p.a.y
+	t := (wx*ey - wy*ex) / den
+	u := (wx*dy - wy*dx) / den
+	tt, tu := eps/dl, eps/el
+	if t < -tt || t > 1+tt || u < -tu || u > 1+tu {
+		return nil
+	}
+	switch {
+	case t <= tt:
+		return []boolPt{p.a}
+	case t >= 1-tt:
+		return []boolPt{p.b}
+	case u <= tu:
+		return []boolPt{q.a}
+	case u >= 1-tu:
+		return []boolPt{q.b}
+	}
+	return []boolPt{{p.a.x + t*dx, p.a.y + t*dy}}
+}
+
+// boolVerts welds together the points that are within a small distance of
+// each other into vertices, so the segments can be linked into subpaths
+type boolVerts struct {
+	pts  []boolPt
+	grid map[[2]int64][]int
+	size float64
+}
+
+// vert returns the index of the vertex for given point
+func (bv *boolVerts) vert(p boolPt) int {
+	gx, gy := int64(math.Floor(p.x/bv.size)), int64(math.Floor(p.y/bv.size))
+	for x := gx - 1; x <= gx+1; x++ {
+		for y := gy - 1; y <= gy+1; y++ {
+			for _, vi := range bv.grid[[2]int64{x, y}] {
+				if math.Hypot(bv.pts[vi].x-p.x, bv.pts[vi].y-p.y) <= bv.size {
+					return vi
+				}
+			}
+		}
+	}
+	vi := len(bv.pts)
+	bv.pts = append(bv.pts, p)
+	k := [2]int64{gx, gy}
+	bv.grid[k] = append(bv.grid[k], vi)
+	return vi
+}
+
+// boolEdge is a directed edge between two vertices
+type boolEdge struct {
+	from, to int
+}
+
+// PathPolysBool returns the closed polygons of the area resulting from the
+// boolean operation on the areas of the two sets of polylines, which are
+// all taken to be closed, filled according to given rules -- it is the basis
+// of PathDataBool.
+//
+// All the segments of both are split where they touch any other segment,
+// and each resulting piece is on the boundary of the result if the result
+// of the operation differs between its two sides -- the pieces are oriented
+// so that the result is on the same side of all of them, and linked up.
+func PathPolysBool(op PathBoolOps, a []PathPoly, arule gi.FillRule, b []PathPoly, brule gi.FillRule) []PathPoly {
+	sa := newBoolShape(a, arule)
+	sb := newBoolShape(b, brule)
+	segs := append(append([]boolSeg{}, sa.segs...), sb.segs...)
+	if len(segs) == 0 {
+		return nil
+	}
+	min, max := segs[0].a, segs[0].a
+	for _, s := range segs {
+		for _, p := range []boolPt{s.a, s.b} {
+			min.x, min.y = math.Min(min.x, p.x), math.Min(min.y, p.y)
+			max.x, max.y = math.Max(max.x, p.x), math.Max(max.y, p.y)
+		}
+	}
+	diag := math.Hypot(max.x-min.x, max.y-min.y)
+	if diag == 0 {
+		return nil
+	}
+	eps := diag * 1e-9
+
+	splits := make([][]boolPt, len(segs))
+	for i := range segs {
+		for j := i + 1; j < len(segs); j++ {
+			for _, pt := range boolTouch(segs[i], segs[j], eps) {
+				splits[i] = append(splits[i], pt)
+				splits[j] = append(splits[j], pt)
+			}
+		}
+	}
+
+	bv := &boolVerts{grid: make(map[[2]int64][]int), size: 10 * eps}
+	var edges []boolEdge
+	used := make(map[boolEdge]bool)
+	side := diag * 1e-7 // distance from pieces to test either side of them
+	for i, s := range segs {
+		pts := append([]boolPt{s.a, s.b}, splits[i]...)
+		dx, dy := s.b.x-s.a.x, s.b.y-s.a.y
+		sort.Slice(pts, func(k, l int) bool {
+			return (pts[k].x-s.a.x)*dx+(pts[k].y-s.a.y)*dy < (pts[l].x-s.a.x)*dx+(pts[l].y-s.a.y)*dy
+		})
+		for k := 1; k < len(pts); k++ {
+			e := boolEdge{bv.vert(pts[k-1]), bv.vert(pts[k])}
+			if e.from == e.to {
+				continue
+			}
+			p1, p2 := bv.pts[e.from], bv.pts[e.to]
+			ln := math.Hypot(p2.x-p1.x, p2.y-p1.y)
+			nx, ny := -(p2.y-p1.y)/ln*side, (p2.x-p1.x)/ln*side
+			mx, my := 0.5*(p1.x+p2.x), 0.5*(p1.y+p2.y)
+			lp, rp := boolPt{mx + nx, my + ny}, boolPt{mx - nx, my - ny}
+			inl := op.In(sa.inside(lp), sb.inside(lp))
+			inr := op.In(sa.inside(rp), sb.inside(rp))
+			if inl == inr {
+				continue
+			}
+			if !inl {
+				e.from, e.to = e.to, e.from
+			}
+			if used[e] {
+				continue
+			}
+			used[e] = true
+			edges = append(edges, e)
+		}
+	}
+	return boolLink(bv.pts, edges)
+}
+
+// boolLink links the boundary edges between the vertices into closed
+// polygons, with repeated and collinear points removed
+func boolLink(verts []boolPt, edges []boolEdge) []PathPoly {
+	out := make(map[int][]int)
+	for ei, e := range edges {
+		out[e.from] = append(out[e.from], ei)
+	}
+	done := make([]bool, len(edges))
+	var polys []PathPoly
+	for ei := range edges {
+		if done[ei] {
+			continue
+		}
+		var loop []int
+		st := edges[ei].from
+		cur := ei
+		for {
+			done[cur] = true
+			loop = append(loop, edges[cur].from)
+			nv := edges[cur].to
+			if nv == st {
+				break
+			}
+			cur = -1
+			for _, oi := range out[nv] {
+				if !done[oi] {
+					cur = oi
+					break
+				}
+			}
+			if cur < 0 { // not closed -- should not happen
+				loop = nil
+				break
+			}
+		}
+		if pts := boolLoopPts(verts, loop); len(pts) >= 3 {
+			polys = append(polys, PathPoly{Pts: pts, Closed: true})
+		}
+	}
+	return polys
+}
+
+// boolLoopPts returns the points of the loop of vertices, without points
+// where the loop continues straight on
+func boolLoopPts(verts []boolPt, loop []int) []gi.Vec2D {
+	n := len(loop)
+	var pts []gi.Vec2D
+	for i, vi := range loop {
+		p0, p, p1 := verts[loop[(i+n-1)%n]], verts[vi], verts[loop[(i+1)%n]]
+		l0 := math.Hypot(p.x-p0.x, p.y-p0.y)
+		l1 := math.Hypot(p1.x-p.x, p1.y-p.y)
+		dot := (p.x-p0.x)*(p1.x-p.x) + (p.y-p0.y)*(p1.y-p.y)
+		if math.Abs(boolCross(p0, p, p1)) <= 1e-12*l0*l1 && dot > 0 {
+			continue
+		}
+		pts = append(pts, gi.Vec2D{float32(p.x), float32(p.y)})
+	}
+	return pts
+}
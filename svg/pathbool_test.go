@@ -0,0 +1,73 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"testing"
+
+	"github.com/goki/gi/gi"
+)
+
+// squarePoly returns a closed square with given corner and size -- reversed
+// orders the points clockwise instead of counter-clockwise
+func squarePoly(x, y, sz float32, reversed bool) PathPoly {
+	pts := []gi.Vec2D{{x, y}, {x + sz, y}, {x + sz, y + sz}, {x, y + sz}}
+	if reversed {
+		pts[1], pts[3] = pts[3], pts[1]
+	}
+	return PathPoly{Pts: pts, Closed: true}
+}
+
+func TestPathPolysBool(t *testing.T) {
+	sq := []PathPoly{squarePoly(0, 0, 10, false)}
+	holed := []PathPoly{squarePoly(0, 0, 20, false), squarePoly(5, 5, 10, true)}
+	strip := []PathPoly{{Pts: []gi.Vec2D{{8, -5}, {12, -5}, {12, 25}, {8, 25}}, Closed: true}}
+	tests := []struct {
+		name string
+		a, b []PathPoly
+		rule gi.FillRule
+		area [PathBoolOpsN]float32 // union, intersect, difference, xor
+	}{
+		{"overlapping", sq, []PathPoly{squarePoly(5, 5, 10, false)}, gi.FillRuleNonZero, [PathBoolOpsN]float32{175, 25, 75, 150}},
+		{"identical", sq, []PathPoly{squarePoly(0, 0, 10, true)}, gi.FillRuleNonZero, [PathBoolOpsN]float32{100, 100, 0, 0}},
+		{"adjacent", sq, []PathPoly{squarePoly(10, 0, 10, false)}, gi.FillRuleNonZero, [PathBoolOpsN]float32{200, 0, 100, 200}},
+		{"holed", holed, strip, gi.FillRuleNonZero, [PathBoolOpsN]float32{380, 40, 260, 340}},
+		{"holed even-odd", []PathPoly{holed[0], squarePoly(5, 5, 10, false)}, strip, gi.FillRuleEvenOdd, [PathBoolOpsN]float32{380, 40, 260, 340}},
+	}
+	for _, ts := range tests {
+		for op := PathUnion; op < PathBoolOpsN; op++ {
+			res := PathPolysBool(op, ts.a, ts.rule, ts.b, ts.rule)
+			if a := polysArea(res); !near(a, ts.area[op], 1e-3) {
+				t.Errorf("%v %v: area %v, want %v", ts.name, op, a, ts.area[op])
+			}
+			for _, pl := range res {
+				if !pl.Closed || len(pl.Pts) < 3 {
+					t.Errorf("%v %v: not a closed polygon: %+v", ts.name, op, pl)
+				}
+			}
+		}
+	}
+
+	// the union of adjacent squares is one rectangle, with the shared edge
+	// and collinear points removed
+	res := PathPolysBool(PathUnion, sq, gi.FillRuleNonZero, []PathPoly{squarePoly(10, 0, 10, false)}, gi.FillRuleNonZero)
+	if len(res) != 1 || len(res[0].Pts) != 4 {
+		t.Errorf("union of adjacent squares: %+v", res)
+	}
+	// and the difference of a holed square with nothing keeps the hole
+	res = PathPolysBool(PathDifference, holed, gi.FillRuleNonZero, nil, gi.FillRuleNonZero)
+	if len(res) != 2 || !near(polysArea(res), 300, 1e-3) {
+		t.Errorf("holed square: %+v", res)
+	}
+}
+
+func TestPathDataBool(t *testing.T) {
+	a := parsePath(t, "M 0 0 L 10 0 L 10 10 L 0 10 Z")
+	b := parsePath(t, "M 5 5 h 10 v 10 h -10 z")
+	data := PathDataBool(PathIntersect, a, gi.FillRuleNonZero, b, gi.FillRuleNonZero)
+	if ar := polysArea(PathDataFlatten(data, 0)); !near(ar, 25, 1e-3) {
+		t.Errorf("intersect area %v, want 25", ar)
+	}
+}
@@ -0,0 +1,515 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"github.com/chewxy/math32"
+	"github.com/goki/gi/gi"
+)
+
+// PathSeg is one segment of path data in absolute coordinates, as visited
+// by PathDataSegFunc -- relative, horizontal, vertical and smooth commands
+// are all resolved into their absolute equivalents, so Cmd is one of PcM,
+// PcL, PcQ, PcC, PcA or PcZ
+type PathSeg struct {
+	Cmd      PathCmds `desc:"absolute command of the segment: PcM, PcL, PcQ, PcC, PcA or PcZ"`
+	Start    gi.Vec2D `desc:"current point at the start of the segment"`
+	End      gi.Vec2D `desc:"end point of the segment -- for PcZ, the start of the subpath"`
+	Ctrl1    gi.Vec2D `desc:"first control point, for PcQ and PcC"`
+	Ctrl2    gi.Vec2D `desc:"second control point, for PcC"`
+	Radii    gi.Vec2D `desc:"radii of the ellipse, for PcA"`
+	Angle    float32  `desc:"rotation of the ellipse in degrees, for PcA"`
+	LargeArc bool     `desc:"large-arc-flag, for PcA"`
+	Sweep    bool     `desc:"sweep-flag, for PcA"`
+}
+
+// PathDataSegFunc traverses the path data and calls given function on each
+// segment, in absolute coordinates -- if function returns false, then
+// traversal is aborted.  This is the basis for the path geometry functions,
+// which need the control points of curves that PathDataIterFunc does not
+// provide.
+func PathDataSegFunc(data []PathData, fun func(seg PathSeg) bool) {
+	sz := len(data)
+	var cur, st, lctrl gi.Vec2D
+	lastCmd := PcErr
+	next := func(i *int, rel bool, base float32) float32 {
+		v := PathDataNext(data, i)
+		if rel {
+			return base + v
+		}
+		return v
+	}
+	for i := 0; i < sz; {
+		cmd, n := PathDataNextCmd(data, &i)
+		rel := PathDataIsRel(cmd)
+		nper := PathCmdNMap[cmd]
+		if nper == 0 {
+			if cmd == PcZ || cmd == Pcz {
+				if !fun(PathSeg{Cmd: PcZ, Start: cur, End: st}) {
+					return
+				}
+				cur = st
+				lastCmd = PcZ
+			}
+			continue
+		}
+		for np := 0; np < n/nper; np++ {
+			seg := PathSeg{Start: cur}
+			switch cmd {
+			case PcM, Pcm:
+				seg.End.X = next(&i, rel, cur.X)
+				seg.End.Y = next(&i, rel, cur.Y)
+				seg.Cmd = PcL
+				if np == 0 {
+					seg.Cmd = PcM
+					st = seg.End
+				}
+			case PcL, Pcl:
+				seg.Cmd = PcL
+				seg.End.X = next(&i, rel, cur.X)
+				seg.End.Y = next(&i, rel, cur.Y)
+			case PcH, Pch:
+				seg.Cmd = PcL
+				seg.End = gi.Vec2D{next(&i, rel, cur.X), cur.Y}
+			case PcV, Pcv:
+				seg.Cmd = PcL
+				seg.End = gi.Vec2D{cur.X, next(&i, rel, cur.Y)}
+			case PcC, Pcc:
+				seg.Cmd = PcC
+				seg.Ctrl1.X = next(&i, rel, cur.X)
+				seg.Ctrl1.Y = next(&i, rel, cur.Y)
+				seg.Ctrl2.X = next(&i, rel, cur.X)
+				seg.Ctrl2.Y = next(&i, rel, cur.Y)
+				seg.End.X = next(&i, rel, cur.X)
+				seg.End.Y = next(&i, rel, cur.Y)
+				lctrl = seg.Ctrl2
+			case PcS, Pcs:
+				seg.Cmd = PcC
+				seg.Ctrl1 = cur
+				if lastCmd == PcC {
+					seg.Ctrl1.X, seg.Ctrl1.Y = reflectPt(cur.X, cur.Y, lctrl.X, lctrl.Y)
+				}
+				seg.Ctrl2.X = next(&i, rel, cur.X)
+				seg.Ctrl2.Y = next(&i, rel, cur.Y)
+				seg.End.X = next(&i, rel, cur.X)
+				seg.End.Y = next(&i, rel, cur.Y)
+				lctrl = seg.Ctrl2
+			case PcQ, Pcq:
+				seg.Cmd = PcQ
+				seg.Ctrl1.X = next(&i, rel, cur.X)
+				seg.Ctrl1.Y = next(&i, rel, cur.Y)
+				seg.End.X = next(&i, rel, cur.X)
+				seg.End.Y = next(&i, rel, cur.Y)
+				lctrl = seg.Ctrl1
+			case PcT, Pct:
+				seg.Cmd = PcQ
+				seg.Ctrl1 = cur
+				if lastCmd == PcQ {
+					seg.Ctrl1.X, seg.Ctrl1.Y = reflectPt(cur.X, cur.Y, lctrl.X, lctrl.Y)
+				}
+				seg.End.X = next(&i, rel, cur.X)
+				seg.End.Y = next(&i, rel, cur.Y)
+				lctrl = seg.Ctrl1
+			case PcA, Pca:
+				seg.Cmd = PcA
+				seg.Radii.X = math32.Abs(PathDataNext(data, &i))
+				seg.Radii.Y = math32.Abs(PathDataNext(data, &i))
+				seg.Angle = PathDataNext(data, &i)
+				seg.LargeArc = PathDataNext(data, &i) != 0
+				seg.Sweep = PathDataNext(data, &i) != 0
+				seg.End.X = next(&i, rel, cur.X)
+				seg.End.Y = next(&i, rel, cur.Y)
+			}
+			if !fun(seg) {
+				return
+			}
+			cur = seg.End
+			lastCmd = seg.Cmd
+		}
+	}
+}
+
+// PathFlattenTol is the default tolerance for flattening curves into lines,
+// as the maximum distance of the lines from the curve, in path coordinates
+var PathFlattenTol = float32(0.02)
+
+// PathPoly is one subpath of path data flattened into a polyline
+type PathPoly struct {
+	Pts    []gi.Vec2D `desc:"points along the subpath"`
+	Closed bool       `desc:"subpath is closed, from the last point back to the first"`
+}
+
+// PathDataFlatten flattens the path data into polylines, one for each
+// subpath, approximating curves and arcs with lines to within given
+// tolerance -- 0 uses PathFlattenTol
+func PathDataFlatten(data []PathData, tol float32) []PathPoly {
+	if tol <= 0 {
+		tol = PathFlattenTol
+	}
+	var polys []PathPoly
+	var cur *PathPoly
+	add := func(pt gi.Vec2D) {
+		if cur == nil {
+			polys = append(polys, PathPoly{Pts: []gi.Vec2D{pt}})
+			cur = &polys[len(polys)-1]
+			return
+		}
+		cur.Pts = append(cur.Pts, pt)
+	}
+	PathDataSegFunc(data, func(seg PathSeg) bool {
+		switch seg.Cmd {
+		case PcM:
+			cur = nil
+			add(seg.End)
+			return true
+		case PcZ:
+			if cur != nil {
+				cur.Closed = true
+				cur = nil
+			}
+			return true
+		}
+		if cur == nil { // drawing after close starts a new subpath at the same point
+			add(seg.Start)
+		}
+		switch seg.Cmd {
+		case PcL:
+			add(seg.End)
+		case PcQ:
+			fl := seg.Start.Sub(seg.Ctrl1.MulVal(2)).Add(seg.End)
+			n := flattenSteps(math32.Sqrt(fl.Length() / (4 * tol)))
+			for s := 1; s <= n; s++ {
+				t := float32(s) / float32(n)
+				mt := 1 - t
+				add(seg.Start.MulVal(mt * mt).Add(seg.Ctrl1.MulVal(2 * mt * t)).Add(seg.End.MulVal(t * t)))
+			}
+		case PcC:
+			fl1 := seg.Start.Sub(seg.Ctrl1.MulVal(2)).Add(seg.Ctrl2)
+			fl2 := seg.Ctrl1.Sub(seg.Ctrl2.MulVal(2)).Add(seg.End)
+			n := flattenSteps(math32.Sqrt(0.75 * gi.Max32(fl1.Length(), fl2.Length()) / tol))
+			for s := 1; s <= n; s++ {
+				t := float32(s) / float32(n)
+				mt := 1 - t
+				add(seg.Start.MulVal(mt * mt * mt).Add(seg.Ctrl1.MulVal(3 * mt * mt * t)).Add(seg.Ctrl2.MulVal(3 * mt * t * t)).Add(seg.End.MulVal(t * t * t)))
+			}
+		case PcA:
+			for _, pt := range flattenArc(seg, tol) {
+				add(pt)
+			}
+		}
+		return true
+	})
+	return polys
+}
+
+// flattenSteps returns the number of line segments for flattening a curve,
+// from the ideal number, which is limited to a sensible range
+func flattenSteps(n float32) int {
+	switch {
+	case n != n || n < 1: // NaN or tiny
+		return 1
+	case n > 1000:
+		return 1000
+	}
+	return int(math32.Ceil(n))
+}
+
+// flattenArc returns the points along the elliptical arc segment after its
+// start, to within given tolerance
+func flattenArc(seg PathSeg, tol float32) []gi.Vec2D {
+	rx, ry := seg.Radii.X, seg.Radii.Y
+	if rx == 0 || ry == 0 || seg.Start == seg.End {
+		return []gi.Vec2D{seg.End}
+	}
+	rot := gi.Radians(seg.Angle)
+	cx, cy := gi.FindEllipseCenter(&rx, &ry, rot, seg.Start.X, seg.Start.Y, seg.End.X, seg.End.Y, seg.Sweep, seg.LargeArc)
+	cr, sr := math32.Cos(rot), math32.Sin(rot)
+	angle := func(pt gi.Vec2D) float32 {
+		dx, dy := pt.X-cx, pt.Y-cy
+		return math32.Atan2((-sr*dx+cr*dy)/ry, (cr*dx+sr*dy)/rx)
+	}
+	a0 := angle(seg.Start)
+	da := angle(seg.End) - a0
+	if seg.Sweep && da < 0 {
+		da += 2 * math32.Pi
+	} else if !seg.Sweep && da > 0 {
+		da -= 2 * math32.Pi
+	}
+	r := gi.Max32(rx, ry)
+	step := 2 * math32.Acos(gi.Max32(1-tol/r, -1))
+	n := flattenSteps(math32.Abs(da) / step)
+	pts := make([]gi.Vec2D, n)
+	for s := 1; s < n; s++ {
+		a := a0 + da*float32(s)/float32(n)
+		ex, ey := rx*math32.Cos(a), ry*math32.Sin(a)
+		pts[s-1] = gi.Vec2D{cx + cr*ex - sr*ey, cy + sr*ex + cr*ey}
+	}
+	pts[n-1] = seg.End
+	return pts
+}
+
+// PathMeasure measures lengths along the path, using its flattened polyline
+type PathMeasure struct {
+	Pts  []gi.Vec2D `desc:"points along the path"`
+	Lens []float32  `desc:"length of the path at each point"`
+}
+
+// NewPathMeasure returns a PathMeasure for the path data, transformed by
+// given transform, with curves flattened to within PathFlattenTol
+func NewPathMeasure(data []PathData, xf gi.Matrix2D) *PathMeasure {
+	pm := &PathMeasure{}
+	for _, pl := range PathDataFlatten(data, 0) {
+		pts := pl.Pts
+		if pl.Closed && len(pts) > 0 {
+			pts = append(pts, pts[0])
+		}
+		for i, pt := range pts {
+			pt = xf.TransformPointVec2D(pt)
+			var ln float32
+			if np := len(pm.Pts); np > 0 {
+				ln = pm.Lens[np-1]
+				if i > 0 { // moving to the next subpath adds no length
+					ln += pt.Distance(pm.Pts[np-1])
+				}
+			}
+			pm.Pts = append(pm.Pts, pt)
+			pm.Lens = append(pm.Lens, ln)
+		}
+	}
+	return pm
+}
+
+// Length returns the total length of the path
+func (pm *PathMeasure) Length() float32 {
+	if len(pm.Lens) == 0 {
+		return 0
+	}
+	return pm.Lens[len(pm.Lens)-1]
+}
+
+// PointAt returns the point at given distance along the path, and the angle
+// of the path there -- false if the distance is off either end of the path
+func (pm *PathMeasure) PointAt(dist float32) (pt gi.Vec2D, ang float32, ok bool) {
+	if dist < 0 || dist > pm.Length() {
+		return
+	}
+	for i := 1; i < len(pm.Pts); i++ {
+		sl := pm.Lens[i] - pm.Lens[i-1]
+		if sl == 0 || pm.Lens[i] < dist {
+			continue
+		}
+		p0, p1 := pm.Pts[i-1], pm.Pts[i]
+		pt = p0.Interpolate(p1, (dist-pm.Lens[i-1])/sl)
+		ang = math32.Atan2(p1.Y-p0.Y, p1.X-p0.X)
+		ok = true
+		return
+	}
+	return
+}
+
+// PathDataLength returns the length of the path, in path coordinates
+func PathDataLength(data []PathData) float32 {
+	return NewPathMeasure(data, gi.Identity2D()).Length()
+}
+
+// PathDataPointAtLength returns the point at given distance along the path,
+// and the angle of the path there, in path coordinates -- false if the
+// distance is off either end of the path
+func PathDataPointAtLength(data []PathData, dist float32) (pt gi.Vec2D, ang float32, ok bool) {
+	return NewPathMeasure(data, gi.Identity2D()).PointAt(dist)
+}
+
+// Length returns the length of the path, in its own coordinates
+func (g *Path) Length() float32 {
+	return PathDataLength(g.Data)
+}
+
+// PointAtLength returns the point at given distance along the path, and
+// the angle of the path there, in its own coordinates -- false if the
+// distance is off either end of the path
+func (g *Path) PointAtLength(dist float32) (pt gi.Vec2D, ang float32, ok bool) {
+	return PathDataPointAtLength(g.Data, dist)
+}
+
+// PathDataStroke returns path data for the outline of the stroke of the path
+// with given width, line cap, line join and miter limit, i.e., the area
+// covered by the stroke, for filling -- curves are flattened to within
+// PathFlattenTol, and overlaps are merged so the outline is a simple shape
+func PathDataStroke(data []PathData, width float32, lcap gi.LineCap, join gi.LineJoin, miterLimit float32) []PathData {
+	hw := 0.5 * width
+	if hw <= 0 {
+		return nil
+	}
+	var out []PathPoly
+	for _, pl := range PathDataFlatten(data, 0) {
+		pts := pl.Pts[:0:0]
+		for _, pt := range pl.Pts { // remove repeated points, which have no direction
+			if len(pts) == 0 || pt != pts[len(pts)-1] {
+				pts = append(pts, pt)
+			}
+		}
+		if pl.Closed && len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+			pts = pts[:len(pts)-1]
+		}
+		switch {
+		case len(pts) == 1:
+			if lcap != gi.LineCapButt {
+				out = append(out, PathPoly{Pts: strokeCap(nil, pts[0], gi.Vec2D{1, 0}, hw, lcap, true), Closed: true})
+				out[len(out)-1].Pts = strokeCap(out[len(out)-1].Pts, pts[0], gi.Vec2D{-1, 0}, hw, lcap, false)
+			}
+		case pl.Closed && len(pts) > 2:
+			rev := make([]gi.Vec2D, len(pts))
+			for i := range pts {
+				rev[i] = pts[len(pts)-1-i]
+			}
+			out = append(out, PathPoly{Pts: strokeSide(pts, hw, join, miterLimit, true), Closed: true})
+			out = append(out, PathPoly{Pts: strokeSide(rev, hw, join, miterLimit, true), Closed: true})
+		default:
+			rev := make([]gi.Vec2D, len(pts))
+			for i := range pts {
+				rev[i] = pts[len(pts)-1-i]
+			}
+			ol := strokeSide(pts, hw, join, miterLimit, false)
+			ol = strokeCap(ol, pts[len(pts)-1], pts[len(pts)-1].Sub(pts[len(pts)-2]), hw, lcap, false)
+			ol = append(ol, strokeSide(rev, hw, join, miterLimit, false)...)
+			ol = strokeCap(ol, pts[0], pts[0].Sub(pts[1]), hw, lcap, false)
+			out = append(out, PathPoly{Pts: ol, Closed: true})
+		}
+	}
+	return PathDataBool(PathUnion, PathPolysData(out), gi.FillRuleNonZero, nil, gi.FillRuleNonZero)
+}
+
+// StrokeToPath returns path data for the outline of the stroke of the path,
+// using its stroke width, line cap, line join and miter limit
+func (g *Path) StrokeToPath() []PathData {
+	ss := &g.Pnt.StrokeStyle
+	return PathDataStroke(g.Data, ss.Width.Dots, ss.Cap, ss.Join, ss.MiterLimit)
+}
+
+// strokeNormal returns the unit normal to the left of the direction from a to b
+func strokeNormal(a, b gi.Vec2D) gi.Vec2D {
+	d := b.Sub(a)
+	ln := d.Length()
+	if ln == 0 {
+		return gi.Vec2DZero
+	}
+	return gi.Vec2D{d.Y / ln, -d.X / ln}
+}
+
+// strokeSide returns the points along one side of the stroke of the
+// polyline, offset by hw to the left of it, with joins between the segments
+// -- a closed polyline is joined all the way around
+func strokeSide(pts []gi.Vec2D, hw float32, join gi.LineJoin, miterLimit float32, closed bool) []gi.Vec2D {
+	np := len(pts)
+	nseg := np - 1
+	if closed {
+		nseg = np
+	}
+	seg := func(s int) (gi.Vec2D, gi.Vec2D) {
+		return pts[s%np], pts[(s+1)%np]
+	}
+	var out []gi.Vec2D
+	for s := 0; s < nseg; s++ {
+		a, b := seg(s)
+		n := strokeNormal(a, b).MulVal(hw)
+		if s == 0 && !closed {
+			out = append(out, a.Add(n))
+		}
+		if s == nseg-1 && !closed {
+			out = append(out, b.Add(n))
+			break
+		}
+		_, c := seg(s + 1)
+		out = strokeJoin(out, b, a, c, hw, join, miterLimit)
+	}
+	return out
+}
+
+// strokeJoin adds the points joining the stroke side of the segment from a
+// to the corner pt, to that of the segment from pt to c
+func strokeJoin(out []gi.Vec2D, pt, a, c gi.Vec2D, hw float32, join gi.LineJoin, miterLimit float32) []gi.Vec2D {
+	n1 := strokeNormal(a, pt)
+	n2 := strokeNormal(pt, c)
+	p1 := pt.Add(n1.MulVal(hw))
+	p2 := pt.Add(n2.MulVal(hw))
+	d1 := pt.Sub(a)
+	d2 := c.Sub(pt)
+	cross := d1.X*d2.Y - d1.Y*d2.X
+	if cross <= 0 { // turning toward this side: inner corner, covered by the segments
+		return append(out, p1, pt, p2)
+	}
+	switch join {
+	case gi.LineJoinRound, gi.LineJoinArcs, gi.LineJoinArcsClip:
+		a1 := math32.Atan2(n1.Y, n1.X)
+		a2 := math32.Atan2(n2.Y, n2.X)
+		for a2 < a1 {
+			a2 += 2 * math32.Pi
+		}
+		out = append(out, p1)
+		step := 2 * math32.Acos(gi.Max32(1-PathFlattenTol/hw, -1))
+		n := flattenSteps((a2 - a1) / step)
+		for s := 1; s < n; s++ {
+			a := a1 + (a2-a1)*float32(s)/float32(n)
+			out = append(out, gi.Vec2D{pt.X + hw*math32.Cos(a), pt.Y + hw*math32.Sin(a)})
+		}
+		return append(out, p2)
+	case gi.LineJoinMiter, gi.LineJoinMiterClip:
+		bis := n1.Add(n2)
+		cosh := bis.Length() / 2 // cos of half the angle between the normals
+		if cosh > 0 && 1/cosh <= miterLimit {
+			bis = bis.MulVal(hw / (cosh * bis.Length()))
+			return append(out, p1, pt.Add(bis), p2)
+		}
+	}
+	return append(out, p1, p2) // bevel
+}
+
+// strokeCap adds the points of the cap at the end pt of a stroke with given
+// direction, from the left side of the stroke to the right -- a round cap of
+// a single point starts a new outline if start is set
+func strokeCap(out []gi.Vec2D, pt, dir gi.Vec2D, hw float32, lcap gi.LineCap, start bool) []gi.Vec2D {
+	n := strokeNormal(gi.Vec2DZero, dir)
+	d := gi.Vec2D{-n.Y, n.X}.MulVal(hw) // direction, scaled
+	n = n.MulVal(hw)
+	if start {
+		out = append(out, pt.Add(n))
+	}
+	switch lcap {
+	case gi.LineCapSquare:
+		return append(out, pt.Add(n).Add(d), pt.Sub(n).Add(d), pt.Sub(n))
+	case gi.LineCapButt:
+		return append(out, pt.Sub(n))
+	}
+	a1 := math32.Atan2(n.Y, n.X)
+	step := 2 * math32.Acos(gi.Max32(1-PathFlattenTol/hw, -1))
+	ns := flattenSteps(math32.Pi / step)
+	for s := 1; s < ns; s++ {
+		a := a1 + math32.Pi*float32(s)/float32(ns)
+		out = append(out, gi.Vec2D{pt.X + hw*math32.Cos(a), pt.Y + hw*math32.Sin(a)})
+	}
+	return append(out, pt.Sub(n))
+}
+
+// PathPolysData returns path data for the polylines, with lines from point
+// to point, and closed polylines closed with a close path command
+func PathPolysData(polys []PathPoly) []PathData {
+	var data []PathData
+	for _, pl := range polys {
+		if len(pl.Pts) == 0 {
+			continue
+		}
+		data = append(data, PcM.EncCmd(2), PathData(pl.Pts[0].X), PathData(pl.Pts[0].Y))
+		if len(pl.Pts) > 1 {
+			data = append(data, PcL.EncCmd(2*(len(pl.Pts)-1)))
+			for _, pt := range pl.Pts[1:] {
+				data = append(data, PathData(pt.X), PathData(pt.Y))
+			}
+		}
+		if pl.Closed {
+			data = append(data, PcZ.EncCmd(0))
+		}
+	}
+	return data
+}
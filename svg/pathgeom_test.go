@@ -0,0 +1,141 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/goki/gi/gi"
+)
+
+// polysArea returns the net area of the closed polylines, from their signed
+// areas, so that holes oriented opposite to their outline are subtracted
+func polysArea(polys []PathPoly) float32 {
+	var a float32
+	for _, pl := range polys {
+		for i, p := range pl.Pts {
+			q := pl.Pts[(i+1)%len(pl.Pts)]
+			a += p.X*q.Y - q.X*p.Y
+		}
+	}
+	return math32.Abs(0.5 * a)
+}
+
+// parsePath returns the path data for given path string
+func parsePath(t *testing.T, d string) []PathData {
+	t.Helper()
+	data, err := PathDataParse(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// near returns true if the values are equal to within tolerance
+func near(a, b, tol float32) bool {
+	return math32.Abs(a-b) <= tol
+}
+
+func TestPathDataFlatten(t *testing.T) {
+	polys := PathDataFlatten(parsePath(t, "M 0 0 L 10 0 L 10 10 Z M 20 20 L 30 20 l 0 5"), 0)
+	if len(polys) != 2 {
+		t.Fatalf("expected 2 subpaths, got %d", len(polys))
+	}
+	if !polys[0].Closed || len(polys[0].Pts) != 3 {
+		t.Errorf("first subpath: %+v", polys[0])
+	}
+	if polys[1].Closed || len(polys[1].Pts) != 3 || polys[1].Pts[2] != (gi.Vec2D{30, 25}) {
+		t.Errorf("second subpath: %+v", polys[1])
+	}
+
+	tol := float32(0.01)
+	arc := PathDataFlatten(parsePath(t, "M 10 0 A 10 10 0 0 1 0 10"), tol)
+	if len(arc) != 1 || len(arc[0].Pts) < 4 {
+		t.Fatalf("arc not flattened into lines: %+v", arc)
+	}
+	pts := arc[0].Pts
+	if pts[0] != (gi.Vec2D{10, 0}) || !near(pts[len(pts)-1].X, 0, 1e-4) || !near(pts[len(pts)-1].Y, 10, 1e-4) {
+		t.Errorf("arc end points: %v, %v", pts[0], pts[len(pts)-1])
+	}
+	for i := 1; i < len(pts); i++ {
+		if r := pts[i].Length(); !near(r, 10, 1e-3) {
+			t.Errorf("arc point %v is off the circle: %v", i, r)
+		}
+		if r := pts[i-1].Add(pts[i]).MulVal(0.5).Length(); r < 10-tol-1e-4 {
+			t.Errorf("arc line %v is further than the tolerance from the circle: %v", i, r)
+		}
+	}
+
+	cub := PathDataFlatten(parsePath(t, "M 0 0 C 0 10 10 10 10 0 Q 15 -10 20 0"), 0)
+	if len(cub) != 1 || len(cub[0].Pts) < 6 {
+		t.Fatalf("curves not flattened into lines: %+v", cub)
+	}
+	if end := cub[0].Pts[len(cub[0].Pts)-1]; !near(end.X, 20, 1e-4) || !near(end.Y, 0, 1e-4) {
+		t.Errorf("curves end at %v", end)
+	}
+}
+
+func TestPathMeasurePointAt(t *testing.T) {
+	pm := NewPathMeasure(parsePath(t, "M 0 0 L 10 0 L 10 10 L 0 10 Z"), gi.Identity2D())
+	if pm.Length() != 40 {
+		t.Errorf("length %v, want 40", pm.Length())
+	}
+	tests := []struct {
+		dist float32
+		pt   gi.Vec2D
+		ang  float32
+	}{
+		{0, gi.Vec2D{0, 0}, 0},
+		{5, gi.Vec2D{5, 0}, 0},
+		{15, gi.Vec2D{10, 5}, math32.Pi / 2},
+		{25, gi.Vec2D{5, 10}, math32.Pi},
+		{40, gi.Vec2D{0, 0}, -math32.Pi / 2},
+	}
+	for _, ts := range tests {
+		pt, ang, ok := pm.PointAt(ts.dist)
+		if !ok || !near(pt.X, ts.pt.X, 1e-4) || !near(pt.Y, ts.pt.Y, 1e-4) || !near(ang, ts.ang, 1e-4) {
+			t.Errorf("PointAt(%v) = %v, %v, %v, want %v, %v", ts.dist, pt, ang, ok, ts.pt, ts.ang)
+		}
+	}
+	for _, dist := range []float32{-1, 41} {
+		if _, _, ok := pm.PointAt(dist); ok {
+			t.Errorf("PointAt(%v) is off the path, but ok", dist)
+		}
+	}
+
+	pm = NewPathMeasure(parsePath(t, "M 0 0 L 10 0 M 20 0 L 30 0"), gi.Scale2D(2, 2))
+	if pm.Length() != 40 {
+		t.Errorf("scaled subpaths: length %v, want 40", pm.Length())
+	}
+	if pt, _, ok := pm.PointAt(30); !ok || !near(pt.X, 50, 1e-4) || pt.Y != 0 {
+		t.Errorf("scaled subpaths: PointAt(30) = %v, %v", pt, ok)
+	}
+}
+
+func TestPathDataStroke(t *testing.T) {
+	tests := []struct {
+		d     string
+		width float32
+		lcap  gi.LineCap
+		join  gi.LineJoin
+		area  float32
+		tol   float32
+	}{
+		{"M 0 0 L 10 0", 2, gi.LineCapButt, gi.LineJoinMiter, 20, 1e-3},
+		{"M 0 0 L 10 0", 2, gi.LineCapSquare, gi.LineJoinMiter, 24, 1e-3},
+		{"M 0 0 L 10 0", 2, gi.LineCapRound, gi.LineJoinMiter, 20 + math32.Pi, 2 * math32.Pi * PathFlattenTol}, // caps within the tolerance of the circle
+		{"M 0 0 L 10 0 L 10 10", 2, gi.LineCapButt, gi.LineJoinMiter, 40, 1e-3},                                // 39 + corner
+		{"M 0 0 L 10 0 L 10 10", 2, gi.LineCapButt, gi.LineJoinBevel, 39.5, 1e-3},
+		{"M 0 0 L 10 0 L 10 10 L 0 10 Z", 2, gi.LineCapButt, gi.LineJoinMiter, 144 - 64, 1e-3},
+		{"M 0 0 L 10 0", 0, gi.LineCapButt, gi.LineJoinMiter, 0, 0},
+	}
+	for _, ts := range tests {
+		data := PathDataStroke(parsePath(t, ts.d), ts.width, ts.lcap, ts.join, 4)
+		if a := polysArea(PathDataFlatten(data, 0)); !near(a, ts.area, ts.tol) {
+			t.Errorf("stroke of %q, width %v, %v, %v: area %v, want %v", ts.d, ts.width, ts.lcap, ts.join, a, ts.area)
+		}
+	}
+}
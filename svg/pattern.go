@@ -0,0 +1,157 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/chewxy/math32"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+	"github.com/srwiley/rasterx"
+)
+
+// Pattern is a paint server that fills or strokes the elements referring to
+// it with fill="url(#name)" or stroke="url(#name)" with its contents,
+// repeated in tiles -- it is a gi.Patterner, used through gi.ColorSpec.
+// Tiles are assumed to be axis-aligned after transformation.
+type Pattern struct {
+	NodeBase
+	Pos          gi.Vec2D    `xml:"{x,y}" desc:"position of the first tile, in Units"`
+	Size         gi.Vec2D    `xml:"{width,height}" desc:"size of the tiles, in Units"`
+	Units        ClipUnits   `xml:"patternUnits" desc:"coordinate system for the tile position and size"`
+	ContentUnits ClipUnits   `xml:"patternContentUnits" desc:"coordinate system for the contents of the pattern, if there is no ViewBox"`
+	ViewBox      ViewBox     `desc:"viewbox defines the coordinate system for the contents of the pattern, mapped into each tile, if set"`
+	PatXForm     gi.Matrix2D `xml:"patternTransform" desc:"transform from the pattern coordinate system to the user space of the element"`
+	tile         *image.RGBA `desc:"cached rendering of one tile"`
+	tileXForm    gi.Matrix2D `desc:"transform that the cached tile was rendered with"`
+}
+
+var KiT_Pattern = kit.Types.AddType(&Pattern{}, nil)
+
+// Defaults sets the svg defaults: tiles in units of the bounding box of the
+// element, and contents in user space
+func (pt *Pattern) Defaults() {
+	pt.Units = ClipObjectBBox
+	pt.ContentUnits = ClipUserSpace
+	pt.PatXForm = gi.Identity2D()
+}
+
+// Render2D does nothing -- patterns are only rendered for the elements that
+// refer to them, by PatternColor
+func (pt *Pattern) Render2D() {
+}
+
+// PatternColor returns a rasterx.ColorFunc that tiles the pattern, for an
+// element with given bounding box, rendered with given transform, at given
+// opacity -- satisfies the gi.Patterner interface
+func (pt *Pattern) PatternColor(opacity float32, bounds image.Rectangle, xform gi.Matrix2D) interface{} {
+	if pt.Size.X == 0 || pt.Size.Y == 0 {
+		return color.Transparent
+	}
+	pxf := pt.PatXForm
+	if pxf == (gi.Matrix2D{}) { // not set
+		pxf = gi.Identity2D()
+	}
+	// tile space (units) and content space to image coordinates
+	sxf := pxf.Multiply(ClipUnitsXForm(pt.Units, xform, bounds))
+	org := sxf.TransformPointVec2D(pt.Pos)
+	tsz := sxf.TransformVectorVec2D(pt.Size)
+	var cxf gi.Matrix2D
+	switch {
+	case !pt.ViewBox.Size.IsZero():
+		cxf = pt.ViewBox.XForm(pt.Pos, pt.Size).Multiply(sxf)
+	default:
+		cxf = pxf.Multiply(ClipUnitsXForm(pt.ContentUnits, xform, bounds))
+		cor := cxf.TransformPointVec2D(gi.Vec2DZero) // contents are relative to the tile
+		cxf.X0 += org.X - cor.X
+		cxf.Y0 += org.Y - cor.Y
+	}
+	tw, th := math32.Abs(tsz.X), math32.Abs(tsz.Y)
+	if tsz.X < 0 {
+		org.X -= tw
+	}
+	if tsz.Y < 0 {
+		org.Y -= th
+	}
+	iw, ih := int(math32.Ceil(tw)), int(math32.Ceil(th))
+	if iw < 1 || ih < 1 {
+		return color.Transparent
+	}
+	cxf.X0 -= org.X
+	cxf.Y0 -= org.Y
+	tile := pt.renderTile(iw, ih, cxf)
+	return rasterx.ColorFunc(func(x, y int) color.Color {
+		tx := math32.Mod(float32(x)-org.X, tw)
+		if tx < 0 {
+			tx += tw
+		}
+		ty := math32.Mod(float32(y)-org.Y, th)
+		if ty < 0 {
+			ty += th
+		}
+		c := tile.RGBAAt(int(tx), int(ty))
+		if opacity < 1 { // premultiplied
+			c.R = uint8(float32(c.R) * opacity)
+			c.G = uint8(float32(c.G) * opacity)
+			c.B = uint8(float32(c.B) * opacity)
+			c.A = uint8(float32(c.A) * opacity)
+		}
+		return c
+	})
+}
+
+// renderTile returns the contents of the pattern rendered into an image of
+// given size with given transform, using the cached tile if it was rendered
+// the same way.  The contents are rendered into a separate viewport, as the
+// render state of the svg is in use while filling.
+func (pt *Pattern) renderTile(w, h int, xf gi.Matrix2D) *image.RGBA {
+	if pt.tile != nil && pt.tile.Bounds().Dx() == w && pt.tile.Bounds().Dy() == h && pt.tileXForm == xf {
+		return pt.tile
+	}
+	if pt.Viewport == nil {
+		pt.This().(gi.Node2D).Init2D()
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	vp := &gi.Viewport2D{}
+	vp.InitName(vp, "pattern-tile")
+	vp.Geom.Size = img.Bounds().Size()
+	vp.Pixels = img
+	vp.Render.Init(w, h, img)
+	vp.Render.Bounds = img.Bounds()
+	vp.Render.XForm = xf
+
+	pvps := make(map[*gi.Node2DBase]*gi.Viewport2D)
+	pt.FuncDownMeFirst(0, nil, func(k ki.Ki, level int, d interface{}) bool {
+		if k == pt.This() {
+			return true
+		}
+		gii, nb := gi.KiToNode2D(k)
+		if nb == nil {
+			return false
+		}
+		if nb.Viewport == nil {
+			gii.Init2D()
+		}
+		pvps[nb] = nb.Viewport
+		nb.Viewport = vp
+		return true
+	})
+	pt.Render2DChildren()
+	for nb, pvp := range pvps {
+		nb.Viewport = pvp
+	}
+	pt.tile = img
+	pt.tileXForm = xf
+	return img
+}
+
+// ClearTile clears the cached rendering of the pattern tile -- call after
+// changing the contents of the pattern
+func (pt *Pattern) ClearTile() {
+	pt.tile = nil
+}
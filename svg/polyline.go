@@ -28,6 +28,7 @@ func (g *Polyline) Render2D() {
 	}
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.PushXForm(pc.XForm)
 	pc.DrawPolyline(rs, g.Points)
 	pc.FillStrokeClear(rs)
@@ -56,5 +57,8 @@ func (g *Polyline) Render2D() {
 	}
 
 	g.Render2DChildren()
+	if clip {
+		g.PopClipMask()
+	}
 	rs.PopXForm()
 }
@@ -25,6 +25,7 @@ func (g *Rect) Render2D() {
 	}
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.PushXForm(pc.XForm)
 	if g.Radius.X == 0 && g.Radius.Y == 0 {
 		pc.DrawRectangle(rs, g.Pos.X, g.Pos.Y, g.Size.X, g.Size.Y)
@@ -35,5 +36,8 @@ func (g *Rect) Render2D() {
 	pc.FillStrokeClear(rs)
 	g.ComputeBBoxSVG()
 	g.Render2DChildren()
+	if clip {
+		g.PopClipMask()
+	}
 	rs.PopXForm()
 }
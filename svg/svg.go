@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
 	"github.com/goki/ki/kit"
 )
 
@@ -83,7 +84,11 @@ func (svg *SVG) Init2D() {
 
 func (svg *SVG) Size2D(iter int) {
 	svg.InitLayout2D()
-	if svg.ViewBox.Size != gi.Vec2DZero {
+	switch {
+	case svg.Viewport == nil && svg.Norm && svg.Geom.Size != image.ZP:
+		// top-level normalized svg keeps the size it was given, e.g., offscreen
+		svg.LayData.AllocSize.SetPoint(svg.Geom.Size)
+	case svg.ViewBox.Size != gi.Vec2DZero:
 		svg.LayData.AllocSize = svg.ViewBox.Size
 	}
 	svg.Size2DAddSpace()
@@ -147,6 +152,23 @@ func (svg *SVG) FindNamedElement(name string) gi.Node2D {
 		return def.(gi.Node2D)
 	}
 
+	// any other element in the svg, e.g., for use, but not in nested svgs
+	var fnd gi.Node2D
+	svg.FuncDownMeFirst(0, nil, func(k ki.Ki, level int, d interface{}) bool {
+		if fnd != nil {
+			return false
+		}
+		if k.Name() == name && k != svg.This() {
+			fnd, _ = gi.KiToNode2D(k)
+			return false
+		}
+		_, nested := k.(*SVG)
+		return !nested || k == svg.This()
+	})
+	if fnd != nil {
+		return fnd
+	}
+
 	if svg.Par == nil {
 		log.Printf("gi.SVG FindNamedElement: could not find name: %v\n", name)
 		return nil
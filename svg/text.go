@@ -9,111 +9,401 @@ import (
 
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/units"
-	"github.com/goki/ki/ints"
 	"github.com/goki/ki/kit"
 )
 
-// Text renders SVG text-- it handles both text and tspan elements (a tspan is
-// just nested under a parent text)
+// Text renders SVG text -- it handles text, tspan and textPath elements: a
+// tspan or textPath is nested under its parent text, and the outermost text
+// element lays out and renders the characters of all of its spans together,
+// each in its own style
 type Text struct {
 	NodeBase
-	Pos          gi.Vec2D      `xml:"{x,y}" desc:"position of the left, baseline of the text"`
-	Width        float32       `xml:"width" desc:"width of text to render if using word-wrapping"`
-	Text         string        `xml:"text" desc:"text string to render"`
-	Render       gi.TextRender `xml:"-" json:"-" desc:"render version of text"`
-	CharPosX     []float32     `desc:"character positions along X axis, if specified"`
-	CharPosY     []float32     `desc:"character positions along Y axis, if specified"`
-	CharPosDX    []float32     `desc:"character delta-positions along X axis, if specified"`
-	CharPosDY    []float32     `desc:"character delta-positions along Y axis, if specified"`
-	CharRots     []float32     `desc:"character rotations, if specified"`
-	TextLength   float32       `desc:"author's computed text length, if specified -- we attempt to match"`
-	AdjustGlyphs bool          `desc:"in attempting to match TextLength, should we adjust glyphs in addition to spacing?"`
+	Pos          gi.Vec2D        `xml:"{x,y}" desc:"position of the left, baseline of the text"`
+	Width        float32         `xml:"width" desc:"width of text to render if using word-wrapping"`
+	Text         string          `xml:"text" desc:"text string to render"`
+	Render       gi.TextRender   `xml:"-" json:"-" desc:"render version of text"`
+	CharPosX     []float32       `desc:"character positions along X axis, if specified -- for a span, a single value sets the position of its first character"`
+	CharPosY     []float32       `desc:"character positions along Y axis, if specified -- for a span, a single value sets the position of its first character"`
+	CharPosDX    []float32       `desc:"character delta-positions along X axis, if specified"`
+	CharPosDY    []float32       `desc:"character delta-positions along Y axis, if specified"`
+	CharRots     []float32       `desc:"character rotations, if specified"`
+	TextLength   float32         `desc:"author's computed text length, if specified -- we attempt to match"`
+	AdjustGlyphs bool            `desc:"in attempting to match TextLength, should we adjust glyphs in addition to spacing?"`
+	bbox         image.Rectangle `desc:"bounding box of the characters of this element, as last rendered"`
 }
 
 var KiT_Text = kit.Types.AddType(&Text{}, nil)
 
+// TextNode is implemented by Text and the types that embed it, which can all
+// be spans within a text element
+type TextNode interface {
+	// AsText returns the Text of the node
+	AsText() *Text
+}
+
+func (g *Text) AsText() *Text {
+	return g
+}
+
+// IsSpan returns true if this is a span within another text element, which
+// lays it out and renders it
+func (g *Text) IsSpan() bool {
+	if g.Parent() == nil {
+		return false
+	}
+	_, ok := g.Parent().(TextNode)
+	return ok
+}
+
 func (g *Text) BBox2D() image.Rectangle {
-	rs := &g.Viewport.Render
-	// todo: could be much more accurate..
-	return g.Pnt.BoundingBox(rs, g.Pos.X, g.Pos.Y, g.Pos.X+g.Render.Size.X, g.Pos.Y+g.Render.Size.Y)
+	return g.bbox
 }
 
 func (g *Text) Render2D() {
 	if g.Viewport == nil {
 		g.This().(gi.Node2D).Init2D()
 	}
+	if g.IsSpan() { // rendered by the outermost text
+		return
+	}
 	pc := &g.Pnt
 	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
 	rs.PushXForm(pc.XForm)
-	if len(g.Text) > 0 {
-		orgsz := pc.FontStyle.Size
-		pos := rs.XForm.TransformPointVec2D(gi.Vec2D{g.Pos.X, g.Pos.Y})
-		rot := rs.XForm.ExtractRot()
-		scx, scy := rs.XForm.ExtractScale()
-		scalex := scx / scy
-		if scalex == 1 {
-			scalex = 0
+	tl := &textLayout{}
+	tl.addElem(g, nil)
+	if len(tl.glyphs) > 0 {
+		tl.applyCharPos()
+		tl.position(g.Pos)
+		tl.render(rs)
+	}
+	for _, te := range tl.elems {
+		te.txt.ComputeBBoxSVG()
+	}
+	if clip {
+		g.PopClipMask()
+	}
+	rs.PopXForm()
+}
+
+// BaselineOffset returns the offset of the alphabetic baseline from the
+// position of the text, for its alignment-baseline property, or the
+// dominant-baseline property of it or its enclosing text elements, for a font
+// with given ascent and descent
+func (g *Text) BaselineOffset(asc, desc float32) float32 {
+	bl, ok := g.Props["alignment-baseline"]
+	for t := g; !ok; {
+		bl, ok = t.Props["dominant-baseline"]
+		if ok || !t.IsSpan() {
+			break
 		}
+		t = t.Parent().(TextNode).AsText()
+	}
+	bs, _ := bl.(string)
+	switch bs {
+	case "middle", "central":
+		return 0.5 * (asc - desc)
+	case "mathematical":
+		return 0.5 * asc
+	case "hanging", "text-before-edge", "text-top":
+		return asc
+	case "text-after-edge", "text-bottom", "ideographic":
+		return -desc
+	}
+	return 0
+}
+
+// TextPath renders the text within it along the path referred to by Href --
+// it is a span within a text element
+type TextPath struct {
+	Text
+	Href        string  `xml:"href" desc:"url of the path to lay out the text along, e.g., #name"`
+	StartOffset float32 `xml:"startOffset" desc:"distance along the path to start the text at -- a proportion of the length of the path if StartPct"`
+	StartPct    bool    `desc:"StartOffset is a proportion of the length of the path, from a percentage"`
+}
+
+var KiT_TextPath = kit.Types.AddType(&TextPath{}, nil)
+
+// RefPath returns the path referred to by Href, or nil if not found
+func (g *TextPath) RefPath() *Path {
+	if g.Href == "" {
+		return nil
+	}
+	nd := g.FindSVGURL(g.Href)
+	if nd == nil {
+		return nil
+	}
+	p, _ := nd.(*Path)
+	return p
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//  Layout
+
+// textGlyph is the layout of one character of a text element
+type textGlyph struct {
+	run    *Text           // element the character belongs to
+	idx    int             // index of the character in run.Render
+	adv    float32         // advance to the next character
+	asc    float32         // font ascent
+	desc   float32         // font descent
+	shift  float32         // baseline offset and shift of the run
+	path   *textPathLayout // path the character is laid out along, if any
+	x, y   float32         // absolute position, if xSet, ySet
+	xSet   bool            // absolute x position is set
+	ySet   bool            // absolute y position is set
+	dx, dy float32         // relative position
+	rot    float32         // rotation from rotate lists, in degrees
+	pos    gi.Vec2D        // pen position -- along the path, if on a path
+	hide   bool            // not rendered -- off the end of its path
+}
+
+// textElem is a text element within a text layout, with the range of glyphs
+// of it and its spans
+type textElem struct {
+	txt    *Text
+	st, ed int
+}
+
+// textLayout lays out a text element and its spans
+type textLayout struct {
+	glyphs []textGlyph
+	elems  []textElem // elements in document order
+}
+
+// addElem adds the text element, its glyphs, and those of its spans, in
+// document order -- characters within a textPath are laid out along its path
+func (tl *textLayout) addElem(g *Text, tp *textPathLayout) {
+	ei := len(tl.elems)
+	tl.elems = append(tl.elems, textElem{txt: g, st: len(tl.glyphs)})
+	g.bbox = image.ZR
+	pc := &g.Pnt
+	if len(g.Text) > 0 {
 		pc.FontStyle.OpenFont(&pc.UnContext) // use original size font
 		if !pc.FillStyle.Color.IsNil() {
 			pc.FontStyle.Color = pc.FillStyle.Color.Color
 		}
-		g.Render.SetString(g.Text, &pc.FontStyle, &pc.UnContext, &pc.TextStyle, true, rot, scalex)
-		g.Render.Size = g.Render.Size.Mul(gi.Vec2D{scx, scy})
-		if gi.IsAlignMiddle(pc.TextStyle.Align) || pc.TextStyle.Anchor == gi.AnchorMiddle {
-			pos.X -= g.Render.Size.X * .5
-		} else if gi.IsAlignEnd(pc.TextStyle.Align) || pc.TextStyle.Anchor == gi.AnchorEnd {
-			pos.X -= g.Render.Size.X
+		g.Render.SetString(g.Text, &pc.FontStyle, &pc.UnContext, &pc.TextStyle, true, 0, 0)
+		mt := pc.FontStyle.Face.Metrics()
+		asc, desc := gi.FixedToFloat32(mt.Ascent), gi.FixedToFloat32(mt.Descent)
+		shift := g.BaselineOffset(asc, desc)
+		switch pc.FontStyle.Shift {
+		case gi.ShiftSuper:
+			shift -= 0.45 * asc
+		case gi.ShiftSub:
+			shift += 0.15 * asc
 		}
-		pc.FontStyle.Size = units.Value{orgsz.Val * scy, orgsz.Un, orgsz.Dots * scy} // rescale by y
-		pc.FontStyle.OpenFont(&pc.UnContext)
 		sr := &(g.Render.Spans[0])
-		sr.Render[0].Face = pc.FontStyle.Face // upscale
+		sz := len(sr.Render)
 		for i := range sr.Render {
-			sr.Render[i].RelPos = rs.XForm.TransformVectorVec2D(sr.Render[i].RelPos)
-			sr.Render[i].Size.Y *= scy
-			sr.Render[i].Size.X *= scx
-		}
-		pc.FontStyle.Size = orgsz
-		if len(g.CharPosX) > 0 {
-			mx := ints.MinInt(len(g.CharPosX), len(sr.Render))
-			for i := 0; i < mx; i++ {
-				// todo: this may not be fully correct, given relativity constraints
-				sr.Render[i].RelPos.X, _ = rs.XForm.TransformVector(g.CharPosX[i], 0)
+			adv := sr.LastPos.X - sr.Render[i].RelPos.X
+			if i < sz-1 {
+				adv = sr.Render[i+1].RelPos.X - sr.Render[i].RelPos.X
 			}
+			tl.glyphs = append(tl.glyphs, textGlyph{run: g, idx: i, adv: adv, asc: asc, desc: desc, shift: shift, path: tp})
+		}
+	} else {
+		g.Render.Spans = nil
+	}
+	for _, kid := range *g.Children() {
+		tn, ok := kid.(TextNode)
+		if !ok {
+			continue
 		}
-		if len(g.CharPosY) > 0 {
-			mx := ints.MinInt(len(g.CharPosY), len(sr.Render))
-			for i := 0; i < mx; i++ {
-				_, sr.Render[i].RelPos.Y = rs.XForm.TransformPoint(g.CharPosY[i], 0)
+		ktp := tp
+		if kp, ok := kid.(*TextPath); ok {
+			ktp = nil
+			if p := kp.RefPath(); p != nil {
+				ktp = newTextPathLayout(p)
+				ktp.start = kp.StartOffset
+				if kp.StartPct {
+					ktp.start *= ktp.Length()
+				}
 			}
 		}
-		if len(g.CharPosDX) > 0 {
-			mx := ints.MinInt(len(g.CharPosDX), len(sr.Render))
-			for i := 0; i < mx; i++ {
-				dx, _ := rs.XForm.TransformVector(g.CharPosDX[i], 0)
-				if i > 0 {
-					sr.Render[i].RelPos.X = sr.Render[i-1].RelPos.X + dx
+		tl.addElem(tn.AsText(), ktp)
+	}
+	tl.elems[ei].ed = len(tl.glyphs)
+}
+
+// applyCharPos applies the position and rotation lists of each element to
+// the characters of it and its spans -- lists of spans override those of the
+// elements enclosing them, and the last rotation applies to all remaining
+// characters
+func (tl *textLayout) applyCharPos() {
+	for _, te := range tl.elems {
+		g := te.txt
+		gl := tl.glyphs[te.st:te.ed]
+		for i := range gl {
+			gg := &gl[i]
+			if i < len(g.CharPosX) {
+				gg.x, gg.xSet = g.CharPosX[i], true
+			}
+			if i < len(g.CharPosY) {
+				gg.y, gg.ySet = g.CharPosY[i], true
+			}
+			if i < len(g.CharPosDX) {
+				gg.dx = g.CharPosDX[i]
+			}
+			if i < len(g.CharPosDY) {
+				gg.dy = g.CharPosDY[i]
+			}
+			if nr := len(g.CharRots); nr > 0 {
+				if i < nr {
+					gg.rot = g.CharRots[i]
 				} else {
-					sr.Render[i].RelPos.X = dx // todo: not sure this is right
+					gg.rot = g.CharRots[nr-1]
 				}
 			}
 		}
-		if len(g.CharPosDY) > 0 {
-			mx := ints.MinInt(len(g.CharPosDY), len(sr.Render))
-			for i := 0; i < mx; i++ {
-				dy, _ := rs.XForm.TransformVector(g.CharPosDY[i], 0)
-				if i > 0 {
-					sr.Render[i].RelPos.Y = sr.Render[i-1].RelPos.Y + dy
-				} else {
-					sr.Render[i].RelPos.Y = dy // todo: not sure this is right
+	}
+}
+
+// position sets the pen positions of all the characters, starting at given
+// position, and aligns each chunk of text according to its text-anchor --
+// a new chunk starts at each absolute position, and at the start and end of
+// each path
+func (tl *textLayout) position(start gi.Vec2D) {
+	pen := start
+	var curPath *textPathLayout
+	chst := 0
+	for i := range tl.glyphs {
+		gg := &tl.glyphs[i]
+		if gg.path != curPath {
+			tl.anchorChunk(chst, i)
+			chst = i
+			switch {
+			case gg.path != nil:
+				pen = gi.Vec2D{gg.path.start, 0}
+			case curPath != nil: // continue from the end of the text on the path
+				if pt, _, ok := curPath.PointAt(pen.X); ok {
+					pen = pt
+				} else if np := len(curPath.Pts); np > 0 {
+					pen = curPath.Pts[np-1]
 				}
 			}
+			curPath = gg.path
+		}
+		if gg.xSet || (gg.ySet && gg.path == nil) {
+			tl.anchorChunk(chst, i)
+			chst = i
+		}
+		if gg.xSet {
+			pen.X = gg.x
 		}
-		// todo: TextLength, AdjustGlyphs -- also svg2 at least supports word wrapping!
-		g.Render.Render(rs, pos)
-		g.ComputeBBoxSVG()
+		if gg.ySet && gg.path == nil { // y does not apply along a path
+			pen.Y = gg.y
+		}
+		pen.X += gg.dx
+		pen.Y += gg.dy
+		gg.pos = pen
+		pen.X += gg.adv
 	}
-	g.Render2DChildren()
-	rs.PopXForm()
+	tl.anchorChunk(chst, len(tl.glyphs))
+}
+
+// anchorChunk aligns the chunk of characters in given range according to the
+// text-anchor or text-align of the first one
+func (tl *textLayout) anchorChunk(st, ed int) {
+	if ed <= st {
+		return
+	}
+	ts := &tl.glyphs[st].run.Pnt.TextStyle
+	lg := &tl.glyphs[ed-1]
+	wd := lg.pos.X + lg.adv - tl.glyphs[st].pos.X
+	var off float32
+	if gi.IsAlignMiddle(ts.Align) || ts.Anchor == gi.AnchorMiddle {
+		off = -wd * .5
+	} else if gi.IsAlignEnd(ts.Align) || ts.Anchor == gi.AnchorEnd {
+		off = -wd
+	}
+	if off == 0 {
+		return
+	}
+	for i := st; i < ed; i++ {
+		tl.glyphs[i].pos.X += off
+	}
+}
+
+// render sets the render positions of all the characters from their layout,
+// through the current transform, and renders each element
+func (tl *textLayout) render(rs *gi.RenderState) {
+	xrot := rs.XForm.ExtractRot()
+	scx, scy := rs.XForm.ExtractScale()
+	scalex := scx / scy
+	if scalex == 1 {
+		scalex = 0
+	}
+	for i := range tl.glyphs {
+		gg := &tl.glyphs[i]
+		var pos gi.Vec2D
+		rot := gi.Radians(gg.rot)
+		if gg.path != nil {
+			mid, ang, ok := gg.path.PointAt(gg.pos.X + 0.5*gg.adv)
+			if !ok {
+				gg.hide = true
+				continue
+			}
+			pos = mid.Add(gi.Rotate2D(ang).TransformVectorVec2D(gi.Vec2D{-0.5 * gg.adv, gg.pos.Y + gg.shift}))
+			rot += ang
+		} else {
+			pos = gi.Vec2D{gg.pos.X, gg.pos.Y + gg.shift}
+		}
+		rr := &(gg.run.Render.Spans[0].Render[gg.idx])
+		rr.RelPos = rs.XForm.TransformPointVec2D(pos)
+		rr.RotRad = xrot + rot
+		rr.ScaleX = scalex
+		rr.Size.X *= scx
+		rr.Size.Y *= scy
+
+		gxf := gi.Rotate2D(rot)
+		var min, max gi.Vec2D
+		for ci, c := range []gi.Vec2D{{0, gg.desc}, {gg.adv, gg.desc}, {0, -gg.asc}, {gg.adv, -gg.asc}} {
+			cp := rs.XForm.TransformPointVec2D(pos.Add(gxf.TransformVectorVec2D(c)))
+			if ci == 0 {
+				min, max = cp, cp
+			} else {
+				min, max = min.Min(cp), max.Max(cp)
+			}
+		}
+		bb := image.Rectangle{min.ToPointFloor(), max.ToPointCeil()}
+		gg.run.bbox = gg.run.bbox.Union(bb)
+		for _, te := range tl.elems { // enclosing elements
+			if te.txt != gg.run && te.st <= i && i < te.ed {
+				te.txt.bbox = te.txt.bbox.Union(bb)
+			}
+		}
+	}
+	for _, gg := range tl.glyphs {
+		if gg.hide {
+			gg.run.Render.Spans[0].Text[gg.idx] = 0 // not printable -- skipped
+		}
+	}
+	// todo: TextLength, AdjustGlyphs -- also svg2 at least supports word wrapping!
+	for _, te := range tl.elems {
+		g := te.txt
+		if len(g.Render.Spans) == 0 {
+			continue
+		}
+		pc := &g.Pnt
+		orgsz := pc.FontStyle.Size
+		pc.FontStyle.Size = units.Value{orgsz.Val * scy, orgsz.Un, orgsz.Dots * scy} // rescale by y
+		pc.FontStyle.OpenFont(&pc.UnContext)
+		sr := &(g.Render.Spans[0])
+		sr.Render[0].Face = pc.FontStyle.Face // upscale
+		sr.RelPos = gi.Vec2DZero
+		pc.FontStyle.Size = orgsz
+		g.Render.Render(rs, gi.Vec2DZero)
+	}
+}
+
+// textPathLayout is a path measured for laying out text along it
+type textPathLayout struct {
+	*PathMeasure
+	start float32 // offset along the path to start the text at
+}
+
+// newTextPathLayout measures the given path, in the coordinates of its parent
+func newTextPathLayout(p *Path) *textPathLayout {
+	return &textPathLayout{PathMeasure: NewPathMeasure(p.Data, pathXForm(p))}
 }
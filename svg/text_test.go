@@ -0,0 +1,271 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"image"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/oswin"
+	"github.com/goki/gi/oswin/driver/offscreen"
+)
+
+// readText renders given svg text elements, of size 200x200, returning the
+// svg and the text element with id t
+func readText(t *testing.T, txt string) (*SVG, *Text) {
+	t.Helper()
+	offscreen.Main(func(app oswin.App) {
+		gi.FontLibrary.InitFontPaths(app.FontPaths()...)
+	})
+	sv := readSVG(t, []byte(`<svg width="200" height="200" viewBox="0 0 200 200">`+txt+`</svg>`))
+	renderPixels(&sv.Viewport2D, image.Point{200, 200})
+	tn, ok := sv.FindNamedElement("t").(TextNode)
+	if !ok {
+		t.Fatalf("text t not found in: %v", txt)
+	}
+	return sv, tn.AsText()
+}
+
+// layoutText lays out and renders the characters of given text element again,
+// returning the layout -- the svg has no transform, so render positions are
+// in the same coordinates as the layout
+func layoutText(g *Text) *textLayout {
+	tl := &textLayout{}
+	tl.addElem(g, nil)
+	tl.applyCharPos()
+	tl.position(g.Pos)
+	tl.render(&g.Viewport.Render)
+	return tl
+}
+
+// glyphRend returns the render of the glyph, as set by rendering
+func glyphRend(gg *textGlyph) *gi.RuneRender {
+	return &gg.run.Render.Spans[0].Render[gg.idx]
+}
+
+func nearf(a, b float32) bool {
+	return math32.Abs(a-b) < 0.01
+}
+
+// checkFlow checks that each character in given range starts where the one
+// before it ends
+func checkFlow(t *testing.T, name string, tl *textLayout, st, ed int) {
+	t.Helper()
+	for i := st + 1; i < ed; i++ {
+		pg, gg := &tl.glyphs[i-1], &tl.glyphs[i]
+		if !nearf(gg.pos.X, pg.pos.X+pg.adv) {
+			t.Errorf("%v: char %v at x %v, want %v after char %v", name, i, gg.pos.X, pg.pos.X+pg.adv, i-1)
+		}
+	}
+}
+
+func TestTextSpans(t *testing.T) {
+	sv, g := readText(t, `<text id="t" x="10" y="40" font-size="20" fill="red">ab<tspan id="s" fill="blue">cd</tspan><tspan id="s2" font-size="10">ef</tspan>g</text>`)
+	s := sv.FindNamedElement("s").(*Text)
+	s2 := sv.FindNamedElement("s2").(*Text)
+	red, blue := gi.Color{255, 0, 0, 255}, gi.Color{0, 0, 255, 255}
+	if g.Pnt.FontStyle.Color != red || s.Pnt.FontStyle.Color != blue || s2.Pnt.FontStyle.Color != red {
+		t.Errorf("colors: text %v, span %v, span inheriting %v -- want red, blue, red", g.Pnt.FontStyle.Color, s.Pnt.FontStyle.Color, s2.Pnt.FontStyle.Color)
+	}
+	if sz, sz2 := g.Pnt.FontStyle.Size.Dots, s2.Pnt.FontStyle.Size.Dots; !nearf(sz2, sz/2) || s.Pnt.FontStyle.Size.Dots != sz {
+		t.Errorf("font sizes: text %v, span %v, smaller span %v", sz, s.Pnt.FontStyle.Size.Dots, sz2)
+	}
+
+	tl := layoutText(g)
+	if len(tl.glyphs) != 7 {
+		t.Fatalf("%v chars laid out, want 7", len(tl.glyphs))
+	}
+	runs := []*Text{g, g, s, s, s2, s2}
+	for i, r := range runs {
+		if tl.glyphs[i].run != r {
+			t.Errorf("char %v in %v, want %v", i, tl.glyphs[i].run.Name(), r.Name())
+		}
+	}
+	if last := tl.glyphs[6].run; last.Parent() != g.This() || last.Pnt.FontStyle.Size.Dots != g.Pnt.FontStyle.Size.Dots {
+		t.Errorf("text after the spans not in a span of the text style")
+	}
+	if tl.glyphs[0].pos != (gi.Vec2D{10, 40}) {
+		t.Errorf("first char at %v, want (10, 40)", tl.glyphs[0].pos)
+	}
+	checkFlow(t, "spans", tl, 0, 7)
+	if !nearf(tl.glyphs[4].adv, tl.glyphs[1].adv/2) || tl.glyphs[4].asc >= tl.glyphs[1].asc {
+		t.Errorf("smaller span laid out in the text font: adv %v, asc %v", tl.glyphs[4].adv, tl.glyphs[4].asc)
+	}
+
+	// each span has its own render, and the text bbox encloses them in order
+	if len(s.Render.Spans) != 1 || string(s.Render.Spans[0].Text) != "cd" {
+		t.Fatalf("span render: %+v", s.Render.Spans)
+	}
+	if rr := glyphRend(&tl.glyphs[2]); !nearf(rr.RelPos.X, tl.glyphs[2].pos.X) || !nearf(rr.RelPos.Y, 40) {
+		t.Errorf("span char rendered at %v, want %v", rr.RelPos, tl.glyphs[2].pos)
+	}
+	if !s.BBox.In(g.BBox) || !s2.BBox.In(g.BBox) || s.BBox.Max.X > s2.BBox.Min.X+1 {
+		t.Errorf("bboxes: text %v, span %v, span %v", g.BBox, s.BBox, s2.BBox)
+	}
+}
+
+func TestTextCharPos(t *testing.T) {
+	_, g := readText(t, `<text id="t" x="10 30" y="50" dx="0 5" dy="1 2 3" rotate="10 20" font-size="20">abcd</text>`)
+	tl := layoutText(g)
+	if len(tl.glyphs) != 4 {
+		t.Fatalf("%v chars laid out, want 4", len(tl.glyphs))
+	}
+	want := []struct {
+		x, y, rot float32
+	}{
+		{10, 51, 10},
+		{35, 53, 20},
+		{35 + tl.glyphs[1].adv, 56, 20},
+		{35 + tl.glyphs[1].adv + tl.glyphs[2].adv, 56, 20}, // last rotation continues
+	}
+	for i, w := range want {
+		gg := &tl.glyphs[i]
+		if !nearf(gg.pos.X, w.x) || !nearf(gg.pos.Y, w.y) || gg.rot != w.rot {
+			t.Errorf("char %v at %v rotated %v, want (%v, %v) rotated %v", i, gg.pos, gg.rot, w.x, w.y, w.rot)
+		}
+		if rr := glyphRend(gg); !nearf(rr.RotRad, gi.Radians(w.rot)) {
+			t.Errorf("char %v rendered rotated %v, want %v", i, rr.RotRad, gi.Radians(w.rot))
+		}
+	}
+
+	// lists of spans override those of the text for their characters, and
+	// a single x in a span sets the position of its first character
+	_, g = readText(t, `<text id="t" x="10" y="50" rotate="5" font-size="20">ab<tspan rotate="30 40" dy="4">cd</tspan><tspan x="100">e</tspan>f</text>`)
+	tl = layoutText(g)
+	if len(tl.glyphs) != 6 {
+		t.Fatalf("%v chars laid out, want 6", len(tl.glyphs))
+	}
+	rots := []float32{5, 5, 30, 40, 5, 5}
+	ys := []float32{50, 50, 54, 54, 54, 54}
+	for i, gg := range tl.glyphs {
+		if gg.rot != rots[i] || !nearf(gg.pos.Y, ys[i]) {
+			t.Errorf("span char %v at y %v rotated %v, want %v rotated %v", i, gg.pos.Y, gg.rot, ys[i], rots[i])
+		}
+	}
+	checkFlow(t, "spans", tl, 0, 4)
+	if tl.glyphs[4].pos.X != 100 {
+		t.Errorf("span x: char at %v, want 100", tl.glyphs[4].pos.X)
+	}
+	checkFlow(t, "after x", tl, 4, 6)
+}
+
+func TestTextAnchor(t *testing.T) {
+	tests := []struct {
+		name, attrs string
+		off         float32 // of the start of the text, in widths
+	}{
+		{"start", `text-anchor="start"`, 0},
+		{"middle", `text-anchor="middle"`, -0.5},
+		{"end", `text-anchor="end"`, -1},
+	}
+	for _, tt := range tests {
+		_, g := readText(t, `<text id="t" x="100" y="50" font-size="20" `+tt.attrs+`>ab<tspan fill="blue">cd</tspan></text>`)
+		tl := layoutText(g)
+		var wd float32
+		for _, gg := range tl.glyphs {
+			wd += gg.adv
+		}
+		if got, want := tl.glyphs[0].pos.X, 100+tt.off*wd; !nearf(got, want) {
+			t.Errorf("%v: text starts at %v, want %v", tt.name, got, want)
+		}
+		checkFlow(t, tt.name, tl, 0, 4)
+	}
+
+	// each absolute position starts a new chunk, anchored on its own
+	_, g := readText(t, `<text id="t" x="100 150" y="50" font-size="20" text-anchor="end">ab</text>`)
+	tl := layoutText(g)
+	for i, x := range []float32{100, 150} {
+		if gg := tl.glyphs[i]; !nearf(gg.pos.X+gg.adv, x) {
+			t.Errorf("chunk %v ends at %v, want %v", i, gg.pos.X+gg.adv, x)
+		}
+	}
+}
+
+func TestTextBaseline(t *testing.T) {
+	_, g := readText(t, `<text id="t" x="10" y="50" font-size="20">a</text>`)
+	gg := layoutText(g).glyphs[0]
+	asc, desc := gg.asc, gg.desc
+	if gg.shift != 0 || asc <= 0 || desc <= 0 {
+		t.Fatalf("alphabetic: shift %v, ascent %v, descent %v", gg.shift, asc, desc)
+	}
+	tests := []struct {
+		name, txt string
+		shifts    []float32
+	}{
+		{"middle", `<text id="t" x="10" y="50" font-size="20" dominant-baseline="middle">a</text>`,
+			[]float32{0.5 * (asc - desc)}},
+		{"hanging", `<text id="t" x="10" y="50" font-size="20" dominant-baseline="hanging">a</text>`,
+			[]float32{asc}},
+		{"inherited", `<text id="t" x="10" y="50" font-size="20" dominant-baseline="hanging">a<tspan>b</tspan></text>`,
+			[]float32{asc, asc}},
+		{"span alignment", `<text id="t" x="10" y="50" font-size="20" dominant-baseline="hanging">a<tspan alignment-baseline="text-after-edge">b</tspan></text>`,
+			[]float32{asc, -desc}},
+		{"super", `<text id="t" x="10" y="50" font-size="20">a<tspan baseline-shift="super">b</tspan></text>`,
+			[]float32{0, -0.45 * asc}},
+		{"sub", `<text id="t" x="10" y="50" font-size="20">a<tspan baseline-shift="sub">b</tspan></text>`,
+			[]float32{0, 0.15 * asc}},
+	}
+	for _, tt := range tests {
+		_, g := readText(t, tt.txt)
+		tl := layoutText(g)
+		for i, sh := range tt.shifts {
+			gg := &tl.glyphs[i]
+			if !nearf(gg.shift, sh) {
+				t.Errorf("%v: char %v shift %v, want %v", tt.name, i, gg.shift, sh)
+			}
+			if rr := glyphRend(gg); !nearf(rr.RelPos.Y, 50+sh) {
+				t.Errorf("%v: char %v rendered at y %v, want %v", tt.name, i, rr.RelPos.Y, 50+sh)
+			}
+		}
+	}
+}
+
+func TestTextPath(t *testing.T) {
+	tests := []struct {
+		name, path, offset string
+		x, y, rot          float32 // of the first character
+	}{
+		{"horizontal", "M10 50 L190 50", "20", 30, 50, 0},
+		{"percent", "M10 50 L190 50", "50%", 100, 50, 0},
+		{"vertical", "M50 10 L50 190", "20", 50, 30, 90},
+	}
+	for _, tt := range tests {
+		_, g := readText(t, `<path id="p" d="`+tt.path+`"/>
+			<text id="t" font-size="20"><textPath href="#p" startOffset="`+tt.offset+`">abc</textPath></text>`)
+		tl := layoutText(g)
+		if len(tl.glyphs) != 3 {
+			t.Fatalf("%v: %v chars laid out, want 3", tt.name, len(tl.glyphs))
+		}
+		checkFlow(t, tt.name, tl, 0, 3) // along the path
+		rr := glyphRend(&tl.glyphs[0])
+		if !nearf(rr.RelPos.X, tt.x) || !nearf(rr.RelPos.Y, tt.y) || !nearf(rr.RotRad, gi.Radians(tt.rot)) {
+			t.Errorf("%v: first char at %v rotated %v, want (%v, %v) rotated %v", tt.name, rr.RelPos, rr.RotRad, tt.x, tt.y, gi.Radians(tt.rot))
+		}
+	}
+
+	// text after a path continues from the end of the text on it
+	_, g := readText(t, `<path id="p" d="M10 60 L190 60"/>
+		<text id="t" x="0" y="0" font-size="20"><textPath href="#p" startOffset="20">ab</textPath>c</text>`)
+	tl := layoutText(g)
+	if gc := tl.glyphs[2]; gc.path != nil || !nearf(gc.pos.X, 30+tl.glyphs[0].adv+tl.glyphs[1].adv) || !nearf(gc.pos.Y, 60) {
+		t.Errorf("text after path at %v, want continuing from the end of the text on it", gc.pos)
+	}
+
+	// characters past the end of the path are hidden, and text after them
+	// continues from the end of the path
+	_, g = readText(t, `<path id="p" d="M10 50 L50 50"/>
+		<text id="t" font-size="20"><textPath href="#p" startOffset="20">abcd</textPath>e</text>`)
+	tl = layoutText(g)
+	tp := g.Kids[0].(*TextPath)
+	txt := tp.Render.Spans[0].Text
+	if txt[0] == 0 || txt[len(txt)-1] != 0 {
+		t.Errorf("chars past the end of the path not hidden: %q", string(txt))
+	}
+	if ge := tl.glyphs[4]; ge.path != nil || ge.pos != (gi.Vec2D{50, 50}) {
+		t.Errorf("text after path at %v, want (50, 50)", ge.pos)
+	}
+}
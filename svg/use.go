@@ -0,0 +1,108 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"image"
+	"log"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+)
+
+// Use renders another element of the svg, referred to by Href, at its own
+// position -- if the element is a Symbol, it is scaled into the Size of the
+// Use, according to the Symbol ViewBox
+type Use struct {
+	NodeBase
+	Href  string   `xml:"href" desc:"url of the element to render, e.g., #name"`
+	Pos   gi.Vec2D `xml:"{x,y}" desc:"position to render the element at -- translates it"`
+	Size  gi.Vec2D `xml:"{width,height}" desc:"size of the Symbol or SVG element to render -- zero uses its own size"`
+	inUse bool     `desc:"currently rendering -- prevents infinite recursion for elements that use themselves"`
+}
+
+var KiT_Use = kit.Types.AddType(&Use{}, nil)
+
+// Ref returns the element referred to by Href, or nil if not found
+func (g *Use) Ref() gi.Node2D {
+	if g.Href == "" {
+		return nil
+	}
+	return g.FindSVGURL(g.Href)
+}
+
+func (g *Use) BBox2D() image.Rectangle {
+	if ref := g.Ref(); ref != nil {
+		return ref.AsNode2D().BBox
+	}
+	return image.ZR
+}
+
+func (g *Use) Render2D() {
+	if g.Viewport == nil {
+		g.This().(gi.Node2D).Init2D()
+	}
+	ref := g.Ref()
+	if ref == nil {
+		return
+	}
+	if g.inUse {
+		log.Printf("svg.Use: %v uses itself\n", g.PathUnique())
+		return
+	}
+	pc := &g.Pnt
+	rs := &g.Viewport.Render
+	clip := g.PushClipMask()
+	rs.PushXFormLock(gi.Translate2D(g.Pos.X, g.Pos.Y).Multiply(pc.XForm))
+	rn := ref.AsNode2D() // keeps its own bbox, e.g., for selecting it where it is
+	bb, obb, vpbb, wbb := rn.BBox, rn.ObjBBox, rn.VpBBox, rn.WinBBox
+	g.inUse = true
+	if sy, ok := ref.(*Symbol); ok {
+		sy.RenderSymbol(g.Size)
+	} else {
+		ref.Render2D()
+	}
+	g.inUse = false
+	g.ComputeBBoxSVG() // from the bbox of the ref as rendered here
+	rn.BBox, rn.ObjBBox, rn.VpBBox, rn.WinBBox = bb, obb, vpbb, wbb
+	if clip {
+		g.PopClipMask()
+	}
+	rs.PopXFormLock()
+}
+
+// Symbol holds elements that are only rendered by a Use element referring to
+// it, scaled into the size of the Use according to its ViewBox
+type Symbol struct {
+	Group
+	ViewBox ViewBox  `desc:"viewbox defines the coordinate system for the elements within the symbol"`
+	Size    gi.Vec2D `xml:"{width,height}" desc:"default size to render the symbol at, if the Use does not specify one -- zero uses the ViewBox size"`
+}
+
+var KiT_Symbol = kit.Types.AddType(&Symbol{}, nil)
+
+// Render2D does nothing -- symbols are only rendered by the Use elements
+// that refer to them, with RenderSymbol
+func (sy *Symbol) Render2D() {
+}
+
+// RenderSymbol renders the elements of the symbol into given size, with the
+// current transform -- zero uses the Size of the symbol
+func (sy *Symbol) RenderSymbol(size gi.Vec2D) {
+	if sy.Viewport == nil {
+		sy.This().(gi.Node2D).Init2D()
+	}
+	if size.IsZero() {
+		size = sy.Size
+	}
+	if size.IsZero() {
+		size = sy.ViewBox.Size
+	}
+	rs := &sy.Viewport.Render
+	rs.PushXFormLock(sy.ViewBox.XForm(gi.Vec2DZero, size))
+	sy.Render2DChildren()
+	sy.ComputeBBoxSVG()
+	rs.PopXFormLock()
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018, The GoKi Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"image"
+	"testing"
+
+	"github.com/goki/gi/gi"
+)
+
+const useSVG = `<svg width="100" height="100" viewBox="0 0 100 100">
+  <rect id="box" x="10" y="10" width="20" height="20" fill="#f00"/>
+  <use id="copy" href="#box" x="50" y="50"/>
+</svg>
+`
+
+func TestUseBBox(t *testing.T) {
+	sv := readSVG(t, []byte(useSVG))
+	renderPixels(&sv.Viewport2D, image.Point{100, 100})
+	box := sv.FindNamedElement("box").AsNode2D()
+	cp := sv.FindNamedElement("copy").AsNode2D()
+	if box.BBox.Min.X >= 50 {
+		t.Errorf("bbox of the used element was left at the use: %v", box.BBox)
+	}
+	if cp.BBox.Min.X < 50 {
+		t.Errorf("bbox of the use is not where it rendered: %v", cp.BBox)
+	}
+}
+
+// renderPixels renders the viewport into pixels of given size -- there is no
+// oswin app to Resize with
+func renderPixels(vp *gi.Viewport2D, sz image.Point) {
+	vp.Pixels = image.NewRGBA(image.Rectangle{Max: sz})
+	vp.Render.Init(sz.X, sz.Y, vp.Pixels)
+	vp.Geom.Size = sz
+	vp.FullRender2DTree()
+}
@@ -4,7 +4,11 @@
 
 package svg
 
-import "github.com/goki/gi/gi"
+import (
+	"strings"
+
+	"github.com/goki/gi/gi"
+)
 
 ////////////////////////////////////////////////////////////////////////////////////////
 // ViewBox defines the SVG viewbox
@@ -64,3 +68,100 @@ type ViewBoxPreserveAspectRatio struct {
 	Align       ViewBoxAlign       `svg:"align" desc:"how to align x,y coordinates within viewbox"`
 	MeetOrSlice ViewBoxMeetOrSlice `svg:"meetOrSlice" desc:"how to scale the view box relative to the viewport"`
 }
+
+// SetString sets the preserveAspectRatio from its svg attribute value, e.g.,
+// "xMidYMid meet" or "none"
+func (pa *ViewBoxPreserveAspectRatio) SetString(s string) {
+	pa.Align = XMid | YMid
+	pa.MeetOrSlice = Meet
+	for _, f := range strings.Fields(s) {
+		switch {
+		case f == "none":
+			pa.Align = NoAlign
+		case f == "meet":
+			pa.MeetOrSlice = Meet
+		case f == "slice":
+			pa.MeetOrSlice = Slice
+		case len(f) == 8 && f[0] == 'x' && f[4] == 'Y':
+			pa.Align = 0
+			switch f[1:4] {
+			case "Min":
+				pa.Align |= XMin
+			case "Mid":
+				pa.Align |= XMid
+			case "Max":
+				pa.Align |= XMax
+			}
+			switch f[5:8] {
+			case "Min":
+				pa.Align |= YMin
+			case "Mid":
+				pa.Align |= YMid
+			case "Max":
+				pa.Align |= YMax
+			}
+		}
+	}
+}
+
+// SVGString returns the svg attribute value of the preserveAspectRatio
+func (pa *ViewBoxPreserveAspectRatio) SVGString() string {
+	if pa.Align&NoAlign != 0 {
+		return "none"
+	}
+	s := "xMid"
+	switch {
+	case pa.Align&XMin != 0:
+		s = "xMin"
+	case pa.Align&XMax != 0:
+		s = "xMax"
+	}
+	switch {
+	case pa.Align&YMin != 0:
+		s += "YMin"
+	case pa.Align&YMax != 0:
+		s += "YMax"
+	default:
+		s += "YMid"
+	}
+	if pa.MeetOrSlice == Slice {
+		s += " slice"
+	}
+	return s
+}
+
+// XForm returns the transform that maps the view box into a viewport at
+// given position and of given size, scaling and aligning according to
+// PreserveAspectRatio
+func (vb *ViewBox) XForm(pos, size gi.Vec2D) gi.Matrix2D {
+	if vb.Size.X == 0 || vb.Size.Y == 0 {
+		return gi.Translate2D(pos.X, pos.Y)
+	}
+	sx := size.X / vb.Size.X
+	sy := size.Y / vb.Size.Y
+	pa := &vb.PreserveAspectRatio
+	if pa.Align&NoAlign == 0 {
+		if (pa.MeetOrSlice == Meet) == (sx < sy) {
+			sy = sx
+		} else {
+			sx = sy
+		}
+	}
+	tx := pos.X - vb.Min.X*sx
+	ty := pos.Y - vb.Min.Y*sy
+	ex := size.X - vb.Size.X*sx // extra space to align within
+	ey := size.Y - vb.Size.Y*sy
+	switch {
+	case pa.Align&XMid != 0:
+		tx += 0.5 * ex
+	case pa.Align&XMax != 0:
+		tx += ex
+	}
+	switch {
+	case pa.Align&YMid != 0:
+		ty += 0.5 * ey
+	case pa.Align&YMax != 0:
+		ty += ey
+	}
+	return gi.Matrix2D{sx, 0, 0, sy, tx, ty}
+}
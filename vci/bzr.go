@@ -0,0 +1,364 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/vcs"
+)
+
+type BzrRepo struct {
+	vcs.Repo
+	FilesAll        map[string]struct{}
+	FilesModified   map[string]struct{}
+	FilesAdded      map[string]struct{}
+	FilesConflicted map[string]struct{}
+}
+
+// runCmd runs bzr with given args in the top directory of the repo,
+// returning its output -- errors include what bzr reported
+func (br *BzrRepo) runCmd(args ...string) ([]byte, error) {
+	return br.runCmdOk(0, args...)
+}
+
+// runCmdOk is runCmd where the given exit code is also a success, for
+// commands that report a result in their exit code, e.g., 1 from bzr diff
+// when there are differences -- it is checked before the error is replaced
+// with what bzr reported
+func (br *BzrRepo) runCmdOk(code int, args ...string) ([]byte, error) {
+	cmd := exec.Command("bzr", args...)
+	cmd.Dir = br.LocalPath()
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok {
+		if code != 0 && ee.ExitCode() == code {
+			return out, nil
+		}
+		if len(ee.Stderr) > 0 {
+			err = fmt.Errorf("bzr %v: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+		}
+	}
+	return out, err
+}
+
+// cacheFiles returns the set of file names, one per line in the output of
+// bzr with given args, relative to the top of the repo
+func (br *BzrRepo) cacheFiles(size int, args ...string) map[string]struct{} {
+	files := make(map[string]struct{}, size)
+	out, _ := br.runCmd(args...)
+	for _, n := range strings.Split(string(out), "\n") {
+		files[filepath.ToSlash(strings.TrimSuffix(n, "/"))] = struct{}{} // directories end in /
+	}
+	return files
+}
+
+func (br *BzrRepo) CacheFileNames() {
+	br.FilesAll = br.cacheFiles(1000, "ls", "--recursive", "--versioned")
+}
+
+func (br *BzrRepo) CacheFilesModified() {
+	br.FilesModified = br.cacheFiles(100, "modified")
+}
+
+func (br *BzrRepo) CacheFilesAdded() {
+	br.FilesAdded = br.cacheFiles(100, "added")
+}
+
+func (br *BzrRepo) CacheFilesConflicted() {
+	br.FilesConflicted = br.cacheFiles(10, "conflicts", "--text")
+}
+
+func (br *BzrRepo) CacheRefresh() {
+	br.CacheFileNames()
+	br.CacheFilesAdded()
+	br.CacheFilesModified()
+	br.CacheFilesConflicted()
+}
+
+// relPath returns the path of the file relative to the top of the repo, as
+// bzr reports it
+func (br *BzrRepo) relPath(filename string) string {
+	if !filepath.IsAbs(filename) {
+		return filename
+	}
+	rel, err := filepath.Rel(br.LocalPath(), filename)
+	if err != nil {
+		return filename
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (br *BzrRepo) InRepo(filename string) bool {
+	if len(br.FilesAll) == 0 {
+		br.CacheFileNames()
+	}
+	_, has := br.FilesAll[br.relPath(filename)]
+	return has
+}
+
+func (br *BzrRepo) IsModified(filename string) bool {
+	if br.FilesModified == nil {
+		br.CacheFilesModified()
+	}
+	_, has := br.FilesModified[br.relPath(filename)]
+	return has
+}
+
+func (br *BzrRepo) IsAdded(filename string) bool {
+	if br.FilesAdded == nil {
+		br.CacheFilesAdded()
+	}
+	_, has := br.FilesAdded[br.relPath(filename)]
+	return has
+}
+
+func (br *BzrRepo) IsConflicted(filename string) bool {
+	if br.FilesConflicted == nil {
+		br.CacheFilesConflicted()
+	}
+	_, has := br.FilesConflicted[br.relPath(filename)]
+	return has
+}
+
+// ConflictVersions returns the base, ours and theirs versions of a file with
+// merge conflicts, from the .BASE, .THIS and .OTHER files that bzr leaves
+// next to it
+func (br *BzrRepo) ConflictVersions(filename string) (base, ours, theirs []byte, err error) {
+	fn := filename
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Join(br.LocalPath(), fn)
+	}
+	base, _ = ioutil.ReadFile(fn + ".BASE") // missing if no common ancestor
+	if ours, err = ioutil.ReadFile(fn + ".THIS"); err != nil {
+		return nil, nil, nil, fmt.Errorf("vci: could not get our version of %v: %v", filename, err)
+	}
+	if theirs, err = ioutil.ReadFile(fn + ".OTHER"); err != nil {
+		return nil, nil, nil, fmt.Errorf("vci: could not get their version of %v: %v", filename, err)
+	}
+	return base, ours, theirs, nil
+}
+
+// MarkResolved marks the merge conflicts in the file as resolved, which also
+// removes the .BASE, .THIS and .OTHER files
+func (br *BzrRepo) MarkResolved(filename string) error {
+	if _, err := br.runCmd("resolve", filename); err != nil {
+		return err
+	}
+	br.CacheFilesConflicted()
+	br.CacheFilesModified()
+	return nil
+}
+
+// Add adds the file to the repo
+func (br *BzrRepo) Add(filename string) error {
+	if _, err := br.runCmd("add", filename); err != nil {
+		return err
+	}
+	br.CacheFileNames()
+	br.CacheFilesAdded()
+	return nil
+}
+
+// Move moves updates the repo with the rename
+func (br *BzrRepo) Move(oldpath, newpath string) error {
+	if _, err := br.runCmd("mv", oldpath, newpath); err != nil {
+		return err
+	}
+	br.CacheRefresh()
+	return nil
+}
+
+// Remove removes the file from the repo
+func (br *BzrRepo) Remove(filename string) error {
+	if _, err := br.runCmd("remove", filename); err != nil {
+		return err
+	}
+	br.CacheRefresh()
+	return nil
+}
+
+// RemoveKeepLocal removes the file from the repo but keeps the file itself
+func (br *BzrRepo) RemoveKeepLocal(filename string) error {
+	if _, err := br.runCmd("remove", "--keep", filename); err != nil {
+		return err
+	}
+	br.CacheRefresh()
+	return nil
+}
+
+// CommitFile commits a single file
+func (br *BzrRepo) CommitFile(filename string, message string) error {
+	if _, err := br.runCmd("commit", "-m", message, filename); err != nil {
+		return err
+	}
+	br.CacheRefresh()
+	return nil
+}
+
+// RevertFile reverts a single file to the last commit
+func (br *BzrRepo) RevertFile(filename string) error {
+	if _, err := br.runCmd("revert", "--no-backup", filename); err != nil {
+		return err
+	}
+	br.CacheFilesModified()
+	return nil
+}
+
+// bzrLogSep is the line that starts each commit in bzr log --long
+const bzrLogSep = "------------------------------------------------------------"
+
+// Log returns the history of commits of the file, most recent first -- the
+// whole repository if filename is empty
+func (br *BzrRepo) Log(filename string) (Log, error) {
+	args := []string{"log", "--long"}
+	if filename != "" {
+		args = append(args, br.relPath(filename))
+	}
+	out, err := br.runCmd(args...)
+	if err != nil {
+		return nil, err
+	}
+	var lg Log
+	for _, rec := range strings.Split(string(out), bzrLogSep) {
+		var cm *Commit
+		lns := strings.Split(rec, "\n")
+		for i, ln := range lns {
+			key := strings.TrimSpace(ln)
+			if key == "" {
+				continue
+			}
+			if cm == nil {
+				cm = &Commit{}
+			}
+			switch {
+			case strings.HasPrefix(key, "revno:"):
+				cm.Rev = strings.Fields(key)[1] // drop [merge] etc
+			case strings.HasPrefix(key, "author:"), strings.HasPrefix(key, "committer:") && cm.Author == "":
+				who := strings.TrimSpace(key[strings.Index(key, ":")+1:])
+				cm.Author, cm.Email = who, ""
+				if st := strings.Index(who, "<"); st >= 0 {
+					cm.Author = strings.TrimSpace(who[:st])
+					cm.Email = strings.Trim(who[st:], "<>")
+				}
+			case strings.HasPrefix(key, "timestamp:"):
+				cm.Date, _ = time.Parse("Mon 2006-01-02 15:04:05 -0700", strings.TrimSpace(key[len("timestamp:"):]))
+			case key == "message:":
+				for _, ml := range lns[i+1:] {
+					if ml = strings.TrimSpace(ml); ml != "" {
+						cm.Message = ml
+						break
+					}
+				}
+			}
+			if key == "message:" {
+				break
+			}
+		}
+		if cm != nil && cm.Rev != "" {
+			lg = append(lg, cm)
+		}
+	}
+	return lg, nil
+}
+
+// Blame returns the commit that last changed each line of the file, from
+// bzr annotate
+func (br *BzrRepo) Blame(filename string) (Blame, error) {
+	out, err := br.runCmd("annotate", "--all", "--long", br.relPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	lns := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	bl := make(Blame, 0, len(lns))
+	for _, ln := range lns {
+		bi := strings.Index(ln, " | ")
+		if bi < 0 {
+			continue
+		}
+		fs := strings.Fields(ln[:bi]) // revno, author, date
+		if len(fs) < 3 {
+			continue
+		}
+		dt, _ := time.Parse("20060102", fs[len(fs)-1])
+		bl = append(bl, BlameLine{Rev: fs[0], Author: strings.Join(fs[1:len(fs)-1], " "), Date: dt, Text: ln[bi+3:]})
+	}
+	return bl, nil
+}
+
+// Diff returns the differences between the file at the given revision and
+// its current contents, in unified diff format -- rev is the last commit if
+// empty
+func (br *BzrRepo) Diff(filename, rev string) ([]byte, error) {
+	args := []string{"diff"}
+	if rev != "" {
+		args = append(args, "-r", rev)
+	}
+	return br.runCmdOk(1, append(args, br.relPath(filename))...) // 1 = there are differences
+}
+
+// Show returns the contents of the file at the given revision -- rev is the
+// last commit if empty
+func (br *BzrRepo) Show(filename, rev string) ([]byte, error) {
+	args := []string{"cat"}
+	if rev != "" {
+		args = append(args, "-r", rev)
+	}
+	return br.runCmd(append(args, br.relPath(filename))...)
+}
+
+// LocalBranches is not supported for bzr, where each branch is a separate
+// directory
+func (br *BzrRepo) LocalBranches() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// SwitchBranch is not supported for bzr, where each branch is a separate
+// directory
+func (br *BzrRepo) SwitchBranch(branch string) error {
+	return ErrNotSupported
+}
+
+// Stash shelves all the uncommitted changes with the given message,
+// reverting the working tree
+func (br *BzrRepo) Stash(message string) error {
+	args := []string{"shelve", "--all"}
+	if message != "" {
+		args = append(args, "-m", message)
+	}
+	if _, err := br.runCmd(args...); err != nil {
+		return err
+	}
+	br.CacheRefresh()
+	return nil
+}
+
+// StashList returns the descriptions of the shelved changes, most recent first
+func (br *BzrRepo) StashList() ([]string, error) {
+	out, err := br.runCmdOk(1, "shelve", "--list") // 1 = nothing shelved
+	if err != nil {
+		return nil, err
+	}
+	var sl []string
+	for _, ln := range strings.Split(string(out), "\n") {
+		if ln = strings.TrimSpace(ln); ln != "" {
+			sl = append(sl, ln)
+		}
+	}
+	return sl, nil
+}
+
+// StashPop restores the most recently shelved changes, and removes the shelf
+func (br *BzrRepo) StashPop() error {
+	if _, err := br.runCmd("unshelve"); err != nil {
+		return err
+	}
+	br.CacheRefresh()
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tempBzrRepo makes a local bzr branch in a temporary directory, with two
+// commits of a.txt, returning it and a function that removes it
+func tempBzrRepo(t *testing.T) (*BzrRepo, func()) {
+	if _, err := exec.LookPath("bzr"); err != nil {
+		t.Skip("bzr not found")
+	}
+	tmp, err := ioutil.TempDir("", "vci-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmp, "work")
+	bzr := func(wd string, args ...string) {
+		cmd := exec.Command("bzr", args...)
+		cmd.Dir = wd
+		cmd.Env = append(os.Environ(), "BZR_EMAIL=Tester <tester@example.com>")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("bzr %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(wd, txt string) {
+		if err := ioutil.WriteFile(filepath.Join(wd, "a.txt"), []byte(txt), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// vcs.NewRepo requires a parent branch, so the commits are made in a
+	// branch of an initial one
+	base := filepath.Join(tmp, "base")
+	bzr(tmp, "init", "-q", base)
+	write(base, "one\ntwo\n")
+	bzr(base, "add", "-q", "a.txt")
+	bzr(base, "commit", "-q", "-m", "first")
+	bzr(tmp, "branch", "-q", base, dir)
+	bzr(dir, "whoami", "--branch", "Tester <tester@example.com>") // for commits by BzrRepo
+	write(dir, "one\n2\nthree\n")
+	bzr(dir, "commit", "-q", "-m", "second")
+	r, err := NewRepo("", dir)
+	if err != nil {
+		os.RemoveAll(tmp)
+		t.Fatal(err)
+	}
+	return r.(*BzrRepo), func() { os.RemoveAll(tmp) }
+}
+
+func TestBzrLogBlameShow(t *testing.T) {
+	br, done := tempBzrRepo(t)
+	defer done()
+	lg, err := br.Log("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lg) != 2 || lg[0].Rev != "2" || lg[1].Rev != "1" || lg[0].Message != "second" || lg[1].Message != "first" || lg[0].Author != "Tester" || lg[0].Date.IsZero() {
+		t.Fatalf("bad log: %+v", lg)
+	}
+	bl, err := br.Blame(filepath.Join(br.LocalPath(), "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bl) != 3 || bl[0].Rev != "1" || bl[1].Rev != "2" || bl[0].Text != "one" || bl[2].Text != "three" || bl[0].Date.IsZero() {
+		t.Fatalf("bad blame: %+v", bl)
+	}
+	txt, err := br.Show("a.txt", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(txt) != "one\ntwo\n" {
+		t.Fatalf("bad show: %q", txt)
+	}
+	df, err := br.Diff("a.txt", "")
+	if err != nil {
+		t.Fatalf("diff with no differences: %v", err)
+	}
+	if len(df) != 0 {
+		t.Fatalf("bad empty diff: %s", df)
+	}
+	ioutil.WriteFile(filepath.Join(br.LocalPath(), "a.txt"), []byte("one\n2\n3\n"), 0644)
+	df, err = br.Diff("a.txt", "")
+	if err != nil { // exits with 1 when there are differences
+		t.Fatalf("diff with differences: %v", err)
+	}
+	if !strings.Contains(string(df), "-three\n+3\n") {
+		t.Fatalf("bad diff: %s", df)
+	}
+	if _, err := br.Show("nonesuch.txt", ""); err == nil {
+		t.Fatal("expected error showing missing file")
+	}
+}
+
+func TestBzrStash(t *testing.T) {
+	br, done := tempBzrRepo(t)
+	defer done()
+	if _, err := br.LocalBranches(); err != ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, not: %v", err)
+	}
+	sl, err := br.StashList()
+	if err != nil { // exits with 1 when nothing is shelved
+		t.Fatalf("empty stash list: %v", err)
+	}
+	if len(sl) != 0 {
+		t.Fatalf("bad empty stash list: %v", sl)
+	}
+	fn := filepath.Join(br.LocalPath(), "a.txt")
+	ioutil.WriteFile(fn, []byte("changed\n"), 0644)
+	if err := br.Stash("wip"); err != nil {
+		t.Fatal(err)
+	}
+	if txt, _ := ioutil.ReadFile(fn); string(txt) != "one\n2\nthree\n" {
+		t.Fatalf("stash did not revert: %q", txt)
+	}
+	sl, err = br.StashList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sl) != 1 || !strings.Contains(sl[0], "wip") {
+		t.Fatalf("bad stash list: %v", sl)
+	}
+	if err := br.StashPop(); err != nil {
+		t.Fatal(err)
+	}
+	if txt, _ := ioutil.ReadFile(fn); string(txt) != "changed\n" {
+		t.Fatalf("stash pop did not restore: %q", txt)
+	}
+}
+
+func TestBzrFiles(t *testing.T) {
+	br, done := tempBzrRepo(t)
+	defer done()
+	testRepoFiles(t, br)
+}
@@ -7,16 +7,20 @@ package vci
 import (
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/vcs"
 )
 
 type GitRepo struct {
 	vcs.Repo
-	FilesAll      map[string]struct{}
-	FilesModified map[string]struct{}
-	FilesAdded    map[string]struct{}
+	FilesAll        map[string]struct{}
+	FilesModified   map[string]struct{}
+	FilesAdded      map[string]struct{}
+	FilesConflicted map[string]struct{}
 }
 
 func (gr *GitRepo) CacheFileNames() {
@@ -55,10 +59,23 @@ func (gr *GitRepo) CacheFilesAdded() {
 	}
 }
 
+func (gr *GitRepo) CacheFilesConflicted() {
+	gr.FilesConflicted = make(map[string]struct{}, 10)
+	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=U")
+	cmd.Dir = gr.LocalPath()
+	bytes, _ := cmd.Output()
+	sep := byte(10) // Linefeed is the separator - will this work cross platform?
+	names := strings.Split(string(bytes), string(sep))
+	for _, n := range names {
+		gr.FilesConflicted[n] = struct{}{}
+	}
+}
+
 func (gr *GitRepo) CacheRefresh() {
 	gr.CacheFileNames()
 	gr.CacheFilesAdded()
 	gr.CacheFilesModified()
+	gr.CacheFilesConflicted()
 }
 
 func (gr *GitRepo) InRepo(filename string) bool {
@@ -85,6 +102,60 @@ func (gr *GitRepo) IsAdded(filename string) bool {
 	return has
 }
 
+func (gr *GitRepo) IsConflicted(filename string) bool {
+	if gr.FilesConflicted == nil {
+		gr.CacheFilesConflicted()
+	}
+	_, has := gr.FilesConflicted[gr.relPath(filename)]
+	return has
+}
+
+// relPath returns the path of the file relative to the top of the repo, as
+// git reports it
+func (gr *GitRepo) relPath(filename string) string {
+	if !filepath.IsAbs(filename) {
+		return filename
+	}
+	rel, err := filepath.Rel(gr.LocalPath(), filename)
+	if err != nil {
+		return filename
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ConflictVersions returns the base, ours and theirs versions of a file with
+// merge conflicts, from stages 1, 2 and 3 of the index
+func (gr *GitRepo) ConflictVersions(filename string) (base, ours, theirs []byte, err error) {
+	rel := gr.relPath(filename)
+	show := func(stage int) ([]byte, error) {
+		cmd := exec.Command("git", "show", fmt.Sprintf(":%d:%s", stage, rel))
+		cmd.Dir = gr.LocalPath()
+		return cmd.Output()
+	}
+	base, _ = show(1) // missing if no common ancestor
+	if ours, err = show(2); err != nil {
+		return nil, nil, nil, fmt.Errorf("vci: could not get our version of %v: %v", rel, err)
+	}
+	if theirs, err = show(3); err != nil {
+		return nil, nil, nil, fmt.Errorf("vci: could not get their version of %v: %v", rel, err)
+	}
+	return base, ours, theirs, nil
+}
+
+// MarkResolved marks the merge conflicts in the file as resolved, by adding it
+func (gr *GitRepo) MarkResolved(filename string) error {
+	oscmd := exec.Command("git", "add", filename)
+	oscmd.Dir = gr.LocalPath()
+	stdoutStderr, err := oscmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("%s\n", stdoutStderr)
+		return err
+	}
+	gr.CacheFilesConflicted()
+	gr.CacheFilesModified()
+	return nil
+}
+
 // Add adds the file to the repo
 func (gr *GitRepo) Add(filename string) error {
 	oscmd := exec.Command("git", "add", filename)
@@ -159,3 +230,151 @@ func (gr *GitRepo) RevertFile(filename string) error {
 	gr.CacheFilesModified()
 	return nil
 }
+
+// runCmd runs git with given args in the top directory of the repo,
+// returning its output -- errors include what git reported
+func (gr *GitRepo) runCmd(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = gr.LocalPath()
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		err = fmt.Errorf("git %v: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+	}
+	return out, err
+}
+
+// Log returns the history of commits of the file, most recent first -- the
+// whole repository if filename is empty
+func (gr *GitRepo) Log(filename string) (Log, error) {
+	args := []string{"log", "--format=%H%x1f%aI%x1f%an%x1f%ae%x1f%s%x1e"}
+	if filename != "" {
+		args = append(args, "--follow", "--", gr.relPath(filename))
+	}
+	out, err := gr.runCmd(args...)
+	if err != nil {
+		return nil, err
+	}
+	var lg Log
+	for _, rec := range strings.Split(string(out), "\x1e") {
+		fs := strings.Split(strings.TrimSpace(rec), "\x1f")
+		if len(fs) < 5 {
+			continue
+		}
+		dt, _ := time.Parse(time.RFC3339, fs[1])
+		lg = append(lg, &Commit{Rev: fs[0], Date: dt, Author: fs[2], Email: fs[3], Message: fs[4]})
+	}
+	return lg, nil
+}
+
+// Blame returns the commit that last changed each line of the file, from
+// git blame --line-porcelain
+func (gr *GitRepo) Blame(filename string) (Blame, error) {
+	out, err := gr.runCmd("blame", "--line-porcelain", "--", gr.relPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	var bl Blame
+	var cur BlameLine
+	hdr := true
+	for _, ln := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(ln, "\t"):
+			cur.Text = ln[1:]
+			bl = append(bl, cur)
+			cur = BlameLine{}
+			hdr = true
+		case hdr:
+			if f := strings.Fields(ln); len(f) > 0 {
+				cur.Rev = f[0]
+				hdr = false
+			}
+		case strings.HasPrefix(ln, "author "):
+			cur.Author = strings.TrimPrefix(ln, "author ")
+		case strings.HasPrefix(ln, "author-time "):
+			if sec, err := strconv.ParseInt(strings.TrimPrefix(ln, "author-time "), 10, 64); err == nil {
+				cur.Date = time.Unix(sec, 0)
+			}
+		}
+	}
+	return bl, nil
+}
+
+// Diff returns the differences between the file at the given revision and
+// its current contents, in unified diff format -- rev is HEAD if empty
+func (gr *GitRepo) Diff(filename, rev string) ([]byte, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return gr.runCmd("diff", rev, "--", gr.relPath(filename))
+}
+
+// Show returns the contents of the file at the given revision -- rev is HEAD
+// if empty
+func (gr *GitRepo) Show(filename, rev string) ([]byte, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return gr.runCmd("show", rev+":"+gr.relPath(filename))
+}
+
+// LocalBranches returns the names of the local branches
+func (gr *GitRepo) LocalBranches() ([]string, error) {
+	out, err := gr.runCmd("branch", "--format=%(refname:short)")
+	if err != nil {
+		return nil, err
+	}
+	var brs []string
+	for _, br := range strings.Split(string(out), "\n") {
+		if br != "" {
+			brs = append(brs, br)
+		}
+	}
+	return brs, nil
+}
+
+// SwitchBranch checks out the given branch
+func (gr *GitRepo) SwitchBranch(branch string) error {
+	if _, err := gr.runCmd("checkout", branch); err != nil {
+		return err
+	}
+	gr.CacheRefresh()
+	return nil
+}
+
+// Stash saves the uncommitted changes with the given message, reverting the
+// working copy
+func (gr *GitRepo) Stash(message string) error {
+	args := []string{"stash", "push"}
+	if message != "" {
+		args = append(args, "-m", message)
+	}
+	if _, err := gr.runCmd(args...); err != nil {
+		return err
+	}
+	gr.CacheRefresh()
+	return nil
+}
+
+// StashList returns the descriptions of the saved stashes, most recent first
+func (gr *GitRepo) StashList() ([]string, error) {
+	out, err := gr.runCmd("stash", "list")
+	if err != nil {
+		return nil, err
+	}
+	var sl []string
+	for _, ln := range strings.Split(string(out), "\n") {
+		if ln != "" {
+			sl = append(sl, ln)
+		}
+	}
+	return sl, nil
+}
+
+// StashPop restores the most recently stashed changes, and removes the stash
+func (gr *GitRepo) StashPop() error {
+	if _, err := gr.runCmd("stash", "pop"); err != nil {
+		return err
+	}
+	gr.CacheRefresh()
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tempGitRepo makes a local git repository in a temporary directory, with
+// two commits of a.txt, returning it and a function that removes it
+func tempGitRepo(t *testing.T) (*GitRepo, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir, err := ioutil.TempDir("", "vci-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(txt string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte(txt), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q", "-b", "master")
+	git("config", "user.name", "Tester")
+	git("config", "user.email", "tester@example.com")
+	git("remote", "add", "origin", dir) // vcs.NewRepo requires a remote
+	write("one\ntwo\n")
+	git("add", "a.txt")
+	git("commit", "-q", "-m", "first")
+	write("one\n2\nthree\n")
+	git("commit", "-q", "-a", "-m", "second")
+	r, err := NewRepo("", dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return r.(*GitRepo), func() { os.RemoveAll(dir) }
+}
+
+func TestGitLogBlameShow(t *testing.T) {
+	gr, done := tempGitRepo(t)
+	defer done()
+	lg, err := gr.Log("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lg) != 2 || lg[0].Message != "second" || lg[1].Message != "first" || lg[0].Author != "Tester" || lg[0].Date.IsZero() {
+		t.Fatalf("bad log: %+v", lg)
+	}
+	bl, err := gr.Blame(filepath.Join(gr.LocalPath(), "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bl) != 3 || bl[0].Rev != lg[1].Rev || bl[1].Rev != lg[0].Rev || bl[2].Text != "three" || bl[0].Author != "Tester" {
+		t.Fatalf("bad blame: %+v", bl)
+	}
+	txt, err := gr.Show("a.txt", lg[1].Rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(txt) != "one\ntwo\n" {
+		t.Fatalf("bad show: %q", txt)
+	}
+	ioutil.WriteFile(filepath.Join(gr.LocalPath(), "a.txt"), []byte("one\n2\n3\n"), 0644)
+	df, err := gr.Diff("a.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(df), "-three\n+3\n") {
+		t.Fatalf("bad diff: %s", df)
+	}
+}
+
+func TestGitBranchesStash(t *testing.T) {
+	gr, done := tempGitRepo(t)
+	defer done()
+	if _, err := gr.runCmd("branch", "feature"); err != nil {
+		t.Fatal(err)
+	}
+	brs, err := gr.LocalBranches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(brs, " ") != "feature master" {
+		t.Fatalf("bad branches: %v", brs)
+	}
+	if err := gr.SwitchBranch("feature"); err != nil {
+		t.Fatal(err)
+	}
+	if cur, _ := gr.Current(); cur != "feature" {
+		t.Fatalf("bad current branch: %v", cur)
+	}
+	fn := filepath.Join(gr.LocalPath(), "a.txt")
+	ioutil.WriteFile(fn, []byte("changed\n"), 0644)
+	if err := gr.Stash("wip"); err != nil {
+		t.Fatal(err)
+	}
+	if txt, _ := ioutil.ReadFile(fn); string(txt) != "one\n2\nthree\n" {
+		t.Fatalf("stash did not revert: %q", txt)
+	}
+	sl, err := gr.StashList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sl) != 1 || !strings.Contains(sl[0], "wip") {
+		t.Fatalf("bad stash list: %v", sl)
+	}
+	if err := gr.StashPop(); err != nil {
+		t.Fatal(err)
+	}
+	if txt, _ := ioutil.ReadFile(fn); string(txt) != "changed\n" {
+		t.Fatalf("stash pop did not restore: %q", txt)
+	}
+	if err := gr.SwitchBranch("nonesuch"); err == nil {
+		t.Fatal("expected error switching to missing branch")
+	}
+}
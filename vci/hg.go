@@ -0,0 +1,340 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/vcs"
+)
+
+type HgRepo struct {
+	vcs.Repo
+	FilesAll        map[string]struct{}
+	FilesModified   map[string]struct{}
+	FilesAdded      map[string]struct{}
+	FilesConflicted map[string]struct{}
+}
+
+// runCmd runs hg with given args in the top directory of the repo,
+// returning its output -- errors include what hg reported
+func (hr *HgRepo) runCmd(args ...string) ([]byte, error) {
+	cmd := exec.Command("hg", args...)
+	cmd.Dir = hr.LocalPath()
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		err = fmt.Errorf("hg %v: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+	}
+	return out, err
+}
+
+// cacheFiles returns the set of file names, one per line in the output of
+// hg with given args, relative to the top of the repo
+func (hr *HgRepo) cacheFiles(size int, args ...string) map[string]struct{} {
+	files := make(map[string]struct{}, size)
+	out, _ := hr.runCmd(args...)
+	for _, n := range strings.Split(string(out), "\n") {
+		files[filepath.ToSlash(n)] = struct{}{}
+	}
+	return files
+}
+
+func (hr *HgRepo) CacheFileNames() {
+	hr.FilesAll = hr.cacheFiles(1000, "files")
+}
+
+func (hr *HgRepo) CacheFilesModified() {
+	hr.FilesModified = hr.cacheFiles(100, "status", "--modified", "--no-status")
+}
+
+func (hr *HgRepo) CacheFilesAdded() {
+	hr.FilesAdded = hr.cacheFiles(100, "status", "--added", "--no-status")
+}
+
+func (hr *HgRepo) CacheFilesConflicted() {
+	hr.FilesConflicted = make(map[string]struct{}, 10)
+	out, _ := hr.runCmd("resolve", "--list")
+	for _, ln := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(ln, "U ") { // U = unresolved, R = resolved
+			hr.FilesConflicted[filepath.ToSlash(ln[2:])] = struct{}{}
+		}
+	}
+}
+
+func (hr *HgRepo) CacheRefresh() {
+	hr.CacheFileNames()
+	hr.CacheFilesAdded()
+	hr.CacheFilesModified()
+	hr.CacheFilesConflicted()
+}
+
+// relPath returns the path of the file relative to the top of the repo, as
+// hg reports it
+func (hr *HgRepo) relPath(filename string) string {
+	if !filepath.IsAbs(filename) {
+		return filename
+	}
+	rel, err := filepath.Rel(hr.LocalPath(), filename)
+	if err != nil {
+		return filename
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (hr *HgRepo) InRepo(filename string) bool {
+	if len(hr.FilesAll) == 0 {
+		hr.CacheFileNames()
+	}
+	_, has := hr.FilesAll[hr.relPath(filename)]
+	return has
+}
+
+func (hr *HgRepo) IsModified(filename string) bool {
+	if hr.FilesModified == nil {
+		hr.CacheFilesModified()
+	}
+	_, has := hr.FilesModified[hr.relPath(filename)]
+	return has
+}
+
+func (hr *HgRepo) IsAdded(filename string) bool {
+	if hr.FilesAdded == nil {
+		hr.CacheFilesAdded()
+	}
+	_, has := hr.FilesAdded[hr.relPath(filename)]
+	return has
+}
+
+func (hr *HgRepo) IsConflicted(filename string) bool {
+	if hr.FilesConflicted == nil {
+		hr.CacheFilesConflicted()
+	}
+	_, has := hr.FilesConflicted[hr.relPath(filename)]
+	return has
+}
+
+// ConflictVersions returns the base, ours and theirs versions of a file with
+// merge conflicts, from the common ancestor and the two parents of the
+// working directory
+func (hr *HgRepo) ConflictVersions(filename string) (base, ours, theirs []byte, err error) {
+	rel := hr.relPath(filename)
+	base, _ = hr.runCmd("cat", "-r", "ancestor(p1(), p2())", rel) // missing if added on both
+	if ours, err = hr.runCmd("cat", "-r", "p1()", rel); err != nil {
+		return nil, nil, nil, fmt.Errorf("vci: could not get our version of %v: %v", rel, err)
+	}
+	if theirs, err = hr.runCmd("cat", "-r", "p2()", rel); err != nil {
+		return nil, nil, nil, fmt.Errorf("vci: could not get their version of %v: %v", rel, err)
+	}
+	return base, ours, theirs, nil
+}
+
+// MarkResolved marks the merge conflicts in the file as resolved
+func (hr *HgRepo) MarkResolved(filename string) error {
+	if _, err := hr.runCmd("resolve", "--mark", filename); err != nil {
+		return err
+	}
+	hr.CacheFilesConflicted()
+	hr.CacheFilesModified()
+	return nil
+}
+
+// Add adds the file to the repo
+func (hr *HgRepo) Add(filename string) error {
+	if _, err := hr.runCmd("add", filename); err != nil {
+		return err
+	}
+	hr.CacheFileNames()
+	hr.CacheFilesAdded()
+	return nil
+}
+
+// Move moves updates the repo with the rename
+func (hr *HgRepo) Move(oldpath, newpath string) error {
+	if _, err := hr.runCmd("mv", oldpath, newpath); err != nil {
+		return err
+	}
+	hr.CacheRefresh()
+	return nil
+}
+
+// Remove removes the file from the repo
+func (hr *HgRepo) Remove(filename string) error {
+	if _, err := hr.runCmd("remove", filename); err != nil {
+		return err
+	}
+	hr.CacheRefresh()
+	return nil
+}
+
+// RemoveKeepLocal removes the file from the repo but keeps the file itself
+func (hr *HgRepo) RemoveKeepLocal(filename string) error {
+	if _, err := hr.runCmd("forget", filename); err != nil {
+		return err
+	}
+	hr.CacheRefresh()
+	return nil
+}
+
+// CommitFile commits a single file
+func (hr *HgRepo) CommitFile(filename string, message string) error {
+	if _, err := hr.runCmd("commit", "-m", message, filename); err != nil {
+		return err
+	}
+	hr.CacheRefresh()
+	return nil
+}
+
+// RevertFile reverts a single file to the parent of the working directory
+func (hr *HgRepo) RevertFile(filename string) error {
+	if _, err := hr.runCmd("revert", "--no-backup", filename); err != nil {
+		return err
+	}
+	hr.CacheFilesModified()
+	return nil
+}
+
+// Log returns the history of commits of the file, most recent first -- the
+// whole repository if filename is empty
+func (hr *HgRepo) Log(filename string) (Log, error) {
+	args := []string{"log", "--template", `{node}\x1f{date|rfc3339date}\x1f{author|person}\x1f{author|email}\x1f{desc|firstline}\x1e`}
+	if filename != "" {
+		args = append(args, "--follow", hr.relPath(filename))
+	}
+	out, err := hr.runCmd(args...)
+	if err != nil {
+		return nil, err
+	}
+	var lg Log
+	for _, rec := range strings.Split(string(out), "\x1e") {
+		fs := strings.Split(strings.TrimSpace(rec), "\x1f")
+		if len(fs) < 5 {
+			continue
+		}
+		dt, _ := time.Parse(time.RFC3339, fs[1])
+		lg = append(lg, &Commit{Rev: fs[0], Date: dt, Author: fs[2], Email: fs[3], Message: fs[4]})
+	}
+	return lg, nil
+}
+
+// hgAnnotate is the json output of hg annotate -T json
+type hgAnnotate []struct {
+	Lines []struct {
+		Node string     `json:"node"`
+		User string     `json:"user"`
+		Date [2]float64 `json:"date"`
+		Line string     `json:"line"`
+	} `json:"lines"`
+}
+
+// Blame returns the commit that last changed each line of the file, from
+// hg annotate
+func (hr *HgRepo) Blame(filename string) (Blame, error) {
+	out, err := hr.runCmd("annotate", "-T", "json", "--user", "--date", "--changeset", hr.relPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	var ha hgAnnotate
+	if err := json.Unmarshal(out, &ha); err != nil {
+		return nil, err
+	}
+	if len(ha) == 0 {
+		return nil, nil
+	}
+	bl := make(Blame, len(ha[0].Lines))
+	for i, l := range ha[0].Lines {
+		bl[i] = BlameLine{Rev: l.Node, Author: l.User, Date: time.Unix(int64(l.Date[0]), 0), Text: strings.TrimRight(l.Line, "\r\n")}
+	}
+	return bl, nil
+}
+
+// Diff returns the differences between the file at the given revision and
+// its current contents, in unified diff format -- rev is the parent of the
+// working directory if empty
+func (hr *HgRepo) Diff(filename, rev string) ([]byte, error) {
+	if rev == "" {
+		rev = "."
+	}
+	return hr.runCmd("diff", "-r", rev, hr.relPath(filename))
+}
+
+// Show returns the contents of the file at the given revision -- rev is the
+// parent of the working directory if empty
+func (hr *HgRepo) Show(filename, rev string) ([]byte, error) {
+	if rev == "" {
+		rev = "."
+	}
+	return hr.runCmd("cat", "-r", rev, hr.relPath(filename))
+}
+
+// LocalBranches returns the names of the named branches
+func (hr *HgRepo) LocalBranches() ([]string, error) {
+	out, err := hr.runCmd("branches", "--quiet")
+	if err != nil {
+		return nil, err
+	}
+	var brs []string
+	for _, br := range strings.Split(string(out), "\n") {
+		if br != "" {
+			brs = append(brs, br)
+		}
+	}
+	return brs, nil
+}
+
+// SwitchBranch updates the working directory to the given branch
+func (hr *HgRepo) SwitchBranch(branch string) error {
+	if _, err := hr.runCmd("update", branch); err != nil {
+		return err
+	}
+	hr.CacheRefresh()
+	return nil
+}
+
+// hgShelve are the args to enable the shelve extension, which hg uses for
+// stashing
+var hgShelve = []string{"--config", "extensions.shelve="}
+
+// Stash shelves the uncommitted changes with the given message, reverting
+// the working directory
+func (hr *HgRepo) Stash(message string) error {
+	args := append([]string{"shelve"}, hgShelve...)
+	if message != "" {
+		args = append(args, "-m", message)
+	}
+	if _, err := hr.runCmd(args...); err != nil {
+		return err
+	}
+	hr.CacheRefresh()
+	return nil
+}
+
+// StashList returns the descriptions of the shelved changes, most recent first
+func (hr *HgRepo) StashList() ([]string, error) {
+	out, err := hr.runCmd(append([]string{"shelve"}, append(hgShelve, "--list")...)...)
+	if err != nil {
+		return nil, err
+	}
+	var sl []string
+	for _, ln := range strings.Split(string(out), "\n") {
+		if ln != "" {
+			sl = append(sl, ln)
+		}
+	}
+	return sl, nil
+}
+
+// StashPop restores the most recently shelved changes, and removes the shelf
+func (hr *HgRepo) StashPop() error {
+	if _, err := hr.runCmd(append([]string{"unshelve"}, hgShelve...)...); err != nil {
+		return err
+	}
+	hr.CacheRefresh()
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tempHgRepo makes a local hg repository in a temporary directory, with
+// two commits of a.txt, returning it and a function that removes it
+func tempHgRepo(t *testing.T) (*HgRepo, func()) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg not found")
+	}
+	dir, err := ioutil.TempDir("", "vci-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hg := func(args ...string) {
+		cmd := exec.Command("hg", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("hg %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(fn, txt string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(txt), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hg("init")
+	// vcs.NewRepo requires a default path
+	write(filepath.Join(".hg", "hgrc"), "[ui]\nusername = Tester <tester@example.com>\n[paths]\ndefault = "+dir+"\n")
+	write("a.txt", "one\ntwo\n")
+	hg("add", "a.txt")
+	hg("commit", "-q", "-m", "first")
+	write("a.txt", "one\n2\nthree\n")
+	hg("commit", "-q", "-m", "second")
+	r, err := NewRepo("", dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return r.(*HgRepo), func() { os.RemoveAll(dir) }
+}
+
+func TestHgLogBlameShow(t *testing.T) {
+	hr, done := tempHgRepo(t)
+	defer done()
+	lg, err := hr.Log("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lg) != 2 || lg[0].Message != "second" || lg[1].Message != "first" || lg[0].Author != "Tester" || lg[0].Email != "tester@example.com" || lg[0].Date.IsZero() {
+		t.Fatalf("bad log: %+v", lg)
+	}
+	bl, err := hr.Blame(filepath.Join(hr.LocalPath(), "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bl) != 3 || !strings.HasPrefix(lg[1].Rev, bl[0].Rev) || !strings.HasPrefix(lg[0].Rev, bl[1].Rev) || bl[2].Text != "three" || bl[0].Date.IsZero() {
+		t.Fatalf("bad blame: %+v", bl)
+	}
+	txt, err := hr.Show("a.txt", lg[1].Rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(txt) != "one\ntwo\n" {
+		t.Fatalf("bad show: %q", txt)
+	}
+	ioutil.WriteFile(filepath.Join(hr.LocalPath(), "a.txt"), []byte("one\n2\n3\n"), 0644)
+	df, err := hr.Diff("a.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(df), "-three\n+3\n") {
+		t.Fatalf("bad diff: %s", df)
+	}
+}
+
+func TestHgBranchesStash(t *testing.T) {
+	hr, done := tempHgRepo(t)
+	defer done()
+	brs, err := hr.LocalBranches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(brs, " ") != "default" {
+		t.Fatalf("bad branches: %v", brs)
+	}
+	if err := hr.SwitchBranch("default"); err != nil {
+		t.Fatal(err)
+	}
+	sl, err := hr.StashList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sl) != 0 {
+		t.Fatalf("bad empty stash list: %v", sl)
+	}
+	fn := filepath.Join(hr.LocalPath(), "a.txt")
+	ioutil.WriteFile(fn, []byte("changed\n"), 0644)
+	if err := hr.Stash("wip"); err != nil {
+		t.Fatal(err)
+	}
+	if txt, _ := ioutil.ReadFile(fn); string(txt) != "one\n2\nthree\n" {
+		t.Fatalf("stash did not revert: %q", txt)
+	}
+	sl, err = hr.StashList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sl) != 1 || !strings.Contains(sl[0], "wip") {
+		t.Fatalf("bad stash list: %v", sl)
+	}
+	if err := hr.StashPop(); err != nil {
+		t.Fatal(err)
+	}
+	if txt, _ := ioutil.ReadFile(fn); string(txt) != "changed\n" {
+		t.Fatalf("stash pop did not restore: %q", txt)
+	}
+	if err := hr.SwitchBranch("nonesuch"); err == nil {
+		t.Fatal("expected error switching to missing branch")
+	}
+}
+
+func TestHgFiles(t *testing.T) {
+	hr, done := tempHgRepo(t)
+	defer done()
+	testRepoFiles(t, hr)
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotSupported is returned when the VCS does not support an operation,
+	// e.g., branches or stashes in svn
+	ErrNotSupported = errors.New("Operation not supported by this VCS")
+)
+
+// Commit is one commit (revision) in the history of the repository
+type Commit struct {
+	Rev     string    `desc:"revision: the commit hash for git, the revision number for svn"`
+	Date    time.Time `desc:"date of the commit"`
+	Author  string    `desc:"name of the author of the commit"`
+	Email   string    `desc:"email of the author of the commit, if known"`
+	Message string    `width:"80" desc:"commit message -- just the first line (subject) for git"`
+}
+
+// Log is the history of commits of a file or the repository, most recent first
+type Log []*Commit
+
+// BlameLine is the last change to one line of a file, as reported by Blame
+type BlameLine struct {
+	Rev    string    `desc:"revision of the commit that last changed the line"`
+	Date   time.Time `desc:"date of the commit"`
+	Author string    `desc:"author of the commit"`
+	Text   string    `desc:"text of the line"`
+}
+
+// Blame is the last change to each line of a file, in line order
+type Blame []BlameLine
@@ -5,18 +5,24 @@
 package vci
 
 import (
+	"encoding/xml"
 	"fmt"
+	"io/ioutil"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/vcs"
 )
 
 type SvnRepo struct {
 	vcs.Repo
-	FilesAll      map[string]struct{}
-	FilesModified map[string]struct{}
-	FilesAdded    map[string]struct{}
+	FilesAll        map[string]struct{}
+	FilesModified   map[string]struct{}
+	FilesAdded      map[string]struct{}
+	FilesConflicted map[string]struct{}
 }
 
 func (gr *SvnRepo) CacheFileNames() {
@@ -58,10 +64,25 @@ func (gr *SvnRepo) CacheFilesAdded() {
 	// }
 }
 
+func (gr *SvnRepo) CacheFilesConflicted() {
+	gr.FilesConflicted = make(map[string]struct{}, 10)
+	cmd := exec.Command("svn", "status")
+	cmd.Dir = gr.LocalPath()
+	bytes, _ := cmd.Output()
+	sep := byte(10) // Linefeed is the separator - will this work cross platform?
+	lines := strings.Split(string(bytes), string(sep))
+	for _, ln := range lines {
+		if len(ln) > 8 && ln[0] == 'C' { // 7 status columns, then a space
+			gr.FilesConflicted[strings.TrimSpace(ln[8:])] = struct{}{}
+		}
+	}
+}
+
 func (gr *SvnRepo) CacheRefresh() {
 	gr.CacheFileNames()
 	gr.CacheFilesAdded()
 	gr.CacheFilesModified()
+	gr.CacheFilesConflicted()
 }
 
 func (gr *SvnRepo) InRepo(filename string) bool {
@@ -88,6 +109,75 @@ func (gr *SvnRepo) IsAdded(filename string) bool {
 	return has
 }
 
+func (gr *SvnRepo) IsConflicted(filename string) bool {
+	if gr.FilesConflicted == nil {
+		gr.CacheFilesConflicted()
+	}
+	if filepath.IsAbs(filename) {
+		if rel, err := filepath.Rel(gr.LocalPath(), filename); err == nil {
+			filename = rel
+		}
+	}
+	_, has := gr.FilesConflicted[filename]
+	return has
+}
+
+// ConflictVersions returns the base, ours and theirs versions of a file with
+// merge conflicts, from the files that svn leaves alongside it: ours is
+// filename.mine, and base and theirs are the older and newer of the
+// filename.rN revisions
+func (gr *SvnRepo) ConflictVersions(filename string) (base, ours, theirs []byte, err error) {
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(gr.LocalPath(), filename)
+	}
+	revs, _ := filepath.Glob(filename + ".r*")
+	var revnos []int
+	var revfns []string
+	for _, rf := range revs {
+		rn, err := strconv.Atoi(strings.TrimPrefix(rf, filename+".r"))
+		if err == nil {
+			revnos = append(revnos, rn)
+			revfns = append(revfns, rf)
+		}
+	}
+	if len(revnos) < 2 {
+		return nil, nil, nil, fmt.Errorf("vci: could not find base and their revisions of %v", filename)
+	}
+	bi, ti := 0, 0
+	for i, rn := range revnos {
+		if rn < revnos[bi] {
+			bi = i
+		}
+		if rn > revnos[ti] {
+			ti = i
+		}
+	}
+	if base, err = ioutil.ReadFile(revfns[bi]); err != nil {
+		return nil, nil, nil, err
+	}
+	if theirs, err = ioutil.ReadFile(revfns[ti]); err != nil {
+		return nil, nil, nil, err
+	}
+	if ours, err = ioutil.ReadFile(filename + ".mine"); err != nil {
+		return nil, nil, nil, err
+	}
+	return base, ours, theirs, nil
+}
+
+// MarkResolved marks the merge conflicts in the file as resolved, accepting
+// the working copy
+func (gr *SvnRepo) MarkResolved(filename string) error {
+	oscmd := exec.Command("svn", "resolve", "--accept", "working", filename)
+	oscmd.Dir = gr.LocalPath()
+	stdoutStderr, err := oscmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("%s\n", stdoutStderr)
+		return err
+	}
+	gr.CacheFilesConflicted()
+	return nil
+}
+
 // Add adds the file to the repo
 func (gr *SvnRepo) Add(filename string) error {
 	oscmd := exec.Command("svn", "add", filename)
@@ -162,3 +252,136 @@ func (gr *SvnRepo) RevertFile(filename string) error {
 	gr.CacheFilesModified()
 	return nil
 }
+
+// runCmd runs svn with given args in the top directory of the working copy,
+// returning its output -- errors include what svn reported
+func (gr *SvnRepo) runCmd(args ...string) ([]byte, error) {
+	cmd := exec.Command("svn", args...)
+	cmd.Dir = gr.LocalPath()
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		err = fmt.Errorf("svn %v: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+	}
+	return out, err
+}
+
+// svnLog is the xml output of svn log --xml
+type svnLog struct {
+	Entries []struct {
+		Rev    string `xml:"revision,attr"`
+		Author string `xml:"author"`
+		Date   string `xml:"date"`
+		Msg    string `xml:"msg"`
+	} `xml:"logentry"`
+}
+
+// Log returns the history of commits of the file, most recent first -- the
+// whole working copy if filename is empty
+func (gr *SvnRepo) Log(filename string) (Log, error) {
+	args := []string{"log", "--xml"}
+	if filename != "" {
+		args = append(args, filename)
+	}
+	out, err := gr.runCmd(args...)
+	if err != nil {
+		return nil, err
+	}
+	var sl svnLog
+	if err := xml.Unmarshal(out, &sl); err != nil {
+		return nil, err
+	}
+	lg := make(Log, len(sl.Entries))
+	for i, e := range sl.Entries {
+		dt, _ := time.Parse(time.RFC3339Nano, e.Date)
+		lg[i] = &Commit{Rev: e.Rev, Date: dt, Author: e.Author, Message: strings.TrimSpace(e.Msg)}
+	}
+	return lg, nil
+}
+
+// svnBlame is the xml output of svn blame --xml -- lines with local changes
+// have no commit
+type svnBlame struct {
+	Entries []struct {
+		Commit struct {
+			Rev    string `xml:"revision,attr"`
+			Author string `xml:"author"`
+			Date   string `xml:"date"`
+		} `xml:"commit"`
+	} `xml:"target>entry"`
+}
+
+// Blame returns the commit that last changed each line of the file, as of
+// the last update -- the text is from svn cat, as the xml output omits it
+func (gr *SvnRepo) Blame(filename string) (Blame, error) {
+	out, err := gr.runCmd("blame", "--xml", filename)
+	if err != nil {
+		return nil, err
+	}
+	txt, err := gr.Show(filename, "")
+	if err != nil {
+		return nil, err
+	}
+	return parseSvnBlame(out, txt)
+}
+
+// parseSvnBlame returns the Blame from the output of svn blame --xml, and
+// the text of the file
+func parseSvnBlame(out, txt []byte) (Blame, error) {
+	var sb svnBlame
+	if err := xml.Unmarshal(out, &sb); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(txt), "\n")
+	bl := make(Blame, len(sb.Entries))
+	for i, e := range sb.Entries {
+		dt, _ := time.Parse(time.RFC3339Nano, e.Commit.Date)
+		bl[i] = BlameLine{Rev: e.Commit.Rev, Date: dt, Author: e.Commit.Author}
+		if i < len(lines) {
+			bl[i].Text = lines[i]
+		}
+	}
+	return bl, nil
+}
+
+// Diff returns the differences between the file at the given revision and
+// its current contents, in unified diff format -- rev is BASE if empty
+func (gr *SvnRepo) Diff(filename, rev string) ([]byte, error) {
+	if rev == "" {
+		rev = "BASE"
+	}
+	return gr.runCmd("diff", "-r", rev, filename)
+}
+
+// Show returns the contents of the file at the given revision -- rev is
+// BASE if empty
+func (gr *SvnRepo) Show(filename, rev string) ([]byte, error) {
+	if rev == "" {
+		rev = "BASE"
+	}
+	return gr.runCmd("cat", "-r", rev, filename)
+}
+
+// LocalBranches is not supported: svn branches are just directories
+func (gr *SvnRepo) LocalBranches() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// SwitchBranch is not supported: svn branches are just directories
+func (gr *SvnRepo) SwitchBranch(branch string) error {
+	return ErrNotSupported
+}
+
+// Stash is not supported
+func (gr *SvnRepo) Stash(message string) error {
+	return ErrNotSupported
+}
+
+// StashList is not supported
+func (gr *SvnRepo) StashList() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// StashPop is not supported
+func (gr *SvnRepo) StashPop() error {
+	return ErrNotSupported
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"testing"
+	"time"
+)
+
+// svnBlameSample is the output of svn blame --xml for a three-line file,
+// the last line of which has local changes
+const svnBlameSample = `<?xml version="1.0" encoding="UTF-8"?>
+<blame>
+<target
+   path="a.txt">
+<entry
+   line-number="1">
+<commit
+   revision="1">
+<author>tester</author>
+<date>2019-01-02T15:04:05.123456Z</date>
+</commit>
+</entry>
+<entry
+   line-number="2">
+<commit
+   revision="2">
+<author>other</author>
+<date>2019-01-03T10:00:00.000000Z</date>
+</commit>
+</entry>
+<entry
+   line-number="3">
+</entry>
+</target>
+</blame>
+`
+
+func TestSvnBlameParse(t *testing.T) {
+	bl, err := parseSvnBlame([]byte(svnBlameSample), []byte("one\n2\nthree\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bl) != 3 {
+		t.Fatalf("bad blame length: %v: %+v", len(bl), bl)
+	}
+	dt := time.Date(2019, 1, 2, 15, 4, 5, 123456000, time.UTC)
+	if bl[0].Rev != "1" || bl[0].Author != "tester" || !bl[0].Date.Equal(dt) || bl[0].Text != "one" {
+		t.Errorf("bad blame line 1: %+v", bl[0])
+	}
+	if bl[1].Rev != "2" || bl[1].Author != "other" || bl[1].Text != "2" {
+		t.Errorf("bad blame line 2: %+v", bl[1])
+	}
+	if bl[2].Rev != "" || bl[2].Author != "" || !bl[2].Date.IsZero() || bl[2].Text != "three" {
+		t.Errorf("bad blame line 3 (local change): %+v", bl[2])
+	}
+}
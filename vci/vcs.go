@@ -6,7 +6,6 @@ package vci
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/Masterminds/vcs"
 )
@@ -32,6 +31,9 @@ type Repo interface {
 	// CacheFilesAdded gets a list of files added to repository but not yet committed
 	CacheFilesAdded()
 
+	// CacheFilesConflicted gets a list of files with unresolved merge conflicts
+	CacheFilesConflicted()
+
 	// CacheRefresh calls all of the Cache functions
 	CacheRefresh()
 
@@ -46,6 +48,17 @@ type Repo interface {
 	// IsAdded checks for the file in the cached FilesAdded list
 	IsAdded(filename string) bool
 
+	// IsConflicted checks for the file in the cached FilesConflicted list
+	IsConflicted(filename string) bool
+
+	// ConflictVersions returns the three versions of a file with merge conflicts:
+	// the common ancestor (base), the local one (ours) and the one being merged (theirs)
+	// -- base is nil if there is no common ancestor, e.g., if both added the file
+	ConflictVersions(filename string) (base, ours, theirs []byte, err error)
+
+	// MarkResolved marks the merge conflicts in the file as resolved, with its current contents
+	MarkResolved(filename string) error
+
 	// Add adds the file to the repo
 	Add(filename string) error
 
@@ -63,6 +76,37 @@ type Repo interface {
 
 	// RevertFile reverts a single file
 	RevertFile(filename string) error
+
+	// Log returns the history of commits of the file, most recent first --
+	// the whole repository if filename is empty
+	Log(filename string) (Log, error)
+
+	// Blame returns the commit that last changed each line of the file
+	Blame(filename string) (Blame, error)
+
+	// Diff returns the differences between the file at the given revision and
+	// its current contents, in unified diff format -- rev is the last commit if empty
+	Diff(filename, rev string) ([]byte, error)
+
+	// Show returns the contents of the file at the given revision -- rev is
+	// the last commit if empty
+	Show(filename, rev string) ([]byte, error)
+
+	// LocalBranches returns the names of the local branches -- vcs.Repo
+	// Branches returns those of the remote, and Current the current one
+	LocalBranches() ([]string, error)
+
+	// SwitchBranch switches the working copy to the given branch
+	SwitchBranch(branch string) error
+
+	// Stash saves the uncommitted changes with the given message, reverting the working copy
+	Stash(message string) error
+
+	// StashList returns the descriptions of the saved stashes, most recent first
+	StashList() ([]string, error)
+
+	// StashPop restores the most recently stashed changes, and removes the stash
+	StashPop() error
 }
 
 func NewRepo(remote, local string) (Repo, error) {
@@ -78,9 +122,13 @@ func NewRepo(remote, local string) (Repo, error) {
 			r.Repo = repo
 			return r, err
 		case vcs.Hg:
-			err = fmt.Errorf("Hg version control not yet supported")
+			r := &HgRepo{}
+			r.Repo = repo
+			return r, err
 		case vcs.Bzr:
-			err = fmt.Errorf("Bzr version control not yet supported")
+			r := &BzrRepo{}
+			r.Repo = repo
+			return r, err
 		}
 	}
 	return nil, err
@@ -0,0 +1,97 @@
+// Copyright (c) 2019, The Gide Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testRepoFiles tests the file status caches and the file operations of a
+// repo made by one of the tempRepo functions, with a.txt committed as
+// "one\n2\nthree\n"
+func testRepoFiles(t *testing.T, r Repo) {
+	dir := r.LocalPath()
+	write := func(fn, txt string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(txt), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(fn string) bool {
+		_, err := os.Stat(filepath.Join(dir, fn))
+		return err == nil
+	}
+
+	r.CacheFileNames()
+	if !r.InRepo("a.txt") || !r.InRepo(filepath.Join(dir, "a.txt")) || r.InRepo("b.txt") {
+		t.Fatalf("InRepo: a.txt %v, abs a.txt %v, b.txt %v -- want true, true, false",
+			r.InRepo("a.txt"), r.InRepo(filepath.Join(dir, "a.txt")), r.InRepo("b.txt"))
+	}
+	r.CacheFilesModified()
+	if r.IsModified("a.txt") {
+		t.Fatal("a.txt modified before change")
+	}
+	write("a.txt", "changed\n")
+	r.CacheFilesModified()
+	if !r.IsModified("a.txt") || !r.IsModified(filepath.Join(dir, "a.txt")) {
+		t.Fatal("a.txt not modified after change")
+	}
+	if err := r.RevertFile("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if txt, _ := ioutil.ReadFile(filepath.Join(dir, "a.txt")); string(txt) != "one\n2\nthree\n" {
+		t.Fatalf("revert did not restore: %q", txt)
+	}
+	if r.IsModified("a.txt") {
+		t.Fatal("a.txt modified after revert")
+	}
+
+	write("b.txt", "bee\n")
+	r.CacheFilesAdded()
+	if r.IsAdded("b.txt") {
+		t.Fatal("b.txt added before Add")
+	}
+	if err := r.Add("b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsAdded("b.txt") || !r.InRepo("b.txt") {
+		t.Fatalf("after Add: added %v, in repo %v", r.IsAdded("b.txt"), r.InRepo("b.txt"))
+	}
+	if err := r.CommitFile("b.txt", "add b"); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsAdded("b.txt") || !r.InRepo("b.txt") {
+		t.Fatalf("after commit: added %v, in repo %v", r.IsAdded("b.txt"), r.InRepo("b.txt"))
+	}
+	lg, err := r.Log("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lg) != 1 || lg[0].Message != "add b" {
+		t.Fatalf("bad log after commit: %+v", lg)
+	}
+
+	if err := r.Move("b.txt", "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if exists("b.txt") || !exists("c.txt") {
+		t.Fatal("Move did not rename the file")
+	}
+	if r.InRepo("b.txt") || !r.InRepo("c.txt") {
+		t.Fatalf("after Move: b.txt in repo %v, c.txt %v", r.InRepo("b.txt"), r.InRepo("c.txt"))
+	}
+
+	if err := r.Remove("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if exists("a.txt") || r.InRepo("a.txt") {
+		t.Fatalf("after Remove: exists %v, in repo %v", exists("a.txt"), r.InRepo("a.txt"))
+	}
+	if err := r.Add("nonesuch.txt"); err == nil {
+		t.Fatal("expected error adding missing file")
+	}
+}